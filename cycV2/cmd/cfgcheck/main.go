@@ -0,0 +1,74 @@
+// cmd/cfgcheck 设备配置离线校验：与加载/热加载前的校验相同，列出全部问题后以退出码表示结果
+//
+//	cfgcheck devices.json
+//	cfgcheck -json -strict site1.json site2.yaml
+//
+// 支持 JSON/YAML/TOML，展开 include 与环境变量后校验（环境变量需与运行时一致）。
+// 退出码：0 通过；1 有 error（-strict 时 warning 也算）；2 参数错误
+package main
+
+import (
+	"cycV2/internal/device"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	// 注册全部适配器，校验 AdapterName 与实际加载一致
+	_ "cycV2/internal/protocol/bacnet"
+	_ "cycV2/internal/protocol/can"
+	_ "cycV2/internal/protocol/canopen"
+	_ "cycV2/internal/protocol/dlt645"
+	_ "cycV2/internal/protocol/dnp3"
+	_ "cycV2/internal/protocol/framed"
+	_ "cycV2/internal/protocol/iec104"
+	_ "cycV2/internal/protocol/iec61850"
+	_ "cycV2/internal/protocol/j1939"
+	_ "cycV2/internal/protocol/modbus"
+	_ "cycV2/internal/protocol/opcua"
+	_ "cycV2/internal/protocol/s7"
+	_ "cycV2/internal/protocol/snmp"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "以JSON输出问题列表")
+	strict := flag.Bool("strict", false, "warning 也视为失败")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s [-json] [-strict] 配置文件...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	all := []device.Issue{}
+	for _, path := range flag.Args() {
+		_, report := device.ValidateConfigFile(path)
+		errs, warns := len(report.Errors()), len(report.Warnings())
+		if errs > 0 || (*strict && warns > 0) {
+			failed = true
+		}
+		if *asJSON {
+			all = append(all, report.Issues...)
+			continue
+		}
+		for _, i := range report.Issues {
+			fmt.Println(i)
+		}
+		fmt.Printf("%s: %d error(s), %d warning(s)\n", path, errs, warns)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(all); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,70 @@
+// cmd/cfgmigrate 旧版 cyc20250422 配置（configs/devices + configs/points）转换为 cycV2 设备配置
+//
+//	cfgmigrate -config /opt/cyc/configs -dry-run
+//	cfgmigrate -config /opt/cyc/configs -o devices.json
+//
+// 无法映射或需人工确认的字段逐条输出到标准错误；-dry-run 只报告不写文件。
+// 退出码：0 成功；1 -strict 时存在需确认的字段；2 参数或读取错误
+package main
+
+import (
+	"cycV2/internal/migrate"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	// 注册全部适配器，转换结果按实际加载时的规则校验
+	_ "cycV2/internal/protocol/bacnet"
+	_ "cycV2/internal/protocol/can"
+	_ "cycV2/internal/protocol/canopen"
+	_ "cycV2/internal/protocol/dlt645"
+	_ "cycV2/internal/protocol/dnp3"
+	_ "cycV2/internal/protocol/framed"
+	_ "cycV2/internal/protocol/iec104"
+	_ "cycV2/internal/protocol/iec61850"
+	_ "cycV2/internal/protocol/j1939"
+	_ "cycV2/internal/protocol/modbus"
+	_ "cycV2/internal/protocol/opcua"
+	_ "cycV2/internal/protocol/s7"
+	_ "cycV2/internal/protocol/snmp"
+)
+
+func main() {
+	configDir := flag.String("config", "configs", "旧版配置目录，含 devices 与 points 子目录")
+	out := flag.String("o", "", "输出的 cycV2 配置文件，默认标准输出")
+	dryRun := flag.Bool("dry-run", false, "只报告无法映射的字段，不输出配置")
+	strict := flag.Bool("strict", false, "存在需确认的字段时以退出码1结束")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	result, err := migrate.ConvertDirs(filepath.Join(*configDir, "devices"), filepath.Join(*configDir, "points"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, n := range result.Notes {
+		fmt.Fprintln(os.Stderr, n)
+	}
+	fmt.Fprintf(os.Stderr, "%d template(s), %d device(s), %d note(s)\n", len(result.Templates), len(result.Devices), len(result.Notes))
+
+	if !*dryRun {
+		data, err := result.JSON()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if *out == "" {
+			os.Stdout.Write(data)
+		} else if err := os.WriteFile(*out, data, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *strict && len(result.Notes) > 0 {
+		os.Exit(1)
+	}
+}
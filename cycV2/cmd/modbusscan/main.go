@@ -0,0 +1,167 @@
+// cmd/modbusscan 现场调试用的 Modbus 站号/寄存器扫描工具
+//
+//	modbusscan -mode tcp -addr 192.168.1.10:502 -start 1 -end 10 -probes hr:0-199/20,ir:0-99
+//	modbusscan -mode rtu -addr /dev/ttyS1 -baud 9600 -skeleton bus1.json -bus bus1
+package main
+
+import (
+	"cycV2/internal/device"
+	"cycV2/internal/protocol/modbus"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	mode := flag.String("mode", "tcp", "tcp | rtu")
+	addr := flag.String("addr", "127.0.0.1:502", "TCP地址或串口设备")
+	baud := flag.Int("baud", 9600, "波特率(rtu)")
+	parity := flag.String("parity", "N", "校验位 N/E/O(rtu)")
+	startID := flag.Int("start", 1, "起始站号")
+	endID := flag.Int("end", 247, "结束站号")
+	timeoutMs := flag.Int("timeout", 200, "单次请求超时(ms)")
+	delayMs := flag.Int("delay", 0, "请求间隔(ms)")
+	probes := flag.String("probes", "", "探测区间, 例: hr:0-99/10,co:0-79")
+	refine := flag.Bool("refine", false, "块读取异常时逐地址细化")
+	skeleton := flag.String("skeleton", "", "输出设备配置骨架JSON文件")
+	busID := flag.String("bus", "", "骨架配置的busId")
+	prefix := flag.String("prefix", "dev", "骨架配置的设备名前缀")
+	flag.Parse()
+	// 站号先校验范围再转换，避免 -end 300 之类的值溢出回绕
+	for _, f := range []struct {
+		name string
+		v    int
+	}{{"start", *startID}, {"end", *endID}} {
+		if err := checkRange(f.name, f.v, 1, 247); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *startID > *endID {
+		log.Fatalf("-start %d 大于 -end %d", *startID, *endID)
+	}
+
+	cfg := map[string]interface{}{
+		"mode":      *mode,
+		"address":   *addr,
+		"slaveId":   *startID,
+		"timeoutMs": *timeoutMs,
+		"baudrate":  *baud,
+		"parity":    *parity,
+	}
+	adapter, err := modbus.NewModbusAdapter(cfg)
+	if err != nil {
+		log.Fatalf("创建适配器失败: %v", err)
+	}
+	defer adapter.Disconnect()
+
+	probeList, err := parseProbes(*probes, *refine)
+	if err != nil {
+		log.Fatalf("探测区间格式错误: %v", err)
+	}
+	scanner, err := modbus.NewScanner(adapter.(*modbus.ModbusAdapter), modbus.ScanConfig{
+		StartID:   uint8(*startID),
+		EndID:     uint8(*endID),
+		Timeout:   time.Duration(*timeoutMs) * time.Millisecond,
+		IdleDelay: time.Duration(*delayMs) * time.Millisecond,
+		Probes:    probeList,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := scanner.Scan()
+	if err != nil {
+		log.Fatalf("扫描失败: %v", err)
+	}
+
+	for _, s := range report.Responsive() {
+		fmt.Printf("slave %d:\n", s.SlaveID)
+		for _, r := range s.Ranges {
+			fmt.Printf("  %s %d-%d (%d)\n", r.Func, r.StartAddr, int(r.StartAddr)+int(r.Quantity)-1, r.Quantity)
+		}
+		for k, n := range s.Exceptions {
+			fmt.Printf("  exception %s x%d\n", k, n)
+		}
+	}
+	fmt.Printf("在线站号%d个, 耗时%v\n", len(report.Responsive()), report.Duration)
+
+	if *skeleton != "" {
+		base := device.DeviceConfig{
+			BusId:       *busID,
+			AdapterName: "modbus",
+			Protocol:    "modbus",
+			Params:      cfg,
+		}
+		if *mode == "tcp" {
+			base.IpAddr = *addr
+		}
+		out, _ := json.MarshalIndent(device.SkeletonFromScan(report, base, *prefix), "", "  ")
+		if err := os.WriteFile(*skeleton, out, 0644); err != nil {
+			log.Fatalf("写入骨架配置失败: %v", err)
+		}
+		fmt.Printf("骨架配置已写入 %s\n", *skeleton)
+	}
+}
+
+// parseProbes 解析 "hr:0-99/10,ir:0-49" 形式的探测区间
+func parseProbes(s string, refine bool) ([]modbus.ProbeRange, error) {
+	if s == "" {
+		probes := modbus.DefaultProbes()
+		for i := range probes {
+			probes[i].Refine = refine
+		}
+		return probes, nil
+	}
+	var out []modbus.ProbeRange
+	for _, item := range strings.Split(s, ",") {
+		fn, rng, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok {
+			return nil, fmt.Errorf("missing ':' in %q", item)
+		}
+		p := modbus.ProbeRange{Func: fn, Refine: refine}
+		if r, bs, ok := strings.Cut(rng, "/"); ok {
+			n, err := strconv.Atoi(bs)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkRange("block size", n, 1, 65535); err != nil {
+				return nil, err
+			}
+			p.BlockSize = uint16(n)
+			rng = r
+		}
+		lo, hi, ok := strings.Cut(rng, "-")
+		if !ok {
+			return nil, fmt.Errorf("missing '-' in %q", item)
+		}
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, err
+		}
+		end, err := strconv.Atoi(hi)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkRange("address", start, 0, 65535); err != nil {
+			return nil, err
+		}
+		if err := checkRange("address", end, 0, 65535); err != nil {
+			return nil, err
+		}
+		p.StartAddr, p.EndAddr = uint16(start), uint16(end)
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// checkRange 命令行数值转为 uint8/uint16 前的范围检查
+func checkRange(name string, v, lo, hi int) error {
+	if v < lo || v > hi {
+		return fmt.Errorf("%s %d out of range %d..%d", name, v, lo, hi)
+	}
+	return nil
+}
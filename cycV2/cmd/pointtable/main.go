@@ -0,0 +1,133 @@
+// cmd/pointtable 点表导入导出：Excel/CSV 点表转为 PointConfig JSON，或把设备点位导出为 xlsx 便于编辑
+//
+//	pointtable import [-sheet BMS] [-col 通道=params.channel] bms.xlsx > points.json
+//	pointtable export -config devices.yaml -device bms1 -o bms1.xlsx
+//
+// 导入时逐单元格校验，错误以单元格引用列出。退出码：0 成功；1 点表有错误；2 参数或文件错误
+package main
+
+import (
+	"cycV2/internal/device"
+	"cycV2/internal/pointtable"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// columnFlag 可重复的 -col 表头=字段
+type columnFlag map[string]string
+
+func (c columnFlag) String() string { return fmt.Sprint(map[string]string(c)) }
+
+func (c columnFlag) Set(v string) error {
+	header, field, ok := strings.Cut(v, "=")
+	if !ok || header == "" || field == "" {
+		return fmt.Errorf("want 表头=字段, got %q", v)
+	}
+	c[header] = field
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "import":
+		importTable(os.Args[2:])
+	case "export":
+		exportTable(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "用法: %s import [-sheet 工作表] [-col 表头=字段]... [-o points.json] 点表.xlsx|点表.csv\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "      %s export -config 配置文件 [-device 设备名] [-sheet 工作表] -o 点表.xlsx\n", os.Args[0])
+	os.Exit(2)
+}
+
+func importTable(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	sheet := fs.String("sheet", "", "xlsx 工作表，默认第一个")
+	out := fs.String("o", "", "输出 JSON 文件，默认标准输出")
+	cols := columnFlag{}
+	fs.Var(cols, "col", "额外的列映射 表头=字段，可重复")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	points, err := pointtable.ImportFile(fs.Arg(0), pointtable.Options{Sheet: *sheet, Columns: cols})
+	var ie *pointtable.ImportError
+	if errors.As(err, &ie) {
+		for _, e := range ie.Errors {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d error(s)\n", fs.Arg(0), len(ie.Errors))
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	data, err := json.MarshalIndent(points, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Fprintf(os.Stderr, "%d point(s) written to %s\n", len(points), *out)
+}
+
+func exportTable(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	config := fs.String("config", "", "设备配置文件（JSON/YAML/TOML）")
+	name := fs.String("device", "", "设备名，配置中只有一个设备时可省略")
+	sheet := fs.String("sheet", "", "工作表名，默认为设备名")
+	out := fs.String("o", "", "输出 xlsx 文件")
+	fs.Parse(args)
+	if *config == "" || *out == "" || fs.NArg() != 0 {
+		usage()
+	}
+
+	cfgs, err := device.LoadDeviceConfigs(*config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var cfg *device.DeviceConfig
+	for _, c := range cfgs {
+		if c.Name == *name || (*name == "" && len(cfgs) == 1) {
+			cfg = c
+		}
+	}
+	if cfg == nil {
+		var names []string
+		for _, c := range cfgs {
+			names = append(names, c.Name)
+		}
+		fmt.Fprintf(os.Stderr, "device %q not found, use -device (devices: %s)\n", *name, strings.Join(names, ", "))
+		os.Exit(2)
+	}
+	if *sheet == "" {
+		*sheet = cfg.Name
+	}
+	if err := pointtable.ExportFile(*out, *sheet, cfg.Points); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Fprintf(os.Stderr, "%d point(s) of %s written to %s\n", len(cfg.Points), cfg.Name, *out)
+}
@@ -5,10 +5,25 @@ go 1.22.9
 require (
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/grid-x/modbus v0.0.0-20250403195434-0b5f88db24d0
+	github.com/grid-x/serial v0.0.0-20211107191517-583c7356b3aa
 	github.com/mitchellh/mapstructure v1.5.0
+	golang.org/x/sys v0.26.0
 )
 
 require (
-	github.com/grid-x/serial v0.0.0-20211107191517-583c7356b3aa // indirect
-	golang.org/x/sys v0.13.0 // indirect
+	github.com/BurntSushi/toml v1.5.0
+	github.com/gosnmp/gosnmp v1.38.0
+	github.com/xuri/excelize/v2 v2.9.0
+	golang.org/x/text v0.19.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
+	github.com/richardlehane/mscfb v1.0.4 // indirect
+	github.com/richardlehane/msoleps v1.0.4 // indirect
+	github.com/xuri/efp v0.0.0-20240408161823-9ad904a10d6d // indirect
+	github.com/xuri/nfp v0.0.0-20240318013403-ab9948c2c4a7 // indirect
+	golang.org/x/crypto v0.28.0 // indirect
+	golang.org/x/net v0.30.0 // indirect
 )
@@ -1,6 +1,8 @@
 // internal/device/config.go
 package device
 
+import "strings"
+
 // internal/device/config.go
 type PointConfig struct {
 	Name      string                 `json:"name"`
@@ -13,6 +15,27 @@ type PointConfig struct {
 	SwapReg   bool                   `json:"swapReg"`   // 多寄存器高低字交换
 	ByteOrder string                 `json:"byteOrder"` // big/little
 	Rw        string                 `json:"rw"`        // "r", "w", "rw"
+	Unit      string                 `json:"unit"`      // 工程单位
+	Scale     float64                `json:"scale"`     // 系数，工程值 = 原始值 × scale；0 与 1 不换算
+	Alarm     *AlarmConfig           `json:"alarm,omitempty"`
+	ScanClass string                 `json:"scanClass"` // 扫描等级 fast/normal/slow/once，空为 normal（或按 scanGroups），见 scan.go
+}
+
+// AlarmConfig 点位越限告警设置（工程值）
+type AlarmConfig struct {
+	Enable    bool     `json:"enable"`
+	HighLimit *float64 `json:"highLimit,omitempty"`
+	LowLimit  *float64 `json:"lowLimit,omitempty"`
+}
+
+// writable 是否可写：rw 含 w 才可写，未配置视为只读
+func (p PointConfig) writable() bool {
+	return strings.Contains(p.Rw, "w")
+}
+
+// scaled 是否需要按系数换算
+func (p PointConfig) scaled() bool {
+	return p.Scale != 0 && p.Scale != 1 && p.DataType != "bool"
 }
 
 type DeviceConfig struct {
@@ -25,6 +48,12 @@ type DeviceConfig struct {
 	SlaveId     uint8                  `json:"slaveId"`     //从站id
 	AdapterName string                 `json:"AdapterName"` //适配器类型  比如:modbus、can等
 	IntervalMs  int                    `json:"interval_ms"` // 采集周期（毫秒）
+	DBCFile     string                 `json:"dbcFile"`     // CAN设备DBC文件，配置后由DBC信号生成点位
+	DBCMessages []string               `json:"dbcMessages"` // 只导出指定报文，为空导出全部
+	Template    string                 `json:"template"`    // 引用的设备模板ID，展开后保留用于追溯
+	Type        string                 `json:"type"`        // 设备类型，如 BMS、PCS，仅用于标识
+	ScanClasses map[string]int         `json:"scanClasses"` // 各扫描等级的周期（毫秒），覆盖默认值
+	ScanGroups  map[string][]string    `json:"scanGroups"`  // 扫描等级 -> 点位名通配，成组指定扫描等级
 }
 
 func FindPointConfigById(points []PointConfig, id string) *PointConfig {
@@ -0,0 +1,486 @@
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 多格式配置：按扩展名识别 JSON / YAML(.yaml/.yml) / TOML(.toml)，其余按 JSON 解析，
+// 解码后统一为与 JSON 相同的结构，再走模板展开。
+//
+// 顶层可以是：设备数组；{"templates", "devices", "include"} 对象；或单个设备对象（含 name，一文件一设备）。
+// include 为字符串或列表，元素可以是文件、目录（目录下受支持扩展名的文件，按文件名排序，不递归，
+// 忽略 . 开头的文件）或通配符，相对路径相对于当前文件所在目录。被包含文件中的模板全局可见，
+// 设备按 include 顺序排在当前文件自身设备之前。
+//
+// 解析前对原文做环境变量替换：${VAR} 未设置时报错，${VAR:-默认值} 未设置或为空时取默认值，
+// $${ 输出字面量 ${。替换是文本级的，值中含特殊字符时应在配置中加引号。
+//
+// 旧版 pkg/config/config.yaml 的 name/type/protocol/addr/params 结构及常见下划线写法
+// 经 normalizeKeys 归一到 DeviceConfig 的字段名。
+
+const keyInclude = "include"
+
+// configExts 目录包含时识别的扩展名
+var configExts = map[string]bool{".json": true, ".yaml": true, ".yml": true, ".toml": true}
+
+// ParseConfig 按 path 的扩展名解析配置内容，展开 include 与模板；path 同时用于解析相对路径
+func ParseConfig(path string, data []byte) ([]*DeviceConfig, error) {
+	cfgs, _, err := parseConfigSources(path, data, nil)
+	return cfgs, err
+}
+
+// configSources 一次解析读取的文件
+type configSources struct {
+	paths   []string          // 读取过的全部文件与 include 的目录（绝对路径），用于文件监听
+	files   map[string][]byte // 读取过的文件原文（环境变量替换前），用于版本记录
+	devices []string          // 各设备所在的文件（绝对路径），与解析结果一一对应，用于校验定位
+}
+
+// parseConfigSources 额外返回读取过的文件，用于文件监听、版本记录与校验定位。
+// snapshot 非空时 include 只从其中读取（绝对路径 -> 原文），目录与通配符也按其中的文件展开，用于回滚到历史版本
+func parseConfigSources(path string, data []byte, snapshot map[string][]byte) ([]*DeviceConfig, *configSources, error) {
+	l := &includeLoader{src: &configSources{files: make(map[string][]byte)}, snapshot: snapshot}
+	var file configFile
+	if err := l.load(path, data, &file); err != nil {
+		return nil, nil, err
+	}
+	cfgs, err := resolveConfigFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfgs, l.src, nil
+}
+
+type includeLoader struct {
+	stack    []string // 当前包含链，检测循环
+	src      *configSources
+	snapshot map[string][]byte
+}
+
+func (l *includeLoader) load(path string, data []byte, out *configFile) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for _, p := range l.stack {
+		if p == abs {
+			return fmt.Errorf("include cycle: %s -> %s", strings.Join(l.stack, " -> "), abs)
+		}
+	}
+	l.stack = append(l.stack, abs)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+	l.src.paths = append(l.src.paths, abs)
+	l.src.files[abs] = data
+
+	var wrap func(error) error
+	if len(l.stack) > 1 {
+		wrap = func(err error) error { return fmt.Errorf("%s: %w", filepath.Base(path), err) }
+	} else {
+		wrap = func(err error) error { return err }
+	}
+	data, err = expandEnv(data)
+	if err != nil {
+		return wrap(err)
+	}
+	file, includes, err := decodeConfigFile(path, data)
+	if err != nil {
+		return wrap(err)
+	}
+	for _, inc := range includes {
+		paths, err := l.includePaths(filepath.Dir(path), inc)
+		if err != nil {
+			return wrap(err)
+		}
+		for _, p := range paths {
+			sub, err := l.readFile(p)
+			if err != nil {
+				return wrap(fmt.Errorf("include %s: %w", inc, err))
+			}
+			if err := l.load(p, sub, out); err != nil {
+				return wrap(err)
+			}
+		}
+	}
+	out.Templates = append(out.Templates, file.Templates...)
+	out.Devices = append(out.Devices, file.Devices...)
+	for range file.Devices {
+		l.src.devices = append(l.src.devices, abs)
+	}
+	return nil
+}
+
+func (l *includeLoader) readFile(p string) ([]byte, error) {
+	if l.snapshot == nil {
+		return os.ReadFile(p)
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := l.snapshot[abs]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s: not in config version", p)
+}
+
+// includePaths 展开一个 include 项；目录与通配符按名称排序
+func (l *includeLoader) includePaths(dir, inc string) ([]string, error) {
+	p := inc
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	if l.snapshot != nil {
+		return l.snapshotPaths(p, inc)
+	}
+	if strings.ContainsAny(inc, "*?[") {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", inc, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("include %s: no files match", inc)
+		}
+		sort.Strings(matches)
+		return matches, nil
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("include %s: %w", inc, err)
+	}
+	if !fi.IsDir() {
+		return []string{p}, nil
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		l.src.paths = append(l.src.paths, abs)
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, fmt.Errorf("include %s: %w", inc, err)
+	}
+	var out []string
+	for _, e := range entries {
+		if isConfigFile(e.Name()) && !e.IsDir() {
+			out = append(out, filepath.Join(p, e.Name()))
+		}
+	}
+	return out, nil
+}
+
+// snapshotPaths 按快照中的文件展开 include 项，与磁盘上的展开规则一致
+func (l *includeLoader) snapshotPaths(p, inc string) ([]string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return nil, err
+	}
+	isGlob := strings.ContainsAny(inc, "*?[")
+	if !isGlob {
+		if _, ok := l.snapshot[abs]; ok {
+			return []string{abs}, nil
+		}
+		l.src.paths = append(l.src.paths, abs)
+	}
+	var out []string
+	for f := range l.snapshot {
+		if isGlob {
+			if ok, _ := filepath.Match(abs, f); ok {
+				out = append(out, f)
+			}
+		} else if filepath.Dir(f) == abs && isConfigFile(filepath.Base(f)) {
+			out = append(out, f)
+		}
+	}
+	if len(out) == 0 && isGlob {
+		return nil, fmt.Errorf("include %s: no files match", inc)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// isConfigFile 目录包含时是否读取该文件：受支持的扩展名且非隐藏文件（编辑器临时文件等）
+func isConfigFile(name string) bool {
+	return !strings.HasPrefix(name, ".") && configExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// decodeConfigFile 按格式解码单个文件，返回其中的模板、设备与 include 列表
+func decodeConfigFile(path string, data []byte) (configFile, []string, error) {
+	var file configFile
+	if len(bytes.TrimSpace(data)) == 0 {
+		return file, nil, nil
+	}
+	// 非 JSON 格式先解码为通用结构再转成 JSON，数值、嵌套对象与直接写 JSON 时一致
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return file, nil, err
+		}
+		js, err := json.Marshal(yamlToJSON(v))
+		if err != nil {
+			return file, nil, err
+		}
+		data = js
+	case ".toml":
+		var v map[string]interface{}
+		if _, err := toml.Decode(string(data), &v); err != nil {
+			return file, nil, err
+		}
+		js, err := json.Marshal(v)
+		if err != nil {
+			return file, nil, err
+		}
+		data = js
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var top interface{}
+	if err := dec.Decode(&top); err != nil {
+		return file, nil, jsonPosError(data, err)
+	}
+	switch x := top.(type) {
+	case nil:
+		return file, nil, nil
+	case []interface{}:
+		for i, d := range x {
+			m, ok := d.(map[string]interface{})
+			if !ok {
+				return file, nil, fmt.Errorf("device #%d: expected an object", i+1)
+			}
+			file.Devices = append(file.Devices, m)
+		}
+		return file, nil, nil
+	case map[string]interface{}:
+		_, hasDevices := x["devices"]
+		_, hasTemplates := x["templates"]
+		_, hasInclude := x[keyInclude]
+		if !hasDevices && !hasTemplates && !hasInclude {
+			if _, ok := x["name"]; ok {
+				file.Devices = []map[string]interface{}{x}
+				return file, nil, nil
+			}
+		}
+		includes, err := includeList(x[keyInclude])
+		if err != nil {
+			return file, nil, err
+		}
+		delete(x, keyInclude)
+		js, _ := json.Marshal(x)
+		if err := json.Unmarshal(js, &file); err != nil {
+			return file, nil, err
+		}
+		return file, includes, nil
+	}
+	return file, nil, fmt.Errorf("unexpected top-level %T, want a device list or object", top)
+}
+
+func includeList(v interface{}) ([]string, error) {
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{x}, nil
+	case []interface{}:
+		out := make([]string, 0, len(x))
+		for _, e := range x {
+			s, ok := e.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("include: expected file or directory names, got %v", e)
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("include: expected a string or list, got %T", v)
+}
+
+// yamlToJSON 非字符串键（如数字键）转为字符串，便于编码为 JSON
+func yamlToJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = yamlToJSON(e)
+		}
+		return x
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			m[fmt.Sprint(k)] = yamlToJSON(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range x {
+			x[i] = yamlToJSON(e)
+		}
+		return x
+	}
+	return v
+}
+
+// expandEnv 替换 ${VAR} 与 ${VAR:-默认值}，未设置且无默认值的变量按行号报错
+func expandEnv(data []byte) ([]byte, error) {
+	if !bytes.Contains(data, []byte("${")) {
+		return data, nil
+	}
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		if bytes.HasPrefix(data[i:], []byte("$${")) {
+			out.WriteString("${")
+			i += 3
+			continue
+		}
+		if !bytes.HasPrefix(data[i:], []byte("${")) {
+			out.WriteByte(data[i])
+			i++
+			continue
+		}
+		line := bytes.Count(data[:i], []byte("\n")) + 1
+		end := bytes.IndexByte(data[i:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated ${", line)
+		}
+		expr := string(data[i+2 : i+end])
+		name, def, hasDef := strings.Cut(expr, ":-")
+		if !validEnvName(name) {
+			return nil, fmt.Errorf("line %d: invalid variable reference ${%s}", line, expr)
+		}
+		val, set := os.LookupEnv(name)
+		switch {
+		case hasDef && val == "":
+			val = def
+		case !set:
+			return nil, fmt.Errorf("line %d: environment variable %s is not set", line, name)
+		}
+		out.WriteString(val)
+		i += end + 1
+	}
+	return out.Bytes(), nil
+}
+
+func validEnvName(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for _, c := range s {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// 归一化：别名键 -> DeviceConfig / PointConfig 的 JSON 字段名
+var (
+	deviceKeyAliases = map[string]string{
+		"adapter":      "AdapterName",
+		"adapterName":  "AdapterName",
+		"adapter_name": "AdapterName",
+		"bus_id":       "busId",
+		"slave_id":     "slaveId",
+		"ip_addr":      "ipAddr",
+		"intervalMs":   "interval_ms",
+		"dbc_file":     "dbcFile",
+		"dbc_messages": "dbcMessages",
+		"scan_classes": "scanClasses",
+		"scan_groups":  "scanGroups",
+	}
+	pointKeyAliases = map[string]string{
+		"func_code":  "funcCode",
+		"reg_addr":   "regAddr",
+		"reg_num":    "regNum",
+		"data_type":  "dataType",
+		"swap_reg":   "swapReg",
+		"byte_order": "byteOrder",
+		"scan_class": "scanClass",
+	}
+	paramKeyAliases = map[string]string{
+		"slave_id": "slaveId",
+	}
+)
+
+// normalizeKeys 将别名与旧版结构改写为规范字段名，模板与设备都经过这一步：
+//   - protocol 在未指定 AdapterName 时作为适配器名（旧版 config.yaml）
+//   - addr 在 params 未指定 address 时作为 params.address
+//   - modbus 设备未指定 params.mode 时按地址推断：host:port 为 tcp，/dev/* 或 COMn 为 rtu
+//   - 别名与规范名同时出现时报错
+func normalizeKeys(m map[string]interface{}) error {
+	if err := renameKeys(m, deviceKeyAliases); err != nil {
+		return err
+	}
+	if p, ok := m["protocol"].(string); ok && p != "" {
+		if _, ok := m["AdapterName"]; !ok {
+			m["AdapterName"] = p
+		}
+	}
+	if addr, ok := m["addr"]; ok {
+		delete(m, "addr")
+		params, _ := m["params"].(map[string]interface{})
+		if params == nil {
+			params = make(map[string]interface{})
+			m["params"] = params
+		}
+		if _, set := params["address"]; set {
+			return fmt.Errorf("both addr and params.address set")
+		}
+		params["address"] = addr
+	}
+	if params, ok := m["params"].(map[string]interface{}); ok {
+		if err := renameKeys(params, paramKeyAliases); err != nil {
+			return fmt.Errorf("params: %w", err)
+		}
+		if m["AdapterName"] == "modbus" {
+			inferModbusMode(params)
+		}
+	}
+	pts, _ := m["points"].([]interface{})
+	for i, p := range pts {
+		pm, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := renameKeys(pm, pointKeyAliases); err != nil {
+			return fmt.Errorf("point #%d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// inferModbusMode 按 params.address 推断 modbus 模式，已指定或无法判断时不改动
+func inferModbusMode(params map[string]interface{}) {
+	if _, set := params["mode"]; set {
+		return
+	}
+	addr, _ := params["address"].(string)
+	switch {
+	case strings.HasPrefix(addr, "/dev/") || strings.HasPrefix(strings.ToUpper(addr), "COM"):
+		params["mode"] = "rtu"
+	case addr != "":
+		if _, _, err := net.SplitHostPort(addr); err == nil {
+			params["mode"] = "tcp"
+		}
+	}
+}
+
+func renameKeys(m map[string]interface{}, aliases map[string]string) error {
+	for alias, key := range aliases {
+		v, ok := m[alias]
+		if !ok {
+			continue
+		}
+		if _, dup := m[key]; dup {
+			return fmt.Errorf("both %s and %s set", alias, key)
+		}
+		delete(m, alias)
+		m[key] = v
+	}
+	return nil
+}
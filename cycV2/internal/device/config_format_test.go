@@ -0,0 +1,209 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfigFormats(t *testing.T) {
+	jsonCfg := `{"templates": [{"id": "T", "AdapterName": "modbus", "interval_ms": 500,
+	    "params": {"mode": "tcp", "timeout": 1000}, "points": [{"name": "soc", "funcCode": "hr", "regAddr": 10}]}],
+	  "devices": [{"name": "bms1", "template": "T", "busId": "A", "params": {"address": "10.0.0.1:502"}}]}`
+	yamlCfg := `
+templates:
+  - id: T
+    adapter: modbus         # 别名
+    interval_ms: 500
+    params: {mode: tcp, timeout: 1000}
+    points:
+      - {name: soc, func_code: hr, reg_addr: 10}
+devices:
+  - name: bms1
+    template: T
+    bus_id: A
+    addr: "10.0.0.1:502"    # 旧版写法
+`
+	tomlCfg := `
+[[templates]]
+id = "T"
+AdapterName = "modbus"
+interval_ms = 500
+params = { mode = "tcp", timeout = 1000 }
+points = [ { name = "soc", funcCode = "hr", regAddr = 10 } ]
+
+[[devices]]
+name = "bms1"
+template = "T"
+busId = "A"
+params = { address = "10.0.0.1:502" }
+`
+	want, err := ParseConfig("devices.json", []byte(jsonCfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ path, cfg string }{{"devices.yaml", yamlCfg}, {"devices.toml", tomlCfg}} {
+		got, err := ParseConfig(tc.path, []byte(tc.cfg))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.path, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tc.path, *got[0], *want[0])
+		}
+	}
+
+	// 旧版 pkg/config/config.yaml 结构
+	legacy, err := ParseConfig("config.yaml", []byte(`
+devices:
+  - name: "PCS1"
+    type: "PCS"
+    protocol: "can"
+    addr: "/dev/can0"
+    params:
+      frame_id: 0x123
+      baudrate: 500000
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := legacy[0]; d.AdapterName != "can" || d.Type != "PCS" || d.Params["address"] != "/dev/can0" ||
+		d.Params["frame_id"] != float64(0x123) || d.Params["baudrate"] != float64(500000) {
+		t.Errorf("legacy = %+v", *d)
+	}
+
+	for _, tc := range []struct{ path, cfg, want string }{
+		{"a.yaml", "devices:\n  - name: d\n    adapter: x\n    AdapterName: y\n", "both adapter and AdapterName"},
+		{"a.yaml", "devices: [name: d\n", "yaml"},
+		{"a.toml", "[[devices]\n", "toml"},
+		{"a.json", `{"devices": [{"name": "d", "addr": "x", "params": {"address": "y"}}]}`, "both addr and params.address"},
+	} {
+		if _, err := ParseConfig(tc.path, []byte(tc.cfg)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s %q: err = %v, want %q", tc.path, tc.cfg, err, tc.want)
+		}
+	}
+}
+
+func TestLegacyConfigYAML(t *testing.T) {
+	// 仓库自带的旧版配置无需改写即可通过校验
+	cfgs, r := ValidateConfigFile("../../pkg/config/config.yaml")
+	if len(r.Issues) != 0 {
+		t.Fatalf("issues = %v", r.Issues)
+	}
+	if p := cfgs[0].Params; p["mode"] != "tcp" || p["slaveId"] != float64(1) || p["slave_id"] != nil {
+		t.Errorf("BMS1 params = %v", p)
+	}
+	if _, set := cfgs[1].Params["mode"]; set {
+		t.Errorf("can device got a modbus mode: %v", cfgs[1].Params)
+	}
+	rtu, err := ParseConfig("a.yaml", []byte("devices:\n  - {name: m, protocol: modbus, addr: /dev/ttyS1}\n"))
+	if err != nil || rtu[0].Params["mode"] != "rtu" {
+		t.Errorf("rtu = %v, %v", rtu, err)
+	}
+}
+
+func TestParseConfigIncludeAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, cfg string) {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+		writeConfig(t, filepath.Join(dir, name), cfg)
+	}
+	write("templates/bms.toml", `
+[[templates]]
+id = "BMS"
+AdapterName = "modbus"
+params = { mode = "tcp" }
+`)
+	// 一文件一设备；目录按文件名排序，隐藏文件与其他扩展名忽略
+	write("site/b.yaml", "name: bms2\ntemplate: BMS\nparams: {address: \"${BMS2_IP:-10.0.0.2}:502\"}\n")
+	write("site/a.json", `{"name": "bms1", "template": "BMS", "params": {"address": "${BMS1_IP}:502", "password": "$${literal}"}}`)
+	write("site/.a.json.swp", `garbage`)
+	write("site/readme.txt", `garbage`)
+	write("main.yaml", "include: [templates/*.toml, site]\ndevices:\n  - {name: local, template: BMS, params: {address: \"127.0.0.1:502\"}}\n")
+
+	t.Setenv("BMS1_IP", "192.168.1.10")
+	cfgs, sources, err := parseConfigSources(filepath.Join(dir, "main.yaml"), mustRead(t, filepath.Join(dir, "main.yaml")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range cfgs {
+		got = append(got, c.Name+"="+c.Params["address"].(string))
+	}
+	if strings.Join(got, " ") != "bms1=192.168.1.10:502 bms2=10.0.0.2:502 local=127.0.0.1:502" {
+		t.Errorf("devices = %v", got)
+	}
+	if cfgs[0].Params["password"] != "${literal}" || cfgs[0].AdapterName != "modbus" {
+		t.Errorf("bms1 = %+v", *cfgs[0])
+	}
+	if len(sources.paths) != 5 || sources.paths[3] != filepath.Join(dir, "site", "a.json") {
+		t.Errorf("sources = %v", sources.paths)
+	}
+	if want := []string{filepath.Join(dir, "site", "a.json"), filepath.Join(dir, "site", "b.yaml"), filepath.Join(dir, "main.yaml")}; !reflect.DeepEqual(sources.devices, want) {
+		t.Errorf("device sources = %v", sources.devices)
+	}
+
+	os.Unsetenv("BMS1_IP")
+	if _, err := LoadDeviceConfigs(filepath.Join(dir, "main.yaml")); err == nil ||
+		!strings.Contains(err.Error(), "a.json: line 1: environment variable BMS1_IP is not set") {
+		t.Errorf("unset env: %v", err)
+	}
+	t.Setenv("BMS1_IP", "1.2.3.4")
+
+	write("loop/x.yaml", "include: y.yaml\n")
+	write("loop/y.yaml", "include: [x.yaml]\n")
+	if _, err := LoadDeviceConfigs(filepath.Join(dir, "loop/x.yaml")); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("cycle: %v", err)
+	}
+	if _, err := ParseConfig(filepath.Join(dir, "main.json"), []byte(`{"include": "missing/*.json"}`)); err == nil ||
+		!strings.Contains(err.Error(), "no files match") {
+		t.Errorf("missing include: %v", err)
+	}
+}
+
+func TestWatchIncludedFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "devices"), 0o755)
+	path := filepath.Join(dir, "main.yaml")
+	writeConfig(t, path, "include: devices\n")
+	writeConfig(t, filepath.Join(dir, "devices", "i1.yaml"), "{name: i1, AdapterName: reloadtest, busId: A, params: {id: i1}}\n")
+	m := NewManager(path)
+	m.ReloadDebounce = 30 * time.Millisecond
+	defer m.Close()
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- m.Watch(stop) }()
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(3 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %s", what)
+			}
+		}
+	}
+	waitFor("initial load", func() bool { _, ok := m.Device("i1"); return ok })
+
+	// 修改被包含的文件、在包含目录中新增文件都会触发加载
+	writeConfig(t, filepath.Join(dir, "devices", "i1.yaml"), "{name: i1, AdapterName: reloadtest, busId: A, params: {id: i1}, interval_ms: 200}\n")
+	waitFor("included file reload", func() bool { d, _ := m.Device("i1"); return d.Cfg.IntervalMs == 200 })
+	writeConfig(t, filepath.Join(dir, "devices", "i2.toml"), "name = \"i2\"\nAdapterName = \"reloadtest\"\nbusId = \"B\"\nparams = { id = \"i2\" }\n")
+	waitFor("new file reload", func() bool { _, ok := m.Device("i2"); return ok })
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
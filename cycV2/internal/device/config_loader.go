@@ -2,20 +2,19 @@ package device
 
 import (
 	"cycV2/internal/protocol"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"cycV2/internal/protocol/can/dbc"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
 //TODO
-//配置合法性校验
-//高速file watch降抖（同一时间段热更新概率降低）
-//日志告警、回滚策略
+//日志告警
 
 type BusInstance struct {
 	Devices []*DeviceInstance
@@ -36,7 +35,17 @@ type Manager struct {
 	BusStop    map[string]chan struct{}   // 每个bus一个stop通道用于优雅重启
 	configPath string                     // 配置文件地址
 	//devices    map[string]*DeviceInstance
-	mu sync.Mutex
+	ReloadDebounce time.Duration // 配置文件变更防抖，缺省300ms
+	mu             sync.Mutex
+	reloadHooks    []func(buses map[string][]*ModbusDevice)
+	busWg          map[string]*sync.WaitGroup             // 各bus的采集/推送协程，重启前等待退出
+	Versions       *VersionStore                          // 已应用配置的版本存储，nil 时不记录
+	Rollback       RollbackPolicy                         // 热加载后自动回滚策略
+	busBase        map[string]map[*ModbusDevice][2]uint64 // 各bus启动时设备的采集点次基数
+	generation     uint64                                 // 每次应用配置加一，用于作废过期的健康检查
+	closeCh        chan struct{}
+	closeOnce      sync.Once
+	sources        map[string]bool // 上次成功加载读取的文件与 include 目录（绝对路径），用于监听
 }
 
 func NewManager(configPath string) *Manager {
@@ -44,6 +53,9 @@ func NewManager(configPath string) *Manager {
 		Buses:      make(map[string][]*ModbusDevice),
 		configPath: configPath,
 		BusStop:    make(map[string]chan struct{}), // ← 新增
+		busWg:      make(map[string]*sync.WaitGroup),
+		busBase:    make(map[string]map[*ModbusDevice][2]uint64),
+		closeCh:    make(chan struct{}),
 		//devices:    make(map[string]*DeviceInstance),
 		RawCh: make(chan RawCollectResult, 100), // buffer依据实际业务量调整
 	}
@@ -105,37 +117,60 @@ func NewManager(configPath string) *Manager {
 //  - m.Buses   map[string][]*ModbusDevice // 现在的bus分组
 
 func (m *Manager) ReloadFromFile() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// 1. 加载JSON获得 []*ModbusDevice，分好 bus_id 分组
-	// busDevicesMap := map[string][]*ModbusDevice // bus_id -> 同一总线设备
-
-	busDevicesMap, err := loadDevicesByBus(m.configPath)
+	report, err := m.Reload()
 	if err != nil {
 		return err
 	}
+	log.Printf("配置已加载: %s", report)
+	return nil
+}
 
-	// 2. 关闭和移除所有“旧的bus worker”
-	for busID, stopCh := range m.BusStop {
-		close(stopCh) // 通知worker退出
-		log.Printf("关闭旧总线worker %s", busID)
-		delete(m.BusStop, busID)
+// Reload 加载配置并增量应用，返回变更报告；校验失败时不做任何改动
+func (m *Manager) Reload() (*ReloadReport, error) {
+	data, err := os.ReadFile(m.configPath)
+	if err != nil {
+		return nil, err
 	}
+	return m.applyConfig(data, nil, SourceReload, "")
+}
 
-	// 3. 启动新的“bus worker”各自管理一个物理总线
-	m.Buses = busDevicesMap
-	for busID, devices := range busDevicesMap {
-		if len(devices) == 0 {
-			continue
-		}
-		log.Printf("启动采集流水线，总线bus[%s]有%d个设备", busID, len(devices))
-		stopCh := make(chan struct{})
-		m.BusStop[busID] = stopCh
-		StartCollectPipeline(devices, m.RawCh, stopCh)
+// applyConfig 应用配置内容并通知回调；snapshot 非空时 include 从中读取（回滚）
+func (m *Manager) applyConfig(data []byte, snapshot map[string][]byte, source, note string) (*ReloadReport, error) {
+	report, err := m.apply(data, snapshot, source, note)
+	if err != nil {
+		return nil, err
 	}
+	m.mu.Lock()
+	hooks := m.reloadHooks
+	buses := m.snapshot()
+	m.mu.Unlock()
+	for _, h := range hooks {
+		h(buses)
+	}
+	return report, nil
+}
 
-	return nil
+// OnReload 注册加载完成回调（如北向服务重建命名空间），在新的bus worker启动后调用
+func (m *Manager) OnReload(h func(buses map[string][]*ModbusDevice)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reloadHooks = append(m.reloadHooks, h)
+}
+
+// BusDevices 当前 bus 分组的副本
+func (m *Manager) BusDevices() map[string][]*ModbusDevice {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshot()
+}
+
+// snapshot 调用方持有 mu
+func (m *Manager) snapshot() map[string][]*ModbusDevice {
+	out := make(map[string][]*ModbusDevice, len(m.Buses))
+	for busID, devices := range m.Buses {
+		out[busID] = append([]*ModbusDevice(nil), devices...)
+	}
+	return out
 }
 
 //func (m *Manager) ReloadFromFile() error {
@@ -184,81 +219,195 @@ func (m *Manager) ReloadFromFile() error {
 //	return nil
 //}
 
+// Device 按名称查找当前运行的设备
+func (m *Manager) Device(name string) (*ModbusDevice, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, devices := range m.Buses {
+		for _, dev := range devices {
+			if dev.Cfg.Name == name {
+				return dev, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // 热加载控制
 func (m *Manager) WatchAndReload() {
+	if err := m.Watch(nil); err != nil {
+		log.Fatalf("监听配置文件失败: %v", err)
+	}
+}
+
+// Watch 加载配置并监听所在目录，stop 关闭时返回。
+// 除 Write 外也处理 Create（编辑器写临时文件后原子改名），连续事件在 ReloadDebounce 内只触发一次加载
+func (m *Manager) Watch(stop <-chan struct{}) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Fatalf("创建文件监听失败: %v", err)
+		return err
 	}
 	defer watcher.Close()
 
-	configDir := "."
-	if abs, _ := os.Stat(m.configPath); !abs.IsDir() {
-		configDir = getParentDir(m.configPath)
+	target, err := filepath.Abs(m.configPath)
+	if err != nil {
+		return err
 	}
-	if err := watcher.Add(configDir); err != nil {
-		log.Fatalf("添加监听失败: %v", err)
+	if err := watcher.Add(filepath.Dir(target)); err != nil {
+		return err
+	}
+	watching := map[string]bool{filepath.Dir(target): true}
+	// 每次加载后补充监听 include 引入的文件与目录
+	watchSources := func() {
+		for _, dir := range m.watchDirs() {
+			if watching[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				log.Printf("监听目录%s失败: %v", dir, err)
+				continue
+			}
+			watching[dir] = true
+		}
+	}
+	debounce := m.ReloadDebounce
+	if debounce <= 0 {
+		debounce = 300 * time.Millisecond
 	}
 
 	log.Printf("开始监听配置文件: %s", m.configPath)
-	_ = m.ReloadFromFile()
+	if err := m.ReloadFromFile(); err != nil {
+		log.Printf("加载配置失败: %v", err)
+	}
+	watchSources()
+	var timer *time.Timer
+	var fire <-chan time.Time
 	for {
 		select {
 		case event, ok := <-watcher.Events:
 			if !ok {
-				return
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 || !m.isSource(target, event.Name) {
+				continue
 			}
-			if event.Op&fsnotify.Write == fsnotify.Write && event.Name == m.configPath {
-				log.Printf("检测到配置文件变更，自动热加载")
-				if err := m.ReloadFromFile(); err != nil {
-					log.Printf("热加载失败: %v", err)
-				}
+			if timer != nil {
+				timer.Stop()
 			}
+			timer = time.NewTimer(debounce)
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			log.Printf("检测到配置文件变更，自动热加载")
+			if err := m.ReloadFromFile(); err != nil {
+				log.Printf("热加载失败: %v", err)
+			}
+			watchSources()
 		case err, ok := <-watcher.Errors:
 			if !ok {
-				return
+				return nil
 			}
 			log.Printf("监听错误: %v", err)
+		case <-stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
 		}
 	}
 }
 
-func getParentDir(filePath string) string {
-	d := filePath
-	for len(d) > 0 && d[len(d)-1] != '/' && d[len(d)-1] != '\\' {
-		d = d[:len(d)-1]
+// watchDirs 需要监听的目录：配置来源文件所在目录与 include 的目录
+func (m *Manager) watchDirs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var dirs []string
+	for p := range m.sources {
+		if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+			dirs = append(dirs, p)
+		} else {
+			dirs = append(dirs, filepath.Dir(p))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// isSource 文件事件是否涉及配置：主文件、已加载的 include 文件，或 include 目录下新增/删除的配置文件
+func (m *Manager) isSource(target, name string) bool {
+	name = filepath.Clean(name)
+	if name == target {
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sources[name] || (m.sources[filepath.Dir(name)] && isConfigFile(filepath.Base(name)))
+}
+
+// loadConfigs 解析并校验配置内容；存在 error 级问题时返回 *ValidationReport。
+// 同时返回读取过的文件与 include 目录
+func loadConfigs(configPath string, data []byte, snapshot map[string][]byte) ([]*DeviceConfig, *configSources, error) {
+	devCfgs, sources, report := validateConfigData(configPath, data, snapshot)
+	for _, w := range report.Warnings() {
+		log.Printf("配置告警: %s", w)
 	}
-	if len(d) == 0 {
-		return "."
+	if report.HasErrors() {
+		return nil, nil, report
 	}
-	return d
+	return devCfgs, sources, nil
 }
 
+// newDevice 创建适配器与设备实例（不连接），失败时以 *ValidationReport 定位到设备
+func newDevice(configPath string, i int, cfg *DeviceConfig) (*ModbusDevice, error) {
+	adapter, err := protocol.GetAdapter(cfg.AdapterName, cfg.Params)
+	if err != nil {
+		r := &ValidationReport{}
+		issueCtx{r: r, file: filepath.Base(configPath), bus: cfg.BusId, device: label(cfg.Name, i)}.errorf("params", "%v", err)
+		return nil, r
+	}
+	return NewModbusDevice(*cfg, adapter), nil
+}
+
+// loadDevicesByBus 加载配置并按 busId 分组创建全部设备
 func loadDevicesByBus(configPath string) (map[string][]*ModbusDevice, error) {
-	dat, err := ioutil.ReadFile(configPath)
+	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
-	var devCfgs []*DeviceConfig
-	err = json.Unmarshal(dat, &devCfgs)
+	devCfgs, _, err := loadConfigs(configPath, data, nil)
 	if err != nil {
 		return nil, err
 	}
 	// map[bus_id][]*ModbusDevice
 	busGroup := make(map[string][]*ModbusDevice)
-	for _, cfg := range devCfgs {
-		busID := cfg.BusId
-		adapter, err := protocol.GetAdapter(cfg.AdapterName, cfg.Params)
+	for i, cfg := range devCfgs {
+		md, err := newDevice(configPath, i, cfg)
 		if err != nil {
-			fmt.Println("protocol.GetAdapter Failed...name:", cfg.AdapterName, " err:", err)
-			continue
+			return nil, err
 		}
-		md := NewModbusDevice(*cfg, adapter)
-		busGroup[busID] = append(busGroup[busID], md)
+		busGroup[cfg.BusId] = append(busGroup[cfg.BusId], md)
 	}
 	return busGroup, nil
 }
 
+// expandDBCPoints 加载设备引用的DBC文件并追加生成的点位，相对路径相对于 baseDir（声明设备的文件所在目录）
+func expandDBCPoints(cfg *DeviceConfig, baseDir string) error {
+	path := cfg.DBCFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	db, err := dbc.ParseFile(path)
+	if err != nil {
+		return err
+	}
+	points, err := PointsFromDBC(db, cfg.DBCMessages)
+	if err != nil {
+		return err
+	}
+	cfg.Points = append(cfg.Points, points...)
+	return nil
+}
+
 //func main() {
 //	configPath := "./devices_config.json"
 //	manager := device.NewManager(configPath)
@@ -0,0 +1,88 @@
+package device
+
+import (
+	"cycV2/internal/protocol/can/dbc"
+	"fmt"
+	"log"
+)
+
+// PointsFromDBC 由DBC报文/信号定义生成CAN设备点位，点名为 "报文名.信号名"。
+// messages 为空时导出全部报文；多路复用子信号依赖选择信号，按最新帧缓存采集会错位，暂不导出
+func PointsFromDBC(db *dbc.Database, messages []string) ([]PointConfig, error) {
+	var selected []*dbc.Message
+	if len(messages) == 0 {
+		selected = db.Messages
+	} else {
+		for _, name := range messages {
+			m, ok := db.MessageByName(name)
+			if !ok {
+				return nil, fmt.Errorf("dbc message %s not found", name)
+			}
+			selected = append(selected, m)
+		}
+	}
+
+	var points []PointConfig
+	for _, m := range selected {
+		for _, s := range m.Signals {
+			if s.Mux == dbc.MuxMultiplexed {
+				log.Printf("[DBC] 跳过多路复用信号 %s.%s", m.Name, s.Name)
+				continue
+			}
+			params := s.Params()
+			params["frame_id"] = int(m.ID)
+			params["extended"] = m.Extended
+			points = append(points, PointConfig{
+				Name:     m.Name + "." + s.Name,
+				Desc:     s.Unit,
+				DataType: "dbc",
+				Rw:       "r",
+				Params:   params,
+			})
+		}
+	}
+	return points, nil
+}
+
+// parseDBCSignal 按点位参数中的信号定义解码帧数据，有值表描述时返回描述字符串
+func parseDBCSignal(data []byte, pt PointConfig) interface{} {
+	sig, err := dbc.SignalFromParams(pt.Params)
+	if err != nil {
+		return err.Error()
+	}
+	if label, ok := sig.Label(data); ok {
+		return label
+	}
+	v, err := sig.Decode(data)
+	if err != nil {
+		return err.Error()
+	}
+	if !sig.InRange(v) {
+		return fmt.Sprintf("value %v out of range [%v,%v]", v, sig.Min, sig.Max)
+	}
+	return v
+}
+
+// parseJ1939SPN 按J1939 SPN解析（Intel字节序），全1为"不可用"、全1减1为"错误"指示
+func parseJ1939SPN(data []byte, pt PointConfig) interface{} {
+	sig, err := dbc.SignalFromParams(pt.Params)
+	if err != nil {
+		return err.Error()
+	}
+	sig.Intel = true
+	raw, err := sig.RawValue(data)
+	if err != nil {
+		return err.Error()
+	}
+	switch {
+	case sig.Length == 2 && raw == 3, sig.Length >= 8 && raw>>uint(sig.Length-8) == 0xFF:
+		return nil // not available
+	case sig.Length == 2 && raw == 2, sig.Length >= 8 && raw>>uint(sig.Length-8) == 0xFE:
+		return "error indicator"
+	}
+	if label, ok := sig.Label(data); ok {
+		return label
+	}
+	v, _ := sig.Decode(data)
+	return v
+}
@@ -0,0 +1,113 @@
+package device
+
+import (
+	"cycV2/internal/protocol/can"
+	"cycV2/internal/protocol/can/dbc"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const pcsDBC = `BO_ 256 PCS_Status: 8 PCS
+ SG_ DcVoltage : 7|16@0+ (0.1,0) [0|1000] "V" EMS
+ SG_ RunState : 16|2@1+ (1,0) [0|3] "" EMS
+
+VAL_ 256 RunState 0 "Stop" 1 "Run" 2 "Fault" ;
+`
+
+func TestDBCPointsThroughPipeline(t *testing.T) {
+	db, err := dbc.Parse(strings.NewReader(pcsDBC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	points, err := PointsFromDBC(db, []string{"PCS_Status"})
+	if err != nil || len(points) != 2 {
+		t.Fatalf("points: %v %v", points, err)
+	}
+
+	bus := can.NewMemBus()
+	pcs := bus.Open()
+	defer pcs.Close()
+	adapter, err := can.NewCANAdapterWithDialer(map[string]interface{}{"interface": "can0"}, bus.Dialer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer adapter.Disconnect()
+	pcs.Send(can.Frame{ID: 256, Data: []byte{0x1F, 0x40, 0x01, 0, 0, 0, 0, 0}})
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, _, ok := adapter.Latest(256, false); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("frame not received")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	dev := NewModbusDevice(DeviceConfig{Name: "pcs1", AdapterName: "can", Points: points}, adapter)
+	raw, err := dev.Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawCh := make(chan RawCollectResult, 1)
+	rawCh <- RawCollectResult{DeviceName: dev.Cfg.Name, RawPoints: raw, Timestamp: time.Now()}
+	stopCh := make(chan struct{})
+	got := make(chan map[string]interface{}, 1)
+	wg := StartParseWorkerPool(rawCh, 1, func(_ string, parsed map[string]interface{}) { got <- parsed }, stopCh)
+	parsed := <-got
+	close(stopCh)
+	wg.Wait()
+
+	if v, ok := parsed["PCS_Status.DcVoltage"].(float64); !ok || math.Abs(v-800) > 1e-9 {
+		t.Errorf("DcVoltage expect 800, got %v", parsed["PCS_Status.DcVoltage"])
+	}
+	if parsed["PCS_Status.RunState"] != "Run" {
+		t.Errorf("RunState expect Run, got %v", parsed["PCS_Status.RunState"])
+	}
+}
+
+func TestJ1939SPNParse(t *testing.T) {
+	// EEC1 SPN190 发动机转速: 字节4-5, 0.125rpm/bit
+	pt := PointConfig{Name: "EngineSpeed", DataType: "spn", Params: map[string]interface{}{
+		"pgn": 61444, "startBit": 24, "bitLength": 16, "factor": 0.125,
+	}}
+	if v := parseRaw([]byte{0xFF, 0xFF, 0xFF, 0x40, 0x1F, 0xFF, 0xFF, 0xFF}, pt); v != float64(1000) {
+		t.Errorf("expect 1000rpm, got %v", v)
+	}
+	if v := parseRaw([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, pt); v != nil {
+		t.Errorf("expect not available, got %v", v)
+	}
+	if v := parseRaw([]byte{0xFF, 0xFF, 0xFF, 0x00, 0xFE, 0xFF, 0xFF, 0xFF}, pt); v != "error indicator" {
+		t.Errorf("expect error indicator, got %v", v)
+	}
+}
+
+func TestParseRawIntegers(t *testing.T) {
+	// CANopen 对象为小端
+	pt := PointConfig{Name: "ActualPosition", DataType: "int32", ByteOrder: "little"}
+	if v := parseRaw([]byte{0xFE, 0xFF, 0xFF, 0xFF}, pt); v != int32(-2) {
+		t.Errorf("int32 expect -2, got %v", v)
+	}
+	pt = PointConfig{Name: "Counter", DataType: "uint32"}
+	if v := parseRaw([]byte{0x00, 0x01, 0x00, 0x00}, pt); v != uint32(65536) {
+		t.Errorf("uint32 expect 65536, got %v", v)
+	}
+	if v := parseRaw([]byte{0x80}, PointConfig{DataType: "int8"}); v != int8(-128) {
+		t.Errorf("int8 expect -128, got %v", v)
+	}
+	// OPC UA 64位与字符串节点
+	if v := parseRaw([]byte{0x40, 0x35, 0x80, 0, 0, 0, 0, 0}, PointConfig{DataType: "float64"}); v != 21.5 {
+		t.Errorf("float64 expect 21.5, got %v", v)
+	}
+	if v := parseRaw([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xF9}, PointConfig{DataType: "int64"}); v != int64(-7) {
+		t.Errorf("int64 expect -7, got %v", v)
+	}
+	if v := parseRaw([]byte("line-1"), PointConfig{DataType: "string"}); v != "line-1" {
+		t.Errorf("string expect line-1, got %v", v)
+	}
+}
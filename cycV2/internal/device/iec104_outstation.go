@@ -0,0 +1,272 @@
+package device
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"cycV2/internal/protocol/iec104"
+)
+
+// IEC104PointMap 设备点位到监视IOA的映射
+type IEC104PointMap struct {
+	Device   string  `json:"device"`
+	Point    string  `json:"point"`
+	IOA      uint32  `json:"ioa"`
+	Type     string  `json:"type"`     // M_SP_NA_1、M_ME_NC_1、M_ME_TF_1 等
+	Deadband float64 `json:"deadband"` // 突发上送死区（工程值绝对值）
+}
+
+// IEC104CommandMap 控制IOA到设备点位的映射，命令经设备控制队列写入
+type IEC104CommandMap struct {
+	Device string `json:"device"`
+	Point  string `json:"point"`
+	IOA    uint32 `json:"ioa"`
+	Type   string `json:"type"` // C_SC_NA_1、C_DC_NA_1、C_SE_NC_1 等
+	SBO    bool   `json:"sbo"`  // 要求选择后执行
+}
+
+// IEC104OutstationConfig 对上级调度的104子站配置
+type IEC104OutstationConfig struct {
+	Listen           string             `json:"listen"`
+	CommonAddress    uint16             `json:"commonAddress"`
+	MaxConns         int                `json:"maxConns"`
+	K                int                `json:"k"`
+	W                int                `json:"w"`
+	T1Ms             int                `json:"t1Ms"`
+	T2Ms             int                `json:"t2Ms"`
+	T3Ms             int                `json:"t3Ms"`
+	SelectTimeoutMs  int                `json:"selectTimeoutMs"`
+	ControlTimeoutMs int                `json:"controlTimeoutMs"`
+	Points           []IEC104PointMap   `json:"points"`
+	Commands         []IEC104CommandMap `json:"commands"`
+}
+
+type pointKey struct {
+	device, point string
+}
+
+// IEC104Outstation 把解析后的采集数据映射为104监视点，实现 data.DataDispatcher，
+// 可注册为分发器；调度下发的命令转为设备控制写入
+type IEC104Outstation struct {
+	server   *iec104.Server
+	lookup   func(name string) (*ModbusDevice, bool)
+	ioaOf    map[pointKey]uint32
+	commands map[uint32]IEC104CommandMap
+	timeout  time.Duration
+}
+
+// NewIEC104Outstation 创建子站，lookup 用于按设备名查找设备（如 Manager.Device）
+func NewIEC104Outstation(cfg IEC104OutstationConfig, lookup func(name string) (*ModbusDevice, bool)) (*IEC104Outstation, error) {
+	o := &IEC104Outstation{
+		lookup:   lookup,
+		ioaOf:    make(map[pointKey]uint32),
+		commands: make(map[uint32]IEC104CommandMap),
+		timeout:  10 * time.Second,
+	}
+	if cfg.ControlTimeoutMs > 0 {
+		o.timeout = time.Duration(cfg.ControlTimeoutMs) * time.Millisecond
+	}
+	timing := iec104.DefaultTiming()
+	if cfg.K > 0 {
+		timing.K = cfg.K
+	}
+	if cfg.W > 0 {
+		timing.W = cfg.W
+	}
+	if cfg.T1Ms > 0 {
+		timing.T1 = time.Duration(cfg.T1Ms) * time.Millisecond
+	}
+	if cfg.T2Ms > 0 {
+		timing.T2 = time.Duration(cfg.T2Ms) * time.Millisecond
+	}
+	if cfg.T3Ms > 0 {
+		timing.T3 = time.Duration(cfg.T3Ms) * time.Millisecond
+	}
+	scfg := iec104.ServerConfig{
+		Listen:        cfg.Listen,
+		CA:            cfg.CommonAddress,
+		Timing:        timing,
+		MaxConns:      cfg.MaxConns,
+		SelectTimeout: time.Duration(cfg.SelectTimeoutMs) * time.Millisecond,
+	}
+	for _, p := range cfg.Points {
+		t, err := iec104.ParseTypeID(p.Type)
+		if err != nil {
+			return nil, fmt.Errorf("point %s.%s: %w", p.Device, p.Point, err)
+		}
+		key := pointKey{p.Device, p.Point}
+		if _, ok := o.ioaOf[key]; ok {
+			return nil, fmt.Errorf("point %s.%s mapped twice", p.Device, p.Point)
+		}
+		o.ioaOf[key] = p.IOA
+		scfg.Points = append(scfg.Points, iec104.ServerPoint{IOA: p.IOA, Type: t, Deadband: p.Deadband})
+	}
+	for _, c := range cfg.Commands {
+		t, err := iec104.ParseTypeID(c.Type)
+		if err != nil {
+			return nil, fmt.Errorf("command %s.%s: %w", c.Device, c.Point, err)
+		}
+		o.commands[c.IOA] = c
+		scfg.Commands = append(scfg.Commands, iec104.ServerCommand{IOA: c.IOA, Type: t, SBO: c.SBO})
+	}
+	server, err := iec104.NewServer(scfg, o.execute)
+	if err != nil {
+		return nil, err
+	}
+	o.server = server
+	return o, nil
+}
+
+// Start 开始监听
+func (o *IEC104Outstation) Start() error { return o.server.Start() }
+
+// Close 停止子站
+func (o *IEC104Outstation) Close() error { return o.server.Close() }
+
+// Server 底层104子站
+func (o *IEC104Outstation) Server() *iec104.Server { return o.server }
+
+// Dispatch 实现 data.DataDispatcher：更新映射点快照，变化超过死区时突发上送。
+// 解析失败的点（字符串错误信息）以无效品质上送
+func (o *IEC104Outstation) Dispatch(deviceName string, points map[string]interface{}) error {
+	now := time.Now()
+	for name, v := range points {
+		ioa, ok := o.ioaOf[pointKey{deviceName, name}]
+		if !ok {
+			continue
+		}
+		if f, ok := toFloat64(v); ok {
+			o.server.Update(ioa, f, 0, now)
+		} else {
+			o.server.Update(ioa, 0, iec104.QualityIV, now)
+		}
+	}
+	return nil
+}
+
+// execute 子站命令回调：转换为点位原始字节并进入设备控制队列
+func (o *IEC104Outstation) execute(cmd iec104.Command) error {
+	m, ok := o.commands[cmd.IOA]
+	if !ok {
+		return fmt.Errorf("ioa %d not mapped", cmd.IOA)
+	}
+	dev, ok := o.lookup(m.Device)
+	if !ok {
+		return fmt.Errorf("device %s not found", m.Device)
+	}
+	pt := FindPointConfigById(dev.Cfg.Points, m.Point)
+	if pt == nil {
+		return fmt.Errorf("point %s.%s not found", m.Device, m.Point)
+	}
+	if !pt.writable() {
+		return fmt.Errorf("point %s.%s is read only", m.Device, m.Point)
+	}
+	value := cmd.Value
+	if cmd.Type == iec104.C_DC_NA_1 {
+		// 双命令 2=合 1=分，其他值无效
+		switch value {
+		case 2:
+			value = 1
+		case 1:
+			value = 0
+		default:
+			return fmt.Errorf("invalid double command state %v", cmd.Value)
+		}
+	}
+	data, err := encodeValue(value, *pt)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-dev.ControlAsync(pt.Name, data, mergeParams(dev.Cfg.Params, pt.Params)):
+		return err
+	case <-time.After(o.timeout):
+		return errors.New("control timeout")
+	}
+}
+
+// toFloat64 解析结果转数值
+func toFloat64(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// encodeValue parseRaw 的逆过程：工程值按系数还原后，按点位数据类型与字节序编码
+func encodeValue(v float64, pt PointConfig) ([]byte, error) {
+	if pt.scaled() {
+		v /= pt.Scale
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if pt.ByteOrder == "little" {
+		order = binary.LittleEndian
+	}
+	switch pt.DataType {
+	case "bool":
+		if v != 0 {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case "uint8", "int8":
+		return []byte{byte(int64(v))}, nil
+	case "uint16", "int16":
+		b := make([]byte, 2)
+		order.PutUint16(b, uint16(int64(math.Round(v))))
+		return b, nil
+	case "uint32", "int32", "float32":
+		b := make([]byte, 4)
+		if pt.DataType == "float32" {
+			order.PutUint32(b, math.Float32bits(float32(v)))
+		} else {
+			order.PutUint32(b, uint32(int64(math.Round(v))))
+		}
+		if pt.SwapReg {
+			b = []byte{b[2], b[3], b[0], b[1]}
+		}
+		return b, nil
+	case "uint64", "int64", "float64":
+		b := make([]byte, 8)
+		switch pt.DataType {
+		case "float64":
+			order.PutUint64(b, math.Float64bits(v))
+		case "int64":
+			order.PutUint64(b, uint64(int64(math.Round(v))))
+		default:
+			order.PutUint64(b, uint64(math.Round(v)))
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("cannot encode value for data type %q", pt.DataType)
+	}
+}
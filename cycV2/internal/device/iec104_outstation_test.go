@@ -0,0 +1,80 @@
+package device
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol/iec104"
+)
+
+// recordAdapter 记录控制写入
+type recordAdapter struct {
+	mockAdapter
+	writes chan []byte
+}
+
+func (r *recordAdapter) Write(_ string, data []byte, _ map[string]interface{}) error {
+	r.writes <- data
+	return nil
+}
+
+func TestIEC104OutstationDispatchAndControl(t *testing.T) {
+	adapter := &recordAdapter{writes: make(chan []byte, 1)}
+	pcs := NewModbusDevice(DeviceConfig{Name: "pcs1", Points: []PointConfig{
+		{Name: "P", DataType: "float32", Rw: "r"},
+		{Name: "PSet", DataType: "int16", Rw: "rw", Params: map[string]interface{}{"func": "hr", "address": 100}},
+		{Name: "Mode", DataType: "int16", Params: map[string]interface{}{"func": "hr", "address": 101}},
+	}}, adapter)
+	lookup := func(name string) (*ModbusDevice, bool) { return pcs, name == "pcs1" }
+
+	o, err := NewIEC104Outstation(IEC104OutstationConfig{
+		Listen:        "127.0.0.1:0",
+		CommonAddress: 1,
+		Points:        []IEC104PointMap{{Device: "pcs1", Point: "P", IOA: 16385, Type: "M_ME_NC_1", Deadband: 1}},
+		Commands: []IEC104CommandMap{{Device: "pcs1", Point: "PSet", IOA: 25089, Type: "C_SE_NC_1"},
+			{Device: "pcs1", Point: "Mode", IOA: 25090, Type: "C_SE_NC_1"}},
+	}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	o.Dispatch("pcs1", map[string]interface{}{"P": float32(-120.5), "Other": 1})
+
+	client, err := iec104.NewIEC104Client(map[string]interface{}{"address": o.Server().Addr().String(), "commonAddress": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	if err := client.GeneralInterrogation(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.Read(map[string]interface{}{"ioa": 16385})
+	if err != nil || math.Float32frombits(binary.BigEndian.Uint32(data)) != -120.5 {
+		t.Fatalf("P via GI: % X %v", data, err)
+	}
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, math.Float32bits(300))
+	if err := client.Write("25089", b, map[string]interface{}{"command": "se_nc"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case w := <-adapter.writes:
+		if !bytes.Equal(w, []byte{0x01, 0x2C}) {
+			t.Fatalf("PSet write % X", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("control not written to device")
+	}
+	// 未配置 rw 的点位只读
+	if err := o.execute(iec104.Command{IOA: 25090, Type: iec104.C_SE_NC_1, Value: 1}); err == nil || !strings.Contains(err.Error(), "read only") {
+		t.Errorf("command on point without rw = %v", err)
+	}
+}
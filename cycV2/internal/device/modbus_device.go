@@ -2,10 +2,13 @@ package device
 
 import (
 	"cycV2/internal/protocol"
+	"cycV2/internal/protocol/dlt645"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 )
 
 type WriteTask struct {
@@ -20,8 +23,16 @@ type ModbusDevice struct {
 	Adapter    protocol.ProtocolAdapter
 	mu         sync.Mutex
 	writeQueue chan *WriteTask
+	qmu        sync.RWMutex  // 保护 writeQueue 关闭
+	closed     bool          // 已关闭，不再接受写入
+	writeDone  chan struct{} // writeWorker 退出
+	readOK     atomic.Uint64 // 采集成功的点次
+	readFailed atomic.Uint64 // 采集失败的点次
 }
 
+// ErrDeviceClosed 设备已被热加载替换或移除
+var ErrDeviceClosed = errors.New("device closed")
+
 // 构造器
 func NewModbusDevice(cfg DeviceConfig, adapter protocol.ProtocolAdapter) *ModbusDevice {
 	//return &ModbusDevice{Cfg: Cfg, Adapter: Adapter}
@@ -29,12 +40,14 @@ func NewModbusDevice(cfg DeviceConfig, adapter protocol.ProtocolAdapter) *Modbus
 		Cfg:        cfg,
 		Adapter:    adapter,
 		writeQueue: make(chan *WriteTask, 100),
+		writeDone:  make(chan struct{}),
 	}
 	go dev.writeWorker()
 	return dev
 }
 
 func (d *ModbusDevice) writeWorker() {
+	defer close(d.writeDone)
 	for task := range d.writeQueue {
 		d.mu.Lock()
 		err := d.Adapter.Write(task.Id, task.Data, task.Params)
@@ -53,16 +66,53 @@ func (d *ModbusDevice) ControlAsync(id string, data []byte, params map[string]in
 		Params: params,
 		RespCh: make(chan error, 1),
 	}
+	d.qmu.RLock()
+	defer d.qmu.RUnlock()
+	if d.closed {
+		task.RespCh <- ErrDeviceClosed
+		return task.RespCh
+	}
 	d.writeQueue <- task
 	return task.RespCh
 }
 
+// Close 停止接受写入，执行完已排队的写任务，等待进行中的采集结束后断开适配器。可重复调用
+func (d *ModbusDevice) Close() error {
+	d.qmu.Lock()
+	if d.closed {
+		d.qmu.Unlock()
+		return nil
+	}
+	d.closed = true
+	if d.writeQueue != nil { // 未经 NewModbusDevice 构造时没有写协程
+		close(d.writeQueue)
+	}
+	d.qmu.Unlock()
+	if d.writeDone != nil {
+		<-d.writeDone
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Adapter.Disconnect()
+}
+
 // 采集所有点数据
 func (d *ModbusDevice) Collect() (map[string]interface{}, error) {
+	return d.CollectPoints(d.Cfg.Points)
+}
+
+// CollectPoints 采集指定点位（如本轮到期的扫描等级），多点位读取的适配器一次打包请求
+func (d *ModbusDevice) CollectPoints(points []PointConfig) (map[string]interface{}, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	result := make(map[string]interface{})
-	for _, pt := range d.Cfg.Points {
+	defer d.countReads(result)
+	if mr, ok := d.Adapter.(protocol.MultiReader); ok {
+		d.collectMulti(mr, points, result)
+		return result, nil
+	}
+	for _, pt := range points {
 		param := mergeParams(d.Cfg.Params, pt.Params)
 		raw, err := d.Adapter.Read(param)
 		if err != nil {
@@ -74,6 +124,39 @@ func (d *ModbusDevice) Collect() (map[string]interface{}, error) {
 	return result, nil
 }
 
+// countReads 累计本轮采集成功/失败点次
+func (d *ModbusDevice) countReads(result map[string]interface{}) {
+	var ok uint64
+	for _, v := range result {
+		if _, isRaw := v.(RawPoint); isRaw {
+			ok++
+		}
+	}
+	d.readOK.Add(ok)
+	d.readFailed.Add(uint64(len(result)) - ok)
+}
+
+// ReadStats 累计采集成功/失败点次
+func (d *ModbusDevice) ReadStats() (ok, failed uint64) {
+	return d.readOK.Load(), d.readFailed.Load()
+}
+
+// collectMulti 适配器支持多点位读取时，点位一次交给适配器打包请求
+func (d *ModbusDevice) collectMulti(mr protocol.MultiReader, points []PointConfig, result map[string]interface{}) {
+	params := make([]map[string]interface{}, len(points))
+	for i, pt := range points {
+		params[i] = mergeParams(d.Cfg.Params, pt.Params)
+	}
+	vals, errs := mr.ReadMulti(params)
+	for i, pt := range points {
+		if errs[i] != nil {
+			result[pt.Name] = fmt.Sprintf("read error: %v", errs[i])
+			continue
+		}
+		result[pt.Name] = RawPoint{PointCfg: pt, Bytes: vals[i]}
+	}
+}
+
 func (d *ModbusDevice) CollectAllParallel() (map[string]interface{}, error) {
 	results := make(map[string]interface{})
 	var wg sync.WaitGroup
@@ -126,8 +209,20 @@ func mergeParams(global, point map[string]interface{}) map[string]interface{} {
 	return out
 }
 
-// 简单的解析函数，可按点表配置扩展
+// parseRaw 解析原始字节，配置了系数的数值点位换算为 float64 工程值
 func parseRaw(data []byte, pt PointConfig) interface{} {
+	v := decodeRaw(data, pt)
+	if !pt.scaled() {
+		return v
+	}
+	if f, ok := toFloat64(v); ok {
+		return f * pt.Scale
+	}
+	return v
+}
+
+// 简单的解析函数，可按点表配置扩展
+func decodeRaw(data []byte, pt PointConfig) interface{} {
 	switch pt.DataType {
 	case "float32":
 		d := data
@@ -159,6 +254,64 @@ func parseRaw(data []byte, pt PointConfig) interface{} {
 			return binary.LittleEndian.Uint16(d)
 		}
 		return binary.BigEndian.Uint16(d)
+	case "uint8":
+		if len(data) != 1 {
+			return fmt.Sprintf("invalid len %d for uint8", len(data))
+		}
+		return data[0]
+	case "int8":
+		if len(data) != 1 {
+			return fmt.Sprintf("invalid len %d for int8", len(data))
+		}
+		return int8(data[0])
+	case "int32", "uint32":
+		d := data
+		if pt.SwapReg && len(d) == 4 {
+			d = []byte{d[2], d[3], d[0], d[1]}
+		}
+		if len(d) != 4 {
+			return fmt.Sprintf("invalid len %d for %s", len(d), pt.DataType)
+		}
+		v := binary.BigEndian.Uint32(d)
+		if pt.ByteOrder == "little" {
+			v = binary.LittleEndian.Uint32(d)
+		}
+		if pt.DataType == "int32" {
+			return int32(v)
+		}
+		return v
+	case "float64", "int64", "uint64":
+		if len(data) != 8 {
+			return fmt.Sprintf("invalid len %d for %s", len(data), pt.DataType)
+		}
+		v := binary.BigEndian.Uint64(data)
+		if pt.ByteOrder == "little" {
+			v = binary.LittleEndian.Uint64(data)
+		}
+		switch pt.DataType {
+		case "float64":
+			return math.Float64frombits(v)
+		case "int64":
+			return int64(v)
+		}
+		return v
+	case "string":
+		return string(data)
+	case "bool":
+		if len(data) == 0 {
+			return "invalid len 0 for bool"
+		}
+		return data[0]&0x01 != 0
+	case "dbc":
+		return parseDBCSignal(data, pt)
+	case "spn":
+		return parseJ1939SPN(data, pt)
+	case "dlt645":
+		v, err := dlt645.DecodeParams(data, pt.Params)
+		if err != nil {
+			return err.Error()
+		}
+		return v
 	default:
 		return data // 默认返回原始数据
 	}
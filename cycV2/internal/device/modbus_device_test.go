@@ -2,6 +2,7 @@
 package device
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -85,3 +86,58 @@ func TestDeviceManager_CollectAll(t *testing.T) {
 		t.Fatalf("CollectAll error, got %v", all)
 	}
 }
+
+// multiAdapter 支持多点位读取，记录调用次数
+type multiAdapter struct {
+	mockAdapter
+	calls int
+}
+
+func (m *multiAdapter) ReadMulti(params []map[string]interface{}) ([][]byte, []error) {
+	m.calls++
+	vals := make([][]byte, len(params))
+	errs := make([]error, len(params))
+	for i, p := range params {
+		if p["address"] == "bad" {
+			errs[i] = fmt.Errorf("object does not exist")
+			continue
+		}
+		vals[i] = []byte{0, byte(i + 1)}
+	}
+	return vals, errs
+}
+
+func TestModbusDevice_CollectMulti(t *testing.T) {
+	adapter := &multiAdapter{}
+	dev := NewModbusDevice(DeviceConfig{Name: "plc1", Points: []PointConfig{
+		{Name: "a", DataType: "uint16", Params: map[string]interface{}{"address": "DB1.DBW0"}},
+		{Name: "b", DataType: "uint16", Params: map[string]interface{}{"address": "bad"}},
+	}}, adapter)
+	raw, err := dev.Collect()
+	if err != nil || adapter.calls != 1 {
+		t.Fatalf("collect = %v, calls %d", err, adapter.calls)
+	}
+	if rp, ok := raw["a"].(RawPoint); !ok || len(rp.Bytes) != 2 || rp.Bytes[1] != 1 {
+		t.Errorf("a = %v", raw["a"])
+	}
+	if s, ok := raw["b"].(string); !ok || s != "read error: object does not exist" {
+		t.Errorf("b = %v", raw["b"])
+	}
+}
+
+func TestParseRawScale(t *testing.T) {
+	pt := PointConfig{DataType: "int16", Scale: 0.1}
+	if v := parseRaw([]byte{0xFF, 0x9C}, pt); v != float64(-100)*0.1 {
+		t.Errorf("scaled int16 = %v", v)
+	}
+	if v := parseRaw([]byte{0x00, 0x64}, PointConfig{DataType: "uint16", Scale: 1}); v != uint16(100) {
+		t.Errorf("scale 1 = %#v", v)
+	}
+	if v := parseRaw([]byte("x"), PointConfig{DataType: "string", Scale: 10}); v != "x" {
+		t.Errorf("scaled string = %#v", v)
+	}
+	// 写入按系数还原
+	if b, err := encodeValue(-10, pt); err != nil || b[0] != 0xFF || b[1] != 0x9C {
+		t.Errorf("encode = % X, %v", b, err)
+	}
+}
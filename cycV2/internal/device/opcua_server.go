@@ -0,0 +1,392 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol/opcua"
+)
+
+// OPCUAServerConfig 对上位机/SCADA 的 OPC UA 服务端配置
+type OPCUAServerConfig struct {
+	Listen           string            `json:"listen"`           // 默认 ":4840"
+	EndpointURL      string            `json:"endpointUrl"`      // 对外公布的端点
+	ApplicationURI   string            `json:"applicationUri"`   //
+	NamespaceURI     string            `json:"namespaceUri"`     // 设备节点所在命名空间（索引2）
+	CertFile         string            `json:"certFile"`         // 为空时生成自签名证书
+	KeyFile          string            `json:"keyFile"`          //
+	SecurityPolicies []string          `json:"securityPolicies"` // None / Basic256Sha256
+	Users            map[string]string `json:"users"`            // 用户名 → 密码
+	AllowAnonymous   bool              `json:"allowAnonymous"`   // 配置了用户时仍允许匿名
+	ControlTimeoutMs int               `json:"controlTimeoutMs"` // 写入等待控制结果的超时
+}
+
+// opcuaVar 已发布的点位变量
+type opcuaVar struct {
+	id  opcua.NodeID
+	typ opcua.TypeID
+	pt  PointConfig
+}
+
+// OPCUAServer 把 Manager 中的总线/设备/点位发布为 OPC UA 地址空间：
+// Objects → 总线文件夹 → 设备文件夹 → 点位变量（ns=2;s=总线.设备.点位）。
+// 实现 data.DataDispatcher 更新变量值与品质；可写点位的写入经设备控制队列下发
+type OPCUAServer struct {
+	server  *opcua.Server
+	lookup  func(name string) (*ModbusDevice, bool)
+	timeout time.Duration
+
+	mu      sync.Mutex
+	buses   map[string]opcua.NodeID
+	devices map[string]opcua.NodeID // 设备名 → 设备文件夹
+	vars    map[pointKey]*opcuaVar
+}
+
+// NewOPCUAServer 创建服务端，lookup 用于写入时按设备名查找设备（如 Manager.Device）。
+// 命名空间由 Sync 构建，通常注册为 Manager.OnReload 回调
+func NewOPCUAServer(cfg OPCUAServerConfig, lookup func(name string) (*ModbusDevice, bool)) (*OPCUAServer, error) {
+	server, err := opcua.NewServer(opcua.ServerConfig{
+		Listen:           cfg.Listen,
+		EndpointURL:      cfg.EndpointURL,
+		ApplicationURI:   cfg.ApplicationURI,
+		NamespaceURI:     cfg.NamespaceURI,
+		CertFile:         cfg.CertFile,
+		KeyFile:          cfg.KeyFile,
+		SecurityPolicies: cfg.SecurityPolicies,
+		Users:            cfg.Users,
+		AllowAnonymous:   cfg.AllowAnonymous,
+	})
+	if err != nil {
+		return nil, err
+	}
+	o := &OPCUAServer{
+		server:  server,
+		lookup:  lookup,
+		timeout: 10 * time.Second,
+		buses:   make(map[string]opcua.NodeID),
+		devices: make(map[string]opcua.NodeID),
+		vars:    make(map[pointKey]*opcuaVar),
+	}
+	if cfg.ControlTimeoutMs > 0 {
+		o.timeout = time.Duration(cfg.ControlTimeoutMs) * time.Millisecond
+	}
+	return o, nil
+}
+
+// NewManagerOPCUAServer 创建绑定 Manager 的服务端：按当前设备建立命名空间，热加载后自动修补
+func NewManagerOPCUAServer(cfg OPCUAServerConfig, m *Manager) (*OPCUAServer, error) {
+	o, err := NewOPCUAServer(cfg, m.Device)
+	if err != nil {
+		return nil, err
+	}
+	o.Sync(m.BusDevices())
+	m.OnReload(o.Sync)
+	return o, nil
+}
+
+// Start 开始监听
+func (o *OPCUAServer) Start() error { return o.server.Start() }
+
+// Close 停止服务
+func (o *OPCUAServer) Close() error { return o.server.Close() }
+
+// Server 底层 OPC UA 服务端
+func (o *OPCUAServer) Server() *opcua.Server { return o.server }
+
+// NodeID 点位变量的节点ID
+func (o *OPCUAServer) NodeID(device, point string) (opcua.NodeID, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	v, ok := o.vars[pointKey{device, point}]
+	if !ok {
+		return opcua.NodeID{}, false
+	}
+	return v.id, true
+}
+
+// Sync 按当前总线分组修补命名空间：删除已下线的总线/设备/点位，
+// 新增缺失节点，点位配置（类型、单位、读写、描述）变化时重建该变量；未变化的节点保留当前值与订阅
+func (o *OPCUAServer) Sync(buses map[string][]*ModbusDevice) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ns := o.server.Namespace()
+
+	wantBus := make(map[string]bool)
+	wantDev := make(map[string]string) // 设备 → 总线
+	wantVar := make(map[pointKey]PointConfig)
+	for busID, devices := range buses {
+		wantBus[busID] = true
+		for _, dev := range devices {
+			wantDev[dev.Cfg.Name] = busID
+			for _, pt := range dev.Cfg.Points {
+				wantVar[pointKey{dev.Cfg.Name, pt.Name}] = pt
+			}
+		}
+	}
+
+	// 先删除：变化的点位、换总线的设备、下线的总线
+	for key, v := range o.vars {
+		busID, devOK := wantDev[key.device]
+		pt, ok := wantVar[key]
+		if !ok || !devOK || !samePublished(v.pt, pt) || o.devices[key.device] != busDeviceID(ns, busID, key.device) {
+			o.server.RemoveNode(v.id)
+			delete(o.vars, key)
+		}
+	}
+	for name, id := range o.devices {
+		if busID, ok := wantDev[name]; !ok || id != busDeviceID(ns, busID, name) {
+			o.server.RemoveNode(id)
+			delete(o.devices, name)
+			for key := range o.vars {
+				if key.device == name {
+					delete(o.vars, key)
+				}
+			}
+		}
+	}
+	for busID, id := range o.buses {
+		if !wantBus[busID] {
+			o.server.RemoveNode(id)
+			delete(o.buses, busID)
+		}
+	}
+
+	// 再新增
+	busIDs := make([]string, 0, len(buses))
+	for busID := range buses {
+		busIDs = append(busIDs, busID)
+	}
+	sort.Strings(busIDs)
+	for _, busID := range busIDs {
+		busNode, ok := o.buses[busID]
+		if !ok {
+			busNode = opcua.NewStringNodeID(ns, busName(busID))
+			if err := o.server.AddFolder(opcua.ObjectsFolder, busNode, busName(busID)); err != nil {
+				log.Printf("[OPCUA] 添加总线 %s 失败: %v", busID, err)
+				continue
+			}
+			o.buses[busID] = busNode
+		}
+		for _, dev := range buses[busID] {
+			name := dev.Cfg.Name
+			devNode, ok := o.devices[name]
+			if !ok {
+				devNode = busDeviceID(ns, busID, name)
+				if err := o.server.AddFolder(busNode, devNode, name); err != nil {
+					log.Printf("[OPCUA] 添加设备 %s 失败: %v", name, err)
+					continue
+				}
+				o.devices[name] = devNode
+			}
+			for _, pt := range dev.Cfg.Points {
+				key := pointKey{name, pt.Name}
+				if _, ok := o.vars[key]; ok {
+					continue
+				}
+				v := &opcuaVar{id: opcua.NewStringNodeID(ns, devNode.Str+"."+pt.Name), typ: publishedType(pt), pt: pt}
+				spec := opcua.Variable{
+					ID: v.id, Name: pt.Name, Description: pt.Desc, DataType: v.typ, Unit: pt.Unit,
+					Value: opcua.DataValue{Status: opcua.StatusBadWaitingForInitialData},
+				}
+				if pt.writable() {
+					spec.OnWrite = o.writer(name, pt.Name)
+				}
+				if err := o.server.AddVariable(devNode, spec); err != nil {
+					log.Printf("[OPCUA] 添加点位 %s.%s 失败: %v", name, pt.Name, err)
+					continue
+				}
+				o.vars[key] = v
+			}
+		}
+	}
+}
+
+// samePublished 影响节点定义的点位配置是否一致；scale 的增删会改变变量类型
+func samePublished(a, b PointConfig) bool {
+	return publishedType(a) == publishedType(b) && a.Unit == b.Unit && a.writable() == b.writable() && a.Desc == b.Desc
+}
+
+// publishedType 点位发布的变量类型：带系数的点位为工程值 Double
+func publishedType(pt PointConfig) opcua.TypeID {
+	if pt.scaled() {
+		return opcua.TypeDouble
+	}
+	return opcuaType(pt.DataType)
+}
+
+func busName(busID string) string {
+	if busID == "" {
+		return "default"
+	}
+	return busID
+}
+
+func busDeviceID(ns uint16, busID, device string) opcua.NodeID {
+	return opcua.NewStringNodeID(ns, busName(busID)+"."+device)
+}
+
+// opcuaType 点位数据类型对应的变量类型；DBC/SPN/DL-T645 为工程值，未知类型为原始字节
+func opcuaType(dataType string) opcua.TypeID {
+	switch dataType {
+	case "bool":
+		return opcua.TypeBoolean
+	case "int8":
+		return opcua.TypeSByte
+	case "uint8":
+		return opcua.TypeByte
+	case "int16":
+		return opcua.TypeInt16
+	case "uint16":
+		return opcua.TypeUInt16
+	case "int32":
+		return opcua.TypeInt32
+	case "uint32":
+		return opcua.TypeUInt32
+	case "int64":
+		return opcua.TypeInt64
+	case "uint64":
+		return opcua.TypeUInt64
+	case "float32":
+		return opcua.TypeFloat
+	case "float64", "dbc", "spn", "dlt645":
+		return opcua.TypeDouble
+	case "string":
+		return opcua.TypeString
+	default:
+		return opcua.TypeByteString
+	}
+}
+
+// Dispatch 实现 data.DataDispatcher：更新变量值，源时标取分发时刻。
+// 解析失败的点（字符串错误信息）保留上次值并置 BadCommunicationError，nil 置 BadNoData
+func (o *OPCUAServer) Dispatch(deviceName string, points map[string]interface{}) error {
+	now := time.Now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for name, raw := range points {
+		v, ok := o.vars[pointKey{deviceName, name}]
+		if !ok {
+			continue
+		}
+		dv := opcua.DataValue{SourceTimestamp: now}
+		if val, ok := toVariant(raw, v.typ); ok {
+			dv.Value = val
+		} else {
+			dv.Status = opcua.StatusBadCommunicationError
+			if raw == nil {
+				dv.Status = opcua.StatusBadNoData
+			}
+			if last, err := o.server.Value(v.id); err == nil {
+				dv.Value = last.Value
+			}
+		}
+		o.server.SetValue(v.id, dv)
+	}
+	return nil
+}
+
+// toVariant 解析结果转换为变量类型
+func toVariant(v interface{}, typ opcua.TypeID) (opcua.Variant, bool) {
+	switch typ {
+	case opcua.TypeString:
+		s, ok := v.(string)
+		return opcua.Variant{Type: typ, Value: s}, ok
+	case opcua.TypeByteString:
+		b, ok := v.([]byte)
+		return opcua.Variant{Type: typ, Value: b}, ok
+	}
+	f, ok := toFloat64(v)
+	if !ok {
+		return opcua.Variant{}, false
+	}
+	var val interface{}
+	switch typ {
+	case opcua.TypeBoolean:
+		val = f != 0
+	case opcua.TypeSByte:
+		val = int8(f)
+	case opcua.TypeByte:
+		val = uint8(f)
+	case opcua.TypeInt16:
+		val = int16(f)
+	case opcua.TypeUInt16:
+		val = uint16(f)
+	case opcua.TypeInt32:
+		val = int32(f)
+	case opcua.TypeUInt32:
+		val = uint32(f)
+	case opcua.TypeInt64:
+		// 大整数不经浮点转换以免丢精度
+		if i, ok := v.(int64); ok {
+			val = i
+		} else {
+			val = int64(f)
+		}
+	case opcua.TypeUInt64:
+		if u, ok := v.(uint64); ok {
+			val = u
+		} else {
+			val = uint64(f)
+		}
+	case opcua.TypeFloat:
+		val = float32(f)
+	default:
+		val = f
+	}
+	return opcua.Variant{Type: typ, Value: val}, true
+}
+
+// writer 变量写入回调：转换为点位原始字节并进入设备控制队列
+func (o *OPCUAServer) writer(device, point string) func(opcua.Variant) opcua.StatusCode {
+	return func(v opcua.Variant) opcua.StatusCode {
+		if err := o.control(device, point, v); err != nil {
+			log.Printf("[OPCUA] 写入 %s.%s 失败: %v", device, point, err)
+			var code opcua.StatusCode
+			if errors.As(err, &code) {
+				return code
+			}
+			return opcua.StatusBadCommunicationError
+		}
+		return opcua.StatusOK
+	}
+}
+
+func (o *OPCUAServer) control(device, point string, v opcua.Variant) error {
+	dev, ok := o.lookup(device)
+	if !ok {
+		return opcua.StatusBadNodeIDUnknown
+	}
+	pt := FindPointConfigById(dev.Cfg.Points, point)
+	if pt == nil {
+		return opcua.StatusBadNodeIDUnknown
+	}
+	if !pt.writable() {
+		return opcua.StatusBadNotWritable
+	}
+	var data []byte
+	switch x := v.Value.(type) {
+	case string:
+		data = []byte(x)
+	case []byte:
+		data = x
+	default:
+		f, ok := toFloat64(x)
+		if !ok {
+			return opcua.StatusBadTypeMismatch
+		}
+		var err error
+		if data, err = encodeValue(f, *pt); err != nil {
+			return fmt.Errorf("%w: %v", opcua.StatusBadTypeMismatch, err)
+		}
+	}
+	select {
+	case err := <-dev.ControlAsync(pt.Name, data, mergeParams(dev.Cfg.Params, pt.Params)):
+		return err
+	case <-time.After(o.timeout):
+		return opcua.StatusBadTimeout
+	}
+}
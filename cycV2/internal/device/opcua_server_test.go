@@ -0,0 +1,183 @@
+package device
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol/opcua"
+)
+
+func TestOPCUAServerNamespace(t *testing.T) {
+	adapter := &recordAdapter{writes: make(chan []byte, 1)}
+	pcs := NewModbusDevice(DeviceConfig{Name: "pcs1", BusId: "rs485-1", Points: []PointConfig{
+		{Name: "P", DataType: "float32", Rw: "r", Unit: "kW", Desc: "有功功率"},
+		{Name: "PSet", DataType: "int16", Rw: "rw", Params: map[string]interface{}{"func": "hr", "address": 100}},
+	}}, adapter)
+	meter := NewModbusDevice(DeviceConfig{Name: "meter1", Points: []PointConfig{{Name: "E", DataType: "uint32", Unit: "kWh"}}}, &mockAdapter{})
+	devices := map[string]*ModbusDevice{"pcs1": pcs, "meter1": meter}
+	lookup := func(name string) (*ModbusDevice, bool) { d, ok := devices[name]; return d, ok }
+
+	o, err := NewOPCUAServer(OPCUAServerConfig{Listen: "127.0.0.1:0"}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	o.Sync(map[string][]*ModbusDevice{"rs485-1": {pcs}, "": {meter}})
+
+	client, err := opcua.NewOPCUAClient(map[string]interface{}{"endpoint": o.Server().EndpointURL(), "timeoutMs": 2000, "publishIntervalMs": 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	names := func(node string) []string {
+		t.Helper()
+		id, _ := opcua.ParseNodeID(node)
+		refs, err := client.Browse(id)
+		if err != nil {
+			t.Fatalf("browse %s: %v", node, err)
+		}
+		var out []string
+		for _, r := range refs {
+			out = append(out, r.NodeID.String())
+		}
+		return out
+	}
+	if got := names("i=85"); len(got) != 3 || got[1] != "ns=2;s=default" || got[2] != "ns=2;s=rs485-1" {
+		t.Fatalf("objects = %v", got)
+	}
+	if got := names("ns=2;s=rs485-1.pcs1"); len(got) != 2 || got[0] != "ns=2;s=rs485-1.pcs1.P" {
+		t.Fatalf("pcs1 = %v", got)
+	}
+	if got := names("ns=2;s=rs485-1.pcs1.P"); len(got) != 1 || got[0] != "ns=2;s=rs485-1.pcs1.P.EngineeringUnits" {
+		t.Fatalf("P properties = %v", got)
+	}
+
+	// 采集前品质为等待初值，分发后为好品质，解析失败保留上次值
+	p, _ := o.NodeID("pcs1", "P")
+	if _, err := client.Read(map[string]interface{}{"nodeId": p.String()}); !errors.Is(err, opcua.StatusBadWaitingForInitialData) {
+		t.Errorf("initial read = %v", err)
+	}
+	o.Dispatch("pcs1", map[string]interface{}{"P": float32(-120.5), "Other": 1})
+	b, err := client.Read(map[string]interface{}{"nodeId": p.String()})
+	if err != nil || math.Float32frombits(binary.BigEndian.Uint32(b)) != -120.5 {
+		t.Fatalf("P = % X, %v", b, err)
+	}
+	o.Dispatch("pcs1", map[string]interface{}{"P": "read error: timeout"})
+	vals, _ := client.ReadValues(p)
+	if vals[0].Status != opcua.StatusBadCommunicationError || vals[0].Value.Value != float32(-120.5) {
+		t.Errorf("bad quality = %+v", vals[0])
+	}
+
+	// 写入经设备控制队列，只读点拒绝
+	pset, _ := o.NodeID("pcs1", "PSet")
+	if err := client.WriteValue(pset, int16(300)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case w := <-adapter.writes:
+		if !bytes.Equal(w, []byte{0x01, 0x2C}) {
+			t.Fatalf("PSet write % X", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("control not written to device")
+	}
+	if err := client.WriteValue(p, float32(1)); !errors.Is(err, opcua.StatusBadNotWritable) {
+		t.Errorf("write read-only = %v", err)
+	}
+	// 未配置 rw 的点位按只读发布，也不接受控制
+	e, _ := o.NodeID("meter1", "E")
+	if err := client.WriteValue(e, uint32(1)); !errors.Is(err, opcua.StatusBadNotWritable) {
+		t.Errorf("write point without rw = %v", err)
+	}
+	if err := o.control("meter1", "E", opcua.Variant{Value: uint32(1)}); !errors.Is(err, opcua.StatusBadNotWritable) {
+		t.Errorf("control point without rw = %v", err)
+	}
+
+	// 热加载：meter1 下线，P 单位变化重建，PSet 保留，新增 pcs2
+	pcs.Cfg.Points[0].Unit = "MW"
+	pcs2 := NewModbusDevice(DeviceConfig{Name: "pcs2", BusId: "rs485-1", Points: []PointConfig{{Name: "P", DataType: "float32"}}}, &mockAdapter{})
+	o.Dispatch("pcs1", map[string]interface{}{"PSet": int16(7)})
+	o.Sync(map[string][]*ModbusDevice{"rs485-1": {pcs, pcs2}})
+	if got := names("i=85"); len(got) != 2 || got[1] != "ns=2;s=rs485-1" {
+		t.Errorf("objects after reload = %v", got)
+	}
+	if got := names("ns=2;s=rs485-1"); len(got) != 2 || got[1] != "ns=2;s=rs485-1.pcs2" {
+		t.Errorf("bus after reload = %v", got)
+	}
+	if _, ok := o.NodeID("meter1", "E"); ok || o.Server().HasNode(opcua.NewStringNodeID(2, "default.meter1.E")) {
+		t.Error("meter1 still published")
+	}
+	vals, _ = client.ReadValues(pset, p, opcua.NewStringNodeID(2, "rs485-1.pcs1.P.EngineeringUnits"))
+	if vals[0].Value.Value != int16(7) || vals[1].Status != opcua.StatusBadWaitingForInitialData {
+		t.Errorf("values after reload = %+v", vals[:2])
+	}
+	if eu, ok := vals[2].Value.Value.(opcua.ExtensionObject).Value.(*opcua.EUInformation); !ok || eu.DisplayName.Text != "MW" {
+		t.Errorf("unit after reload = %+v", vals[2].Value)
+	}
+}
+
+func TestOPCUAServerScaleReload(t *testing.T) {
+	bms := NewModbusDevice(DeviceConfig{Name: "bms1", Points: []PointConfig{{Name: "V", DataType: "uint16"}}}, &mockAdapter{})
+	o, err := NewOPCUAServer(OPCUAServerConfig{Listen: "127.0.0.1:0"}, func(string) (*ModbusDevice, bool) { return bms, true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	o.Sync(map[string][]*ModbusDevice{"": {bms}})
+	client, err := opcua.NewOPCUAClient(map[string]interface{}{"endpoint": o.Server().EndpointURL(), "timeoutMs": 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	v, _ := o.NodeID("bms1", "V")
+
+	// 热加载增删 scale：变量在 Double 工程值与原始整数之间重建
+	for _, step := range []struct {
+		scale float64
+		value interface{}
+	}{{0.1, 23.4}, {0, uint16(5)}} {
+		bms.Cfg.Points[0].Scale = step.scale
+		o.Sync(map[string][]*ModbusDevice{"": {bms}})
+		o.Dispatch("bms1", map[string]interface{}{"V": step.value})
+		vals, err := client.ReadValues(v)
+		if err != nil || vals[0].Value.Value != step.value {
+			t.Errorf("scale %v: V = %+v, %v", step.scale, vals, err)
+		}
+	}
+}
+
+func TestManagerOPCUAServerReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	if err := os.WriteFile(path, []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(path)
+	o, err := NewManagerOPCUAServer(OPCUAServerConfig{Listen: "127.0.0.1:0"}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	// 模拟上次加载遗留的总线，重新加载后命名空间随之清理
+	o.Sync(map[string][]*ModbusDevice{"old": {NewModbusDevice(DeviceConfig{Name: "d1", Points: []PointConfig{{Name: "x", DataType: "uint16"}}}, &mockAdapter{})}})
+	if !o.Server().HasNode(opcua.NewStringNodeID(2, "old.d1.x")) {
+		t.Fatal("point not published")
+	}
+	if err := m.ReloadFromFile(); err != nil {
+		t.Fatal(err)
+	}
+	if o.Server().HasNode(opcua.NewStringNodeID(2, "old")) || o.Server().HasNode(opcua.NewStringNodeID(2, "old.d1.x")) {
+		t.Error("namespace not patched on reload")
+	}
+}
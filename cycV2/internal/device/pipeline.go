@@ -57,30 +57,28 @@ import (
 //}
 
 // StartCollectPipeline 启动总线采集流水线：同一总线下仅一组worker顺序采集所有设备，避免串口冲突。
-// 支持每设备配置独立采集间隔。
-func StartCollectPipeline(devices []*ModbusDevice, out chan<- RawCollectResult, stopCh <-chan struct{}) {
+// 支持每设备配置独立采集间隔，设备内点位按扫描等级（scan.go）分别计时，每轮只采集到期的点位。
+// 返回的 WaitGroup 在 stopCh 关闭、worker 退出后完成
+func StartCollectPipeline(devices []*ModbusDevice, out chan<- RawCollectResult, stopCh <-chan struct{}) *sync.WaitGroup {
+	var wg sync.WaitGroup
 	if len(devices) == 0 {
-		return
+		return &wg
 	}
+	wg.Add(1)
 	go func() {
-		// 每轮都遍历一遍devices，但按各自采集间隔（IntervalMs）决定是否采集
-		lastCollect := make(map[string]time.Time)
-		minInterval := 0
-		for _, dev := range devices {
+		defer wg.Done()
+		// 每轮都遍历一遍devices，按各扫描等级的周期决定采集哪些点位
+		schedules := make([]*scanSchedule, len(devices))
+		var interval time.Duration
+		for i, dev := range devices {
 			fmt.Println("name:", dev.Cfg.Name, " intervalMs:", dev.Cfg.IntervalMs)
-			lastCollect[dev.Cfg.Name] = time.Time{}
+			schedules[i] = newScanSchedule(&dev.Cfg)
 			//按照设备组中采集频率最快的能适应的采集频率进行采集
-			if dev.Cfg.IntervalMs != 0 {
-				if minInterval == 0 || minInterval > dev.Cfg.IntervalMs {
-					minInterval = dev.Cfg.IntervalMs
-				}
+			if p := schedules[i].minPeriod(); interval == 0 || p < interval {
+				interval = p
 			}
 		}
-		if minInterval <= 0 {
-			minInterval = 1000 // 默认1s
-		}
-		fmt.Println("###minInterval:", minInterval)
-		interval := time.Duration(minInterval) * time.Millisecond
+		fmt.Println("###minInterval:", interval.Milliseconds())
 		ticker := time.NewTicker(interval)
 
 		defer ticker.Stop()
@@ -88,28 +86,32 @@ func StartCollectPipeline(devices []*ModbusDevice, out chan<- RawCollectResult,
 			select {
 			case <-ticker.C:
 				now := time.Now()
-				for _, d := range devices {
-
-					temp := d.Cfg.IntervalMs
-					if temp <= 0 {
-						temp = 1000 //默认1s
+				for i, d := range devices {
+					// 距离上次采集是否到达间隔，防止同一组中有些设备/点位需要慢点采集的需求
+					classes, points := schedules[i].due(now)
+					if len(classes) == 0 {
+						continue
 					}
-					t := time.Duration(temp) * time.Millisecond
-
-					// 距离上次采集是否到达间隔
-					if now.Sub(lastCollect[d.Cfg.Name]) >= t { //防止同一组中有些设备需要慢点采集的需求
-						raw, err := d.Collect()
-						if err != nil {
-							log.Printf("[采集流水线] 设备%s采集错误: %v", d.Cfg.Name, err)
-							continue
-						}
-						out <- RawCollectResult{
-							DeviceName: d.Cfg.Name,
-							RawPoints:  raw,
-							Timestamp:  now,
-						}
-						lastCollect[d.Cfg.Name] = now
+					if len(points) == 0 {
+						schedules[i].done(now, classes, nil)
+						continue
+					}
+					raw, err := d.CollectPoints(points)
+					if err != nil {
+						log.Printf("[采集流水线] 设备%s采集错误: %v", d.Cfg.Name, err)
+						continue
 					}
+					select {
+					case out <- RawCollectResult{
+						DeviceName: d.Cfg.Name,
+						RawPoints:  raw,
+						Timestamp:  now,
+					}:
+					case <-stopCh:
+						log.Printf("总线 worker 轮询退出, 设备: %v", deviceNames(devices))
+						return
+					}
+					schedules[i].done(now, classes, raw)
 				}
 			case <-stopCh:
 				log.Printf("总线 worker 轮询退出, 设备: %v", deviceNames(devices))
@@ -117,6 +119,7 @@ func StartCollectPipeline(devices []*ModbusDevice, out chan<- RawCollectResult,
 			}
 		}
 	}()
+	return &wg
 }
 
 // 输出设备名列表辅助日志
@@ -2,11 +2,18 @@ package device
 
 import (
 	"cycV2/internal/protocol/modbus"
+	"net"
 	"testing"
 	"time"
 )
 
+// 需要本机 127.0.0.1:502 的 Modbus 从站（模拟器），运行1小时观察采集输出，属人工联调测试
 func TestStartParseWorkerPool(t *testing.T) {
+	if conn, err := net.DialTimeout("tcp", "127.0.0.1:502", 200*time.Millisecond); err != nil {
+		t.Skipf("modbus slave unavailable: %v", err)
+	} else {
+		conn.Close()
+	}
 
 	// 1. 配置两个设备（不同slaveId即可区分）
 	dev1Cfg := DeviceConfig{
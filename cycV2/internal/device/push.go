@@ -0,0 +1,132 @@
+package device
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// pushForwarder 单台设备的推送转发。适配器回调只写入待发表（同一点位保留最新值），
+// 由转发协程送入原始通道；通道拥塞时点位合并而不阻塞适配器接收协程，内存占用以点位数为上限
+type pushForwarder struct {
+	dev       string
+	mu        sync.Mutex
+	pending   map[string]interface{}
+	latest    time.Time
+	coalesced int // 拥塞期间被新值覆盖的次数
+	notify    chan struct{}
+}
+
+// StartPushPipeline 为实现了 protocol.Subscriber 的设备订阅全部点位，
+// 更新数据与轮询结果一样送入 out，由解析worker统一处理；stopCh 关闭时取消订阅。
+// 返回成功订阅的点位数
+func StartPushPipeline(devices []*ModbusDevice, out chan<- RawCollectResult, stopCh <-chan struct{}) int {
+	return startPushPipeline(devices, out, stopCh, nil)
+}
+
+// startPushPipeline wg 非空时转发协程计入其中，stopCh 关闭后等待即可确认订阅已全部取消
+func startPushPipeline(devices []*ModbusDevice, out chan<- RawCollectResult, stopCh <-chan struct{}, wg *sync.WaitGroup) int {
+	total := 0
+	for _, dev := range devices {
+		sub, ok := dev.Adapter.(protocol.Subscriber)
+		if !ok {
+			continue
+		}
+		f := &pushForwarder{dev: dev.Cfg.Name, pending: make(map[string]interface{}), notify: make(chan struct{}, 1)}
+		var cancels []func()
+		for _, pt := range dev.Cfg.Points {
+			pt := pt
+			cancel, err := sub.Subscribe(mergeParams(dev.Cfg.Params, pt.Params), func(u protocol.PointUpdate) {
+				f.put(pt, u)
+			})
+			if err != nil {
+				log.Printf("[推送] 设备%s点位%s订阅失败，仅轮询: %v", dev.Cfg.Name, pt.Name, err)
+				continue
+			}
+			cancels = append(cancels, cancel)
+		}
+		if len(cancels) == 0 {
+			continue
+		}
+		total += len(cancels)
+		log.Printf("[推送] 设备%s订阅%d个点位", dev.Cfg.Name, len(cancels))
+		if wg != nil {
+			wg.Add(1)
+		}
+		go func() {
+			if wg != nil {
+				defer wg.Done()
+			}
+			f.run(out, stopCh, cancels)
+		}()
+	}
+	return total
+}
+
+// put 适配器回调，不阻塞
+func (f *pushForwarder) put(pt PointConfig, u protocol.PointUpdate) {
+	var val interface{} = RawPoint{PointCfg: pt, Bytes: u.Bytes, Timestamp: u.Timestamp}
+	if u.Err != nil {
+		val = fmt.Sprintf("read error: %v", u.Err)
+	}
+	f.mu.Lock()
+	if _, ok := f.pending[pt.Name]; ok {
+		f.coalesced++
+	}
+	f.pending[pt.Name] = val
+	if u.Timestamp.After(f.latest) {
+		f.latest = u.Timestamp
+	}
+	f.mu.Unlock()
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take 取出待发点位
+func (f *pushForwarder) take() (map[string]interface{}, time.Time, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.pending) == 0 {
+		return nil, time.Time{}, 0
+	}
+	points, latest, coalesced := f.pending, f.latest, f.coalesced
+	f.pending = make(map[string]interface{}, len(points))
+	f.latest, f.coalesced = time.Time{}, 0
+	return points, latest, coalesced
+}
+
+func (f *pushForwarder) run(out chan<- RawCollectResult, stopCh <-chan struct{}, cancels []func()) {
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-f.notify:
+		}
+		points, latest, coalesced := f.take()
+		if points == nil {
+			continue
+		}
+		if coalesced > 0 {
+			log.Printf("[推送] 设备%s原始通道拥塞，合并%d次点位更新", f.dev, coalesced)
+		}
+		if latest.IsZero() {
+			latest = time.Now()
+		}
+		// 阻塞在这里时新更新继续在 pending 中合并
+		select {
+		case out <- RawCollectResult{DeviceName: f.dev, RawPoints: points, Timestamp: latest}:
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package device
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+	"cycV2/internal/protocol/iec104"
+)
+
+func TestIEC104PushToRawCh(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		startDT := make([]byte, 6)
+		if _, err := io.ReadFull(conn, startDT); err != nil {
+			return
+		}
+		conn.Write([]byte{0x68, 0x04, 0x0B, 0x00, 0x00, 0x00})
+		asdu := &iec104.ASDU{Type: iec104.M_ME_NC_1, Cause: iec104.CotSpontaneous, CA: 1,
+			Objects: []iec104.InfoObject{{IOA: 16385, Value: 231.5}}}
+		b, _ := asdu.Encode(time.UTC)
+		conn.Write(append([]byte{0x68, byte(4 + len(b)), 0, 0, 0, 0}, b...))
+		io.Copy(io.Discard, conn)
+	}()
+
+	client, err := iec104.NewIEC104Client(map[string]interface{}{"address": ln.Addr().String(), "giOnConnect": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	dev := &ModbusDevice{Cfg: DeviceConfig{Name: "pcs1", Points: []PointConfig{
+		{Name: "Ua", DataType: "float32", Params: map[string]interface{}{"ioa": 16385}},
+	}}, Adapter: client}
+
+	rawCh := make(chan RawCollectResult, 1)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if n := StartPushPipeline([]*ModbusDevice{dev}, rawCh, stopCh); n != 1 {
+		t.Fatalf("subscribed %d points", n)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case res := <-rawCh:
+		rp, ok := res.RawPoints["Ua"].(RawPoint)
+		if res.DeviceName != "pcs1" || !ok {
+			t.Fatalf("push result %+v", res)
+		}
+		if v := parseRaw(rp.Bytes, rp.PointCfg); v != float32(231.5) {
+			t.Fatalf("Ua expect 231.5, got %v", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no pushed result")
+	}
+}
+
+// subAdapter 手动触发推送的订阅适配器
+type subAdapter struct {
+	mockAdapter
+	handlers map[string]func(protocol.PointUpdate)
+}
+
+func (s *subAdapter) Subscribe(params map[string]interface{}, h func(protocol.PointUpdate)) (func(), error) {
+	name, _ := params["tag"].(string)
+	if name == "" {
+		return nil, errors.New("no tag")
+	}
+	s.handlers[name] = h
+	return func() {}, nil
+}
+
+func TestPushPipelineCoalesce(t *testing.T) {
+	adapter := &subAdapter{handlers: map[string]func(protocol.PointUpdate){}}
+	dev := &ModbusDevice{Cfg: DeviceConfig{Name: "d1", Points: []PointConfig{
+		{Name: "A", DataType: "uint16", Params: map[string]interface{}{"tag": "A"}},
+		{Name: "B", DataType: "uint16", Params: map[string]interface{}{"tag": "B"}},
+		{Name: "C", DataType: "uint16"}, // 订阅失败，仅轮询
+	}}, Adapter: adapter}
+	rawCh := make(chan RawCollectResult) // 无缓冲，模拟解析端拥塞
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if n := StartPushPipeline([]*ModbusDevice{dev}, rawCh, stopCh); n != 2 {
+		t.Fatalf("subscribed %d points", n)
+	}
+
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// 回调不得阻塞：通道无人接收时连续推送
+	for i := 0; i < 100; i++ {
+		adapter.handlers["A"](protocol.PointUpdate{Bytes: []byte{0, byte(i)}, Timestamp: ts})
+	}
+	adapter.handlers["B"](protocol.PointUpdate{Err: errors.New("invalid")})
+
+	got := map[string]interface{}{}
+	deadline := time.After(2 * time.Second)
+	for len(got) < 2 || got["A"].(RawPoint).Bytes[1] != 99 {
+		select {
+		case res := <-rawCh:
+			for k, v := range res.RawPoints {
+				got[k] = v
+			}
+		case <-deadline:
+			t.Fatalf("latest values not delivered: %+v", got)
+		}
+	}
+	if rp := got["A"].(RawPoint); !rp.Timestamp.Equal(ts) || parseRaw(rp.Bytes, rp.PointCfg) != uint16(99) {
+		t.Fatalf("A %+v", rp)
+	}
+	if s, ok := got["B"].(string); !ok || s != "read error: invalid" {
+		t.Fatalf("B %v", got["B"])
+	}
+}
@@ -3,9 +3,10 @@ package device
 import "time"
 
 type RawPoint struct {
-	PointCfg PointConfig
-	Bytes    []byte
-	Err      error
+	PointCfg  PointConfig
+	Bytes     []byte
+	Err       error
+	Timestamp time.Time // 推送型数据的时标，轮询采集为零值
 }
 
 //type RawDeviceData struct {
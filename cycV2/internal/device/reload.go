@@ -0,0 +1,251 @@
+package device
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 增量热加载：按设备名比对新旧配置，配置完全相同的设备沿用原实例（连接与写队列不中断），
+// 只有设备组成（增删、替换、顺序）变化的总线才停止并重启 worker，其余总线不受影响。
+// 被替换或移除的设备在旧 worker 退出后关闭：执行完已排队的写任务，再断开适配器。
+
+// ReloadReport 一次加载的变更
+type ReloadReport struct {
+	Added          []string // 新增设备
+	Updated        []string // 配置变化、已替换的设备
+	Removed        []string // 已移除并关闭的设备
+	Unchanged      int      // 沿用的设备数
+	RestartedBuses []string // 重启（含新建）worker 的总线
+	StoppedBuses   []string // 已无设备、停止 worker 的总线
+	Version        int      // 记录的配置版本，未启用版本存储时为0
+}
+
+// Changed 是否有任何设备或总线变化
+func (r *ReloadReport) Changed() bool {
+	return len(r.Added)+len(r.Updated)+len(r.Removed)+len(r.RestartedBuses)+len(r.StoppedBuses) > 0
+}
+
+func (r *ReloadReport) String() string {
+	if !r.Changed() {
+		if r.Version > 0 {
+			return fmt.Sprintf("no changes, %d device(s), version %d", r.Unchanged, r.Version)
+		}
+		return fmt.Sprintf("no changes, %d device(s)", r.Unchanged)
+	}
+	var parts []string
+	for _, p := range []struct {
+		name  string
+		items []string
+	}{
+		{"added", r.Added}, {"updated", r.Updated}, {"removed", r.Removed},
+		{"restarted buses", r.RestartedBuses}, {"stopped buses", r.StoppedBuses},
+	} {
+		if len(p.items) > 0 {
+			parts = append(parts, fmt.Sprintf("%s [%s]", p.name, strings.Join(p.items, " ")))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("unchanged %d", r.Unchanged))
+	if r.Version > 0 {
+		parts = append(parts, fmt.Sprintf("version %d", r.Version))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// apply 校验并增量应用配置内容，记录版本（含 include 的全部文件），必要时启动健康检查
+func (m *Manager) apply(data []byte, snapshot map[string][]byte, source, note string) (*ReloadReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 校验不通过时直接返回，旧的bus worker继续运行
+	cfgs, sources, err := loadConfigs(m.configPath, data, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]*ModbusDevice)
+	for _, devices := range m.Buses {
+		for _, dev := range devices {
+			current[dev.Cfg.Name] = dev
+		}
+	}
+
+	// 1. 组装新的 bus 分组：未变化的设备沿用，其余新建（仅创建适配器，不连接）
+	report := &ReloadReport{}
+	next := make(map[string][]*ModbusDevice)
+	kept := make(map[*ModbusDevice]bool)
+	var created []*ModbusDevice
+	for i, cfg := range cfgs {
+		old, exists := current[cfg.Name]
+		if exists && reflect.DeepEqual(old.Cfg, *cfg) {
+			kept[old] = true
+			report.Unchanged++
+			next[cfg.BusId] = append(next[cfg.BusId], old)
+			continue
+		}
+		dev, err := newDevice(m.configPath, i, cfg)
+		if err != nil {
+			for _, d := range created {
+				d.Close()
+			}
+			return nil, err
+		}
+		created = append(created, dev)
+		if exists {
+			report.Updated = append(report.Updated, cfg.Name)
+		} else {
+			report.Added = append(report.Added, cfg.Name)
+		}
+		next[cfg.BusId] = append(next[cfg.BusId], dev)
+	}
+	for name := range current {
+		if !containsDevice(next, name) {
+			report.Removed = append(report.Removed, name)
+		}
+	}
+
+	// 2. 停止设备组成变化的总线，worker 退出后再关闭被替换/移除的设备
+	var affected []string
+	for busID := range m.Buses {
+		if !sameDevices(m.Buses[busID], next[busID]) {
+			affected = append(affected, busID)
+		}
+	}
+	for busID := range next {
+		if _, running := m.Buses[busID]; !running {
+			affected = append(affected, busID)
+		}
+	}
+	sort.Strings(affected)
+	baseline := m.healthyBuses(affected)
+	for _, busID := range affected {
+		m.stopBus(busID)
+	}
+	for _, dev := range current {
+		if kept[dev] {
+			continue
+		}
+		if err := dev.Close(); err != nil {
+			log.Printf("关闭设备%s失败: %v", dev.Cfg.Name, err)
+		}
+	}
+
+	// 3. 启动新的“bus worker”各自管理一个物理总线
+	for _, busID := range affected {
+		if devices := next[busID]; len(devices) > 0 {
+			m.startBus(busID, devices)
+			report.RestartedBuses = append(report.RestartedBuses, busID)
+		} else {
+			report.StoppedBuses = append(report.StoppedBuses, busID)
+		}
+	}
+	m.Buses = next
+	m.sources = make(map[string]bool, len(sources.paths))
+	for _, p := range sources.paths {
+		m.sources[p] = true
+	}
+	sort.Strings(report.Added)
+	sort.Strings(report.Updated)
+	sort.Strings(report.Removed)
+
+	// 4. 记录版本；热加载（非回滚）改动了原本健康的总线时进入观察期
+	m.generation++
+	if m.Versions == nil {
+		return report, nil
+	}
+	prev, hasPrev := m.Versions.Latest()
+	bundle, err := encodeConfigBundle(m.configPath, sources.files)
+	if err != nil {
+		log.Printf("保存配置版本失败: %v", err)
+		return report, nil
+	}
+	// 启用自动回滚时 keep=1 也要保留上一版本，否则观察期内无版本可回滚
+	minKeep := 0
+	if m.Rollback.Grace > 0 {
+		minKeep = 2
+	}
+	v, isNew, err := m.Versions.save(bundle, source, note, minKeep)
+	if err != nil {
+		log.Printf("保存配置版本失败: %v", err)
+		return report, nil
+	}
+	report.Version = v.Version
+	if isNew && source == SourceReload && hasPrev && m.Rollback.Grace > 0 && len(baseline) > 0 {
+		go m.watchHealth(m.generation, prev.Version, baseline)
+	}
+	return report, nil
+}
+
+// startBus 调用方持有 mu
+func (m *Manager) startBus(busID string, devices []*ModbusDevice) {
+	log.Printf("启动采集流水线，总线bus[%s]有%d个设备", busID, len(devices))
+	stopCh := make(chan struct{})
+	base := make(map[*ModbusDevice][2]uint64, len(devices))
+	for _, dev := range devices {
+		ok, failed := dev.ReadStats()
+		base[dev] = [2]uint64{ok, failed}
+	}
+	wg := StartCollectPipeline(devices, m.RawCh, stopCh)
+	startPushPipeline(devices, m.RawCh, stopCh, wg)
+	m.BusStop[busID] = stopCh
+	m.busWg[busID] = wg
+	m.busBase[busID] = base
+}
+
+// stopBus 通知 worker 退出并等待，调用方持有 mu
+func (m *Manager) stopBus(busID string) {
+	if stopCh, ok := m.BusStop[busID]; ok {
+		close(stopCh)
+		delete(m.BusStop, busID)
+		log.Printf("关闭总线worker %s", busID)
+	}
+	if wg, ok := m.busWg[busID]; ok {
+		wg.Wait()
+		delete(m.busWg, busID)
+	}
+	delete(m.busBase, busID)
+}
+
+// Close 停止全部总线并关闭所有设备
+func (m *Manager) Close() error {
+	m.closeOnce.Do(func() { close(m.closeCh) })
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generation++
+	var firstErr error
+	for busID, devices := range m.Buses {
+		m.stopBus(busID)
+		for _, dev := range devices {
+			if err := dev.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	m.Buses = make(map[string][]*ModbusDevice)
+	return firstErr
+}
+
+func sameDevices(a, b []*ModbusDevice) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsDevice(buses map[string][]*ModbusDevice, name string) bool {
+	for _, devices := range buses {
+		for _, dev := range devices {
+			if dev.Cfg.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package device
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// reloadAdapter 记录写入与断开次数，按 params.id 登记便于检查实例是否被替换
+type reloadAdapter struct {
+	mockAdapter
+	writes, disconnects atomic.Int32
+}
+
+func (a *reloadAdapter) Write(_ string, _ []byte, _ map[string]interface{}) error {
+	time.Sleep(20 * time.Millisecond)
+	a.writes.Add(1)
+	return nil
+}
+
+func (a *reloadAdapter) Disconnect() error {
+	a.disconnects.Add(1)
+	return nil
+}
+
+var reloadAdapters sync.Map // id -> *reloadAdapter
+
+func init() {
+	protocol.Register("reloadtest", func(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+		a := &reloadAdapter{}
+		id, _ := cfg["id"].(string)
+		reloadAdapters.Store(id, a)
+		return a, nil
+	})
+}
+
+func reloadAdapterOf(id string) *reloadAdapter {
+	a, _ := reloadAdapters.Load(id)
+	return a.(*reloadAdapter)
+}
+
+func writeConfig(t *testing.T, path, cfg string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncrementalReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	writeConfig(t, path, `[
+	  {"name": "d1", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "d1-v1"}},
+	  {"name": "d2", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "d2-v1"}},
+	  {"name": "d3", "AdapterName": "reloadtest", "busId": "B", "params": {"id": "d3-v1"}}]`)
+	m := NewManager(path)
+	defer m.Close()
+	report, err := m.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Added, ",") != "d1,d2,d3" || strings.Join(report.RestartedBuses, ",") != "A,B" {
+		t.Fatalf("first load: %s", report)
+	}
+	d1, _ := m.Device("d1")
+	d2, _ := m.Device("d2")
+	d3, _ := m.Device("d3")
+	stopB := m.BusStop["B"]
+
+	// d2 变更、d4 新增到新总线 C；d1、d3 不变，总线 B 不受影响
+	writeConfig(t, path, `[
+	  {"name": "d1", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "d1-v1"}},
+	  {"name": "d2", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "d2-v2"}, "interval_ms": 500},
+	  {"name": "d3", "AdapterName": "reloadtest", "busId": "B", "params": {"id": "d3-v1"}},
+	  {"name": "d4", "AdapterName": "reloadtest", "busId": "C", "params": {"id": "d4-v1"}}]`)
+	pending := d2.ControlAsync("x", []byte{1}, nil) // 替换前排队的写入应被执行
+	report, err = m.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := report.String(); got != "added [d4], updated [d2], restarted buses [A C], unchanged 2" {
+		t.Errorf("report = %s", got)
+	}
+	if err := <-pending; err != nil || reloadAdapterOf("d2-v1").writes.Load() != 1 {
+		t.Errorf("queued write: %v", err)
+	}
+	if reloadAdapterOf("d2-v1").disconnects.Load() != 1 || reloadAdapterOf("d1-v1").disconnects.Load() != 0 {
+		t.Error("old adapter not closed or unchanged adapter closed")
+	}
+	if err := <-d2.ControlAsync("x", []byte{1}, nil); !errors.Is(err, ErrDeviceClosed) {
+		t.Errorf("write to replaced device: %v", err)
+	}
+	if n1, _ := m.Device("d1"); n1 != d1 {
+		t.Error("unchanged device d1 was rebuilt")
+	}
+	if n2, _ := m.Device("d2"); n2 == d2 || n2.Cfg.IntervalMs != 500 {
+		t.Error("d2 not swapped")
+	}
+	if n3, _ := m.Device("d3"); n3 != d3 || m.BusStop["B"] != stopB {
+		t.Error("untouched bus B restarted")
+	}
+
+	// 移除 d3：总线 B 停止，设备关闭
+	writeConfig(t, path, `[
+	  {"name": "d1", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "d1-v1"}},
+	  {"name": "d2", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "d2-v2"}, "interval_ms": 500},
+	  {"name": "d4", "AdapterName": "reloadtest", "busId": "C", "params": {"id": "d4-v1"}}]`)
+	if report, err = m.Reload(); err != nil || report.String() != "removed [d3], stopped buses [B], unchanged 3" {
+		t.Fatalf("report = %v, %v", report, err)
+	}
+	if _, ok := m.BusStop["B"]; ok || reloadAdapterOf("d3-v1").disconnects.Load() != 1 {
+		t.Error("bus B not stopped")
+	}
+
+	// 无变化
+	if report, err = m.Reload(); err != nil || report.Changed() {
+		t.Errorf("report = %v, %v", report, err)
+	}
+}
+
+func TestWatchDebounceAndRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devices.json")
+	writeConfig(t, path, `[{"name": "w1", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "w1"}}]`)
+	m := NewManager(path)
+	m.ReloadDebounce = 50 * time.Millisecond
+	defer m.Close()
+	var reloads atomic.Int32
+	m.OnReload(func(map[string][]*ModbusDevice) { reloads.Add(1) })
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- m.Watch(stop) }()
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(3 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %s", what)
+			}
+		}
+	}
+	waitFor("initial load", func() bool { _, ok := m.Device("w1"); return ok })
+
+	// 连续多次写入只触发一次加载
+	for i := 0; i < 5; i++ {
+		writeConfig(t, path, `[{"name": "w1", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "w1"}, "interval_ms": 200}]`)
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitFor("debounced reload", func() bool { d, _ := m.Device("w1"); return d.Cfg.IntervalMs == 200 })
+	time.Sleep(150 * time.Millisecond)
+	if n := reloads.Load(); n != 2 {
+		t.Errorf("reloads = %d, want 2", n)
+	}
+
+	// 原子改名替换（Create 事件）
+	tmp := filepath.Join(dir, ".devices.json.tmp")
+	writeConfig(t, tmp, `[{"name": "w2", "AdapterName": "reloadtest", "busId": "A", "params": {"id": "w2"}}]`)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("rename reload", func() bool { _, ok := m.Device("w2"); return ok })
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,194 @@
+package device
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RollbackPolicy 热加载后的健康检查：观察期内，被本次加载重启、且加载前健康的总线，
+// 采集成功率跌破阈值（如整条总线波特率配错）时自动回滚到上一版本。需启用版本存储
+type RollbackPolicy struct {
+	Grace          time.Duration // 观察期，0 关闭自动回滚
+	MinSuccessRate float64       // 成功率阈值，缺省0.5
+	MinPolls       int           // 判定所需的最少采集点次，缺省10
+}
+
+func (p RollbackPolicy) minRate() float64 {
+	if p.MinSuccessRate <= 0 {
+		return 0.5
+	}
+	return p.MinSuccessRate
+}
+
+func (p RollbackPolicy) minPolls() uint64 {
+	if p.MinPolls <= 0 {
+		return 10
+	}
+	return uint64(p.MinPolls)
+}
+
+// EnableVersions 启用版本存储，dir 为空时使用配置文件同目录下的 .versions/<文件名>
+func (m *Manager) EnableVersions(dir string, keep int) error {
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(m.configPath), ".versions", filepath.Base(m.configPath))
+	}
+	s, err := NewVersionStore(dir, keep)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.Versions = s
+	m.mu.Unlock()
+	return nil
+}
+
+// RollbackTo 重新应用指定版本并写回配置文件（含 include 的文件），记录为新版本（来源 rollback），不进入观察期
+func (m *Manager) RollbackTo(version int) (*ReloadReport, error) {
+	return m.rollbackTo(version, fmt.Sprintf("rollback to v%d", version))
+}
+
+func (m *Manager) rollbackTo(version int, note string) (*ReloadReport, error) {
+	if m.Versions == nil {
+		return nil, errors.New("config versioning is not enabled")
+	}
+	data, _, err := m.Versions.Load(version)
+	if err != nil {
+		return nil, err
+	}
+	main, files, err := decodeConfigBundle(m.configPath, data)
+	if err != nil {
+		return nil, err
+	}
+	// 先按版本内容应用再写回，写回后文件监听触发的加载内容相同，不会产生变更或新版本
+	report, err := m.applyConfig(main, files, SourceRollback, note)
+	if err != nil {
+		return nil, fmt.Errorf("rollback to v%d: %w", version, err)
+	}
+	if err := restoreFiles(m.configPath, main, files, version); err != nil {
+		return report, fmt.Errorf("rollback to v%d applied, but restoring config files failed: %w", version, err)
+	}
+	return report, nil
+}
+
+// restoreFiles 写回版本中的文件（内容未变的不写）。写回后的配置仍会读入版本之外的文件时
+// （如 include 目录或通配符新增的文件），将其改名为 <文件名>.rollback-v<版本号> 移出，不删除
+func restoreFiles(configPath string, main []byte, files map[string][]byte, version int) error {
+	if files == nil {
+		return writeFileAtomic(configPath, main) // 早期版本只有主文件
+	}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if cur, err := os.ReadFile(p); err == nil && bytes.Equal(cur, files[p]) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(p, files[p]); err != nil {
+			return err
+		}
+	}
+	// 每轮至少移出一个文件，必然结束
+	for {
+		_, src, err := parseConfigSources(configPath, main, nil)
+		if err != nil {
+			return err
+		}
+		var extra []string
+		for p := range src.files {
+			if _, ok := files[p]; !ok {
+				extra = append(extra, p)
+			}
+		}
+		if len(extra) == 0 {
+			return nil
+		}
+		sort.Strings(extra)
+		for _, p := range extra {
+			aside := fmt.Sprintf("%s.rollback-v%d", p, version)
+			if err := os.Rename(p, aside); err != nil {
+				return err
+			}
+			log.Printf("回滚到版本%d: %s 不在该版本中，已改名为 %s", version, p, aside)
+		}
+	}
+}
+
+// busRate 总线自启动以来的采集成功率与点次，调用方持有 mu
+func (m *Manager) busRate(busID string) (float64, uint64) {
+	var ok, total uint64
+	base := m.busBase[busID]
+	for _, dev := range m.Buses[busID] {
+		o, f := dev.ReadStats()
+		b := base[dev]
+		ok += o - b[0]
+		total += o - b[0] + f - b[1]
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(ok) / float64(total), total
+}
+
+// healthyBuses 即将重启的总线中当前健康的（样本足够且成功率达标），调用方持有 mu
+func (m *Manager) healthyBuses(busIDs []string) map[string]float64 {
+	out := make(map[string]float64)
+	for _, busID := range busIDs {
+		if _, running := m.BusStop[busID]; !running {
+			continue
+		}
+		if rate, n := m.busRate(busID); n >= m.Rollback.minPolls() && rate >= m.Rollback.minRate() {
+			out[busID] = rate
+		}
+	}
+	return out
+}
+
+// watchHealth 观察期内定期检查，任一总线成功率崩溃即回滚到 prev；期间有新的加载则放弃
+func (m *Manager) watchHealth(gen uint64, prev int, baseline map[string]float64) {
+	policy := m.Rollback
+	ticker := time.NewTicker(policy.Grace / 4)
+	defer ticker.Stop()
+	deadline := time.Now().Add(policy.Grace)
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.closeCh:
+			return
+		}
+		m.mu.Lock()
+		if m.generation != gen {
+			m.mu.Unlock()
+			return
+		}
+		var reason string
+		for busID, before := range baseline {
+			rate, n := m.busRate(busID)
+			if n >= policy.minPolls() && rate < policy.minRate() {
+				reason = fmt.Sprintf("bus %s success rate %.0f%% (was %.0f%%) after reload", busID, rate*100, before*100)
+				break
+			}
+		}
+		m.mu.Unlock()
+		if reason != "" {
+			log.Printf("配置热加载后采集异常，自动回滚到版本%d: %s", prev, reason)
+			if _, err := m.rollbackTo(prev, "auto rollback: "+reason); err != nil {
+				log.Printf("自动回滚失败: %v", err)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			return
+		}
+	}
+}
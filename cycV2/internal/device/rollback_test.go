@@ -0,0 +1,231 @@
+package device
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// baudAdapter 波特率配成1200时所有读取失败，模拟整条总线参数配错
+type baudAdapter struct {
+	mockAdapter
+	fail bool
+}
+
+func (a *baudAdapter) Read(map[string]interface{}) ([]byte, error) {
+	if a.fail {
+		return nil, errors.New("timeout")
+	}
+	return []byte{0, 1}, nil
+}
+
+func (a *baudAdapter) Disconnect() error { return nil }
+
+func init() {
+	protocol.Register("baudtest", func(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+		baud, _ := toInt(cfg["baudRate"])
+		return &baudAdapter{fail: baud == 1200}, nil
+	})
+}
+
+func TestVersionStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewVersionStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, cfg := range []string{"a", "a", "b", "c"} {
+		v, isNew, err := s.Save([]byte(cfg), SourceReload, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isNew == (i == 1) {
+			t.Errorf("save %d: version %d, new %v", i, v.Version, isNew)
+		}
+	}
+	// 只保留最近2个，重新打开后索引一致
+	s, err = NewVersionStore(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := s.List()
+	if len(list) != 2 || list[0].Version != 2 || list[1].Version != 3 {
+		t.Fatalf("versions = %+v", list)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "v000001.json")); !os.IsNotExist(err) {
+		t.Error("pruned version kept")
+	}
+	if data, v, err := s.Load(2); err != nil || string(data) != "b" || v.Hash != list[0].Hash {
+		t.Errorf("load = %q %+v %v", data, v, err)
+	}
+	os.WriteFile(filepath.Join(dir, "v000003.json"), []byte("x"), 0o644)
+	if _, _, err := s.Load(3); err == nil {
+		t.Error("tampered version loaded")
+	}
+}
+
+func TestAutoRollback(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devices.json")
+	good := []byte(`[{"name": "d1", "AdapterName": "baudtest", "busId": "A", "interval_ms": 10,
+	  "params": {"baudRate": 9600}, "points": [{"name": "p1"}, {"name": "p2"}]}]`)
+	bad := bytes.Replace(good, []byte("9600"), []byte("1200"), 1)
+	writeConfig(t, path, string(good))
+
+	m := NewManager(path)
+	defer m.Close()
+	go func() {
+		for range m.RawCh {
+		}
+	}()
+	if err := m.EnableVersions("", 5); err != nil {
+		t.Fatal(err)
+	}
+	m.Rollback = RollbackPolicy{Grace: 2 * time.Second, MinPolls: 6}
+	if r, err := m.Reload(); err != nil || r.Version != 1 {
+		t.Fatalf("load: %v %v", r, err)
+	}
+	waitUntil := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(3 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %s", what)
+			}
+		}
+	}
+	d, _ := m.Device("d1")
+	waitUntil("healthy polls", func() bool { ok, _ := d.ReadStats(); return ok >= 10 })
+
+	writeConfig(t, path, string(bad))
+	if r, err := m.Reload(); err != nil || r.Version != 2 {
+		t.Fatalf("bad reload: %v %v", r, err)
+	}
+	waitUntil("auto rollback", func() bool {
+		d, _ := m.Device("d1")
+		return d.Cfg.Params["baudRate"] == float64(9600)
+	})
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, good) {
+		t.Errorf("config file not restored: %s", data)
+	}
+	list := m.Versions.List()
+	if len(list) != 3 || list[2].Source != SourceRollback || list[2].Hash != list[0].Hash {
+		t.Fatalf("versions = %+v", list)
+	}
+
+	// 写回的文件再次加载：内容相同，无变更也无新版本
+	if r, err := m.Reload(); err != nil || r.Changed() || r.Version != 3 {
+		t.Errorf("reload after rollback: %v %v", r, err)
+	}
+
+	// 显式回滚到坏版本：不进入观察期
+	if _, err := m.RollbackTo(2); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(700 * time.Millisecond)
+	if d, _ := m.Device("d1"); d.Cfg.Params["baudRate"] != float64(1200) || len(m.Versions.List()) != 4 {
+		t.Errorf("explicit rollback undone: %v", d.Cfg.Params)
+	}
+	if _, err := m.RollbackTo(99); err == nil {
+		t.Error("rollback to unknown version")
+	}
+}
+
+func TestAutoRollbackKeepOne(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "devices.json")
+	good := `[{"name": "d1", "AdapterName": "baudtest", "busId": "A", "interval_ms": 10,
+	  "params": {"baudRate": 9600}, "points": [{"name": "p1"}]}]`
+	writeConfig(t, path, good)
+
+	m := NewManager(path)
+	defer m.Close()
+	go func() {
+		for range m.RawCh {
+		}
+	}()
+	if err := m.EnableVersions("", 1); err != nil {
+		t.Fatal(err)
+	}
+	m.Rollback = RollbackPolicy{Grace: 2 * time.Second, MinPolls: 6}
+	if _, err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, path, strings.Replace(good, "9600", "1200", 1))
+	if r, err := m.Reload(); err != nil || r.Version != 2 {
+		t.Fatalf("bad reload: %v %v", r, err)
+	}
+	// keep=1 时仍保留观察期需要的上一版本
+	if _, _, err := m.Versions.Load(1); err != nil {
+		t.Errorf("previous version pruned: %v", err)
+	}
+}
+
+func TestAutoRollbackIncludedFile(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "devices"), 0o755)
+	path := filepath.Join(dir, "main.yaml")
+	inc := filepath.Join(dir, "devices", "d1.json")
+	good := `{"name": "d1", "AdapterName": "baudtest", "busId": "A", "interval_ms": 10, "params": {"baudRate": 9600}, "points": [{"name": "p1"}]}`
+	writeConfig(t, path, "include: devices\n")
+	writeConfig(t, inc, good)
+
+	m := NewManager(path)
+	defer m.Close()
+	go func() {
+		for range m.RawCh {
+		}
+	}()
+	if err := m.EnableVersions("", 5); err != nil {
+		t.Fatal(err)
+	}
+	m.Rollback = RollbackPolicy{Grace: 2 * time.Second, MinPolls: 6}
+	if r, err := m.Reload(); err != nil || r.Version != 1 {
+		t.Fatalf("load: %v %v", r, err)
+	}
+	d, _ := m.Device("d1")
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if ok, _ := d.ReadStats(); ok >= 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for healthy polls")
+		}
+	}
+
+	// 只改被包含的文件并新增一个文件：产生新版本并进入观察期
+	writeConfig(t, inc, strings.Replace(good, "9600", "1200", 1))
+	writeConfig(t, filepath.Join(dir, "devices", "d2.json"), `{"name": "d2", "AdapterName": "baudtest", "busId": "B", "params": {"baudRate": 9600}}`)
+	if r, err := m.Reload(); err != nil || r.Version != 2 {
+		t.Fatalf("bad reload: %v %v", r, err)
+	}
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if d, _ := m.Device("d1"); d.Cfg.Params["baudRate"] == float64(9600) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for auto rollback")
+		}
+	}
+	if data, _ := os.ReadFile(inc); string(data) != good {
+		t.Errorf("included file not restored: %s", data)
+	}
+	if _, ok := m.Device("d2"); ok {
+		t.Error("device from the added file still loaded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "devices", "d2.json.rollback-v1")); err != nil {
+		t.Errorf("added file not set aside: %v", err)
+	}
+	list := m.Versions.List()
+	if len(list) != 3 || list[2].Source != SourceRollback || list[2].Hash != list[0].Hash {
+		t.Fatalf("versions = %+v", list)
+	}
+	if r, err := m.Reload(); err != nil || r.Changed() || r.Version != 3 {
+		t.Errorf("reload after rollback: %v %v", r, err)
+	}
+}
@@ -0,0 +1,176 @@
+package device
+
+import (
+	"path"
+	"time"
+)
+
+// 点位扫描等级：同一设备内的点位可按不同周期采集，如单体电压 200ms、序列号/固件版本连接后只读一次。
+// 点位 scanClass 指定等级；设备 scanGroups 按点位名通配（path.Match 语法）成组指定，点位自身设置优先；都未指定为 normal。
+// 周期默认 fast 200ms、normal 取 interval_ms（默认1s）、slow 60s，设备 scanClasses 可覆盖：
+//
+//	{"name": "bms1", "interval_ms": 1000, "scanClasses": {"slow": 86400000},
+//	 "scanGroups": {"fast": ["cell*"], "once": ["sn", "fw*"]}, "points": [...]}
+//
+// 总线每个节拍为各设备收集到期等级的点位一并交给 CollectPoints，实现 protocol.MultiReader 的适配器合并请求
+// （Modbus 按功能码把地址相邻的点位合并为块读取，见 modbus.ReadMulti）。
+// once 点位随总线启动后的首轮采集读取，失败则随后续采集重试；设备一轮采集全部失败（视为断线）后重新读取
+const (
+	ScanFast   = "fast"
+	ScanNormal = "normal"
+	ScanSlow   = "slow"
+	ScanOnce   = "once"
+)
+
+// 周期等级，按周期由短到长
+var periodicScanClasses = []string{ScanFast, ScanNormal, ScanSlow}
+
+// 未配置 scanClasses 时的默认周期（毫秒），normal 取 interval_ms
+var defaultScanPeriods = map[string]int{ScanFast: 200, ScanSlow: 60000}
+
+func knownScanClass(class string) bool {
+	return class == ScanOnce || class == ScanFast || class == ScanNormal || class == ScanSlow
+}
+
+// scanClassOf 点位的扫描等级；scanGroups 中多个等级都匹配时取 periodicScanClasses 中靠前的，once 最后
+func (c *DeviceConfig) scanClassOf(pt PointConfig) string {
+	if pt.ScanClass != "" {
+		return pt.ScanClass
+	}
+	for _, class := range append(periodicScanClasses, ScanOnce) {
+		for _, pattern := range c.ScanGroups[class] {
+			if ok, _ := path.Match(pattern, pt.Name); ok {
+				return class
+			}
+		}
+	}
+	return ScanNormal
+}
+
+// scanPeriodMs 周期等级的采集周期
+func (c *DeviceConfig) scanPeriodMs(class string) int {
+	if ms := c.ScanClasses[class]; ms > 0 {
+		return ms
+	}
+	if class == ScanNormal {
+		if c.IntervalMs > 0 {
+			return c.IntervalMs
+		}
+		return 1000 // 默认1s
+	}
+	return defaultScanPeriods[class]
+}
+
+// scanBatch 同一周期等级的点位
+type scanBatch struct {
+	class    string
+	periodMs int
+	points   []PointConfig
+}
+
+// scanPlan 按周期等级划分点位（保持配置顺序），只返回有点位的等级；
+// 没有周期点位的设备返回一个空的 normal，总线节拍与未分级时一致。once 点位单独返回
+func (c *DeviceConfig) scanPlan() (batches []scanBatch, once []PointConfig) {
+	byClass := make(map[string][]PointConfig)
+	for _, pt := range c.Points {
+		class := c.scanClassOf(pt)
+		if class == ScanOnce {
+			once = append(once, pt)
+			continue
+		}
+		byClass[class] = append(byClass[class], pt)
+	}
+	for _, class := range periodicScanClasses {
+		if pts := byClass[class]; len(pts) > 0 {
+			batches = append(batches, scanBatch{class: class, periodMs: c.scanPeriodMs(class), points: pts})
+		}
+	}
+	if len(batches) == 0 {
+		batches = []scanBatch{{class: ScanNormal, periodMs: c.scanPeriodMs(ScanNormal)}}
+	}
+	return batches, once
+}
+
+// scanSchedule 单台设备的扫描调度状态，只在总线 worker 中使用
+type scanSchedule struct {
+	cfg     *DeviceConfig
+	class   map[string]string // 点位名 -> 扫描等级
+	period  map[string]time.Duration
+	last    map[string]time.Time
+	once    []string        // once 点位名
+	pending map[string]bool // 尚未读取成功的 once 点位
+}
+
+func newScanSchedule(cfg *DeviceConfig) *scanSchedule {
+	s := &scanSchedule{
+		cfg:     cfg,
+		class:   make(map[string]string, len(cfg.Points)),
+		period:  make(map[string]time.Duration),
+		last:    make(map[string]time.Time),
+		pending: make(map[string]bool),
+	}
+	batches, once := cfg.scanPlan()
+	for _, b := range batches {
+		s.period[b.class] = time.Duration(b.periodMs) * time.Millisecond
+		for _, pt := range b.points {
+			s.class[pt.Name] = b.class
+		}
+	}
+	for _, pt := range once {
+		s.class[pt.Name] = ScanOnce
+		s.once = append(s.once, pt.Name)
+		s.pending[pt.Name] = true
+	}
+	return s
+}
+
+// minPeriod 最短采集周期，用作总线节拍
+func (s *scanSchedule) minPeriod() time.Duration {
+	var min time.Duration
+	for _, p := range s.period {
+		if min == 0 || p < min {
+			min = p
+		}
+	}
+	return min
+}
+
+// due 返回本轮到期的等级与点位（按配置顺序）。待读的 once 点位随周期等级一起读取，
+// 离线时不单独发请求；设备没有周期点位时每个 normal 周期重试
+func (s *scanSchedule) due(now time.Time) (classes map[string]bool, points []PointConfig) {
+	classes = make(map[string]bool)
+	for class, p := range s.period {
+		if now.Sub(s.last[class]) >= p {
+			classes[class] = true
+		}
+	}
+	if len(classes) == 0 {
+		return nil, nil
+	}
+	for _, pt := range s.cfg.Points {
+		class := s.class[pt.Name]
+		if classes[class] || class == ScanOnce && s.pending[pt.Name] {
+			points = append(points, pt)
+		}
+	}
+	return classes, points
+}
+
+// done 记录本轮采集结果
+func (s *scanSchedule) done(now time.Time, classes map[string]bool, result map[string]interface{}) {
+	for class := range classes {
+		s.last[class] = now
+	}
+	ok := 0
+	for name, v := range result {
+		if _, isRaw := v.(RawPoint); isRaw {
+			ok++
+			delete(s.pending, name)
+		}
+	}
+	if len(result) > 0 && ok == 0 {
+		for _, name := range s.once {
+			s.pending[name] = true
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package device
+
+import (
+	"cycV2/internal/protocol/modbus"
+	"fmt"
+)
+
+// SkeletonFromScan 根据扫描结果为每个在线站号生成设备配置骨架，所有可读地址按 uint16 单寄存器点位输出（线圈/离散量为 bool），
+// 现场再按实际点表修改名称/类型。base 提供 BusId/AdapterName/Params 等总线公共配置
+func SkeletonFromScan(report *modbus.ScanReport, base DeviceConfig, namePrefix string) []DeviceConfig {
+	if namePrefix == "" {
+		namePrefix = "dev"
+	}
+	var out []DeviceConfig
+	for _, slave := range report.Responsive() {
+		cfg := base
+		cfg.Name = fmt.Sprintf("%s-%d", namePrefix, slave.SlaveID)
+		cfg.SlaveId = slave.SlaveID
+		if cfg.AdapterName == "" {
+			cfg.AdapterName = "modbus"
+		}
+		cfg.Params = mergeParams(base.Params, map[string]interface{}{"slaveId": int(slave.SlaveID)})
+		cfg.Points = nil
+		for _, r := range slave.Ranges {
+			// 探测区间可写 "01"~"04"，统一为适配器识别的 hr/ir/co/di
+			fn := modbus.FuncName(r.Func)
+			dataType := "uint16"
+			if fn == "co" || fn == "di" {
+				dataType = "bool"
+			}
+			for i := uint16(0); i < r.Quantity; i++ {
+				addr := r.StartAddr + i
+				cfg.Points = append(cfg.Points, PointConfig{
+					Name:      fmt.Sprintf("%s_%d", fn, addr),
+					FuncCode:  fn,
+					RegAddr:   addr,
+					RegNum:    1,
+					DataType:  dataType,
+					ByteOrder: "big",
+					Rw:        "r",
+					Params: map[string]interface{}{
+						"func":     fn,
+						"address":  int(addr),
+						"quantity": 1,
+					},
+				})
+			}
+		}
+		out = append(out, cfg)
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package device
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"cycV2/internal/protocol/modbus"
+)
+
+// 简易 Modbus TCP 从站：站号1的四种读功能码均应答全0，其他请求返回非法地址；返回地址与请求计数
+func startModbusStub(t *testing.T) (string, *atomic.Int64) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var requests atomic.Int64
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				hdr := make([]byte, 7)
+				for {
+					if _, err := io.ReadFull(conn, hdr); err != nil {
+						return
+					}
+					pdu := make([]byte, int(binary.BigEndian.Uint16(hdr[4:]))-1)
+					if _, err := io.ReadFull(conn, pdu); err != nil {
+						return
+					}
+					requests.Add(1)
+					fc, qty := pdu[0], binary.BigEndian.Uint16(pdu[3:])
+					var resp []byte
+					switch {
+					case hdr[6] == 1 && (fc == 0x01 || fc == 0x02):
+						n := (qty + 7) / 8
+						resp = append([]byte{fc, byte(n)}, make([]byte, n)...)
+					case hdr[6] == 1 && (fc == 0x03 || fc == 0x04):
+						resp = append([]byte{fc, byte(qty * 2)}, make([]byte, qty*2)...)
+					default:
+						resp = []byte{fc | 0x80, 0x02}
+					}
+					out := make([]byte, 7, 7+len(resp))
+					copy(out, hdr[:4])
+					binary.BigEndian.PutUint16(out[4:], uint16(len(resp)+1))
+					out[6] = hdr[6]
+					conn.Write(append(out, resp...))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), &requests
+}
+
+func TestSkeletonFromScanNumericFunc(t *testing.T) {
+	addr, _ := startModbusStub(t)
+	// 探测区间按数字功能码配置时，扫描结果中的 Func 也是数字
+	report := &modbus.ScanReport{Slaves: []modbus.SlaveReport{{
+		SlaveID: 1, Responsive: true,
+		Ranges: []modbus.ReadableRange{
+			{Func: "01", StartAddr: 0, Quantity: 1},
+			{Func: "02", StartAddr: 4, Quantity: 1},
+			{Func: "03", StartAddr: 10, Quantity: 2},
+			{Func: "04", StartAddr: 0, Quantity: 1},
+		},
+	}}}
+	base := DeviceConfig{BusId: "tcp1", Params: map[string]interface{}{"mode": "tcp", "address": addr}}
+	cfgs := SkeletonFromScan(report, base, "")
+	if len(cfgs) != 1 || len(cfgs[0].Points) != 5 {
+		t.Fatalf("skeleton = %+v", cfgs)
+	}
+	cfg := cfgs[0]
+	want := map[string]string{"co_0": "bool", "di_4": "bool", "hr_10": "uint16", "hr_11": "uint16", "ir_0": "uint16"}
+	for _, pt := range cfg.Points {
+		if dt, ok := want[pt.Name]; !ok || pt.DataType != dt || pt.FuncCode != pt.Params["func"] {
+			t.Errorf("point %s: funcCode %s, func %v, dataType %s", pt.Name, pt.FuncCode, pt.Params["func"], pt.DataType)
+		}
+	}
+	if r := ValidateDeviceConfigs("", []*DeviceConfig{&cfg}); len(r.Issues) != 0 {
+		t.Errorf("issues = %v", r.Issues)
+	}
+
+	a, err := modbus.NewModbusAdapter(cfg.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Disconnect()
+	for _, pt := range cfg.Points {
+		data, err := a.Read(mergeParams(cfg.Params, pt.Params))
+		if err != nil {
+			t.Errorf("read %s: %v", pt.Name, err)
+			continue
+		}
+		// 线圈/离散量1个点1字节，寄存器2字节
+		if size := map[string]int{"bool": 1, "uint16": 2}[pt.DataType]; len(data) != size {
+			t.Errorf("read %s: %d byte(s), want %d", pt.Name, len(data), size)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package device
+
+import (
+	"cycV2/internal/protocol/modbus"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scanTestConfig() *DeviceConfig {
+	pt := func(name, class string) PointConfig {
+		return PointConfig{Name: name, DataType: "uint16", ScanClass: class, Params: map[string]interface{}{"address": name}}
+	}
+	return &DeviceConfig{
+		Name: "bms1", AdapterName: "modbus", IntervalMs: 1000,
+		ScanClasses: map[string]int{ScanSlow: 5000},
+		ScanGroups:  map[string][]string{ScanFast: {"cell*"}, ScanOnce: {"sn", "fw*"}},
+		Points: []PointConfig{
+			pt("cell1", ""), pt("soc", ""), pt("sn", ""), pt("cell2", ""), pt("temp", ScanSlow), pt("fwVer", ""), pt("cellSum", ScanNormal),
+		},
+	}
+}
+
+func names(points []PointConfig) string {
+	var out []string
+	for _, p := range points {
+		out = append(out, p.Name)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestScanSchedule(t *testing.T) {
+	cfg := scanTestConfig()
+	s := newScanSchedule(cfg)
+	if s.minPeriod() != 200*time.Millisecond {
+		t.Fatalf("minPeriod = %v", s.minPeriod())
+	}
+	t0 := time.Now()
+	ok := func(points []PointConfig, failed ...string) map[string]interface{} {
+		res := make(map[string]interface{})
+		for _, p := range points {
+			res[p.Name] = RawPoint{PointCfg: p}
+		}
+		for _, n := range failed {
+			res[n] = "read error: timeout"
+		}
+		return res
+	}
+	for _, step := range []struct {
+		at     time.Duration
+		want   string
+		failed []string
+	}{
+		// 首轮全部到期，once 点位一并读取；fwVer 失败
+		{0, "cell1,soc,sn,cell2,temp,fwVer,cellSum", []string{"fwVer"}},
+		{100 * time.Millisecond, "", nil},
+		// 只有 fast 到期，待重试的 once 点位随之读取
+		{200 * time.Millisecond, "cell1,cell2,fwVer", nil},
+		{400 * time.Millisecond, "cell1,cell2", nil},
+		{1000 * time.Millisecond, "cell1,soc,cell2,cellSum", nil},
+		{5000 * time.Millisecond, "cell1,soc,cell2,temp,cellSum", nil},
+	} {
+		now := t0.Add(step.at)
+		classes, points := s.due(now)
+		if got := names(points); got != step.want {
+			t.Errorf("%v: due = %s, want %s", step.at, got, step.want)
+		}
+		if len(classes) > 0 {
+			s.done(now, classes, ok(points, step.failed...))
+		}
+	}
+
+	// 一轮全部失败视为断线，恢复后重新读取 once 点位
+	now := t0.Add(5200 * time.Millisecond)
+	classes, points := s.due(now)
+	s.done(now, classes, ok(nil, "cell1", "cell2"))
+	now = now.Add(200 * time.Millisecond)
+	if _, points = s.due(now); names(points) != "cell1,sn,cell2,fwVer" {
+		t.Errorf("after reconnect due = %s", names(points))
+	}
+}
+
+func TestScanPlanDefaults(t *testing.T) {
+	// 未配置扫描等级时与原来一致：全部点位按 interval_ms 采集
+	cfg := &DeviceConfig{Points: []PointConfig{{Name: "a"}, {Name: "b"}}}
+	batches, once := cfg.scanPlan()
+	if len(batches) != 1 || batches[0].class != ScanNormal || batches[0].periodMs != 1000 || len(batches[0].points) != 2 || once != nil {
+		t.Errorf("plan = %+v %v", batches, once)
+	}
+	cfg = &DeviceConfig{IntervalMs: 300, Points: []PointConfig{{Name: "sn", ScanClass: ScanOnce}, {Name: "v", ScanClass: ScanFast}}}
+	if batches, once = cfg.scanPlan(); len(batches) != 1 || batches[0].class != ScanFast || batches[0].periodMs != 200 || len(once) != 1 {
+		t.Errorf("plan = %+v %v", batches, once)
+	}
+}
+
+func TestCollectPointsMerged(t *testing.T) {
+	adapter := &multiAdapter{}
+	cfg := scanTestConfig()
+	dev := NewModbusDevice(*cfg, adapter)
+	s := newScanSchedule(&dev.Cfg)
+	classes, points := s.due(time.Now())
+	raw, err := dev.CollectPoints(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 多个到期等级的点位一次交给适配器打包
+	if adapter.calls != 1 || len(raw) != len(cfg.Points) || len(classes) != 3 {
+		t.Errorf("calls = %d, raw = %v, classes = %v", adapter.calls, raw, classes)
+	}
+}
+
+func TestCollectPointsModbusBlocks(t *testing.T) {
+	addr, requests := startModbusStub(t)
+	params := map[string]interface{}{"mode": "tcp", "address": addr, "slaveId": 1}
+	adapter, err := modbus.NewModbusAdapter(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := func(name string, address, qty int, class string) PointConfig {
+		return PointConfig{Name: name, DataType: "uint16", ScanClass: class,
+			Params: map[string]interface{}{"func": "hr", "address": float64(address), "quantity": float64(qty)}}
+	}
+	cfg := DeviceConfig{Name: "bms1", AdapterName: "modbus", Params: params, Points: []PointConfig{
+		pt("cell1", 0, 1, ScanFast), pt("cell2", 1, 1, ScanFast), pt("cell3", 2, 1, ScanFast),
+		pt("soc", 3, 1, ""), pt("sn", 10, 2, ScanOnce),
+	}}
+	cfg.Points[4].DataType = "uint32"
+	dev := NewModbusDevice(cfg, adapter)
+	defer dev.Close()
+	s := newScanSchedule(&dev.Cfg)
+
+	// 首轮 hr 0~3 合并为一次，sn 单独一次；之后只有 fast 到期，cell1~3 一次
+	t0 := time.Now()
+	for _, step := range []struct {
+		at   time.Duration
+		want int64
+	}{{0, 2}, {200 * time.Millisecond, 1}} {
+		requests.Store(0)
+		now := t0.Add(step.at)
+		classes, points := s.due(now)
+		raw, err := dev.CollectPoints(points)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, v := range raw {
+			if _, ok := v.(RawPoint); !ok {
+				t.Errorf("%v: %s = %v", step.at, name, v)
+			}
+		}
+		s.done(now, classes, raw)
+		if n := requests.Load(); n != step.want {
+			t.Errorf("%v: %d request(s) for %s, want %d", step.at, n, names(points), step.want)
+		}
+	}
+}
+
+func TestValidateScanClasses(t *testing.T) {
+	cfg := scanTestConfig()
+	cfg.Points[1].ScanClass = "hourly"
+	cfg.ScanClasses = map[string]int{ScanOnce: 10, "turbo": 50, ScanFast: 0}
+	cfg.ScanGroups = map[string][]string{ScanFast: {"cell*", "[bad"}, ScanSlow: {"cell1", "nothing*"}, "daily": {"sn"}}
+	r := ValidateDeviceConfigs("", []*DeviceConfig{cfg})
+	for _, want := range []struct{ severity, point, field, msg string }{
+		{"error", "soc(#2)", "scanClass", `unknown scan class "hourly"`},
+		{"error", "", "scanClasses.once", "has no period"},
+		{"error", "", "scanClasses.turbo", `unknown scan class "turbo"`},
+		{"error", "", "scanClasses.fast", "period 0 ms must be positive"},
+		{"error", "", "scanGroups.fast", `bad pattern "[bad"`},
+		{"warning", "", "scanGroups.slow", `pattern "nothing*" matches no point`},
+		{"error", "", "scanGroups.daily", `unknown scan class "daily"`},
+		{"warning", "cell1(#1)", "scanGroups", "point matches scan groups fast, slow, using fast"},
+	} {
+		if !hasIssue(r, want.severity, "bms1(#1)", want.point, want.field, want.msg) {
+			t.Errorf("missing %s %s %s: %s", want.severity, want.point, want.field, want.msg)
+		}
+	}
+}
+
+func TestValidateScanClassWireTime(t *testing.T) {
+	line := map[string]interface{}{"mode": "rtu", "address": "/dev/ttyS2", "baudrate": float64(9600), "slaveId": float64(1)}
+	var points []PointConfig
+	for i := 0; i < 20; i++ {
+		points = append(points, PointConfig{Name: fmt.Sprintf("cell%d", i), DataType: "uint16", Params: map[string]interface{}{"address": float64(i)}})
+	}
+	points = append(points, PointConfig{Name: "sn", DataType: "uint16", ScanClass: ScanOnce, Params: map[string]interface{}{"address": float64(100)}})
+	cfg := &DeviceConfig{Name: "bms1", AdapterName: "modbus", BusId: "b", IntervalMs: 1000, Params: line, Points: points,
+		ScanGroups: map[string][]string{ScanFast: {"cell*"}}, ScanClasses: map[string]int{ScanFast: 300}}
+	// 20次问答 × 22字符 × 10位 / 9600 ≈ 458ms，超过 fast 周期；once 点位不计入
+	r := ValidateDeviceConfigs("", []*DeviceConfig{cfg})
+	if !hasIssue(r, "error", "bms1(#1)", "", "scanClasses.fast", "300 ms is shorter than one poll on the wire (>= 458 ms for 20 point(s) at 9600 baud)") {
+		t.Errorf("issues = %v", r.Issues)
+	}
+	cfg.ScanClasses[ScanFast] = 500
+	if r := ValidateDeviceConfigs("", []*DeviceConfig{cfg}); len(r.Issues) != 0 {
+		t.Errorf("issues = %v", r.Issues)
+	}
+}
+
+func TestParseScanClassAliases(t *testing.T) {
+	cfgs, err := ParseConfig("devices.yaml", []byte(`
+templates:
+  - id: BMS
+    adapter: modbus
+    scan_classes: {fast: 100}
+    scan_groups: {once: [sn]}
+    points:
+      - {name: sn, func_code: hr}
+      - {name: v, scan_class: fast}
+devices:
+  - {name: bms1, template: BMS, scan_classes: {slow: 86400000}, points: [{name: v, scan_class: normal}]}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := cfgs[0]
+	var classes []string
+	for _, p := range d.Points {
+		classes = append(classes, p.Name+"="+d.scanClassOf(p))
+	}
+	sort.Strings(classes)
+	if strings.Join(classes, " ") != "sn=once v=normal" || d.ScanClasses[ScanFast] != 100 || d.ScanClasses[ScanSlow] != 86400000 {
+		t.Errorf("classes = %v, periods = %v", classes, d.ScanClasses)
+	}
+}
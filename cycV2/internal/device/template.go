@@ -0,0 +1,281 @@
+package device
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 设备模板（型号）：可复用的点表与协议参数，设备实例以 template 引用，模板以 extends 继承。
+// 配置文件为 {"templates": [...], "devices": [...]}，也兼容旧格式（设备数组）。
+//
+// 合并规则（基模板 → 派生模板 → 设备实例，逐层覆盖）：
+//   - 对象（如 params）逐键深度合并，值为 null 时删除该键
+//   - points 按 name 合并：同名点位逐字段覆盖，新点位追加在后；removePoints 列出要删除的点位
+//   - 其余字段（含数组）整体替换
+//
+// 例：
+//
+//	{"templates": [
+//	   {"id": "BMS-v1", "AdapterName": "modbus", "interval_ms": 1000, "params": {"timeout": 1000}, "points": [...]},
+//	   {"id": "BMS-v2", "extends": "BMS-v1", "points": [{"name": "soc", "regAddr": 40}], "removePoints": ["legacyFlag"]}],
+//	 "devices": [
+//	   {"name": "bms1", "template": "BMS-v2", "busId": "rs485-1", "slaveId": 1, "params": {"address": "/dev/ttyS1"}}]}
+type configFile struct {
+	Templates []map[string]interface{} `json:"templates"`
+	Devices   []map[string]interface{} `json:"devices"`
+}
+
+// 模板/实例专用键，不进入合并结果
+const (
+	keyTemplateID   = "id"
+	keyExtends      = "extends"
+	keyTemplate     = "template"
+	keyRemovePoints = "removePoints"
+)
+
+// LoadDeviceConfigs 读取配置文件（JSON/YAML/TOML），展开 include、环境变量与模板
+func LoadDeviceConfigs(path string) ([]*DeviceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(path, data)
+}
+
+// ParseDeviceConfigs 解析 JSON 配置内容并展开模板，返回按文件顺序的设备配置；不处理 include 与环境变量
+func ParseDeviceConfigs(data []byte) ([]*DeviceConfig, error) {
+	var file configFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := dec.Decode(&file.Devices); err != nil {
+			return nil, jsonPosError(data, err)
+		}
+	} else if err := dec.Decode(&file); err != nil {
+		return nil, jsonPosError(data, err)
+	}
+	return resolveConfigFile(file)
+}
+
+// jsonPosError 语法/类型错误补充行列号，便于定位
+func jsonPosError(data []byte, err error) error {
+	var off int64
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &se):
+		off = se.Offset
+	case errors.As(err, &te):
+		off = te.Offset
+	default:
+		return err
+	}
+	if off > int64(len(data)) {
+		off = int64(len(data))
+	}
+	head := data[:off]
+	line := bytes.Count(head, []byte("\n")) + 1
+	col := len(head) - bytes.LastIndexByte(head, '\n')
+	return fmt.Errorf("line %d col %d: %w", line, col, err)
+}
+
+func resolveConfigFile(file configFile) ([]*DeviceConfig, error) {
+	r := &templateResolver{raw: make(map[string]map[string]interface{}), done: make(map[string]map[string]interface{})}
+	for i, t := range file.Templates {
+		id, _ := t[keyTemplateID].(string)
+		if id == "" {
+			return nil, fmt.Errorf("template #%d: missing id", i+1)
+		}
+		if err := normalizeKeys(t); err != nil {
+			return nil, fmt.Errorf("template %q: %w", id, err)
+		}
+		if _, dup := r.raw[id]; dup {
+			return nil, fmt.Errorf("template %q: duplicate id", id)
+		}
+		r.raw[id] = t
+	}
+	out := make([]*DeviceConfig, 0, len(file.Devices))
+	for i, d := range file.Devices {
+		name, _ := d["name"].(string)
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if err := normalizeKeys(d); err != nil {
+			return nil, fmt.Errorf("device %s: %w", name, err)
+		}
+		var base map[string]interface{}
+		if id, _ := d[keyTemplate].(string); id != "" {
+			var err error
+			if base, err = r.resolve(id, nil); err != nil {
+				return nil, fmt.Errorf("device %s: %w", name, err)
+			}
+		}
+		cfg, err := decodeDeviceConfig(mergeConfig(base, d))
+		if err != nil {
+			return nil, fmt.Errorf("device %s: %w", name, err)
+		}
+		cfg.Template, _ = d[keyTemplate].(string)
+		out = append(out, cfg)
+	}
+	return out, nil
+}
+
+// templateResolver 展开继承链，缓存已展开的模板
+type templateResolver struct {
+	raw  map[string]map[string]interface{}
+	done map[string]map[string]interface{}
+}
+
+func (r *templateResolver) resolve(id string, chain []string) (map[string]interface{}, error) {
+	if t, ok := r.done[id]; ok {
+		return t, nil
+	}
+	for _, c := range chain {
+		if c == id {
+			return nil, fmt.Errorf("template inheritance cycle: %s -> %s", strings.Join(chain, " -> "), id)
+		}
+	}
+	t, ok := r.raw[id]
+	if !ok {
+		if len(chain) > 0 {
+			return nil, fmt.Errorf("template %q extends unknown template %q", chain[len(chain)-1], id)
+		}
+		return nil, fmt.Errorf("unknown template %q", id)
+	}
+	var base map[string]interface{}
+	if parent, _ := t[keyExtends].(string); parent != "" {
+		var err error
+		if base, err = r.resolve(parent, append(chain, id)); err != nil {
+			return nil, err
+		}
+	}
+	merged := mergeConfig(base, t)
+	r.done[id] = merged
+	return merged, nil
+}
+
+// mergeConfig 在 base 副本上叠加 over 一层，返回新对象，不修改输入
+func mergeConfig(base, over map[string]interface{}) map[string]interface{} {
+	out := deepCopy(base)
+	for k, v := range over {
+		switch k {
+		case keyTemplateID, keyExtends, keyTemplate, keyRemovePoints:
+			continue
+		case "points":
+			if pts, ok := v.([]interface{}); ok {
+				cur, _ := out[k].([]interface{})
+				out[k] = mergePoints(cur, pts)
+				continue
+			}
+		}
+		mergeValue(out, k, v)
+	}
+	if rm, ok := over[keyRemovePoints].([]interface{}); ok {
+		out["points"] = removePoints(out["points"], rm)
+	}
+	return out
+}
+
+func mergeValue(dst map[string]interface{}, k string, v interface{}) {
+	if v == nil {
+		delete(dst, k)
+		return
+	}
+	if vm, ok := v.(map[string]interface{}); ok {
+		cur, _ := dst[k].(map[string]interface{})
+		m := deepCopy(cur)
+		for kk, vv := range vm {
+			mergeValue(m, kk, vv)
+		}
+		dst[k] = m
+		return
+	}
+	dst[k] = deepCopyValue(v)
+}
+
+// mergePoints 与 base 同名的点位逐字段覆盖，新点位按出现顺序追加
+func mergePoints(base, over []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(base)+len(over))
+	index := make(map[string]int, len(base))
+	for _, p := range base {
+		if pm, ok := p.(map[string]interface{}); ok {
+			if name, _ := pm["name"].(string); name != "" {
+				index[name] = len(out)
+			}
+		}
+		out = append(out, deepCopyValue(p))
+	}
+	for _, p := range over {
+		pm, ok := p.(map[string]interface{})
+		name, _ := pm["name"].(string)
+		if i, exists := index[name]; ok && name != "" && exists {
+			cur, _ := out[i].(map[string]interface{})
+			for k, v := range pm {
+				mergeValue(cur, k, v)
+			}
+			continue
+		}
+		// 同层重名不合并，留给配置校验报告
+		out = append(out, deepCopyValue(p))
+	}
+	return out
+}
+
+func removePoints(points interface{}, names []interface{}) []interface{} {
+	drop := make(map[string]bool, len(names))
+	for _, n := range names {
+		if s, ok := n.(string); ok {
+			drop[s] = true
+		}
+	}
+	pts, _ := points.([]interface{})
+	out := make([]interface{}, 0, len(pts))
+	for _, p := range pts {
+		if pm, ok := p.(map[string]interface{}); ok {
+			if name, _ := pm["name"].(string); drop[name] {
+				continue
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func deepCopy(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = deepCopyValue(v)
+	}
+	return out
+}
+
+func deepCopyValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		return deepCopy(x)
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			out[i] = deepCopyValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+// decodeDeviceConfig 合并结果转为 DeviceConfig；经 JSON 往返，params 中的数值与直接解析时一样为 float64
+func decodeDeviceConfig(m map[string]interface{}) (*DeviceConfig, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var cfg DeviceConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
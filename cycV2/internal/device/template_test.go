@@ -0,0 +1,121 @@
+package device
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const templateConfig = `{
+  "templates": [
+    {"id": "BMS-v1", "AdapterName": "modbus", "interval_ms": 1000,
+     "params": {"mode": "rtu", "baudRate": 9600, "timeout": 1000},
+     "points": [
+       {"name": "soc", "funcCode": "hr", "regAddr": 10, "regNum": 1, "dataType": "uint16", "unit": "%"},
+       {"name": "voltage", "funcCode": "hr", "regAddr": 11, "regNum": 1, "dataType": "uint16", "params": {"scale": 0.1}},
+       {"name": "legacyFlag", "funcCode": "co", "regAddr": 1, "regNum": 1, "dataType": "bool"}
+     ]},
+    {"id": "BMS-v2", "extends": "BMS-v1",
+     "params": {"baudRate": 19200, "timeout": null},
+     "points": [
+       {"name": "soc", "regAddr": 40},
+       {"name": "soh", "funcCode": "hr", "regAddr": 41, "regNum": 1, "dataType": "uint16"}
+     ],
+     "removePoints": ["legacyFlag"]}
+  ],
+  "devices": [
+    {"name": "bms1", "template": "BMS-v2", "busId": "rs485-1", "slaveId": 1, "params": {"address": "/dev/ttyS1"}},
+    {"name": "bms2", "template": "BMS-v1", "busId": "rs485-1", "slaveId": 2, "interval_ms": 500,
+     "points": [{"name": "voltage", "params": {"scale": 0.01}}, {"name": "current", "funcCode": "hr", "regAddr": 12, "regNum": 1, "dataType": "int16"}]},
+    {"name": "meter", "AdapterName": "dlt645", "busId": "rs485-2", "points": [{"name": "energy", "params": {"di": "00010000"}}]}
+  ]
+}`
+
+func pointNames(cfg *DeviceConfig) string {
+	var names []string
+	for _, p := range cfg.Points {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestDeviceTemplates(t *testing.T) {
+	cfgs, err := ParseDeviceConfigs([]byte(templateConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 3 {
+		t.Fatalf("%d devices", len(cfgs))
+	}
+	bms1, bms2, meter := cfgs[0], cfgs[1], cfgs[2]
+
+	// 继承链：v2 覆盖 soc 地址、追加 soh、删除 legacyFlag，参数逐键合并
+	if bms1.AdapterName != "modbus" || bms1.IntervalMs != 1000 || bms1.SlaveId != 1 || bms1.BusId != "rs485-1" || bms1.Template != "BMS-v2" {
+		t.Errorf("bms1 = %+v", bms1)
+	}
+	if got := pointNames(bms1); got != "soc,voltage,soh" {
+		t.Errorf("bms1 points = %s", got)
+	}
+	if p := bms1.Points[0]; p.RegAddr != 40 || p.FuncCode != "hr" || p.Unit != "%" {
+		t.Errorf("bms1 soc = %+v", p)
+	}
+	if p := bms1.Params; p["baudRate"] != float64(19200) || p["mode"] != "rtu" || p["address"] != "/dev/ttyS1" {
+		t.Errorf("bms1 params = %v", p)
+	} else if _, ok := p["timeout"]; ok {
+		t.Error("null did not remove timeout")
+	}
+
+	// 实例覆盖：周期、点位参数深度合并、追加点位；不影响其他实例
+	if bms2.IntervalMs != 500 || pointNames(bms2) != "soc,voltage,legacyFlag,current" {
+		t.Errorf("bms2 = %d ms, points %s", bms2.IntervalMs, pointNames(bms2))
+	}
+	if v := bms2.Points[1]; v.Params["scale"] != 0.01 || v.RegAddr != 11 {
+		t.Errorf("bms2 voltage = %+v", v)
+	}
+	if bms2.Points[0].RegAddr != 10 || bms2.Params["baudRate"] != float64(9600) || bms2.Params["timeout"] != float64(1000) {
+		t.Errorf("bms2 inherited v2 overrides: %+v %v", bms2.Points[0], bms2.Params)
+	}
+	if meter.Template != "" || pointNames(meter) != "energy" || meter.Points[0].Params["di"] != "00010000" {
+		t.Errorf("meter = %+v", meter)
+	}
+
+	// 旧格式（设备数组）
+	legacy, err := ParseDeviceConfigs([]byte(`[{"name": "d1", "AdapterName": "modbus", "points": [{"name": "p"}]}]`))
+	if err != nil || len(legacy) != 1 || legacy[0].Name != "d1" || pointNames(legacy[0]) != "p" {
+		t.Errorf("legacy = %+v, %v", legacy, err)
+	}
+}
+
+func TestDeviceTemplateErrors(t *testing.T) {
+	for name, tc := range map[string]struct{ cfg, want string }{
+		"unknown":   {`{"devices": [{"name": "d", "template": "X"}]}`, `unknown template "X"`},
+		"parent":    {`{"templates": [{"id": "A", "extends": "B"}], "devices": [{"name": "d", "template": "A"}]}`, `extends unknown template "B"`},
+		"cycle":     {`{"templates": [{"id": "A", "extends": "B"}, {"id": "B", "extends": "A"}], "devices": [{"name": "d", "template": "A"}]}`, "cycle: A -> B -> A"},
+		"duplicate": {`{"templates": [{"id": "A"}, {"id": "A"}]}`, "duplicate"},
+		"no id":     {`{"templates": [{"extends": "A"}]}`, "missing id"},
+		"type":      {`{"templates": [{"id": "A", "slaveId": "x"}], "devices": [{"name": "d", "template": "A"}]}`, "device d"},
+	} {
+		_, err := ParseDeviceConfigs([]byte(tc.cfg))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: %v, want %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestLoadDevicesByBusWithTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	cfg := `{"templates": [{"id": "T", "AdapterName": "dlt645", "params": {"address": "127.0.0.1:1", "mode": "tcp"},
+	  "points": [{"name": "energy", "params": {"di": "00010000"}}]}],
+	 "devices": [{"name": "m1", "template": "T", "busId": "b"}, {"name": "m2", "template": "T", "busId": "b", "params": {"meterAddr": "2"}}]}`
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buses, err := loadDevicesByBus(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if devs := buses["b"]; len(devs) != 2 || devs[1].Cfg.Params["meterAddr"] != "2" || len(devs[0].Cfg.Points) != 1 {
+		t.Errorf("buses = %+v", buses)
+	}
+}
@@ -0,0 +1,759 @@
+package device
+
+import (
+	"cycV2/internal/protocol"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 配置校验：加载/热加载生效前检查整份配置，一次列出全部问题并定位到文件、总线、设备、点位。
+// 有 error 级问题时拒绝加载（旧配置继续运行），warning 只记录日志。
+//
+// 校验内容：
+//   - 设备名/点位名为空或重复
+//   - 适配器未注册、dataType 未知、rw/byteOrder 取值非法、scale 对该类型无效（告警）、告警上下限颠倒、常用参数类型错误
+//   - Modbus 点位：params.quantity（未配置时适配器读1个）与 dataType 长度不符、regNum/funcCode/regAddr 与实际读写参数不一致
+//   - 同一设备可写点位地址重叠
+//   - 同一 busId 的串口设备线路参数（端口、波特率、数据位、校验、停止位）不一致，同一串口被多条总线占用，站号重复
+//   - 扫描等级（scanClass/scanClasses/scanGroups）取值未知、周期非正、分组通配非法或未匹配任何点位
+//   - 采集周期（含各扫描等级）为负、非总线节拍整数倍，或按串口线路速率估算一轮采集耗时已超过周期
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue 一条校验结果
+type Issue struct {
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Bus      string `json:"bus,omitempty"`
+	Device   string `json:"device,omitempty"` // 设备名(#序号)
+	Point    string `json:"point,omitempty"`  // 点位名(#序号)
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	loc := make([]string, 0, 5)
+	if i.File != "" {
+		loc = append(loc, i.File)
+	}
+	if i.Bus != "" {
+		loc = append(loc, "bus "+i.Bus)
+	}
+	if i.Device != "" {
+		loc = append(loc, "device "+i.Device)
+	}
+	if i.Point != "" {
+		loc = append(loc, "point "+i.Point)
+	}
+	if i.Field != "" {
+		loc = append(loc, i.Field)
+	}
+	loc = append(loc, i.Message)
+	return i.Severity + ": " + strings.Join(loc, ": ")
+}
+
+// ValidationReport 校验报告；有 error 时作为加载错误返回
+type ValidationReport struct {
+	Issues []Issue `json:"issues"`
+}
+
+func (r *ValidationReport) HasErrors() bool {
+	for _, i := range r.Issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors error 级问题
+func (r *ValidationReport) Errors() []Issue { return r.filter(SeverityError) }
+
+// Warnings warning 级问题
+func (r *ValidationReport) Warnings() []Issue { return r.filter(SeverityWarning) }
+
+func (r *ValidationReport) filter(severity string) []Issue {
+	var out []Issue
+	for _, i := range r.Issues {
+		if i.Severity == severity {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (r *ValidationReport) Error() string {
+	errs := r.Errors()
+	lines := make([]string, 0, len(errs)+1)
+	lines = append(lines, fmt.Sprintf("invalid device config: %d error(s)", len(errs)))
+	for _, i := range errs {
+		lines = append(lines, "  "+i.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// issueCtx 当前校验位置
+type issueCtx struct {
+	r                        *ValidationReport
+	file, bus, device, point string
+}
+
+func (c issueCtx) add(severity, field, format string, args ...interface{}) {
+	c.r.Issues = append(c.r.Issues, Issue{Severity: severity, File: c.file, Bus: c.bus, Device: c.device, Point: c.point,
+		Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c issueCtx) errorf(field, format string, args ...interface{}) {
+	c.add(SeverityError, field, format, args...)
+}
+
+func (c issueCtx) warnf(field, format string, args ...interface{}) {
+	c.add(SeverityWarning, field, format, args...)
+}
+
+func label(name string, i int) string {
+	if name == "" {
+		return fmt.Sprintf("#%d", i+1)
+	}
+	return fmt.Sprintf("%s(#%d)", name, i+1)
+}
+
+// ValidateConfigFile 读取配置文件（展开模板与DBC点位）并校验；解析失败时返回 nil 配置与对应问题
+func ValidateConfigFile(path string) ([]*DeviceConfig, *ValidationReport) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		r := &ValidationReport{}
+		issueCtx{r: r, file: filepath.Base(path)}.errorf("", "%v", err)
+		return nil, r
+	}
+	return ValidateConfigData(path, data)
+}
+
+// ValidateConfigData 校验配置内容，path 决定格式（按扩展名），并用于定位与解析相对路径的 include、DBC文件
+func ValidateConfigData(path string, data []byte) ([]*DeviceConfig, *ValidationReport) {
+	cfgs, _, r := validateConfigData(path, data, nil)
+	return cfgs, r
+}
+
+// validateConfigData 同时返回读取过的文件，供文件监听与版本记录使用；snapshot 见 parseConfigSources。
+// 设备的问题定位到其所在文件（include 引入的文件显示为相对主文件目录的路径），总线级问题定位到主文件
+func validateConfigData(path string, data []byte, snapshot map[string][]byte) ([]*DeviceConfig, *configSources, *ValidationReport) {
+	file := filepath.Base(path)
+	r := &ValidationReport{}
+	cfgs, sources, err := parseConfigSources(path, data, snapshot)
+	if err != nil {
+		issueCtx{r: r, file: file}.errorf("", "%v", err)
+		return nil, nil, r
+	}
+	files := make([]string, len(cfgs))
+	dir, _ := filepath.Abs(filepath.Dir(path))
+	for i, src := range sources.devices {
+		files[i] = file
+		if rel, err := filepath.Rel(dir, src); err == nil && rel != file {
+			files[i] = filepath.ToSlash(rel)
+		}
+	}
+	for i, cfg := range cfgs {
+		if cfg.DBCFile == "" {
+			continue
+		}
+		// 相对路径相对于声明该设备的文件（include 的文件可能在子目录）
+		if err := expandDBCPoints(cfg, filepath.Dir(sources.devices[i])); err != nil {
+			issueCtx{r: r, file: files[i], bus: cfg.BusId, device: label(cfg.Name, i)}.errorf("dbcFile", "%v", err)
+		}
+	}
+	r.Issues = append(r.Issues, validateDeviceConfigs(file, files, cfgs).Issues...)
+	return cfgs, sources, r
+}
+
+// ValidateDeviceConfigs 校验已展开的设备配置，file 仅用于定位
+func ValidateDeviceConfigs(file string, cfgs []*DeviceConfig) *ValidationReport {
+	files := make([]string, len(cfgs))
+	for i := range files {
+		files[i] = file
+	}
+	return validateDeviceConfigs(file, files, cfgs)
+}
+
+// validateDeviceConfigs files 为各设备所在文件，file 用于总线级问题
+func validateDeviceConfigs(file string, files []string, cfgs []*DeviceConfig) *ValidationReport {
+	r := &ValidationReport{}
+	names := make(map[string]string, len(cfgs))
+	for i, cfg := range cfgs {
+		c := issueCtx{r: r, file: files[i], bus: cfg.BusId, device: label(cfg.Name, i)}
+		if cfg.Name == "" {
+			c.errorf("name", "device name is empty")
+		} else if first, dup := names[cfg.Name]; dup {
+			c.errorf("name", "duplicate device name, first defined as device %s", first)
+		} else {
+			names[cfg.Name] = c.device
+		}
+		validateDevice(c, cfg)
+	}
+	validateBuses(r, file, files, cfgs)
+	return r
+}
+
+// parseRaw 支持的数据类型；空与 raw 为原始字节
+var knownDataTypes = map[string]int{
+	"": 0, "raw": 0, "string": 0, "bool": 0, "dbc": 0, "spn": 0, "dlt645": 0,
+	"uint8": 1, "int8": 1, "int16": 2, "uint16": 2,
+	"int32": 4, "uint32": 4, "float32": 4, "int64": 8, "uint64": 8, "float64": 8,
+}
+
+func dataTypeNames() string {
+	var names []string
+	for k := range knownDataTypes {
+		if k != "" {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// 各适配器共用的设备参数类型：字符串被断言失败时会静默取默认值
+var (
+	stringParams = []string{"mode", "address", "parity"}
+	numberParams = []string{"timeoutMs", "retries", "slaveId", "baudRate", "baudrate", "dataBits", "databits", "stopBits", "stopbits"}
+)
+
+func validateDevice(c issueCtx, cfg *DeviceConfig) {
+	switch {
+	case cfg.AdapterName == "":
+		c.errorf("AdapterName", "adapter is not set (registered: %s)", strings.Join(protocol.Adapters(), ", "))
+	case !protocol.Registered(cfg.AdapterName):
+		hint := ""
+		for _, n := range protocol.Adapters() {
+			if strings.EqualFold(n, cfg.AdapterName) {
+				hint = fmt.Sprintf(", did you mean %q?", n)
+			}
+		}
+		c.errorf("AdapterName", "unknown adapter %q (registered: %s)%s", cfg.AdapterName, strings.Join(protocol.Adapters(), ", "), hint)
+	}
+	if cfg.IntervalMs < 0 {
+		c.errorf("interval_ms", "interval %d ms is negative", cfg.IntervalMs)
+	}
+	validateScanClasses(c, cfg)
+	for _, k := range stringParams {
+		if v, ok := cfg.Params[k]; ok {
+			if _, isStr := v.(string); !isStr {
+				c.errorf("params."+k, "must be a string, got %v", v)
+			}
+		}
+	}
+	for _, k := range numberParams {
+		if v, ok := cfg.Params[k]; ok {
+			if _, isNum := toInt(v); !isNum {
+				c.errorf("params."+k, "must be a number, got %q", fmt.Sprint(v))
+			}
+		}
+	}
+	if _, ok := serialLineOf(cfg); ok {
+		for _, right := range serialKeys[cfg.AdapterName] {
+			for k := range cfg.Params {
+				if k != right && strings.EqualFold(k, right) {
+					c.warnf("params."+k, "%s ignores %q, use %q", cfg.AdapterName, k, right)
+				}
+			}
+		}
+	}
+	if cfg.AdapterName == "modbus" {
+		validateModbusDevice(c, cfg)
+	}
+
+	names := make(map[string]string, len(cfg.Points))
+	var writes []writeRange
+	for i := range cfg.Points {
+		pt := &cfg.Points[i]
+		pc := c
+		pc.point = label(pt.Name, i)
+		if pt.Name == "" {
+			pc.errorf("name", "point name is empty")
+		} else if first, dup := names[pt.Name]; dup {
+			pc.errorf("name", "duplicate point name, first defined as point %s", first)
+		} else {
+			names[pt.Name] = pc.point
+		}
+		if _, ok := knownDataTypes[pt.DataType]; !ok {
+			pc.errorf("dataType", "unknown data type %q (supported: %s)", pt.DataType, dataTypeNames())
+		}
+		switch pt.Rw {
+		case "", "r", "w", "rw":
+		default:
+			pc.errorf("rw", "must be r, w or rw, got %q", pt.Rw)
+		}
+		switch pt.ByteOrder {
+		case "", "big", "little":
+		default:
+			pc.warnf("byteOrder", "%q is treated as big, use big or little", pt.ByteOrder)
+		}
+		if pt.scaled() && knownDataTypes[pt.DataType] == 0 && pt.DataType != "dbc" && pt.DataType != "spn" && pt.DataType != "dlt645" {
+			pc.warnf("scale", "scale %g has no effect on data type %q", pt.Scale, pt.DataType)
+		}
+		if pt.ScanClass != "" && !knownScanClass(pt.ScanClass) {
+			pc.errorf("scanClass", "unknown scan class %q (supported: fast, normal, slow, once)", pt.ScanClass)
+		} else if pt.ScanClass == "" {
+			if classes := matchedScanGroups(cfg, pt.Name); len(classes) > 1 {
+				pc.warnf("scanGroups", "point matches scan groups %s, using %s", strings.Join(classes, ", "), classes[0])
+			}
+		}
+		if a := pt.Alarm; a != nil && a.HighLimit != nil && a.LowLimit != nil && *a.HighLimit <= *a.LowLimit {
+			pc.errorf("alarm", "highLimit %g must be greater than lowLimit %g", *a.HighLimit, *a.LowLimit)
+		}
+		params := mergeParams(cfg.Params, pt.Params)
+		var w *writeRange
+		if cfg.AdapterName == "modbus" {
+			w = validateModbusPoint(pc, pt, params)
+		} else if pt.writable() {
+			if addr, ok := pt.Params["address"]; ok {
+				key := fmt.Sprint(addr)
+				w = &writeRange{table: key}
+			}
+		}
+		if w == nil {
+			continue
+		}
+		w.point = pc.point
+		for _, o := range writes {
+			if o.overlaps(*w) {
+				pc.errorf("params.address", "write address %s overlaps point %s (%s)", w, o.point, o)
+			}
+		}
+		writes = append(writes, *w)
+	}
+}
+
+// validateScanClasses 扫描等级周期与分组
+func validateScanClasses(c issueCtx, cfg *DeviceConfig) {
+	for _, class := range sortedKeys(cfg.ScanClasses) {
+		switch ms := cfg.ScanClasses[class]; {
+		case class == ScanOnce:
+			c.errorf("scanClasses."+class, "once is read at connect and has no period")
+		case !knownScanClass(class):
+			c.errorf("scanClasses."+class, "unknown scan class %q (supported: fast, normal, slow)", class)
+		case ms <= 0:
+			c.errorf("scanClasses."+class, "period %d ms must be positive", ms)
+		}
+	}
+	for _, class := range sortedKeys(cfg.ScanGroups) {
+		if !knownScanClass(class) {
+			c.errorf("scanGroups."+class, "unknown scan class %q (supported: fast, normal, slow, once)", class)
+			continue
+		}
+		for _, pattern := range cfg.ScanGroups[class] {
+			if _, err := path.Match(pattern, ""); err != nil {
+				c.errorf("scanGroups."+class, "bad pattern %q: %v", pattern, err)
+				continue
+			}
+			matched := false
+			for _, pt := range cfg.Points {
+				if ok, _ := path.Match(pattern, pt.Name); ok {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				c.warnf("scanGroups."+class, "pattern %q matches no point", pattern)
+			}
+		}
+	}
+}
+
+// matchedScanGroups 点位名匹配的分组等级，顺序与 scanClassOf 的优先级一致
+func matchedScanGroups(cfg *DeviceConfig, name string) []string {
+	var out []string
+	for _, class := range append(periodicScanClasses, ScanOnce) {
+		for _, pattern := range cfg.ScanGroups[class] {
+			if ok, _ := path.Match(pattern, name); ok {
+				out = append(out, class)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeRange 可写点位占用的地址；非 Modbus 适配器只比较 params.address 是否相同
+type writeRange struct {
+	table      string
+	start, end int // [start, end)，Modbus 寄存器/线圈地址
+	point      string
+}
+
+func (w writeRange) overlaps(o writeRange) bool {
+	return w.table == o.table && w.start < o.end && o.start < w.end || w.table == o.table && w.end == 0
+}
+
+func (w writeRange) String() string {
+	if w.end == 0 {
+		return w.table
+	}
+	if w.end-w.start == 1 {
+		return fmt.Sprintf("%s:%d", w.table, w.start)
+	}
+	return fmt.Sprintf("%s:%d-%d", w.table, w.start, w.end-1)
+}
+
+func validateModbusDevice(c issueCtx, cfg *DeviceConfig) {
+	mode, _ := cfg.Params["mode"].(string)
+	if mode != "tcp" && mode != "rtu" {
+		c.errorf("params.mode", "modbus mode must be tcp or rtu, got %q", mode)
+	}
+	if addr, _ := cfg.Params["address"].(string); addr == "" {
+		c.errorf("params.address", "modbus %s address is empty", mode)
+	}
+	slave, set := modbusSlave(cfg)
+	if raw, ok := cfg.Params["slaveId"]; ok {
+		if _, num := raw.(float64); !num {
+			if _, num = raw.(int); !num {
+				c.errorf("params.slaveId", "must be a JSON number, got %q (adapter falls back to 1)", fmt.Sprint(raw))
+			}
+		}
+		if n, _ := toInt(raw); mode == "rtu" && (n < 1 || n > 247) {
+			c.errorf("params.slaveId", "slave id %d out of range 1..247", n)
+		}
+	}
+	if cfg.SlaveId != 0 && (!set || int(cfg.SlaveId) != slave) {
+		c.warnf("slaveId", "slaveId %d is not passed to the adapter, it polls params.slaveId=%d", cfg.SlaveId, slave)
+	}
+}
+
+// modbusSlave 适配器实际使用的站号（params.slaveId，缺省1）
+func modbusSlave(cfg *DeviceConfig) (int, bool) {
+	switch v := cfg.Params["slaveId"].(type) {
+	case float64:
+		return int(uint8(v)), true
+	case int:
+		return int(uint8(v)), true
+	}
+	return 1, false
+}
+
+// modbusNumber 点位整数参数：适配器只接受 JSON 数值，其他类型静默取默认值
+func modbusNumber(c issueCtx, params map[string]interface{}, key string, def, lo, hi int) (int, bool) {
+	raw, ok := params[key]
+	if !ok {
+		return def, false
+	}
+	var n int
+	switch v := raw.(type) {
+	case float64:
+		if v != float64(int(v)) {
+			c.errorf("params."+key, "must be an integer, got %v", v)
+		}
+		n = int(v)
+	case int:
+		n = v
+	default:
+		c.errorf("params."+key, "must be a JSON number, got %q (adapter falls back to %d)", fmt.Sprint(raw), def)
+		return def, true
+	}
+	if n < lo || n > hi {
+		c.errorf("params."+key, "%d out of range %d..%d", n, lo, hi)
+	}
+	return n, true
+}
+
+func validateModbusPoint(c issueCtx, pt *PointConfig, params map[string]interface{}) *writeRange {
+	fn := "hr"
+	if raw, ok := params["func"]; ok {
+		fn, _ = raw.(string)
+		switch fn {
+		case "hr", "ir", "co", "di":
+		default:
+			c.errorf("params.func", "must be hr, ir, co or di, got %q", fmt.Sprint(raw))
+			return nil
+		}
+	}
+	if pt.FuncCode != "" && pt.FuncCode != fn {
+		c.warnf("funcCode", "funcCode %q is informational, the adapter uses params.func=%q", pt.FuncCode, fn)
+	}
+	bits := fn == "co" || fn == "di"
+	maxQty := 125
+	if bits {
+		maxQty = 2000
+	}
+	// 设备级 address 为串口/IP，点位未配置时适配器读地址0
+	addr, addrSet := modbusNumber(c, pt.Params, "address", 0, 0, 65535)
+	qty, qtySet := modbusNumber(c, params, "quantity", 1, 1, maxQty)
+	switch {
+	case !addrSet && pt.RegAddr != 0:
+		c.errorf("params.address", "not set, the adapter reads address 0 (regAddr %d is informational)", pt.RegAddr)
+	case pt.RegAddr != 0 && int(pt.RegAddr) != addr:
+		c.warnf("regAddr", "regAddr %d differs from params.address %d used by the adapter", pt.RegAddr, addr)
+	}
+	switch {
+	case !qtySet && pt.RegNum > 1:
+		c.errorf("params.quantity", "not set, the adapter reads 1 (regNum %d is informational)", pt.RegNum)
+	case pt.RegNum != 0 && int(pt.RegNum) != qty:
+		c.warnf("regNum", "regNum %d differs from params.quantity %d used by the adapter", pt.RegNum, qty)
+	}
+
+	size := knownDataTypes[pt.DataType]
+	switch pt.DataType {
+	case "dbc", "spn", "dlt645":
+		c.errorf("dataType", "%s is not a modbus data type", pt.DataType)
+	}
+	n := 2 * qty
+	if bits {
+		n = (qty + 7) / 8
+	}
+	if size > 0 && n != size {
+		switch {
+		case bits:
+			c.errorf("dataType", "%s needs %d byte(s) but %s quantity %d returns %d", pt.DataType, size, fn, qty, n)
+		case size%2 != 0:
+			c.errorf("dataType", "%s cannot be read from %s registers (2 bytes each), use int16/uint16", pt.DataType, fn)
+		default:
+			c.errorf("params.quantity", "%s needs %d register(s), quantity is %d", pt.DataType, size/2, qty)
+		}
+	}
+
+	if !pt.writable() {
+		return nil
+	}
+	if fn != "hr" && fn != "co" {
+		c.errorf("rw", "%s is read-only, writable points need func hr or co", fn)
+		return nil
+	}
+	return &writeRange{table: fn, start: addr, end: addr + qty}
+}
+
+// serialLine 串口线路参数（按各适配器的默认值与参数名取实际生效值）
+type serialLine struct {
+	port                     string
+	baud, dataBits, stopBits int
+	parity                   string
+}
+
+func (l serialLine) String() string {
+	return fmt.Sprintf("%d %d%s%d", l.baud, l.dataBits, strings.ToUpper(l.parity), l.stopBits)
+}
+
+// charBits 每字符位数：起始位+数据位+校验位+停止位
+func (l serialLine) charBits() int {
+	n := 1 + l.dataBits + l.stopBits
+	if p := strings.ToUpper(l.parity); p != "N" && p != "" {
+		n++
+	}
+	return n
+}
+
+// serialKeys 各适配器识别的波特率/数据位/停止位参数名
+var serialKeys = map[string][3]string{
+	"modbus": {"baudrate", "databits", "stopbits"},
+	"dlt645": {"baudRate", "dataBits", "stopBits"},
+	"framed": {"baudRate", "dataBits", "stopBits"},
+}
+
+// serialLineOf 串口设备的线路参数；TCP 等非串口设备返回 false
+func serialLineOf(cfg *DeviceConfig) (serialLine, bool) {
+	mode, _ := cfg.Params["mode"].(string)
+	var l serialLine
+	switch cfg.AdapterName {
+	case "modbus":
+		if mode != "rtu" {
+			return l, false
+		}
+		l = serialLine{baud: 9600, dataBits: 8, stopBits: 1, parity: "N"}
+	case "dlt645":
+		if mode != "" && mode != "serial" && mode != "rtu" {
+			return l, false
+		}
+		l = serialLine{baud: 2400, dataBits: 8, stopBits: 1, parity: "E"}
+	case "framed":
+		if mode != "" && mode != "serial" && mode != "rtu" {
+			return l, false
+		}
+		l = serialLine{baud: 9600, dataBits: 8, stopBits: 1, parity: "N"}
+	default:
+		return l, false
+	}
+	keys := serialKeys[cfg.AdapterName]
+	l.port, _ = cfg.Params["address"].(string)
+	for i, dst := range []*int{&l.baud, &l.dataBits, &l.stopBits} {
+		if v, ok := toInt(cfg.Params[keys[i]]); ok && v > 0 {
+			*dst = v
+		}
+	}
+	if v, _ := cfg.Params["parity"].(string); v != "" {
+		l.parity = v
+	}
+	return l, true
+}
+
+// 每次问答的最少字符数（含帧间隔），用于估算串口一轮采集耗时下限
+const (
+	modbusRTUOverhead = 8 + 5 + 7 // 请求8字节 + 应答头尾5字节 + 两次3.5字符帧间隔
+	dlt645Exchange    = 20 + 24   // 请求(含4字节前导) + 最短应答
+)
+
+// pollWireMs 按线路速率估算设备一轮采集的最短耗时，无法估算时返回0
+func pollWireMs(cfg *DeviceConfig, points []PointConfig, l serialLine) float64 {
+	chars := 0
+	for _, pt := range points {
+		switch cfg.AdapterName {
+		case "modbus":
+			params := mergeParams(cfg.Params, pt.Params)
+			fn, _ := params["func"].(string)
+			qty, ok := toInt(params["quantity"])
+			if !ok || qty < 1 {
+				qty = 1
+			}
+			n := 2 * qty
+			if fn == "co" || fn == "di" {
+				n = (qty + 7) / 8
+			}
+			chars += modbusRTUOverhead + n
+		case "dlt645":
+			chars += dlt645Exchange
+		}
+	}
+	return float64(chars*l.charBits()) * 1000 / float64(l.baud)
+}
+
+func validateBuses(r *ValidationReport, file string, files []string, cfgs []*DeviceConfig) {
+	var busIDs []string
+	buses := make(map[string][]int)
+	for i, cfg := range cfgs {
+		if _, ok := buses[cfg.BusId]; !ok {
+			busIDs = append(busIDs, cfg.BusId)
+		}
+		buses[cfg.BusId] = append(buses[cfg.BusId], i)
+	}
+	portBus := make(map[string]string) // 串口 -> 首个使用的总线
+	for _, busID := range busIDs {
+		members := buses[busID]
+		type serialDev struct {
+			i    int
+			line serialLine
+		}
+		var serials []serialDev
+		for _, i := range members {
+			if l, ok := serialLineOf(cfgs[i]); ok {
+				serials = append(serials, serialDev{i, l})
+			}
+		}
+
+		// 线路参数一致性与串口占用
+		slaves := make(map[string]string)
+		for k, s := range serials {
+			cfg := cfgs[s.i]
+			c := issueCtx{r: r, file: files[s.i], bus: busID, device: label(cfg.Name, s.i)}
+			if s.line.port == "" {
+				continue
+			}
+			first := serials[0]
+			for _, f := range serials[:k] {
+				if f.line.port == s.line.port {
+					first = f
+					break
+				}
+			}
+			if first.line.port != s.line.port {
+				c.warnf("params.address", "serial port %s differs from %s used by device %s on the same bus; devices on different ports are still polled one after another",
+					s.line.port, first.line.port, label(cfgs[first.i].Name, first.i))
+			} else if first.i != s.i && first.line.String() != s.line.String() {
+				c.errorf("params", "line settings %s on %s differ from %s used by device %s on the same bus",
+					s.line, s.line.port, first.line, label(cfgs[first.i].Name, first.i))
+			}
+			if other, used := portBus[s.line.port]; used && other != busID {
+				c.errorf("busId", "serial port %s is also used by bus %q, two buses would poll it concurrently", s.line.port, other)
+			} else if !used {
+				portBus[s.line.port] = busID
+			}
+			if cfg.AdapterName == "modbus" {
+				slave, _ := modbusSlave(cfg)
+				key := fmt.Sprintf("%s/%d", s.line.port, slave)
+				if other, dup := slaves[key]; dup {
+					c.errorf("params.slaveId", "slave id %d on %s is also used by device %s", slave, s.line.port, other)
+				} else {
+					slaves[key] = c.device
+				}
+			}
+		}
+
+		// 采集周期：总线以最短周期（含扫描等级）为节拍顺序轮询各设备
+		plans := make(map[int][]scanBatch, len(members))
+		tick := 0
+		for _, i := range members {
+			plans[i], _ = cfgs[i].scanPlan()
+			for _, b := range plans[i] {
+				if tick == 0 || b.periodMs < tick {
+					tick = b.periodMs
+				}
+			}
+		}
+		lines := make(map[int]serialLine)
+		for _, s := range serials {
+			lines[s.i] = s.line
+		}
+		var load, round float64
+		for _, i := range members {
+			cfg := cfgs[i]
+			c := issueCtx{r: r, file: files[i], bus: busID, device: label(cfg.Name, i)}
+			for _, b := range plans[i] {
+				field, iv := "interval_ms", b.periodMs
+				if b.class != ScanNormal || cfg.ScanClasses[ScanNormal] > 0 {
+					field = "scanClasses." + b.class
+				}
+				if iv%tick != 0 {
+					c.warnf(field, "%d ms is not a multiple of the bus tick %d ms (fastest device), effective interval is %d ms",
+						iv, tick, (iv+tick-1)/tick*tick)
+				}
+				l, serial := lines[i]
+				if !serial {
+					continue
+				}
+				w := pollWireMs(cfg, b.points, l)
+				if w > float64(iv) {
+					c.errorf(field, "%d ms is shorter than one poll on the wire (>= %.0f ms for %d point(s) at %d baud)",
+						iv, w, len(b.points), l.baud)
+				}
+				load += w / float64(iv)
+				round += w
+			}
+		}
+		c := issueCtx{r: r, file: file, bus: busID}
+		if load > 1 {
+			c.errorf("interval_ms", "serial bus is overloaded: polls need >= %.0f%% of the line time at the configured intervals", load*100)
+		} else if round > float64(tick) {
+			c.warnf("interval_ms", "one full round needs >= %.0f ms on the wire, longer than the %d ms tick; faster devices will lag", round, tick)
+		}
+	}
+}
+
+// toInt 兼容 int/float64/字符串配置
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,170 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const invalidConfig = `{"devices": [
+  {"name": "bms1", "AdapterName": "modbus", "busId": "rs485-1", "interval_ms": 1000,
+   "params": {"mode": "rtu", "address": "/dev/ttyS1", "baudrate": 9600, "slaveId": 1},
+   "points": [
+     {"name": "soc", "dataType": "uint16", "params": {"address": 10}},
+     {"name": "power", "regAddr": 20, "regNum": 2, "dataType": "float32"},
+     {"name": "soc", "dataType": "uint16", "params": {"address": 11}},
+     {"name": "mode", "dataType": "uint8", "params": {"address": 12}},
+     {"name": "limit", "dataType": "int32", "rw": "rw", "params": {"address": 100, "quantity": 2}},
+     {"name": "limitLo", "dataType": "uint16", "rw": "w", "params": {"address": 101}},
+     {"name": "temp", "dataType": "float", "params": {"address": 30, "quantity": 2}},
+     {"name": "alarm", "dataType": "bool", "rw": "w", "params": {"func": "di", "address": 5}}
+   ]},
+  {"name": "bms2", "AdapterName": "modbus", "busId": "rs485-1",
+   "params": {"mode": "rtu", "address": "/dev/ttyS1", "baudrate": 19200, "slaveId": 1},
+   "points": [{"name": "soc", "dataType": "uint16", "params": {"address": 10}}]},
+  {"name": "bms1", "AdapterName": "Modbus", "busId": "tcp-1", "params": {"mode": "tcp", "address": "10.0.0.1:502"}},
+  {"name": "meter", "AdapterName": "dlt645", "busId": "rs485-2", "params": {"address": "/dev/ttyS1", "baudrate": 2400},
+   "points": [{"name": "energy", "dataType": "dlt645", "params": {"di": "00010000"}}]}
+]}`
+
+func hasIssue(r *ValidationReport, severity, device, point, field, msg string) bool {
+	for _, i := range r.Issues {
+		if i.Severity == severity && i.Device == device && i.Point == point && i.Field == field && strings.Contains(i.Message, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateDeviceConfigs(t *testing.T) {
+	cfgs, err := ParseDeviceConfigs([]byte(invalidConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := ValidateDeviceConfigs("devices.json", cfgs)
+	for _, want := range []struct{ severity, device, point, field, msg string }{
+		{"error", "bms1(#1)", "power(#2)", "params.address", "regAddr 20 is informational"},
+		{"error", "bms1(#1)", "power(#2)", "params.quantity", "regNum 2 is informational"},
+		{"error", "bms1(#1)", "power(#2)", "params.quantity", "float32 needs 2 register(s), quantity is 1"},
+		{"error", "bms1(#1)", "soc(#3)", "name", "first defined as point soc(#1)"},
+		{"error", "bms1(#1)", "mode(#4)", "dataType", "uint8 cannot be read from hr registers"},
+		{"error", "bms1(#1)", "limitLo(#6)", "params.address", "hr:101 overlaps point limit(#5) (hr:100-101)"},
+		{"error", "bms1(#1)", "temp(#7)", "dataType", `unknown data type "float"`},
+		{"error", "bms1(#1)", "alarm(#8)", "rw", "di is read-only"},
+		{"error", "bms2(#2)", "", "params", "line settings 19200 8N1 on /dev/ttyS1 differ from 9600 8N1 used by device bms1(#1)"},
+		{"error", "bms2(#2)", "", "params.slaveId", "slave id 1 on /dev/ttyS1 is also used by device bms1(#1)"},
+		{"error", "bms1(#3)", "", "name", "first defined as device bms1(#1)"},
+		{"error", "bms1(#3)", "", "AdapterName", `did you mean "modbus"?`},
+		{"error", "meter(#4)", "", "busId", `serial port /dev/ttyS1 is also used by bus "rs485-1"`},
+		{"warning", "meter(#4)", "", "params.baudrate", `dlt645 ignores "baudrate", use "baudRate"`},
+	} {
+		if !hasIssue(r, want.severity, want.device, want.point, want.field, want.msg) {
+			t.Errorf("missing %s %s/%s %s: %s", want.severity, want.device, want.point, want.field, want.msg)
+		}
+	}
+	if hasIssue(r, "error", "bms1(#1)", "soc(#1)", "", "") || hasIssue(r, "error", "meter(#4)", "energy(#1)", "", "") {
+		t.Error("valid points reported")
+	}
+	if !r.HasErrors() || len(r.Warnings()) != 1 {
+		for _, i := range r.Issues {
+			t.Log(i)
+		}
+		t.Errorf("%d warnings", len(r.Warnings()))
+	}
+	if s := r.Issues[0].String(); !strings.HasPrefix(s, "error: devices.json: bus rs485-1: device bms1(#1): point ") {
+		t.Errorf("issue string = %s", s)
+	}
+}
+
+func TestValidateBusIntervals(t *testing.T) {
+	line := map[string]interface{}{"mode": "rtu", "address": "/dev/ttyS2", "baudrate": float64(9600), "slaveId": float64(1)}
+	var points []PointConfig
+	for i := 0; i < 50; i++ {
+		points = append(points, PointConfig{Name: fmt.Sprintf("p%d", i), DataType: "uint16", Params: map[string]interface{}{"address": float64(i)}})
+	}
+	slow := map[string]interface{}{}
+	for k, v := range line {
+		slow[k] = v
+	}
+	slow["slaveId"] = float64(2)
+	cfgs := []*DeviceConfig{
+		// 50次问答 × 22字符 × 10位 / 9600 ≈ 1146ms，超过1秒周期
+		{Name: "fast", AdapterName: "modbus", BusId: "b", IntervalMs: 1000, Params: line, Points: points},
+		{Name: "slow", AdapterName: "modbus", BusId: "b", IntervalMs: 1500, Params: slow, Points: points[:1]},
+		{Name: "neg", AdapterName: "modbus", BusId: "c", IntervalMs: -1, Params: map[string]interface{}{"mode": "tcp", "address": "x:502"}},
+	}
+	r := ValidateDeviceConfigs("", cfgs)
+	for _, want := range []struct{ severity, device, field, msg string }{
+		{"error", "fast(#1)", "interval_ms", "shorter than one poll on the wire (>= 1146 ms for 50 point(s) at 9600 baud)"},
+		{"warning", "slow(#2)", "interval_ms", "not a multiple of the bus tick 1000 ms (fastest device), effective interval is 2000 ms"},
+		{"error", "", "interval_ms", "serial bus is overloaded"},
+		{"error", "neg(#3)", "interval_ms", "negative"},
+	} {
+		if !hasIssue(r, want.severity, want.device, "", want.field, want.msg) {
+			t.Errorf("missing %s %s: %s", want.severity, want.device, want.msg)
+		}
+	}
+
+	// 放宽周期后不再报超限
+	cfgs[0].IntervalMs, cfgs[1].IntervalMs = 2000, 4000
+	cfgs = cfgs[:2]
+	if r := ValidateDeviceConfigs("", cfgs); len(r.Issues) != 0 {
+		t.Errorf("issues = %v", r.Issues)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	if err := os.WriteFile(path, []byte(invalidConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(path)
+	var report *ValidationReport
+	if err := m.ReloadFromFile(); !errors.As(err, &report) || !strings.Contains(err.Error(), "devices.json: bus rs485-1: device bms2(#2)") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(m.BusDevices()) != 0 {
+		t.Error("invalid config applied")
+	}
+
+	if err := os.WriteFile(path, []byte("{\"devices\": [\n  {\"name\": \"d\",}\n]}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ReloadFromFile(); err == nil || !strings.Contains(err.Error(), "devices.json: line 2 col 17") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestValidateIncludedFileLocation(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "site"), 0o755)
+	writeConfig(t, filepath.Join(dir, "site", "a.yaml"), "{name: bad, AdapterName: nope, busId: A}\n")
+	writeConfig(t, filepath.Join(dir, "main.yaml"), "include: site\ndevices:\n  - {name: local, AdapterName: nope2, busId: B}\n")
+	_, r := ValidateConfigFile(filepath.Join(dir, "main.yaml"))
+	got := make(map[string]string)
+	for _, i := range r.Issues {
+		if i.Field == "AdapterName" {
+			got[i.Device] = i.File
+		}
+	}
+	if got["bad(#1)"] != "site/a.yaml" || got["local(#2)"] != "main.yaml" {
+		t.Errorf("issue files = %v\n%s", got, r)
+	}
+}
+
+func TestValidateIncludedDBCFile(t *testing.T) {
+	// include 的设备的 dbcFile 相对于其所在文件的目录
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "site"), 0o755)
+	writeConfig(t, filepath.Join(dir, "site", "pcs.dbc"), pcsDBC)
+	writeConfig(t, filepath.Join(dir, "site", "pcs.yaml"),
+		"{name: pcs1, AdapterName: can, busId: C, params: {interface: can0}, dbcFile: pcs.dbc, dbcMessages: [PCS_Status]}\n")
+	writeConfig(t, filepath.Join(dir, "main.yaml"), "include: site\n")
+	cfgs, r := ValidateConfigFile(filepath.Join(dir, "main.yaml"))
+	if r.HasErrors() || len(cfgs) != 1 || len(cfgs[0].Points) != 2 {
+		t.Errorf("cfgs = %v\n%s", cfgs, r)
+	}
+}
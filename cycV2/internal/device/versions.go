@@ -0,0 +1,232 @@
+package device
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 已应用配置的版本存储：目录下 index.json 记录版本号、哈希、时间与来源，v<版本号>.json 保存原文，
+// 只保留最近 keep 个版本。内容与最新版本相同时不新增版本。Manager 记录的原文是 configBundle：
+// 主配置文件与 include 读取的全部文件（环境变量替换前），任一文件改动都产生新版本。
+
+// 版本来源
+const (
+	SourceReload   = "reload"
+	SourceRollback = "rollback"
+)
+
+// ConfigVersion 一个已应用的配置版本
+type ConfigVersion struct {
+	Version int       `json:"version"`
+	Hash    string    `json:"hash"` // 原文 sha256
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Note    string    `json:"note,omitempty"`
+}
+
+type VersionStore struct {
+	dir      string
+	keep     int
+	mu       sync.Mutex
+	versions []ConfigVersion // 按版本号升序
+}
+
+// NewVersionStore 打开（或创建）版本目录，keep<=0 时保留10个
+func NewVersionStore(dir string, keep int) (*VersionStore, error) {
+	if keep <= 0 {
+		keep = 10
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &VersionStore{dir: dir, keep: keep}
+	data, err := os.ReadFile(s.indexPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.versions); err != nil {
+			return nil, fmt.Errorf("version index %s: %w", s.indexPath(), err)
+		}
+	}
+	return s, nil
+}
+
+func (s *VersionStore) indexPath() string { return filepath.Join(s.dir, "index.json") }
+
+func (s *VersionStore) dataPath(version int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("v%06d.json", version))
+}
+
+// Save 记录一次已应用的配置；与最新版本内容相同时返回最新版本且 created 为 false
+func (s *VersionStore) Save(data []byte, source, note string) (v ConfigVersion, created bool, err error) {
+	return s.save(data, source, note, 0)
+}
+
+// save 同 Save，至少保留 minKeep 个版本（自动回滚观察期内须保留上一版本）
+func (s *VersionStore) save(data []byte, source, note string, minKeep int) (v ConfigVersion, created bool, err error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.versions); n > 0 && s.versions[n-1].Hash == hash {
+		return s.versions[n-1], false, nil
+	}
+	v = ConfigVersion{Version: 1, Hash: hash, Time: time.Now(), Source: source, Note: note}
+	if n := len(s.versions); n > 0 {
+		v.Version = s.versions[n-1].Version + 1
+	}
+	if err := writeFileAtomic(s.dataPath(v.Version), data); err != nil {
+		return v, false, err
+	}
+	versions := append(append([]ConfigVersion(nil), s.versions...), v)
+	keep := s.keep
+	if keep < minKeep {
+		keep = minKeep
+	}
+	var pruned []ConfigVersion
+	if len(versions) > keep {
+		pruned = versions[:len(versions)-keep]
+		versions = versions[len(versions)-keep:]
+	}
+	if err := s.writeIndex(versions); err != nil {
+		return v, false, err
+	}
+	s.versions = versions
+	for _, old := range pruned {
+		os.Remove(s.dataPath(old.Version))
+	}
+	return v, true, nil
+}
+
+func (s *VersionStore) writeIndex(versions []ConfigVersion) error {
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.indexPath(), data)
+}
+
+// List 保留的版本，按版本号升序
+func (s *VersionStore) List() []ConfigVersion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ConfigVersion(nil), s.versions...)
+}
+
+// Latest 最新版本
+func (s *VersionStore) Latest() (ConfigVersion, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.versions) == 0 {
+		return ConfigVersion{}, false
+	}
+	return s.versions[len(s.versions)-1], true
+}
+
+// Load 读取指定版本原文并校验哈希
+func (s *VersionStore) Load(version int) ([]byte, ConfigVersion, error) {
+	s.mu.Lock()
+	var v ConfigVersion
+	found := false
+	for _, x := range s.versions {
+		if x.Version == version {
+			v, found = x, true
+		}
+	}
+	s.mu.Unlock()
+	if !found {
+		return nil, v, fmt.Errorf("config version %d not found", version)
+	}
+	data, err := os.ReadFile(s.dataPath(version))
+	if err != nil {
+		return nil, v, err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != v.Hash {
+		return nil, v, fmt.Errorf("config version %d: hash mismatch", version)
+	}
+	return data, v, nil
+}
+
+// configBundle 一个版本读取的全部配置文件，路径相对主配置文件所在目录
+type configBundle struct {
+	Main  string            `json:"main"`
+	Files map[string]string `json:"files"`
+}
+
+// encodeConfigBundle files 为绝对路径 -> 原文；map 按键排序编码，相同内容得到相同哈希
+func encodeConfigBundle(mainPath string, files map[string][]byte) ([]byte, error) {
+	main, err := filepath.Abs(mainPath)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(main)
+	b := configBundle{Main: filepath.Base(main), Files: make(map[string]string, len(files))}
+	for p, data := range files {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		b.Files[filepath.ToSlash(rel)] = string(data)
+	}
+	return json.MarshalIndent(b, "", "  ")
+}
+
+// decodeConfigBundle 返回主文件原文与全部文件（绝对路径 -> 原文）。
+// 早期版本只保存了主文件原文，此时 files 为 nil
+func decodeConfigBundle(mainPath string, data []byte) (main []byte, files map[string][]byte, err error) {
+	var b configBundle
+	if json.Unmarshal(data, &b) != nil || b.Main == "" || b.Files == nil {
+		return data, nil, nil
+	}
+	if _, ok := b.Files[b.Main]; !ok {
+		return data, nil, nil
+	}
+	abs, err := filepath.Abs(mainPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	dir := filepath.Dir(abs)
+	files = make(map[string][]byte, len(b.Files))
+	for rel, content := range b.Files {
+		files[filepath.Join(dir, filepath.FromSlash(rel))] = []byte(content)
+	}
+	return []byte(b.Files[b.Main]), files, nil
+}
+
+// writeFileAtomic 写临时文件后改名，读者不会看到写了一半的内容
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,569 @@
+// Package migrate 旧版 cyc20250422 配置迁移：configs/devices/*.json（设备，按 model 引用型号）与
+// configs/points/*.json（按 modelId 的型号点表）转换为 cycV2 配置。
+//
+// 每个型号转为一个设备模板（id 为 modelId），每个设备转为引用该模板的实例，设备名取旧版 id，
+// 各设备独占一条总线（busId 同设备名）。字段映射：
+//   - regType holding/input/coil/discrete -> funcCode 与 params.func hr/ir/co/di，address/regNum -> params.address/quantity
+//   - scaleFactor -> scale，unit -> unit，readOnly -> rw（r / rw，输入寄存器与离散输入总是 r），regSwap -> swapReg
+//   - alarmSettings -> alarm，id -> name，name 与 description -> desc
+//   - 各点 scanRate -> 扫描等级：最常见的周期为模板 interval_ms（normal），更快的为 fast，更慢的为 slow
+//   - CAN 点位（address 为帧ID，startBit/bitLength/offset）-> dbc 信号点位
+//   - 设备 params（或旧格式的 connection/parameters）按协议改写为 cycV2 适配器参数，如 host+port -> address
+//
+// 无法映射、取了近似值或原样保留的字段都记入 Notes，dry-run 时据此人工确认。
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cycV2/internal/device"
+)
+
+// Note 一条需要人工确认的迁移记录
+type Note struct {
+	File    string `json:"file"`
+	Path    string `json:"path"` // 旧配置中的位置，如 devices[0].params.retries
+	Message string `json:"message"`
+}
+
+func (n Note) String() string {
+	return fmt.Sprintf("%s: %s: %s", n.File, n.Path, n.Message)
+}
+
+// Template 输出的设备模板
+type Template struct {
+	ID          string               `json:"id"`
+	AdapterName string               `json:"AdapterName"`
+	IntervalMs  int                  `json:"interval_ms,omitempty"`
+	ScanClasses map[string]int       `json:"scanClasses,omitempty"`
+	Points      []device.PointConfig `json:"points"`
+}
+
+// Device 输出的设备实例
+type Device struct {
+	Name     string                   `json:"name"`
+	Template string                   `json:"template"`
+	BusId    string                   `json:"busId"`
+	Params   map[string]interface{}   `json:"params,omitempty"`
+	Points   []map[string]interface{} `json:"points,omitempty"` // 对模板点位的覆盖，如设备级字节序
+}
+
+// Result 迁移结果，JSON() 即 cycV2 配置文件内容
+type Result struct {
+	Templates []Template `json:"templates"`
+	Devices   []Device   `json:"devices"`
+	Notes     []Note     `json:"-"`
+}
+
+// JSON 输出配置文件内容
+func (r *Result) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// regTypes 旧版寄存器类型 -> 功能码
+var regTypes = map[string]string{
+	"holding": "hr", "input": "ir", "coil": "co", "discrete": "di",
+	"holding_register": "hr", "input_register": "ir", "discrete_input": "di",
+}
+
+// ConvertDirs 读取旧版设备目录与点表目录下的全部 .json 文件（按文件名排序）并转换
+func ConvertDirs(devicesDir, pointsDir string) (*Result, error) {
+	c := &converter{models: make(map[string]*Template)}
+	pointFiles, err := jsonFiles(pointsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range pointFiles {
+		if err := c.pointsFile(path); err != nil {
+			return nil, err
+		}
+	}
+	deviceFiles, err := jsonFiles(devicesDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range deviceFiles {
+		if err := c.devicesFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return c.result()
+}
+
+func jsonFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		// 与旧版 ConfigManager 相同：只读 .json，.json_bak 等备份文件忽略
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".json" {
+			out = append(out, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+type converter struct {
+	models  map[string]*Template
+	order   []string
+	devices []Device
+	notes   []Note
+	file    string
+}
+
+func (c *converter) note(path, format string, args ...interface{}) {
+	c.notes = append(c.notes, Note{File: filepath.Base(c.file), Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// fields 逐个取出已映射的键，剩余的键即无法映射
+type fields map[string]interface{}
+
+func (f fields) take(k string) (interface{}, bool) {
+	v, ok := f[k]
+	delete(f, k)
+	return v, ok
+}
+
+func (f fields) str(k string) string {
+	v, _ := f.take(k)
+	s, _ := v.(string)
+	return s
+}
+
+func (f fields) num(k string) (float64, bool) {
+	v, ok := f.take(k)
+	n, isNum := v.(float64)
+	return n, ok && isNum
+}
+
+func (c *converter) unmapped(path string, f fields) {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c.note(path+"."+k, "no cycV2 equivalent, dropped (value %s)", compact(f[k]))
+	}
+}
+
+func compact(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func (c *converter) pointsFile(path string) error {
+	c.file = path
+	var file struct {
+		Models []fields `json:"models"`
+	}
+	if err := readJSON(path, &file); err != nil {
+		return err
+	}
+	for i, m := range file.Models {
+		at := fmt.Sprintf("models[%d]", i)
+		id := m.str("modelId")
+		if id == "" {
+			c.note(at, "modelId is empty, model skipped")
+			continue
+		}
+		if _, dup := c.models[id]; dup {
+			// 旧版后加载的同名型号覆盖先前的
+			c.note(at, "duplicate modelId %q, replaces the earlier definition", id)
+		} else {
+			c.order = append(c.order, id)
+		}
+		t := &Template{ID: id, AdapterName: m.str("protocol")}
+		if desc := m.str("description"); desc != "" {
+			c.note(at+".description", "templates have no description, dropped (%q)", desc)
+		}
+		pts, _ := m.take("points")
+		list, _ := pts.([]interface{})
+		var rates []int // 与 t.Points 一一对应
+		for j, raw := range list {
+			pm, ok := raw.(map[string]interface{})
+			if !ok {
+				c.note(fmt.Sprintf("%s.points[%d]", at, j), "not an object, skipped")
+				continue
+			}
+			pt, rate := c.point(fmt.Sprintf("%s.points[%d]", at, j), t.AdapterName, fields(pm))
+			t.Points = append(t.Points, pt)
+			rates = append(rates, rate)
+		}
+		c.scanClasses(at, t, rates)
+		c.unmapped(at, m)
+		c.models[id] = t
+	}
+	return nil
+}
+
+// scanClasses 按各点 scanRate 划分扫描等级：点数最多的周期作为 interval_ms（normal，同数取较快的），
+// 更快的归为 fast、更慢的归为 slow。同一等级内有多个周期时取其中最快的并记录
+func (c *converter) scanClasses(at string, t *Template, rates []int) {
+	count := make(map[int]int)
+	for _, r := range rates {
+		if r > 0 {
+			count[r]++
+		}
+	}
+	if len(count) == 0 {
+		return
+	}
+	var list []int
+	for r := range count {
+		list = append(list, r)
+	}
+	sort.Ints(list)
+	normal := list[0]
+	for _, r := range list {
+		if count[r] > count[normal] {
+			normal = r
+		}
+	}
+	t.IntervalMs = normal
+	if len(list) == 1 {
+		return
+	}
+	classOf := func(r int) string {
+		switch {
+		case r <= 0 || r == normal:
+			return ""
+		case r < normal:
+			return device.ScanFast
+		default:
+			return device.ScanSlow
+		}
+	}
+	buckets := make(map[string][]int)
+	for _, r := range list {
+		if class := classOf(r); class != "" {
+			buckets[class] = append(buckets[class], r)
+		}
+	}
+	t.ScanClasses = make(map[string]int)
+	for _, class := range []string{device.ScanFast, device.ScanSlow} {
+		b := buckets[class]
+		if len(b) == 0 {
+			continue
+		}
+		t.ScanClasses[class] = b[0]
+		if len(b) > 1 {
+			parts := make([]string, len(b))
+			for i, r := range b {
+				parts[i] = fmt.Sprintf("%dms x%d", r, count[r])
+			}
+			c.note(at+".points[].scanRate", "scan rates %s all map to scan class %s, using the fastest %d", strings.Join(parts, ", "), class, b[0])
+		}
+	}
+	for i := range t.Points {
+		t.Points[i].ScanClass = classOf(rates[i])
+	}
+}
+
+func (c *converter) point(at, protocol string, f fields) (device.PointConfig, int) {
+	pt := device.PointConfig{Name: f.str("id"), Unit: f.str("unit")}
+	name, desc := f.str("name"), f.str("description")
+	switch {
+	case name == desc || desc == "":
+		pt.Desc = name
+	case name == "":
+		pt.Desc = desc
+	default:
+		pt.Desc = name + " - " + desc
+	}
+	if pt.Name == "" {
+		c.note(at+".id", "point id is empty")
+	}
+	addr, hasAddr := f.num("address")
+	regNum, _ := f.num("regNum")
+	pt.RegAddr, pt.RegNum = uint16(addr), uint16(regNum)
+	pt.DataType = f.str("dataType")
+	if s, ok := f.num("scaleFactor"); ok && s != 1 && s != 0 {
+		pt.Scale = s
+	}
+	readOnly, _ := f.take("readOnly")
+	pt.Rw = "rw"
+	if ro, _ := readOnly.(bool); ro {
+		pt.Rw = "r"
+	}
+	swap, _ := f.take("regSwap")
+	pt.SwapReg, _ = swap.(bool)
+	regType := f.str("regType")
+
+	if protocol == "modbus" {
+		fc, ok := regTypes[strings.ToLower(regType)]
+		if !ok {
+			c.note(at+".regType", "unknown register type %q, using hr", regType)
+			fc = "hr"
+		}
+		if fc == "ir" || fc == "di" {
+			pt.Rw = "r"
+		}
+		// 与旧版 validatePoints 一致：寄存器数量以数据类型为准
+		if want := regsOf(pt.DataType); fc != "co" && fc != "di" && want > 0 && pt.RegNum != want {
+			c.note(at+".regNum", "regNum %d does not match %s, using %d", pt.RegNum, pt.DataType, want)
+			pt.RegNum = want
+		}
+		if pt.RegNum == 0 {
+			pt.RegNum = 1
+		}
+		pt.FuncCode = fc
+		pt.Params = map[string]interface{}{"func": fc, "address": float64(pt.RegAddr), "quantity": float64(pt.RegNum)}
+	} else if protocol == "can" {
+		c.canSignal(at, &pt, f, addr)
+	} else {
+		if regType != "" {
+			c.note(at+".regType", "regType %q is modbus-only, dropped", regType)
+		}
+		if hasAddr {
+			key := "address"
+			if protocol == "iec104" {
+				key = "ioa"
+			} else {
+				c.note(at+".address", "copied to params.address, check against the %s adapter", protocol)
+			}
+			pt.Params = map[string]interface{}{key: addr}
+		}
+	}
+
+	if raw, ok := f.take("alarmSettings"); ok {
+		pt.Alarm = c.alarm(at+".alarmSettings", raw)
+	}
+	rate, _ := f.num("scanRate")
+	c.unmapped(at, f)
+	return pt, int(rate)
+}
+
+// canSignal 旧版 CAN 点位（address 为帧ID，startBit/bitLength/offset）转为 dbc 信号点位，
+// 系数并入信号 factor；旧版未记录字节序，按 Intel（小端）处理
+func (c *converter) canSignal(at string, pt *device.PointConfig, f fields, frameID float64) {
+	start, okStart := f.num("startBit")
+	length, okLen := f.num("bitLength")
+	if !okStart || !okLen {
+		c.note(at, "can point without startBit/bitLength, kept as %s", pt.DataType)
+		return
+	}
+	factor := 1.0
+	if pt.Scale != 0 {
+		factor, pt.Scale = pt.Scale, 0
+	}
+	offset, _ := f.num("offset")
+	pt.Params = map[string]interface{}{
+		"frame_id": frameID, "extended": frameID > 0x7FF,
+		"startBit": start, "bitLength": length, "intel": true,
+		"signed": strings.HasPrefix(pt.DataType, "int"), "factor": factor, "offset": offset,
+	}
+	pt.DataType, pt.Rw = "dbc", "r"
+	c.note(at, "converted to a dbc signal assuming Intel byte order, check the frame layout")
+}
+
+func (c *converter) alarm(at string, raw interface{}) *device.AlarmConfig {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		c.note(at, "not an object, dropped")
+		return nil
+	}
+	f := fields(m)
+	a := &device.AlarmConfig{}
+	enable, _ := f.take("enableAlarm")
+	a.Enable, _ = enable.(bool)
+	if v, ok := f.num("highLimit"); ok {
+		a.HighLimit = &v
+	}
+	if v, ok := f.num("lowLimit"); ok {
+		a.LowLimit = &v
+	}
+	c.unmapped(at, f)
+	return a
+}
+
+// regsOf 数据类型占用的寄存器数，未知类型为0
+func regsOf(dataType string) uint16 {
+	switch dataType {
+	case "int16", "uint16":
+		return 1
+	case "float32", "int32", "uint32":
+		return 2
+	case "float64", "int64", "uint64":
+		return 4
+	}
+	return 0
+}
+
+// paramRenames 各协议的旧参数名 -> cycV2 适配器参数名
+var paramRenames = map[string]map[string]string{
+	"modbus":   {"slaveId": "slaveId", "slave_id": "slaveId", "timeout": "timeoutMs"},
+	"iec104":   {"common_address": "commonAddress", "asdu_address": "commonAddress"},
+	"iec61850": {"timeout": "timeoutMs"},
+	"can":      {"interface": "interface", "bitrate": "baudrate", "extended_id": "extended"},
+}
+
+func (c *converter) devicesFile(path string) error {
+	c.file = path
+	var file struct {
+		Devices []fields `json:"devices"`
+	}
+	if err := readJSON(path, &file); err != nil {
+		return err
+	}
+	for i, d := range file.Devices {
+		at := fmt.Sprintf("devices[%d]", i)
+		dev := Device{Name: d.str("id"), Template: d.str("model")}
+		dev.BusId = dev.Name
+		if dev.Name == "" {
+			c.note(at+".id", "device id is empty, device skipped")
+			continue
+		}
+		if name := d.str("name"); name != "" && name != dev.Name {
+			c.note(at+".name", "display name %q dropped, cycV2 uses the id %q as device name", name, dev.Name)
+		}
+		protocol := d.str("protocol")
+		t, ok := c.models[dev.Template]
+		switch {
+		case !ok:
+			c.note(at+".model", "model %q has no points file, device skipped", dev.Template)
+			continue
+		case protocol != "" && protocol != t.AdapterName:
+			c.note(at+".protocol", "protocol %q differs from model %q (%s), using the model's", protocol, t.ID, t.AdapterName)
+		}
+		var order string
+		dev.Params, order = c.params(at, t.AdapterName, d)
+		// 旧版字节序是设备级的，而点位属于型号：以实例覆盖模板点位
+		if order != "" && order != "big" {
+			for _, pt := range t.Points {
+				dev.Points = append(dev.Points, map[string]interface{}{"name": pt.Name, "byteOrder": order})
+			}
+		}
+		c.unmapped(at, d)
+		c.devices = append(c.devices, dev)
+	}
+	return nil
+}
+
+// params 合并 params 与旧格式的 connection/parameters，按协议改写；另返回 modbus 设备级字节序
+func (c *converter) params(at, protocol string, d fields) (map[string]interface{}, string) {
+	in := fields{}
+	src := make(map[string]string)
+	for _, section := range []string{"params", "connection", "parameters"} {
+		raw, _ := d.take(section)
+		m, _ := raw.(map[string]interface{})
+		for k, v := range m {
+			in[k] = v
+			src[k] = at + "." + section + "." + k
+		}
+	}
+	if len(in) == 0 {
+		return nil, ""
+	}
+	var order string
+	out := make(map[string]interface{})
+	host := in.str("host")
+	if host == "" {
+		host = in.str("address")
+	}
+	if host != "" {
+		if port, ok := in.num("port"); ok {
+			host = fmt.Sprintf("%s:%d", host, int(port))
+		}
+		out["address"] = host
+		if protocol == "modbus" {
+			out["mode"] = "tcp"
+		}
+	}
+	renames := paramRenames[protocol]
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := in[k]
+		switch {
+		case protocol == "modbus" && k == "byte_order":
+			order = c.byteOrder(src[k], v)
+		case protocol == "iec61850" && k == "authentication":
+			auth, _ := v.(map[string]interface{})
+			if pw, ok := auth["password"]; ok {
+				out["password"] = pw
+			}
+			if user, ok := auth["username"]; ok {
+				c.note(src[k]+".username", "iec61850 adapter has no username, dropped (%s)", compact(user))
+			}
+		case renames[k] != "":
+			to := renames[k]
+			if prev, dup := out[to]; dup && compact(prev) != compact(v) {
+				c.note(src[k], "conflicts with %s already set to %s, dropped", to, compact(prev))
+				continue
+			}
+			out[to] = v
+		case k == "retries":
+			c.note(src[k], "retries has no cycV2 equivalent, dropped (value %s)", compact(v))
+		default:
+			out[k] = v
+			c.note(src[k], "copied unchanged as params.%s, check against the %s adapter", k, protocol)
+		}
+	}
+	return out, order
+}
+
+// byteOrder 旧版设备级字节序 big_endian/little_endian
+func (c *converter) byteOrder(at string, v interface{}) string {
+	s, _ := v.(string)
+	switch strings.ToLower(s) {
+	case "big_endian", "big":
+		return "big"
+	case "little_endian", "little":
+		return "little"
+	}
+	c.note(at, "unknown byte order %q, dropped", s)
+	return ""
+}
+
+func (c *converter) result() (*Result, error) {
+	r := &Result{Devices: c.devices, Notes: c.notes}
+	for _, id := range c.order {
+		r.Templates = append(r.Templates, *c.models[id])
+	}
+	// 生成的配置走一遍 cycV2 校验，问题一并报告
+	data, err := r.JSON()
+	if err != nil {
+		return nil, err
+	}
+	cfgs, err := device.ParseDeviceConfigs(data)
+	if err != nil {
+		return nil, fmt.Errorf("converted config does not parse: %w", err)
+	}
+	for _, issue := range device.ValidateDeviceConfigs("output", cfgs).Issues {
+		var path []string
+		for _, p := range []string{issue.Device, issue.Point, issue.Field} {
+			if p != "" {
+				path = append(path, p)
+			}
+		}
+		r.Notes = append(r.Notes, Note{File: "output", Path: strings.Join(path, "."), Message: issue.Severity + ": " + issue.Message})
+	}
+	return r, nil
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
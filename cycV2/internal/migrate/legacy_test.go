@@ -0,0 +1,125 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cycV2/internal/device"
+	_ "cycV2/internal/protocol/can"
+	_ "cycV2/internal/protocol/modbus"
+)
+
+const legacyPoints = `{"models": [
+  {"modelId": "PLC", "protocol": "modbus", "points": [
+    {"id": "temp", "name": "温度", "description": "环境温度", "address": 1, "regType": "holding", "regNum": 1,
+     "dataType": "int16", "unit": "°C", "scaleFactor": 0.1, "readOnly": true, "regSwap": false,
+     "alarmSettings": {"highLimit": 75.0, "lowLimit": -10.0, "enableAlarm": true, "deadband": 1}, "scanRate": 1000},
+    {"id": "power", "name": "功率", "address": 10, "regType": "input", "regNum": 1, "dataType": "float32",
+     "scaleFactor": 1, "readOnly": false, "regSwap": true, "scanRate": 200},
+    {"id": "run", "name": "运行", "address": 0, "regType": "coil", "dataType": "bool", "readOnly": false, "color": "red", "scanRate": 1000},
+    {"id": "sn", "name": "序列号", "address": 20, "regType": "holding", "regNum": 1, "dataType": "uint16", "readOnly": true, "scanRate": 86400000},
+    {"id": "fw", "name": "固件版本", "address": 21, "regType": "holding", "regNum": 1, "dataType": "uint16", "readOnly": true, "scanRate": 3600000}]},
+  {"modelId": "ECU", "protocol": "can", "points": [
+    {"id": "rpm", "name": "转速", "address": 291, "startBit": 0, "bitLength": 16, "dataType": "uint16",
+     "scaleFactor": 0.5, "offset": -40, "readOnly": true}]}]}`
+
+const legacyDevices = `{"devices": [
+  {"id": "plc1", "name": "1号PLC", "protocol": "modbus", "model": "PLC",
+   "params": {"host": "10.0.0.5", "port": 502, "timeout": 3000, "retries": 3, "slaveId": 2}},
+  {"id": "plc2", "protocol": "modbus", "model": "PLC",
+   "connection": {"address": "10.0.0.6", "port": 1502}, "parameters": {"slave_id": 3, "byte_order": "little_endian"}},
+  {"id": "ecu1", "protocol": "can", "model": "ECU", "connection": {"interface": "can0", "bitrate": 250000}},
+  {"id": "ghost", "protocol": "modbus", "model": "NOPE"}]}`
+
+func writeLegacy(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"points/points.json":       legacyPoints,
+		"points/points.json_bak":   `not json`,
+		"devices/devices.json":     legacyDevices,
+		"devices/devices.json_bak": `not json`,
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestConvertLegacy(t *testing.T) {
+	dir := writeLegacy(t)
+	r, err := ConvertDirs(filepath.Join(dir, "devices"), filepath.Join(dir, "points"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := r.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgs, err := device.ParseDeviceConfigs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 3 {
+		t.Fatalf("devices = %d", len(cfgs))
+	}
+
+	plc1, plc2, ecu := cfgs[0], cfgs[1], cfgs[2]
+	if plc1.Name != "plc1" || plc1.BusId != "plc1" || plc1.Template != "PLC" || plc1.AdapterName != "modbus" || plc1.IntervalMs != 1000 ||
+		plc1.Params["address"] != "10.0.0.5:502" || plc1.Params["mode"] != "tcp" || plc1.Params["slaveId"] != float64(2) || plc1.Params["timeoutMs"] != float64(3000) {
+		t.Errorf("plc1 = %+v", *plc1)
+	}
+	temp, power, run := plc1.Points[0], plc1.Points[1], plc1.Points[2]
+	// scanRate：最常见的 1000ms 为 normal，200ms 为 fast，更慢的两个周期并入 slow
+	if temp.ScanClass != "" || power.ScanClass != device.ScanFast || plc1.Points[3].ScanClass != device.ScanSlow ||
+		plc1.ScanClasses[device.ScanFast] != 200 || plc1.ScanClasses[device.ScanSlow] != 3600000 || len(plc1.ScanClasses) != 2 {
+		t.Errorf("scan classes = %v %+v", plc1.ScanClasses, plc1.Points)
+	}
+	if temp.Desc != "温度 - 环境温度" || temp.FuncCode != "hr" || temp.Scale != 0.1 || temp.Rw != "r" || temp.Unit != "°C" ||
+		temp.Alarm == nil || !temp.Alarm.Enable || *temp.Alarm.HighLimit != 75 || *temp.Alarm.LowLimit != -10 {
+		t.Errorf("temp = %+v", temp)
+	}
+	// 输入寄存器只读；regNum 按数据类型修正；系数1不换算
+	if power.FuncCode != "ir" || power.Rw != "r" || power.RegNum != 2 || power.Params["quantity"] != float64(2) || !power.SwapReg || power.Scale != 0 {
+		t.Errorf("power = %+v", power)
+	}
+	if run.FuncCode != "co" || run.Rw != "rw" || run.Params["func"] != "co" || run.RegNum != 1 {
+		t.Errorf("run = %+v", run)
+	}
+	if plc2.Params["address"] != "10.0.0.6:1502" || plc2.Params["slaveId"] != float64(3) || plc2.Points[0].ByteOrder != "little" || plc1.Points[0].ByteOrder != "" {
+		t.Errorf("plc2 = %+v", *plc2)
+	}
+	rpm := ecu.Points[0]
+	if rpm.DataType != "dbc" || rpm.Scale != 0 || rpm.Params["factor"] != 0.5 || rpm.Params["offset"] != float64(-40) ||
+		rpm.Params["frame_id"] != float64(291) || ecu.Params["interface"] != "can0" || ecu.Params["baudrate"] != float64(250000) {
+		t.Errorf("ecu = %+v %+v", *ecu, rpm)
+	}
+
+	var notes []string
+	for _, n := range r.Notes {
+		notes = append(notes, n.String())
+	}
+	all := strings.Join(notes, "\n")
+	for _, want := range []string{
+		"points.json: models[0].points[0].alarmSettings.deadband: no cycV2 equivalent",
+		"points.json: models[0].points[1].regNum: regNum 1 does not match float32, using 2",
+		"points.json: models[0].points[2].color: no cycV2 equivalent",
+		"points.json: models[0].points[].scanRate: scan rates 3600000ms x1, 86400000ms x1 all map to scan class slow, using the fastest 3600000",
+		"points.json: models[1].points[0]: converted to a dbc signal",
+		"devices.json: devices[0].name: display name \"1号PLC\" dropped",
+		"devices.json: devices[0].params.retries: retries has no cycV2 equivalent",
+		"devices.json: devices[3].model: model \"NOPE\" has no points file, device skipped",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("missing note %q in:\n%s", want, all)
+		}
+	}
+	if strings.Contains(all, "output:") || strings.Contains(all, "json_bak") {
+		t.Errorf("unexpected notes:\n%s", all)
+	}
+}
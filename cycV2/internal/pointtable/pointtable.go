@@ -0,0 +1,687 @@
+// Package pointtable 点表导入导出：工程人员在 Excel 中维护的点表（.xlsx/.csv）与 PointConfig 互转。
+//
+// 第一行为表头，按列名（中英文均可，忽略大小写与首尾空格）映射到 PointConfig 字段，之后每行一个点位，
+// 空行跳过，含单位、系数与告警上下限列。"params.<键>" 列写入点位参数；备注列忽略；其他无法识别的列报错。
+// 每个单元格单独校验，错误以单元格引用定位（如 点表!C5），一次报告全部问题。
+//
+// 表中有功能码列时按 Modbus 点表处理：params.func/address/quantity 未单独给出时取功能码、寄存器地址与
+// 寄存器数量，寄存器数量为空时按数据类型推算，导入结果可直接被 modbus 适配器使用。
+package pointtable
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"cycV2/internal/device"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 字段名与 PointConfig 的 JSON 字段名一致
+const (
+	fieldName      = "name"
+	fieldDesc      = "desc"
+	fieldFuncCode  = "funcCode"
+	fieldRegAddr   = "regAddr"
+	fieldRegNum    = "regNum"
+	fieldDataType  = "dataType"
+	fieldByteOrder = "byteOrder"
+	fieldSwapReg   = "swapReg"
+	fieldRw        = "rw"
+	fieldUnit      = "unit"
+	fieldScale     = "scale"
+	fieldAlarmOn   = "alarm.enable"
+	fieldAlarmHigh = "alarm.highLimit"
+	fieldAlarmLow  = "alarm.lowLimit"
+	fieldIgnore    = "-"
+	paramsPrefix   = "params."
+)
+
+// columns 导出顺序与表头（首个为导出时的表头），其余为导入时识别的别名
+var columns = []struct {
+	field   string
+	headers []string
+}{
+	{fieldName, []string{"名称", "点位名称", "点名", "测点名称", "标识", "name", "id"}},
+	{fieldDesc, []string{"描述", "说明", "中文名", "点位描述", "desc", "description"}},
+	{fieldFuncCode, []string{"功能码", "寄存器类型", "区域", "funccode", "func", "regtype"}},
+	{fieldRegAddr, []string{"寄存器地址", "地址", "起始地址", "regaddr", "address", "addr"}},
+	{fieldRegNum, []string{"寄存器数量", "数量", "长度", "regnum", "quantity", "count"}},
+	{fieldDataType, []string{"数据类型", "类型", "datatype", "type", "parse"}},
+	{fieldByteOrder, []string{"字节序", "byteorder"}},
+	{fieldSwapReg, []string{"字交换", "高低字交换", "swapreg", "swap"}},
+	{fieldRw, []string{"读写", "读写属性", "权限", "rw", "access"}},
+	{fieldUnit, []string{"单位", "unit"}},
+	{fieldScale, []string{"系数", "倍率", "比例", "缩放系数", "scale", "factor", "scalefactor"}},
+	{fieldAlarmOn, []string{"告警使能", "告警", "enablealarm", "alarm.enable"}},
+	{fieldAlarmHigh, []string{"告警上限", "上限", "highlimit", "alarm.highlimit"}},
+	{fieldAlarmLow, []string{"告警下限", "下限", "lowlimit", "alarm.lowlimit"}},
+	{fieldIgnore, []string{"备注", "remark", "note", "comment"}},
+}
+
+// Options 导入选项
+type Options struct {
+	Sheet   string            // xlsx 工作表，空为第一个
+	Columns map[string]string // 额外的表头 -> 字段映射（字段名同 PointConfig 的 JSON 名，或 params.<键>，"-" 忽略）
+}
+
+// CellError 单元格级错误
+type CellError struct {
+	Cell    string // 单元格引用，xlsx 含工作表名，如 点表!C5
+	Field   string
+	Message string
+}
+
+func (e CellError) Error() string {
+	if e.Field == "" {
+		return e.Cell + ": " + e.Message
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Cell, e.Field, e.Message)
+}
+
+// ImportError 一张点表的全部错误
+type ImportError struct {
+	Errors []CellError
+}
+
+func (e *ImportError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, c := range e.Errors {
+		msgs[i] = c.Error()
+	}
+	return fmt.Sprintf("point table: %d error(s):\n  %s", len(e.Errors), strings.Join(msgs, "\n  "))
+}
+
+// ImportFile 按扩展名导入 .xlsx 或 .csv 点表
+func ImportFile(path string, opt Options) ([]device.PointConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlsx", ".xlsm":
+		return ImportXLSX(f, opt)
+	case ".csv":
+		return ImportCSV(f, opt)
+	}
+	return nil, fmt.Errorf("unsupported point table %s, use .xlsx or .csv", filepath.Base(path))
+}
+
+// ImportXLSX 导入 xlsx 工作表
+func ImportXLSX(r io.Reader, opt Options) ([]device.PointConfig, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sheet := opt.Sheet
+	if sheet == "" {
+		sheet = f.GetSheetName(0)
+	} else if idx, _ := f.GetSheetIndex(sheet); idx < 0 {
+		return nil, fmt.Errorf("sheet %q not found (sheets: %s)", sheet, strings.Join(f.GetSheetList(), ", "))
+	}
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	return parseRows(rows, sheet+"!", opt)
+}
+
+// ImportCSV 导入 csv；兼容 Excel 导出的 UTF-8 BOM 与 GBK 编码
+func ImportCSV(r io.Reader, opt Options) ([]device.PointConfig, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		if data, err = simplifiedchinese.GBK.NewDecoder().Bytes(data); err != nil {
+			return nil, fmt.Errorf("csv is neither UTF-8 nor GBK: %w", err)
+		}
+	}
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return parseRows(rows, "", opt)
+}
+
+// rowParser 逐行解析，收集单元格错误
+type rowParser struct {
+	prefix string
+	errs   []CellError
+}
+
+func (p *rowParser) errorf(col, row int, field, format string, args ...interface{}) {
+	cell, _ := excelize.CoordinatesToCellName(col+1, row+1)
+	p.errs = append(p.errs, CellError{Cell: p.prefix + cell, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func parseRows(rows [][]string, prefix string, opt Options) ([]device.PointConfig, error) {
+	p := &rowParser{prefix: prefix}
+	if len(rows) == 0 {
+		return nil, &ImportError{Errors: []CellError{{Cell: prefix + "A1", Message: "empty point table"}}}
+	}
+	fields := p.header(rows[0], opt)
+	if len(p.errs) > 0 {
+		return nil, &ImportError{Errors: p.errs}
+	}
+	modbus := false
+	for _, f := range fields {
+		modbus = modbus || f == fieldFuncCode
+	}
+
+	var points []device.PointConfig
+	seen := make(map[string]string)
+	for r := 1; r < len(rows); r++ {
+		if blankRow(rows[r]) {
+			continue
+		}
+		pt, ok := p.row(rows[r], r, fields, modbus)
+		// 有错误的行也登记名称，重名照样报告
+		if pt.Name != "" {
+			col := indexOf(fields, fieldName)
+			if first, dup := seen[pt.Name]; dup {
+				p.errorf(col, r, fieldName, "duplicate point name %q, first defined at %s", pt.Name, first)
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(col+1, r+1)
+			seen[pt.Name] = prefix + cell
+		}
+		if ok {
+			points = append(points, pt)
+		}
+	}
+	if len(p.errs) > 0 {
+		return nil, &ImportError{Errors: p.errs}
+	}
+	return points, nil
+}
+
+// header 表头映射到字段，列号对应 fields 下标
+func (p *rowParser) header(row []string, opt Options) []string {
+	aliases := make(map[string]string)
+	for _, c := range columns {
+		for _, h := range c.headers {
+			aliases[strings.ToLower(h)] = c.field
+		}
+	}
+	for h, f := range opt.Columns {
+		aliases[normalizeHeader(h)] = f
+	}
+	fields := make([]string, len(row))
+	at := make(map[string]int)
+	for i, h := range row {
+		key := normalizeHeader(h)
+		if key == "" {
+			continue
+		}
+		f, ok := aliases[key]
+		if !ok && strings.HasPrefix(key, paramsPrefix) && len(key) > len(paramsPrefix) {
+			f, ok = key, true
+		}
+		if !ok {
+			p.errorf(i, 0, "", "unknown column %q", strings.TrimSpace(h))
+			continue
+		}
+		if f == fieldIgnore {
+			continue
+		}
+		if first, dup := at[f]; dup {
+			cell, _ := excelize.CoordinatesToCellName(first+1, 1)
+			p.errorf(i, 0, f, "column %q maps to the same field as %s", strings.TrimSpace(h), p.prefix+cell)
+			continue
+		}
+		at[f] = i
+		fields[i] = f
+	}
+	if _, ok := at[fieldName]; !ok && len(p.errs) == 0 {
+		p.errorf(0, 0, fieldName, "missing point name column (e.g. 名称 or name)")
+	}
+	return fields
+}
+
+// normalizeHeader 小写，去掉空格与括号内的说明，如 "地址（十进制）" -> "地址"
+func normalizeHeader(h string) string {
+	h = strings.TrimSpace(h)
+	for _, open := range []string{"(", "（"} {
+		if i := strings.Index(h, open); i > 0 {
+			h = strings.TrimSpace(h[:i])
+		}
+	}
+	if strings.HasPrefix(strings.ToLower(h), paramsPrefix) {
+		return strings.ToLower(h[:len(paramsPrefix)]) + h[len(paramsPrefix):]
+	}
+	return strings.ToLower(strings.ReplaceAll(h, " ", ""))
+}
+
+func (p *rowParser) row(row []string, r int, fields []string, modbus bool) (device.PointConfig, bool) {
+	var pt device.PointConfig
+	nerr := len(p.errs)
+	var regNumSet bool
+	for c, f := range fields {
+		if f == "" || c >= len(row) {
+			continue
+		}
+		v := strings.TrimSpace(row[c])
+		if v == "" {
+			continue
+		}
+		switch f {
+		case fieldName:
+			pt.Name = v
+		case fieldDesc:
+			pt.Desc = v
+		case fieldUnit:
+			pt.Unit = v
+		case fieldFuncCode:
+			fc, ok := parseFuncCode(v)
+			if !ok {
+				p.errorf(c, r, f, "unknown function code %q, use hr/ir/co/di (or 3/4/1/2, 保持寄存器/输入寄存器/线圈/离散输入)", v)
+			}
+			pt.FuncCode = fc
+		case fieldRegAddr, fieldRegNum:
+			n, err := parseUint16(v)
+			if err != nil {
+				p.errorf(c, r, f, "%v", err)
+			}
+			if f == fieldRegAddr {
+				pt.RegAddr = n
+			} else {
+				pt.RegNum, regNumSet = n, true
+			}
+		case fieldDataType:
+			dt := parseDataType(v)
+			if _, ok := dataTypeSize[dt]; !ok {
+				p.errorf(c, r, f, "unknown data type %q", v)
+			}
+			pt.DataType = dt
+		case fieldByteOrder:
+			switch strings.ToLower(v) {
+			case "big", "大端", "be", "abcd":
+				pt.ByteOrder = "big"
+			case "little", "小端", "le", "dcba":
+				pt.ByteOrder = "little"
+			default:
+				p.errorf(c, r, f, "must be big or little (大端/小端), got %q", v)
+			}
+		case fieldSwapReg:
+			b, ok := parseBool(v)
+			if !ok {
+				p.errorf(c, r, f, "must be 是/否 or true/false, got %q", v)
+			}
+			pt.SwapReg = b
+		case fieldRw:
+			rw, ok := parseRw(v)
+			if !ok {
+				p.errorf(c, r, f, "must be r, w or rw (只读/只写/读写), got %q", v)
+			}
+			pt.Rw = rw
+		case fieldScale:
+			s, err := strconv.ParseFloat(v, 64)
+			if err != nil || s == 0 {
+				p.errorf(c, r, f, "must be a non-zero number, got %q", v)
+			}
+			pt.Scale = s
+		case fieldAlarmOn:
+			b, ok := parseBool(v)
+			if !ok {
+				p.errorf(c, r, f, "must be 是/否 or true/false, got %q", v)
+			}
+			alarmOf(&pt).Enable = b
+		case fieldAlarmHigh, fieldAlarmLow:
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				p.errorf(c, r, f, "must be a number, got %q", v)
+			}
+			if f == fieldAlarmHigh {
+				alarmOf(&pt).HighLimit = &n
+			} else {
+				alarmOf(&pt).LowLimit = &n
+			}
+		default: // params.<键>
+			if pt.Params == nil {
+				pt.Params = make(map[string]interface{})
+			}
+			pt.Params[strings.TrimPrefix(f, paramsPrefix)] = cellValue(v)
+		}
+	}
+	if pt.Name == "" {
+		p.errorf(indexOf(fields, fieldName), r, fieldName, "point name is empty")
+	}
+	if len(p.errs) > nerr {
+		return pt, false
+	}
+	if modbus {
+		p.modbusParams(&pt, r, fields, regNumSet)
+	}
+	return pt, len(p.errs) == nerr
+}
+
+func alarmOf(pt *device.PointConfig) *device.AlarmConfig {
+	if pt.Alarm == nil {
+		pt.Alarm = &device.AlarmConfig{}
+	}
+	return pt.Alarm
+}
+
+// modbusParams 由功能码/地址/数量补齐适配器实际使用的 params
+func (p *rowParser) modbusParams(pt *device.PointConfig, r int, fields []string, regNumSet bool) {
+	if pt.FuncCode == "" {
+		p.errorf(indexOf(fields, fieldFuncCode), r, fieldFuncCode, "function code is empty")
+		return
+	}
+	if !regNumSet {
+		switch size := dataTypeSize[pt.DataType]; {
+		case pt.FuncCode == "co" || pt.FuncCode == "di":
+			pt.RegNum = 1
+		case size > 1:
+			pt.RegNum = uint16(size / 2)
+		default:
+			pt.RegNum = 1
+		}
+	}
+	if pt.Params == nil {
+		pt.Params = make(map[string]interface{})
+	}
+	for k, v := range map[string]interface{}{
+		"func": pt.FuncCode, "address": float64(pt.RegAddr), "quantity": float64(pt.RegNum),
+	} {
+		if _, set := pt.Params[k]; !set {
+			pt.Params[k] = v
+		}
+	}
+}
+
+// dataTypeSize 与设备解析支持的类型一致，值为字节数
+var dataTypeSize = map[string]int{
+	"raw": 0, "string": 0, "bool": 0, "dbc": 0, "spn": 0, "dlt645": 0,
+	"uint8": 1, "int8": 1, "int16": 2, "uint16": 2,
+	"int32": 4, "uint32": 4, "float32": 4, "int64": 8, "uint64": 8, "float64": 8,
+}
+
+func parseDataType(v string) string {
+	v = strings.ToLower(v)
+	switch v {
+	case "float", "real", "单精度浮点":
+		return "float32"
+	case "double", "双精度浮点":
+		return "float64"
+	case "short", "int":
+		return "int16"
+	case "word", "ushort", "uint":
+		return "uint16"
+	case "dint", "long":
+		return "int32"
+	case "dword", "ulong", "udint":
+		return "uint32"
+	case "boolean", "bit", "布尔":
+		return "bool"
+	case "字符串":
+		return "string"
+	}
+	return v
+}
+
+func parseFuncCode(v string) (string, bool) {
+	switch strings.ToLower(v) {
+	case "hr", "3", "03", "4x", "holding", "保持寄存器":
+		return "hr", true
+	case "ir", "4", "04", "3x", "input", "输入寄存器":
+		return "ir", true
+	case "co", "1", "01", "0x", "coil", "线圈":
+		return "co", true
+	case "di", "2", "02", "1x", "discrete", "离散输入":
+		return "di", true
+	}
+	return v, false
+}
+
+func parseRw(v string) (string, bool) {
+	switch strings.ToLower(v) {
+	case "r", "ro", "read", "只读", "读":
+		return "r", true
+	case "w", "wo", "write", "只写", "写":
+		return "w", true
+	case "rw", "wr", "读写", "可读写":
+		return "rw", true
+	}
+	return v, false
+}
+
+func parseBool(v string) (bool, bool) {
+	switch strings.ToLower(v) {
+	case "1", "true", "yes", "y", "是", "√":
+		return true, true
+	case "0", "false", "no", "n", "否", "×":
+		return false, true
+	}
+	return false, false
+}
+
+// parseUint16 寄存器地址/数量，支持十六进制 0x 前缀；Excel 中的整数可能带 .0
+func parseUint16(v string) (uint16, error) {
+	if f, err := strconv.ParseFloat(v, 64); err == nil && !strings.HasPrefix(strings.ToLower(v), "0x") {
+		if f != float64(int64(f)) || f < 0 || f > 65535 {
+			return 0, fmt.Errorf("must be an integer in 0..65535, got %q", v)
+		}
+		return uint16(f), nil
+	}
+	n, err := strconv.ParseUint(v, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("must be an integer in 0..65535, got %q", v)
+	}
+	return uint16(n), nil
+}
+
+// cellValue params 列的值：数值转 float64、true/false 转 bool，与 JSON 配置解码一致
+func cellValue(v string) interface{} {
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f
+	}
+	switch strings.ToLower(v) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return v
+}
+
+func blankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOf(fields []string, f string) int {
+	for i, x := range fields {
+		if x == f {
+			return i
+		}
+	}
+	return 0
+}
+
+// ExportXLSX 导出点表，表头为中文列名；params 中与功能码/地址/数量重复的 func/address/quantity 不单独成列，
+// 其余参数导出为 params.<键> 列，导出的文件可直接再导入
+func ExportXLSX(w io.Writer, sheet string, points []device.PointConfig) error {
+	if sheet == "" {
+		sheet = "点表"
+	}
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		return err
+	}
+
+	paramKeys := make(map[string]bool)
+	for _, pt := range points {
+		for k := range exportParams(pt) {
+			paramKeys[k] = true
+		}
+	}
+	keys := make([]string, 0, len(paramKeys))
+	for k := range paramKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// 告警列只在有点位配置告警时导出
+	hasAlarm := false
+	for _, pt := range points {
+		hasAlarm = hasAlarm || pt.Alarm != nil
+	}
+	var fields []string
+	header := make([]interface{}, 0, len(columns)+len(keys))
+	for _, c := range columns {
+		if c.field == fieldIgnore || (!hasAlarm && strings.HasPrefix(c.field, "alarm.")) {
+			continue
+		}
+		fields = append(fields, c.field)
+		header = append(header, c.headers[0])
+	}
+	for _, k := range keys {
+		header = append(header, paramsPrefix+k)
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	for i, pt := range points {
+		row := make([]interface{}, 0, len(header))
+		for _, f := range fields {
+			row = append(row, cellOf(pt, f))
+		}
+		params := exportParams(pt)
+		for _, k := range keys {
+			row = append(row, params[k])
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	style, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	last, _ := excelize.ColumnNumberToName(len(header))
+	if err := f.SetCellStyle(sheet, "A1", last+"1", style); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(sheet, "A", last, 14); err != nil {
+		return err
+	}
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	return f.Write(w)
+}
+
+// ExportFile 导出到 .xlsx 文件
+func ExportFile(path, sheet string, points []device.PointConfig) error {
+	var buf bytes.Buffer
+	if err := ExportXLSX(&buf, sheet, points); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// exportParams 需要单独成列的点位参数
+func exportParams(pt device.PointConfig) map[string]interface{} {
+	out := make(map[string]interface{}, len(pt.Params))
+	for k, v := range pt.Params {
+		if pt.FuncCode != "" && derivedParam(pt, k, v) {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// derivedParam 导入时会由功能码/地址/数量重新生成的参数
+func derivedParam(pt device.PointConfig, k string, v interface{}) bool {
+	n, isNum := v.(float64)
+	switch k {
+	case "func":
+		return v == pt.FuncCode
+	case "address":
+		return isNum && n == float64(pt.RegAddr)
+	case "quantity":
+		return isNum && n == float64(pt.RegNum)
+	}
+	return false
+}
+
+// cellOf 导出单元格的值，未设置的留空
+func cellOf(pt device.PointConfig, field string) interface{} {
+	a := pt.Alarm
+	if a == nil {
+		a = &device.AlarmConfig{}
+	}
+	switch field {
+	case fieldName:
+		return pt.Name
+	case fieldDesc:
+		return pt.Desc
+	case fieldFuncCode:
+		return pt.FuncCode
+	case fieldRegAddr:
+		return optional(pt.RegAddr, pt.FuncCode != "" || pt.RegAddr != 0)
+	case fieldRegNum:
+		return optional(pt.RegNum, pt.RegNum != 0)
+	case fieldDataType:
+		return pt.DataType
+	case fieldByteOrder:
+		return pt.ByteOrder
+	case fieldSwapReg:
+		return boolCell(pt.SwapReg)
+	case fieldRw:
+		return pt.Rw
+	case fieldUnit:
+		return pt.Unit
+	case fieldScale:
+		return optional(pt.Scale, pt.Scale != 0)
+	case fieldAlarmOn:
+		return boolCell(a.Enable)
+	case fieldAlarmHigh:
+		if a.HighLimit != nil {
+			return *a.HighLimit
+		}
+	case fieldAlarmLow:
+		if a.LowLimit != nil {
+			return *a.LowLimit
+		}
+	}
+	return nil
+}
+
+func optional(v interface{}, set bool) interface{} {
+	if !set {
+		return nil
+	}
+	return v
+}
+
+func boolCell(b bool) interface{} {
+	if b {
+		return "是"
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package pointtable
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cycV2/internal/device"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// buildXLSX 按行生成单工作表的 xlsx
+func buildXLSX(t *testing.T, sheet string, rows [][]interface{}) *bytes.Buffer {
+	t.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestImportXLSX(t *testing.T) {
+	buf := buildXLSX(t, "BMS", [][]interface{}{
+		{"名称", "描述", "功能码", "地址（十进制）", "数量", "数据类型", "字交换", "读写", "单位", "系数", "备注", "params.slaveId"},
+		{"volt", "电池电压", "保持寄存器", 0, nil, "float", "是", "只读", "V", 0.1, "x", nil},
+		{},
+		{"soc", "SOC", "03", 2, 1, "uint16", "否", "读写", "%", nil, nil, 2},
+		{"run", "运行", "线圈", "0x10", nil, "bool", nil, "rw", nil, nil, nil, nil},
+	})
+	points, err := ImportXLSX(buf, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []device.PointConfig{
+		{Name: "volt", Desc: "电池电压", FuncCode: "hr", RegAddr: 0, RegNum: 2, DataType: "float32", SwapReg: true, Rw: "r", Unit: "V", Scale: 0.1,
+			Params: map[string]interface{}{"func": "hr", "address": float64(0), "quantity": float64(2)}},
+		{Name: "soc", Desc: "SOC", FuncCode: "hr", RegAddr: 2, RegNum: 1, DataType: "uint16", Rw: "rw", Unit: "%",
+			Params: map[string]interface{}{"func": "hr", "address": float64(2), "quantity": float64(1), "slaveId": float64(2)}},
+		{Name: "run", Desc: "运行", FuncCode: "co", RegAddr: 16, RegNum: 1, DataType: "bool", Rw: "rw",
+			Params: map[string]interface{}{"func": "co", "address": float64(16), "quantity": float64(1)}},
+	}
+	if !reflect.DeepEqual(points, want) {
+		t.Errorf("points:\n got %+v\nwant %+v", points, want)
+	}
+}
+
+func TestImportErrorsByCell(t *testing.T) {
+	buf := buildXLSX(t, "点表", [][]interface{}{
+		{"名称", "功能码", "寄存器地址", "数据类型", "读写", "系数"},
+		{"a", "hr", 70000, "float32", "r", nil},
+		{"b", "xx", 1, "float128", "读", "abc"},
+		{"", "hr", 2, nil, nil, nil},
+		{"a", "ir", 3, nil, nil, nil},
+	})
+	_, err := ImportXLSX(buf, Options{})
+	var ie *ImportError
+	if !errors.As(err, &ie) {
+		t.Fatalf("err = %v", err)
+	}
+	var got []string
+	for _, e := range ie.Errors {
+		got = append(got, e.Cell+" "+e.Field)
+	}
+	want := "点表!C2 regAddr,点表!B3 funcCode,点表!D3 dataType,点表!F3 scale,点表!A4 name,点表!A5 name"
+	if strings.Join(got, ",") != want {
+		t.Errorf("errors:\n got %s\nwant %s\n%v", strings.Join(got, ","), want, err)
+	}
+
+	for _, tc := range []struct {
+		header []interface{}
+		want   string
+	}{
+		{[]interface{}{"名称", "颜色"}, "点表!B1: unknown column \"颜色\""},
+		{[]interface{}{"名称", "name"}, "点表!B1: name: column \"name\" maps to the same field as 点表!A1"},
+		{[]interface{}{"描述"}, "点表!A1: name: missing point name column"},
+	} {
+		_, err := ImportXLSX(buildXLSX(t, "点表", [][]interface{}{tc.header}), Options{})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("header %v: err = %v, want %q", tc.header, err, tc.want)
+		}
+	}
+	if _, err := ImportXLSX(buildXLSX(t, "点表", [][]interface{}{{"名称"}}), Options{Sheet: "BMS"}); err == nil {
+		t.Error("missing sheet imported")
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	csv := "点名,描述,单位,倍率,通道\ncell1,单体电压1,mV,1,3\ncell2,单体电压2,mV,,4\n"
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"utf8-bom": "\xef\xbb\xbf" + csv, "gbk": gbk} {
+		points, err := ImportCSV(strings.NewReader(data), Options{Columns: map[string]string{"通道": "params.channel"}})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(points) != 2 || points[0].Desc != "单体电压1" || points[0].Scale != 1 || points[1].Scale != 0 ||
+			points[1].Params["channel"] != float64(4) || points[0].FuncCode != "" || points[1].Params["quantity"] != nil {
+			t.Errorf("%s: %+v", name, points)
+		}
+	}
+	_, err = ImportCSV(strings.NewReader("name,scale\np1,0\n"), Options{})
+	if err == nil || !strings.Contains(err.Error(), "B2: scale:") {
+		t.Errorf("csv error = %v", err)
+	}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	high := 60.0
+	points := []device.PointConfig{
+		{Name: "volt", Desc: "电池电压", FuncCode: "hr", RegAddr: 0, RegNum: 2, DataType: "float32", SwapReg: true, ByteOrder: "little",
+			Rw: "r", Unit: "V", Scale: 0.1, Params: map[string]interface{}{"func": "hr", "address": float64(0), "quantity": float64(2)},
+			Alarm: &device.AlarmConfig{Enable: true, HighLimit: &high}},
+		{Name: "mode", FuncCode: "hr", RegAddr: 100, RegNum: 1, DataType: "uint16", Rw: "rw",
+			Params: map[string]interface{}{"func": "hr", "address": float64(100), "quantity": float64(1), "slaveId": float64(3), "tag": "ctl"}},
+	}
+	var buf bytes.Buffer
+	if err := ExportXLSX(&buf, "", points); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := f.GetRows("点表")
+	f.Close()
+	if got := strings.Join(header[0], ","); got != "名称,描述,功能码,寄存器地址,寄存器数量,数据类型,字节序,字交换,读写,单位,系数,告警使能,告警上限,告警下限,params.slaveId,params.tag" {
+		t.Errorf("header = %s", got)
+	}
+	back, err := ImportXLSX(&buf, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, points) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", back, points)
+	}
+}
@@ -10,5 +10,11 @@ type ProtocolAdapter interface {
 	WriteModbus(funcCode string, addr uint16, value []byte) error
 	//Write(funcCode string, slaveId uint8, addr uint16, value []byte) error
 
-	// 支持订阅/推送型协议也可增加回调注册等
+	// 订阅/推送型协议另外实现 Subscriber 接口，见 subscribe.go
+}
+
+// MultiReader 可在一次请求中读取多个点位的协议（S7 等）可选实现的接口。
+// 结果与 params 一一对应，errs[i] 非 nil 表示该点位读取失败
+type MultiReader interface {
+	ReadMulti(params []map[string]interface{}) (vals [][]byte, errs []error)
 }
@@ -0,0 +1,175 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// BVLC 功能
+const (
+	bvlcType             = 0x81
+	bvlcForwardedNPDU    = 0x04
+	bvlcOriginalUnicast  = 0x0A
+	bvlcOriginalBroadcst = 0x0B
+)
+
+// APDU 类型
+const (
+	pduConfirmed   = 0x00
+	pduUnconfirmed = 0x10
+	pduSimpleAck   = 0x20
+	pduComplexAck  = 0x30
+	pduSegmentAck  = 0x40
+	pduError       = 0x50
+	pduReject      = 0x60
+	pduAbort       = 0x70
+)
+
+// 服务
+const (
+	svcConfirmedCOVNotification   = 1
+	svcSubscribeCOV               = 5
+	svcReadProperty               = 12
+	svcReadPropertyMultiple       = 14
+	svcWriteProperty              = 15
+	svcIAm                        = 0
+	svcUnconfirmedCOVNotification = 2
+	svcWhoIs                      = 8
+)
+
+// 最大可接受 APDU 编码（1476 字节）
+const maxAPDUCode = 0x05
+
+// Abort 原因
+const (
+	abortBufferOverflow           = 1
+	abortSegmentationNotSupported = 4
+)
+
+// Route 路由目的/源（DNET/DADR），Net 为0表示本地网络
+type Route struct {
+	Net  uint16
+	Addr []byte
+}
+
+// npdu 构造网络层头：版本1，可选目的网络，期望应答位
+func npdu(dst Route, expectReply bool) []byte {
+	ctrl := byte(0)
+	if expectReply {
+		ctrl |= 0x04
+	}
+	if dst.Net == 0 {
+		return []byte{1, ctrl}
+	}
+	b := []byte{1, ctrl | 0x20, byte(dst.Net >> 8), byte(dst.Net), byte(len(dst.Addr))}
+	return append(append(b, dst.Addr...), 255)
+}
+
+// bvlc 加 BVLC 头
+func bvlc(fn byte, npduAPDU []byte) []byte {
+	b := []byte{bvlcType, fn, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], uint16(4+len(npduAPDU)))
+	return append(b, npduAPDU...)
+}
+
+// parseFrame 解析 BVLC+NPDU，返回源路由与 APDU；网络层报文返回 nil APDU
+func parseFrame(b []byte) (src Route, apdu []byte, err error) {
+	if len(b) < 4 || b[0] != bvlcType || int(binary.BigEndian.Uint16(b[2:])) != len(b) {
+		return src, nil, errMalformed
+	}
+	switch b[1] {
+	case bvlcOriginalUnicast, bvlcOriginalBroadcst:
+		b = b[4:]
+	case bvlcForwardedNPDU:
+		if len(b) < 10 {
+			return src, nil, errMalformed
+		}
+		b = b[10:]
+	default:
+		return src, nil, nil
+	}
+	if len(b) < 2 || b[0] != 1 {
+		return src, nil, errMalformed
+	}
+	ctrl := b[1]
+	b = b[2:]
+	if ctrl&0x20 != 0 { // DNET
+		if len(b) < 3 || len(b) < 3+int(b[2]) {
+			return src, nil, errMalformed
+		}
+		b = b[3+int(b[2]):]
+	}
+	if ctrl&0x08 != 0 { // SNET
+		if len(b) < 3 || len(b) < 3+int(b[2]) {
+			return src, nil, errMalformed
+		}
+		src.Net = binary.BigEndian.Uint16(b)
+		src.Addr = append([]byte(nil), b[3:3+int(b[2])]...)
+		b = b[3+int(b[2]):]
+	}
+	if ctrl&0x20 != 0 { // 跳数
+		if len(b) < 1 {
+			return src, nil, errMalformed
+		}
+		b = b[1:]
+	}
+	if ctrl&0x80 != 0 { // 网络层报文
+		return src, nil, nil
+	}
+	return src, b, nil
+}
+
+// ServiceError 设备返回的 Error PDU 或属性访问错误
+type ServiceError struct {
+	Class, Code uint32
+}
+
+var errorClassNames = map[uint32]string{0: "device", 1: "object", 2: "property", 3: "resources", 4: "security", 5: "services", 7: "communication"}
+
+var errorCodeNames = map[uint32]string{
+	9: "invalid-data-type", 31: "unknown-object", 32: "unknown-property", 37: "value-out-of-range",
+	40: "write-access-denied", 42: "invalid-array-index", 45: "optional-functionality-not-supported",
+	50: "property-is-not-an-array", 2: "configuration-in-progress", 25: "operational-problem",
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("bacnet: error %s/%s", nameOf(errorClassNames, e.Class), nameOf(errorCodeNames, e.Code))
+}
+
+// RejectError 请求被拒绝
+type RejectError byte
+
+func (e RejectError) Error() string {
+	return fmt.Sprintf("bacnet: request rejected (reason %d)", byte(e))
+}
+
+// AbortError 事务中止
+type AbortError byte
+
+func (e AbortError) Error() string {
+	return fmt.Sprintf("bacnet: transaction aborted (reason %d)", byte(e))
+}
+
+// parseErrorPDU Error PDU 中的 错误类别/代码（两个应用 Enumerated）
+func parseErrorPDU(b []byte) error {
+	var vals []uint32
+	for len(b) > 0 && len(vals) < 2 {
+		t, rest, err := readTag(b)
+		if err != nil {
+			return errMalformed
+		}
+		b = rest
+		if t.open || t.close || t.context {
+			continue
+		}
+		v, ok := beUint(t.data)
+		if !ok {
+			return errMalformed
+		}
+		vals = append(vals, v)
+	}
+	if len(vals) < 2 {
+		return errMalformed
+	}
+	return &ServiceError{Class: vals[0], Code: vals[1]}
+}
@@ -0,0 +1,855 @@
+package bacnet
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("bacnet", NewBACnetAdapter)
+}
+
+// IAm 发现结果
+type IAm struct {
+	Device       uint32
+	Addr         *net.UDPAddr
+	Route        Route // 经路由器转发时的源网络/地址
+	MaxAPDU      uint32
+	Segmentation uint32
+	Vendor       uint32
+}
+
+type apduResult struct {
+	data []byte
+	err  error
+}
+
+// BACnetAdapter BACnet/IP 客户端，实现 protocol.ProtocolAdapter、protocol.MultiReader 与 protocol.Subscriber。
+// 点位参数 object 为 objectType:instance:property（见 ParsePoint）；Read 返回值的大端字节（见 Value.Bytes），
+// 二值对象的 presentValue/relinquishDefault 为1字节 0/1；COV 订阅推送 presentValue 与 statusFlags
+type BACnetAdapter struct {
+	local         string
+	broadcast     *net.UDPAddr
+	target        *net.UDPAddr // 为空时按 deviceInstance 发现
+	route         Route
+	device        int64 // -1 未配置
+	timeout       time.Duration
+	retries       int
+	rpmMax        int
+	writePriority int
+	covLifetime   time.Duration
+	covConfirmed  bool
+
+	connMu   sync.Mutex // 串行化建链
+	mu       sync.Mutex
+	conn     *net.UDPConn
+	peer     *net.UDPAddr // 实际通信地址
+	invoke   byte
+	pending  map[byte]chan apduResult
+	whois    []chan IAm
+	noRPM    bool // 设备不支持 ReadPropertyMultiple
+	stop     chan struct{}
+	covMu    sync.Mutex
+	covs     map[ObjectID]*covSub
+	nextProc uint32
+	subs     protocol.Subscriptions[string]
+}
+
+// NewBACnetAdapter 工厂函数
+// cfg: address("ip[:47808]"，为空时按 deviceInstance 广播发现), deviceInstance, network/mac(经路由器访问 MS/TP 设备时的 DNET/DADR，mac 为十六进制),
+//
+//	localAddress(默认":0"，接收 COV 通知), broadcast(默认"255.255.255.255:47808"), timeoutMs, retries(默认2),
+//	rpmMaxProperties(单个 ReadPropertyMultiple 的属性数，默认16), writePriority(1-16，默认不带优先级),
+//	covLifetimeSec(默认300，过半时续订), covConfirmed
+func NewBACnetAdapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewBACnetClient(cfg)
+}
+
+// NewBACnetClient 创建客户端，返回具体类型以便发现与属性读写
+func NewBACnetClient(cfg map[string]interface{}) (*BACnetAdapter, error) {
+	a := &BACnetAdapter{
+		local:       ":0",
+		device:      -1,
+		timeout:     3 * time.Second,
+		retries:     2,
+		rpmMax:      16,
+		covLifetime: 300 * time.Second,
+		pending:     make(map[byte]chan apduResult),
+		covs:        make(map[ObjectID]*covSub),
+		nextProc:    1,
+	}
+	if v, ok := toInt(cfg["deviceInstance"]); ok {
+		if v < 0 || v > 0x3FFFFE {
+			return nil, fmt.Errorf("bacnet: invalid deviceInstance %d", v)
+		}
+		a.device = int64(v)
+	}
+	if addr, _ := cfg["address"].(string); addr != "" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "47808")
+		}
+		udp, err := net.ResolveUDPAddr("udp4", addr)
+		if err != nil {
+			return nil, fmt.Errorf("bacnet: address %q: %w", addr, err)
+		}
+		a.target = udp
+	} else if a.device < 0 {
+		return nil, errors.New("bacnet: missing address or deviceInstance")
+	}
+	bcast := "255.255.255.255:47808"
+	if v, ok := cfg["broadcast"].(string); ok && v != "" {
+		bcast = v
+	}
+	var err error
+	if a.broadcast, err = net.ResolveUDPAddr("udp4", bcast); err != nil {
+		return nil, fmt.Errorf("bacnet: broadcast %q: %w", bcast, err)
+	}
+	if v, ok := cfg["localAddress"].(string); ok && v != "" {
+		a.local = v
+	}
+	if v, ok := toInt(cfg["network"]); ok && v > 0 {
+		mac, _ := cfg["mac"].(string)
+		b, err := hexBytes(mac)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("bacnet: invalid mac %q for network %d", mac, v)
+		}
+		a.route = Route{Net: uint16(v), Addr: b}
+	}
+	if v, ok := toInt(cfg["timeoutMs"]); ok && v > 0 {
+		a.timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(cfg["retries"]); ok && v >= 0 {
+		a.retries = v
+	}
+	if v, ok := toInt(cfg["rpmMaxProperties"]); ok && v > 0 {
+		a.rpmMax = v
+	}
+	if v, ok := toInt(cfg["writePriority"]); ok {
+		if v < 0 || v > 16 {
+			return nil, fmt.Errorf("bacnet: writePriority %d out of range 1..16", v)
+		}
+		a.writePriority = v
+	}
+	if v, ok := toInt(cfg["covLifetimeSec"]); ok && v > 0 {
+		a.covLifetime = time.Duration(v) * time.Second
+	}
+	a.covConfirmed, _ = cfg["covConfirmed"].(bool)
+	return a, nil
+}
+
+func hexBytes(s string) ([]byte, error) {
+	s = strings.NewReplacer(":", "", " ", "", "0x", "").Replace(s)
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	b := make([]byte, len(s)/2)
+	for i := range b {
+		v, err := strconv.ParseUint(s[2*i:2*i+2], 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		b[i] = byte(v)
+	}
+	return b, nil
+}
+
+// open 打开本地套接字并启动接收协程
+func (a *BACnetAdapter) open() (*net.UDPConn, error) {
+	a.mu.Lock()
+	if a.conn != nil {
+		conn := a.conn
+		a.mu.Unlock()
+		return conn, nil
+	}
+	a.mu.Unlock()
+	lc := net.ListenConfig{Control: broadcastControl}
+	pc, err := lc.ListenPacket(context.Background(), "udp4", a.local)
+	if err != nil {
+		return nil, fmt.Errorf("bacnet: listen %s: %w", a.local, err)
+	}
+	conn := pc.(*net.UDPConn)
+	a.mu.Lock()
+	a.conn = conn
+	a.stop = make(chan struct{})
+	a.mu.Unlock()
+	go a.recvLoop(conn)
+	go a.renewLoop(a.stop)
+	return conn, nil
+}
+
+// Connect 打开套接字；未配置地址时按 deviceInstance 发现设备
+func (a *BACnetAdapter) Connect() error {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	if _, err := a.open(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	resolved := a.peer != nil
+	a.mu.Unlock()
+	if resolved {
+		return nil
+	}
+	if a.target != nil {
+		a.mu.Lock()
+		a.peer = a.target
+		a.mu.Unlock()
+		return nil
+	}
+	found, err := a.WhoIs(int(a.device), int(a.device), a.timeout)
+	if err != nil {
+		return err
+	}
+	if len(found) == 0 {
+		return fmt.Errorf("bacnet: device %d not found", a.device)
+	}
+	a.mu.Lock()
+	a.peer, a.route = found[0].Addr, found[0].Route
+	a.mu.Unlock()
+	log.Printf("[BACnet] 发现设备 %d 于 %s", a.device, found[0].Addr)
+	return nil
+}
+
+// Disconnect 取消 COV 订阅并关闭套接字
+func (a *BACnetAdapter) Disconnect() error {
+	a.covMu.Lock()
+	covs := a.covs
+	a.covs = make(map[ObjectID]*covSub)
+	a.covMu.Unlock()
+	for obj, s := range covs {
+		a.cancelCOV(obj, s.proc)
+	}
+	a.mu.Lock()
+	conn := a.conn
+	a.conn = nil
+	if a.target == nil {
+		a.peer = nil
+	}
+	if a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+	for id, ch := range a.pending {
+		ch <- apduResult{err: errors.New("bacnet: disconnected")}
+		delete(a.pending, id)
+	}
+	a.mu.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+	return nil
+}
+
+// WhoIs 广播 Who-Is 并在 wait 内收集 I-Am；low/high 为负时不限范围，low==high 时收到即返回
+func (a *BACnetAdapter) WhoIs(low, high int, wait time.Duration) ([]IAm, error) {
+	conn, err := a.open()
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan IAm, 64)
+	a.mu.Lock()
+	a.whois = append(a.whois, ch)
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		for i, c := range a.whois {
+			if c == ch {
+				a.whois = append(a.whois[:i], a.whois[i+1:]...)
+				break
+			}
+		}
+		a.mu.Unlock()
+	}()
+	apdu := []byte{pduUnconfirmed, svcWhoIs}
+	if low >= 0 && high >= low {
+		apdu = append(append(apdu, ctxUnsigned(0, uint32(low))...), ctxUnsigned(1, uint32(high))...)
+	}
+	if _, err := conn.WriteToUDP(bvlc(bvlcOriginalBroadcst, append(npdu(Route{}, false), apdu...)), a.broadcast); err != nil {
+		return nil, fmt.Errorf("bacnet: who-is: %w", err)
+	}
+	var found []IAm
+	seen := make(map[uint32]bool)
+	deadline := time.After(wait)
+	for {
+		select {
+		case iam := <-ch:
+			if seen[iam.Device] || (low >= 0 && (int(iam.Device) < low || int(iam.Device) > high)) {
+				continue
+			}
+			seen[iam.Device] = true
+			found = append(found, iam)
+			if low >= 0 && low == high {
+				return found, nil
+			}
+		case <-deadline:
+			return found, nil
+		}
+	}
+}
+
+func (a *BACnetAdapter) recvLoop(conn *net.UDPConn) {
+	buf := make([]byte, 1600)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[BACnet] 接收失败: %v", err)
+			}
+			return
+		}
+		src, apdu, err := parseFrame(buf[:n])
+		if err != nil || len(apdu) < 2 {
+			continue
+		}
+		apdu = append([]byte(nil), apdu...)
+		switch apdu[0] & 0xF0 {
+		case pduSimpleAck:
+			if len(apdu) >= 3 {
+				a.deliver(from, apdu[1], apduResult{})
+			}
+		case pduComplexAck:
+			if apdu[0]&0x08 != 0 {
+				if len(apdu) >= 2 {
+					a.deliver(from, apdu[1], apduResult{err: errors.New("bacnet: segmented response not supported")})
+				}
+			} else if len(apdu) >= 3 {
+				a.deliver(from, apdu[1], apduResult{data: apdu[3:]})
+			}
+		case pduError:
+			if len(apdu) >= 3 {
+				a.deliver(from, apdu[1], apduResult{err: parseErrorPDU(apdu[3:])})
+			}
+		case pduReject:
+			if len(apdu) >= 3 {
+				a.deliver(from, apdu[1], apduResult{err: RejectError(apdu[2])})
+			}
+		case pduAbort:
+			if len(apdu) >= 3 {
+				a.deliver(from, apdu[1], apduResult{err: AbortError(apdu[2])})
+			}
+		case pduUnconfirmed:
+			switch apdu[1] {
+			case svcIAm:
+				if iam, err := parseIAm(apdu[2:]); err == nil {
+					iam.Addr, iam.Route = from, src
+					a.mu.Lock()
+					for _, ch := range a.whois {
+						select {
+						case ch <- iam:
+						default:
+						}
+					}
+					a.mu.Unlock()
+				}
+			case svcUnconfirmedCOVNotification:
+				a.handleCOV(from, apdu[2:])
+			}
+		case pduConfirmed:
+			if len(apdu) < 4 || apdu[0]&0x08 != 0 {
+				continue
+			}
+			id, svc := apdu[2], apdu[3]
+			resp := []byte{pduReject, id, 9} // unrecognized-service
+			if svc == svcConfirmedCOVNotification {
+				a.handleCOV(from, apdu[4:])
+				resp = []byte{pduSimpleAck, id, svc}
+			}
+			conn.WriteToUDP(bvlc(bvlcOriginalUnicast, append(npdu(src, false), resp...)), from)
+		}
+	}
+}
+
+// deliver 应答交给等待中的请求，只接受目标设备的应答
+func (a *BACnetAdapter) deliver(from *net.UDPAddr, id byte, r apduResult) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.peer == nil || !a.peer.IP.Equal(from.IP) || a.peer.Port != from.Port {
+		return
+	}
+	if ch, ok := a.pending[id]; ok {
+		delete(a.pending, id)
+		ch <- r
+	}
+}
+
+func parseIAm(b []byte) (IAm, error) {
+	var vals []Value
+	for len(b) > 0 && len(vals) < 4 {
+		t, rest, err := readTag(b)
+		if err != nil || t.context || t.open || t.close {
+			return IAm{}, errMalformed
+		}
+		v, err := decodeApp(t)
+		if err != nil {
+			return IAm{}, err
+		}
+		vals = append(vals, v)
+		b = rest
+	}
+	if len(vals) < 4 {
+		return IAm{}, errMalformed
+	}
+	obj, ok1 := vals[0].Value.(ObjectID)
+	maxAPDU, ok2 := vals[1].Value.(uint32)
+	seg, ok3 := vals[2].Value.(uint32)
+	vendor, ok4 := vals[3].Value.(uint32)
+	if !ok1 || !ok2 || !ok3 || !ok4 || obj.Type != 8 {
+		return IAm{}, errMalformed
+	}
+	return IAm{Device: obj.Instance, MaxAPDU: maxAPDU, Segmentation: seg, Vendor: vendor}, nil
+}
+
+// request 发送确认请求并等待应答，超时按 retries 重发
+func (a *BACnetAdapter) request(service byte, body []byte) ([]byte, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		ch := make(chan apduResult, 1)
+		a.mu.Lock()
+		conn, peer, route := a.conn, a.peer, a.route
+		if conn == nil || peer == nil {
+			a.mu.Unlock()
+			return nil, errors.New("bacnet: not connected")
+		}
+		id := a.invoke
+		for i := 0; i < 256; i++ {
+			if _, busy := a.pending[id]; !busy {
+				break
+			}
+			id++
+		}
+		if _, busy := a.pending[id]; busy {
+			a.mu.Unlock()
+			return nil, errors.New("bacnet: too many outstanding requests")
+		}
+		a.invoke = id + 1
+		a.pending[id] = ch
+		a.mu.Unlock()
+
+		apdu := append([]byte{pduConfirmed, maxAPDUCode, id, service}, body...)
+		if _, err := conn.WriteToUDP(bvlc(bvlcOriginalUnicast, append(npdu(route, true), apdu...)), peer); err != nil {
+			a.mu.Lock()
+			delete(a.pending, id)
+			a.mu.Unlock()
+			return nil, err
+		}
+		timer := time.NewTimer(a.timeout)
+		select {
+		case r := <-ch:
+			timer.Stop()
+			return r.data, r.err
+		case <-timer.C:
+		}
+		a.mu.Lock()
+		delete(a.pending, id)
+		a.mu.Unlock()
+		if attempt >= a.retries {
+			return nil, fmt.Errorf("bacnet: service %d timeout after %d attempts", service, attempt+1)
+		}
+	}
+}
+
+// propertyRef 对象+属性+数组下标的编码（ReadProperty/WriteProperty 共用）
+func propertyRef(p Point) []byte {
+	b := append(ctxObjectID(0, p.Object), ctxUnsigned(1, p.Property)...)
+	if p.Index >= 0 {
+		b = append(b, ctxUnsigned(2, uint32(p.Index))...)
+	}
+	return b
+}
+
+// ReadProperty 读属性，数组/列表属性返回多个值
+func (a *BACnetAdapter) ReadProperty(p Point) ([]Value, error) {
+	data, err := a.request(svcReadProperty, propertyRef(p))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	_, b, err := expectCtx(data, 0)
+	if err == nil {
+		_, b, err = expectCtx(b, 1)
+	}
+	if err == nil && peekCtx(b, 2) {
+		_, b, err = expectCtx(b, 2)
+	}
+	if err != nil || !peekOpening(b, 3) {
+		return nil, fmt.Errorf("%s: %w", p, errMalformed)
+	}
+	_, b, _ = readTag(b)
+	vals, _, err := readValues(b, 3)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return vals, nil
+}
+
+// ReadPropertyMultiple 一次读取多个属性；返回与 points 对应的值与属性级错误
+func (a *BACnetAdapter) ReadPropertyMultiple(points []Point) ([][]Value, []error, error) {
+	var body []byte
+	for i := 0; i < len(points); {
+		obj := points[i].Object
+		body = append(append(body, ctxObjectID(0, obj)...), opening(1)...)
+		for ; i < len(points) && points[i].Object == obj; i++ {
+			body = append(body, ctxUnsigned(0, points[i].Property)...)
+			if points[i].Index >= 0 {
+				body = append(body, ctxUnsigned(1, uint32(points[i].Index))...)
+			}
+		}
+		body = append(body, closing(1)...)
+	}
+	data, err := a.request(svcReadPropertyMultiple, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make(map[string]apduValues)
+	for len(data) > 0 {
+		t, b, err := expectCtx(data, 0)
+		if err != nil || len(t.data) != 4 || !peekOpening(b, 1) {
+			return nil, nil, errMalformed
+		}
+		obj := decodeObjectID(binary.BigEndian.Uint32(t.data))
+		_, b, _ = readTag(b)
+		for {
+			if c, rest, err := readTag(b); err == nil && c.close && c.num == 1 {
+				b = rest
+				break
+			}
+			pt, rest, err := expectCtx(b, 2)
+			if err != nil {
+				return nil, nil, errMalformed
+			}
+			prop, _ := beUint(pt.data)
+			p := Point{Object: obj, Property: prop, Index: -1}
+			if peekCtx(rest, 3) {
+				var it tag
+				it, rest, _ = expectCtx(rest, 3)
+				idx, _ := beUint(it.data)
+				p.Index = int(idx)
+			}
+			var r apduValues
+			switch {
+			case peekOpening(rest, 4):
+				_, rest, _ = readTag(rest)
+				r.vals, rest, err = readValues(rest, 4)
+			case peekOpening(rest, 5):
+				_, rest, _ = readTag(rest)
+				var ev []Value
+				ev, rest, err = readValues(rest, 5)
+				if err == nil && len(ev) == 2 {
+					c, _ := ev[0].Value.(uint32)
+					d, _ := ev[1].Value.(uint32)
+					r.err = &ServiceError{Class: c, Code: d}
+				}
+			default:
+				err = errMalformed
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			results[p.String()] = r
+			b = rest
+		}
+		data = b
+	}
+	vals := make([][]Value, len(points))
+	errs := make([]error, len(points))
+	for i, p := range points {
+		r, ok := results[p.String()]
+		switch {
+		case !ok:
+			errs[i] = fmt.Errorf("%s: missing in response", p)
+		case r.err != nil:
+			errs[i] = fmt.Errorf("%s: %w", p, r.err)
+		default:
+			vals[i] = r.vals
+		}
+	}
+	return vals, errs, nil
+}
+
+type apduValues struct {
+	vals []Value
+	err  error
+}
+
+// ReadMulti 实现 protocol.MultiReader：按 rpmMaxProperties 分批 ReadPropertyMultiple，
+// 应答超出设备缓冲时折半重试，设备不支持时退回逐个 ReadProperty
+func (a *BACnetAdapter) ReadMulti(params []map[string]interface{}) ([][]byte, []error) {
+	vals := make([][]byte, len(params))
+	errs := make([]error, len(params))
+	var points []Point
+	var pos []int
+	for i, p := range params {
+		pt, err := pointParam(p)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		points = append(points, pt)
+		pos = append(pos, i)
+	}
+	for start := 0; start < len(points); start += a.rpmMax {
+		end := min(start+a.rpmMax, len(points))
+		a.readBatch(points[start:end], func(j int, v []Value, err error) {
+			i := pos[start+j]
+			if err == nil {
+				vals[i], err = valueBytes(points[start+j], v)
+			}
+			errs[i] = err
+		})
+	}
+	return vals, errs
+}
+
+func (a *BACnetAdapter) readBatch(points []Point, set func(int, []Value, error)) {
+	a.mu.Lock()
+	noRPM := a.noRPM
+	a.mu.Unlock()
+	if !noRPM && len(points) > 1 {
+		vals, errs, err := a.ReadPropertyMultiple(points)
+		var ab AbortError
+		var rj RejectError
+		var se *ServiceError
+		switch {
+		case err == nil:
+			for j := range points {
+				set(j, vals[j], errs[j])
+			}
+			return
+		case errors.As(err, &ab) && (ab == abortBufferOverflow || ab == abortSegmentationNotSupported) && len(points) > 1:
+			half := len(points) / 2
+			a.readBatch(points[:half], set)
+			a.readBatch(points[half:], func(j int, v []Value, err error) { set(half+j, v, err) })
+			return
+		case errors.As(err, &rj) && rj == 9, errors.As(err, &se) && se.Class == 5:
+			log.Printf("[BACnet] 设备不支持 ReadPropertyMultiple，改为逐个读取")
+			a.mu.Lock()
+			a.noRPM = true
+			a.mu.Unlock()
+		default:
+			for j := range points {
+				set(j, nil, err)
+			}
+			return
+		}
+	}
+	for j, p := range points {
+		v, err := a.ReadProperty(p)
+		set(j, v, err)
+	}
+}
+
+// valueBytes 属性值转点位字节：单值见 Value.Bytes，二值对象的 presentValue/relinquishDefault 为1字节，
+// 多值（数组/列表）为 JSON 数组
+func valueBytes(p Point, vals []Value) ([]byte, error) {
+	switch len(vals) {
+	case 0:
+		return nil, fmt.Errorf("%s: empty value", p)
+	case 1:
+		v := vals[0]
+		if isBinary(p.Object.Type) && (p.Property == PropPresentValue || p.Property == PropRelinquishDflt) {
+			if x, ok := v.Value.(uint32); ok {
+				return []byte{byte(x & 1)}, nil
+			}
+		}
+		return v.Bytes(), nil
+	}
+	out := make([]interface{}, len(vals))
+	for i, v := range vals {
+		switch x := v.Value.(type) {
+		case nil, bool, uint32, int32, float64, string:
+			out[i] = x
+		case float32:
+			out[i] = float64(x)
+		default:
+			out[i] = v.String()
+		}
+	}
+	return json.Marshal(out)
+}
+
+func isBinary(t uint16) bool { return t == 3 || t == 4 || t == 5 }
+
+func isAnalog(t uint16) bool { return t <= 2 }
+
+func isMultiState(t uint16) bool { return t == 13 || t == 14 || t == 19 }
+
+// Read 读单个点位，params["object"] 为点位地址
+func (a *BACnetAdapter) Read(params map[string]interface{}) ([]byte, error) {
+	p, err := pointParam(params)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := a.ReadProperty(p)
+	if err != nil {
+		return nil, err
+	}
+	return valueBytes(p, vals)
+}
+
+func (a *BACnetAdapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("bacnet: BatchRead not supported")
+}
+
+// WriteProperty 写属性，priority 为0时不带优先级
+func (a *BACnetAdapter) WriteProperty(p Point, v Value, priority int) error {
+	enc, err := encodeApp(v)
+	if err != nil {
+		return err
+	}
+	body := append(append(append(propertyRef(p), opening(3)...), enc...), closing(3)...)
+	if priority > 0 {
+		body = append(body, ctxUnsigned(4, uint32(priority))...)
+	}
+	if _, err := a.request(svcWriteProperty, body); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	return nil
+}
+
+// Write 写点位，地址取 params["object"]（缺省时用 address 参数）。
+// params: priority(1-16，缺省用 writePriority)，valueType(real|double|unsigned|signed|enumerated|boolean|string|null，
+// 缺省按对象类型：模拟量 real、二值 enumerated、多态 unsigned)，relinquish 为 true 或 data 为空时写 Null 释放该优先级
+func (a *BACnetAdapter) Write(address string, data []byte, params map[string]interface{}) error {
+	if s, ok := params["object"].(string); ok && s != "" {
+		address = s
+	}
+	p, err := ParsePoint(address)
+	if err != nil {
+		return err
+	}
+	prio := a.writePriority
+	if v, ok := toInt(params["priority"]); ok {
+		if v < 1 || v > 16 {
+			return fmt.Errorf("bacnet: priority %d out of range 1..16", v)
+		}
+		prio = v
+	}
+	v, err := writeValue(p, data, params)
+	if err != nil {
+		return err
+	}
+	return a.WriteProperty(p, v, prio)
+}
+
+func writeValue(p Point, data []byte, params map[string]interface{}) (Value, error) {
+	if r, _ := params["relinquish"].(bool); r || len(data) == 0 {
+		return Value{Tag: TagNull}, nil
+	}
+	typ, _ := params["valueType"].(string)
+	if typ == "" {
+		switch {
+		case isAnalog(p.Object.Type):
+			typ = "real"
+		case isBinary(p.Object.Type):
+			typ = "enumerated"
+		case isMultiState(p.Object.Type):
+			typ = "unsigned"
+		default:
+			return Value{}, fmt.Errorf("bacnet: %s needs valueType", p)
+		}
+	}
+	switch strings.ToLower(typ) {
+	case "real", "double":
+		var f float64
+		switch len(data) {
+		case 4:
+			f = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+		case 8:
+			f = math.Float64frombits(binary.BigEndian.Uint64(data))
+		default:
+			return Value{}, fmt.Errorf("bacnet: %s: real needs 4 or 8 bytes, got %d", p, len(data))
+		}
+		if strings.EqualFold(typ, "double") {
+			return Value{Tag: TagDouble, Value: f}, nil
+		}
+		return Value{Tag: TagReal, Value: float32(f)}, nil
+	case "unsigned", "enumerated":
+		if len(data) > 8 {
+			return Value{}, fmt.Errorf("bacnet: %s: integer of %d bytes", p, len(data))
+		}
+		var u uint64
+		for _, c := range data {
+			u = u<<8 | uint64(c)
+		}
+		if u > math.MaxUint32 {
+			return Value{}, fmt.Errorf("bacnet: %s: value %d out of range", p, u)
+		}
+		tag := byte(TagUnsigned)
+		if strings.EqualFold(typ, "enumerated") {
+			tag = TagEnumerated
+		}
+		return Value{Tag: tag, Value: uint32(u)}, nil
+	case "signed":
+		var v int64
+		switch len(data) {
+		case 1:
+			v = int64(int8(data[0]))
+		case 2:
+			v = int64(int16(binary.BigEndian.Uint16(data)))
+		case 4:
+			v = int64(int32(binary.BigEndian.Uint32(data)))
+		case 8:
+			v = int64(binary.BigEndian.Uint64(data))
+		default:
+			return Value{}, fmt.Errorf("bacnet: %s: signed needs 1/2/4/8 bytes", p)
+		}
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return Value{}, fmt.Errorf("bacnet: %s: value %d out of range", p, v)
+		}
+		return Value{Tag: TagSigned, Value: int32(v)}, nil
+	case "boolean", "bool":
+		on := false
+		for _, c := range data {
+			on = on || c != 0
+		}
+		return Value{Tag: TagBoolean, Value: on}, nil
+	case "string":
+		return Value{Tag: TagCharacterString, Value: string(data)}, nil
+	case "null":
+		return Value{Tag: TagNull}, nil
+	}
+	return Value{}, fmt.Errorf("bacnet: unknown valueType %q", typ)
+}
+
+func (a *BACnetAdapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("bacnet: WriteModbus not supported")
+}
+
+func pointParam(params map[string]interface{}) (Point, error) {
+	s, _ := params["object"].(string)
+	if s == "" {
+		return Point{}, errors.New("bacnet: missing object")
+	}
+	return ParsePoint(s)
+}
+
+// toInt 兼容 int/float64/字符串配置
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
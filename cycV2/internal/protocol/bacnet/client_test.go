@@ -0,0 +1,317 @@
+package bacnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+func TestParsePoint(t *testing.T) {
+	for in, want := range map[string]Point{
+		"analogInput:1:presentValue":      {Object: ObjectID{Type: 0, Instance: 1}, Property: 85, Index: -1},
+		"ai:1":                            {Object: ObjectID{Type: 0, Instance: 1}, Property: 85, Index: -1},
+		"binaryOutput:3:priorityArray[8]": {Object: ObjectID{Type: 4, Instance: 3}, Property: 87, Index: 8},
+		"MSV:7:statusFlags":               {Object: ObjectID{Type: 19, Instance: 7}, Property: 111, Index: -1},
+		"2:5:85":                          {Object: ObjectID{Type: 2, Instance: 5}, Property: 85, Index: -1},
+		"device:1234:objectList[0]":       {Object: ObjectID{Type: 8, Instance: 1234}, Property: 76, Index: 0},
+	} {
+		got, err := ParsePoint(in)
+		if err != nil || got != want {
+			t.Errorf("ParsePoint(%q) = %+v, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", "ai", "foo:1", "ai:x", "ai:4194304", "ai:1:bogus", "ai:1:priorityArray[-1]", "ai:1:85:2", "1024:1"} {
+		if p, err := ParsePoint(in); err == nil {
+			t.Errorf("ParsePoint(%q) accepted: %+v", in, p)
+		}
+	}
+	if s := (Point{Object: ObjectID{Type: 4, Instance: 3}, Property: 87, Index: 8}).String(); s != "binaryOutput:3:priorityArray[8]" {
+		t.Errorf("String() = %s", s)
+	}
+}
+
+func newTestClient(t *testing.T, s *stubDevice, extra map[string]interface{}) *BACnetAdapter {
+	t.Helper()
+	cfg := map[string]interface{}{"address": s.addr(), "localAddress": "127.0.0.1:0", "timeoutMs": 1000, "retries": 0}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	a, err := NewBACnetClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Disconnect() })
+	return a
+}
+
+func real32(f float32) []byte { return binary.BigEndian.AppendUint32(nil, math.Float32bits(f)) }
+
+func TestBACnetReadWrite(t *testing.T) {
+	s := newStubDevice(t, 1234)
+	a := newTestClient(t, s, map[string]interface{}{"writePriority": 8})
+	read := func(obj string) []byte {
+		t.Helper()
+		b, err := a.Read(map[string]interface{}{"object": obj})
+		if err != nil {
+			t.Fatalf("read %s: %v", obj, err)
+		}
+		return b
+	}
+	write := func(obj string, data []byte, params map[string]interface{}) {
+		t.Helper()
+		if params == nil {
+			params = map[string]interface{}{}
+		}
+		params["object"] = obj
+		if err := a.Write("ignored", data, params); err != nil {
+			t.Fatalf("write %s: %v", obj, err)
+		}
+	}
+
+	if b := read("ai:1"); !bytes.Equal(b, real32(23.5)) {
+		t.Errorf("ai:1 = % X", b)
+	}
+	if b := read("analogInput:1:objectName"); string(b) != "Cabin Temp" {
+		t.Errorf("objectName = %q", b)
+	}
+	if b := read("ai:1:units"); !bytes.Equal(b, []byte{0, 0, 0, 62}) {
+		t.Errorf("units = % X", b)
+	}
+	if b := read("msv:4"); !bytes.Equal(b, []byte{0, 0, 0, 2}) {
+		t.Errorf("msv:4 = % X", b)
+	}
+	if b := read("device:1234:objectList"); string(b) != `["analogInput:1","analogOutput:2","binaryOutput:3"]` {
+		t.Errorf("objectList = %s", b)
+	}
+	if b := read("device:1234:objectList[0]"); !bytes.Equal(b, []byte{0, 0, 0, 3}) {
+		t.Errorf("objectList[0] = % X", b)
+	}
+
+	// 优先级写入与释放
+	write("ao:2", real32(55), nil)
+	write("ao:2", real32(30), map[string]interface{}{"priority": 12})
+	if b := read("ao:2"); !bytes.Equal(b, real32(55)) {
+		t.Errorf("ao:2 = % X", b)
+	}
+	if b := read("ao:2:priorityArray[8]"); !bytes.Equal(b, real32(55)) {
+		t.Errorf("priorityArray[8] = % X", b)
+	}
+	write("ao:2", nil, map[string]interface{}{"relinquish": true})
+	if b := read("ao:2"); !bytes.Equal(b, real32(30)) {
+		t.Errorf("after relinquish 8: % X", b)
+	}
+	write("ao:2", nil, map[string]interface{}{"priority": 12})
+	if b := read("ao:2"); !bytes.Equal(b, real32(20)) {
+		t.Errorf("after relinquish 12: % X", b)
+	}
+	write("bo:3", []byte{1}, nil)
+	if b := read("binaryOutput:3"); !bytes.Equal(b, []byte{1}) {
+		t.Errorf("bo:3 = % X", b)
+	}
+
+	// 设备错误与参数校验
+	var se *ServiceError
+	if err := a.Write("ai:1", real32(1), nil); !errors.As(err, &se) || se.Code != 40 {
+		t.Errorf("write ai:1 = %v", err)
+	}
+	if _, err := a.Read(map[string]interface{}{"object": "ai:1:highLimit"}); !errors.As(err, &se) || se.Code != 32 {
+		t.Errorf("unknown property = %v", err)
+	}
+	if _, err := a.Read(map[string]interface{}{"object": "ai:99"}); !errors.As(err, &se) || se.Code != 31 {
+		t.Errorf("unknown object = %v", err)
+	}
+	if err := a.Write("ao:2", real32(1), map[string]interface{}{"priority": 17}); err == nil {
+		t.Error("priority 17 accepted")
+	}
+	if err := a.Write("ao:2", []byte{1, 2}, nil); err == nil {
+		t.Error("2-byte real accepted")
+	}
+	if err := a.Write("bo:3", []byte{1}, map[string]interface{}{"valueType": "real"}); err == nil {
+		t.Error("short real accepted")
+	}
+	if err := a.Write("bo:3", real32(1), map[string]interface{}{"valueType": "real"}); !errors.As(err, &se) || se.Code != 9 {
+		t.Errorf("wrong datatype = %v", err)
+	}
+}
+
+func TestBACnetReadMulti(t *testing.T) {
+	s := newStubDevice(t, 1234)
+	a := newTestClient(t, s, nil)
+	objs := []string{"ai:1", "ai:1:objectName", "ai:1:units", "ao:2", "bo:3", "msv:4", "ai:1:highLimit", "bogus", "ai:99", "ao:2:priorityArray[0]"}
+	params := make([]map[string]interface{}, len(objs))
+	for i, o := range objs {
+		params[i] = map[string]interface{}{"object": o}
+	}
+	check := func(name string) {
+		t.Helper()
+		vals, errs := a.ReadMulti(params)
+		want := [][]byte{real32(23.5), []byte("Cabin Temp"), {0, 0, 0, 62}, real32(20), {0}, {0, 0, 0, 2}}
+		for i, w := range want {
+			if errs[i] != nil || !bytes.Equal(vals[i], w) {
+				t.Errorf("%s: %s = % X, %v", name, objs[i], vals[i], errs[i])
+			}
+		}
+		if errs[6] == nil || errs[7] == nil || errs[8] == nil {
+			t.Errorf("%s: errors = %v", name, errs[6:9])
+		}
+		if errs[9] != nil || !bytes.Equal(vals[9], []byte{0, 0, 0, 16}) {
+			t.Errorf("%s: priorityArray[0] = % X, %v", name, vals[9], errs[9])
+		}
+	}
+
+	check("rpm")
+	if rp, rpm, _ := s.counts(); rp != 0 || rpm != 1 {
+		t.Errorf("rp=%d rpm=%d, want single RPM", rp, rpm)
+	}
+
+	// 应答超出设备缓冲：折半重试
+	s.mu.Lock()
+	s.maxResp, s.rpm = 60, 0
+	s.mu.Unlock()
+	check("split")
+	if _, rpm, _ := s.counts(); rpm < 3 {
+		t.Errorf("split: rpm=%d", rpm)
+	}
+
+	// 不支持 RPM：逐个 ReadProperty
+	s.mu.Lock()
+	s.noRPM, s.rp = true, 0
+	s.mu.Unlock()
+	check("fallback")
+	if rp, _, _ := s.counts(); rp != 9 {
+		t.Errorf("fallback: rp=%d, want 9", rp)
+	}
+}
+
+func TestBACnetCOV(t *testing.T) {
+	s := newStubDevice(t, 1234)
+	a := newTestClient(t, s, map[string]interface{}{"covConfirmed": true})
+	updates := make(chan string, 16)
+	sub := func(obj string) func() {
+		t.Helper()
+		cancel, err := a.Subscribe(map[string]interface{}{"object": obj}, func(u protocol.PointUpdate) {
+			updates <- fmt.Sprintf("%s=% X", obj, u.Bytes)
+		})
+		if err != nil {
+			t.Fatalf("subscribe %s: %v", obj, err)
+		}
+		return cancel
+	}
+	next := func(want string) {
+		t.Helper()
+		select {
+		case got := <-updates:
+			if got != want {
+				t.Errorf("update %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no update, want %q", want)
+		}
+	}
+
+	cancelPV := sub("ao:2")
+	next("ao:2=" + fmt.Sprintf("% X", real32(20))) // 订阅后的初始通知
+	cancelFlags := sub("analogOutput:2:statusFlags")
+	if err := a.Write("ao:2", real32(42.5), map[string]interface{}{"priority": 10}); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case u := <-updates:
+			got[u] = true
+		case <-time.After(2 * time.Second):
+			t.Fatal("missing COV notification")
+		}
+	}
+	if !got["ao:2="+fmt.Sprintf("% X", real32(42.5))] || !got["analogOutput:2:statusFlags=00"] {
+		t.Errorf("updates = %v", got)
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, _, acks := s.counts(); acks >= 2 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Errorf("confirmed notifications acked %d times", acks)
+			break
+		}
+	}
+	s.mu.Lock()
+	if n := len(s.covs[ObjectID{Type: 1, Instance: 2}]); n != 1 {
+		t.Errorf("%d device subscriptions for ao:2, want 1 shared", n)
+	}
+	s.mu.Unlock()
+
+	// 最后一个点位取消后设备端订阅也取消
+	cancelPV()
+	cancelFlags()
+	s.mu.Lock()
+	n := len(s.covs[ObjectID{Type: 1, Instance: 2}])
+	s.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d device subscriptions after cancel", n)
+	}
+	a.Write("ao:2", real32(1), map[string]interface{}{"priority": 10})
+	select {
+	case u := <-updates:
+		t.Errorf("update after cancel: %s", u)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if _, err := a.Subscribe(map[string]interface{}{"object": "ai:1:objectName"}, func(protocol.PointUpdate) {}); err == nil {
+		t.Error("objectName subscription accepted")
+	}
+	if _, err := a.Subscribe(map[string]interface{}{"object": "ai:99"}, func(protocol.PointUpdate) {}); err == nil {
+		t.Error("unknown object subscription accepted")
+	}
+}
+
+func TestBACnetDiscovery(t *testing.T) {
+	s := newStubDevice(t, 1234)
+	a, err := NewBACnetClient(map[string]interface{}{
+		"deviceInstance": 1234, "broadcast": s.addr(), "localAddress": "127.0.0.1:0", "timeoutMs": 500,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Disconnect()
+	if err := a.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := a.Read(map[string]interface{}{"object": "ai:1"}); err != nil || !bytes.Equal(b, real32(23.5)) {
+		t.Errorf("read after discovery = % X, %v", b, err)
+	}
+	found, err := a.WhoIs(-1, -1, 200*time.Millisecond)
+	if err != nil || len(found) != 1 || found[0].Device != 1234 || found[0].Vendor != 999 || found[0].MaxAPDU != 1476 {
+		t.Errorf("WhoIs = %+v, %v", found, err)
+	}
+	if found, _ := a.WhoIs(1, 100, 200*time.Millisecond); len(found) != 0 {
+		t.Errorf("out of range WhoIs = %+v", found)
+	}
+
+	missing, _ := NewBACnetClient(map[string]interface{}{
+		"deviceInstance": 4321, "broadcast": s.addr(), "localAddress": "127.0.0.1:0", "timeoutMs": 200,
+	})
+	defer missing.Disconnect()
+	if err := missing.Connect(); err == nil {
+		t.Error("connected to missing device")
+	}
+
+	if _, err := NewBACnetClient(map[string]interface{}{}); err == nil {
+		t.Error("config without address or deviceInstance accepted")
+	}
+	if _, err := NewBACnetClient(map[string]interface{}{"address": "10.0.0.5", "network": 5}); err == nil {
+		t.Error("network without mac accepted")
+	}
+	if _, err := protocol.GetAdapter("bacnet", map[string]interface{}{"address": "10.0.0.5", "network": "5", "mac": "0x1A"}); err != nil {
+		t.Errorf("registry: %v", err)
+	}
+}
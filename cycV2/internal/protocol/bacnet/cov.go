@@ -0,0 +1,185 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// covSub 单个对象的 COV 订阅，同一对象的 presentValue/statusFlags 点位共用
+type covSub struct {
+	proc uint32
+	refs int
+}
+
+// Subscribe 订阅点位 COV：仅支持对象的 presentValue 与 statusFlags（不带下标），
+// 其余点位返回错误由采集管道回退为轮询。订阅按 covLifetimeSec 过半续订，设备重启后自动恢复
+func (a *BACnetAdapter) Subscribe(params map[string]interface{}, handler func(protocol.PointUpdate)) (func(), error) {
+	p, err := pointParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if p.Index >= 0 || (p.Property != PropPresentValue && p.Property != PropStatusFlags) {
+		return nil, fmt.Errorf("bacnet: %s: only presentValue/statusFlags support COV", p)
+	}
+	cancel := a.subs.Add(p.String(), handler)
+
+	a.covMu.Lock()
+	s := a.covs[p.Object]
+	if s == nil {
+		s = &covSub{proc: a.nextProc}
+		a.nextProc++
+		a.covs[p.Object] = s
+	}
+	s.refs++
+	first := s.refs == 1
+	a.covMu.Unlock()
+
+	if first {
+		if err := a.subscribeCOV(p.Object, s.proc); err != nil {
+			cancel()
+			a.release(p.Object, s)
+			return nil, err
+		}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			if a.release(p.Object, s) {
+				a.cancelCOV(p.Object, s.proc)
+			}
+		})
+	}, nil
+}
+
+// release 减少引用，返回是否为最后一个
+func (a *BACnetAdapter) release(obj ObjectID, s *covSub) bool {
+	a.covMu.Lock()
+	defer a.covMu.Unlock()
+	s.refs--
+	if s.refs > 0 || a.covs[obj] != s {
+		return false
+	}
+	delete(a.covs, obj)
+	return true
+}
+
+func (a *BACnetAdapter) subscribeCOV(obj ObjectID, proc uint32) error {
+	body := append(ctxUnsigned(0, proc), ctxObjectID(1, obj)...)
+	body = append(append(body, ctxBool(2, a.covConfirmed)...), ctxUnsigned(3, uint32(a.covLifetime/time.Second))...)
+	if _, err := a.request(svcSubscribeCOV, body); err != nil {
+		return fmt.Errorf("bacnet: subscribe COV %s: %w", obj, err)
+	}
+	return nil
+}
+
+// cancelCOV 不带确认与有效期的 SubscribeCOV 即取消
+func (a *BACnetAdapter) cancelCOV(obj ObjectID, proc uint32) {
+	a.mu.Lock()
+	open := a.conn != nil
+	a.mu.Unlock()
+	if !open {
+		return
+	}
+	if _, err := a.request(svcSubscribeCOV, append(ctxUnsigned(0, proc), ctxObjectID(1, obj)...)); err != nil {
+		log.Printf("[BACnet] 取消 COV 订阅 %s 失败: %v", obj, err)
+	}
+}
+
+// renewLoop 有效期过半时续订全部对象
+func (a *BACnetAdapter) renewLoop(stop chan struct{}) {
+	ticker := time.NewTicker(a.covLifetime / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		a.covMu.Lock()
+		subs := make(map[ObjectID]uint32, len(a.covs))
+		for obj, s := range a.covs {
+			subs[obj] = s.proc
+		}
+		a.covMu.Unlock()
+		for obj, proc := range subs {
+			if err := a.subscribeCOV(obj, proc); err != nil {
+				log.Printf("[BACnet] COV 续订失败: %v", err)
+			}
+		}
+	}
+}
+
+// handleCOV 解析 COV 通知（确认/非确认相同）并按点位发布属性值
+func (a *BACnetAdapter) handleCOV(from *net.UDPAddr, b []byte) {
+	if err := a.parseCOV(b); err != nil {
+		log.Printf("[BACnet] 来自 %s 的 COV 通知无效: %v", from, err)
+	}
+}
+
+func (a *BACnetAdapter) parseCOV(b []byte) error {
+	t, b, err := expectCtx(b, 0)
+	if err != nil {
+		return err
+	}
+	proc, _ := beUint(t.data)
+	if _, b, err = expectCtx(b, 1); err != nil {
+		return err
+	}
+	if t, b, err = expectCtx(b, 2); err != nil || len(t.data) != 4 {
+		return errMalformed
+	}
+	obj := decodeObjectID(binary.BigEndian.Uint32(t.data))
+	if _, b, err = expectCtx(b, 3); err != nil || !peekOpening(b, 4) {
+		return errMalformed
+	}
+	a.covMu.Lock()
+	s := a.covs[obj]
+	a.covMu.Unlock()
+	if s == nil || s.proc != proc {
+		return nil // 已取消或其他进程的订阅
+	}
+	_, b, _ = readTag(b)
+	now := time.Now()
+	for !peekClosing(b, 4) {
+		pt, rest, err := expectCtx(b, 0)
+		if err != nil {
+			return err
+		}
+		prop, _ := beUint(pt.data)
+		p := Point{Object: obj, Property: prop, Index: -1}
+		if peekCtx(rest, 1) {
+			var it tag
+			it, rest, _ = expectCtx(rest, 1)
+			idx, _ := beUint(it.data)
+			p.Index = int(idx)
+		}
+		if !peekOpening(rest, 2) {
+			return errMalformed
+		}
+		_, rest, _ = readTag(rest)
+		vals, rest, err := readValues(rest, 2)
+		if err != nil {
+			return err
+		}
+		if peekCtx(rest, 3) {
+			_, rest, _ = expectCtx(rest, 3)
+		}
+		b = rest
+		u := protocol.PointUpdate{Timestamp: now}
+		u.Bytes, u.Err = valueBytes(p, vals)
+		a.subs.Publish(p.String(), u)
+	}
+	return nil
+}
+
+func peekClosing(b []byte, num byte) bool {
+	t, _, err := readTag(b)
+	return err == nil && t.close && t.num == num
+}
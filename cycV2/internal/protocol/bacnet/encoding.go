@@ -0,0 +1,577 @@
+// Package bacnet 纯Go实现的 BACnet/IP 客户端子集：Who-Is/I-Am 发现、ReadProperty、
+// ReadPropertyMultiple、带优先级的 WriteProperty 与 COV 订阅
+package bacnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 应用标签
+const (
+	TagNull            = 0
+	TagBoolean         = 1
+	TagUnsigned        = 2
+	TagSigned          = 3
+	TagReal            = 4
+	TagDouble          = 5
+	TagOctetString     = 6
+	TagCharacterString = 7
+	TagBitString       = 8
+	TagEnumerated      = 9
+	TagDate            = 10
+	TagTime            = 11
+	TagObjectID        = 12
+	tagConstructed     = 0xFF // 上下文标签或嵌套结构，保留原始编码
+)
+
+var errMalformed = errors.New("bacnet: malformed APDU")
+
+// ObjectID 对象标识：类型(10位)+实例号(22位)
+type ObjectID struct {
+	Type     uint16
+	Instance uint32
+}
+
+func (o ObjectID) encode() uint32 { return uint32(o.Type)<<22 | o.Instance&0x3FFFFF }
+
+func decodeObjectID(v uint32) ObjectID {
+	return ObjectID{Type: uint16(v >> 22), Instance: v & 0x3FFFFF}
+}
+
+func (o ObjectID) String() string {
+	return fmt.Sprintf("%s:%d", nameOf(objectTypeNames, uint32(o.Type)), o.Instance)
+}
+
+// Value 应用标签编码的值。Value 字段：nil(Null)、bool、uint32(Unsigned/Enumerated)、int32、float32、float64、
+// []byte(OctetString/BitString/Date/Time/结构原始编码)、string、ObjectID
+type Value struct {
+	Tag   byte
+	Value interface{}
+}
+
+// Bytes 值转大端字节：Unsigned/Enumerated 为 uint32，Signed 为 int32，Real 为 float32，Double 为 float64，
+// Boolean 为1字节，CharacterString 为 UTF-8，BitString 为位数据（高位在前），Null 为空
+func (v Value) Bytes() []byte {
+	switch x := v.Value.(type) {
+	case nil:
+		return []byte{}
+	case bool:
+		if x {
+			return []byte{1}
+		}
+		return []byte{0}
+	case uint32:
+		return binary.BigEndian.AppendUint32(nil, x)
+	case int32:
+		return binary.BigEndian.AppendUint32(nil, uint32(x))
+	case float32:
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(x))
+	case float64:
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(x))
+	case []byte:
+		return x
+	case string:
+		return []byte(x)
+	case ObjectID:
+		return binary.BigEndian.AppendUint32(nil, x.encode())
+	}
+	return nil
+}
+
+func (v Value) String() string {
+	switch x := v.Value.(type) {
+	case nil:
+		return "null"
+	case []byte:
+		return fmt.Sprintf("% X", x)
+	}
+	return fmt.Sprint(v.Value)
+}
+
+// 对象类型
+var objectTypeNames = map[uint32]string{
+	0: "analogInput", 1: "analogOutput", 2: "analogValue",
+	3: "binaryInput", 4: "binaryOutput", 5: "binaryValue",
+	6: "calendar", 8: "device", 10: "file", 12: "loop",
+	13: "multiStateInput", 14: "multiStateOutput", 15: "notificationClass",
+	17: "schedule", 19: "multiStateValue", 20: "trendLog",
+}
+
+var objectTypeAliases = map[string]uint32{
+	"ai": 0, "ao": 1, "av": 2, "bi": 3, "bo": 4, "bv": 5, "dev": 8,
+	"msi": 13, "mso": 14, "msv": 19,
+}
+
+// 属性标识
+const (
+	PropObjectList     = 76
+	PropPresentValue   = 85
+	PropPriorityArray  = 87
+	PropStatusFlags    = 111
+	PropObjectName     = 77
+	PropMaxAPDU        = 62
+	PropRelinquishDflt = 104
+)
+
+var propertyNames = map[uint32]string{
+	4: "activeText", 22: "covIncrement", 25: "deadband", 28: "description", 36: "eventState",
+	44: "firmwareRevision", 45: "highLimit", 46: "inactiveText", 59: "lowLimit",
+	62: "maxApduLengthAccepted", 65: "maxPresValue", 69: "minPresValue", 70: "modelName",
+	74: "numberOfStates", 75: "objectIdentifier", 76: "objectList", 77: "objectName",
+	79: "objectType", 81: "outOfService", 85: "presentValue", 87: "priorityArray",
+	103: "reliability", 104: "relinquishDefault", 107: "segmentationSupported",
+	110: "stateText", 111: "statusFlags", 112: "systemStatus", 117: "units",
+	120: "vendorIdentifier", 121: "vendorName",
+}
+
+func nameOf(names map[uint32]string, v uint32) string {
+	if s, ok := names[v]; ok {
+		return s
+	}
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// lookupName 名称（不区分大小写）或数字
+func lookupName(names map[uint32]string, aliases map[string]uint32, s string) (uint32, bool) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n), true
+	}
+	if v, ok := aliases[strings.ToLower(s)]; ok {
+		return v, true
+	}
+	for v, name := range names {
+		if strings.EqualFold(name, s) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Point 点位地址 objectType:instance[:property[index]]，属性缺省为 presentValue
+type Point struct {
+	Object   ObjectID
+	Property uint32
+	Index    int // 数组下标，-1 表示整个属性
+}
+
+// ParsePoint 解析点位，如 analogInput:1:presentValue、ai:1、binaryOutput:3:priorityArray[8]、2:5:85
+func ParsePoint(s string) (Point, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Point{}, fmt.Errorf("bacnet: invalid point %q (objectType:instance:property)", s)
+	}
+	typ, ok := lookupName(objectTypeNames, objectTypeAliases, parts[0])
+	if !ok || typ > 1023 {
+		return Point{}, fmt.Errorf("bacnet: unknown object type %q", parts[0])
+	}
+	inst, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil || inst > 0x3FFFFF {
+		return Point{}, fmt.Errorf("bacnet: invalid instance in %q", s)
+	}
+	p := Point{Object: ObjectID{Type: uint16(typ), Instance: uint32(inst)}, Property: PropPresentValue, Index: -1}
+	if len(parts) == 3 {
+		prop := parts[2]
+		if i := strings.IndexByte(prop, '['); i >= 0 && strings.HasSuffix(prop, "]") {
+			idx, err := strconv.Atoi(prop[i+1 : len(prop)-1])
+			if err != nil || idx < 0 {
+				return Point{}, fmt.Errorf("bacnet: invalid array index in %q", s)
+			}
+			p.Index, prop = idx, prop[:i]
+		}
+		if p.Property, ok = lookupName(propertyNames, nil, prop); !ok {
+			return Point{}, fmt.Errorf("bacnet: unknown property %q", prop)
+		}
+	}
+	return p, nil
+}
+
+func (p Point) String() string {
+	s := p.Object.String() + ":" + nameOf(propertyNames, p.Property)
+	if p.Index >= 0 {
+		s += "[" + strconv.Itoa(p.Index) + "]"
+	}
+	return s
+}
+
+// 编码
+
+func unsignedBytes(v uint32) []byte {
+	switch {
+	case v < 1<<8:
+		return []byte{byte(v)}
+	case v < 1<<16:
+		return []byte{byte(v >> 8), byte(v)}
+	case v < 1<<24:
+		return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func signedBytes(v int32) []byte {
+	switch {
+	case v >= -1<<7 && v < 1<<7:
+		return []byte{byte(v)}
+	case v >= -1<<15 && v < 1<<15:
+		return []byte{byte(v >> 8), byte(v)}
+	case v >= -1<<23 && v < 1<<23:
+		return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+	return binary.BigEndian.AppendUint32(nil, uint32(v))
+}
+
+// tagHeader 标签头：标签号、上下文类、长度/值/类型
+func tagHeader(num byte, context bool, lvt int) []byte {
+	var b []byte
+	first := byte(0)
+	if context {
+		first |= 0x08
+	}
+	if num < 15 {
+		first |= num << 4
+	} else {
+		first |= 0xF0
+	}
+	ext := []byte(nil)
+	if num >= 15 {
+		ext = []byte{num}
+	}
+	switch {
+	case lvt < 5:
+		b = append([]byte{first | byte(lvt)}, ext...)
+	case lvt <= 253:
+		b = append(append([]byte{first | 5}, ext...), byte(lvt))
+	case lvt <= 65535:
+		b = append(append([]byte{first | 5}, ext...), 254, byte(lvt>>8), byte(lvt))
+	default:
+		b = append(append([]byte{first | 5}, ext...), 255)
+		b = binary.BigEndian.AppendUint32(b, uint32(lvt))
+	}
+	return b
+}
+
+func encTag(num byte, context bool, data []byte) []byte {
+	return append(tagHeader(num, context, len(data)), data...)
+}
+
+func ctxUnsigned(num byte, v uint32) []byte { return encTag(num, true, unsignedBytes(v)) }
+
+func ctxObjectID(num byte, o ObjectID) []byte {
+	return encTag(num, true, binary.BigEndian.AppendUint32(nil, o.encode()))
+}
+
+func ctxBool(num byte, v bool) []byte {
+	if v {
+		return encTag(num, true, []byte{1})
+	}
+	return encTag(num, true, []byte{0})
+}
+
+func opening(num byte) []byte {
+	if num < 15 {
+		return []byte{num<<4 | 0x0E}
+	}
+	return []byte{0xFE, num}
+}
+
+func closing(num byte) []byte {
+	if num < 15 {
+		return []byte{num<<4 | 0x0F}
+	}
+	return []byte{0xFF, num}
+}
+
+// encodeApp 应用标签编码
+func encodeApp(v Value) ([]byte, error) {
+	switch v.Tag {
+	case TagNull:
+		return []byte{0x00}, nil
+	case TagBoolean:
+		if b, _ := v.Value.(bool); b {
+			return []byte{0x11}, nil
+		}
+		return []byte{0x10}, nil
+	case TagUnsigned, TagEnumerated:
+		x, ok := v.Value.(uint32)
+		if !ok {
+			break
+		}
+		return encTag(v.Tag, false, unsignedBytes(x)), nil
+	case TagSigned:
+		x, ok := v.Value.(int32)
+		if !ok {
+			break
+		}
+		return encTag(TagSigned, false, signedBytes(x)), nil
+	case TagReal:
+		x, ok := v.Value.(float32)
+		if !ok {
+			break
+		}
+		return encTag(TagReal, false, binary.BigEndian.AppendUint32(nil, math.Float32bits(x))), nil
+	case TagDouble:
+		x, ok := v.Value.(float64)
+		if !ok {
+			break
+		}
+		return encTag(TagDouble, false, binary.BigEndian.AppendUint64(nil, math.Float64bits(x))), nil
+	case TagOctetString:
+		x, ok := v.Value.([]byte)
+		if !ok {
+			break
+		}
+		return encTag(TagOctetString, false, x), nil
+	case TagCharacterString:
+		x, ok := v.Value.(string)
+		if !ok {
+			break
+		}
+		return encTag(TagCharacterString, false, append([]byte{0}, x...)), nil
+	case TagBitString:
+		x, ok := v.Value.([]byte)
+		if !ok {
+			break
+		}
+		return encTag(TagBitString, false, append([]byte{0}, x...)), nil
+	case TagObjectID:
+		x, ok := v.Value.(ObjectID)
+		if !ok {
+			break
+		}
+		return encTag(TagObjectID, false, binary.BigEndian.AppendUint32(nil, x.encode())), nil
+	}
+	return nil, fmt.Errorf("bacnet: cannot encode %T as application tag %d", v.Value, v.Tag)
+}
+
+// 解码
+
+// tag 解码后的标签
+type tag struct {
+	num     byte
+	context bool
+	open    bool
+	close   bool
+	lvt     int // 应用 Boolean 时为值
+	data    []byte
+	raw     []byte // 含标签头的完整编码
+}
+
+func readTag(b []byte) (tag, []byte, error) {
+	if len(b) == 0 {
+		return tag{}, nil, errMalformed
+	}
+	start := b
+	t := tag{num: b[0] >> 4, context: b[0]&0x08 != 0}
+	lvt := int(b[0] & 0x07)
+	b = b[1:]
+	if t.num == 15 {
+		if len(b) == 0 {
+			return tag{}, nil, errMalformed
+		}
+		t.num, b = b[0], b[1:]
+	}
+	if t.context && lvt == 6 {
+		t.open = true
+		t.raw = start[:len(start)-len(b)]
+		return t, b, nil
+	}
+	if t.context && lvt == 7 {
+		t.close = true
+		t.raw = start[:len(start)-len(b)]
+		return t, b, nil
+	}
+	if lvt == 5 {
+		if len(b) == 0 {
+			return tag{}, nil, errMalformed
+		}
+		lvt, b = int(b[0]), b[1:]
+		switch lvt {
+		case 254:
+			if len(b) < 2 {
+				return tag{}, nil, errMalformed
+			}
+			lvt, b = int(binary.BigEndian.Uint16(b)), b[2:]
+		case 255:
+			if len(b) < 4 {
+				return tag{}, nil, errMalformed
+			}
+			lvt, b = int(binary.BigEndian.Uint32(b)), b[4:]
+		}
+	}
+	t.lvt = lvt
+	if !t.context && t.num == TagBoolean {
+		t.raw = start[:len(start)-len(b)]
+		return t, b, nil
+	}
+	if len(b) < lvt {
+		return tag{}, nil, errMalformed
+	}
+	t.data, b = b[:lvt], b[lvt:]
+	t.raw = start[:len(start)-len(b)]
+	return t, b, nil
+}
+
+func beUint(b []byte) (uint32, bool) {
+	if len(b) == 0 || len(b) > 4 {
+		return 0, false
+	}
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v, true
+}
+
+func beInt(b []byte) (int32, bool) {
+	if len(b) == 0 || len(b) > 4 {
+		return 0, false
+	}
+	v := int32(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int32(c)
+	}
+	return v, true
+}
+
+// decodeApp 解码应用标签
+func decodeApp(t tag) (Value, error) {
+	v := Value{Tag: t.num}
+	var ok = true
+	switch t.num {
+	case TagNull:
+	case TagBoolean:
+		v.Value = t.lvt != 0
+	case TagUnsigned, TagEnumerated:
+		v.Value, ok = beUint(t.data)
+	case TagSigned:
+		v.Value, ok = beInt(t.data)
+	case TagReal:
+		if ok = len(t.data) == 4; ok {
+			v.Value = math.Float32frombits(binary.BigEndian.Uint32(t.data))
+		}
+	case TagDouble:
+		if ok = len(t.data) == 8; ok {
+			v.Value = math.Float64frombits(binary.BigEndian.Uint64(t.data))
+		}
+	case TagOctetString, TagDate, TagTime:
+		v.Value = append([]byte(nil), t.data...)
+	case TagCharacterString:
+		v.Value, ok = decodeString(t.data)
+	case TagBitString:
+		if ok = len(t.data) >= 1; ok {
+			v.Value = append([]byte(nil), t.data[1:]...)
+		}
+	case TagObjectID:
+		if ok = len(t.data) == 4; ok {
+			v.Value = decodeObjectID(binary.BigEndian.Uint32(t.data))
+		}
+	default:
+		ok = false
+	}
+	if !ok {
+		return v, fmt.Errorf("bacnet: invalid application tag %d (% X)", t.num, t.data)
+	}
+	return v, nil
+}
+
+// decodeString 字符集：0 UTF-8，4 UCS-2，5 ISO-8859-1
+func decodeString(b []byte) (string, bool) {
+	if len(b) == 0 {
+		return "", false
+	}
+	switch b[0] {
+	case 0:
+		return string(b[1:]), true
+	case 4:
+		if len(b)%2 != 1 {
+			return "", false
+		}
+		u := make([]uint16, 0, len(b)/2)
+		for i := 1; i+1 < len(b); i += 2 {
+			u = append(u, binary.BigEndian.Uint16(b[i:]))
+		}
+		return string(utf16.Decode(u)), true
+	case 5:
+		r := make([]rune, len(b)-1)
+		for i, c := range b[1:] {
+			r[i] = rune(c)
+		}
+		return string(r), true
+	}
+	return "", false
+}
+
+// readValues 读取开标签 num 与闭标签之间的值，返回值列表与闭标签之后的字节；
+// 嵌套结构与上下文标签按原始编码保留
+func readValues(b []byte, num byte) ([]Value, []byte, error) {
+	var vals []Value
+	for {
+		t, rest, err := readTag(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case t.close && t.num == num:
+			return vals, rest, nil
+		case t.open:
+			end, err := skipConstructed(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			vals = append(vals, Value{Tag: tagConstructed, Value: append([]byte(nil), b[:len(b)-len(end)]...)})
+			rest = end
+		case t.context || t.close:
+			vals = append(vals, Value{Tag: tagConstructed, Value: append([]byte(nil), t.raw...)})
+		default:
+			v, err := decodeApp(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			vals = append(vals, v)
+		}
+		b = rest
+	}
+}
+
+// skipConstructed 跳过嵌套结构直到匹配的闭标签
+func skipConstructed(b []byte) ([]byte, error) {
+	depth := 1
+	for depth > 0 {
+		t, rest, err := readTag(b)
+		if err != nil {
+			return nil, err
+		}
+		if t.open {
+			depth++
+		} else if t.close {
+			depth--
+		}
+		b = rest
+	}
+	return b, nil
+}
+
+// expectCtx 读取指定编号的上下文标签
+func expectCtx(b []byte, num byte) (tag, []byte, error) {
+	t, rest, err := readTag(b)
+	if err != nil || !t.context || t.num != num || t.open || t.close {
+		return tag{}, nil, errMalformed
+	}
+	return t, rest, nil
+}
+
+// peekCtx 下一个标签是否为指定编号的上下文标签
+func peekCtx(b []byte, num byte) bool {
+	t, _, err := readTag(b)
+	return err == nil && t.context && t.num == num && !t.open && !t.close
+}
+
+func peekOpening(b []byte, num byte) bool {
+	t, _, err := readTag(b)
+	return err == nil && t.open && t.num == num
+}
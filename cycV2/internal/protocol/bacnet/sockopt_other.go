@@ -0,0 +1,10 @@
+//go:build !unix
+
+package bacnet
+
+import "syscall"
+
+// broadcastControl 非 unix 平台不设置套接字选项，广播发现可能不可用
+func broadcastControl(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package bacnet
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// broadcastControl 允许套接字发送广播（Who-Is）
+func broadcastControl(network, address string, c syscall.RawConn) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,365 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+)
+
+// 测试用 BACnet/IP 设备桩：应答 Who-Is，支持 ReadProperty、ReadPropertyMultiple（可限制应答长度触发 Abort，
+// 或整体拒绝）、带优先级数组的 WriteProperty 与 SubscribeCOV，presentValue 变化时发送确认/非确认 COV 通知
+
+type stubCOV struct {
+	addr      *net.UDPAddr
+	proc      uint32
+	confirmed bool
+}
+
+type stubDevice struct {
+	conn     *net.UDPConn
+	instance uint32
+
+	mu       sync.Mutex
+	props    map[ObjectID]map[uint32][]Value
+	prio     map[ObjectID]*[16]Value // 可命令对象的优先级数组，Tag 为 TagNull 表示空
+	covs     map[ObjectID][]stubCOV
+	maxResp  int  // RPM 应答上限，超出时 Abort(segmentation-not-supported)
+	noRPM    bool // 拒绝 RPM
+	rp, rpm  int  // 请求计数
+	acks     int  // 收到的确认通知应答
+	invokeID byte
+}
+
+func newStubDevice(t *testing.T, instance uint32) *stubDevice {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ai := ObjectID{Type: 0, Instance: 1}
+	ao := ObjectID{Type: 1, Instance: 2}
+	bo := ObjectID{Type: 4, Instance: 3}
+	msv := ObjectID{Type: 19, Instance: 4}
+	s := &stubDevice{
+		conn:     conn,
+		instance: instance,
+		props: map[ObjectID]map[uint32][]Value{
+			{Type: 8, Instance: instance}: {
+				PropObjectName: {{Tag: TagCharacterString, Value: "BMS-HVAC"}},
+				PropObjectList: {{Tag: TagObjectID, Value: ai}, {Tag: TagObjectID, Value: ao}, {Tag: TagObjectID, Value: bo}},
+			},
+			ai: {
+				PropPresentValue: {{Tag: TagReal, Value: float32(23.5)}},
+				PropObjectName:   {{Tag: TagCharacterString, Value: "Cabin Temp"}},
+				PropStatusFlags:  {{Tag: TagBitString, Value: []byte{0x00}}},
+				117:              {{Tag: TagEnumerated, Value: uint32(62)}},
+			},
+			ao:  {PropRelinquishDflt: {{Tag: TagReal, Value: float32(20)}}, PropStatusFlags: {{Tag: TagBitString, Value: []byte{0x00}}}},
+			bo:  {PropRelinquishDflt: {{Tag: TagEnumerated, Value: uint32(0)}}},
+			msv: {PropPresentValue: {{Tag: TagUnsigned, Value: uint32(2)}}},
+		},
+		prio: map[ObjectID]*[16]Value{ao: {}, bo: {}},
+		covs: make(map[ObjectID][]stubCOV),
+	}
+	go s.serve()
+	t.Cleanup(func() { conn.Close() })
+	return s
+}
+
+func (s *stubDevice) addr() string { return s.conn.LocalAddr().String() }
+
+func (s *stubDevice) counts() (rp, rpm, acks int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rp, s.rpm, s.acks
+}
+
+func (s *stubDevice) serve() {
+	buf := make([]byte, 1600)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		_, apdu, err := parseFrame(buf[:n])
+		if err != nil || len(apdu) < 2 {
+			continue
+		}
+		s.mu.Lock()
+		switch apdu[0] & 0xF0 {
+		case pduUnconfirmed:
+			if apdu[1] == svcWhoIs && s.whoIsMatch(apdu[2:]) {
+				iam := []byte{pduUnconfirmed, svcIAm}
+				for _, v := range []Value{
+					{Tag: TagObjectID, Value: ObjectID{Type: 8, Instance: s.instance}},
+					{Tag: TagUnsigned, Value: uint32(1476)},
+					{Tag: TagEnumerated, Value: uint32(3)},
+					{Tag: TagUnsigned, Value: uint32(999)},
+				} {
+					enc, _ := encodeApp(v)
+					iam = append(iam, enc...)
+				}
+				s.send(from, iam)
+			}
+		case pduSimpleAck:
+			s.acks++
+		case pduConfirmed:
+			if len(apdu) >= 4 {
+				s.confirmed(from, apdu[2], apdu[3], apdu[4:])
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *stubDevice) send(to *net.UDPAddr, apdu []byte) {
+	s.conn.WriteToUDP(bvlc(bvlcOriginalUnicast, append(npdu(Route{}, false), apdu...)), to)
+}
+
+func (s *stubDevice) whoIsMatch(b []byte) bool {
+	if len(b) == 0 {
+		return true
+	}
+	lo, rest, err := expectCtx(b, 0)
+	if err != nil {
+		return false
+	}
+	hi, _, err := expectCtx(rest, 1)
+	if err != nil {
+		return false
+	}
+	l, _ := beUint(lo.data)
+	h, _ := beUint(hi.data)
+	return s.instance >= l && s.instance <= h
+}
+
+func (s *stubDevice) confirmed(from *net.UDPAddr, id, svc byte, body []byte) {
+	errPDU := func(class, code uint32) {
+		e := []byte{pduError, id, svc}
+		c, _ := encodeApp(Value{Tag: TagEnumerated, Value: class})
+		d, _ := encodeApp(Value{Tag: TagEnumerated, Value: code})
+		s.send(from, append(append(e, c...), d...))
+	}
+	switch svc {
+	case svcReadProperty:
+		s.rp++
+		p, _, ok := parseRef(body, 0, 1, 2)
+		if !ok {
+			s.send(from, []byte{pduReject, id, 0})
+			return
+		}
+		vals, class, code := s.read(p)
+		if vals == nil {
+			errPDU(class, code)
+			return
+		}
+		resp := append([]byte{pduComplexAck, id, svc}, propertyRef(p)...)
+		s.send(from, append(append(append(resp, opening(3)...), encodeValues(vals)...), closing(3)...))
+	case svcReadPropertyMultiple:
+		if s.noRPM {
+			s.send(from, []byte{pduReject, id, 9})
+			return
+		}
+		s.rpm++
+		resp := []byte{pduComplexAck, id, svc}
+		for len(body) > 0 {
+			t, b, err := expectCtx(body, 0)
+			if err != nil || !peekOpening(b, 1) {
+				s.send(from, []byte{pduReject, id, 0})
+				return
+			}
+			obj := decodeObjectID(binary.BigEndian.Uint32(t.data))
+			resp = append(append(resp, ctxObjectID(0, obj)...), opening(1)...)
+			_, b, _ = readTag(b)
+			for !peekClosing(b, 1) {
+				pt, rest, _ := expectCtx(b, 0)
+				prop, _ := beUint(pt.data)
+				p := Point{Object: obj, Property: prop, Index: -1}
+				resp = append(resp, ctxUnsigned(2, prop)...)
+				if peekCtx(rest, 1) {
+					var it tag
+					it, rest, _ = expectCtx(rest, 1)
+					idx, _ := beUint(it.data)
+					p.Index = int(idx)
+					resp = append(resp, ctxUnsigned(3, idx)...)
+				}
+				b = rest
+				if vals, class, code := s.read(p); vals != nil {
+					resp = append(append(append(resp, opening(4)...), encodeValues(vals)...), closing(4)...)
+				} else {
+					c, _ := encodeApp(Value{Tag: TagEnumerated, Value: class})
+					d, _ := encodeApp(Value{Tag: TagEnumerated, Value: code})
+					resp = append(append(append(append(resp, opening(5)...), c...), d...), closing(5)...)
+				}
+			}
+			_, body, _ = readTag(b)
+			resp = append(resp, closing(1)...)
+		}
+		if s.maxResp > 0 && len(resp) > s.maxResp {
+			s.send(from, []byte{pduAbort | 1, id, abortSegmentationNotSupported})
+			return
+		}
+		s.send(from, resp)
+	case svcWriteProperty:
+		p, b, ok := parseRef(body, 0, 1, 2)
+		if !ok || !peekOpening(b, 3) {
+			s.send(from, []byte{pduReject, id, 0})
+			return
+		}
+		_, b, _ = readTag(b)
+		vals, b, err := readValues(b, 3)
+		if err != nil || len(vals) != 1 {
+			s.send(from, []byte{pduReject, id, 0})
+			return
+		}
+		prio := 16
+		if t, _, err := expectCtx(b, 4); err == nil {
+			v, _ := beUint(t.data)
+			prio = int(v)
+		}
+		if class, code, ok := s.write(p, vals[0], prio); !ok {
+			errPDU(class, code)
+			return
+		}
+		s.send(from, []byte{pduSimpleAck, id, svc})
+		s.notify(p.Object)
+	case svcSubscribeCOV:
+		t, b, err := expectCtx(body, 0)
+		if err != nil {
+			return
+		}
+		proc, _ := beUint(t.data)
+		ot, b, err := expectCtx(b, 1)
+		if err != nil {
+			return
+		}
+		obj := decodeObjectID(binary.BigEndian.Uint32(ot.data))
+		if _, ok := s.props[obj]; !ok {
+			errPDU(1, 31)
+			return
+		}
+		subs := s.covs[obj][:0]
+		for _, c := range s.covs[obj] {
+			if c.proc != proc || c.addr.String() != from.String() {
+				subs = append(subs, c)
+			}
+		}
+		if ct, _, err := expectCtx(b, 2); err == nil { // 不带确认参数即取消
+			subs = append(subs, stubCOV{addr: from, proc: proc, confirmed: len(ct.data) == 1 && ct.data[0] == 1})
+		}
+		s.covs[obj] = subs
+		s.send(from, []byte{pduSimpleAck, id, svc})
+		s.notify(obj)
+	default:
+		s.send(from, []byte{pduReject, id, 9})
+	}
+}
+
+// parseRef 解析 对象/属性/可选下标 三个上下文标签
+func parseRef(b []byte, objTag, propTag, idxTag byte) (Point, []byte, bool) {
+	ot, b, err := expectCtx(b, objTag)
+	if err != nil || len(ot.data) != 4 {
+		return Point{}, nil, false
+	}
+	pt, b, err := expectCtx(b, propTag)
+	if err != nil {
+		return Point{}, nil, false
+	}
+	prop, _ := beUint(pt.data)
+	p := Point{Object: decodeObjectID(binary.BigEndian.Uint32(ot.data)), Property: prop, Index: -1}
+	if peekCtx(b, idxTag) {
+		it, rest, _ := expectCtx(b, idxTag)
+		idx, _ := beUint(it.data)
+		p.Index, b = int(idx), rest
+	}
+	return p, b, true
+}
+
+func encodeValues(vals []Value) []byte {
+	var out []byte
+	for _, v := range vals {
+		enc, _ := encodeApp(v)
+		out = append(out, enc...)
+	}
+	return out
+}
+
+// read 返回属性值；失败时返回 nil 与错误类别/代码
+func (s *stubDevice) read(p Point) ([]Value, uint32, uint32) {
+	props, ok := s.props[p.Object]
+	if !ok {
+		return nil, 1, 31
+	}
+	var vals []Value
+	if pa := s.prio[p.Object]; pa != nil && (p.Property == PropPresentValue || p.Property == PropPriorityArray) {
+		if p.Property == PropPriorityArray {
+			vals = pa[:] // 零值即 Null
+		} else {
+			vals = props[PropRelinquishDflt]
+			for _, v := range pa {
+				if v.Tag != TagNull {
+					vals = []Value{v}
+					break
+				}
+			}
+		}
+	} else if vals, ok = props[p.Property]; !ok {
+		return nil, 2, 32
+	}
+	if p.Index >= 0 {
+		switch {
+		case p.Index == 0:
+			return []Value{{Tag: TagUnsigned, Value: uint32(len(vals))}}, 0, 0
+		case p.Index > len(vals):
+			return nil, 2, 42
+		}
+		return []Value{vals[p.Index-1]}, 0, 0
+	}
+	return vals, 0, 0
+}
+
+func (s *stubDevice) write(p Point, v Value, prio int) (uint32, uint32, bool) {
+	props, ok := s.props[p.Object]
+	if !ok {
+		return 1, 31, false
+	}
+	if pa := s.prio[p.Object]; pa != nil && p.Property == PropPresentValue {
+		if prio < 1 || prio > 16 {
+			return 2, 37, false
+		}
+		want := props[PropRelinquishDflt][0].Tag
+		if v.Tag != TagNull && v.Tag != want {
+			return 2, 9, false
+		}
+		pa[prio-1] = v
+		return 0, 0, true
+	}
+	if t := p.Object.Type; t == 0 || t == 3 || t == 13 || p.Property != PropPresentValue { // 输入对象只读
+		return 2, 40, false
+	}
+	props[p.Property] = []Value{v}
+	return 0, 0, true
+}
+
+// notify 向对象的订阅者发送 presentValue 与 statusFlags
+func (s *stubDevice) notify(obj ObjectID) {
+	pv, _, _ := s.read(Point{Object: obj, Property: PropPresentValue, Index: -1})
+	flags := s.props[obj][PropStatusFlags]
+	if flags == nil {
+		flags = []Value{{Tag: TagBitString, Value: []byte{0x00}}}
+	}
+	for _, c := range s.covs[obj] {
+		body := append(ctxUnsigned(0, c.proc), ctxObjectID(1, ObjectID{Type: 8, Instance: s.instance})...)
+		body = append(append(append(body, ctxObjectID(2, obj)...), ctxUnsigned(3, 300)...), opening(4)...)
+		body = append(append(append(append(body, ctxUnsigned(0, PropPresentValue)...), opening(2)...), encodeValues(pv)...), closing(2)...)
+		body = append(append(append(append(body, ctxUnsigned(0, PropStatusFlags)...), opening(2)...), encodeValues(flags)...), closing(2)...)
+		body = append(body, closing(4)...)
+		if c.confirmed {
+			s.invokeID++
+			s.send(c.addr, append([]byte{pduConfirmed, maxAPDUCode, s.invokeID, svcConfirmedCOVNotification}, body...))
+		} else {
+			s.send(c.addr, append([]byte{pduUnconfirmed, svcUnconfirmedCOVNotification}, body...))
+		}
+	}
+}
@@ -1,21 +1,344 @@
 package can
 
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("can", NewCANAdapter)
+}
+
+// cachedFrame 接收缓存，按CAN ID保存最新一帧
+type cachedFrame struct {
+	frame Frame
+	at    time.Time
+}
+
+// FrameListener 原始帧回调，供 J1939/CANopen 等上层协议使用
+type FrameListener func(f Frame)
+
+// CANAdapter 实现 protocol.ProtocolAdapter 接口，基于 SocketCAN 原始帧收发。
+// 接收协程持续收帧并按ID缓存，Read 返回该ID最新一帧的数据
 type CANAdapter struct {
-	// 设备句柄/配置
+	ifname   string
+	filters  []Filter
+	frameID  uint32 // 默认发送帧ID（兼容 config.yaml 的 frame_id）
+	extended bool
+	baudRate int
+	dial     Dialer
+
+	mu        sync.RWMutex
+	sock      Socket
+	cache     map[uint32]cachedFrame
+	listeners []FrameListener
+	subs      protocol.Subscriptions[uint32]
+	opened    bool
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
 }
 
-func NewCANAdapter(params map[string]interface{}) (*CANAdapter, error) {
-	// 初始化CAN接口
-	return &CANAdapter{}, nil
+// NewCANAdapter 工厂函数
+// cfg: interface/address(如 "can0"、"/dev/can0"), frame_id, extended, baudrate, filters
+func NewCANAdapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewCANAdapterWithDialer(cfg, DefaultDialer)
 }
 
-func (c *CANAdapter) Connect() error    { /* The connect logic */ return nil }
-func (c *CANAdapter) Disconnect() error { /* The disconnect logic */ return nil }
-func (c *CANAdapter) Read(address string, params map[string]interface{}) ([]byte, error) {
-	// 具体实现
-	return []byte{}, nil
+// NewCANAdapterWithDialer 使用指定拨号函数创建适配器，测试时注入 MemBus
+func NewCANAdapterWithDialer(cfg map[string]interface{}, dial Dialer) (*CANAdapter, error) {
+	ifname := firstString(cfg, "interface", "address", "addr")
+	if ifname == "" {
+		return nil, errors.New("can: missing interface")
+	}
+	filters, err := parseFilters(cfg["filters"])
+	if err != nil {
+		return nil, err
+	}
+	frameID, _ := parseUint32(cfg["frame_id"])
+	ext, _ := cfg["extended"].(bool)
+	// 波特率仅用于提示，JSON/YAML 解码后可能为 float64/uint64
+	var baud uint32
+	if raw, ok := cfg["baudrate"]; ok && raw != nil {
+		if baud, ok = parseUint32(raw); !ok {
+			return nil, fmt.Errorf("can: invalid baudrate %v", raw)
+		}
+	}
+	return &CANAdapter{
+		ifname:   ifname,
+		filters:  filters,
+		frameID:  frameID,
+		extended: ext || frameID > SFFMask,
+		baudRate: int(baud),
+		dial:     dial,
+		cache:    make(map[uint32]cachedFrame),
+	}, nil
 }
-func (c *CANAdapter) Write(address string, data []byte, params map[string]interface{}) error {
-	// 具体实现
+
+// Connect 打开套接字、设置过滤器并启动接收协程
+func (c *CANAdapter) Connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.opened {
+		return nil
+	}
+	sock, err := c.dial(c.ifname, 200*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	if err := sock.SetFilters(c.filters); err != nil {
+		sock.Close()
+		return fmt.Errorf("can: set filters: %w", err)
+	}
+	if c.baudRate > 0 {
+		log.Printf("[CAN] %s 波特率%d需在系统中通过ip link配置", c.ifname, c.baudRate)
+	}
+	c.sock = sock
+	c.stopCh = make(chan struct{})
+	c.opened = true
+	c.wg.Add(1)
+	go c.recvLoop(sock, c.stopCh)
 	return nil
 }
+
+// Disconnect 停止接收协程并关闭套接字
+func (c *CANAdapter) Disconnect() error {
+	c.mu.Lock()
+	if !c.opened {
+		c.mu.Unlock()
+		return nil
+	}
+	close(c.stopCh)
+	sock := c.sock
+	c.opened = false
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	return sock.Close()
+}
+
+func (c *CANAdapter) recvLoop(sock Socket, stopCh <-chan struct{}) {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		f, err := sock.Recv()
+		if err != nil {
+			if errors.Is(err, ErrTimeout) {
+				continue
+			}
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+			log.Printf("[CAN] %s 接收错误: %v", c.ifname, err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		now := time.Now()
+		key := cacheKey(f.ID, f.Extended)
+		c.mu.Lock()
+		c.cache[key] = cachedFrame{frame: f, at: now}
+		listeners := c.listeners
+		c.mu.Unlock()
+		for _, l := range listeners {
+			l(f)
+		}
+		c.subs.Publish(key, protocol.PointUpdate{Bytes: f.Data, Timestamp: now})
+	}
+}
+
+// AddListener 注册原始帧回调，在接收协程中同步调用，回调内不可阻塞
+func (c *CANAdapter) AddListener(l FrameListener) {
+	c.mu.Lock()
+	c.listeners = append(c.listeners, l)
+	c.mu.Unlock()
+}
+
+// SendFrame 发送一帧原始报文
+func (c *CANAdapter) SendFrame(f Frame) error {
+	if err := c.Connect(); err != nil {
+		return fmt.Errorf("connect failed: %w", err)
+	}
+	c.mu.RLock()
+	sock := c.sock
+	c.mu.RUnlock()
+	return sock.Send(f)
+}
+
+// Latest 返回某ID最新一帧及接收时间
+func (c *CANAdapter) Latest(id uint32, extended bool) (Frame, time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cf, ok := c.cache[cacheKey(id, extended)]
+	return cf.frame, cf.at, ok
+}
+
+// Read 读取缓存中某ID的最新帧数据
+// params: frame_id, extended, offset/length(截取数据域字节), maxAgeMs(超过则视为过期)
+func (c *CANAdapter) Read(params map[string]interface{}) ([]byte, error) {
+	if err := c.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	id, ok := parseUint32(params["frame_id"])
+	if !ok {
+		return nil, errors.New("can: missing frame_id")
+	}
+	ext, _ := params["extended"].(bool)
+	f, at, ok := c.Latest(id, ext || id > SFFMask)
+	if !ok {
+		return nil, fmt.Errorf("can: no frame received for id 0x%X", id)
+	}
+	if maxAge, ok := parseUint32(params["maxAgeMs"]); ok && maxAge > 0 {
+		if time.Since(at) > time.Duration(maxAge)*time.Millisecond {
+			return nil, fmt.Errorf("can: frame 0x%X stale (%v)", id, time.Since(at).Truncate(time.Millisecond))
+		}
+	}
+	return sliceData(f.Data, params)
+}
+
+// sliceData 按 offset/length 截取数据域
+func sliceData(frame []byte, params map[string]interface{}) ([]byte, error) {
+	data := frame
+	offset, _ := parseUint32(params["offset"])
+	if int(offset) > len(data) {
+		return nil, fmt.Errorf("can: offset %d out of frame length %d", offset, len(data))
+	}
+	data = data[offset:]
+	if length, ok := parseUint32(params["length"]); ok && length > 0 {
+		if int(length) > len(data) {
+			return nil, fmt.Errorf("can: length %d out of frame length %d", length, len(frame))
+		}
+		data = data[:length]
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Subscribe 实现 protocol.Subscriber，每收到一帧匹配ID的报文回调一次
+// params 同 Read: frame_id, extended, offset, length
+func (c *CANAdapter) Subscribe(params map[string]interface{}, handler func(protocol.PointUpdate)) (func(), error) {
+	id, ok := parseUint32(params["frame_id"])
+	if !ok {
+		return nil, errors.New("can: missing frame_id")
+	}
+	ext, _ := params["extended"].(bool)
+	if err := c.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	return c.subs.Add(cacheKey(id, ext || id > SFFMask), func(u protocol.PointUpdate) {
+		u.Bytes, u.Err = sliceData(u.Bytes, params)
+		handler(u)
+	}), nil
+}
+
+func (c *CANAdapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("can: BatchRead not supported")
+}
+
+// Write 发送一帧报文，address 为帧ID（支持 "0x123"），为空时取 params["frame_id"] 或默认帧ID
+func (c *CANAdapter) Write(address string, data []byte, params map[string]interface{}) error {
+	id := c.frameID
+	ext := c.extended
+	if address != "" {
+		v, err := strconv.ParseUint(address, 0, 32)
+		if err != nil {
+			return fmt.Errorf("can: invalid frame id %q", address)
+		}
+		id = uint32(v)
+		ext = id > SFFMask
+	} else if v, ok := parseUint32(params["frame_id"]); ok {
+		id = v
+		ext = id > SFFMask
+	}
+	if e, ok := params["extended"].(bool); ok {
+		ext = e
+	}
+	return c.SendFrame(Frame{ID: id, Extended: ext, Data: data})
+}
+
+func (c *CANAdapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("can: WriteModbus not supported")
+}
+
+func cacheKey(id uint32, extended bool) uint32 {
+	if extended {
+		return id | EFFFlag
+	}
+	return id
+}
+
+// ------- 参数类型转换工具 --------
+
+func firstString(cfg map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if v, ok := cfg[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseUint32 兼容 int/float64/uint32 以及 "0x123" 字符串
+func parseUint32(raw interface{}) (uint32, bool) {
+	switch v := raw.(type) {
+	case uint32:
+		return v, true
+	case int:
+		return uint32(v), true
+	case int64:
+		return uint32(v), true
+	case uint64:
+		return uint32(v), true
+	case float64:
+		return uint32(v), true
+	case string:
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 0, 32)
+		if err != nil {
+			return 0, false
+		}
+		return uint32(n), true
+	default:
+		return 0, false
+	}
+}
+
+// parseFilters 支持 [0x100, "0x200", {"id":0x300,"mask":0x7F0,"extended":false}]
+func parseFilters(raw interface{}) ([]Filter, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("can: filters should be a list, got %T", raw)
+	}
+	out := make([]Filter, 0, len(items))
+	for _, it := range items {
+		if m, ok := it.(map[string]interface{}); ok {
+			id, ok := parseUint32(m["id"])
+			if !ok {
+				return nil, fmt.Errorf("can: filter missing id: %v", m)
+			}
+			mask, _ := parseUint32(m["mask"])
+			ext, _ := m["extended"].(bool)
+			out = append(out, Filter{ID: id, Mask: mask, Extended: ext || id > SFFMask})
+			continue
+		}
+		id, ok := parseUint32(it)
+		if !ok {
+			return nil, fmt.Errorf("can: invalid filter %v", it)
+		}
+		out = append(out, Filter{ID: id, Extended: id > SFFMask})
+	}
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package can
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met in 1s")
+}
+
+func TestFrameMarshalRoundTrip(t *testing.T) {
+	in := Frame{ID: 0x18FF50E5, Extended: true, Data: []byte{1, 2, 3}}
+	buf, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != FrameSize || buf[4] != 3 {
+		t.Fatalf("bad encoding: % X", buf)
+	}
+	var out Frame
+	if err := out.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != in.ID || !out.Extended || !bytes.Equal(out.Data, in.Data) {
+		t.Fatalf("round trip mismatch: %v", out)
+	}
+}
+
+func TestCANAdapterReadLatestAndWrite(t *testing.T) {
+	bus := NewMemBus()
+	peer := bus.Open()
+	defer peer.Close()
+
+	a, err := NewCANAdapterWithDialer(map[string]interface{}{
+		"interface": "/dev/can0",
+		"frame_id":  0x123,
+		"filters":   []interface{}{0x100, map[string]interface{}{"id": "0x200", "mask": 0x7F0}},
+	}, bus.Dialer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Disconnect()
+
+	peer.Send(Frame{ID: 0x100, Data: []byte{0x01, 0x02}})
+	peer.Send(Frame{ID: 0x100, Data: []byte{0x0A, 0x0B, 0x0C, 0x0D}})
+	peer.Send(Frame{ID: 0x20F, Data: []byte{0xFF}})
+	peer.Send(Frame{ID: 0x300, Data: []byte{0xEE}}) // 被过滤
+
+	waitFor(t, func() bool {
+		_, _, ok := a.Latest(0x20F, false)
+		return ok
+	})
+	data, err := a.Read(map[string]interface{}{"frame_id": 0x100, "offset": 1, "length": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x0B, 0x0C}) {
+		t.Errorf("expect latest payload slice 0B0C, got % X", data)
+	}
+	if _, err := a.Read(map[string]interface{}{"frame_id": 0x300}); err == nil {
+		t.Error("filtered id should not be cached")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := a.Read(map[string]interface{}{"frame_id": 0x100, "maxAgeMs": 10}); err == nil {
+		t.Error("expect stale error")
+	}
+
+	if err := a.Write("", []byte{0x55}, nil); err != nil {
+		t.Fatal(err)
+	}
+	f, err := peer.Recv()
+	if err != nil || f.ID != 0x123 || f.Data[0] != 0x55 {
+		t.Fatalf("peer got %v %v", f, err)
+	}
+}
+
+func TestCANRegistered(t *testing.T) {
+	if _, err := protocol.GetAdapter("can", map[string]interface{}{"interface": "vcan0"}); err != nil {
+		t.Fatalf("can adapter not registered: %v", err)
+	}
+}
+
+func TestCANBaudrate(t *testing.T) {
+	// JSON 解码为 float64，YAML 可能为 uint64，字符串也可接受
+	for _, raw := range []interface{}{250000, float64(250000), uint64(250000), "250000"} {
+		a, err := NewCANAdapterWithDialer(map[string]interface{}{"interface": "can0", "baudrate": raw}, NewMemBus().Dialer())
+		if err != nil || a.baudRate != 250000 {
+			t.Errorf("baudrate %T %v: got %v, %v", raw, raw, a, err)
+		}
+	}
+	if _, err := NewCANAdapterWithDialer(map[string]interface{}{"interface": "can0", "baudrate": "fast"}, NewMemBus().Dialer()); err == nil {
+		t.Error("invalid baudrate should be rejected")
+	}
+}
+
+// 需要本机存在 vcan0: ip link add dev vcan0 type vcan && ip link set up vcan0
+func TestSocketCANOnVCAN(t *testing.T) {
+	tx, err := DefaultDialer("vcan0", 100*time.Millisecond)
+	if err != nil {
+		t.Skipf("vcan0 unavailable: %v", err)
+	}
+	defer tx.Close()
+	rx, err := DefaultDialer("vcan0", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rx.Close()
+	if err := rx.SetFilters([]Filter{{ID: 0x42}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Send(Frame{ID: 0x42, Data: []byte{9}}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := rx.Recv()
+	if err != nil || f.ID != 0x42 || f.Data[0] != 9 {
+		t.Fatalf("recv %v %v", f, err)
+	}
+}
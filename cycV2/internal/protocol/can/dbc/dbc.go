@@ -0,0 +1,360 @@
+// Package dbc 解析 Vector CAN 数据库(.dbc)文件，并按信号定义解码/编码CAN帧
+package dbc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Database 一个DBC文件的内容
+type Database struct {
+	Messages []*Message
+	byID     map[uint32]*Message
+}
+
+// Message BO_ 报文定义
+type Message struct {
+	ID       uint32 // 不含扩展帧标志位
+	Extended bool
+	Name     string
+	DLC      int
+	Sender   string
+	Signals  []*Signal
+}
+
+// MuxType 多路复用类型
+type MuxType int
+
+const (
+	MuxNone        MuxType = iota
+	MuxSwitch              // M：多路选择信号
+	MuxMultiplexed         // mN：仅在选择信号等于 MuxValue 时有效
+)
+
+// ValueType 信号值类型（SIG_VALTYPE_）
+type ValueType int
+
+const (
+	ValueInteger ValueType = iota
+	ValueFloat32
+	ValueFloat64
+)
+
+// Signal SG_ 信号定义
+type Signal struct {
+	Name       string
+	StartBit   int
+	Length     int
+	Intel      bool // @1 小端(Intel)，@0 大端(Motorola)
+	Signed     bool
+	Factor     float64
+	Offset     float64
+	Min        float64
+	Max        float64
+	Unit       string
+	Receivers  []string
+	Mux        MuxType
+	MuxValue   int
+	ValueType  ValueType
+	ValueTable map[int64]string
+}
+
+// Message 按ID查找报文
+func (db *Database) Message(id uint32) (*Message, bool) {
+	m, ok := db.byID[id]
+	return m, ok
+}
+
+// MessageByName 按名称查找报文
+func (db *Database) MessageByName(name string) (*Message, bool) {
+	for _, m := range db.Messages {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// Signal 按名称查找信号
+func (m *Message) Signal(name string) (*Signal, bool) {
+	for _, s := range m.Signals {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// ParseFile 解析DBC文件
+func ParseFile(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Parse 解析DBC内容，只处理 BO_/SG_/VAL_/SIG_VALTYPE_，其余语句忽略
+func Parse(r io.Reader) (*Database, error) {
+	db := &Database{byID: make(map[uint32]*Message)}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var cur *Message
+	lineNo := 0
+	pending := "" // 跨行语句（VAL_ 等以 ';' 结尾）
+	pendingLine := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		if pending != "" {
+			pending += " " + line
+			if !strings.HasSuffix(line, ";") {
+				continue
+			}
+			line, pending = pending, ""
+		}
+		switch {
+		case strings.HasPrefix(line, "BO_ "):
+			m, err := parseMessage(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			db.Messages = append(db.Messages, m)
+			db.byID[m.ID] = m
+			cur = m
+		case strings.HasPrefix(line, "SG_ "):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: SG_ outside of BO_", lineNo)
+			}
+			s, err := parseSignal(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			cur.Signals = append(cur.Signals, s)
+		case strings.HasPrefix(line, "VAL_ "), strings.HasPrefix(line, "SIG_VALTYPE_ "):
+			if !strings.HasSuffix(line, ";") {
+				pending, pendingLine = line, lineNo
+				continue
+			}
+			if err := db.parseAttachment(line); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		case line == "":
+			cur = nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("line %d: unterminated statement", pendingLine)
+	}
+	return db, nil
+}
+
+// BO_ 2364540158 EEC1: 8 Vector__XXX
+func parseMessage(line string) (*Message, error) {
+	fields := strings.Fields(strings.Replace(line, ":", " : ", 1))
+	if len(fields) < 5 || fields[3] != ":" {
+		return nil, fmt.Errorf("invalid BO_: %q", line)
+	}
+	rawID, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message id %q", fields[1])
+	}
+	dlc, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid dlc %q", fields[4])
+	}
+	m := &Message{
+		ID:       uint32(rawID) & 0x1FFFFFFF,
+		Extended: rawID&0x80000000 != 0,
+		Name:     fields[2],
+		DLC:      dlc,
+	}
+	if len(fields) > 5 {
+		m.Sender = fields[5]
+	}
+	return m, nil
+}
+
+// SG_ EngineSpeed m1 : 24|16@1+ (0.125,0) [0|8031.875] "rpm" Vector__XXX
+func parseSignal(line string) (*Signal, error) {
+	head, body, ok := strings.Cut(strings.TrimPrefix(line, "SG_ "), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid SG_: %q", line)
+	}
+	hf := strings.Fields(head)
+	if len(hf) == 0 {
+		return nil, fmt.Errorf("invalid SG_: %q", line)
+	}
+	s := &Signal{Name: hf[0], Factor: 1}
+	if len(hf) > 1 {
+		switch mux := hf[1]; {
+		case mux == "M":
+			s.Mux = MuxSwitch
+		case strings.HasPrefix(mux, "m"):
+			v, err := strconv.Atoi(strings.TrimSuffix(mux[1:], "M"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid multiplexer %q", mux)
+			}
+			s.Mux, s.MuxValue = MuxMultiplexed, v
+		}
+	}
+
+	body = strings.TrimSpace(body)
+	// 位定义 24|16@1+
+	layout, rest, _ := strings.Cut(body, " ")
+	pos, order, ok := strings.Cut(layout, "@")
+	if !ok || len(order) != 2 {
+		return nil, fmt.Errorf("invalid bit layout %q", layout)
+	}
+	start, length, ok := strings.Cut(pos, "|")
+	if !ok {
+		return nil, fmt.Errorf("invalid bit layout %q", layout)
+	}
+	var err error
+	if s.StartBit, err = strconv.Atoi(start); err != nil {
+		return nil, fmt.Errorf("invalid start bit %q", start)
+	}
+	if s.Length, err = strconv.Atoi(length); err != nil || s.Length <= 0 || s.Length > 64 {
+		return nil, fmt.Errorf("invalid length %q", length)
+	}
+	s.Intel = order[0] == '1'
+	s.Signed = order[1] == '-'
+
+	// (factor,offset)
+	rest = strings.TrimSpace(rest)
+	fo, rest, ok := cutEnclosed(rest, '(', ')')
+	if !ok {
+		return nil, fmt.Errorf("missing (factor,offset) in %q", line)
+	}
+	f, o, _ := strings.Cut(fo, ",")
+	if s.Factor, err = strconv.ParseFloat(strings.TrimSpace(f), 64); err != nil {
+		return nil, fmt.Errorf("invalid factor %q", f)
+	}
+	if s.Offset, err = strconv.ParseFloat(strings.TrimSpace(o), 64); err != nil {
+		return nil, fmt.Errorf("invalid offset %q", o)
+	}
+	// [min|max]
+	mm, rest, ok := cutEnclosed(rest, '[', ']')
+	if !ok {
+		return nil, fmt.Errorf("missing [min|max] in %q", line)
+	}
+	lo, hi, _ := strings.Cut(mm, "|")
+	if s.Min, err = strconv.ParseFloat(strings.TrimSpace(lo), 64); err != nil {
+		return nil, fmt.Errorf("invalid min %q", lo)
+	}
+	if s.Max, err = strconv.ParseFloat(strings.TrimSpace(hi), 64); err != nil {
+		return nil, fmt.Errorf("invalid max %q", hi)
+	}
+	// "unit" receivers
+	unit, rest, ok := cutEnclosed(rest, '"', '"')
+	if ok {
+		s.Unit = unit
+	}
+	for _, r := range strings.Split(rest, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			s.Receivers = append(s.Receivers, r)
+		}
+	}
+	return s, nil
+}
+
+// VAL_ <id> <signal> 0 "Off" 1 "On" ;
+// SIG_VALTYPE_ <id> <signal> : 1;
+func (db *Database) parseAttachment(line string) error {
+	toks, err := tokenize(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+	if err != nil {
+		return err
+	}
+	if len(toks) < 3 {
+		return fmt.Errorf("invalid statement %q", line)
+	}
+	rawID, err := strconv.ParseUint(toks[1], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid message id %q", toks[1])
+	}
+	m, ok := db.byID[uint32(rawID)&0x1FFFFFFF]
+	if !ok {
+		return nil // 引用了不存在的报文，忽略
+	}
+	s, ok := m.Signal(toks[2])
+	if !ok {
+		return nil
+	}
+	switch toks[0] {
+	case "VAL_":
+		pairs := toks[3:]
+		if len(pairs)%2 != 0 {
+			return fmt.Errorf("odd value table for %s", s.Name)
+		}
+		if s.ValueTable == nil {
+			s.ValueTable = make(map[int64]string, len(pairs)/2)
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			v, err := strconv.ParseInt(pairs[i], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid value %q in table of %s", pairs[i], s.Name)
+			}
+			s.ValueTable[v] = pairs[i+1]
+		}
+	case "SIG_VALTYPE_":
+		vt := toks[len(toks)-1]
+		switch vt {
+		case "1":
+			s.ValueType = ValueFloat32
+		case "2":
+			s.ValueType = ValueFloat64
+		}
+	}
+	return nil
+}
+
+// tokenize 按空白切分，保留引号内的字符串为一个token
+func tokenize(s string) ([]string, error) {
+	var out []string
+	for {
+		s = strings.TrimLeft(s, " \t:")
+		if s == "" {
+			return out, nil
+		}
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in %q", s)
+			}
+			out = append(out, s[1:end+1])
+			s = s[end+2:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			return append(out, s), nil
+		}
+		out = append(out, s[:end])
+		s = s[end:]
+	}
+}
+
+// cutEnclosed 取出以 open 开始、close 结束的内容，返回剩余部分
+func cutEnclosed(s string, open, close byte) (inner, rest string, ok bool) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || s[0] != open {
+		return "", s, false
+	}
+	end := strings.IndexByte(s[1:], close)
+	if end < 0 {
+		return "", s, false
+	}
+	return s[1 : end+1], strings.TrimSpace(s[end+2:]), true
+}
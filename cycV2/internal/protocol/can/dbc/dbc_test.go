@@ -0,0 +1,107 @@
+package dbc
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const sampleDBC = `VERSION ""
+
+BU_: PCS BMS
+
+BO_ 2364540158 EEC1: 8 PCS
+ SG_ EngineSpeed : 24|16@1+ (0.125,0) [0|8031.875] "rpm" BMS
+ SG_ TorqueMode : 0|4@1+ (1,0) [0|15] "" BMS
+
+BO_ 256 BMS_Status: 8 BMS
+ SG_ PackVoltage : 7|16@0+ (0.1,0) [0|1000] "V" PCS
+ SG_ PackCurrent : 23|16@0- (0.1,-10) [-3000|3000] "A" PCS
+ SG_ State : 32|3@1+ (1,0) [0|7] "" PCS
+ SG_ CellTemp : 40|8@1- (1,0) [0|0] "degC" PCS
+
+BO_ 512 Mux_Msg: 8 BMS
+ SG_ MuxSel M : 0|8@1+ (1,0) [0|255] "" PCS
+ SG_ CellV1 m0 : 8|16@1+ (0.001,0) [0|5] "V" PCS
+
+BO_ 768 Float_Msg: 8 BMS
+ SG_ SOC : 0|32@1+ (1,0) [0|100] "%" PCS
+
+CM_ SG_ 256 PackVoltage "total voltage";
+VAL_ 256 State 0 "Idle" 1 "Charge"
+  2 "Discharge" 3 "Fault" ;
+SIG_VALTYPE_ 768 SOC : 1;
+`
+
+func TestParseAndDecode(t *testing.T) {
+	db, err := Parse(strings.NewReader(sampleDBC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Messages) != 4 {
+		t.Fatalf("expect 4 messages, got %d", len(db.Messages))
+	}
+	eec1, ok := db.Message(0x0CF004FE)
+	if !ok || !eec1.Extended || eec1.Name != "EEC1" {
+		t.Fatalf("EEC1 not parsed as extended: %+v", eec1)
+	}
+	speed, _ := eec1.Signal("EngineSpeed")
+	// 0x1F40 * 0.125 = 1000rpm，Intel 起始位24
+	v, err := speed.Decode([]byte{0, 0, 0, 0x40, 0x1F, 0, 0, 0})
+	if err != nil || v != 1000 {
+		t.Errorf("EngineSpeed expect 1000, got %v %v", v, err)
+	}
+
+	bms, _ := db.Message(256)
+	volt, _ := bms.Signal("PackVoltage")
+	curr, _ := bms.Signal("PackCurrent")
+	state, _ := bms.Signal("State")
+	temp, _ := bms.Signal("CellTemp")
+	if volt.Intel || volt.Unit != "V" || curr.Signed != true {
+		t.Fatalf("layout flags wrong: %+v %+v", volt, curr)
+	}
+	// Motorola: PackVoltage 0x0FA0=4000 -> 400.0V，PackCurrent 0xFF9C=-100 -> -100*0.1-10=-20A
+	data := []byte{0x0F, 0xA0, 0xFF, 0x9C, 0x02, 0xF6, 0, 0}
+	if v, _ := volt.Decode(data); math.Abs(v-400) > 1e-9 {
+		t.Errorf("PackVoltage expect 400, got %v", v)
+	}
+	if v, _ := curr.Decode(data); math.Abs(v+20) > 1e-9 {
+		t.Errorf("PackCurrent expect -20, got %v", v)
+	}
+	if l, ok := state.Label(data); !ok || l != "Discharge" {
+		t.Errorf("State label expect Discharge, got %q", l)
+	}
+	if v, _ := temp.Decode(data); v != -10 || !temp.InRange(v) {
+		t.Errorf("CellTemp expect -10 with no range limit, got %v", v)
+	}
+
+	mux, _ := db.MessageByName("Mux_Msg")
+	if s, _ := mux.Signal("CellV1"); s.Mux != MuxMultiplexed || s.MuxValue != 0 {
+		t.Errorf("mux not parsed: %+v", s)
+	}
+	soc, _ := db.Message(768)
+	if s, _ := soc.Signal("SOC"); s.ValueType != ValueFloat32 {
+		t.Errorf("SIG_VALTYPE_ not applied")
+	}
+}
+
+func TestEncodeRoundTripAndParams(t *testing.T) {
+	for _, s := range []*Signal{
+		{Name: "intel", StartBit: 3, Length: 12, Intel: true, Signed: true, Factor: 0.5, Offset: 1},
+		{Name: "moto", StartBit: 13, Length: 10, Factor: 2, Offset: -100},
+		{Name: "f32", StartBit: 32, Length: 32, Intel: true, Factor: 1, ValueType: ValueFloat32},
+	} {
+		data := make([]byte, 8)
+		if err := s.Encode(data, 38); err != nil {
+			t.Fatal(err)
+		}
+		restored, err := SignalFromParams(s.Params())
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := restored.Decode(data)
+		if err != nil || v != 38 {
+			t.Errorf("%s: expect 38 after round trip, got %v %v (% X)", s.Name, v, err, data)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package dbc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// RawValue 从帧数据中取出信号的原始值（已做符号扩展），数据不足8字节时按0补齐
+func (s *Signal) RawValue(data []byte) (uint64, error) {
+	var buf [8]byte
+	copy(buf[:], data)
+	if err := s.checkLayout(len(data)); err != nil {
+		return 0, err
+	}
+	var raw uint64
+	if s.Intel {
+		raw = binary.LittleEndian.Uint64(buf[:]) >> uint(s.StartBit)
+	} else {
+		raw = binary.BigEndian.Uint64(buf[:]) >> uint(s.motorolaLSB())
+	}
+	if s.Length < 64 {
+		raw &= (uint64(1) << uint(s.Length)) - 1
+	}
+	return raw, nil
+}
+
+// Decode 解码为物理值：整数信号 raw*factor+offset，浮点信号按 IEEE754 解释后再换算
+func (s *Signal) Decode(data []byte) (float64, error) {
+	raw, err := s.RawValue(data)
+	if err != nil {
+		return 0, err
+	}
+	var v float64
+	switch s.ValueType {
+	case ValueFloat32:
+		v = float64(math.Float32frombits(uint32(raw)))
+	case ValueFloat64:
+		v = math.Float64frombits(raw)
+	default:
+		if s.Signed {
+			v = float64(signExtend(raw, s.Length))
+		} else {
+			v = float64(raw)
+		}
+	}
+	return v*s.Factor + s.Offset, nil
+}
+
+// IntValue 整数原始值（含符号），用于查找值表
+func (s *Signal) IntValue(data []byte) (int64, error) {
+	raw, err := s.RawValue(data)
+	if err != nil {
+		return 0, err
+	}
+	if s.Signed {
+		return signExtend(raw, s.Length), nil
+	}
+	return int64(raw), nil
+}
+
+// Label 按值表返回原始值对应的描述
+func (s *Signal) Label(data []byte) (string, bool) {
+	if len(s.ValueTable) == 0 {
+		return "", false
+	}
+	v, err := s.IntValue(data)
+	if err != nil {
+		return "", false
+	}
+	l, ok := s.ValueTable[v]
+	return l, ok
+}
+
+// InRange 物理值是否在 [Min,Max] 内，Min==Max（通常为0|0）表示不限制
+func (s *Signal) InRange(v float64) bool {
+	if s.Min == s.Max {
+		return true
+	}
+	return v >= s.Min && v <= s.Max
+}
+
+// Encode 将物理值写入帧数据（data 长度需覆盖信号位）
+func (s *Signal) Encode(data []byte, phys float64) error {
+	if err := s.checkLayout(len(data)); err != nil {
+		return err
+	}
+	var raw uint64
+	switch s.ValueType {
+	case ValueFloat32:
+		raw = uint64(math.Float32bits(float32((phys - s.Offset) / s.Factor)))
+	case ValueFloat64:
+		raw = math.Float64bits((phys - s.Offset) / s.Factor)
+	default:
+		raw = uint64(int64(math.Round((phys - s.Offset) / s.Factor)))
+	}
+	mask := ^uint64(0)
+	if s.Length < 64 {
+		mask = (uint64(1) << uint(s.Length)) - 1
+	}
+	raw &= mask
+
+	var buf [8]byte
+	copy(buf[:], data)
+	if s.Intel {
+		u := binary.LittleEndian.Uint64(buf[:])
+		u = u&^(mask<<uint(s.StartBit)) | raw<<uint(s.StartBit)
+		binary.LittleEndian.PutUint64(buf[:], u)
+	} else {
+		shift := uint(s.motorolaLSB())
+		u := binary.BigEndian.Uint64(buf[:])
+		u = u&^(mask<<shift) | raw<<shift
+		binary.BigEndian.PutUint64(buf[:], u)
+	}
+	copy(data, buf[:])
+	return nil
+}
+
+// motorolaLSB Motorola 起始位为MSB（锯齿编号），换算为大端64位整数中LSB的位置
+func (s *Signal) motorolaLSB() int {
+	msb := (7-s.StartBit/8)*8 + s.StartBit%8
+	return msb - s.Length + 1
+}
+
+func (s *Signal) checkLayout(dataLen int) error {
+	if s.Length <= 0 || s.Length > 64 {
+		return fmt.Errorf("dbc: signal %s invalid length %d", s.Name, s.Length)
+	}
+	if s.Intel {
+		if s.StartBit+s.Length > 64 {
+			return fmt.Errorf("dbc: signal %s exceeds 8 bytes", s.Name)
+		}
+		if need := (s.StartBit + s.Length + 7) / 8; need > dataLen {
+			return fmt.Errorf("dbc: signal %s needs %d bytes, frame has %d", s.Name, need, dataLen)
+		}
+		return nil
+	}
+	if s.motorolaLSB() < 0 {
+		return fmt.Errorf("dbc: signal %s exceeds 8 bytes", s.Name)
+	}
+	if need := 8 - s.motorolaLSB()/8; need > dataLen {
+		return fmt.Errorf("dbc: signal %s needs %d bytes, frame has %d", s.Name, need, dataLen)
+	}
+	return nil
+}
+
+func signExtend(raw uint64, length int) int64 {
+	if length >= 64 {
+		return int64(raw)
+	}
+	shift := uint(64 - length)
+	return int64(raw<<shift) >> shift
+}
+
+// ------- 与点位 Params 之间的转换 --------
+
+// Params 将信号定义导出为点位参数，配合 dataType "dbc" 使用
+func (s *Signal) Params() map[string]interface{} {
+	p := map[string]interface{}{
+		"signal":    s.Name,
+		"startBit":  s.StartBit,
+		"bitLength": s.Length,
+		"intel":     s.Intel,
+		"signed":    s.Signed,
+		"factor":    s.Factor,
+		"offset":    s.Offset,
+		"min":       s.Min,
+		"max":       s.Max,
+	}
+	switch s.ValueType {
+	case ValueFloat32:
+		p["valueType"] = "float32"
+	case ValueFloat64:
+		p["valueType"] = "float64"
+	}
+	if s.Unit != "" {
+		p["unit"] = s.Unit
+	}
+	if len(s.ValueTable) > 0 {
+		vt := make(map[string]interface{}, len(s.ValueTable))
+		for k, v := range s.ValueTable {
+			vt[strconv.FormatInt(k, 10)] = v
+		}
+		p["valueTable"] = vt
+	}
+	return p
+}
+
+// SignalFromParams 从点位参数还原信号定义（兼容 json 解码后的 float64）
+func SignalFromParams(p map[string]interface{}) (*Signal, error) {
+	start, ok := toInt(p["startBit"])
+	if !ok {
+		return nil, fmt.Errorf("dbc: missing startBit")
+	}
+	length, ok := toInt(p["bitLength"])
+	if !ok {
+		return nil, fmt.Errorf("dbc: missing bitLength")
+	}
+	s := &Signal{StartBit: start, Length: length, Factor: 1}
+	s.Name, _ = p["signal"].(string)
+	s.Intel, _ = p["intel"].(bool)
+	s.Signed, _ = p["signed"].(bool)
+	if v, ok := toFloat(p["factor"]); ok {
+		s.Factor = v
+	}
+	s.Offset, _ = toFloat(p["offset"])
+	s.Min, _ = toFloat(p["min"])
+	s.Max, _ = toFloat(p["max"])
+	s.Unit, _ = p["unit"].(string)
+	switch p["valueType"] {
+	case "float32":
+		s.ValueType = ValueFloat32
+	case "float64":
+		s.ValueType = ValueFloat64
+	}
+	if vt, ok := p["valueTable"].(map[string]interface{}); ok {
+		s.ValueTable = make(map[int64]string, len(vt))
+		for k, v := range vt {
+			n, err := strconv.ParseInt(k, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("dbc: invalid value table key %q", k)
+			}
+			s.ValueTable[n], _ = v.(string)
+		}
+	}
+	return s, s.checkLayout(8)
+}
+
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+func toFloat(raw interface{}) (float64, bool) {
+	switch v := raw.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
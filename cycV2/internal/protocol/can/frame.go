@@ -0,0 +1,103 @@
+package can
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// SocketCAN can_frame 相关常量
+const (
+	FrameSize = 16 // struct can_frame 固定16字节
+
+	EFFFlag = 0x80000000 // 扩展帧标志
+	RTRFlag = 0x40000000 // 远程帧标志
+	ERRFlag = 0x20000000 // 错误帧标志
+	SFFMask = 0x000007FF // 标准帧ID掩码
+	EFFMask = 0x1FFFFFFF // 扩展帧ID掩码
+)
+
+// Frame 一帧经典CAN报文
+type Frame struct {
+	ID       uint32 // 不含标志位的帧ID
+	Extended bool   // 29位扩展帧
+	RTR      bool   // 远程帧
+	Data     []byte // 数据域，最多8字节
+}
+
+// Filter CAN ID过滤器，(收到的ID & Mask) == (ID & Mask) 时接收
+type Filter struct {
+	ID       uint32
+	Mask     uint32
+	Extended bool
+}
+
+// rawID 带标志位的 can_id
+func (f Frame) rawID() uint32 {
+	id := f.ID
+	if f.Extended {
+		id = (id & EFFMask) | EFFFlag
+	} else {
+		id &= SFFMask
+	}
+	if f.RTR {
+		id |= RTRFlag
+	}
+	return id
+}
+
+// MarshalBinary 按 struct can_frame 编码（主机字节序）
+func (f Frame) MarshalBinary() ([]byte, error) {
+	if len(f.Data) > 8 {
+		return nil, fmt.Errorf("can: data length %d exceeds 8", len(f.Data))
+	}
+	buf := make([]byte, FrameSize)
+	binary.NativeEndian.PutUint32(buf[0:4], f.rawID())
+	buf[4] = byte(len(f.Data))
+	copy(buf[8:], f.Data)
+	return buf, nil
+}
+
+// UnmarshalBinary 解析 struct can_frame
+func (f *Frame) UnmarshalBinary(buf []byte) error {
+	if len(buf) < FrameSize {
+		return fmt.Errorf("can: short frame %d bytes", len(buf))
+	}
+	id := binary.NativeEndian.Uint32(buf[0:4])
+	dlc := int(buf[4])
+	if dlc > 8 {
+		dlc = 8
+	}
+	f.Extended = id&EFFFlag != 0
+	f.RTR = id&RTRFlag != 0
+	if f.Extended {
+		f.ID = id & EFFMask
+	} else {
+		f.ID = id & SFFMask
+	}
+	f.Data = append([]byte(nil), buf[8:8+dlc]...)
+	return nil
+}
+
+// isErrorFrame 是否为错误帧
+func isErrorFrame(buf []byte) bool {
+	return len(buf) >= 4 && binary.NativeEndian.Uint32(buf[0:4])&ERRFlag != 0
+}
+
+// Match 判断帧是否通过过滤器
+func (flt Filter) Match(f Frame) bool {
+	if flt.Extended != f.Extended {
+		return false
+	}
+	mask := flt.Mask
+	if mask == 0 {
+		mask = EFFMask
+	}
+	return f.ID&mask == flt.ID&mask
+}
+
+func (f Frame) String() string {
+	if f.Extended {
+		return fmt.Sprintf("%08X#% X", f.ID, f.Data)
+	}
+	return fmt.Sprintf("%03X#% X", f.ID, f.Data)
+}
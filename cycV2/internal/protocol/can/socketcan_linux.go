@@ -0,0 +1,98 @@
+//go:build linux
+
+package can
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// rawSocket Linux SocketCAN CAN_RAW 套接字
+type rawSocket struct {
+	fd int
+}
+
+// dialSocketCAN 打开并绑定 SocketCAN 网卡，ifname 兼容 "can0" 与 "/dev/can0" 两种写法。
+// 波特率由系统 ip link 配置，套接字层不可设置
+func dialSocketCAN(ifname string, recvTimeout time.Duration) (Socket, error) {
+	ifname = strings.TrimPrefix(ifname, "/dev/")
+	iface, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return nil, fmt.Errorf("can: interface %s: %w", ifname, err)
+	}
+	fd, err := unix.Socket(unix.AF_CAN, unix.SOCK_RAW, unix.CAN_RAW)
+	if err != nil {
+		return nil, fmt.Errorf("can: socket: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrCAN{Ifindex: iface.Index}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("can: bind %s: %w", ifname, err)
+	}
+	if recvTimeout > 0 {
+		tv := unix.NsecToTimeval(recvTimeout.Nanoseconds())
+		if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("can: set recv timeout: %w", err)
+		}
+	}
+	return &rawSocket{fd: fd}, nil
+}
+
+func (s *rawSocket) Send(f Frame) error {
+	buf, err := f.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = unix.Write(s.fd, buf)
+	return err
+}
+
+func (s *rawSocket) Recv() (Frame, error) {
+	buf := make([]byte, FrameSize)
+	for {
+		n, err := unix.Read(s.fd, buf)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EWOULDBLOCK) {
+				return Frame{}, ErrTimeout
+			}
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return Frame{}, err
+		}
+		if n < FrameSize || isErrorFrame(buf) {
+			continue
+		}
+		var f Frame
+		err = f.UnmarshalBinary(buf)
+		return f, err
+	}
+}
+
+func (s *rawSocket) SetFilters(filters []Filter) error {
+	if len(filters) == 0 {
+		return nil
+	}
+	raw := make([]unix.CanFilter, 0, len(filters))
+	for _, flt := range filters {
+		mask := flt.Mask
+		if mask == 0 {
+			mask = EFFMask
+		}
+		if flt.Extended {
+			raw = append(raw, unix.CanFilter{Id: (flt.ID & EFFMask) | EFFFlag, Mask: (mask & EFFMask) | EFFFlag | RTRFlag})
+		} else {
+			raw = append(raw, unix.CanFilter{Id: flt.ID & SFFMask, Mask: (mask & SFFMask) | EFFFlag | RTRFlag})
+		}
+	}
+	return unix.SetsockoptCanRawFilter(s.fd, unix.SOL_CAN_RAW, unix.CAN_RAW_FILTER, raw)
+}
+
+func (s *rawSocket) Close() error {
+	return unix.Close(s.fd)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package can
+
+import (
+	"errors"
+	"time"
+)
+
+func dialSocketCAN(string, time.Duration) (Socket, error) {
+	return nil, errors.New("can: SocketCAN is only supported on linux")
+}
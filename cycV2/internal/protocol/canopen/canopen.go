@@ -0,0 +1,679 @@
+// Package canopen 在 CAN 适配器之上实现 CANopen 主站：SDO 客户端（快速/分段）、NMT、
+// 心跳/节点守护监测以及 RPDO/TPDO 映射，点位按 "index:subindex" 寻址
+package canopen
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+	"cycV2/internal/protocol/can"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("canopen", NewCANopenAdapter)
+}
+
+// NMT 命令
+const (
+	NMTStart          = 0x01
+	NMTStop           = 0x02
+	NMTPreOperational = 0x80
+	NMTResetNode      = 0x81
+	NMTResetComm      = 0x82
+)
+
+// 节点状态（心跳/守护报文中的状态字节）
+const (
+	StateBootUp         = 0x00
+	StateStopped        = 0x04
+	StateOperational    = 0x05
+	StatePreOperational = 0x7F
+	StateUnknown        = 0xFF
+)
+
+// ObjectKey 对象字典条目
+type ObjectKey struct {
+	Node     uint8
+	Index    uint16
+	SubIndex uint8
+}
+
+func (k ObjectKey) String() string {
+	return fmt.Sprintf("%d/%04X:%02X", k.Node, k.Index, k.SubIndex)
+}
+
+// NodeStatus 节点在线状态
+type NodeStatus struct {
+	State    uint8
+	LastSeen time.Time
+	Online   bool
+}
+
+type objectValue struct {
+	data []byte
+	at   time.Time
+}
+
+type nodeMonitor struct {
+	state      uint8
+	lastSeen   time.Time
+	hbTimeout  time.Duration // 心跳超时，0 表示不监测心跳
+	guardLost  bool
+	lastToggle int // -1 表示尚未收到守护应答
+}
+
+// CANopenAdapter 实现 protocol.ProtocolAdapter 接口
+type CANopenAdapter struct {
+	can        *can.CANAdapter
+	sdoTimeout time.Duration
+	pdos       []*PDOMapping
+	configure  bool // 连接时通过SDO下发PDO映射到从站
+	startNodes []uint8
+	guardTime  time.Duration
+	lifeFactor int
+	guardNodes []uint8
+	listenOnce sync.Once // CAN监听只注册一次，重连后继续生效
+	started    bool
+
+	sdoMu   map[uint8]*sync.Mutex // 每个节点同一时间只有一个SDO事务
+	sdoResp map[uint8]chan []byte
+
+	mu      sync.Mutex
+	objects map[ObjectKey]objectValue // TPDO 接收到的对象值
+	nodes   map[uint8]*nodeMonitor
+	subs    protocol.Subscriptions[ObjectKey]
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewCANopenAdapter 工厂函数
+// cfg: CAN参数(interface) + sdoTimeoutMs, pdos, configurePdos, startNodes,
+//
+//	heartbeat: {"5": 1000}（节点->超时ms）, guardTimeMs, lifeTimeFactor, guardNodes
+func NewCANopenAdapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewCANopenAdapterWithDialer(cfg, can.DefaultDialer)
+}
+
+// NewCANopenAdapterWithDialer 使用指定CAN拨号函数创建适配器，测试时注入 MemBus
+func NewCANopenAdapterWithDialer(cfg map[string]interface{}, dial can.Dialer) (*CANopenAdapter, error) {
+	canAdapter, err := can.NewCANAdapterWithDialer(cfg, dial)
+	if err != nil {
+		return nil, err
+	}
+	a := &CANopenAdapter{
+		can:        canAdapter,
+		sdoTimeout: 500 * time.Millisecond,
+		lifeFactor: 3,
+		sdoMu:      make(map[uint8]*sync.Mutex),
+		sdoResp:    make(map[uint8]chan []byte),
+		objects:    make(map[ObjectKey]objectValue),
+		nodes:      make(map[uint8]*nodeMonitor),
+	}
+	if v, ok := parseUint(cfg["sdoTimeoutMs"]); ok && v > 0 {
+		a.sdoTimeout = time.Duration(v) * time.Millisecond
+	}
+	if a.pdos, err = parsePDOs(cfg["pdos"]); err != nil {
+		return nil, err
+	}
+	a.configure, _ = cfg["configurePdos"].(bool)
+	if a.startNodes, err = parseNodeList(cfg["startNodes"]); err != nil {
+		return nil, err
+	}
+	if hb, ok := cfg["heartbeat"].(map[string]interface{}); ok {
+		for k, v := range hb {
+			node, err := strconv.Atoi(k)
+			ms, ok := parseUint(v)
+			if err != nil || !ok || node < 1 || node > 127 {
+				return nil, fmt.Errorf("canopen: invalid heartbeat entry %s=%v", k, v)
+			}
+			a.monitor(uint8(node)).hbTimeout = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if v, ok := parseUint(cfg["guardTimeMs"]); ok && v > 0 {
+		a.guardTime = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := parseUint(cfg["lifeTimeFactor"]); ok && v > 0 {
+		a.lifeFactor = int(v)
+	}
+	if a.guardNodes, err = parseNodeList(cfg["guardNodes"]); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Connect 打开CAN，按配置下发PDO映射、启动节点并开启节点守护
+func (a *CANopenAdapter) Connect() error {
+	if err := a.can.Connect(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	if a.started {
+		a.mu.Unlock()
+		return nil
+	}
+	a.started = true
+	a.mu.Unlock()
+	a.listenOnce.Do(func() { a.can.AddListener(a.handleFrame) })
+	if err := a.startup(); err != nil {
+		a.mu.Lock()
+		a.started = false
+		a.mu.Unlock()
+		return err
+	}
+	if a.guardTime > 0 && len(a.guardNodes) > 0 {
+		stopCh := make(chan struct{})
+		a.mu.Lock()
+		a.stopCh = stopCh
+		a.mu.Unlock()
+		a.wg.Add(1)
+		go a.guardLoop(stopCh)
+	}
+	return nil
+}
+
+// startup 下发PDO映射并启动节点
+func (a *CANopenAdapter) startup() error {
+	if a.configure {
+		for _, p := range a.pdos {
+			if err := a.ConfigurePDO(p); err != nil {
+				return fmt.Errorf("canopen: configure %s: %w", p, err)
+			}
+		}
+	}
+	for _, n := range a.startNodes {
+		if err := a.NMT(NMTStart, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Disconnect 停止守护并断开CAN
+func (a *CANopenAdapter) Disconnect() error {
+	a.mu.Lock()
+	if a.stopCh != nil {
+		close(a.stopCh)
+		a.stopCh = nil
+	}
+	a.started = false
+	a.mu.Unlock()
+	a.wg.Wait()
+	return a.can.Disconnect()
+}
+
+// NMT 发送NMT命令，node=0 表示所有节点
+func (a *CANopenAdapter) NMT(cmd uint8, node uint8) error {
+	return a.can.SendFrame(can.Frame{ID: cobNMT, Data: []byte{cmd, node}})
+}
+
+// Sync 发送SYNC报文，同步型TPDO据此发送
+func (a *CANopenAdapter) Sync() error {
+	return a.can.SendFrame(can.Frame{ID: cobSync})
+}
+
+// Status 返回节点状态，配置了心跳超时或节点守护时判断在线
+func (a *CANopenAdapter) Status(node uint8) NodeStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m, ok := a.nodes[node]
+	if !ok {
+		return NodeStatus{State: StateUnknown}
+	}
+	st := NodeStatus{State: m.state, LastSeen: m.lastSeen, Online: !m.lastSeen.IsZero()}
+	if m.hbTimeout > 0 && time.Since(m.lastSeen) > m.hbTimeout {
+		st.Online = false
+	}
+	if m.guardLost {
+		st.Online = false
+	}
+	return st
+}
+
+// Upload SDO上传（读取从站对象字典）
+func (a *CANopenAdapter) Upload(node uint8, index uint16, sub uint8) ([]byte, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+	lock, ch := a.sdoChannel(node)
+	lock.Lock()
+	defer lock.Unlock()
+	drain(ch)
+
+	resp, err := a.sdoRequest(node, ch, sdoFrame(sdoCCSUploadInit, index, sub, nil))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAbort(resp, index, sub); err != nil {
+		return nil, err
+	}
+	if resp[0]&0xE0 != sdoSCSUploadInit {
+		return nil, a.abort(node, index, sub, AbortCommand)
+	}
+	if resp[0]&0x02 != 0 { // 快速传输
+		size := 4
+		if resp[0]&0x01 != 0 {
+			size = 4 - int((resp[0]>>2)&0x03)
+		}
+		return append([]byte(nil), resp[4:4+size]...), nil
+	}
+
+	// 分段传输
+	total := -1
+	if resp[0]&0x01 != 0 {
+		total = int(binary.LittleEndian.Uint32(resp[4:8]))
+	}
+	var out []byte
+	toggle := byte(0)
+	for {
+		resp, err = a.sdoRequest(node, ch, []byte{sdoCCSUploadSeg | toggle<<4, 0, 0, 0, 0, 0, 0, 0})
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAbort(resp, index, sub); err != nil {
+			return nil, err
+		}
+		if resp[0]&0xE0 != sdoSCSUploadSeg {
+			return nil, a.abort(node, index, sub, AbortCommand)
+		}
+		if (resp[0]>>4)&0x01 != toggle {
+			return nil, a.abort(node, index, sub, AbortToggle)
+		}
+		n := int((resp[0] >> 1) & 0x07)
+		out = append(out, resp[1:8-n]...)
+		if resp[0]&0x01 != 0 {
+			break
+		}
+		toggle ^= 1
+	}
+	if total >= 0 && total != len(out) {
+		return nil, fmt.Errorf("canopen: segmented upload size %d, expect %d", len(out), total)
+	}
+	return out, nil
+}
+
+// Download SDO下载（写从站对象字典），不超过4字节时使用快速传输
+func (a *CANopenAdapter) Download(node uint8, index uint16, sub uint8, data []byte) error {
+	if err := a.Connect(); err != nil {
+		return err
+	}
+	lock, ch := a.sdoChannel(node)
+	lock.Lock()
+	defer lock.Unlock()
+	drain(ch)
+
+	if len(data) <= 4 {
+		cmd := byte(sdoCCSDownloadInit | 0x02 | 0x01 | byte(4-len(data))<<2)
+		resp, err := a.sdoRequest(node, ch, sdoFrame(cmd, index, sub, data))
+		if err != nil {
+			return err
+		}
+		if err := checkAbort(resp, index, sub); err != nil {
+			return err
+		}
+		if resp[0]&0xE0 != sdoSCSDownloadInit {
+			return a.abort(node, index, sub, AbortCommand)
+		}
+		return nil
+	}
+
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(data)))
+	resp, err := a.sdoRequest(node, ch, sdoFrame(sdoCCSDownloadInit|0x01, index, sub, size))
+	if err != nil {
+		return err
+	}
+	if err := checkAbort(resp, index, sub); err != nil {
+		return err
+	}
+	if resp[0]&0xE0 != sdoSCSDownloadInit {
+		return a.abort(node, index, sub, AbortCommand)
+	}
+	toggle := byte(0)
+	for off := 0; off < len(data); off += 7 {
+		end := off + 7
+		last := byte(0)
+		if end >= len(data) {
+			end, last = len(data), 1
+		}
+		seg := make([]byte, 8)
+		seg[0] = sdoCCSDownloadSeg | toggle<<4 | byte(7-(end-off))<<1 | last
+		copy(seg[1:], data[off:end])
+		resp, err = a.sdoRequest(node, ch, seg)
+		if err != nil {
+			return err
+		}
+		if err := checkAbort(resp, index, sub); err != nil {
+			return err
+		}
+		if resp[0]&0xE0 != sdoSCSDownloadSeg || (resp[0]>>4)&0x01 != toggle {
+			return a.abort(node, index, sub, AbortToggle)
+		}
+		toggle ^= 1
+	}
+	return nil
+}
+
+// Read 读取对象字典条目
+// params: node, od("6000:01") 或 index/subindex, source("pdo"|"sdo"，默认有PDO缓存则用缓存), maxAgeMs
+func (a *CANopenAdapter) Read(params map[string]interface{}) ([]byte, error) {
+	if err := a.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	key, err := objectKeyFrom("", params)
+	if err != nil {
+		return nil, err
+	}
+	source, _ := params["source"].(string)
+	if source != "sdo" {
+		a.mu.Lock()
+		v, ok := a.objects[key]
+		a.mu.Unlock()
+		if ok {
+			if maxAge, _ := parseUint(params["maxAgeMs"]); maxAge > 0 && time.Since(v.at) > time.Duration(maxAge)*time.Millisecond {
+				return nil, fmt.Errorf("canopen: %s stale", key)
+			}
+			return append([]byte(nil), v.data...), nil
+		}
+		if source == "pdo" {
+			return nil, fmt.Errorf("canopen: no PDO data for %s", key)
+		}
+	}
+	return a.Upload(key.Node, key.Index, key.SubIndex)
+}
+
+// Subscribe 实现 protocol.Subscriber，映射在TPDO中的对象每收到一次PDO回调一次
+// params 同 Read: node, od 或 index/subindex
+func (a *CANopenAdapter) Subscribe(params map[string]interface{}, handler func(protocol.PointUpdate)) (func(), error) {
+	key, err := objectKeyFrom("", params)
+	if err != nil {
+		return nil, err
+	}
+	mapped := false
+	for _, p := range a.pdos {
+		if p.Type == TPDO && p.Node == key.Node && p.has(key) {
+			mapped = true
+		}
+	}
+	if !mapped {
+		return nil, fmt.Errorf("canopen: %s not mapped in any TPDO", key)
+	}
+	if err := a.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	return a.subs.Add(key, handler), nil
+}
+
+func (a *CANopenAdapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("canopen: BatchRead not supported")
+}
+
+// Write 写对象字典条目，address 为 "index:subindex"（可为空，取params）。
+// 条目映射在某个RPDO中时更新PDO映像并发送整帧，否则走SDO下载
+func (a *CANopenAdapter) Write(address string, data []byte, params map[string]interface{}) error {
+	if err := a.Connect(); err != nil {
+		return fmt.Errorf("connect failed: %w", err)
+	}
+	key, err := objectKeyFrom(address, params)
+	if err != nil {
+		return err
+	}
+	if via, _ := params["source"].(string); via != "sdo" {
+		for _, p := range a.pdos {
+			if p.Type == RPDO && p.Node == key.Node && p.has(key) {
+				frame, err := p.update(key, data)
+				if err != nil {
+					return err
+				}
+				return a.can.SendFrame(can.Frame{ID: p.COBID, Data: frame})
+			}
+		}
+	}
+	return a.Download(key.Node, key.Index, key.SubIndex, data)
+}
+
+func (a *CANopenAdapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("canopen: WriteModbus not supported")
+}
+
+// handleFrame CAN接收回调
+func (a *CANopenAdapter) handleFrame(f can.Frame) {
+	if f.Extended {
+		return
+	}
+	now := time.Now()
+	switch fn := f.ID & 0x780; {
+	case fn == cobSDOTx && f.ID != cobSDOTx:
+		node := uint8(f.ID - cobSDOTx)
+		a.mu.Lock()
+		ch, ok := a.sdoResp[node]
+		a.mu.Unlock()
+		if ok {
+			select {
+			case ch <- f.Data:
+			default:
+			}
+		}
+		return
+	case fn == cobHeartbeat && f.ID != cobHeartbeat && !f.RTR && len(f.Data) >= 1:
+		node := uint8(f.ID - cobHeartbeat)
+		a.mu.Lock()
+		m := a.monitor(node)
+		m.state = f.Data[0] & 0x7F
+		m.lastSeen = now
+		if a.isGuarded(node) {
+			toggle := int(f.Data[0] >> 7)
+			if m.lastToggle >= 0 && toggle == m.lastToggle {
+				log.Printf("[CANopen] 节点%d守护应答翻转位错误", node)
+			}
+			m.lastToggle = toggle
+			m.guardLost = false
+		}
+		a.mu.Unlock()
+		return
+	}
+	for _, p := range a.pdos {
+		if p.Type != TPDO || p.COBID != f.ID {
+			continue
+		}
+		values, err := p.split(f.Data)
+		if err != nil {
+			log.Printf("[CANopen] %s 解析失败: %v", p, err)
+			return
+		}
+		a.mu.Lock()
+		for k, v := range values {
+			a.objects[k] = objectValue{data: v, at: now}
+		}
+		a.mu.Unlock()
+		for k, v := range values {
+			a.subs.Publish(k, protocol.PointUpdate{Bytes: v, Timestamp: now})
+		}
+		return
+	}
+}
+
+// guardLoop 节点守护：周期发送远程帧，lifeTime 内无应答判定节点丢失
+func (a *CANopenAdapter) guardLoop(stopCh <-chan struct{}) {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.guardTime)
+	defer ticker.Stop()
+	life := a.guardTime * time.Duration(a.lifeFactor)
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			for _, n := range a.guardNodes {
+				if err := a.can.SendFrame(can.Frame{ID: cobHeartbeat + uint32(n), RTR: true}); err != nil {
+					log.Printf("[CANopen] 节点%d守护请求失败: %v", n, err)
+				}
+				a.mu.Lock()
+				m := a.monitor(n)
+				if !m.lastSeen.IsZero() && time.Since(m.lastSeen) > life && !m.guardLost {
+					m.guardLost = true
+					log.Printf("[CANopen] 节点%d守护超时", n)
+				}
+				a.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (a *CANopenAdapter) isGuarded(node uint8) bool {
+	for _, n := range a.guardNodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// monitor 获取节点监测状态，调用方需持有 a.mu（构造阶段除外）
+func (a *CANopenAdapter) monitor(node uint8) *nodeMonitor {
+	m, ok := a.nodes[node]
+	if !ok {
+		m = &nodeMonitor{state: StateUnknown, lastToggle: -1}
+		a.nodes[node] = m
+	}
+	return m
+}
+
+func (a *CANopenAdapter) sdoChannel(node uint8) (*sync.Mutex, chan []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	lock, ok := a.sdoMu[node]
+	if !ok {
+		lock = &sync.Mutex{}
+		a.sdoMu[node] = lock
+		a.sdoResp[node] = make(chan []byte, 1)
+	}
+	return lock, a.sdoResp[node]
+}
+
+func (a *CANopenAdapter) sdoRequest(node uint8, ch chan []byte, req []byte) ([]byte, error) {
+	if err := a.can.SendFrame(can.Frame{ID: cobSDORx + uint32(node), Data: req}); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-time.After(a.sdoTimeout):
+		index := binary.LittleEndian.Uint16(req[1:3])
+		_ = a.can.SendFrame(can.Frame{ID: cobSDORx + uint32(node), Data: abortFrame(index, req[3], AbortTimeout)})
+		return nil, fmt.Errorf("canopen: SDO timeout on node %d", node)
+	}
+}
+
+// abort 客户端主动中止传输
+func (a *CANopenAdapter) abort(node uint8, index uint16, sub uint8, code uint32) error {
+	_ = a.can.SendFrame(can.Frame{ID: cobSDORx + uint32(node), Data: abortFrame(index, sub, code)})
+	return &AbortError{Index: index, SubIndex: sub, Code: code}
+}
+
+func drain(ch chan []byte) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
+
+// ------- 参数解析 --------
+
+// ParseObjectAddress 解析 "6000:01"（十六进制索引:子索引）
+func ParseObjectAddress(s string) (uint16, uint8, error) {
+	idx, sub, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("canopen: invalid object address %q, expect index:subindex", s)
+	}
+	i, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(idx), "0x"), 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("canopen: invalid index in %q", s)
+	}
+	si, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(sub), "0x"), 16, 8)
+	if err != nil {
+		return 0, 0, fmt.Errorf("canopen: invalid subindex in %q", s)
+	}
+	return uint16(i), uint8(si), nil
+}
+
+func objectKeyFrom(address string, params map[string]interface{}) (ObjectKey, error) {
+	node, ok := parseUint(params["node"])
+	if !ok || node < 1 || node > 127 {
+		return ObjectKey{}, fmt.Errorf("canopen: invalid node %v", params["node"])
+	}
+	key := ObjectKey{Node: uint8(node)}
+	od := address
+	if od == "" {
+		od, _ = params["od"].(string)
+	}
+	if od != "" {
+		idx, sub, err := ParseObjectAddress(od)
+		if err != nil {
+			return ObjectKey{}, err
+		}
+		key.Index, key.SubIndex = idx, sub
+		return key, nil
+	}
+	idx, ok := parseUint(params["index"])
+	if !ok {
+		return ObjectKey{}, errors.New("canopen: missing od or index")
+	}
+	sub, _ := parseUint(params["subindex"])
+	key.Index, key.SubIndex = uint16(idx), uint8(sub)
+	return key, nil
+}
+
+func parseNodeList(raw interface{}) ([]uint8, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("canopen: node list should be a list, got %T", raw)
+	}
+	out := make([]uint8, 0, len(items))
+	for _, it := range items {
+		n, ok := parseUint(it)
+		if !ok || n > 127 {
+			return nil, fmt.Errorf("canopen: invalid node %v", it)
+		}
+		out = append(out, uint8(n))
+	}
+	return out, nil
+}
+
+// parseUint 兼容 int/float64 以及 "0x.." 字符串
+func parseUint(raw interface{}) (uint64, bool) {
+	switch v := raw.(type) {
+	case int:
+		return uint64(v), true
+	case int64:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case uint32:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case float64:
+		return uint64(v), true
+	case string:
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 0, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,331 @@
+package canopen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+	"cycV2/internal/protocol/can"
+)
+
+type odKey struct {
+	index uint16
+	sub   uint8
+}
+
+// simNode 进程内模拟的CANopen从站：SDO服务器、NMT、节点守护应答
+type simNode struct {
+	id    uint8
+	sock  *can.MemSocket
+	mu    sync.Mutex
+	od    map[odKey][]byte
+	state uint8
+	// 分段传输状态
+	segKey    odKey
+	segBuf    []byte
+	segOff    int
+	segToggle byte
+	guardTog  byte
+}
+
+func newSimNode(t *testing.T, bus *can.MemBus, id uint8) *simNode {
+	n := &simNode{id: id, sock: bus.Open(), od: make(map[odKey][]byte), state: StatePreOperational}
+	t.Cleanup(func() { n.sock.Close() })
+	go n.run()
+	return n
+}
+
+func (n *simNode) set(index uint16, sub uint8, data []byte) {
+	n.mu.Lock()
+	n.od[odKey{index, sub}] = data
+	n.mu.Unlock()
+}
+
+func (n *simNode) get(index uint16, sub uint8) []byte {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.od[odKey{index, sub}]
+}
+
+func (n *simNode) run() {
+	for {
+		f, err := n.sock.Recv()
+		if errors.Is(err, can.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		switch {
+		case f.ID == cobNMT && len(f.Data) == 2 && (f.Data[1] == 0 || f.Data[1] == n.id):
+			n.mu.Lock()
+			if f.Data[0] == NMTStart {
+				n.state = StateOperational
+			}
+			n.mu.Unlock()
+		case f.ID == cobHeartbeat+uint32(n.id) && f.RTR:
+			n.mu.Lock()
+			st := n.state | n.guardTog<<7
+			n.guardTog ^= 1
+			n.mu.Unlock()
+			n.sock.Send(can.Frame{ID: cobHeartbeat + uint32(n.id), Data: []byte{st}})
+		case f.ID == cobSDORx+uint32(n.id) && len(f.Data) == 8:
+			if resp := n.sdo(f.Data); resp != nil {
+				n.sock.Send(can.Frame{ID: cobSDOTx + uint32(n.id), Data: resp})
+			}
+		}
+	}
+}
+
+func (n *simNode) sdo(req []byte) []byte {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	index, sub := binary.LittleEndian.Uint16(req[1:3]), req[3]
+	key := odKey{index, sub}
+	switch req[0] & 0xE0 {
+	case sdoCCSUploadInit:
+		v, ok := n.od[key]
+		if !ok {
+			return abortFrame(index, sub, AbortNoObject)
+		}
+		if len(v) <= 4 {
+			return sdoFrame(sdoSCSUploadInit|0x03|byte(4-len(v))<<2, index, sub, v)
+		}
+		n.segKey, n.segBuf, n.segOff, n.segToggle = key, v, 0, 0
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(len(v)))
+		return sdoFrame(sdoSCSUploadInit|0x01, index, sub, size)
+	case sdoCCSUploadSeg:
+		if (req[0]>>4)&1 != n.segToggle {
+			return abortFrame(n.segKey.index, n.segKey.sub, AbortToggle)
+		}
+		end, last := n.segOff+7, byte(0)
+		if end >= len(n.segBuf) {
+			end, last = len(n.segBuf), 1
+		}
+		out := make([]byte, 8)
+		out[0] = sdoSCSUploadSeg | n.segToggle<<4 | byte(7-(end-n.segOff))<<1 | last
+		copy(out[1:], n.segBuf[n.segOff:end])
+		n.segOff = end
+		n.segToggle ^= 1
+		return out
+	case sdoCCSDownloadInit:
+		if index == 0x1008 {
+			return abortFrame(index, sub, AbortReadOnly)
+		}
+		if req[0]&0x02 != 0 {
+			size := 4
+			if req[0]&0x01 != 0 {
+				size = 4 - int((req[0]>>2)&0x03)
+			}
+			n.od[key] = append([]byte(nil), req[4:4+size]...)
+		} else {
+			n.segKey, n.segBuf, n.segToggle = key, nil, 0
+		}
+		return sdoFrame(sdoSCSDownloadInit, index, sub, nil)
+	case sdoCCSDownloadSeg:
+		if (req[0]>>4)&1 != n.segToggle {
+			return abortFrame(n.segKey.index, n.segKey.sub, AbortToggle)
+		}
+		k := int((req[0] >> 1) & 0x07)
+		n.segBuf = append(n.segBuf, req[1:8-k]...)
+		if req[0]&0x01 != 0 {
+			n.od[n.segKey] = n.segBuf
+		}
+		out := make([]byte, 8)
+		out[0] = sdoSCSDownloadSeg | n.segToggle<<4
+		n.segToggle ^= 1
+		return out
+	}
+	return nil
+}
+
+func newTestMaster(t *testing.T, bus *can.MemBus, cfg map[string]interface{}) *CANopenAdapter {
+	t.Helper()
+	cfg["interface"] = "can0"
+	cfg["sdoTimeoutMs"] = 300
+	a, err := NewCANopenAdapterWithDialer(cfg, bus.Dialer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Disconnect() })
+	return a
+}
+
+func TestSDOExpeditedAndSegmented(t *testing.T) {
+	bus := can.NewMemBus()
+	node := newSimNode(t, bus, 5)
+	node.set(0x1018, 1, []byte{0x78, 0x56, 0x34, 0x12})
+	node.set(0x1008, 0, []byte("BMS-Controller-V2"))
+	a := newTestMaster(t, bus, map[string]interface{}{})
+
+	data, err := a.Read(map[string]interface{}{"node": 5, "od": "1018:01"})
+	if err != nil || binary.LittleEndian.Uint32(data) != 0x12345678 {
+		t.Fatalf("expedited upload % X %v", data, err)
+	}
+	data, err = a.Upload(5, 0x1008, 0)
+	if err != nil || string(data) != "BMS-Controller-V2" {
+		t.Fatalf("segmented upload %q %v", data, err)
+	}
+
+	if err := a.Write("2000:01", []byte{0x34, 0x12}, map[string]interface{}{"node": 5}); err != nil {
+		t.Fatal(err)
+	}
+	if got := node.get(0x2000, 1); !bytes.Equal(got, []byte{0x34, 0x12}) {
+		t.Fatalf("expedited download % X", got)
+	}
+	long := []byte("0123456789ABCDEFGHIJ")
+	if err := a.Download(5, 0x2001, 0, long); err != nil {
+		t.Fatal(err)
+	}
+	if got := node.get(0x2001, 0); !bytes.Equal(got, long) {
+		t.Fatalf("segmented download %q", got)
+	}
+
+	var abort *AbortError
+	if _, err := a.Upload(5, 0x3000, 0); !errors.As(err, &abort) || abort.Code != AbortNoObject {
+		t.Fatalf("expect no-object abort, got %v", err)
+	}
+	if err := a.Download(5, 0x1008, 0, []byte{1}); !errors.As(err, &abort) || abort.Code != AbortReadOnly {
+		t.Fatalf("expect read-only abort, got %v", err)
+	}
+	if _, err := a.Upload(9, 0x1000, 0); err == nil {
+		t.Fatal("expect timeout on missing node")
+	}
+}
+
+func TestPDOMappingAndNMT(t *testing.T) {
+	bus := can.NewMemBus()
+	node := newSimNode(t, bus, 3)
+	observer := bus.Open()
+	defer observer.Close()
+	a := newTestMaster(t, bus, map[string]interface{}{
+		"configurePdos": true,
+		"startNodes":    []interface{}{3},
+		"pdos": []interface{}{
+			map[string]interface{}{"type": "tpdo", "node": 3, "num": 1, "transmissionType": 1,
+				"entries": []interface{}{"6000:01/8", map[string]interface{}{"od": "6401:01", "bits": 16}}},
+			map[string]interface{}{"type": "rpdo", "node": 3, "num": 1,
+				"entries": []interface{}{"6200:01/8", "6411:01/16"}},
+		},
+	})
+
+	// 映射已下发到从站
+	if got := node.get(0x1A00, 1); binary.LittleEndian.Uint32(got) != 0x60000108 {
+		t.Fatalf("tpdo mapping entry % X", got)
+	}
+	if got := node.get(0x1A00, 0); !bytes.Equal(got, []byte{2}) {
+		t.Fatalf("tpdo mapping count % X", got)
+	}
+	if got := node.get(0x1800, 1); binary.LittleEndian.Uint32(got) != 0x183 {
+		t.Fatalf("tpdo cob-id % X", got)
+	}
+	if got := node.get(0x1800, 2); !bytes.Equal(got, []byte{1}) {
+		t.Fatalf("tpdo transmission type % X", got)
+	}
+	// NMT 启动命令异步到达从站
+	deadline := time.Now().Add(time.Second)
+	for {
+		node.mu.Lock()
+		state := node.state
+		node.mu.Unlock()
+		if state == StateOperational {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("node not started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// TPDO 数据按映射拆分
+	node.sock.Send(can.Frame{ID: 0x183, Data: []byte{0xA5, 0xE8, 0x03}})
+	for {
+		data, err := a.Read(map[string]interface{}{"node": 3, "od": "6401:01", "source": "pdo"})
+		if err == nil {
+			if !bytes.Equal(data, []byte{0xE8, 0x03}) {
+				t.Fatalf("pdo value % X", data)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if data, _ := a.Read(map[string]interface{}{"node": 3, "index": 0x6000, "subindex": 1}); !bytes.Equal(data, []byte{0xA5}) {
+		t.Fatalf("pdo value by index % X", data)
+	}
+
+	// 写 RPDO 映射条目发送整帧
+	if err := a.Write("6411:01", []byte{0x10, 0x27}, map[string]interface{}{"node": 3}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		f, err := observer.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.ID == 0x203 {
+			if !bytes.Equal(f.Data, []byte{0x00, 0x10, 0x27}) {
+				t.Fatalf("rpdo frame % X", f.Data)
+			}
+			break
+		}
+	}
+}
+
+func TestHeartbeatAndGuarding(t *testing.T) {
+	bus := can.NewMemBus()
+	hb := bus.Open()
+	defer hb.Close()
+	newSimNode(t, bus, 7)
+	a := newTestMaster(t, bus, map[string]interface{}{
+		"heartbeat":   map[string]interface{}{"4": 100},
+		"guardTimeMs": 20,
+		"guardNodes":  []interface{}{7},
+	})
+
+	if st := a.Status(4); st.Online {
+		t.Fatalf("node 4 should be offline before heartbeat: %+v", st)
+	}
+	hb.Send(can.Frame{ID: cobHeartbeat + 4, Data: []byte{StateOperational}})
+	deadline := time.Now().Add(time.Second)
+	for !a.Status(4).Online {
+		if time.Now().After(deadline) {
+			t.Fatal("heartbeat not seen")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if st := a.Status(4); st.State != StateOperational {
+		t.Fatalf("heartbeat state %+v", st)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if a.Status(4).Online {
+		t.Fatal("node 4 should time out")
+	}
+
+	// 节点守护应答
+	for a.Status(7).State != StatePreOperational {
+		if time.Now().After(deadline) {
+			t.Fatalf("guarding state %+v", a.Status(7))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !a.Status(7).Online {
+		t.Fatal("guarded node should be online")
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	if _, err := protocol.GetAdapter("canopen", map[string]interface{}{"interface": "can0"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,229 @@
+package canopen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PDO 方向（从站视角）：TPDO 由从站发送、主站接收；RPDO 由主站发送、从站接收
+const (
+	TPDO = "tpdo"
+	RPDO = "rpdo"
+)
+
+// PDOEntry PDO 映射条目
+type PDOEntry struct {
+	Index    uint16
+	SubIndex uint8
+	Bits     uint8 // 8的整数倍
+}
+
+// PDOMapping 一个PDO的映射配置
+type PDOMapping struct {
+	Type             string
+	Node             uint8
+	Num              int    // PDO编号 1..4，用于推导默认COB-ID及下发映射
+	COBID            uint32 // 未配置时按预定义连接集推导
+	TransmissionType int    // 下发映射时写入通信参数，-1 表示不写
+	Entries          []PDOEntry
+
+	mu    sync.Mutex
+	image []byte // RPDO 发送映像
+}
+
+func (p *PDOMapping) String() string {
+	return fmt.Sprintf("%s%d(node %d, 0x%03X)", strings.ToUpper(p.Type), p.Num, p.Node, p.COBID)
+}
+
+// size PDO 数据长度（字节）
+func (p *PDOMapping) size() int {
+	n := 0
+	for _, e := range p.Entries {
+		n += int(e.Bits) / 8
+	}
+	return n
+}
+
+func (p *PDOMapping) has(key ObjectKey) bool {
+	for _, e := range p.Entries {
+		if e.Index == key.Index && e.SubIndex == key.SubIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// split 把接收到的PDO数据按映射拆分为对象值
+func (p *PDOMapping) split(data []byte) (map[ObjectKey][]byte, error) {
+	if len(data) < p.size() {
+		return nil, fmt.Errorf("length %d, mapping needs %d", len(data), p.size())
+	}
+	out := make(map[ObjectKey][]byte, len(p.Entries))
+	off := 0
+	for _, e := range p.Entries {
+		n := int(e.Bits) / 8
+		out[ObjectKey{Node: p.Node, Index: e.Index, SubIndex: e.SubIndex}] = append([]byte(nil), data[off:off+n]...)
+		off += n
+	}
+	return out, nil
+}
+
+// update 更新RPDO映像中的一个条目，返回整帧数据
+func (p *PDOMapping) update(key ObjectKey, data []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.image == nil {
+		p.image = make([]byte, p.size())
+	}
+	off := 0
+	for _, e := range p.Entries {
+		n := int(e.Bits) / 8
+		if e.Index == key.Index && e.SubIndex == key.SubIndex {
+			if len(data) != n {
+				return nil, fmt.Errorf("canopen: %04X:%02X needs %d bytes, got %d", e.Index, e.SubIndex, n, len(data))
+			}
+			copy(p.image[off:], data)
+			return append([]byte(nil), p.image...), nil
+		}
+		off += n
+	}
+	return nil, fmt.Errorf("canopen: %s not mapped in %s", key, p)
+}
+
+// ConfigurePDO 通过SDO把映射下发到从站：
+// 禁用PDO -> 清映射 -> 写条目 -> 写条目数 -> 传输类型 -> 启用PDO
+func (a *CANopenAdapter) ConfigurePDO(p *PDOMapping) error {
+	if p.Num < 1 || p.Num > 4 {
+		return fmt.Errorf("pdo num %d out of range 1..4", p.Num)
+	}
+	comm, mapping := uint16(0x1800), uint16(0x1A00)
+	if p.Type == RPDO {
+		comm, mapping = 0x1400, 0x1600
+	}
+	comm += uint16(p.Num - 1)
+	mapping += uint16(p.Num - 1)
+
+	u32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, v)
+		return b
+	}
+	if err := a.Download(p.Node, comm, 1, u32(p.COBID|0x80000000)); err != nil {
+		return err
+	}
+	if err := a.Download(p.Node, mapping, 0, []byte{0}); err != nil {
+		return err
+	}
+	for i, e := range p.Entries {
+		v := uint32(e.Index)<<16 | uint32(e.SubIndex)<<8 | uint32(e.Bits)
+		if err := a.Download(p.Node, mapping, uint8(i+1), u32(v)); err != nil {
+			return err
+		}
+	}
+	if err := a.Download(p.Node, mapping, 0, []byte{byte(len(p.Entries))}); err != nil {
+		return err
+	}
+	if p.TransmissionType >= 0 {
+		if err := a.Download(p.Node, comm, 2, []byte{byte(p.TransmissionType)}); err != nil {
+			return err
+		}
+	}
+	return a.Download(p.Node, comm, 1, u32(p.COBID))
+}
+
+// parsePDOs 解析配置
+//
+//	"pdos": [{"type":"tpdo","node":5,"num":1,"cobId":"0x185","transmissionType":255,
+//	          "entries":["6000:01/8", {"od":"6401:01","bits":16}]}]
+func parsePDOs(raw interface{}) ([]*PDOMapping, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("canopen: pdos should be a list, got %T", raw)
+	}
+	var out []*PDOMapping
+	for i, it := range items {
+		m, ok := it.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("canopen: pdos[%d] should be an object", i)
+		}
+		p := &PDOMapping{TransmissionType: -1}
+		p.Type, _ = m["type"].(string)
+		p.Type = strings.ToLower(p.Type)
+		if p.Type != TPDO && p.Type != RPDO {
+			return nil, fmt.Errorf("canopen: pdos[%d] type should be tpdo or rpdo", i)
+		}
+		node, ok := parseUint(m["node"])
+		if !ok || node < 1 || node > 127 {
+			return nil, fmt.Errorf("canopen: pdos[%d] invalid node %v", i, m["node"])
+		}
+		p.Node = uint8(node)
+		if n, ok := parseUint(m["num"]); ok {
+			p.Num = int(n)
+		}
+		if v, ok := parseUint(m["cobId"]); ok {
+			p.COBID = uint32(v)
+		} else {
+			if p.Num < 1 || p.Num > 4 {
+				return nil, fmt.Errorf("canopen: pdos[%d] needs cobId or num 1..4", i)
+			}
+			base := uint32(cobTPDO1)
+			if p.Type == RPDO {
+				base = cobRPDO1
+			}
+			p.COBID = base + uint32(p.Num-1)*0x100 + uint32(p.Node)
+		}
+		if v, ok := parseUint(m["transmissionType"]); ok {
+			p.TransmissionType = int(v)
+		}
+		entries, _ := m["entries"].([]interface{})
+		for j, e := range entries {
+			entry, err := parsePDOEntry(e)
+			if err != nil {
+				return nil, fmt.Errorf("canopen: pdos[%d].entries[%d]: %w", i, j, err)
+			}
+			p.Entries = append(p.Entries, entry)
+		}
+		if len(p.Entries) == 0 || p.size() > 8 {
+			return nil, fmt.Errorf("canopen: pdos[%d] mapping size %d bytes, expect 1..8", i, p.size())
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// parsePDOEntry 支持 "6000:01/8" 与 {"od":"6000:01","bits":8}
+func parsePDOEntry(raw interface{}) (PDOEntry, error) {
+	var od string
+	var bits uint64
+	switch v := raw.(type) {
+	case string:
+		s, b, ok := strings.Cut(v, "/")
+		if !ok {
+			return PDOEntry{}, fmt.Errorf("entry %q should be index:subindex/bits", v)
+		}
+		n, err := strconv.ParseUint(b, 10, 8)
+		if err != nil {
+			return PDOEntry{}, fmt.Errorf("entry %q invalid bits", v)
+		}
+		od, bits = s, n
+	case map[string]interface{}:
+		od, _ = v["od"].(string)
+		bits, _ = parseUint(v["bits"])
+	default:
+		return PDOEntry{}, fmt.Errorf("unsupported entry %T", raw)
+	}
+	idx, sub, err := ParseObjectAddress(od)
+	if err != nil {
+		return PDOEntry{}, err
+	}
+	if bits == 0 || bits%8 != 0 || bits > 64 {
+		return PDOEntry{}, fmt.Errorf("bits %d should be a multiple of 8 up to 64", bits)
+	}
+	return PDOEntry{Index: idx, SubIndex: sub, Bits: uint8(bits)}, nil
+}
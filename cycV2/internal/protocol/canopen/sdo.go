@@ -0,0 +1,114 @@
+package canopen
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// COB-ID 基址（预定义连接集）
+const (
+	cobNMT       = 0x000
+	cobSync      = 0x080
+	cobEmcy      = 0x080
+	cobTPDO1     = 0x180
+	cobRPDO1     = 0x200
+	cobSDOTx     = 0x580 // 服务器->客户端
+	cobSDORx     = 0x600 // 客户端->服务器
+	cobHeartbeat = 0x700
+)
+
+// SDO 命令字
+const (
+	sdoCCSDownloadSeg  = 0 << 5
+	sdoCCSDownloadInit = 1 << 5
+	sdoCCSUploadInit   = 2 << 5
+	sdoCCSUploadSeg    = 3 << 5
+	sdoCSAbort         = 4 << 5
+
+	sdoSCSUploadSeg    = 0 << 5
+	sdoSCSDownloadSeg  = 1 << 5
+	sdoSCSUploadInit   = 2 << 5
+	sdoSCSDownloadInit = 3 << 5
+)
+
+// AbortError SDO中止传输
+type AbortError struct {
+	Index    uint16
+	SubIndex uint8
+	Code     uint32
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("canopen: SDO abort 0x%08X (%s) on %04X:%02X", e.Code, abortText(e.Code), e.Index, e.SubIndex)
+}
+
+// 常见中止码
+const (
+	AbortToggle        = 0x05030000
+	AbortTimeout       = 0x05040000
+	AbortCommand       = 0x05040001
+	AbortWriteOnly     = 0x06010001
+	AbortReadOnly      = 0x06010002
+	AbortNoObject      = 0x06020000
+	AbortNoSubIndex    = 0x06090011
+	AbortLengthHigh    = 0x06070012
+	AbortLengthLow     = 0x06070013
+	AbortGeneral       = 0x08000000
+	AbortDataTransfer  = 0x08000020
+	AbortLengthUnmatch = 0x06070010
+)
+
+func abortText(code uint32) string {
+	switch code {
+	case AbortToggle:
+		return "toggle bit not alternated"
+	case AbortTimeout:
+		return "SDO protocol timed out"
+	case AbortCommand:
+		return "command specifier not valid"
+	case AbortWriteOnly:
+		return "attempt to read a write only object"
+	case AbortReadOnly:
+		return "attempt to write a read only object"
+	case AbortNoObject:
+		return "object does not exist"
+	case AbortNoSubIndex:
+		return "sub-index does not exist"
+	case AbortLengthUnmatch:
+		return "data type length does not match"
+	case AbortLengthHigh:
+		return "data type length too high"
+	case AbortLengthLow:
+		return "data type length too low"
+	case AbortDataTransfer:
+		return "data cannot be transferred"
+	default:
+		return "general error"
+	}
+}
+
+func sdoFrame(cmd byte, index uint16, sub uint8, data []byte) []byte {
+	out := make([]byte, 8)
+	out[0] = cmd
+	binary.LittleEndian.PutUint16(out[1:3], index)
+	out[3] = sub
+	copy(out[4:], data)
+	return out
+}
+
+func abortFrame(index uint16, sub uint8, code uint32) []byte {
+	d := make([]byte, 4)
+	binary.LittleEndian.PutUint32(d, code)
+	return sdoFrame(sdoCSAbort, index, sub, d)
+}
+
+// checkAbort 服务器回复为中止时返回 AbortError
+func checkAbort(resp []byte, index uint16, sub uint8) error {
+	if len(resp) < 8 {
+		return fmt.Errorf("canopen: short SDO response % X", resp)
+	}
+	if resp[0]&0xE0 == sdoCSAbort {
+		return &AbortError{Index: index, SubIndex: sub, Code: binary.LittleEndian.Uint32(resp[4:8])}
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+package dlt645
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+	"github.com/grid-x/serial"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("dlt645", NewDLT645Adapter)
+}
+
+// port 一个物理通道（RS-485 总线或串口服务器连接），总线上多块表的适配器共用，事务互斥
+type port struct {
+	key  string
+	open func() (io.ReadWriteCloser, error)
+
+	mu   sync.Mutex
+	rwc  io.ReadWriteCloser
+	r    *bufio.Reader
+	refs int
+}
+
+var ports = struct {
+	sync.Mutex
+	m map[string]*port
+}{m: make(map[string]*port)}
+
+func acquirePort(key string, open func() (io.ReadWriteCloser, error)) *port {
+	ports.Lock()
+	defer ports.Unlock()
+	p, ok := ports.m[key]
+	if !ok {
+		p = &port{key: key, open: open}
+		ports.m[key] = p
+	}
+	p.refs++
+	return p
+}
+
+func (p *port) release() {
+	ports.Lock()
+	p.refs--
+	last := p.refs == 0
+	if last {
+		delete(ports.m, p.key)
+	}
+	ports.Unlock()
+	if last {
+		p.mu.Lock()
+		p.closeLocked()
+		p.mu.Unlock()
+	}
+}
+
+func (p *port) closeLocked() {
+	if p.rwc != nil {
+		p.rwc.Close()
+		p.rwc, p.r = nil, nil
+	}
+}
+
+// transact 发送请求并等待 accept 接受的应答帧；accept 为 nil 时不等待（广播）。
+// 总线上其他表的应答、转换器回显等不被接受的帧跳过
+func (p *port) transact(req []byte, timeout time.Duration, accept func(Frame) bool) (Frame, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rwc == nil {
+		rwc, err := p.open()
+		if err != nil {
+			return Frame{}, err
+		}
+		p.rwc, p.r = rwc, bufio.NewReader(rwc)
+	}
+	// 丢弃上次事务残留
+	p.r.Discard(p.r.Buffered())
+	conn, isConn := p.rwc.(net.Conn)
+	if isConn {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if _, err := p.rwc.Write(req); err != nil {
+		p.closeLocked()
+		return Frame{}, err
+	}
+	if accept == nil {
+		return Frame{}, nil
+	}
+	for {
+		f, err := readFrame(p.r)
+		if err != nil {
+			var ne net.Error
+			if !errors.Is(err, errChecksum) && !errors.Is(err, serial.ErrTimeout) && !(errors.As(err, &ne) && ne.Timeout()) {
+				// 连接异常，下次事务重新打开
+				p.closeLocked()
+			}
+			return Frame{}, err
+		}
+		if accept(f) {
+			return f, nil
+		}
+	}
+}
+
+// DLT645Adapter DL/T 645 主站，实现 protocol.ProtocolAdapter 接口。
+// 点位按数据标识读取，Read 返回去掉数据标识后的原始数据域（BCD，低字节在前），由解析流程按格式解码
+type DLT645Adapter struct {
+	key      string
+	open     func() (io.ReadWriteCloser, error)
+	meter    Address
+	preamble int
+	timeout  time.Duration
+	retries  int
+	password []byte // 写数据密码（权限+密码，报文顺序）
+	operator []byte // 写数据操作者代码
+
+	mu   sync.Mutex
+	port *port
+}
+
+// NewDLT645Adapter 工厂函数
+// cfg: mode("serial"|"tcp"，默认serial), address(串口设备或串口服务器"ip:port"),
+//
+//	baudRate(默认2400), dataBits(8), parity("E"), stopBits(1), meterAddr(12位表地址，A为通配，默认全通配),
+//	preamble(前导FE个数，默认4), timeoutMs(默认2000), retries(默认1), password, operator
+func NewDLT645Adapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	addr, _ := cfg["address"].(string)
+	if addr == "" {
+		return nil, errors.New("dlt645: missing address")
+	}
+	a := &DLT645Adapter{meter: WildcardAddress, preamble: 4, timeout: 2 * time.Second, retries: 1}
+	if v, ok := toInt(cfg["timeoutMs"]); ok && v > 0 {
+		a.timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(cfg["preamble"]); ok && v >= 0 {
+		a.preamble = v
+	}
+	if v, ok := toInt(cfg["retries"]); ok && v >= 0 {
+		a.retries = v
+	}
+	if s, _ := cfg["meterAddr"].(string); s != "" {
+		m, err := ParseAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		a.meter = m
+	}
+	var err error
+	if a.password, err = hexLE(cfg["password"], "00000000", true); err != nil {
+		return nil, err
+	}
+	if a.operator, err = hexLE(cfg["operator"], "00000000", false); err != nil {
+		return nil, err
+	}
+
+	mode, _ := cfg["mode"].(string)
+	switch mode {
+	case "tcp":
+		timeout := a.timeout
+		a.key = "tcp:" + addr
+		a.open = func() (io.ReadWriteCloser, error) { return net.DialTimeout("tcp", addr, timeout) }
+	case "", "serial", "rtu":
+		sc := &serial.Config{Address: addr, BaudRate: 2400, DataBits: 8, Parity: "E", StopBits: 1, Timeout: a.timeout}
+		if v, ok := toInt(cfg["baudRate"]); ok && v > 0 {
+			sc.BaudRate = v
+		}
+		if v, ok := toInt(cfg["dataBits"]); ok && v > 0 {
+			sc.DataBits = v
+		}
+		if v, ok := cfg["parity"].(string); ok && v != "" {
+			sc.Parity = v
+		}
+		if v, ok := toInt(cfg["stopBits"]); ok && v > 0 {
+			sc.StopBits = v
+		}
+		a.key = "serial:" + addr
+		a.open = func() (io.ReadWriteCloser, error) { return serial.Open(sc) }
+	default:
+		return nil, fmt.Errorf("dlt645: unsupported mode %q", mode)
+	}
+	return a, nil
+}
+
+// hexLE 8位十六进制配置转为报文中的4字节，keepFirst 时首字节（权限）保持在前，其余低字节在前
+func hexLE(raw interface{}, def string, keepFirst bool) ([]byte, error) {
+	s, _ := raw.(string)
+	if s == "" {
+		s = def
+	}
+	d, err := ParseDI(s)
+	if err != nil || d.V1997 {
+		return nil, fmt.Errorf("dlt645: %q must be 8 hex digits", s)
+	}
+	b := d.Bytes()
+	if keepFirst {
+		return []byte{b[3], b[0], b[1], b[2]}, nil
+	}
+	return b, nil
+}
+
+// Connect 登记到物理通道，通道在首次事务时打开
+func (a *DLT645Adapter) Connect() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.port == nil {
+		a.port = acquirePort(a.key, a.open)
+	}
+	return nil
+}
+
+// Disconnect 最后一块表断开时关闭通道
+func (a *DLT645Adapter) Disconnect() error {
+	a.mu.Lock()
+	p := a.port
+	a.port = nil
+	a.mu.Unlock()
+	if p != nil {
+		p.release()
+	}
+	return nil
+}
+
+func (a *DLT645Adapter) getPort() (*port, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.port, nil
+}
+
+// request 发送请求并等待本表同功能的应答，超时或校验错误时重试
+func (a *DLT645Adapter) request(req Frame) (Frame, error) {
+	p, err := a.getPort()
+	if err != nil {
+		return Frame{}, err
+	}
+	accept := func(f Frame) bool {
+		return f.Control&ctrlReply != 0 && f.Control&0x1F == req.Control&0x1F && req.Addr.Match(f.Addr)
+	}
+	var lastErr error
+	for attempt := 0; attempt <= a.retries; attempt++ {
+		f, err := p.transact(req.Encode(a.preamble), a.timeout, accept)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if f.Control&ctrlAbnormal != 0 {
+			code := byte(0)
+			if len(f.Data) > 0 {
+				code = f.Data[0]
+			}
+			return f, &ErrorReply{Code: code}
+		}
+		return f, nil
+	}
+	return Frame{}, fmt.Errorf("dlt645: meter %s: %w", req.Addr, lastErr)
+}
+
+// ReadData 按数据标识读数据，自动读取后续帧，返回去掉数据标识的数据域
+func (a *DLT645Adapter) ReadData(di DI) ([]byte, error) {
+	ctrl, follow := CtrlRead, CtrlReadFollow
+	if di.V1997 {
+		ctrl, follow = CtrlRead97, CtrlReadFollow97
+	}
+	id := di.Bytes()
+	f, err := a.request(Frame{Addr: a.meter, Control: ctrl, Data: id})
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(f.Data, id) {
+		return nil, fmt.Errorf("dlt645: reply DI mismatch for %s", di)
+	}
+	data := append([]byte(nil), f.Data[len(id):]...)
+	for seq := 1; f.Control&ctrlFollow != 0; seq++ {
+		req := Frame{Addr: a.meter, Control: follow, Data: id}
+		if !di.V1997 {
+			req.Data = append(append([]byte(nil), id...), byte(seq))
+		}
+		if f, err = a.request(req); err != nil {
+			return nil, err
+		}
+		part := f.Data
+		if !bytes.HasPrefix(part, id) {
+			return nil, fmt.Errorf("dlt645: follow-up DI mismatch for %s", di)
+		}
+		part = part[len(id):]
+		if !di.V1997 {
+			// 2007 后续帧末尾为帧序号
+			if len(part) == 0 {
+				return nil, errors.New("dlt645: follow-up frame without sequence")
+			}
+			part = part[:len(part)-1]
+		}
+		data = append(data, part...)
+	}
+	return data, nil
+}
+
+// ReadAddress 用通配地址读取通信地址（总线上只能有一块表），并改用该地址通信
+func (a *DLT645Adapter) ReadAddress() (Address, error) {
+	f, err := a.request(Frame{Addr: WildcardAddress, Control: CtrlReadAddress})
+	if err != nil {
+		return Address{}, err
+	}
+	if len(f.Data) < 6 {
+		return Address{}, errors.New("dlt645: short address reply")
+	}
+	var addr Address
+	copy(addr[:], f.Data)
+	a.meter = addr
+	return addr, nil
+}
+
+// BroadcastTime 广播校时，表不应答
+func (a *DLT645Adapter) BroadcastTime(t time.Time) error {
+	p, err := a.getPort()
+	if err != nil {
+		return err
+	}
+	bcd := func(v int) byte { return byte(v/10)<<4 | byte(v%10) }
+	data := []byte{bcd(t.Second()), bcd(t.Minute()), bcd(t.Hour()), bcd(t.Day()), bcd(int(t.Month())), bcd(t.Year() % 100)}
+	_, err = p.transact(Frame{Addr: BroadcastAddress, Control: CtrlBroadcastTime, Data: data}.Encode(a.preamble), a.timeout, nil)
+	return err
+}
+
+// Read 读取 params["di"] 数据标识；数据块可用 params["index"] 取第 n 个元素（元素长度按格式）
+func (a *DLT645Adapter) Read(params map[string]interface{}) ([]byte, error) {
+	di, err := ParseDI(params["di"])
+	if err != nil {
+		return nil, err
+	}
+	data, err := a.ReadData(di)
+	if err != nil {
+		return nil, err
+	}
+	if idx, ok := toInt(params["index"]); ok {
+		f, err := FormatFromParams(params)
+		if err != nil {
+			return nil, err
+		}
+		start := idx * f.Length
+		if idx < 0 || start+f.Length > len(data) {
+			return nil, fmt.Errorf("dlt645: index %d out of block (%d bytes)", idx, len(data))
+		}
+		return data[start : start+f.Length], nil
+	}
+	return data, nil
+}
+
+func (a *DLT645Adapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("dlt645: BatchRead not supported")
+}
+
+// Write 写数据，address 为数据标识（为空时取 params["di"]），data 为报文格式的数据（BCD，低字节在前）。
+// 2007 附带配置的密码与操作者代码
+func (a *DLT645Adapter) Write(address string, data []byte, params map[string]interface{}) error {
+	var raw interface{} = address
+	if address == "" {
+		raw = params["di"]
+	}
+	di, err := ParseDI(raw)
+	if err != nil {
+		return err
+	}
+	req := Frame{Addr: a.meter, Control: CtrlWrite}
+	req.Data = append(req.Data, di.Bytes()...)
+	if di.V1997 {
+		req.Control = CtrlWrite97
+	} else {
+		req.Data = append(append(req.Data, a.password...), a.operator...)
+	}
+	req.Data = append(req.Data, data...)
+	if _, err := a.request(req); err != nil {
+		return err
+	}
+	log.Printf("[DLT645] 表%s 写 %s 成功", a.meter, di)
+	return nil
+}
+
+func (a *DLT645Adapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("dlt645: WriteModbus not supported")
+}
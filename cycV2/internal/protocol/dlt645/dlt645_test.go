@@ -0,0 +1,274 @@
+package dlt645
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// fakeBus 串口服务器上的一条 RS-485 总线，挂多块表；应答前先回显请求（模拟转换器回显）
+type fakeBus struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	meters  map[Address]map[string][]byte // 表地址 → DI → 数据域
+	writes  []Frame
+	corrupt int // 接下来几次应答的校验和置错
+}
+
+func newFakeBus(t *testing.T) *fakeBus {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBus{ln: ln, meters: make(map[Address]map[string][]byte)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *fakeBus) meter(addr string, data map[string][]byte) {
+	a, _ := ParseAddress(addr)
+	b.meters[a] = data
+}
+
+func (b *fakeBus) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		req, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		conn.Write(req.Encode(0))
+		b.mu.Lock()
+		for addr, data := range b.meters {
+			if !req.Addr.Match(addr) || req.Addr == BroadcastAddress {
+				continue
+			}
+			reply := b.reply(addr, data, req)
+			out := reply.Encode(4)
+			if b.corrupt > 0 {
+				b.corrupt--
+				out[len(out)-2]++
+			}
+			conn.Write(out)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// reply 调用方持有 mu
+func (b *fakeBus) reply(addr Address, data map[string][]byte, req Frame) Frame {
+	resp := Frame{Addr: addr, Control: req.Control | ctrlReply}
+	abnormal := func(code byte) Frame {
+		resp.Control |= ctrlAbnormal
+		resp.Data = []byte{code}
+		return resp
+	}
+	switch req.Control {
+	case CtrlReadAddress:
+		resp.Data = addr[:]
+	case CtrlRead, CtrlRead97, CtrlReadFollow:
+		n := 4
+		if req.Control == CtrlRead97 {
+			n = 2
+		}
+		id := req.Data[:n]
+		key := DI{Value: uint32(id[0]) | uint32(id[1])<<8, V1997: n == 2}
+		if n == 4 {
+			key.Value |= uint32(id[2])<<16 | uint32(id[3])<<24
+		}
+		v, ok := data[key.String()]
+		if !ok {
+			return abnormal(0x02)
+		}
+		// 超过4字节的数据分帧，每帧4字节
+		seq := 0
+		if req.Control == CtrlReadFollow {
+			seq = int(req.Data[4])
+		}
+		part := v[min(seq*4, len(v)):min(seq*4+4, len(v))]
+		resp.Data = append(append([]byte(nil), id...), part...)
+		if seq > 0 {
+			resp.Data = append(resp.Data, byte(seq))
+		}
+		if n == 4 && seq*4+4 < len(v) {
+			resp.Control |= ctrlFollow
+		}
+	case CtrlWrite:
+		if !bytes.Equal(req.Data[4:8], []byte{0x02, 0x56, 0x34, 0x12}) {
+			return abnormal(0x04)
+		}
+		b.writes = append(b.writes, req)
+	default:
+		return abnormal(0x01)
+	}
+	return resp
+}
+
+func newTestMeter(t *testing.T, b *fakeBus, meterAddr string, extra map[string]interface{}) *DLT645Adapter {
+	cfg := map[string]interface{}{"mode": "tcp", "address": b.ln.Addr().String(), "meterAddr": meterAddr, "timeoutMs": 300}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	a, err := NewDLT645Adapter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Disconnect() })
+	return a.(*DLT645Adapter)
+}
+
+func TestFrameAndBCD(t *testing.T) {
+	addr, err := ParseAddress("123456789012")
+	if err != nil || addr != (Address{0x12, 0x90, 0x78, 0x56, 0x34, 0x12}) || addr.String() != "123456789012" {
+		t.Fatalf("address %v %v", addr, err)
+	}
+	wild, _ := ParseAddress("AAAAAA789012")
+	if !wild.Match(addr) || wild.Match(Address{0x12, 0x90, 0x77}) {
+		t.Fatal("wildcard match")
+	}
+	f := Frame{Addr: addr, Control: CtrlRead, Data: []byte{0x00, 0x00, 0x01, 0x00}}
+	enc := f.Encode(4)
+	// 标准示例：读正向有功总电能
+	want := []byte{0xFE, 0xFE, 0xFE, 0xFE, 0x68, 0x12, 0x90, 0x78, 0x56, 0x34, 0x12, 0x68, 0x11, 0x04, 0x33, 0x33, 0x34, 0x33}
+	if !bytes.Equal(enc[:len(want)], want) || enc[len(enc)-1] != 0x16 {
+		t.Fatalf("encode % X", enc)
+	}
+	got, err := readFrame(bufio.NewReader(bytes.NewReader(append([]byte{0x00, 0xFE}, enc...))))
+	if err != nil || got.Addr != addr || !bytes.Equal(got.Data, f.Data) {
+		t.Fatalf("decode %+v %v", got, err)
+	}
+	enc[len(enc)-2]++
+	if _, err := readFrame(bufio.NewReader(bytes.NewReader(enc))); !errors.Is(err, errChecksum) {
+		t.Fatalf("checksum error expected, got %v", err)
+	}
+
+	for _, c := range []struct {
+		di   string
+		v    float64
+		want []byte
+	}{
+		{"00010000", 123456.78, []byte{0x78, 0x56, 0x34, 0x12}},
+		{"02010100", 220.1, []byte{0x01, 0x22}},
+		{"02030000", -1.2345, []byte{0x45, 0x23, 0x81}},
+		{"B611", 231, []byte{0x31, 0x02}},
+	} {
+		f, err := FormatFromParams(map[string]interface{}{"di": c.di})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := EncodeBCD(c.v, f)
+		if err != nil || !bytes.Equal(b, c.want) {
+			t.Fatalf("%s encode % X %v", c.di, b, err)
+		}
+		if v, err := DecodeBCD(b, f); err != nil || v != c.v {
+			t.Fatalf("%s decode %v %v", c.di, v, err)
+		}
+	}
+	if _, err := DecodeParams([]byte{0xFF, 0xFF}, map[string]interface{}{"di": "02010100"}); err == nil {
+		t.Fatal("invalid BCD should fail")
+	}
+	if _, err := FormatFromParams(map[string]interface{}{"di": "04000101"}); err == nil {
+		t.Fatal("unknown DI without length should fail")
+	}
+}
+
+func TestReadSharedBus(t *testing.T) {
+	b := newFakeBus(t)
+	b.meter("000000000001", map[string][]byte{
+		"00010000": {0x78, 0x56, 0x34, 0x12},
+		"0201FF00": {0x01, 0x22, 0x02, 0x22, 0x03, 0x22},
+		"9010":     {0x00, 0x10, 0x00, 0x00},
+	})
+	b.meter("000000000002", map[string][]byte{
+		"00010000": {0x00, 0x00, 0x01, 0x00},
+	})
+	m1 := newTestMeter(t, b, "000000000001", nil)
+	m2 := newTestMeter(t, b, "000000000002", nil)
+
+	data, err := m1.Read(map[string]interface{}{"di": "00010000"})
+	if v, _ := DecodeParams(data, map[string]interface{}{"di": "00010000"}); err != nil || v != 123456.78 {
+		t.Fatalf("meter1 energy % X %v", data, err)
+	}
+	data, err = m2.Read(map[string]interface{}{"di": "00010000"})
+	if v, _ := DecodeParams(data, map[string]interface{}{"di": "00010000"}); err != nil || v != 100 {
+		t.Fatalf("meter2 energy % X %v", data, err)
+	}
+	if m1.port != m2.port {
+		t.Fatal("meters on one bus should share the port")
+	}
+
+	// 数据块跨后续帧，按序号取C相电压
+	params := map[string]interface{}{"di": "0201FF00", "index": 2}
+	data, err = m1.Read(params)
+	if v, _ := DecodeParams(data, params); err != nil || v != 220.3 {
+		t.Fatalf("phase C voltage % X %v", data, err)
+	}
+	if data, err := m1.Read(map[string]interface{}{"di": "9010"}); err != nil || !bytes.Equal(data, []byte{0x00, 0x10, 0x00, 0x00}) {
+		t.Fatalf("1997 read % X %v", data, err)
+	}
+
+	var er *ErrorReply
+	if _, err := m1.Read(map[string]interface{}{"di": "02800002"}); !errors.As(err, &er) || er.Code != 0x02 {
+		t.Fatalf("missing DI: %v", err)
+	}
+
+	// 校验错误后重试
+	b.mu.Lock()
+	b.corrupt = 1
+	b.mu.Unlock()
+	if _, err := m2.Read(map[string]interface{}{"di": "00010000"}); err != nil {
+		t.Fatalf("retry after checksum error: %v", err)
+	}
+}
+
+func TestAddressAndWrite(t *testing.T) {
+	b := newFakeBus(t)
+	b.meter("202501020304", map[string][]byte{})
+	m := newTestMeter(t, b, "", map[string]interface{}{"password": "02123456", "operator": "00000001"})
+	addr, err := m.ReadAddress()
+	if err != nil || addr.String() != "202501020304" {
+		t.Fatalf("read address %v %v", addr, err)
+	}
+	if err := m.Write("04000401", []byte{0x04, 0x03, 0x02, 0x01, 0x25, 0x20}, nil); err != nil {
+		t.Fatal(err)
+	}
+	b.mu.Lock()
+	w := b.writes[0]
+	b.mu.Unlock()
+	if w.Addr != addr || !bytes.Equal(w.Data[8:12], []byte{0x01, 0, 0, 0}) || !bytes.Equal(w.Data[12:], []byte{0x04, 0x03, 0x02, 0x01, 0x25, 0x20}) {
+		t.Fatalf("write frame %+v", w)
+	}
+	bad := newTestMeter(t, b, "202501020304", map[string]interface{}{"password": "02000000"})
+	var er *ErrorReply
+	if err := bad.Write("04000401", []byte{0}, nil); !errors.As(err, &er) || er.Code != 0x04 {
+		t.Fatalf("wrong password: %v", err)
+	}
+	if err := m.BroadcastTime(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if _, err := protocol.GetAdapter("dlt645", map[string]interface{}{"address": "/dev/ttyS1", "meterAddr": "12"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := protocol.GetAdapter("dlt645", map[string]interface{}{"address": "/dev/ttyS1", "meterAddr": "12X"}); err == nil {
+		t.Fatal("invalid meter address should fail")
+	}
+}
@@ -0,0 +1,201 @@
+package dlt645
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DI 数据标识：2007 为4字节 DI3~DI0，1997 为2字节
+type DI struct {
+	Value uint32
+	V1997 bool
+}
+
+// ParseDI 解析十六进制数据标识，8位为2007、4位为1997，允许 "-"、"." 分隔
+func ParseDI(raw interface{}) (DI, error) {
+	s, ok := raw.(string)
+	if !ok {
+		return DI{}, fmt.Errorf("dlt645: data identifier must be a hex string, got %v", raw)
+	}
+	s = strings.NewReplacer("-", "", ".", "", " ", "").Replace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(s) != 8 && len(s) != 4 {
+		return DI{}, fmt.Errorf("dlt645: data identifier %q must have 8 (2007) or 4 (1997) hex digits", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return DI{}, fmt.Errorf("dlt645: invalid data identifier %q", s)
+	}
+	return DI{Value: uint32(v), V1997: len(s) == 4}, nil
+}
+
+// Bytes 报文中的数据标识，DI0 在前
+func (d DI) Bytes() []byte {
+	if d.V1997 {
+		return []byte{byte(d.Value), byte(d.Value >> 8)}
+	}
+	return []byte{byte(d.Value), byte(d.Value >> 8), byte(d.Value >> 16), byte(d.Value >> 24)}
+}
+
+func (d DI) String() string {
+	if d.V1997 {
+		return fmt.Sprintf("%04X", d.Value)
+	}
+	return fmt.Sprintf("%08X", d.Value)
+}
+
+// Format 数据格式：BCD 字节数、小数位数，Signed 时最高位为符号位
+type Format struct {
+	Length   int
+	Decimals int
+	Signed   bool
+	Unit     string
+}
+
+// LookupFormat 常用数据标识的格式：电能、电压、电流、功率、功率因数、频率等。
+// 数据块标识（如 0201FF00）返回单个元素的格式
+func LookupFormat(d DI) (Format, bool) {
+	v := d.Value
+	if d.V1997 {
+		switch {
+		case v>>12 == 0x9:
+			return Format{4, 2, false, "kWh"}, true
+		case v&0xFFF0 == 0xB610:
+			return Format{2, 0, false, "V"}, true
+		case v&0xFFF0 == 0xB620:
+			return Format{2, 2, false, "A"}, true
+		case v&0xFFF0 == 0xB630:
+			return Format{3, 4, false, "kW"}, true
+		case v&0xFFF0 == 0xB640:
+			return Format{2, 2, false, "kvar"}, true
+		case v&0xFFF0 == 0xB650:
+			return Format{2, 3, false, ""}, true
+		}
+		return Format{}, false
+	}
+	switch {
+	case v>>24 == 0x00:
+		// 组合电能可为负
+		return Format{4, 2, v>>16 == 0, "kWh"}, true
+	case v&0xFFFF00FF == 0x02010000:
+		return Format{2, 1, false, "V"}, true
+	case v&0xFFFF00FF == 0x02020000:
+		return Format{3, 3, true, "A"}, true
+	case v&0xFFFF00FF == 0x02030000:
+		return Format{3, 4, true, "kW"}, true
+	case v&0xFFFF00FF == 0x02040000:
+		return Format{3, 4, true, "kvar"}, true
+	case v&0xFFFF00FF == 0x02050000:
+		return Format{3, 4, true, "kVA"}, true
+	case v&0xFFFF00FF == 0x02060000:
+		return Format{2, 3, true, ""}, true
+	case v&0xFFFF00FF == 0x02070000:
+		return Format{2, 1, false, "°"}, true
+	case v == 0x02800001:
+		return Format{3, 3, true, "A"}, true
+	case v == 0x02800002:
+		return Format{2, 2, false, "Hz"}, true
+	case v >= 0x02800004 && v <= 0x02800006:
+		return Format{3, 4, true, "kW"}, true
+	case v == 0x02800007:
+		return Format{2, 1, true, "℃"}, true
+	case v == 0x02800008 || v == 0x02800009:
+		return Format{2, 2, false, "V"}, true
+	}
+	return Format{}, false
+}
+
+// FormatFromParams 点位参数中的格式：先按 di 查表，length/decimals/signed 可覆盖
+func FormatFromParams(params map[string]interface{}) (Format, error) {
+	d, err := ParseDI(params["di"])
+	if err != nil {
+		return Format{}, err
+	}
+	f, ok := LookupFormat(d)
+	if v, set := toInt(params["length"]); set {
+		f.Length, ok = v, true
+	}
+	if v, set := toInt(params["decimals"]); set {
+		f.Decimals = v
+	}
+	if v, set := params["signed"].(bool); set {
+		f.Signed = v
+	}
+	if !ok || f.Length <= 0 {
+		return f, fmt.Errorf("dlt645: unknown format for DI %s, set length/decimals", d)
+	}
+	return f, nil
+}
+
+// DecodeBCD 解码低字节在前的 BCD 数据
+func DecodeBCD(b []byte, f Format) (float64, error) {
+	if len(b) != f.Length {
+		return 0, fmt.Errorf("dlt645: data length %d, want %d", len(b), f.Length)
+	}
+	var n uint64
+	neg := false
+	for i := len(b) - 1; i >= 0; i-- {
+		c := b[i]
+		if i == len(b)-1 && f.Signed {
+			neg, c = c&0x80 != 0, c&0x7F
+		}
+		if c>>4 > 9 || c&0x0F > 9 {
+			return 0, fmt.Errorf("dlt645: invalid BCD % X", b)
+		}
+		n = n*100 + uint64(c>>4)*10 + uint64(c&0x0F)
+	}
+	v := float64(n) / math.Pow10(f.Decimals)
+	if neg {
+		v = -v
+	}
+	return v, nil
+}
+
+// EncodeBCD DecodeBCD 的逆过程
+func EncodeBCD(v float64, f Format) ([]byte, error) {
+	neg := v < 0
+	if neg && !f.Signed {
+		return nil, fmt.Errorf("dlt645: negative value %v for unsigned format", v)
+	}
+	n := uint64(math.Round(math.Abs(v) * math.Pow10(f.Decimals)))
+	b := make([]byte, f.Length)
+	for i := range b {
+		b[i] = byte(n%10) | byte(n/10%10)<<4
+		n /= 100
+	}
+	if n != 0 || (f.Signed && b[len(b)-1]&0x80 != 0) {
+		return nil, fmt.Errorf("dlt645: value %v overflows %d bytes", v, f.Length)
+	}
+	if neg {
+		b[len(b)-1] |= 0x80
+	}
+	return b, nil
+}
+
+// DecodeParams 按点位参数解码 Read 返回的数据，供解析流程使用
+func DecodeParams(data []byte, params map[string]interface{}) (float64, error) {
+	f, err := FormatFromParams(params)
+	if err != nil {
+		return 0, err
+	}
+	return DecodeBCD(data, f)
+}
+
+// toInt 兼容 int/float64/字符串配置
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
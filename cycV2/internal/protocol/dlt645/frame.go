@@ -0,0 +1,184 @@
+// Package dlt645 DL/T 645-2007（兼容1997）多功能电能表通信协议主站
+package dlt645
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 控制码功能部分（D4~D0），应答帧 D7=1，异常应答 D6=1，有后续帧 D5=1
+const (
+	CtrlBroadcastTime byte = 0x08
+	CtrlRead          byte = 0x11 // 2007 读数据
+	CtrlReadFollow    byte = 0x12 // 2007 读后续数据
+	CtrlReadAddress   byte = 0x13 // 2007 读通信地址
+	CtrlWrite         byte = 0x14 // 2007 写数据
+	CtrlRead97        byte = 0x01 // 1997 读数据
+	CtrlReadFollow97  byte = 0x02 // 1997 读后续数据
+	CtrlWrite97       byte = 0x04 // 1997 写数据
+
+	ctrlReply    byte = 0x80
+	ctrlAbnormal byte = 0x40
+	ctrlFollow   byte = 0x20
+)
+
+// Address 表地址，6字节BCD，低字节在前；半字节 0xA 为通配
+type Address [6]byte
+
+var (
+	// WildcardAddress 全通配地址，总线上只有一只表时用于读地址或直接读数
+	WildcardAddress = Address{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}
+	// BroadcastAddress 广播地址（广播校时）
+	BroadcastAddress = Address{0x99, 0x99, 0x99, 0x99, 0x99, 0x99}
+)
+
+// ParseAddress 解析12位表地址（高位在前，不足左补0），字符 A 表示该位通配
+func ParseAddress(s string) (Address, error) {
+	var a Address
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) > 12 {
+		return a, fmt.Errorf("dlt645: address %q longer than 12 digits", s)
+	}
+	s = strings.Repeat("0", 12-len(s)) + s
+	for i := 0; i < 6; i++ {
+		var b byte
+		for _, c := range s[10-2*i : 12-2*i] {
+			switch {
+			case c >= '0' && c <= '9':
+				b = b<<4 | byte(c-'0')
+			case c == 'A':
+				b = b<<4 | 0xA
+			default:
+				return a, fmt.Errorf("dlt645: invalid address %q", s)
+			}
+		}
+		a[i] = b
+	}
+	return a, nil
+}
+
+func (a Address) String() string {
+	return fmt.Sprintf("%02X%02X%02X%02X%02X%02X", a[5], a[4], a[3], a[2], a[1], a[0])
+}
+
+// Match 应答地址是否符合请求地址（通配半字节不比较）
+func (a Address) Match(reply Address) bool {
+	for i := range a {
+		if a[i]&0xF0 != 0xA0 && a[i]&0xF0 != reply[i]&0xF0 {
+			return false
+		}
+		if a[i]&0x0F != 0x0A && a[i]&0x0F != reply[i]&0x0F {
+			return false
+		}
+	}
+	return true
+}
+
+// Frame 一帧报文，Data 为去掉 0x33 偏移后的数据域
+type Frame struct {
+	Addr    Address
+	Control byte
+	Data    []byte
+}
+
+// Encode 编码为带前导唤醒符的帧
+func (f Frame) Encode(preamble int) []byte {
+	b := make([]byte, 0, preamble+12+len(f.Data))
+	for i := 0; i < preamble; i++ {
+		b = append(b, 0xFE)
+	}
+	start := len(b)
+	b = append(b, 0x68)
+	b = append(b, f.Addr[:]...)
+	b = append(b, 0x68, f.Control, byte(len(f.Data)))
+	for _, d := range f.Data {
+		b = append(b, d+0x33)
+	}
+	return append(b, checksum(b[start:]), 0x16)
+}
+
+func checksum(b []byte) byte {
+	var cs byte
+	for _, c := range b {
+		cs += c
+	}
+	return cs
+}
+
+var errChecksum = errors.New("dlt645: checksum mismatch")
+
+// readFrame 读取一帧：跳过前导 0xFE 与噪声直到起始符，校验第二起始符、结束符与校验和
+func readFrame(r *bufio.Reader) (Frame, error) {
+	var f Frame
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return f, err
+		}
+		if c == 0x68 {
+			break
+		}
+	}
+	hdr := make([]byte, 10)
+	hdr[0] = 0x68
+	if _, err := fullRead(r, hdr[1:]); err != nil {
+		return f, err
+	}
+	if hdr[7] != 0x68 {
+		return f, fmt.Errorf("dlt645: bad frame header % X", hdr)
+	}
+	n := int(hdr[9])
+	body := make([]byte, n+2)
+	if _, err := fullRead(r, body); err != nil {
+		return f, err
+	}
+	if body[n+1] != 0x16 {
+		return f, fmt.Errorf("dlt645: bad end byte 0x%02X", body[n+1])
+	}
+	if checksum(append(hdr, body[:n]...)) != body[n] {
+		return f, errChecksum
+	}
+	copy(f.Addr[:], hdr[1:7])
+	f.Control = hdr[8]
+	f.Data = make([]byte, n)
+	for i, d := range body[:n] {
+		f.Data[i] = d - 0x33
+	}
+	return f, nil
+}
+
+// fullRead 串口读超时返回的错误由调用方判断，这里只负责读满
+func fullRead(r *bufio.Reader, b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		m, err := r.Read(b[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// ErrorReply 异常应答，Code 为错误信息字
+type ErrorReply struct {
+	Code byte
+}
+
+var errorBits = []string{"other", "no data requested", "unauthorized", "baud rate unchangeable",
+	"year zone overflow", "day period overflow", "tariff overflow"}
+
+func (e *ErrorReply) Error() string {
+	var reasons []string
+	for i, s := range errorBits {
+		if e.Code&(1<<i) != 0 {
+			reasons = append(reasons, s)
+		}
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "unknown")
+	}
+	return fmt.Sprintf("dlt645: meter error 0x%02X (%s)", e.Code, strings.Join(reasons, ", "))
+}
@@ -0,0 +1,500 @@
+package dnp3
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 应用层功能码
+const (
+	FuncConfirm             = 0x00
+	FuncRead                = 0x01
+	FuncWrite               = 0x02
+	FuncSelect              = 0x03
+	FuncOperate             = 0x04
+	FuncDirectOperate       = 0x05
+	FuncDirectOperateNR     = 0x06
+	FuncColdRestart         = 0x0D
+	FuncEnableUnsolicited   = 0x14
+	FuncDisableUnsolicited  = 0x15
+	FuncDelayMeasure        = 0x17
+	FuncRecordCurrentTime   = 0x18
+	FuncResponse            = 0x81
+	FuncUnsolicitedResponse = 0x82
+)
+
+// 应用控制字
+const (
+	appFIR = 0x80
+	appFIN = 0x40
+	appCON = 0x20
+	appUNS = 0x10
+)
+
+// IIN 内部指示，低字节为IIN1，高字节为IIN2
+type IIN uint16
+
+const (
+	IINAllStations         IIN = 1 << 0
+	IINClass1Events        IIN = 1 << 1
+	IINClass2Events        IIN = 1 << 2
+	IINClass3Events        IIN = 1 << 3
+	IINNeedTime            IIN = 1 << 4
+	IINLocalControl        IIN = 1 << 5
+	IINDeviceTrouble       IIN = 1 << 6
+	IINDeviceRestart       IIN = 1 << 7
+	IINNoFuncCodeSupport   IIN = 1 << 8
+	IINObjectUnknown       IIN = 1 << 9
+	IINParameterError      IIN = 1 << 10
+	IINEventBufferOverflow IIN = 1 << 11
+	IINAlreadyExecuting    IIN = 1 << 12
+	IINConfigCorrupt       IIN = 1 << 13
+
+	iinRequestErrors = IINNoFuncCodeSupport | IINObjectUnknown | IINParameterError
+)
+
+func (i IIN) String() string {
+	names := []string{"ALL_STATIONS", "CLASS1_EVENTS", "CLASS2_EVENTS", "CLASS3_EVENTS", "NEED_TIME", "LOCAL_CONTROL",
+		"DEVICE_TROUBLE", "DEVICE_RESTART", "NO_FUNC_CODE_SUPPORT", "OBJECT_UNKNOWN", "PARAMETER_ERROR",
+		"EVENT_BUFFER_OVERFLOW", "ALREADY_EXECUTING", "CONFIG_CORRUPT"}
+	var s []string
+	for n, name := range names {
+		if i&(1<<n) != 0 {
+			s = append(s, name)
+		}
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// 品质标志
+const (
+	FlagOnline        = 0x01
+	FlagRestart       = 0x02
+	FlagCommLost      = 0x04
+	FlagRemoteForced  = 0x08
+	FlagLocalForced   = 0x10
+	FlagChatterFilter = 0x20 // 开关量
+	FlagOverRange     = 0x20 // 模拟量；计数器为 ROLLOVER
+	FlagReferenceErr  = 0x40 // 模拟量
+	FlagState         = 0x80 // 开关量状态
+)
+
+// 静态数据组号，事件组按 staticGroup 归并
+const (
+	GroupBinaryInput        = 1
+	GroupDoubleBitInput     = 3
+	GroupBinaryOutput       = 10
+	GroupCROB               = 12
+	GroupCounter            = 20
+	GroupFrozenCounter      = 21
+	GroupAnalogInput        = 30
+	GroupAnalogOutputStatus = 40
+	GroupAnalogOutput       = 41
+	groupTime               = 50
+	groupCTO                = 51
+	groupDelay              = 52
+	groupClass              = 60
+	groupIIN                = 80
+)
+
+// staticGroup 事件组映射到对应静态组，同一点位的静态值与事件写入同一缓存
+func staticGroup(g uint8) uint8 {
+	switch g {
+	case 2:
+		return GroupBinaryInput
+	case 4:
+		return GroupDoubleBitInput
+	case 11:
+		return GroupBinaryOutput
+	case 22:
+		return GroupCounter
+	case 23:
+		return GroupFrozenCounter
+	case 32:
+		return GroupAnalogInput
+	case 42:
+		return GroupAnalogOutputStatus
+	}
+	return g
+}
+
+// Object 应用层对象解码结果
+type Object struct {
+	Group     uint8 // 报文中的组号
+	Variation uint8
+	Index     uint32
+	Flags     uint8
+	Value     float64
+	Time      time.Time // 事件时标，无时标为零值
+	Status    uint8     // 控制对象（g12/g41）的状态码
+}
+
+// fragment 一个应用层报文
+type fragment struct {
+	control  byte
+	function byte
+	iin      IIN
+	objects  []byte
+	parsed   []Object // 接收协程解析的对象
+	parseErr error
+}
+
+func (f *fragment) seq() uint8 { return f.control & 0x0F }
+
+func parseFragment(b []byte) (*fragment, error) {
+	if len(b) < 2 {
+		return nil, errors.New("dnp3: short application fragment")
+	}
+	f := &fragment{control: b[0], function: b[1], objects: b[2:]}
+	if f.function == FuncResponse || f.function == FuncUnsolicitedResponse {
+		if len(b) < 4 {
+			return nil, errors.New("dnp3: short response fragment")
+		}
+		f.iin = IIN(b[2]) | IIN(b[3])<<8
+		f.objects = b[4:]
+	}
+	return f, nil
+}
+
+// 对象数值编码
+const (
+	valNone = iota
+	valU16
+	valU32
+	valI16
+	valI32
+	valF32
+	valF64
+)
+
+// 时标编码
+const (
+	timeNone = iota
+	time48   // 绝对时标
+	time16   // 相对于 CTO 的毫秒偏移
+)
+
+type layout struct {
+	flags  bool
+	val    byte
+	tm     byte
+	status bool // 值后跟控制状态
+}
+
+func (l layout) size() int {
+	n := map[byte]int{valNone: 0, valU16: 2, valU32: 4, valI16: 2, valI32: 4, valF32: 4, valF64: 8}[l.val]
+	if l.flags {
+		n++
+	}
+	if l.status {
+		n++
+	}
+	return n + map[byte]int{timeNone: 0, time48: 6, time16: 2}[l.tm]
+}
+
+func gv(g, v uint8) uint16 { return uint16(g)<<8 | uint16(v) }
+
+// layouts 支持的定长对象
+var layouts = map[uint16]layout{
+	gv(1, 2): {flags: true},
+	gv(2, 1): {flags: true}, gv(2, 2): {flags: true, tm: time48}, gv(2, 3): {flags: true, tm: time16},
+	gv(3, 2): {flags: true},
+	gv(4, 1): {flags: true}, gv(4, 2): {flags: true, tm: time48}, gv(4, 3): {flags: true, tm: time16},
+	gv(10, 2): {flags: true},
+	gv(11, 1): {flags: true}, gv(11, 2): {flags: true, tm: time48},
+
+	gv(20, 1): {flags: true, val: valU32}, gv(20, 2): {flags: true, val: valU16},
+	gv(20, 5): {val: valU32}, gv(20, 6): {val: valU16},
+	gv(21, 1): {flags: true, val: valU32}, gv(21, 2): {flags: true, val: valU16},
+	gv(21, 5): {flags: true, val: valU32, tm: time48}, gv(21, 6): {flags: true, val: valU16, tm: time48},
+	gv(21, 9): {val: valU32}, gv(21, 10): {val: valU16},
+	gv(22, 1): {flags: true, val: valU32}, gv(22, 2): {flags: true, val: valU16},
+	gv(22, 5): {flags: true, val: valU32, tm: time48}, gv(22, 6): {flags: true, val: valU16, tm: time48},
+	gv(23, 1): {flags: true, val: valU32}, gv(23, 2): {flags: true, val: valU16},
+	gv(23, 5): {flags: true, val: valU32, tm: time48}, gv(23, 6): {flags: true, val: valU16, tm: time48},
+
+	gv(30, 1): {flags: true, val: valI32}, gv(30, 2): {flags: true, val: valI16},
+	gv(30, 3): {val: valI32}, gv(30, 4): {val: valI16},
+	gv(30, 5): {flags: true, val: valF32}, gv(30, 6): {flags: true, val: valF64},
+	gv(32, 1): {flags: true, val: valI32}, gv(32, 2): {flags: true, val: valI16},
+	gv(32, 3): {flags: true, val: valI32, tm: time48}, gv(32, 4): {flags: true, val: valI16, tm: time48},
+	gv(32, 5): {flags: true, val: valF32}, gv(32, 6): {flags: true, val: valF64},
+	gv(32, 7): {flags: true, val: valF32, tm: time48}, gv(32, 8): {flags: true, val: valF64, tm: time48},
+	gv(40, 1): {flags: true, val: valI32}, gv(40, 2): {flags: true, val: valI16},
+	gv(40, 3): {flags: true, val: valF32}, gv(40, 4): {flags: true, val: valF64},
+	gv(41, 1): {val: valI32, status: true}, gv(41, 2): {val: valI16, status: true},
+	gv(41, 3): {val: valF32, status: true}, gv(41, 4): {val: valF64, status: true},
+	gv(42, 1): {flags: true, val: valI32}, gv(42, 2): {flags: true, val: valI16},
+	gv(42, 3): {flags: true, val: valI32, tm: time48}, gv(42, 4): {flags: true, val: valI16, tm: time48},
+	gv(42, 5): {flags: true, val: valF32}, gv(42, 6): {flags: true, val: valF64},
+	gv(42, 7): {flags: true, val: valF32, tm: time48}, gv(42, 8): {flags: true, val: valF64, tm: time48},
+
+	gv(50, 1): {tm: time48}, gv(50, 3): {tm: time48},
+	gv(51, 1): {tm: time48}, gv(51, 2): {tm: time48},
+	gv(52, 1): {val: valU16}, gv(52, 2): {val: valU16},
+}
+
+// 按位打包的对象及每个点位占用的位数
+var packed = map[uint16]int{gv(1, 1): 1, gv(3, 1): 2, gv(10, 1): 1, gv(80, 1): 1}
+
+const crobSize = 11
+
+// parseObjects 解析对象区。CTO（g51）只用于计算后续相对时标，不出现在结果中
+func parseObjects(b []byte) ([]Object, error) {
+	var objs []Object
+	var cto time.Time
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return objs, errors.New("dnp3: truncated object header")
+		}
+		g, v, q := b[0], b[1], b[2]
+		b = b[3:]
+		prefix, rng := (q>>4)&0x07, q&0x0F
+		var start, count uint32
+		ranged := false
+		switch rng {
+		case 0x00, 0x01, 0x02:
+			n := 1 << rng
+			if len(b) < 2*n {
+				return objs, errors.New("dnp3: truncated range")
+			}
+			stop := readUint(b[n : 2*n])
+			start, b = readUint(b[:n]), b[2*n:]
+			if stop < start {
+				return objs, fmt.Errorf("dnp3: invalid range %d-%d", start, stop)
+			}
+			count, ranged = stop-start+1, true
+		case 0x06:
+		case 0x07, 0x08, 0x09:
+			n := 1 << (rng - 7)
+			if len(b) < n {
+				return objs, errors.New("dnp3: truncated count")
+			}
+			count = readUint(b[:n])
+			b = b[n:]
+		default:
+			return objs, fmt.Errorf("dnp3: unsupported qualifier 0x%02X", q)
+		}
+		var prefixLen int
+		switch prefix {
+		case 0:
+		case 1, 2, 3:
+			prefixLen = 1 << (prefix - 1)
+		default:
+			return objs, fmt.Errorf("dnp3: unsupported qualifier 0x%02X", q)
+		}
+
+		if bits, ok := packed[gv(g, v)]; ok {
+			if prefixLen != 0 || !ranged {
+				return objs, fmt.Errorf("dnp3: g%dv%d requires range qualifier", g, v)
+			}
+			n := int((count*uint32(bits) + 7) / 8)
+			if len(b) < n {
+				return objs, fmt.Errorf("dnp3: truncated g%dv%d", g, v)
+			}
+			for i := uint32(0); i < count; i++ {
+				bit := uint(i) * uint(bits)
+				val := (b[bit/8] >> (bit % 8)) & byte(1<<bits-1)
+				objs = append(objs, Object{Group: g, Variation: v, Index: start + i, Flags: FlagOnline, Value: float64(val)})
+			}
+			b = b[n:]
+			continue
+		}
+
+		size := 0
+		l, ok := layouts[gv(g, v)]
+		switch {
+		case ok:
+			size = l.size()
+		case g == GroupCROB && v == 1:
+			size = crobSize
+		case g == groupClass || v == 0:
+			continue // 请求中的类数据/全部变体，无对象数据
+		default:
+			return objs, fmt.Errorf("dnp3: unsupported object g%dv%d", g, v)
+		}
+		for i := uint32(0); i < count; i++ {
+			idx := start + i
+			if prefixLen > 0 {
+				if len(b) < prefixLen {
+					return objs, errors.New("dnp3: truncated index prefix")
+				}
+				idx = readUint(b[:prefixLen])
+				b = b[prefixLen:]
+			} else if !ranged {
+				idx = i
+			}
+			if len(b) < size {
+				return objs, fmt.Errorf("dnp3: truncated g%dv%d", g, v)
+			}
+			o := Object{Group: g, Variation: v, Index: idx}
+			if g == GroupCROB {
+				o.Value, o.Status = float64(b[0]), b[10]
+			} else {
+				decodeObject(&o, l, b[:size], cto)
+			}
+			b = b[size:]
+			if g == groupCTO {
+				cto = o.Time
+				continue
+			}
+			objs = append(objs, o)
+		}
+	}
+	return objs, nil
+}
+
+func decodeObject(o *Object, l layout, b []byte, cto time.Time) {
+	o.Flags = FlagOnline
+	if l.flags {
+		o.Flags, b = b[0], b[1:]
+	}
+	switch l.val {
+	case valNone:
+		switch staticGroup(o.Group) {
+		case GroupBinaryInput, GroupBinaryOutput:
+			o.Value = float64(o.Flags >> 7)
+		case GroupDoubleBitInput:
+			o.Value = float64(o.Flags >> 6)
+		}
+	case valU16:
+		o.Value, b = float64(binary.LittleEndian.Uint16(b)), b[2:]
+	case valU32:
+		o.Value, b = float64(binary.LittleEndian.Uint32(b)), b[4:]
+	case valI16:
+		o.Value, b = float64(int16(binary.LittleEndian.Uint16(b))), b[2:]
+	case valI32:
+		o.Value, b = float64(int32(binary.LittleEndian.Uint32(b))), b[4:]
+	case valF32:
+		o.Value, b = float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), b[4:]
+	case valF64:
+		o.Value, b = math.Float64frombits(binary.LittleEndian.Uint64(b)), b[8:]
+	}
+	if o.Group == groupDelay && o.Variation == 1 {
+		o.Value *= 1000 // 粗延时单位为秒，统一为毫秒
+	}
+	if l.status {
+		o.Status, b = b[0], b[1:]
+	}
+	switch l.tm {
+	case time48:
+		o.Time = readTime48(b)
+	case time16:
+		if !cto.IsZero() {
+			o.Time = cto.Add(time.Duration(binary.LittleEndian.Uint16(b)) * time.Millisecond)
+		}
+	}
+}
+
+func readUint(b []byte) uint32 {
+	var v uint32
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint32(b[i])
+	}
+	return v
+}
+
+// readTime48 自1970-01-01 UTC起的毫秒数，6字节小端
+func readTime48(b []byte) time.Time {
+	ms := int64(readUint(b[:4])) | int64(binary.LittleEndian.Uint16(b[4:6]))<<32
+	return time.UnixMilli(ms).UTC()
+}
+
+func appendTime48(b []byte, t time.Time) []byte {
+	ms := uint64(t.UnixMilli())
+	b = binary.LittleEndian.AppendUint32(b, uint32(ms))
+	return binary.LittleEndian.AppendUint16(b, uint16(ms>>32))
+}
+
+// 请求对象头
+
+// classHeader 类数据读取/非请求使能
+func classHeader(b []byte, class int) []byte {
+	return append(b, groupClass, byte(class+1), 0x06)
+}
+
+// rangeHeader 起止序号限定词（0x00/0x01）
+func rangeHeader(b []byte, g, v uint8, start, stop uint32) []byte {
+	if stop <= 0xFF {
+		return append(b, g, v, 0x00, byte(start), byte(stop))
+	}
+	b = append(b, g, v, 0x01)
+	b = binary.LittleEndian.AppendUint16(b, uint16(start))
+	return binary.LittleEndian.AppendUint16(b, uint16(stop))
+}
+
+// prefixHeader 单个对象带序号前缀（0x17/0x28），调用方随后追加对象数据
+func prefixHeader(b []byte, g, v uint8, index uint32) []byte {
+	if index <= 0xFF {
+		return append(b, g, v, 0x17, 1, byte(index))
+	}
+	b = append(b, g, v, 0x28, 1, 0)
+	return binary.LittleEndian.AppendUint16(b, uint16(index))
+}
+
+// CROB 控制继电器输出块（g12v1）
+type CROB struct {
+	Code   uint8 // 操作类型（低4位）、Queue/Clear、TCC（高2位）
+	Count  uint8
+	OnMs   uint32
+	OffMs  uint32
+	Status uint8
+}
+
+// CROB 操作类型与 TCC
+const (
+	OpNul      = 0x00
+	OpPulseOn  = 0x01
+	OpPulseOff = 0x02
+	OpLatchOn  = 0x03
+	OpLatchOff = 0x04
+	TCCClose   = 0x40
+	TCCTrip    = 0x80
+)
+
+func (c CROB) appendTo(b []byte) []byte {
+	b = append(b, c.Code, c.Count)
+	b = binary.LittleEndian.AppendUint32(b, c.OnMs)
+	b = binary.LittleEndian.AppendUint32(b, c.OffMs)
+	return append(b, c.Status)
+}
+
+// appendAnalogOutput g41 模拟量输出块
+func appendAnalogOutput(b []byte, v uint8, value float64) ([]byte, error) {
+	switch v {
+	case 1:
+		if value < math.MinInt32 || value > math.MaxInt32 {
+			return nil, fmt.Errorf("dnp3: value %v out of int32 range", value)
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(int32(math.Round(value))))
+	case 2:
+		if value < math.MinInt16 || value > math.MaxInt16 {
+			return nil, fmt.Errorf("dnp3: value %v out of int16 range", value)
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(int16(math.Round(value))))
+	case 3:
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(value)))
+	case 4:
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(value))
+	default:
+		return nil, fmt.Errorf("dnp3: unsupported analog output variation %d", v)
+	}
+	return append(b, 0), nil
+}
+
+// CommandStatus 控制命令状态码
+type CommandStatus uint8
+
+func (s CommandStatus) Error() string {
+	names := map[CommandStatus]string{1: "TIMEOUT", 2: "NO_SELECT", 3: "FORMAT_ERROR", 4: "NOT_SUPPORTED",
+		5: "ALREADY_ACTIVE", 6: "HARDWARE_ERROR", 7: "LOCAL", 8: "TOO_MANY_OPS", 9: "NOT_AUTHORIZED",
+		10: "AUTOMATION_INHIBIT", 11: "PROCESSING_LIMITED", 12: "OUT_OF_RANGE"}
+	if n, ok := names[s]; ok {
+		return "dnp3: command status " + n
+	}
+	return fmt.Sprintf("dnp3: command status %d", uint8(s))
+}
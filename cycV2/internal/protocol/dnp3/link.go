@@ -0,0 +1,200 @@
+// Package dnp3 实现 DNP3 主站：数据链路层（FT3帧、CRC）、传输层分段重组、
+// 应用层对象编解码，以及类数据轮询、非请求响应、对时和控制命令
+package dnp3
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	startByte0 = 0x05
+	startByte1 = 0x64
+
+	maxLinkData    = 250 // 链路帧用户数据最大长度
+	maxSegmentData = maxLinkData - 1
+	blockSize      = 16
+)
+
+// 链路控制字
+const (
+	linkDir = 0x80 // 主站发出
+	linkPrm = 0x40 // 启动站
+	linkFCB = 0x20
+	linkFCV = 0x10
+)
+
+// 链路功能码（启动站）
+const (
+	linkResetLinkStates     = 0x00
+	linkTestLinkStates      = 0x02
+	linkConfirmedUserData   = 0x03
+	linkUnconfirmedUserData = 0x04
+	linkRequestLinkStatus   = 0x09
+)
+
+// 链路功能码（从动站）
+const (
+	linkAck          = 0x00
+	linkNack         = 0x01
+	linkStatus       = 0x0B
+	linkNotSupported = 0x0F
+)
+
+// 传输层头
+const (
+	transFIN = 0x80
+	transFIR = 0x40
+)
+
+var errCRC = errors.New("dnp3: crc error")
+
+var crcTable = func() (t [256]uint16) {
+	for i := range t {
+		c := uint16(i)
+		for j := 0; j < 8; j++ {
+			if c&1 != 0 {
+				c = c>>1 ^ 0xA6BC
+			} else {
+				c >>= 1
+			}
+		}
+		t[i] = c
+	}
+	return
+}()
+
+// crc16 DNP CRC（多项式0x3D65，反向，结果取反，低字节在前）
+func crc16(b []byte) uint16 {
+	var c uint16
+	for _, x := range b {
+		c = c>>8 ^ crcTable[byte(c)^x]
+	}
+	return ^c
+}
+
+// linkFrame 一个链路帧
+type linkFrame struct {
+	ctrl byte
+	dst  uint16
+	src  uint16
+	data []byte
+}
+
+func (f linkFrame) function() byte { return f.ctrl & 0x0F }
+
+// encode 帧头8字节+CRC，用户数据每16字节一块，每块后跟CRC
+func (f linkFrame) encode() []byte {
+	out := make([]byte, 0, 10+len(f.data)+2*((len(f.data)+blockSize-1)/blockSize))
+	out = append(out, startByte0, startByte1, byte(5+len(f.data)), f.ctrl)
+	out = binary.LittleEndian.AppendUint16(out, f.dst)
+	out = binary.LittleEndian.AppendUint16(out, f.src)
+	out = binary.LittleEndian.AppendUint16(out, crc16(out))
+	for i := 0; i < len(f.data); i += blockSize {
+		blk := f.data[i:min(i+blockSize, len(f.data))]
+		out = append(out, blk...)
+		out = binary.LittleEndian.AppendUint16(out, crc16(blk))
+	}
+	return out
+}
+
+// readLinkFrame 读取一帧，丢弃起始字节前的杂字节；CRC错误返回 errCRC，调用方可继续读
+func readLinkFrame(r *bufio.Reader) (linkFrame, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return linkFrame{}, err
+		}
+		if b != startByte0 {
+			continue
+		}
+		if next, err := r.Peek(1); err != nil {
+			return linkFrame{}, err
+		} else if next[0] == startByte1 {
+			break
+		}
+	}
+	hdr := make([]byte, 10)
+	hdr[0] = startByte0
+	if _, err := io.ReadFull(r, hdr[1:]); err != nil {
+		return linkFrame{}, err
+	}
+	if crc16(hdr[:8]) != binary.LittleEndian.Uint16(hdr[8:]) {
+		return linkFrame{}, errCRC
+	}
+	if hdr[2] < 5 {
+		return linkFrame{}, fmt.Errorf("dnp3: invalid link length %d", hdr[2])
+	}
+	f := linkFrame{
+		ctrl: hdr[3],
+		dst:  binary.LittleEndian.Uint16(hdr[4:]),
+		src:  binary.LittleEndian.Uint16(hdr[6:]),
+	}
+	n := int(hdr[2]) - 5
+	f.data = make([]byte, 0, n)
+	blk := make([]byte, blockSize+2)
+	for n > 0 {
+		size := min(n, blockSize)
+		if _, err := io.ReadFull(r, blk[:size+2]); err != nil {
+			return linkFrame{}, err
+		}
+		if crc16(blk[:size]) != binary.LittleEndian.Uint16(blk[size:]) {
+			return linkFrame{}, errCRC
+		}
+		f.data = append(f.data, blk[:size]...)
+		n -= size
+	}
+	return f, nil
+}
+
+// segment 应用层报文按传输层分段
+func segment(apdu []byte, seq *uint8) [][]byte {
+	var segs [][]byte
+	for i := 0; i == 0 || i < len(apdu); i += maxSegmentData {
+		end := min(i+maxSegmentData, len(apdu))
+		hdr := *seq & 0x3F
+		if i == 0 {
+			hdr |= transFIR
+		}
+		if end == len(apdu) {
+			hdr |= transFIN
+		}
+		*seq++
+		segs = append(segs, append([]byte{hdr}, apdu[i:end]...))
+	}
+	return segs
+}
+
+// reassembler 传输层重组
+type reassembler struct {
+	buf    []byte
+	seq    uint8
+	active bool
+}
+
+// push 输入一个传输段，完整报文返回非nil；序号不连续时丢弃已收部分
+func (r *reassembler) push(seg []byte) []byte {
+	if len(seg) == 0 {
+		return nil
+	}
+	hdr, data := seg[0], seg[1:]
+	switch {
+	case hdr&transFIR != 0:
+		r.buf = append(r.buf[:0], data...)
+		r.active = true
+	case r.active && hdr&0x3F == (r.seq+1)&0x3F:
+		r.buf = append(r.buf, data...)
+	default:
+		r.active = false
+		return nil
+	}
+	r.seq = hdr & 0x3F
+	if hdr&transFIN == 0 {
+		return nil
+	}
+	r.active = false
+	return append([]byte(nil), r.buf...)
+}
@@ -0,0 +1,876 @@
+package dnp3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grid-x/serial"
+
+	"cycV2/internal/protocol"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("dnp3", NewDNP3Adapter)
+}
+
+var errSessionClosed = errors.New("dnp3: channel closed")
+
+// Event 一个点位更新（轮询响应或非请求响应），Time 无事件时标时为接收时间
+type Event struct {
+	Object
+	Unsolicited bool
+}
+
+// EventHandler 事件回调，在接收协程中调用，不应阻塞
+type EventHandler func(Event)
+
+type pointKey struct {
+	group uint8 // 静态组号
+	index uint32
+}
+
+// session 一条已打开的通道（TCP连接或串口）
+type session struct {
+	rwc      io.ReadWriteCloser
+	writeMu  sync.Mutex
+	transSeq uint8
+	resp     chan *fragment // 请求响应
+	lastRecv atomic.Int64
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (s *session) close() {
+	s.once.Do(func() {
+		s.rwc.Close()
+		close(s.done)
+	})
+}
+
+func (s *session) alive() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// DNP3Adapter DNP3主站，实现 protocol.ProtocolAdapter 与 protocol.Subscriber。
+// 建链后禁止非请求上送、完整性轮询（Class 0123）、再使能非请求上送；
+// 收到的数据按 组号/序号 缓存，Read 返回缓存值，缓存缺失时按组/变体/序号直接读取
+type DNP3Adapter struct {
+	tcp          bool
+	addr         string
+	open         func() (io.ReadWriteCloser, error)
+	local        uint16
+	remote       uint16
+	timeout      time.Duration
+	integrity    time.Duration
+	eventPoll    time.Duration
+	eventClasses []int
+	keepAlive    time.Duration
+	unsolicited  bool
+	timeSync     string
+
+	connMu   sync.Mutex // 串行化建链
+	reqMu    sync.Mutex // 同一时刻只有一个未完成请求
+	mu       sync.Mutex
+	sess     *session
+	appSeq   uint8
+	lastUns  []byte
+	cache    map[pointKey]Event
+	handlers []EventHandler
+	subs     protocol.Subscriptions[pointKey]
+	stopCh   chan struct{}
+	syncing  atomic.Bool
+	clearing atomic.Bool
+}
+
+// NewDNP3Adapter 工厂函数
+// cfg: mode(tcp|serial，默认tcp), address(tcp默认端口20000，或串口路径), baudRate/dataBits/parity/stopBits,
+//
+//	localAddr(默认1), remoteAddr(默认10), timeoutMs, integrityPollMs, eventPollMs, eventClasses,
+//	unsolicited(默认true), timeSync(auto|lan|nonlan|none), keepAliveMs
+func NewDNP3Adapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewDNP3Master(cfg)
+}
+
+// NewDNP3Master 创建主站，返回具体类型以便注册事件回调与下发命令
+func NewDNP3Master(cfg map[string]interface{}) (*DNP3Adapter, error) {
+	addr, _ := cfg["address"].(string)
+	if addr == "" {
+		return nil, errors.New("dnp3: missing address")
+	}
+	a := &DNP3Adapter{
+		addr:         addr,
+		local:        1,
+		remote:       10,
+		timeout:      5 * time.Second,
+		eventClasses: []int{1, 2, 3},
+		keepAlive:    60 * time.Second,
+		unsolicited:  true,
+		timeSync:     "auto",
+		cache:        make(map[pointKey]Event),
+	}
+	if v, ok := toInt(cfg["localAddr"]); ok {
+		a.local = uint16(v)
+	}
+	if v, ok := toInt(cfg["remoteAddr"]); ok {
+		a.remote = uint16(v)
+	}
+	for key, dst := range map[string]*time.Duration{"timeoutMs": &a.timeout, "integrityPollMs": &a.integrity,
+		"eventPollMs": &a.eventPoll, "keepAliveMs": &a.keepAlive} {
+		if v, ok := toInt(cfg[key]); ok && v >= 0 {
+			*dst = time.Duration(v) * time.Millisecond
+		}
+	}
+	if raw, ok := cfg["eventClasses"].([]interface{}); ok {
+		a.eventClasses = a.eventClasses[:0]
+		for _, c := range raw {
+			v, ok := toInt(c)
+			if !ok || v < 1 || v > 3 {
+				return nil, fmt.Errorf("dnp3: invalid event class %v", c)
+			}
+			a.eventClasses = append(a.eventClasses, v)
+		}
+	}
+	if v, ok := cfg["unsolicited"].(bool); ok {
+		a.unsolicited = v
+	}
+	if v, ok := cfg["timeSync"].(string); ok && v != "" {
+		switch v {
+		case "auto", "lan", "nonlan", "none":
+			a.timeSync = v
+		default:
+			return nil, fmt.Errorf("dnp3: invalid timeSync %q", v)
+		}
+	}
+
+	mode, _ := cfg["mode"].(string)
+	switch mode {
+	case "", "tcp":
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			a.addr = net.JoinHostPort(addr, "20000")
+		}
+		a.tcp = true
+		a.open = func() (io.ReadWriteCloser, error) { return net.DialTimeout("tcp", a.addr, a.timeout) }
+	case "serial":
+		sc := &serial.Config{Address: addr, BaudRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, Timeout: time.Second}
+		if v, ok := toInt(cfg["baudRate"]); ok && v > 0 {
+			sc.BaudRate = v
+		}
+		if v, ok := toInt(cfg["dataBits"]); ok && v > 0 {
+			sc.DataBits = v
+		}
+		if v, ok := cfg["parity"].(string); ok && v != "" {
+			sc.Parity = v
+		}
+		if v, ok := toInt(cfg["stopBits"]); ok && v > 0 {
+			sc.StopBits = v
+		}
+		a.open = func() (io.ReadWriteCloser, error) { return serial.Open(sc) }
+	default:
+		return nil, fmt.Errorf("dnp3: unsupported mode %q", mode)
+	}
+	return a, nil
+}
+
+// OnEvent 注册事件回调
+func (a *DNP3Adapter) OnEvent(h EventHandler) {
+	a.mu.Lock()
+	a.handlers = append(a.handlers, h)
+	a.mu.Unlock()
+}
+
+// pointParams 点位参数 group（静态或事件组号）、index
+func pointParams(params map[string]interface{}) (pointKey, error) {
+	g, ok := toInt(params["group"])
+	if !ok || g <= 0 || g > 0xFF {
+		return pointKey{}, errors.New("dnp3: missing group")
+	}
+	idx, ok := toInt(params["index"])
+	if !ok || idx < 0 {
+		return pointKey{}, errors.New("dnp3: missing index")
+	}
+	k := pointKey{group: staticGroup(uint8(g)), index: uint32(idx)}
+	if !measurement(k.group) {
+		return pointKey{}, fmt.Errorf("dnp3: group %d is not a measurement group", g)
+	}
+	return k, nil
+}
+
+func measurement(g uint8) bool {
+	switch g {
+	case GroupBinaryInput, GroupDoubleBitInput, GroupBinaryOutput, GroupCounter, GroupFrozenCounter,
+		GroupAnalogInput, GroupAnalogOutputStatus:
+		return true
+	}
+	return false
+}
+
+// Subscribe 实现 protocol.Subscriber，按 params 的 group/index 订阅轮询与非请求上送
+func (a *DNP3Adapter) Subscribe(params map[string]interface{}, handler func(protocol.PointUpdate)) (func(), error) {
+	k, err := pointParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return a.subs.Add(k, handler), nil
+}
+
+// Connect 打开通道并完成启动流程
+func (a *DNP3Adapter) Connect() error {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	a.mu.Lock()
+	s := a.sess
+	a.mu.Unlock()
+	if s != nil && s.alive() {
+		return nil
+	}
+
+	rwc, err := a.open()
+	if err != nil {
+		return fmt.Errorf("dnp3: open %s: %w", a.addr, err)
+	}
+	s = &session{rwc: rwc, resp: make(chan *fragment, 16), done: make(chan struct{})}
+	s.lastRecv.Store(time.Now().UnixNano())
+	stopCh := make(chan struct{})
+	a.mu.Lock()
+	a.sess = s
+	if a.stopCh != nil {
+		close(a.stopCh)
+	}
+	a.stopCh = stopCh
+	a.lastUns = nil
+	a.mu.Unlock()
+	go a.recvLoop(s)
+
+	classes := []int{1, 2, 3}
+	if a.unsolicited {
+		if err := a.DisableUnsolicited(classes...); err != nil {
+			log.Printf("[DNP3] %s 禁止非请求上送失败: %v", a.addr, err)
+		}
+	}
+	if err := a.IntegrityPoll(); err != nil {
+		s.close()
+		return err
+	}
+	if a.unsolicited {
+		if err := a.EnableUnsolicited(classes...); err != nil {
+			log.Printf("[DNP3] %s 使能非请求上送失败: %v", a.addr, err)
+		}
+	}
+	log.Printf("[DNP3] %s 主站%d→子站%d 已建链", a.addr, a.local, a.remote)
+	go a.periodic(s, stopCh)
+	return nil
+}
+
+// Disconnect 关闭通道
+func (a *DNP3Adapter) Disconnect() error {
+	a.mu.Lock()
+	s := a.sess
+	a.sess = nil
+	if a.stopCh != nil {
+		close(a.stopCh)
+		a.stopCh = nil
+	}
+	a.mu.Unlock()
+	if s != nil {
+		s.close()
+	}
+	return nil
+}
+
+// periodic 周期完整性/事件轮询，TCP 空闲时发链路状态请求检测断线
+func (a *DNP3Adapter) periodic(s *session, stopCh <-chan struct{}) {
+	var intC, evC, kaC <-chan time.Time
+	if a.integrity > 0 {
+		t := time.NewTicker(a.integrity)
+		defer t.Stop()
+		intC = t.C
+	}
+	if a.eventPoll > 0 {
+		t := time.NewTicker(a.eventPoll)
+		defer t.Stop()
+		evC = t.C
+	}
+	if a.tcp && a.keepAlive > 0 {
+		t := time.NewTicker(a.keepAlive / 2)
+		defer t.Stop()
+		kaC = t.C
+	}
+	var kaSent time.Time
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-s.done:
+			return
+		case <-intC:
+			if err := a.IntegrityPoll(); err != nil {
+				log.Printf("[DNP3] %s 完整性轮询失败: %v", a.addr, err)
+			}
+		case <-evC:
+			if err := a.PollClasses(a.eventClasses...); err != nil {
+				log.Printf("[DNP3] %s 事件轮询失败: %v", a.addr, err)
+			}
+		case now := <-kaC:
+			last := time.Unix(0, s.lastRecv.Load())
+			switch {
+			case !kaSent.IsZero() && last.Before(kaSent) && now.Sub(kaSent) > a.timeout:
+				log.Printf("[DNP3] %s 链路状态无响应，断开", a.addr)
+				s.close()
+				return
+			case now.Sub(last) >= a.keepAlive && (kaSent.IsZero() || last.After(kaSent)):
+				kaSent = now
+				a.sendLink(s, linkDir|linkPrm|linkRequestLinkStatus, nil)
+			}
+		}
+	}
+}
+
+// IntegrityPoll 完整性轮询：Class 1/2/3 事件与 Class 0 静态数据
+func (a *DNP3Adapter) IntegrityPoll() error {
+	return a.PollClasses(1, 2, 3, 0)
+}
+
+// PollClasses 读取指定类数据，0 为静态数据
+func (a *DNP3Adapter) PollClasses(classes ...int) error {
+	var objs []byte
+	for _, c := range classes {
+		if c < 0 || c > 3 {
+			return fmt.Errorf("dnp3: invalid class %d", c)
+		}
+		objs = classHeader(objs, c)
+	}
+	_, err := a.request(FuncRead, objs)
+	return err
+}
+
+// EnableUnsolicited 使能指定类事件的非请求上送
+func (a *DNP3Adapter) EnableUnsolicited(classes ...int) error {
+	return a.unsolicitedCtl(FuncEnableUnsolicited, classes)
+}
+
+// DisableUnsolicited 禁止指定类事件的非请求上送
+func (a *DNP3Adapter) DisableUnsolicited(classes ...int) error {
+	return a.unsolicitedCtl(FuncDisableUnsolicited, classes)
+}
+
+func (a *DNP3Adapter) unsolicitedCtl(fn byte, classes []int) error {
+	var objs []byte
+	for _, c := range classes {
+		if c < 1 || c > 3 {
+			return fmt.Errorf("dnp3: invalid event class %d", c)
+		}
+		objs = classHeader(objs, c)
+	}
+	_, err := a.request(fn, objs)
+	return err
+}
+
+// ReadPoint 按组/变体/序号直接读取（变体0为子站默认变体），结果写入缓存
+func (a *DNP3Adapter) ReadPoint(group, variation uint8, index uint32) error {
+	_, err := a.request(FuncRead, rangeHeader(nil, group, variation, index, index))
+	return err
+}
+
+// SyncTime 对时。LAN 方式先记录当前时间再写 g50v3；非LAN 方式先测量传输延时再写 g50v1
+func (a *DNP3Adapter) SyncTime() error {
+	if err := a.Connect(); err != nil {
+		return err
+	}
+	return a.syncTime()
+}
+
+func (a *DNP3Adapter) syncTime() error {
+	mode := a.timeSync
+	if mode == "auto" || mode == "none" {
+		mode = "nonlan"
+		if a.tcp {
+			mode = "lan"
+		}
+	}
+	if mode == "lan" {
+		t := time.Now()
+		if _, err := a.request(FuncRecordCurrentTime, nil); err != nil {
+			return err
+		}
+		_, err := a.request(FuncWrite, appendTime48([]byte{groupTime, 3, 0x07, 1}, t))
+		return err
+	}
+	t0 := time.Now()
+	objs, err := a.request(FuncDelayMeasure, nil)
+	if err != nil {
+		return err
+	}
+	rtt := time.Since(t0)
+	var processing time.Duration
+	for _, o := range objs {
+		if o.Group == groupDelay {
+			processing = time.Duration(o.Value) * time.Millisecond
+		}
+	}
+	delay := max((rtt-processing)/2, 0)
+	_, err = a.request(FuncWrite, appendTime48([]byte{groupTime, 1, 0x07, 1}, time.Now().Add(delay)))
+	return err
+}
+
+// clearRestart 清除 IIN1.7 设备重启标志
+func (a *DNP3Adapter) clearRestart() error {
+	_, err := a.request(FuncWrite, append(rangeHeader(nil, groupIIN, 1, 7, 7), 0))
+	return err
+}
+
+// Read 读取缓存值，缓存缺失或超过 maxAgeMs 时按组/变体/序号直接读取。
+// 返回大端字节：开关量/输出状态 bool(1字节)、双点 uint8、计数器 uint32、模拟量 float32
+// params: group, index, variation, maxAgeMs
+func (a *DNP3Adapter) Read(params map[string]interface{}) ([]byte, error) {
+	if err := a.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	k, err := pointParams(params)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, _ := toInt(params["maxAgeMs"])
+	lookup := func() (Event, bool) {
+		a.mu.Lock()
+		ev, ok := a.cache[k]
+		a.mu.Unlock()
+		return ev, ok && (maxAge <= 0 || time.Since(ev.Time) <= time.Duration(maxAge)*time.Millisecond)
+	}
+	ev, ok := lookup()
+	if !ok {
+		v, _ := toInt(params["variation"])
+		if err := a.ReadPoint(k.group, uint8(v), k.index); err != nil {
+			return nil, err
+		}
+		if ev, ok = lookup(); !ok {
+			return nil, fmt.Errorf("dnp3: no data for g%d index %d", k.group, k.index)
+		}
+	}
+	if err := qualityErr(ev.Object); err != nil {
+		return nil, err
+	}
+	return valueBytes(ev.Object), nil
+}
+
+func qualityErr(o Object) error {
+	if o.Flags&FlagOnline == 0 || o.Flags&FlagCommLost != 0 {
+		return fmt.Errorf("dnp3: g%d index %d quality invalid (0x%02X)", staticGroup(o.Group), o.Index, o.Flags)
+	}
+	return nil
+}
+
+func (a *DNP3Adapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("dnp3: BatchRead not supported")
+}
+
+// Write 控制输出，address 为序号（可为空，取params["index"]）
+// params: group(12/10为CROB，41/40为模拟量输出，默认12), sbo(选择后执行), noAck(直接执行不要求响应)
+//
+//	CROB: data 1字节(非0为合/ON)，op(latch|pulse|closeTrip，默认latch)，onTimeMs，offTimeMs，count
+//	模拟量: data 为大端 int16(2字节)/float32(4字节)/float64(8字节)，variation(1~4，默认1)
+func (a *DNP3Adapter) Write(address string, data []byte, params map[string]interface{}) error {
+	idx, ok := toInt(params["index"])
+	if address != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(address))
+		if err != nil {
+			return fmt.Errorf("dnp3: invalid index %q", address)
+		}
+		idx, ok = n, true
+	}
+	if !ok || idx < 0 || idx > 0xFFFF {
+		return errors.New("dnp3: missing index")
+	}
+	sbo, _ := params["sbo"].(bool)
+	noAck, _ := params["noAck"].(bool)
+	if sbo && noAck {
+		return errors.New("dnp3: sbo and noAck are exclusive")
+	}
+	fn := byte(FuncDirectOperate)
+	if noAck {
+		fn = FuncDirectOperateNR
+	}
+	group, ok := toInt(params["group"])
+	if !ok {
+		group = GroupCROB
+	}
+	switch group {
+	case GroupCROB, GroupBinaryOutput:
+		if len(data) != 1 {
+			return fmt.Errorf("dnp3: CROB expects 1 byte, got %d", len(data))
+		}
+		c, err := crobFromParams(data[0] != 0, params)
+		if err != nil {
+			return err
+		}
+		return a.operate(fn, sbo, prefixHeader(nil, GroupCROB, 1, uint32(idx)), c.appendTo(nil))
+	case GroupAnalogOutput, GroupAnalogOutputStatus:
+		var value float64
+		switch len(data) {
+		case 2:
+			value = float64(int16(binary.BigEndian.Uint16(data)))
+		case 4:
+			value = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+		case 8:
+			value = math.Float64frombits(binary.BigEndian.Uint64(data))
+		default:
+			return fmt.Errorf("dnp3: analog output expects 2/4/8 bytes, got %d", len(data))
+		}
+		v, ok := toInt(params["variation"])
+		if !ok {
+			v = 1
+		}
+		obj, err := appendAnalogOutput(nil, uint8(v), value)
+		if err != nil {
+			return err
+		}
+		return a.operate(fn, sbo, prefixHeader(nil, GroupAnalogOutput, uint8(v), uint32(idx)), obj)
+	default:
+		return fmt.Errorf("dnp3: group %d is not an output group", group)
+	}
+}
+
+func crobFromParams(on bool, params map[string]interface{}) (CROB, error) {
+	c := CROB{Count: 1}
+	op, _ := params["op"].(string)
+	switch strings.ToLower(op) {
+	case "", "latch":
+		c.Code = OpLatchOff
+		if on {
+			c.Code = OpLatchOn
+		}
+	case "pulse":
+		c.Code, c.OnMs = OpPulseOff, 1000
+		if on {
+			c.Code = OpPulseOn
+		}
+	case "closetrip":
+		c.Code, c.OnMs = OpPulseOn|TCCTrip, 1000
+		if on {
+			c.Code = OpPulseOn | TCCClose
+		}
+	default:
+		return c, fmt.Errorf("dnp3: unsupported op %q", op)
+	}
+	if v, ok := toInt(params["onTimeMs"]); ok {
+		c.OnMs = uint32(v)
+	}
+	if v, ok := toInt(params["offTimeMs"]); ok {
+		c.OffMs = uint32(v)
+	}
+	if v, ok := toInt(params["count"]); ok {
+		c.Count = uint8(v)
+	}
+	return c, nil
+}
+
+func (a *DNP3Adapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("dnp3: WriteModbus not supported")
+}
+
+// OperateCROB 下发控制继电器输出块
+func (a *DNP3Adapter) OperateCROB(index uint32, c CROB, sbo bool) error {
+	return a.operate(FuncDirectOperate, sbo, prefixHeader(nil, GroupCROB, 1, index), c.appendTo(nil))
+}
+
+// OperateAnalog 下发模拟量输出（g41，variation 1~4）
+func (a *DNP3Adapter) OperateAnalog(index uint32, variation uint8, value float64, sbo bool) error {
+	obj, err := appendAnalogOutput(nil, variation, value)
+	if err != nil {
+		return err
+	}
+	return a.operate(FuncDirectOperate, sbo, prefixHeader(nil, GroupAnalogOutput, variation, index), obj)
+}
+
+// operate 直接执行，或选择成功后以相同对象执行；检查响应中回显对象的状态码
+func (a *DNP3Adapter) operate(fn byte, sbo bool, header, obj []byte) error {
+	if err := a.Connect(); err != nil {
+		return err
+	}
+	req := append(header, obj...)
+	steps := []byte{fn}
+	if sbo {
+		steps = []byte{FuncSelect, FuncOperate}
+	}
+	for _, step := range steps {
+		objs, err := a.request(step, req)
+		if err != nil {
+			return err
+		}
+		if step == FuncDirectOperateNR {
+			return nil
+		}
+		echoed := false
+		for _, o := range objs {
+			if o.Group == GroupCROB || o.Group == GroupAnalogOutput {
+				echoed = true
+				if o.Status != 0 {
+					return CommandStatus(o.Status)
+				}
+			}
+		}
+		if !echoed {
+			return errors.New("dnp3: command response without echoed object")
+		}
+	}
+	return nil
+}
+
+// request 发送请求并等待完整响应（多分片时收齐FIN），返回全部对象
+func (a *DNP3Adapter) request(fn byte, objects []byte) ([]Object, error) {
+	a.reqMu.Lock()
+	defer a.reqMu.Unlock()
+	a.mu.Lock()
+	s := a.sess
+	seq := a.appSeq
+	a.appSeq = (a.appSeq + 1) & 0x0F
+	a.mu.Unlock()
+	if s == nil || !s.alive() {
+		return nil, errSessionClosed
+	}
+	for len(s.resp) > 0 {
+		<-s.resp // 丢弃超时请求的迟到响应
+	}
+	if err := a.sendAPDU(s, append([]byte{appFIR | appFIN | seq, fn}, objects...)); err != nil {
+		return nil, err
+	}
+	if fn == FuncDirectOperateNR {
+		return nil, nil
+	}
+
+	var objs []Object
+	first := true
+	timer := time.NewTimer(a.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case f := <-s.resp:
+			if first && (f.control&appFIR == 0 || f.seq() != seq) {
+				continue
+			}
+			if !first && f.seq() != seq {
+				continue
+			}
+			first = false
+			seq = (seq + 1) & 0x0F
+			if f.iin&iinRequestErrors != 0 {
+				return nil, fmt.Errorf("dnp3: function 0x%02X rejected, IIN %s", fn, f.iin&iinRequestErrors)
+			}
+			objs = append(objs, f.parsed...)
+			if f.parseErr != nil {
+				return objs, f.parseErr
+			}
+			if f.control&appFIN != 0 {
+				return objs, nil
+			}
+			timer.Reset(a.timeout)
+		case <-s.done:
+			return nil, errSessionClosed
+		case <-timer.C:
+			return nil, fmt.Errorf("dnp3: response timeout for function 0x%02X", fn)
+		}
+	}
+}
+
+func (a *DNP3Adapter) sendAPDU(s *session, apdu []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	for _, seg := range segment(apdu, &s.transSeq) {
+		f := linkFrame{ctrl: linkDir | linkPrm | linkUnconfirmedUserData, dst: a.remote, src: a.local, data: seg}
+		if _, err := s.rwc.Write(f.encode()); err != nil {
+			s.close()
+			return fmt.Errorf("dnp3: write: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *DNP3Adapter) sendLink(s *session, ctrl byte, data []byte) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	f := linkFrame{ctrl: ctrl, dst: a.remote, src: a.local, data: data}
+	if _, err := s.rwc.Write(f.encode()); err != nil {
+		s.close()
+	}
+}
+
+// recvLoop 链路接收：应答子站链路服务、传输层重组、应用层分发
+func (a *DNP3Adapter) recvLoop(s *session) {
+	defer s.close()
+	r := bufio.NewReader(s.rwc)
+	var re reassembler
+	for {
+		f, err := readLinkFrame(r)
+		if err != nil {
+			if errors.Is(err, errCRC) || errors.Is(err, serial.ErrTimeout) {
+				continue
+			}
+			if s.alive() {
+				log.Printf("[DNP3] %s 接收中断: %v", a.addr, err)
+			}
+			return
+		}
+		if f.src != a.remote || (f.dst != a.local && f.dst < 0xFFFD) {
+			continue
+		}
+		s.lastRecv.Store(time.Now().UnixNano())
+		if f.ctrl&linkPrm == 0 {
+			continue // 从动站应答（链路状态等）
+		}
+		switch f.function() {
+		case linkRequestLinkStatus:
+			a.sendLink(s, linkDir|linkStatus, nil)
+		case linkResetLinkStates, linkTestLinkStates:
+			a.sendLink(s, linkDir|linkAck, nil)
+		case linkConfirmedUserData:
+			a.sendLink(s, linkDir|linkAck, nil)
+			fallthrough
+		case linkUnconfirmedUserData:
+			if apdu := re.push(f.data); apdu != nil {
+				a.handleAPDU(s, apdu)
+			}
+		default:
+			a.sendLink(s, linkDir|linkNotSupported, nil)
+		}
+	}
+}
+
+// handleAPDU 应用层报文：确认、更新缓存与推送、处理IIN，响应交给等待中的请求
+func (a *DNP3Adapter) handleAPDU(s *session, apdu []byte) {
+	f, err := parseFragment(apdu)
+	if err != nil {
+		log.Printf("[DNP3] %s %v", a.addr, err)
+		return
+	}
+	uns := f.function == FuncUnsolicitedResponse
+	if !uns && f.function != FuncResponse {
+		return
+	}
+	if f.control&appCON != 0 {
+		ctl := appFIR | appFIN | f.seq()
+		if uns {
+			ctl |= appUNS
+		}
+		a.sendAPDU(s, []byte{ctl, FuncConfirm})
+	}
+	if uns {
+		// 确认丢失时子站以相同序号重发，不重复处理
+		a.mu.Lock()
+		dup := bytes.Equal(a.lastUns, apdu)
+		a.lastUns = apdu
+		a.mu.Unlock()
+		if dup {
+			return
+		}
+	}
+	f.parsed, f.parseErr = parseObjects(f.objects)
+	if f.parseErr != nil {
+		log.Printf("[DNP3] %s %v", a.addr, f.parseErr)
+	}
+	a.publish(f.parsed, uns)
+	a.handleIIN(f.iin)
+	if !uns {
+		select {
+		case s.resp <- f:
+		default:
+		}
+	}
+}
+
+func (a *DNP3Adapter) publish(objs []Object, uns bool) {
+	now := time.Now()
+	events := make([]Event, 0, len(objs))
+	for _, o := range objs {
+		if !measurement(staticGroup(o.Group)) {
+			continue
+		}
+		if o.Time.IsZero() {
+			o.Time = now
+		}
+		events = append(events, Event{Object: o, Unsolicited: uns})
+	}
+	if len(events) == 0 {
+		return
+	}
+	a.mu.Lock()
+	for _, ev := range events {
+		a.cache[pointKey{staticGroup(ev.Group), ev.Index}] = ev
+	}
+	handlers := a.handlers
+	a.mu.Unlock()
+	for _, h := range handlers {
+		for _, ev := range events {
+			h(ev)
+		}
+	}
+	for _, ev := range events {
+		a.subs.Publish(pointKey{staticGroup(ev.Group), ev.Index},
+			protocol.PointUpdate{Bytes: valueBytes(ev.Object), Timestamp: ev.Time, Err: qualityErr(ev.Object)})
+	}
+}
+
+// handleIIN 子站请求对时或刚重启时在后台处理，不阻塞接收协程
+func (a *DNP3Adapter) handleIIN(iin IIN) {
+	if iin&IINNeedTime != 0 && a.timeSync != "none" && a.syncing.CompareAndSwap(false, true) {
+		go func() {
+			defer a.syncing.Store(false)
+			if err := a.syncTime(); err != nil {
+				log.Printf("[DNP3] %s 对时失败: %v", a.addr, err)
+			}
+		}()
+	}
+	if iin&IINDeviceRestart != 0 && a.clearing.CompareAndSwap(false, true) {
+		go func() {
+			defer a.clearing.Store(false)
+			if err := a.clearRestart(); err != nil {
+				log.Printf("[DNP3] %s 清除重启标志失败: %v", a.addr, err)
+			}
+		}()
+	}
+}
+
+// valueBytes 转换为点位原始字节（大端）
+func valueBytes(o Object) []byte {
+	switch staticGroup(o.Group) {
+	case GroupBinaryInput, GroupBinaryOutput, GroupDoubleBitInput:
+		return []byte{byte(o.Value)}
+	case GroupCounter, GroupFrozenCounter:
+		return binary.BigEndian.AppendUint32(nil, uint32(o.Value))
+	default:
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(o.Value)))
+	}
+}
+
+// toInt 兼容 int/float64/字符串配置
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
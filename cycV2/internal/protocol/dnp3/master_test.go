@@ -0,0 +1,313 @@
+package dnp3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+func newTestMaster(t *testing.T, o *outstation, extra map[string]interface{}) *DNP3Adapter {
+	m, err := NewDNP3Master(o.cfg(extra))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Disconnect() })
+	return m
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLinkAndObjects(t *testing.T) {
+	// 复位链路帧示例 05 64 05 C0 01 00 00 04 E9 21
+	f := linkFrame{ctrl: linkDir | linkPrm | linkResetLinkStates, dst: 1, src: 1024}
+	if got := f.encode(); !bytes.Equal(got, []byte{0x05, 0x64, 0x05, 0xC0, 0x01, 0x00, 0x00, 0x04, 0xE9, 0x21}) {
+		t.Fatalf("reset link frame % X", got)
+	}
+
+	apdu := make([]byte, 600)
+	for i := range apdu {
+		apdu[i] = byte(i)
+	}
+	var seq uint8 = 62
+	var stream []byte
+	segs := segment(apdu, &seq)
+	for _, s := range segs {
+		stream = append(stream, linkFrame{ctrl: linkPrm | linkUnconfirmedUserData, dst: 1, src: 10, data: s}.encode()...)
+	}
+	stream = append([]byte{0x00, 0x05}, stream...) // 起始前杂字节
+	r := bufio.NewReader(bytes.NewReader(stream))
+	var re reassembler
+	var got []byte
+	for range segs {
+		lf, err := readLinkFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = re.push(lf.data)
+	}
+	if len(segs) != 3 || !bytes.Equal(got, apdu) {
+		t.Fatalf("reassembled %d segments, %d bytes", len(segs), len(got))
+	}
+	bad := linkFrame{ctrl: 0x44, dst: 1, src: 10, data: []byte{1, 2, 3}}.encode()
+	bad[11]++
+	if _, err := readLinkFrame(bufio.NewReader(bytes.NewReader(bad))); !errors.Is(err, errCRC) {
+		t.Fatalf("crc error expected, got %v", err)
+	}
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 678e6, time.UTC)
+	var objs []byte
+	objs = append(rangeHeader(objs, 1, 1, 3, 12), 0x05, 0x02) // 序号3、5、12为1
+	objs = appendTime48(append(objs, groupCTO, 1, 0x07, 1), at)
+	objs = append(objs, 2, 3, 0x17, 1, 7, FlagOnline|FlagState, 0x10, 0x00)
+	objs = append(objs, 32, 7, 0x28, 1, 0, 0x2C, 0x01, FlagOnline)
+	objs = appendTime48(binary.LittleEndian.AppendUint32(objs, math.Float32bits(-1.25)), at)
+	objs = binary.LittleEndian.AppendUint16(append(objs, 30, 2, 0x01, 0x00, 0x01, 0x00, 0x01, FlagOnline|FlagOverRange), 0xFFFF)
+	parsed, err := parseObjects(objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 13 {
+		t.Fatalf("parsed %d objects: %+v", len(parsed), parsed)
+	}
+	for i, o := range parsed[:10] {
+		if want := float64(map[int]int{0: 1, 2: 1, 9: 1}[i]); o.Index != uint32(3+i) || o.Value != want {
+			t.Fatalf("packed %d: %+v", i, o)
+		}
+	}
+	if o := parsed[10]; o.Group != 2 || o.Index != 7 || o.Value != 1 || !o.Time.Equal(at.Add(16*time.Millisecond)) {
+		t.Fatalf("g2v3 %+v", o)
+	}
+	if o := parsed[11]; o.Index != 300 || o.Value != -1.25 || !o.Time.Equal(at) {
+		t.Fatalf("g32v7 %+v", o)
+	}
+	if o := parsed[12]; o.Index != 256 || o.Value != -1 || o.Flags&FlagOverRange == 0 {
+		t.Fatalf("g30v2 %+v", o)
+	}
+	if _, err := parseObjects([]byte{110, 1, 0x00, 0, 0, 1}); err == nil {
+		t.Fatal("unsupported object should fail")
+	}
+}
+
+func TestStartupAndRead(t *testing.T) {
+	o := newOutstation(t)
+	m := newTestMaster(t, o, nil)
+	if err := m.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	funcs, _, _, _, unsOn := o.snapshot()
+	if len(funcs) < 3 || funcs[0] != FuncDisableUnsolicited || funcs[1] != FuncRead || !unsOn {
+		t.Fatalf("startup sequence % X, unsolicited %v", funcs, unsOn)
+	}
+	eventually(t, "multi-fragment confirms", func() bool {
+		_, confirms, _, _, _ := o.snapshot()
+		return len(confirms) == 2 && confirms[0]&appUNS == 0
+	})
+
+	// 重启与对时标志在后台处理
+	eventually(t, "restart cleared and time synced", func() bool {
+		_, _, iin, ts, _ := o.snapshot()
+		return iin&(IINDeviceRestart|IINNeedTime) == 0 && time.Since(ts) < time.Minute
+	})
+
+	read := func(params map[string]interface{}) []byte {
+		t.Helper()
+		b, err := m.Read(params)
+		if err != nil {
+			t.Fatalf("%v: %v", params, err)
+		}
+		return b
+	}
+	if b := read(map[string]interface{}{"group": 1, "index": 1}); !bytes.Equal(b, []byte{1}) {
+		t.Fatalf("binary 1 % X", b)
+	}
+	if b := read(map[string]interface{}{"group": 30, "index": "59"}); math.Float32frombits(binary.BigEndian.Uint32(b)) != 59.5 {
+		t.Fatalf("analog 59 % X", b)
+	}
+	if b := read(map[string]interface{}{"group": 20, "index": 0}); binary.BigEndian.Uint32(b) != 123456 {
+		t.Fatalf("counter % X", b)
+	}
+	// 不在 Class 0 中的点位按序号直接读取
+	if b := read(map[string]interface{}{"group": 32, "index": 100, "variation": 1}); math.Float32frombits(binary.BigEndian.Uint32(b)) != -42 {
+		t.Fatalf("analog 100 % X", b)
+	}
+	if _, err := m.Read(map[string]interface{}{"group": 1, "index": 2}); err == nil {
+		t.Fatal("offline binary should fail")
+	}
+	if _, err := m.Read(map[string]interface{}{"group": 20, "index": 9}); err == nil {
+		t.Fatal("unknown object should fail")
+	}
+	if _, err := m.Read(map[string]interface{}{"group": 12, "index": 0}); err == nil {
+		t.Fatal("output group is not readable")
+	}
+
+	// 断线后下次读取重新建链
+	o.mu.Lock()
+	o.conn.Close()
+	o.mu.Unlock()
+	eventually(t, "disconnect detected", func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return !m.sess.alive()
+	})
+	read(map[string]interface{}{"group": 1, "index": 1})
+}
+
+func TestUnsolicitedAndEvents(t *testing.T) {
+	o := newOutstation(t)
+	m := newTestMaster(t, o, map[string]interface{}{"timeSync": "none"})
+	var mu sync.Mutex
+	var updates []protocol.PointUpdate
+	var events []Event
+	cancel, err := m.Subscribe(map[string]interface{}{"group": 30, "index": 5}, func(u protocol.PointUpdate) {
+		mu.Lock()
+		updates = append(updates, u)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.OnEvent(func(ev Event) {
+		if ev.Unsolicited || ev.Group == 2 {
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
+		}
+	})
+	if err := m.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	count := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(updates), len(events)
+	}
+	if n, _ := count(); n != 1 {
+		t.Fatalf("integrity poll updates %d", n)
+	}
+
+	at := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	objs := appendTime48(binary.LittleEndian.AppendUint32(append(prefixHeader(nil, 32, 3, 5), FlagOnline), 0xFFFFFFF9), at)
+	o.unsolicited(objs)
+	o.unsolicited(objs) // 确认丢失后的重发
+	eventually(t, "unsolicited confirmed", func() bool {
+		_, confirms, _, _, _ := o.snapshot()
+		return len(confirms) >= 4 && confirms[len(confirms)-1]&appUNS != 0
+	})
+	eventually(t, "unsolicited update", func() bool { n, _ := count(); return n == 2 })
+	mu.Lock()
+	u := updates[1]
+	mu.Unlock()
+	if u.Err != nil || math.Float32frombits(binary.BigEndian.Uint32(u.Bytes)) != -7 || !u.Timestamp.Equal(at) {
+		t.Fatalf("unsolicited update %+v", u)
+	}
+
+	// 事件轮询：带时标的开关量变位
+	o.mu.Lock()
+	o.events = appendTime48(append(prefixHeader(nil, 2, 2, 0), FlagOnline|FlagState), at)
+	o.mu.Unlock()
+	if err := m.PollClasses(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, n := count(); n != 2 {
+		t.Fatalf("events %d, want unsolicited + class 1", n)
+	}
+	if b, err := m.Read(map[string]interface{}{"group": 1, "index": 0}); err != nil || b[0] != 1 {
+		t.Fatalf("binary after event % X %v", b, err)
+	}
+	cancel()
+	o.unsolicited(append(rangeHeader(nil, 30, 5, 5, 5), FlagCommLost, 0, 0, 0, 0))
+	eventually(t, "cache updated", func() bool {
+		_, err := m.Read(map[string]interface{}{"group": 30, "index": 5})
+		return err != nil
+	})
+	if n, _ := count(); n != 2 {
+		t.Fatal("update after cancel")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	o := newOutstation(t)
+	m := newTestMaster(t, o, map[string]interface{}{"unsolicited": false, "timeSync": "nonlan"})
+	if err := m.Write("3", []byte{1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write("", []byte{0}, map[string]interface{}{"index": 4, "op": "pulse", "sbo": true, "onTimeMs": 500}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write("7", []byte{1}, map[string]interface{}{"op": "closeTrip", "noAck": true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write("2", binary.BigEndian.AppendUint32(nil, math.Float32bits(12.5)), map[string]interface{}{"group": 41, "variation": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write("300", []byte{0xFF, 0x38}, map[string]interface{}{"group": 40}); err != nil {
+		t.Fatal(err)
+	}
+	var status CommandStatus
+	if err := m.Write("9", []byte{1}, nil); !errors.As(err, &status) || status != 4 {
+		t.Fatalf("unsupported index: %v", err)
+	}
+	if err := m.SyncTime(); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "direct operate without ack", func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return len(o.operated) == 5
+	})
+	o.mu.Lock()
+	ops := o.operated
+	o.mu.Unlock()
+	want := [][]byte{
+		append([]byte{12, 1, 0x17, 1, 3}, CROB{Code: OpLatchOn, Count: 1}.appendTo(nil)...),
+		append([]byte{12, 1, 0x17, 1, 4}, CROB{Code: OpPulseOff, Count: 1, OnMs: 500}.appendTo(nil)...),
+		append([]byte{12, 1, 0x17, 1, 7}, CROB{Code: OpPulseOn | TCCClose, Count: 1, OnMs: 1000}.appendTo(nil)...),
+		binary.LittleEndian.AppendUint32([]byte{41, 3, 0x17, 1, 2}, math.Float32bits(12.5)),
+		binary.LittleEndian.AppendUint32([]byte{41, 1, 0x28, 1, 0, 0x2C, 0x01}, 0xFFFFFF38),
+	}
+	for i := range want {
+		if i >= 3 {
+			want[i] = append(want[i], 0)
+		}
+		if !bytes.Equal(ops[i], want[i]) {
+			t.Fatalf("command %d: % X, want % X", i, ops[i], want[i])
+		}
+	}
+	funcs, _, _, ts, _ := o.snapshot()
+	if !bytes.Contains(funcs, []byte{FuncSelect, FuncOperate}) || !bytes.Contains(funcs, []byte{FuncDelayMeasure, FuncWrite}) {
+		t.Fatalf("functions % X", funcs)
+	}
+	if d := time.Since(ts); d < 0 || d > time.Minute {
+		t.Fatalf("time set %v", ts)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	a, err := protocol.GetAdapter("dnp3", map[string]interface{}{"address": "127.0.0.1", "remoteAddr": 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.(protocol.Subscriber); !ok || a.(*DNP3Adapter).addr != "127.0.0.1:20000" || a.(*DNP3Adapter).remote != 1024 {
+		t.Fatalf("adapter %+v", a)
+	}
+	if _, err := protocol.GetAdapter("dnp3", map[string]interface{}{"address": "x", "timeSync": "gps"}); err == nil {
+		t.Fatal("invalid timeSync should fail")
+	}
+}
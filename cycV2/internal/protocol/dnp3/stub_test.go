@@ -0,0 +1,275 @@
+package dnp3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	stubAddr   = 10
+	masterAddr = 1
+)
+
+// outstation 测试用子站：静态数据、Class 1 事件、非请求上送、对时与控制命令
+type outstation struct {
+	t  *testing.T
+	ln net.Listener
+
+	mu        sync.Mutex
+	conn      net.Conn
+	transSeq  uint8
+	unsSeq    uint8
+	iin       IIN
+	binary    map[uint32]uint8 // 序号 → 品质（含状态位）
+	analog    map[uint32]float64
+	hidden    map[uint32]float64 // 不属于 Class 0，只能按序号读取
+	counter   map[uint32]uint32
+	events    []byte // 待读取的 Class 1 事件对象
+	unsOn     bool
+	funcs     []byte
+	confirms  []byte // 收到的确认报文控制字
+	timeSet   time.Time
+	selected  []byte
+	operated  [][]byte
+	linkStats int
+}
+
+func newOutstation(t *testing.T) *outstation {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &outstation{
+		t: t, ln: ln,
+		iin:     IINDeviceRestart | IINNeedTime,
+		binary:  map[uint32]uint8{0: FlagOnline, 1: FlagOnline | FlagState, 2: 0},
+		analog:  make(map[uint32]float64),
+		hidden:  map[uint32]float64{100: -42},
+		counter: map[uint32]uint32{0: 123456},
+	}
+	for i := uint32(0); i < 60; i++ {
+		o.analog[i] = float64(i) + 0.5
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			o.mu.Lock()
+			if o.conn != nil {
+				o.conn.Close()
+			}
+			o.conn = conn
+			o.mu.Unlock()
+			go o.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		o.mu.Lock()
+		if o.conn != nil {
+			o.conn.Close()
+		}
+		o.mu.Unlock()
+	})
+	return o
+}
+
+func (o *outstation) cfg(extra map[string]interface{}) map[string]interface{} {
+	cfg := map[string]interface{}{"address": o.ln.Addr().String(), "timeoutMs": 1000}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	return cfg
+}
+
+func (o *outstation) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var re reassembler
+	for {
+		f, err := readLinkFrame(r)
+		if err != nil {
+			return
+		}
+		if f.dst != stubAddr || f.src != masterAddr || f.ctrl&linkDir == 0 {
+			o.t.Errorf("unexpected link header %+v", f)
+			continue
+		}
+		if f.ctrl&linkPrm == 0 {
+			if f.function() == linkStatus {
+				o.mu.Lock()
+				o.linkStats++
+				o.mu.Unlock()
+			}
+			continue
+		}
+		if f.function() == linkRequestLinkStatus {
+			o.mu.Lock()
+			o.writeLink(linkStatus, nil)
+			o.mu.Unlock()
+			continue
+		}
+		if apdu := re.push(f.data); apdu != nil {
+			o.handle(apdu)
+		}
+	}
+}
+
+// writeLink 调用方持有 mu
+func (o *outstation) writeLink(ctrl byte, data []byte) {
+	if o.conn != nil {
+		o.conn.Write(linkFrame{ctrl: ctrl, dst: masterAddr, src: stubAddr, data: data}.encode())
+	}
+}
+
+// send 调用方持有 mu
+func (o *outstation) send(control, fn byte, objs []byte) {
+	apdu := append([]byte{control, fn, byte(o.iin), byte(o.iin >> 8)}, objs...)
+	for _, seg := range segment(apdu, &o.transSeq) {
+		o.writeLink(linkPrm|linkUnconfirmedUserData, seg)
+	}
+}
+
+func (o *outstation) handle(apdu []byte) {
+	f, err := parseFragment(apdu)
+	if err != nil {
+		o.t.Error(err)
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if f.function == FuncConfirm {
+		o.confirms = append(o.confirms, f.control)
+		return
+	}
+	o.funcs = append(o.funcs, f.function)
+	seq := f.seq()
+	reply := func(objs []byte) { o.send(appFIR|appFIN|seq, FuncResponse, objs) }
+	switch f.function {
+	case FuncRead:
+		o.read(seq, f.objects)
+	case FuncWrite:
+		objs, err := parseObjects(f.objects)
+		if err != nil {
+			o.t.Error(err)
+		}
+		for _, obj := range objs {
+			switch {
+			case obj.Group == groupTime:
+				o.timeSet = obj.Time
+				o.iin &^= IINNeedTime
+			case obj.Group == groupIIN && obj.Index == 7 && obj.Value == 0:
+				o.iin &^= IINDeviceRestart
+			}
+		}
+		reply(nil)
+	case FuncRecordCurrentTime:
+		reply(nil)
+	case FuncDelayMeasure:
+		reply([]byte{groupDelay, 2, 0x07, 1, 5, 0})
+	case FuncEnableUnsolicited, FuncDisableUnsolicited:
+		o.unsOn = f.function == FuncEnableUnsolicited
+		reply(nil)
+	case FuncSelect, FuncOperate, FuncDirectOperate:
+		reply(o.control(f.function, f.objects))
+	case FuncDirectOperateNR:
+		o.control(f.function, f.objects)
+	default:
+		o.iin |= IINNoFuncCodeSupport
+		reply(nil)
+		o.iin &^= IINNoFuncCodeSupport
+	}
+}
+
+// read 类数据读取与按序号读取；Class 0 分两个应用分片应答，第二片超过一个传输段
+func (o *outstation) read(seq uint8, req []byte) {
+	var events, static, analog []byte
+	for len(req) >= 3 {
+		g, v, q := req[0], req[1], req[2]
+		req = req[3:]
+		if q == 0x06 && g == groupClass {
+			switch v {
+			case 1:
+				static = rangeHeader(static, 1, 2, 0, 2)
+				for i := uint32(0); i < 3; i++ {
+					static = append(static, o.binary[i])
+				}
+				static = rangeHeader(static, 20, 1, 0, 0)
+				static = binary.LittleEndian.AppendUint32(append(static, FlagOnline), o.counter[0])
+				analog = rangeHeader(analog, 30, 5, 0, uint32(len(o.analog)-1))
+				for i := uint32(0); i < uint32(len(o.analog)); i++ {
+					analog = binary.LittleEndian.AppendUint32(append(analog, FlagOnline), math.Float32bits(float32(o.analog[i])))
+				}
+			case 2:
+				events, o.events = append(events, o.events...), nil
+			}
+			continue
+		}
+		if q != 0x00 || len(req) < 2 {
+			o.t.Errorf("unexpected read header g%dv%d q%02X", g, v, q)
+			return
+		}
+		start, stop := uint32(req[0]), uint32(req[1])
+		req = req[2:]
+		if g != GroupAnalogInput {
+			o.iin |= IINObjectUnknown
+			o.send(appFIR|appFIN|seq, FuncResponse, nil)
+			o.iin &^= IINObjectUnknown
+			return
+		}
+		static = rangeHeader(static, 30, 1, start, stop)
+		for i := start; i <= stop; i++ {
+			static = binary.LittleEndian.AppendUint32(append(static, FlagOnline), uint32(int32(o.hidden[i])))
+		}
+	}
+	if analog == nil {
+		o.send(appFIR|appFIN|seq, FuncResponse, append(events, static...))
+		return
+	}
+	o.send(appFIR|appCON|seq, FuncResponse, append(events, static...))
+	o.send(appFIN|appCON|(seq+1)&0x0F, FuncResponse, analog)
+}
+
+// control 选择/执行：序号9不支持；执行须与选择的对象一致
+func (o *outstation) control(fn byte, req []byte) []byte {
+	resp := append([]byte(nil), req...)
+	status := byte(0)
+	if req[4] == 9 {
+		status = 4
+	}
+	switch fn {
+	case FuncSelect:
+		o.selected = append([]byte(nil), req...)
+	case FuncOperate:
+		if !bytes.Equal(o.selected, req) {
+			status = 2
+		}
+		o.selected = nil
+	}
+	if status == 0 && fn != FuncSelect {
+		o.operated = append(o.operated, append([]byte(nil), req...))
+	}
+	resp[len(resp)-1] = status
+	return resp
+}
+
+// unsolicited 非请求上送，要求确认
+func (o *outstation) unsolicited(objs []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.send(appFIR|appFIN|appCON|appUNS|o.unsSeq, FuncUnsolicitedResponse, objs)
+}
+
+func (o *outstation) snapshot() (funcs, confirms []byte, iin IIN, timeSet time.Time, unsOn bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]byte(nil), o.funcs...), append([]byte(nil), o.confirms...), o.iin, o.timeSet, o.unsOn
+}
@@ -0,0 +1,124 @@
+package framed
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// checksumAlgo 校验算法：计算函数与结果字节数
+type checksumAlgo struct {
+	size   int
+	little bool // 默认字节序（Modbus CRC 低字节在前）
+	sum    func([]byte) uint32
+}
+
+var checksumAlgos = map[string]checksumAlgo{
+	"sum8":        {size: 1, sum: func(b []byte) uint32 { return sum(b) & 0xFF }},
+	"sum16":       {size: 2, sum: func(b []byte) uint32 { return sum(b) & 0xFFFF }},
+	"lrc8":        {size: 1, sum: func(b []byte) uint32 { return -sum(b) & 0xFF }},
+	"xor8":        {size: 1, sum: xor8},
+	"crc8":        {size: 1, sum: crc8},
+	"crc16modbus": {size: 2, little: true, sum: func(b []byte) uint32 { return uint32(crc16Reflected(b, 0xFFFF, 0xA001)) }},
+	"crc16ccitt":  {size: 2, sum: func(b []byte) uint32 { return uint32(crc16(b, 0xFFFF, 0x1021)) }},
+	"crc16xmodem": {size: 2, sum: func(b []byte) uint32 { return uint32(crc16(b, 0, 0x1021)) }},
+	"crc32":       {size: 4, sum: crc32.ChecksumIEEE},
+}
+
+func lookupChecksum(name string) (checksumAlgo, error) {
+	name = strings.ToLower(strings.ReplaceAll(name, "-", ""))
+	if name == "crc16" {
+		name = "crc16modbus"
+	}
+	a, ok := checksumAlgos[name]
+	if !ok {
+		return a, fmt.Errorf("framed: unknown checksum %q", name)
+	}
+	return a, nil
+}
+
+func sum(b []byte) uint32 {
+	var s uint32
+	for _, c := range b {
+		s += uint32(c)
+	}
+	return s
+}
+
+func xor8(b []byte) uint32 {
+	var x byte
+	for _, c := range b {
+		x ^= c
+	}
+	return uint32(x)
+}
+
+// crc8 多项式0x07，初值0
+func crc8(b []byte) uint32 {
+	var crc byte
+	for _, c := range b {
+		crc ^= c
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return uint32(crc)
+}
+
+func crc16(b []byte, init, poly uint16) uint16 {
+	crc := init
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func crc16Reflected(b []byte, init, poly uint16) uint16 {
+	crc := init
+	for _, c := range b {
+		crc ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ poly
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// putUint 按字节数与字节序写整数
+func putUint(b []byte, v uint32, little bool) {
+	n := len(b)
+	for i := 0; i < n; i++ {
+		shift := 8 * uint(n-1-i)
+		if little {
+			shift = 8 * uint(i)
+		}
+		b[i] = byte(v >> shift)
+	}
+}
+
+func getUint(b []byte, little bool) uint32 {
+	var v uint32
+	for i := range b {
+		if little {
+			v |= uint32(b[i]) << (8 * uint(i))
+		} else {
+			v = v<<8 | uint32(b[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,446 @@
+package framed
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+	"github.com/grid-x/serial"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("framed", NewFramedAdapter)
+}
+
+// port 一个物理通道（RS-485 总线或串口服务器连接），同一通道上的设备共用，事务互斥
+type port struct {
+	key  string
+	open func() (io.ReadWriteCloser, error)
+
+	mu   sync.Mutex
+	rwc  io.ReadWriteCloser
+	r    *bufio.Reader
+	refs int
+}
+
+var ports = struct {
+	sync.Mutex
+	m map[string]*port
+}{m: make(map[string]*port)}
+
+func acquirePort(key string, open func() (io.ReadWriteCloser, error)) *port {
+	ports.Lock()
+	defer ports.Unlock()
+	p, ok := ports.m[key]
+	if !ok {
+		p = &port{key: key, open: open}
+		ports.m[key] = p
+	}
+	p.refs++
+	return p
+}
+
+func (p *port) release() {
+	ports.Lock()
+	p.refs--
+	last := p.refs == 0
+	if last {
+		delete(ports.m, p.key)
+	}
+	ports.Unlock()
+	if last {
+		p.mu.Lock()
+		p.closeLocked()
+		p.mu.Unlock()
+	}
+}
+
+func (p *port) closeLocked() {
+	if p.rwc != nil {
+		p.rwc.Close()
+		p.rwc, p.r = nil, nil
+	}
+}
+
+// transact 发送请求并按帧格式收取 accept 接受的应答；accept 为 nil 时不等待应答。
+// 回显、其他设备的应答等不被接受的帧跳过
+func (p *port) transact(req []byte, timeout time.Duration, g *Grammar, accept func([]byte) bool) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rwc == nil {
+		rwc, err := p.open()
+		if err != nil {
+			return nil, err
+		}
+		p.rwc, p.r = rwc, bufio.NewReader(rwc)
+	}
+	// 丢弃上次事务残留
+	p.r.Discard(p.r.Buffered())
+	if conn, ok := p.rwc.(net.Conn); ok {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if _, err := p.rwc.Write(req); err != nil {
+		p.closeLocked()
+		return nil, err
+	}
+	if accept == nil {
+		return nil, nil
+	}
+	for {
+		f, err := g.read(p.r)
+		if err != nil {
+			var ne net.Error
+			if !errors.Is(err, errChecksum) && !errors.Is(err, errFrame) && !errors.Is(err, serial.ErrTimeout) && !(errors.As(err, &ne) && ne.Timeout()) {
+				// 连接异常，下次事务重新打开
+				p.closeLocked()
+			}
+			return nil, err
+		}
+		if accept(f) {
+			return f, nil
+		}
+	}
+}
+
+// matcher 应答匹配：offset 处应为模板渲染出的字节（可引用参数，如设备地址）
+type matcher struct {
+	offset int
+	tmpl   *Template
+}
+
+// request 命名请求
+type request struct {
+	name    string
+	tmpl    *Template
+	match   []matcher
+	noReply bool
+}
+
+// FramedAdapter 配置驱动的私有协议主站，实现 protocol.ProtocolAdapter 与 protocol.MultiReader。
+// 点位参数 request 指定请求，offset/length 指定应答帧中的字段（相对帧首，负数自帧尾倒数），
+// Read 返回字段原始字节，由解析流程按 dataType/byteOrder 解码；bit 指定时返回该位 0/1
+type FramedAdapter struct {
+	key      string
+	open     func() (io.ReadWriteCloser, error)
+	timeout  time.Duration
+	retries  int
+	reqFrame *Grammar
+	resFrame *Grammar
+	requests map[string]*request
+
+	mu   sync.Mutex
+	port *port
+}
+
+// NewFramedAdapter 工厂函数
+// cfg: mode("serial"|"tcp"，默认serial), address(串口设备或"ip:port"), baudRate(默认9600), dataBits(8), parity("N"), stopBits(1),
+//
+//	timeoutMs(默认1000), retries(默认1), frame(应答帧格式，见 ParseGrammar), requestFrame(请求帧格式，缺省同 frame),
+//	requests: {名称: 模板字符串 | {template, match: {偏移: 模板}, noReply}}
+func NewFramedAdapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewFramedClient(cfg)
+}
+
+// NewFramedClient 创建客户端，返回具体类型
+func NewFramedClient(cfg map[string]interface{}) (*FramedAdapter, error) {
+	addr, _ := cfg["address"].(string)
+	if addr == "" {
+		return nil, errors.New("framed: missing address")
+	}
+	a := &FramedAdapter{timeout: time.Second, retries: 1, requests: make(map[string]*request)}
+	if v, ok := toInt(cfg["timeoutMs"]); ok && v > 0 {
+		a.timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(cfg["retries"]); ok && v >= 0 {
+		a.retries = v
+	}
+
+	frame, ok := cfg["frame"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("framed: missing frame definition")
+	}
+	var err error
+	if a.resFrame, err = ParseGrammar(frame); err != nil {
+		return nil, err
+	}
+	if a.resFrame.LengthSize == 0 && a.resFrame.FrameLength <= 0 {
+		return nil, errors.New("framed: frame needs lengthSize or frameLength")
+	}
+	a.reqFrame = a.resFrame
+	if rf, ok := cfg["requestFrame"].(map[string]interface{}); ok {
+		if a.reqFrame, err = ParseGrammar(rf); err != nil {
+			return nil, fmt.Errorf("framed: requestFrame: %w", err)
+		}
+	}
+	reqs, _ := cfg["requests"].(map[string]interface{})
+	if len(reqs) == 0 {
+		return nil, errors.New("framed: no requests defined")
+	}
+	for name, raw := range reqs {
+		r, err := parseRequest(name, raw)
+		if err != nil {
+			return nil, err
+		}
+		a.requests[name] = r
+	}
+
+	mode, _ := cfg["mode"].(string)
+	switch mode {
+	case "tcp":
+		timeout := a.timeout
+		a.key = "tcp:" + addr
+		a.open = func() (io.ReadWriteCloser, error) { return net.DialTimeout("tcp", addr, timeout) }
+	case "", "serial", "rtu":
+		sc := &serial.Config{Address: addr, BaudRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, Timeout: a.timeout}
+		if v, ok := toInt(cfg["baudRate"]); ok && v > 0 {
+			sc.BaudRate = v
+		}
+		if v, ok := toInt(cfg["dataBits"]); ok && v > 0 {
+			sc.DataBits = v
+		}
+		if v, ok := cfg["parity"].(string); ok && v != "" {
+			sc.Parity = v
+		}
+		if v, ok := toInt(cfg["stopBits"]); ok && v > 0 {
+			sc.StopBits = v
+		}
+		a.key = "serial:" + addr
+		a.open = func() (io.ReadWriteCloser, error) { return serial.Open(sc) }
+	default:
+		return nil, fmt.Errorf("framed: unsupported mode %q", mode)
+	}
+	return a, nil
+}
+
+func parseRequest(name string, raw interface{}) (*request, error) {
+	r := &request{name: name}
+	src, _ := raw.(string)
+	var match map[string]interface{}
+	if m, ok := raw.(map[string]interface{}); ok {
+		src, _ = m["template"].(string)
+		match, _ = m["match"].(map[string]interface{})
+		r.noReply, _ = m["noReply"].(bool)
+	}
+	if src == "" {
+		return nil, fmt.Errorf("framed: request %q has no template", name)
+	}
+	var err error
+	if r.tmpl, err = ParseTemplate(src); err != nil {
+		return nil, fmt.Errorf("framed: request %q: %w", name, err)
+	}
+	for off, v := range match {
+		n, err := strconv.Atoi(strings.TrimSpace(off))
+		s, _ := v.(string)
+		if err != nil || s == "" {
+			return nil, fmt.Errorf("framed: request %q: invalid match %q: %v", name, off, v)
+		}
+		m := matcher{offset: n}
+		if m.tmpl, err = ParseTemplate(s); err != nil {
+			return nil, fmt.Errorf("framed: request %q match: %w", name, err)
+		}
+		r.match = append(r.match, m)
+	}
+	sort.Slice(r.match, func(i, j int) bool { return r.match[i].offset < r.match[j].offset })
+	return r, nil
+}
+
+// Connect 登记到物理通道，通道在首次事务时打开
+func (a *FramedAdapter) Connect() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.port == nil {
+		a.port = acquirePort(a.key, a.open)
+	}
+	return nil
+}
+
+// Disconnect 通道上最后一个设备断开时关闭通道
+func (a *FramedAdapter) Disconnect() error {
+	a.mu.Lock()
+	p := a.port
+	a.port = nil
+	a.mu.Unlock()
+	if p != nil {
+		p.release()
+	}
+	return nil
+}
+
+func (a *FramedAdapter) getPort() (*port, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.port, nil
+}
+
+func (a *FramedAdapter) lookup(name string) (*request, error) {
+	r, ok := a.requests[name]
+	if !ok {
+		return nil, fmt.Errorf("framed: unknown request %q", name)
+	}
+	return r, nil
+}
+
+// Exchange 按命名请求组帧发送，返回通过匹配的完整应答帧；value 为写入数据（{value}），noReply 请求返回 nil
+func (a *FramedAdapter) Exchange(name string, vars map[string]interface{}, value []byte) ([]byte, error) {
+	r, err := a.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.tmpl.render(a.reqFrame, vars, value)
+	if err != nil {
+		return nil, err
+	}
+	return a.exchange(r, req, vars)
+}
+
+func (a *FramedAdapter) exchange(r *request, req []byte, vars map[string]interface{}) ([]byte, error) {
+	p, err := a.getPort()
+	if err != nil {
+		return nil, err
+	}
+	var accept func([]byte) bool
+	if !r.noReply {
+		want := make([][]byte, len(r.match))
+		for i, m := range r.match {
+			if want[i], err = m.tmpl.render(nil, vars, nil); err != nil {
+				return nil, err
+			}
+		}
+		accept = func(f []byte) bool {
+			for i, m := range r.match {
+				off := at(m.offset, len(f))
+				if off < 0 || off+len(want[i]) > len(f) || !bytes.Equal(f[off:off+len(want[i])], want[i]) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	var lastErr error
+	for attempt := 0; attempt <= a.retries; attempt++ {
+		f, err := p.transact(req, a.timeout, a.resFrame, accept)
+		if err == nil {
+			return f, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("framed: request %s: %w", r.name, lastErr)
+}
+
+// field 按点位参数从应答帧中提取字段
+func field(frame []byte, params map[string]interface{}) ([]byte, error) {
+	off, _ := toInt(params["offset"])
+	n := 1
+	if v, ok := toInt(params["length"]); ok {
+		n = v
+	}
+	start := at(off, len(frame))
+	if n < 1 || start < 0 || start+n > len(frame) {
+		return nil, fmt.Errorf("framed: field %d+%d outside %d-byte frame", off, n, len(frame))
+	}
+	b := append([]byte(nil), frame[start:start+n]...)
+	if bit, ok := toInt(params["bit"]); ok {
+		if bit < 0 || bit >= 8*n {
+			return nil, fmt.Errorf("framed: bit %d outside %d-byte field", bit, n)
+		}
+		// 位号自字段最低有效字节的最低位起（大端：末字节）
+		return []byte{b[n-1-bit/8] >> (bit % 8) & 1}, nil
+	}
+	return b, nil
+}
+
+// Read 发送 params["request"] 并提取字段
+func (a *FramedAdapter) Read(params map[string]interface{}) ([]byte, error) {
+	name, _ := params["request"].(string)
+	f, err := a.Exchange(name, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	return field(f, params)
+}
+
+// ReadMulti 实现 protocol.MultiReader：组帧结果相同的点位只发送一次请求
+func (a *FramedAdapter) ReadMulti(params []map[string]interface{}) ([][]byte, []error) {
+	vals := make([][]byte, len(params))
+	errs := make([]error, len(params))
+	type result struct {
+		frame []byte
+		err   error
+	}
+	frames := make(map[string]result)
+	for i, p := range params {
+		name, _ := p["request"].(string)
+		r, err := a.lookup(name)
+		var req []byte
+		if err == nil {
+			req, err = r.tmpl.render(a.reqFrame, p, nil)
+		}
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		key := name + "\x00" + string(req)
+		res, ok := frames[key]
+		if !ok {
+			res.frame, res.err = a.exchange(r, req, p)
+			frames[key] = res
+		}
+		if res.err != nil {
+			errs[i] = res.err
+			continue
+		}
+		vals[i], errs[i] = field(res.frame, p)
+	}
+	return vals, errs
+}
+
+func (a *FramedAdapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("framed: BatchRead not supported")
+}
+
+// Write 发送 params["writeRequest"]（缺省以 address 为请求名），data 填入模板 {value}
+func (a *FramedAdapter) Write(address string, data []byte, params map[string]interface{}) error {
+	name, _ := params["writeRequest"].(string)
+	if name == "" {
+		name = address
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	_, err := a.Exchange(name, params, data)
+	return err
+}
+
+func (a *FramedAdapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("framed: WriteModbus not supported")
+}
+
+// toInt 兼容 int/float64/字符串配置
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
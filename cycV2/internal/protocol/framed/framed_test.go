@@ -0,0 +1,317 @@
+package framed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"cycV2/internal/protocol"
+)
+
+func TestChecksums(t *testing.T) {
+	in := []byte("123456789")
+	for name, want := range map[string]uint32{
+		"crc16modbus": 0x4B37, "crc16": 0x4B37, "CRC16-CCITT": 0x29B1, "crc16xmodem": 0x31C3,
+		"crc8": 0xF4, "crc32": 0xCBF43926, "sum8": 0xDD, "sum16": 0x01DD, "lrc8": 0x23, "xor8": 0x31,
+	} {
+		a, err := lookupChecksum(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.sum(in); got != want {
+			t.Errorf("%s = %#x, want %#x", name, got, want)
+		}
+	}
+	if _, err := lookupChecksum("md5"); err == nil {
+		t.Error("unknown checksum accepted")
+	}
+}
+
+// 测试协议：AA 55 LEN CMD ADDR 数据 CRC16(Modbus，低字节在前)，LEN 为 CMD 起到校验之前的字节数
+var bmsFrame = map[string]interface{}{
+	"header": "AA 55", "lengthOffset": 2, "lengthSize": 1, "lengthAdjust": 5,
+	"checksum": "crc16modbus", "checksumStart": 2,
+}
+
+func TestTemplateAndGrammar(t *testing.T) {
+	g, err := ParseGrammar(bmsFrame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := ParseTemplate("0xAA55 {len} 10 {addr} {reg:2le} {value:2} {cs}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := tmpl.Vars(); strings.Join(v, ",") != "addr,reg" {
+		t.Errorf("Vars = %v", v)
+	}
+	req, err := tmpl.render(g, map[string]interface{}{"addr": 3, "reg": "0x1234"}, []byte{0x01, 0xF4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0xAA, 0x55, 6, 0x10, 3, 0x34, 0x12, 0x01, 0xF4}
+	want = binary.LittleEndian.AppendUint16(want, crc16Reflected(want[2:], 0xFFFF, 0xA001))
+	if !bytes.Equal(req, want) {
+		t.Errorf("render = % X, want % X", req, want)
+	}
+
+	// 收帧：跳过噪声与残缺帧头，校验错误可识别
+	bad := append([]byte(nil), req...)
+	bad[len(bad)-1]++
+	r := bufio.NewReader(bytes.NewReader(append(append([]byte{0x00, 0xAA, 0x13, 0xAA}, bad...), req...)))
+	if _, err := g.read(r); err != errChecksum {
+		t.Errorf("corrupt frame: %v", err)
+	}
+	if f, err := g.read(r); err != nil || !bytes.Equal(f, req) {
+		t.Errorf("read = % X, %v", f, err)
+	}
+
+	// 定长帧、帧尾与累加和
+	fixed, err := ParseGrammar(map[string]interface{}{"header": "7E", "frameLength": 6, "checksum": "sum8", "checksumStart": 1, "trailer": "0D"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := []byte{0x7E, 0x01, 0x02, 0x03, 0x06, 0x0D}
+	if f, err := fixed.read(bufio.NewReader(bytes.NewReader(frame))); err != nil || !bytes.Equal(f, frame) {
+		t.Errorf("fixed read = % X, %v", f, err)
+	}
+	frame[5] = 0x0A
+	if _, err := fixed.read(bufio.NewReader(bytes.NewReader(frame))); err == nil {
+		t.Error("bad trailer accepted")
+	}
+
+	for _, s := range []string{"AA 5", "AA {len:2}", "AA {x:5}", "AA {", "AG", "{}"} {
+		if _, err := ParseTemplate(s); err == nil {
+			t.Errorf("ParseTemplate(%q) accepted", s)
+		}
+	}
+	if _, err := tmpl.render(g, map[string]interface{}{"addr": 300, "reg": 1}, []byte{0, 0}); err == nil {
+		t.Error("addr 300 fit in 1 byte")
+	}
+	if _, err := tmpl.render(g, map[string]interface{}{"reg": 1}, []byte{0, 0}); err == nil {
+		t.Error("missing addr accepted")
+	}
+	if _, err := tmpl.render(g, map[string]interface{}{"addr": 1, "reg": 1}, nil); err == nil {
+		t.Error("{value} rendered for read")
+	}
+}
+
+// fakeBus 串口服务器上的一条总线，挂多台 BMS；应答前回显请求（模拟转换器回显）。
+// 命令 01 读状态：电压(2)、电流(2，有符号)、SOC(1)、告警位(1)；命令 10 写功率设定(2)，应答回带设定值
+type fakeBus struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	status  map[byte][]byte
+	setting map[byte][]byte
+	reqs    int
+	corrupt int // 接下来几次应答的校验置错
+}
+
+func newFakeBus(t *testing.T) *fakeBus {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBus{ln: ln, status: make(map[byte][]byte), setting: make(map[byte][]byte)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *fakeBus) serve(conn net.Conn) {
+	defer conn.Close()
+	g, _ := ParseGrammar(bmsFrame)
+	r := bufio.NewReader(conn)
+	for {
+		req, err := g.read(r)
+		if err != nil {
+			return
+		}
+		conn.Write(req)
+		b.mu.Lock()
+		b.reqs++
+		cmd, addr, data := req[3], req[4], req[5:len(req)-2]
+		var payload []byte
+		if st, ok := b.status[addr]; ok {
+			switch cmd {
+			case 0x01:
+				payload = st
+			case 0x10:
+				b.setting[addr] = append([]byte(nil), data...)
+				payload = data
+			}
+		}
+		if payload != nil {
+			resp := append([]byte{0xAA, 0x55, byte(2 + len(payload)), cmd | 0x80, addr}, payload...)
+			resp = binary.LittleEndian.AppendUint16(resp, crc16Reflected(resp[2:], 0xFFFF, 0xA001))
+			if b.corrupt > 0 {
+				b.corrupt--
+				resp[len(resp)-1]++
+			}
+			conn.Write(resp)
+		}
+		b.mu.Unlock()
+	}
+}
+
+func bmsConfig(addr string) map[string]interface{} {
+	return map[string]interface{}{
+		"mode": "tcp", "address": addr, "timeoutMs": 500, "frame": bmsFrame,
+		"requests": map[string]interface{}{
+			"status": map[string]interface{}{
+				"template": "AA 55 {len} 01 {addr} {cs}",
+				"match":    map[string]interface{}{"3": "81", "4": "{addr}"},
+			},
+			"setPower": map[string]interface{}{
+				"template": "AA 55 {len} 10 {addr} {value:2} {cs}",
+				"match":    map[string]interface{}{"3": "90", "4": "{addr}"},
+			},
+			"reset": map[string]interface{}{"template": "AA 55 {len} 7F {addr} {cs}", "noReply": true},
+		},
+	}
+}
+
+func TestFramedReadWrite(t *testing.T) {
+	bus := newFakeBus(t)
+	bus.status[1] = []byte{0x02, 0xEE, 0xFF, 0x9C, 87, 0x05}
+	bus.status[2] = []byte{0x03, 0x00, 0x00, 0x64, 50, 0x00}
+
+	a1, err := NewFramedClient(bmsConfig(bus.ln.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a2, _ := NewFramedClient(bmsConfig(bus.ln.Addr().String()))
+	defer a1.Disconnect()
+	defer a2.Disconnect()
+
+	point := func(addr int, kv ...interface{}) map[string]interface{} {
+		p := map[string]interface{}{"addr": addr, "request": "status"}
+		for i := 0; i+1 < len(kv); i += 2 {
+			p[kv[i].(string)] = kv[i+1]
+		}
+		return p
+	}
+	if b, err := a1.Read(point(1, "offset", 5, "length", 2)); err != nil || !bytes.Equal(b, []byte{0x02, 0xEE}) {
+		t.Errorf("voltage = % X, %v", b, err)
+	}
+	if b, err := a2.Read(point(2, "offset", 5, "length", 2)); err != nil || !bytes.Equal(b, []byte{0x03, 0x00}) {
+		t.Errorf("bms2 voltage = % X, %v", b, err)
+	}
+
+	// 同一请求的多个点位只发送一次；位与负偏移
+	params := []map[string]interface{}{
+		point(1, "offset", 5, "length", 2),
+		point(1, "offset", 7, "length", 2),
+		point(1, "offset", 9),
+		point(1, "offset", 10, "bit", 2),
+		point(1, "offset", 10, "bit", 1),
+		point(1, "offset", -2, "length", 2),
+		point(1, "offset", 20),
+		point(2, "offset", 9),
+		{"addr": 1, "request": "bogus"},
+		{"request": "status"},
+	}
+	bus.mu.Lock()
+	bus.reqs = 0
+	bus.mu.Unlock()
+	vals, errs := a1.ReadMulti(params)
+	want := [][]byte{{0x02, 0xEE}, {0xFF, 0x9C}, {87}, {1}, {0}}
+	for i, w := range want {
+		if errs[i] != nil || !bytes.Equal(vals[i], w) {
+			t.Errorf("point %d = % X, %v", i, vals[i], errs[i])
+		}
+	}
+	if errs[5] != nil || len(vals[5]) != 2 {
+		t.Errorf("crc field = % X, %v", vals[5], errs[5])
+	}
+	if errs[6] == nil || errs[8] == nil || errs[9] == nil {
+		t.Errorf("errors = %v", errs[6:])
+	}
+	if errs[7] != nil || !bytes.Equal(vals[7], []byte{50}) {
+		t.Errorf("bms2 soc = % X, %v", vals[7], errs[7])
+	}
+	bus.mu.Lock()
+	reqs := bus.reqs
+	bus.mu.Unlock()
+	if reqs != 2 {
+		t.Errorf("%d requests for 2 devices", reqs)
+	}
+
+	// 校验错误重试
+	bus.mu.Lock()
+	bus.corrupt = 1
+	bus.mu.Unlock()
+	if _, err := a1.Read(point(1, "offset", 9)); err != nil {
+		t.Errorf("retry after checksum error: %v", err)
+	}
+	bus.mu.Lock()
+	bus.corrupt = 2
+	bus.mu.Unlock()
+	if _, err := a1.Read(point(1, "offset", 9)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("persistent checksum error = %v", err)
+	}
+
+	// 写：writeRequest 指定请求，data 填入 {value}
+	if err := a2.Write("power", []byte{0x01, 0xF4}, map[string]interface{}{"addr": 2, "writeRequest": "setPower"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a2.Write("setPower", []byte{0x00, 0x10}, map[string]interface{}{"addr": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a2.Write("setPower", []byte{0x10}, map[string]interface{}{"addr": 1}); err == nil {
+		t.Error("1-byte value accepted for {value:2}")
+	}
+	if err := a2.Write("reset", nil, map[string]interface{}{"addr": 1}); err != nil {
+		t.Errorf("noReply write: %v", err)
+	}
+	if err := a2.Write("setPower", []byte{0, 1}, map[string]interface{}{"addr": 9}); err == nil {
+		t.Error("write to absent device succeeded")
+	}
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if !bytes.Equal(bus.setting[2], []byte{0x01, 0xF4}) || !bytes.Equal(bus.setting[1], []byte{0x00, 0x10}) {
+		t.Errorf("settings = % X", bus.setting)
+	}
+}
+
+func TestFramedConfig(t *testing.T) {
+	base := bmsConfig("127.0.0.1:1")
+	if _, err := protocol.GetAdapter("framed", base); err != nil {
+		t.Errorf("registry: %v", err)
+	}
+	for name, mutate := range map[string]func(map[string]interface{}){
+		"no address":  func(c map[string]interface{}) { delete(c, "address") },
+		"no frame":    func(c map[string]interface{}) { delete(c, "frame") },
+		"no requests": func(c map[string]interface{}) { delete(c, "requests") },
+		"bad mode":    func(c map[string]interface{}) { c["mode"] = "udp" },
+		"undelimited": func(c map[string]interface{}) { c["frame"] = map[string]interface{}{"header": "AA"} },
+		"bad checksum": func(c map[string]interface{}) {
+			c["frame"] = map[string]interface{}{"lengthSize": 1, "checksum": "md5"}
+		},
+		"bad template": func(c map[string]interface{}) { c["requests"] = map[string]interface{}{"x": "AA {len"} },
+		"bad match": func(c map[string]interface{}) {
+			c["requests"] = map[string]interface{}{"x": map[string]interface{}{"template": "AA", "match": map[string]interface{}{"a": "81"}}}
+		},
+	} {
+		cfg := make(map[string]interface{})
+		for k, v := range base {
+			cfg[k] = v
+		}
+		mutate(cfg)
+		if _, err := NewFramedClient(cfg); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package framed 配置驱动的私有二进制协议适配器：按帧格式（帧头、长度字段、校验、帧尾）收帧，
+// 按请求模板组帧，按偏移从应答帧中提取点位字段。用于接入各厂家私有 BMS 协议而无需逐个编写适配器
+package framed
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	errChecksum = errors.New("framed: checksum mismatch")
+	errFrame    = errors.New("framed: bad frame")
+)
+
+// Grammar 帧格式。偏移均相对帧首，负数表示自帧尾倒数
+type Grammar struct {
+	Header       []byte
+	LengthOffset int
+	LengthSize   int // 长度字段字节数，0 表示定长帧
+	LengthLittle bool
+	LengthAdjust int // 帧总长 = 长度值 + LengthAdjust
+	FrameLength  int // 定长帧总长
+	Checksum     string
+	CSStart      int // 校验范围起点
+	CSEnd        int // 校验范围终点（不含），0 表示到校验字段为止
+	CSOffset     int // 校验字段位置，缺省紧靠帧尾之前
+	CSLittle     bool
+	Trailer      []byte
+	MaxLength    int
+
+	algo checksumAlgo
+}
+
+// ParseGrammar 解析帧格式配置
+// cfg: header/trailer(十六进制，如"AA 55"), lengthOffset, lengthSize(0|1|2|4), lengthEndian("big"|"little"),
+//
+//	lengthAdjust(帧总长=长度值+lengthAdjust), frameLength(无长度字段时的定长), checksum(sum8|sum16|lrc8|xor8|crc8|
+//	crc16modbus|crc16ccitt|crc16xmodem|crc32|none), checksumStart, checksumEnd, checksumOffset, checksumEndian, maxLength(默认1024)
+func ParseGrammar(cfg map[string]interface{}) (*Grammar, error) {
+	g := &Grammar{MaxLength: 1024}
+	var err error
+	if g.Header, err = hexParam(cfg["header"]); err != nil {
+		return nil, fmt.Errorf("framed: header: %w", err)
+	}
+	if g.Trailer, err = hexParam(cfg["trailer"]); err != nil {
+		return nil, fmt.Errorf("framed: trailer: %w", err)
+	}
+	g.LengthOffset, _ = toInt(cfg["lengthOffset"])
+	g.LengthSize, _ = toInt(cfg["lengthSize"])
+	g.LengthAdjust, _ = toInt(cfg["lengthAdjust"])
+	g.FrameLength, _ = toInt(cfg["frameLength"])
+	if v, ok := toInt(cfg["maxLength"]); ok && v > 0 {
+		g.MaxLength = v
+	}
+	g.LengthLittle, err = littleParam(cfg["lengthEndian"], false)
+	if err != nil {
+		return nil, err
+	}
+	switch g.LengthSize {
+	case 0:
+	case 1, 2, 4:
+		if g.LengthOffset < 0 {
+			return nil, errors.New("framed: lengthOffset must be counted from frame start")
+		}
+	default:
+		return nil, fmt.Errorf("framed: invalid lengthSize %d", g.LengthSize)
+	}
+
+	if name, _ := cfg["checksum"].(string); name != "" && !strings.EqualFold(name, "none") {
+		if g.algo, err = lookupChecksum(name); err != nil {
+			return nil, err
+		}
+		g.Checksum = name
+		g.CSStart, _ = toInt(cfg["checksumStart"])
+		g.CSEnd, _ = toInt(cfg["checksumEnd"])
+		g.CSOffset = -(g.algo.size + len(g.Trailer))
+		if v, ok := toInt(cfg["checksumOffset"]); ok {
+			g.CSOffset = v
+		}
+		if g.CSLittle, err = littleParam(cfg["checksumEndian"], g.algo.little); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+func littleParam(raw interface{}, def bool) (bool, error) {
+	s, _ := raw.(string)
+	switch strings.ToLower(s) {
+	case "":
+		return def, nil
+	case "little", "le":
+		return true, nil
+	case "big", "be":
+		return false, nil
+	}
+	return false, fmt.Errorf("framed: invalid endian %q", s)
+}
+
+// hexParam 十六进制字节串，允许空格、逗号与 0x 前缀
+func hexParam(raw interface{}) ([]byte, error) {
+	s, _ := raw.(string)
+	s = strings.NewReplacer(" ", "", ",", "", "0x", "", "0X", "").Replace(s)
+	return hex.DecodeString(s)
+}
+
+// at 负偏移换算为自帧首
+func at(off, n int) int {
+	if off < 0 {
+		return n + off
+	}
+	return off
+}
+
+// read 收一帧：跳过噪声直到帧头，按长度字段或定长读满，校验帧尾与校验和
+func (g *Grammar) read(r *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, 0, 64)
+	if len(g.Header) > 0 {
+		for !strings.HasSuffix(string(frame), string(g.Header)) {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if frame = append(frame, c); len(frame) > len(g.Header) {
+				frame = frame[1:]
+			}
+		}
+		frame = append(frame[:0:0], g.Header...)
+	}
+	total := g.FrameLength
+	if g.LengthSize > 0 {
+		if err := readTo(r, &frame, g.LengthOffset+g.LengthSize); err != nil {
+			return nil, err
+		}
+		total = int(getUint(frame[g.LengthOffset:g.LengthOffset+g.LengthSize], g.LengthLittle)) + g.LengthAdjust
+	}
+	if total < len(frame) || total < len(g.Header)+len(g.Trailer)+g.algo.size || total > g.MaxLength {
+		return nil, fmt.Errorf("%w: length %d", errFrame, total)
+	}
+	if err := readTo(r, &frame, total); err != nil {
+		return nil, err
+	}
+	if len(g.Trailer) > 0 && !strings.HasSuffix(string(frame), string(g.Trailer)) {
+		return nil, fmt.Errorf("%w: trailer % X", errFrame, frame[len(frame)-len(g.Trailer):])
+	}
+	if g.algo.sum != nil {
+		pos := at(g.CSOffset, len(frame))
+		cs, err := g.checksum(frame, pos)
+		if err != nil {
+			return nil, err
+		}
+		if getUint(frame[pos:pos+g.algo.size], g.CSLittle) != cs {
+			return nil, errChecksum
+		}
+	}
+	return frame, nil
+}
+
+// checksum 计算 pos 处校验字段的值
+func (g *Grammar) checksum(frame []byte, pos int) (uint32, error) {
+	start, end := at(g.CSStart, len(frame)), pos
+	if g.CSEnd != 0 {
+		end = at(g.CSEnd, len(frame))
+	}
+	if pos < 0 || pos+g.algo.size > len(frame) || start < 0 || start > end || end > len(frame) {
+		return 0, fmt.Errorf("%w: checksum range %d..%d at %d outside %d bytes", errFrame, start, end, pos, len(frame))
+	}
+	return g.algo.sum(frame[start:end]), nil
+}
+
+func readTo(r *bufio.Reader, frame *[]byte, n int) error {
+	for len(*frame) < n {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		*frame = append(*frame, c)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package framed
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 模板片段类型
+const (
+	partLiteral = iota
+	partVar     // {name[:size][le]} 取参数整数
+	partValue   // {value[:size]} 写入数据
+	partLength  // {len} 长度字段，按帧格式计算
+	partCS      // {cs} 校验字段，按帧格式计算
+)
+
+type part struct {
+	kind   int
+	lit    []byte
+	name   string
+	size   int
+	little bool
+}
+
+// Template 请求模板，如 "AA 55 {len} 01 {addr} {reg:2} {cs}"：十六进制字面量与占位符，
+// 占位符 {name} 取点位/设备参数中的整数（缺省1字节，{name:2le} 为2字节低字节在前），
+// {value} 为写入数据，{len}/{cs} 按请求帧格式回填长度与校验
+type Template struct {
+	src   string
+	parts []part
+}
+
+// ParseTemplate 解析请求模板
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{src: s}
+	var digits strings.Builder
+	flush := func() error {
+		if digits.Len() == 0 {
+			return nil
+		}
+		b, err := hex.DecodeString(digits.String())
+		if err != nil {
+			return fmt.Errorf("framed: template %q: odd or invalid hex %q", s, digits.String())
+		}
+		t.parts = append(t.parts, part{kind: partLiteral, lit: b})
+		digits.Reset()
+		return nil
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == ',':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case c == '0' && i+1 < len(s) && (s[i+1] == 'x' || s[i+1] == 'X') && digits.Len() == 0:
+			i++
+		case c == '{':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("framed: template %q: unclosed {", s)
+			}
+			p, err := parsePlaceholder(s[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("framed: template %q: %w", s, err)
+			}
+			t.parts = append(t.parts, p)
+			i += end
+		default:
+			digits.WriteByte(c)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func parsePlaceholder(spec string) (part, error) {
+	name, size, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	p := part{kind: partVar, name: name, size: 1}
+	switch name {
+	case "":
+		return p, fmt.Errorf("empty placeholder")
+	case "len":
+		p.kind = partLength
+	case "cs":
+		p.kind = partCS
+	case "value":
+		p.kind, p.size = partValue, 0
+	}
+	if size == "" {
+		return p, nil
+	}
+	if p.kind == partLength || p.kind == partCS {
+		return p, fmt.Errorf("{%s} size comes from the frame definition", name)
+	}
+	lower := strings.ToLower(size)
+	if strings.HasSuffix(lower, "le") {
+		p.little, lower = true, strings.TrimSuffix(lower, "le")
+	} else {
+		lower = strings.TrimSuffix(lower, "be")
+	}
+	n, err := strconv.Atoi(lower)
+	if err != nil || n < 1 || (p.kind == partVar && n > 4) {
+		return p, fmt.Errorf("invalid size %q for {%s}", size, name)
+	}
+	p.size = n
+	return p, nil
+}
+
+// Vars 模板引用的参数名
+func (t *Template) Vars() []string {
+	var names []string
+	for _, p := range t.parts {
+		if p.kind == partVar {
+			names = append(names, p.name)
+		}
+	}
+	return names
+}
+
+func (t *Template) String() string { return t.src }
+
+// render 按参数组帧；g 为 nil 时不允许 {len}/{cs}（如应答匹配模板）
+func (t *Template) render(g *Grammar, vars map[string]interface{}, value []byte) ([]byte, error) {
+	var out []byte
+	lenPos, csPos := -1, -1
+	for _, p := range t.parts {
+		switch p.kind {
+		case partLiteral:
+			out = append(out, p.lit...)
+		case partVar:
+			v, ok := toInt(vars[p.name])
+			if !ok {
+				return nil, fmt.Errorf("framed: template %q: missing parameter %q", t.src, p.name)
+			}
+			if limit := int64(1) << (8 * p.size); int64(v) >= limit || int64(v) < -limit/2 {
+				return nil, fmt.Errorf("framed: parameter %s=%d does not fit %d bytes", p.name, v, p.size)
+			}
+			b := make([]byte, p.size)
+			putUint(b, uint32(v), p.little)
+			out = append(out, b...)
+		case partValue:
+			if value == nil {
+				return nil, fmt.Errorf("framed: template %q: {value} only allowed in write requests", t.src)
+			}
+			if p.size > 0 && len(value) != p.size {
+				return nil, fmt.Errorf("framed: {value:%d} got %d bytes", p.size, len(value))
+			}
+			out = append(out, value...)
+		case partLength:
+			if g == nil || g.LengthSize == 0 {
+				return nil, fmt.Errorf("framed: template %q: {len} needs lengthSize", t.src)
+			}
+			lenPos = len(out)
+			out = append(out, make([]byte, g.LengthSize)...)
+		case partCS:
+			if g == nil || g.algo.sum == nil {
+				return nil, fmt.Errorf("framed: template %q: {cs} needs checksum", t.src)
+			}
+			csPos = len(out)
+			out = append(out, make([]byte, g.algo.size)...)
+		}
+	}
+	if lenPos >= 0 {
+		putUint(out[lenPos:lenPos+g.LengthSize], uint32(len(out)-g.LengthAdjust), g.LengthLittle)
+	}
+	if csPos >= 0 {
+		cs, err := g.checksum(out, csPos)
+		if err != nil {
+			return nil, err
+		}
+		putUint(out[csPos:csPos+g.algo.size], cs, g.CSLittle)
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+// Package iec104 实现 IEC 60870-5-104 主站（客户端）与子站（服务端）共用的
+// APCI/ASDU 编解码和链路层（k/w 窗口、t1/t2/t3 定时器）
+package iec104
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	startByte    = 0x68
+	maxAPDULen   = 253 // 控制域4字节 + ASDU最大249字节
+	apciCtrlSize = 4
+	seqModulo    = 1 << 15
+)
+
+// U 格式功能
+const (
+	uStartDTAct = 0x07
+	uStartDTCon = 0x0B
+	uStopDTAct  = 0x13
+	uStopDTCon  = 0x23
+	uTestFRAct  = 0x43
+	uTestFRCon  = 0x83
+)
+
+type apduKind int
+
+const (
+	kindI apduKind = iota
+	kindS
+	kindU
+)
+
+type apdu struct {
+	kind apduKind
+	ns   uint16 // 发送序号 N(S)
+	nr   uint16 // 接收序号 N(R)
+	u    byte   // U格式功能
+	asdu []byte
+}
+
+func (a apdu) String() string {
+	switch a.kind {
+	case kindI:
+		return fmt.Sprintf("I(ns=%d nr=%d len=%d)", a.ns, a.nr, len(a.asdu))
+	case kindS:
+		return fmt.Sprintf("S(nr=%d)", a.nr)
+	default:
+		return fmt.Sprintf("U(0x%02X)", a.u)
+	}
+}
+
+var errFrame = errors.New("iec104: invalid APDU")
+
+// readAPDU 从流中读取一个APDU
+func readAPDU(r io.Reader) (apdu, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return apdu{}, err
+	}
+	if head[0] != startByte || head[1] < apciCtrlSize || head[1] > maxAPDULen {
+		return apdu{}, fmt.Errorf("%w: header % X", errFrame, head)
+	}
+	body := make([]byte, head[1])
+	if _, err := io.ReadFull(r, body); err != nil {
+		return apdu{}, err
+	}
+	var a apdu
+	switch {
+	case body[0]&0x01 == 0:
+		a.kind = kindI
+		a.ns = (uint16(body[0]) | uint16(body[1])<<8) >> 1
+		a.nr = (uint16(body[2]) | uint16(body[3])<<8) >> 1
+		a.asdu = body[apciCtrlSize:]
+	case body[0]&0x03 == 0x01:
+		a.kind = kindS
+		a.nr = (uint16(body[2]) | uint16(body[3])<<8) >> 1
+	default:
+		a.kind = kindU
+		a.u = body[0]
+	}
+	if a.kind != kindI && len(body) != apciCtrlSize {
+		return apdu{}, fmt.Errorf("%w: %s with payload", errFrame, a)
+	}
+	return a, nil
+}
+
+func encodeI(ns, nr uint16, asdu []byte) []byte {
+	out := make([]byte, 0, 2+apciCtrlSize+len(asdu))
+	out = append(out, startByte, byte(apciCtrlSize+len(asdu)),
+		byte(ns<<1), byte(ns>>7), byte(nr<<1), byte(nr>>7))
+	return append(out, asdu...)
+}
+
+func encodeS(nr uint16) []byte {
+	return []byte{startByte, apciCtrlSize, 0x01, 0x00, byte(nr << 1), byte(nr >> 7)}
+}
+
+func encodeU(fn byte) []byte {
+	return []byte{startByte, apciCtrlSize, fn, 0x00, 0x00, 0x00}
+}
+
+// seqDiff 计算 a-b（模 2^15）
+func seqDiff(a, b uint16) uint16 {
+	return (a - b) & (seqModulo - 1)
+}
@@ -0,0 +1,397 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// TypeID 类型标识
+type TypeID uint8
+
+// 支持的类型标识
+const (
+	M_SP_NA_1 TypeID = 1  // 单点信息
+	M_DP_NA_1 TypeID = 3  // 双点信息
+	M_ME_NA_1 TypeID = 9  // 测量值，归一化值
+	M_ME_NB_1 TypeID = 11 // 测量值，标度化值
+	M_ME_NC_1 TypeID = 13 // 测量值，短浮点数
+	M_IT_NA_1 TypeID = 15 // 累计量
+	M_SP_TB_1 TypeID = 30 // 带CP56Time2a时标的单点信息
+	M_DP_TB_1 TypeID = 31
+	M_ME_TD_1 TypeID = 34
+	M_ME_TE_1 TypeID = 35
+	M_ME_TF_1 TypeID = 36
+	M_IT_TB_1 TypeID = 37
+	M_EI_NA_1 TypeID = 70 // 初始化结束
+
+	C_SC_NA_1 TypeID = 45  // 单命令
+	C_DC_NA_1 TypeID = 46  // 双命令
+	C_SE_NA_1 TypeID = 48  // 设定值，归一化值
+	C_SE_NB_1 TypeID = 49  // 设定值，标度化值
+	C_SE_NC_1 TypeID = 50  // 设定值，短浮点数
+	C_IC_NA_1 TypeID = 100 // 总召唤
+	C_CI_NA_1 TypeID = 101 // 电度量召唤
+	C_CS_NA_1 TypeID = 103 // 时钟同步
+)
+
+// Cause 传送原因
+type Cause uint8
+
+const (
+	CotPeriodic     Cause = 1
+	CotBackground   Cause = 2
+	CotSpontaneous  Cause = 3
+	CotInit         Cause = 4
+	CotRequest      Cause = 5
+	CotAct          Cause = 6
+	CotActCon       Cause = 7
+	CotDeact        Cause = 8
+	CotDeactCon     Cause = 9
+	CotActTerm      Cause = 10
+	CotInrogen      Cause = 20 // 响应站召唤
+	CotReqcogen     Cause = 37 // 响应计数量召唤
+	CotUnknownType  Cause = 44
+	CotUnknownCause Cause = 45
+	CotUnknownCA    Cause = 46
+	CotUnknownIOA   Cause = 47
+)
+
+// 品质描述位（SIQ/DIQ/QDS/BCR 归一到同一组位）
+const (
+	QualityOV uint8 = 0x01 // 溢出
+	QualityBL uint8 = 0x10 // 被闭锁
+	QualitySB uint8 = 0x20 // 被取代
+	QualityNT uint8 = 0x40 // 非当前值
+	QualityIV uint8 = 0x80 // 无效
+)
+
+// 命令限定词
+const (
+	QOIStation = 20 // 站召唤
+	QCCGeneral = 5  // 总的请求计数量（冻结不带复位）
+	SelectBit  = 0x80
+)
+
+// InfoObject 信息对象。
+// Value: 单点0/1、双点0..3、归一化值(-1..1)、标度化值、短浮点、累计量；
+// Qualifier: 命令的 S/E|QU 或 QOS、召唤的 QOI/QCC、初始化原因 COI
+type InfoObject struct {
+	IOA       uint32
+	Value     float64
+	Counter   int32 // 累计量
+	Quality   uint8
+	Qualifier uint8
+	Time      time.Time // 为零表示不带时标
+}
+
+// ASDU 应用服务数据单元（传送原因2字节、公共地址2字节、信息对象地址3字节）
+type ASDU struct {
+	Type       TypeID
+	SQ         bool // 顺序信息对象
+	Cause      Cause
+	Negative   bool
+	Test       bool
+	Originator uint8
+	CA         uint16
+	Objects    []InfoObject
+}
+
+func (a *ASDU) String() string {
+	return fmt.Sprintf("ASDU(type=%d cot=%d neg=%v ca=%d n=%d)", a.Type, a.Cause, a.Negative, a.CA, len(a.Objects))
+}
+
+// elementSize 单个信息元素长度（不含IOA），0 表示不支持
+func elementSize(t TypeID) int {
+	switch t {
+	case M_SP_NA_1, M_DP_NA_1, C_SC_NA_1, C_DC_NA_1, C_IC_NA_1, C_CI_NA_1, M_EI_NA_1:
+		return 1
+	case M_ME_NA_1, M_ME_NB_1, C_SE_NA_1, C_SE_NB_1:
+		return 3
+	case M_ME_NC_1, M_IT_NA_1, C_SE_NC_1:
+		return 5
+	case M_SP_TB_1, M_DP_TB_1:
+		return 1 + 7
+	case M_ME_TD_1, M_ME_TE_1:
+		return 3 + 7
+	case M_ME_TF_1, M_IT_TB_1:
+		return 5 + 7
+	case C_CS_NA_1:
+		return 7
+	default:
+		return 0
+	}
+}
+
+var typeNames = map[string]TypeID{
+	"M_SP_NA_1": M_SP_NA_1, "M_DP_NA_1": M_DP_NA_1, "M_ME_NA_1": M_ME_NA_1, "M_ME_NB_1": M_ME_NB_1,
+	"M_ME_NC_1": M_ME_NC_1, "M_IT_NA_1": M_IT_NA_1, "M_SP_TB_1": M_SP_TB_1, "M_DP_TB_1": M_DP_TB_1,
+	"M_ME_TD_1": M_ME_TD_1, "M_ME_TE_1": M_ME_TE_1, "M_ME_TF_1": M_ME_TF_1, "M_IT_TB_1": M_IT_TB_1,
+	"C_SC_NA_1": C_SC_NA_1, "C_DC_NA_1": C_DC_NA_1, "C_SE_NA_1": C_SE_NA_1, "C_SE_NB_1": C_SE_NB_1,
+	"C_SE_NC_1": C_SE_NC_1,
+}
+
+// ParseTypeID 按标准名称（如 "M_ME_NC_1"）解析类型标识
+func ParseTypeID(name string) (TypeID, error) {
+	t, ok := typeNames[strings.ToUpper(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownType, name)
+	}
+	return t, nil
+}
+
+// HasTime 类型是否带CP56Time2a时标
+func (t TypeID) HasTime() bool {
+	switch t {
+	case M_SP_TB_1, M_DP_TB_1, M_ME_TD_1, M_ME_TE_1, M_ME_TF_1, M_IT_TB_1, C_CS_NA_1:
+		return true
+	}
+	return false
+}
+
+// ErrUnknownType 不支持的类型标识
+var ErrUnknownType = errors.New("iec104: unsupported type id")
+
+// DecodeASDU 解析ASDU
+func DecodeASDU(b []byte, loc *time.Location) (*ASDU, error) {
+	if len(b) < 6 {
+		return nil, fmt.Errorf("iec104: short ASDU % X", b)
+	}
+	a := &ASDU{
+		Type:       TypeID(b[0]),
+		SQ:         b[1]&0x80 != 0,
+		Cause:      Cause(b[2] & 0x3F),
+		Negative:   b[2]&0x40 != 0,
+		Test:       b[2]&0x80 != 0,
+		Originator: b[3],
+		CA:         binary.LittleEndian.Uint16(b[4:6]),
+	}
+	n := int(b[1] & 0x7F)
+	size := elementSize(a.Type)
+	if size == 0 {
+		return a, fmt.Errorf("%w %d", ErrUnknownType, a.Type)
+	}
+	body := b[6:]
+	want := n * (3 + size)
+	if a.SQ {
+		want = 3 + n*size
+	}
+	if len(body) != want {
+		return a, fmt.Errorf("iec104: type %d with %d objects expects %d bytes, got %d", a.Type, n, want, len(body))
+	}
+	var ioa uint32
+	for i := 0; i < n; i++ {
+		if !a.SQ || i == 0 {
+			ioa = uint32(body[0]) | uint32(body[1])<<8 | uint32(body[2])<<16
+			body = body[3:]
+		} else {
+			ioa++
+		}
+		obj := decodeElement(a.Type, body[:size], loc)
+		obj.IOA = ioa
+		a.Objects = append(a.Objects, obj)
+		body = body[size:]
+	}
+	return a, nil
+}
+
+func decodeElement(t TypeID, e []byte, loc *time.Location) InfoObject {
+	var o InfoObject
+	switch t {
+	case M_SP_NA_1, M_SP_TB_1:
+		o.Value = float64(e[0] & 0x01)
+		o.Quality = e[0] & 0xF0
+	case M_DP_NA_1, M_DP_TB_1:
+		o.Value = float64(e[0] & 0x03)
+		o.Quality = e[0] & 0xF0
+	case M_ME_NA_1, M_ME_TD_1:
+		o.Value = float64(int16(binary.LittleEndian.Uint16(e))) / 32768
+		o.Quality = e[2]
+	case M_ME_NB_1, M_ME_TE_1:
+		o.Value = float64(int16(binary.LittleEndian.Uint16(e)))
+		o.Quality = e[2]
+	case M_ME_NC_1, M_ME_TF_1:
+		o.Value = float64(math.Float32frombits(binary.LittleEndian.Uint32(e)))
+		o.Quality = e[4]
+	case M_IT_NA_1, M_IT_TB_1:
+		o.Counter = int32(binary.LittleEndian.Uint32(e))
+		o.Value = float64(o.Counter)
+		o.Qualifier = e[4] & 0x1F // 顺序号
+		if e[4]&0x80 != 0 {
+			o.Quality |= QualityIV
+		}
+		if e[4]&0x20 != 0 {
+			o.Quality |= QualityOV // 进位
+		}
+	case C_SC_NA_1:
+		o.Value = float64(e[0] & 0x01)
+		o.Qualifier = e[0] & 0xFC
+	case C_DC_NA_1:
+		o.Value = float64(e[0] & 0x03)
+		o.Qualifier = e[0] & 0xFC
+	case C_SE_NA_1:
+		o.Value = float64(int16(binary.LittleEndian.Uint16(e))) / 32768
+		o.Qualifier = e[2]
+	case C_SE_NB_1:
+		o.Value = float64(int16(binary.LittleEndian.Uint16(e)))
+		o.Qualifier = e[2]
+	case C_SE_NC_1:
+		o.Value = float64(math.Float32frombits(binary.LittleEndian.Uint32(e)))
+		o.Qualifier = e[4]
+	case C_IC_NA_1, C_CI_NA_1, M_EI_NA_1:
+		o.Qualifier = e[0]
+	}
+	if t.HasTime() {
+		o.Time = decodeCP56(e[len(e)-7:], loc)
+	}
+	return o
+}
+
+// Encode 编码ASDU，SQ=1 时按首个IOA连续编码
+func (a *ASDU) Encode(loc *time.Location) ([]byte, error) {
+	size := elementSize(a.Type)
+	if size == 0 {
+		return nil, fmt.Errorf("%w %d", ErrUnknownType, a.Type)
+	}
+	if len(a.Objects) == 0 || len(a.Objects) > 127 {
+		return nil, fmt.Errorf("iec104: %d objects in one ASDU", len(a.Objects))
+	}
+	out := make([]byte, 6, 6+len(a.Objects)*(3+size))
+	out[0] = byte(a.Type)
+	out[1] = byte(len(a.Objects))
+	if a.SQ {
+		out[1] |= 0x80
+	}
+	out[2] = byte(a.Cause) & 0x3F
+	if a.Negative {
+		out[2] |= 0x40
+	}
+	if a.Test {
+		out[2] |= 0x80
+	}
+	out[3] = a.Originator
+	binary.LittleEndian.PutUint16(out[4:6], a.CA)
+	for i, o := range a.Objects {
+		if !a.SQ || i == 0 {
+			out = append(out, byte(o.IOA), byte(o.IOA>>8), byte(o.IOA>>16))
+		}
+		out = append(out, encodeElement(a.Type, o, loc)...)
+	}
+	if len(out) > maxAPDULen-apciCtrlSize {
+		return nil, fmt.Errorf("iec104: ASDU too long (%d bytes)", len(out))
+	}
+	return out, nil
+}
+
+func encodeElement(t TypeID, o InfoObject, loc *time.Location) []byte {
+	e := make([]byte, 0, elementSize(t))
+	switch t {
+	case M_SP_NA_1, M_SP_TB_1:
+		e = append(e, boolBit(o.Value)|o.Quality&0xF0)
+	case M_DP_NA_1, M_DP_TB_1:
+		e = append(e, byte(o.Value)&0x03|o.Quality&0xF0)
+	case M_ME_NA_1, M_ME_TD_1:
+		e = binary.LittleEndian.AppendUint16(e, uint16(normalized(o.Value)))
+		e = append(e, o.Quality)
+	case M_ME_NB_1, M_ME_TE_1:
+		e = binary.LittleEndian.AppendUint16(e, uint16(scaled(o.Value)))
+		e = append(e, o.Quality)
+	case M_ME_NC_1, M_ME_TF_1:
+		e = binary.LittleEndian.AppendUint32(e, math.Float32bits(float32(o.Value)))
+		e = append(e, o.Quality)
+	case M_IT_NA_1, M_IT_TB_1:
+		e = binary.LittleEndian.AppendUint32(e, uint32(o.Counter))
+		seq := o.Qualifier & 0x1F
+		if o.Quality&QualityIV != 0 {
+			seq |= 0x80
+		}
+		if o.Quality&QualityOV != 0 {
+			seq |= 0x20
+		}
+		e = append(e, seq)
+	case C_SC_NA_1:
+		e = append(e, boolBit(o.Value)|o.Qualifier&0xFC)
+	case C_DC_NA_1:
+		e = append(e, byte(o.Value)&0x03|o.Qualifier&0xFC)
+	case C_SE_NA_1:
+		e = binary.LittleEndian.AppendUint16(e, uint16(normalized(o.Value)))
+		e = append(e, o.Qualifier)
+	case C_SE_NB_1:
+		e = binary.LittleEndian.AppendUint16(e, uint16(scaled(o.Value)))
+		e = append(e, o.Qualifier)
+	case C_SE_NC_1:
+		e = binary.LittleEndian.AppendUint32(e, math.Float32bits(float32(o.Value)))
+		e = append(e, o.Qualifier)
+	case C_IC_NA_1, C_CI_NA_1, M_EI_NA_1:
+		e = append(e, o.Qualifier)
+	}
+	if t.HasTime() {
+		e = append(e, encodeCP56(o.Time, loc)...)
+	}
+	return e
+}
+
+func boolBit(v float64) byte {
+	if v != 0 {
+		return 1
+	}
+	return 0
+}
+
+// normalized 归一化值 -1..1 转为 int16，超限截断
+func normalized(v float64) int16 {
+	r := math.Round(v * 32768)
+	if r > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if r < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(r)
+}
+
+func scaled(v float64) int16 {
+	r := math.Round(v)
+	if r > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if r < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(r)
+}
+
+// decodeCP56 解析7字节 CP56Time2a 时标
+func decodeCP56(b []byte, loc *time.Location) time.Time {
+	ms := int(binary.LittleEndian.Uint16(b[0:2]))
+	min := int(b[2] & 0x3F)
+	hour := int(b[3] & 0x1F)
+	day := int(b[4] & 0x1F)
+	month := time.Month(b[5] & 0x0F)
+	year := 2000 + int(b[6]&0x7F)
+	return time.Date(year, month, day, hour, min, ms/1000, (ms%1000)*int(time.Millisecond), loc)
+}
+
+// encodeCP56 编码 CP56Time2a，零值时间按当前时间编码
+func encodeCP56(t time.Time, loc *time.Location) []byte {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	t = t.In(loc)
+	ms := t.Second()*1000 + t.Nanosecond()/int(time.Millisecond)
+	dow := int(t.Weekday())
+	if dow == 0 {
+		dow = 7
+	}
+	return []byte{
+		byte(ms), byte(ms >> 8),
+		byte(t.Minute()),
+		byte(t.Hour()),
+		byte(t.Day()) | byte(dow)<<5,
+		byte(t.Month()),
+		byte(t.Year() - 2000),
+	}
+}
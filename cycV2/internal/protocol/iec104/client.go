@@ -0,0 +1,520 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("iec104", NewIEC104Adapter)
+}
+
+// Event 监视方向的一个信息对象更新（总召唤响应或突发上送）。
+// Bytes 为点位原始字节，格式与 DataType 对应：
+// 单点 bool(1字节)、双点 uint8、归一化/短浮点 float32、标度化 int16、累计量 int32，均为大端
+type Event struct {
+	IOA     uint32
+	Type    TypeID
+	Cause   Cause
+	Value   float64
+	Quality uint8
+	Time    time.Time // 报文时标，无时标时为接收时间
+	Bytes   []byte
+}
+
+// EventHandler 事件回调，在接收协程中调用，不应阻塞
+type EventHandler func(Event)
+
+type cmdKey struct {
+	typ TypeID
+	ioa uint32
+}
+
+// IEC104Adapter 104主站，实现 protocol.ProtocolAdapter 接口。
+// 接收到的监视数据按IOA缓存，Read 返回缓存值；同时通过 OnEvent/Subscribe 推送
+type IEC104Adapter struct {
+	addr        string
+	ca          uint16
+	originator  uint8
+	timing      Timing
+	loc         *time.Location
+	giOnConnect bool
+	giInterval  time.Duration
+	ciInterval  time.Duration
+
+	connMu   sync.Mutex // 串行化建链
+	mu       sync.Mutex
+	link     *link
+	cache    map[uint32]Event
+	handlers []EventHandler
+	subs     protocol.Subscriptions[uint32]
+	pending  map[cmdKey]chan *ASDU
+	stopCh   chan struct{}
+}
+
+// NewIEC104Adapter 工厂函数
+// cfg: address("ip:port"，默认端口2404), commonAddress, originator, k, w, t0Ms~t3Ms,
+//
+//	giOnConnect(默认true), giIntervalMs, ciIntervalMs, utc
+func NewIEC104Adapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewIEC104Client(cfg)
+}
+
+// NewIEC104Client 创建104主站，返回具体类型以便注册事件回调
+func NewIEC104Client(cfg map[string]interface{}) (*IEC104Adapter, error) {
+	addr, _ := cfg["address"].(string)
+	if addr == "" {
+		addr, _ = cfg["addr"].(string)
+	}
+	if addr == "" {
+		return nil, errors.New("iec104: missing address")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "2404")
+	}
+	a := &IEC104Adapter{
+		addr:        addr,
+		ca:          1,
+		timing:      parseTiming(cfg),
+		loc:         time.Local,
+		giOnConnect: true,
+		cache:       make(map[uint32]Event),
+		pending:     make(map[cmdKey]chan *ASDU),
+	}
+	if v, ok := toInt(cfg["commonAddress"]); ok {
+		a.ca = uint16(v)
+	}
+	if v, ok := toInt(cfg["originator"]); ok {
+		a.originator = uint8(v)
+	}
+	if v, ok := cfg["giOnConnect"].(bool); ok {
+		a.giOnConnect = v
+	}
+	if v, ok := toInt(cfg["giIntervalMs"]); ok && v > 0 {
+		a.giInterval = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(cfg["ciIntervalMs"]); ok && v > 0 {
+		a.ciInterval = time.Duration(v) * time.Millisecond
+	}
+	if utc, _ := cfg["utc"].(bool); utc {
+		a.loc = time.UTC
+	}
+	return a, nil
+}
+
+// OnEvent 注册事件回调
+func (a *IEC104Adapter) OnEvent(h EventHandler) {
+	a.mu.Lock()
+	a.handlers = append(a.handlers, h)
+	a.mu.Unlock()
+}
+
+// Subscribe 实现 protocol.Subscriber，按 params["ioa"] 订阅总召唤响应与突发上送
+func (a *IEC104Adapter) Subscribe(params map[string]interface{}, handler func(protocol.PointUpdate)) (func(), error) {
+	ioa, ok := toInt(params["ioa"])
+	if !ok || ioa < 0 || ioa > 0xFFFFFF {
+		return nil, errors.New("iec104: missing ioa")
+	}
+	return a.subs.Add(uint32(ioa), handler), nil
+}
+
+// Connect 建立TCP连接并启动数据传输（STARTDT），按配置发起总召唤
+func (a *IEC104Adapter) Connect() error {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	a.mu.Lock()
+	l := a.link
+	a.mu.Unlock()
+	if l != nil && l.isStarted() {
+		return nil
+	}
+	if l != nil {
+		l.close(errors.New("iec104: reconnect"))
+	}
+
+	conn, err := net.DialTimeout("tcp", a.addr, a.timing.T0)
+	if err != nil {
+		return fmt.Errorf("iec104: dial %s: %w", a.addr, err)
+	}
+	l = newLink(conn, a.timing, true, a.handleASDU)
+	l.run()
+	if err := l.startDT(); err != nil {
+		l.close(err)
+		return err
+	}
+	stopCh := make(chan struct{})
+	a.mu.Lock()
+	a.link = l
+	if a.stopCh != nil {
+		close(a.stopCh)
+	}
+	a.stopCh = stopCh
+	a.mu.Unlock()
+	log.Printf("[IEC104] %s 已启动数据传输", a.addr)
+
+	if a.giOnConnect {
+		if err := a.send(C_IC_NA_1, CotAct, InfoObject{Qualifier: QOIStation}); err != nil {
+			return err
+		}
+	}
+	if a.giInterval > 0 || a.ciInterval > 0 {
+		go a.periodic(l, stopCh)
+	}
+	return nil
+}
+
+// Disconnect 关闭连接
+func (a *IEC104Adapter) Disconnect() error {
+	a.mu.Lock()
+	l := a.link
+	a.link = nil
+	if a.stopCh != nil {
+		close(a.stopCh)
+		a.stopCh = nil
+	}
+	a.mu.Unlock()
+	if l != nil {
+		l.close(errors.New("iec104: disconnected"))
+	}
+	return nil
+}
+
+// periodic 周期总召唤/电度召唤
+func (a *IEC104Adapter) periodic(l *link, stopCh <-chan struct{}) {
+	var giC, ciC <-chan time.Time
+	if a.giInterval > 0 {
+		t := time.NewTicker(a.giInterval)
+		defer t.Stop()
+		giC = t.C
+	}
+	if a.ciInterval > 0 {
+		t := time.NewTicker(a.ciInterval)
+		defer t.Stop()
+		ciC = t.C
+	}
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-l.done:
+			return
+		case <-giC:
+			if err := a.send(C_IC_NA_1, CotAct, InfoObject{Qualifier: QOIStation}); err != nil {
+				log.Printf("[IEC104] 周期总召唤失败: %v", err)
+			}
+		case <-ciC:
+			if err := a.send(C_CI_NA_1, CotAct, InfoObject{Qualifier: QCCGeneral}); err != nil {
+				log.Printf("[IEC104] 周期电度召唤失败: %v", err)
+			}
+		}
+	}
+}
+
+// GeneralInterrogation 总召唤，等待激活确认与激活终止
+func (a *IEC104Adapter) GeneralInterrogation() error {
+	return a.interrogate(C_IC_NA_1, QOIStation)
+}
+
+// CounterInterrogation 电度量召唤，等待激活确认与激活终止
+func (a *IEC104Adapter) CounterInterrogation() error {
+	return a.interrogate(C_CI_NA_1, QCCGeneral)
+}
+
+func (a *IEC104Adapter) interrogate(t TypeID, qualifier uint8) error {
+	if err := a.Connect(); err != nil {
+		return err
+	}
+	ch := a.expect(t, 0)
+	defer a.unexpect(t, 0)
+	if err := a.send(t, CotAct, InfoObject{Qualifier: qualifier}); err != nil {
+		return err
+	}
+	if err := a.waitCon(ch, t, 0); err != nil {
+		return err
+	}
+	return a.waitCause(ch, CotActTerm, 4*a.timing.T1)
+}
+
+// Read 读取IOA缓存值
+// params: ioa, maxAgeMs
+func (a *IEC104Adapter) Read(params map[string]interface{}) ([]byte, error) {
+	if err := a.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	ioa, ok := toInt(params["ioa"])
+	if !ok {
+		return nil, errors.New("iec104: missing ioa")
+	}
+	a.mu.Lock()
+	ev, ok := a.cache[uint32(ioa)]
+	a.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("iec104: no data for ioa %d", ioa)
+	}
+	if ev.Quality&QualityIV != 0 {
+		return nil, fmt.Errorf("iec104: ioa %d quality invalid (0x%02X)", ioa, ev.Quality)
+	}
+	if maxAge, _ := toInt(params["maxAgeMs"]); maxAge > 0 && time.Since(ev.Time) > time.Duration(maxAge)*time.Millisecond {
+		return nil, fmt.Errorf("iec104: ioa %d stale", ioa)
+	}
+	return append([]byte(nil), ev.Bytes...), nil
+}
+
+func (a *IEC104Adapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("iec104: BatchRead not supported")
+}
+
+// Write 遥控/遥调，address 为IOA（可为空，取params["ioa"]）
+// params: command("sc"|"dc"|"se_na"|"se_nb"|"se_nc"，默认sc), sbo(选择后执行), qu/ql 限定词
+// data: sc/dc 为1字节(非0为合/ON)，se_na/se_nc 为大端float32，se_nb 为大端int16
+func (a *IEC104Adapter) Write(address string, data []byte, params map[string]interface{}) error {
+	ioa, ok := toInt(params["ioa"])
+	if address != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(address))
+		if err != nil {
+			return fmt.Errorf("iec104: invalid ioa %q", address)
+		}
+		ioa, ok = n, true
+	}
+	if !ok || ioa < 0 || ioa > 0xFFFFFF {
+		return errors.New("iec104: missing ioa")
+	}
+	cmd, _ := params["command"].(string)
+	if cmd == "" {
+		cmd = "sc"
+	}
+	qualifier, _ := toInt(params["qu"])
+	if v, ok := toInt(params["ql"]); ok {
+		qualifier = v
+	}
+	obj := InfoObject{IOA: uint32(ioa)}
+	var t TypeID
+	switch strings.ToLower(cmd) {
+	case "sc", "dc":
+		if len(data) != 1 {
+			return fmt.Errorf("iec104: %s command expects 1 byte, got %d", cmd, len(data))
+		}
+		t, obj.Value = C_SC_NA_1, float64(boolBit(float64(data[0])))
+		if cmd == "dc" {
+			t, obj.Value = C_DC_NA_1, 1 // 双命令 1=分 2=合
+			if data[0] != 0 {
+				obj.Value = 2
+			}
+		}
+		obj.Qualifier = byte(qualifier&0x1F) << 2
+	case "se_na", "se_nc":
+		if len(data) != 4 {
+			return fmt.Errorf("iec104: %s expects float32, got %d bytes", cmd, len(data))
+		}
+		t, obj.Value = C_SE_NC_1, float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+		if cmd == "se_na" {
+			t = C_SE_NA_1
+		}
+		obj.Qualifier = byte(qualifier & 0x7F)
+	case "se_nb":
+		if len(data) != 2 {
+			return fmt.Errorf("iec104: se_nb expects int16, got %d bytes", len(data))
+		}
+		t, obj.Value = C_SE_NB_1, float64(int16(binary.BigEndian.Uint16(data)))
+		obj.Qualifier = byte(qualifier & 0x7F)
+	default:
+		return fmt.Errorf("iec104: unsupported command %q", cmd)
+	}
+	if sbo, _ := params["sbo"].(bool); sbo {
+		sel := obj
+		sel.Qualifier |= SelectBit
+		if err := a.Command(t, sel); err != nil {
+			return fmt.Errorf("iec104: select ioa %d: %w", ioa, err)
+		}
+	}
+	return a.Command(t, obj)
+}
+
+func (a *IEC104Adapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("iec104: WriteModbus not supported")
+}
+
+// Command 发送命令并等待激活确认
+func (a *IEC104Adapter) Command(t TypeID, obj InfoObject) error {
+	if err := a.Connect(); err != nil {
+		return err
+	}
+	ch := a.expect(t, obj.IOA)
+	defer a.unexpect(t, obj.IOA)
+	if err := a.send(t, CotAct, obj); err != nil {
+		return err
+	}
+	return a.waitCon(ch, t, obj.IOA)
+}
+
+func (a *IEC104Adapter) send(t TypeID, cause Cause, obj InfoObject) error {
+	a.mu.Lock()
+	l := a.link
+	a.mu.Unlock()
+	if l == nil {
+		return errLinkClosed
+	}
+	asdu := &ASDU{Type: t, Cause: cause, Originator: a.originator, CA: a.ca, Objects: []InfoObject{obj}}
+	b, err := asdu.Encode(a.loc)
+	if err != nil {
+		return err
+	}
+	return l.sendASDU(b)
+}
+
+func (a *IEC104Adapter) expect(t TypeID, ioa uint32) chan *ASDU {
+	ch := make(chan *ASDU, 4)
+	a.mu.Lock()
+	a.pending[cmdKey{t, ioa}] = ch
+	a.mu.Unlock()
+	return ch
+}
+
+func (a *IEC104Adapter) unexpect(t TypeID, ioa uint32) {
+	a.mu.Lock()
+	delete(a.pending, cmdKey{t, ioa})
+	a.mu.Unlock()
+}
+
+// waitCon 等待激活确认，否定确认或异常原因返回错误
+func (a *IEC104Adapter) waitCon(ch chan *ASDU, t TypeID, ioa uint32) error {
+	select {
+	case resp := <-ch:
+		if resp.Negative {
+			return fmt.Errorf("iec104: negative confirmation for type %d ioa %d (cot %d)", t, ioa, resp.Cause)
+		}
+		if resp.Cause != CotActCon {
+			return fmt.Errorf("iec104: unexpected cause %d for type %d ioa %d", resp.Cause, t, ioa)
+		}
+		return nil
+	case <-time.After(a.timing.T1):
+		return fmt.Errorf("iec104: confirmation timeout for type %d ioa %d", t, ioa)
+	}
+}
+
+func (a *IEC104Adapter) waitCause(ch chan *ASDU, cause Cause, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case resp := <-ch:
+			if resp.Cause == cause {
+				return nil
+			}
+		case <-deadline:
+			return fmt.Errorf("iec104: timeout waiting cause %d", cause)
+		}
+	}
+}
+
+// handleASDU 链路接收回调
+func (a *IEC104Adapter) handleASDU(b []byte) {
+	asdu, err := DecodeASDU(b, a.loc)
+	if err != nil {
+		log.Printf("[IEC104] %v", err)
+		return
+	}
+	if asdu.CA != a.ca && asdu.CA != 0xFFFF {
+		log.Printf("[IEC104] 忽略公共地址%d的%s", asdu.CA, asdu)
+		return
+	}
+	switch asdu.Type {
+	case C_SC_NA_1, C_DC_NA_1, C_SE_NA_1, C_SE_NB_1, C_SE_NC_1, C_IC_NA_1, C_CI_NA_1:
+		// 命令确认只含一个信息对象，VSQ=0 等异常帧直接丢弃
+		if len(asdu.Objects) != 1 {
+			log.Printf("[IEC104] 忽略%d个信息对象的命令确认%s", len(asdu.Objects), asdu)
+			return
+		}
+		ioa := asdu.Objects[0].IOA
+		a.mu.Lock()
+		ch, ok := a.pending[cmdKey{asdu.Type, ioa}]
+		a.mu.Unlock()
+		if ok {
+			select {
+			case ch <- asdu:
+			default:
+			}
+		}
+		return
+	case M_EI_NA_1:
+		log.Printf("[IEC104] %s 子站初始化结束，重新总召唤", a.addr)
+		go func() {
+			if err := a.send(C_IC_NA_1, CotAct, InfoObject{Qualifier: QOIStation}); err != nil {
+				log.Printf("[IEC104] 总召唤失败: %v", err)
+			}
+		}()
+		return
+	}
+
+	now := time.Now()
+	events := make([]Event, 0, len(asdu.Objects))
+	for _, o := range asdu.Objects {
+		ev := Event{IOA: o.IOA, Type: asdu.Type, Cause: asdu.Cause, Value: o.Value, Quality: o.Quality, Time: o.Time}
+		if ev.Time.IsZero() {
+			ev.Time = now
+		}
+		ev.Bytes = valueBytes(asdu.Type, o)
+		events = append(events, ev)
+	}
+	a.mu.Lock()
+	for _, ev := range events {
+		a.cache[ev.IOA] = ev
+	}
+	handlers := a.handlers
+	a.mu.Unlock()
+	for _, h := range handlers {
+		for _, ev := range events {
+			h(ev)
+		}
+	}
+	for _, ev := range events {
+		u := protocol.PointUpdate{Bytes: ev.Bytes, Timestamp: ev.Time}
+		if ev.Quality&QualityIV != 0 {
+			u.Err = fmt.Errorf("iec104: ioa %d quality invalid (0x%02X)", ev.IOA, ev.Quality)
+		}
+		a.subs.Publish(ev.IOA, u)
+	}
+}
+
+// valueBytes 转换为点位原始字节（大端）
+func valueBytes(t TypeID, o InfoObject) []byte {
+	switch t {
+	case M_SP_NA_1, M_SP_TB_1, M_DP_NA_1, M_DP_TB_1:
+		return []byte{byte(o.Value)}
+	case M_ME_NB_1, M_ME_TE_1:
+		return binary.BigEndian.AppendUint16(nil, uint16(int16(o.Value)))
+	case M_IT_NA_1, M_IT_TB_1:
+		return binary.BigEndian.AppendUint32(nil, uint32(o.Counter))
+	default:
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(o.Value)))
+	}
+}
+
+// toInt 兼容 int/float64/字符串配置
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,346 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStation 原始报文级的模拟子站，由测试逐帧驱动
+type fakeStation struct {
+	t      *testing.T
+	ln     net.Listener
+	conn   net.Conn
+	vs, vr uint16
+	frames chan apdu
+}
+
+func newFakeStation(t *testing.T) *fakeStation {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeStation{t: t, ln: ln, frames: make(chan apdu, 64)}
+	t.Cleanup(func() {
+		ln.Close()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	})
+	return s
+}
+
+// accept 接受连接并确认STARTDT
+func (s *fakeStation) accept() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		s.t.Error(err)
+		return
+	}
+	s.conn = conn
+	go func() {
+		for {
+			a, err := readAPDU(conn)
+			if err != nil {
+				close(s.frames)
+				return
+			}
+			s.frames <- a
+		}
+	}()
+	if a := s.next(); a.kind != kindU || a.u != uStartDTAct {
+		s.t.Errorf("expect STARTDT act, got %s", a)
+		return
+	}
+	conn.Write(encodeU(uStartDTCon))
+}
+
+// next 下一帧（跳过测试帧与S帧以外的类型由调用方判断）
+func (s *fakeStation) next() apdu {
+	select {
+	case a, ok := <-s.frames:
+		if !ok {
+			s.t.Fatal("connection closed")
+		}
+		if a.kind == kindI {
+			s.vr++
+		}
+		return a
+	case <-time.After(2 * time.Second):
+		s.t.Fatal("timeout waiting frame")
+	}
+	return apdu{}
+}
+
+// nextASDU 下一个I帧的ASDU
+func (s *fakeStation) nextASDU() *ASDU {
+	for {
+		a := s.next()
+		if a.kind != kindI {
+			continue
+		}
+		asdu, err := DecodeASDU(a.asdu, time.UTC)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		return asdu
+	}
+}
+
+func (s *fakeStation) send(asdu *ASDU) {
+	b, err := asdu.Encode(time.UTC)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.conn.Write(encodeI(s.vs, s.vr, b))
+	s.vs++
+}
+
+func newTestClient(t *testing.T, s *fakeStation, extra map[string]interface{}) *IEC104Adapter {
+	cfg := map[string]interface{}{"address": s.ln.Addr().String(), "commonAddress": 1, "utc": true, "t1Ms": 500}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	c, err := NewIEC104Client(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+func connect(t *testing.T, s *fakeStation, c *IEC104Adapter) {
+	done := make(chan struct{})
+	go func() { s.accept(); close(done) }()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
+
+func TestASDURoundTrip(t *testing.T) {
+	ts := time.Date(2025, 6, 1, 12, 30, 15, 250*int(time.Millisecond), time.UTC)
+	in := &ASDU{Type: M_ME_TF_1, Cause: CotSpontaneous, CA: 1, Objects: []InfoObject{
+		{IOA: 16385, Value: -12.5, Quality: QualityNT, Time: ts},
+		{IOA: 16386, Value: 3.25, Time: ts},
+	}}
+	b, err := in.Encode(time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecodeASDU(b, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Objects) != 2 || out.Objects[0].Value != -12.5 || out.Objects[0].Quality != QualityNT ||
+		!out.Objects[1].Time.Equal(ts) || out.Objects[1].IOA != 16386 {
+		t.Fatalf("round trip %+v", out.Objects)
+	}
+
+	seq := &ASDU{Type: M_SP_NA_1, SQ: true, Cause: CotInrogen, CA: 1, Objects: []InfoObject{
+		{IOA: 1, Value: 1}, {IOA: 2, Value: 0, Quality: QualityIV}, {IOA: 3, Value: 1},
+	}}
+	b, _ = seq.Encode(time.UTC)
+	if len(b) != 6+3+3 {
+		t.Fatalf("SQ=1 encoding length %d", len(b))
+	}
+	out, err = DecodeASDU(b, time.UTC)
+	if err != nil || out.Objects[2].IOA != 3 || out.Objects[1].Quality != QualityIV {
+		t.Fatalf("SQ decode %+v %v", out, err)
+	}
+	if v := normalized(-1); v != math.MinInt16 {
+		t.Fatalf("normalized(-1)=%d", v)
+	}
+}
+
+func TestHandleEmptyASDU(t *testing.T) {
+	c, err := NewIEC104Client(map[string]interface{}{"address": "127.0.0.1:2404", "commonAddress": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// VSQ=0 的命令确认/监视帧，以及截断的帧，都不能导致崩溃
+	for _, b := range [][]byte{
+		{byte(C_SC_NA_1), 0x00, byte(CotActCon), 0, 1, 0},
+		{byte(C_IC_NA_1), 0x80, byte(CotActTerm), 0, 1, 0, 0, 0, 0},
+		{byte(M_SP_NA_1), 0x00, byte(CotSpontaneous), 0, 1, 0},
+		{byte(C_SC_NA_1), 0x01, byte(CotActCon), 0, 1, 0, 1, 0},
+		{byte(C_SC_NA_1), 0x01},
+	} {
+		c.handleASDU(b)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.cache) != 0 {
+		t.Errorf("cache = %v", c.cache)
+	}
+}
+
+func TestInterrogationAndSpontaneous(t *testing.T) {
+	s := newFakeStation(t)
+	c := newTestClient(t, s, map[string]interface{}{"w": 2})
+	events := make(chan Event, 16)
+	c.OnEvent(func(ev Event) { events <- ev })
+	connect(t, s, c)
+
+	gi := s.nextASDU()
+	if gi.Type != C_IC_NA_1 || gi.Cause != CotAct || gi.Objects[0].Qualifier != QOIStation {
+		t.Fatalf("expect GI on connect, got %s", gi)
+	}
+	gi.Cause = CotActCon
+	s.send(gi)
+	s.send(&ASDU{Type: M_ME_NC_1, Cause: CotInrogen, CA: 1, Objects: []InfoObject{{IOA: 1001, Value: 12.5}}})
+	s.send(&ASDU{Type: M_SP_NA_1, Cause: CotInrogen, CA: 1, Objects: []InfoObject{{IOA: 2001, Value: 1}}})
+	// w=2：收到两个I帧后主站应立即发S帧确认
+	if a := s.next(); a.kind != kindS || a.nr != 2 {
+		t.Fatalf("expect S frame nr=2, got %s", a)
+	}
+	term := *gi
+	term.Cause = CotActTerm
+	s.send(&term)
+
+	for i := 0; i < 2; i++ {
+		<-events
+	}
+	data, err := c.Read(map[string]interface{}{"ioa": 1001})
+	if err != nil || math.Float32frombits(binary.BigEndian.Uint32(data)) != 12.5 {
+		t.Fatalf("read 1001: % X %v", data, err)
+	}
+	if data, err := c.Read(map[string]interface{}{"ioa": 2001}); err != nil || data[0] != 1 {
+		t.Fatalf("read 2001: % X %v", data, err)
+	}
+
+	// 带时标的突发上送
+	ts := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	s.send(&ASDU{Type: M_DP_TB_1, Cause: CotSpontaneous, CA: 1, Objects: []InfoObject{{IOA: 3001, Value: 2, Time: ts}}})
+	s.send(&ASDU{Type: M_IT_NA_1, Cause: CotSpontaneous, CA: 1, Objects: []InfoObject{{IOA: 4001, Counter: 123456}}})
+	ev := <-events
+	if ev.IOA != 3001 || ev.Value != 2 || !ev.Time.Equal(ts) || ev.Cause != CotSpontaneous {
+		t.Fatalf("spontaneous event %+v", ev)
+	}
+	ev = <-events
+	if ev.IOA != 4001 || int32(binary.BigEndian.Uint32(ev.Bytes)) != 123456 {
+		t.Fatalf("counter event %+v", ev)
+	}
+	// 其他公共地址忽略
+	s.send(&ASDU{Type: M_SP_NA_1, Cause: CotSpontaneous, CA: 9, Objects: []InfoObject{{IOA: 2001, Value: 0}}})
+	if data, _ := c.Read(map[string]interface{}{"ioa": 2001}); data[0] != 1 {
+		t.Fatal("ASDU from other common address applied")
+	}
+}
+
+func TestSelectBeforeOperate(t *testing.T) {
+	s := newFakeStation(t)
+	c := newTestClient(t, s, map[string]interface{}{"giOnConnect": false})
+	connect(t, s, c)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Write("6001", []byte{1}, map[string]interface{}{"command": "sc", "sbo": true})
+	}()
+	sel := s.nextASDU()
+	if sel.Type != C_SC_NA_1 || sel.Objects[0].Qualifier&SelectBit == 0 || sel.Objects[0].Value != 1 {
+		t.Fatalf("select %s %+v", sel, sel.Objects)
+	}
+	sel.Cause = CotActCon
+	s.send(sel)
+	exe := s.nextASDU()
+	if exe.Objects[0].Qualifier&SelectBit != 0 {
+		t.Fatalf("execute with select bit %+v", exe.Objects)
+	}
+	exe.Cause = CotActCon
+	s.send(exe)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	// 设定值否定确认
+	go func() {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, math.Float32bits(50))
+		errCh <- c.Write("", b, map[string]interface{}{"ioa": 6101, "command": "se_nc"})
+	}()
+	se := s.nextASDU()
+	if se.Type != C_SE_NC_1 || se.Objects[0].Value != 50 {
+		t.Fatalf("setpoint %s %+v", se, se.Objects)
+	}
+	se.Cause, se.Negative = CotActCon, true
+	s.send(se)
+	if err := <-errCh; err == nil {
+		t.Fatal("expect negative confirmation error")
+	}
+}
+
+func TestT1TimeoutAndTestFrame(t *testing.T) {
+	s := newFakeStation(t)
+	c := newTestClient(t, s, map[string]interface{}{"giOnConnect": false, "t1Ms": 200, "t2Ms": 100, "t3Ms": 50})
+	connect(t, s, c)
+
+	// t3 空闲后主站发送测试帧
+	if a := s.next(); a.kind != kindU || a.u != uTestFRAct {
+		t.Fatalf("expect TESTFR act, got %s", a)
+	}
+	// 不应答测试帧，t1 超时后主站关闭连接
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.mu.Lock()
+		l := c.link
+		c.mu.Unlock()
+		if !l.isStarted() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("link not closed after t1")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// blockingConn 首次写阻塞到 release 后返回错误，之后的写立即失败
+type blockingConn struct {
+	net.Conn
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (c *blockingConn) Write(b []byte) (int, error) {
+	first := false
+	c.once.Do(func() { first = true })
+	if first {
+		close(c.entered)
+		<-c.release
+	}
+	return 0, errors.New("broken pipe")
+}
+
+func (c *blockingConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *blockingConn) Close() error                     { return nil }
+
+func TestLinkWriteErrorDuringSend(t *testing.T) {
+	conn := &blockingConn{entered: make(chan struct{}), release: make(chan struct{})}
+	l := newLink(conn, DefaultTiming(), true, nil)
+	l.started = true
+
+	errs := make(chan error, 2)
+	go func() { errs <- l.write(encodeU(uTestFRAct)) }()
+	<-conn.entered
+	// sendASDU 持有 l.mu 等待 writeMu 时，write 出错关闭链路不能死锁
+	go func() { errs <- l.sendASDU([]byte{byte(M_SP_NA_1), 1, 3, 0, 1, 0, 1, 0, 0, 1}) }()
+	time.Sleep(50 * time.Millisecond)
+	close(conn.release)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Error("write succeeded on a broken connection")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("deadlock between write and sendASDU")
+		}
+	}
+	<-l.done
+}
@@ -0,0 +1,371 @@
+package iec104
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Timing 链路参数
+type Timing struct {
+	K  int           // 未被确认的I帧最大数目
+	W  int           // 收到w个I帧后必须确认
+	T0 time.Duration // 建立连接超时
+	T1 time.Duration // 发送或测试APDU的超时
+	T2 time.Duration // 无数据报文时确认的超时，t2 < t1
+	T3 time.Duration // 长期空闲发送测试帧的超时
+}
+
+// DefaultTiming 标准推荐值
+func DefaultTiming() Timing {
+	return Timing{K: 12, W: 8, T0: 30 * time.Second, T1: 15 * time.Second, T2: 10 * time.Second, T3: 20 * time.Second}
+}
+
+// parseTiming 从配置读取 k/w/t0~t3（t单位为ms）
+func parseTiming(cfg map[string]interface{}) Timing {
+	t := DefaultTiming()
+	if v, ok := toInt(cfg["k"]); ok && v > 0 {
+		t.K = v
+	}
+	if v, ok := toInt(cfg["w"]); ok && v > 0 {
+		t.W = v
+	}
+	for key, dst := range map[string]*time.Duration{"t0Ms": &t.T0, "t1Ms": &t.T1, "t2Ms": &t.T2, "t3Ms": &t.T3} {
+		if v, ok := toInt(cfg[key]); ok && v > 0 {
+			*dst = time.Duration(v) * time.Millisecond
+		}
+	}
+	return t
+}
+
+var (
+	errLinkClosed = errors.New("iec104: link closed")
+	errNotStarted = errors.New("iec104: data transfer not started")
+)
+
+// link 一条104 TCP连接：序号管理、k/w 窗口、t1/t2/t3 定时器。
+// 主站与子站共用，active=true 时为主站侧（发送STARTDT），否则响应STARTDT/STOPDT
+type link struct {
+	conn    net.Conn
+	timing  Timing
+	active  bool
+	onASDU  func([]byte)
+	onState func(started bool)
+
+	mu          sync.Mutex
+	cond        *sync.Cond
+	vs, vr      uint16      // 发送/接收状态变量
+	ackVr       uint16      // 已向对端确认到的接收序号
+	unacked     []time.Time // 已发送未确认I帧的发送时间，按序号顺序
+	recvPending time.Time   // 最早未确认接收I帧的时间
+	lastRecv    time.Time
+	testSent    time.Time // TESTFR act 未确认
+	uSent       time.Time // STARTDT/STOPDT act 未确认
+	started     bool
+	closed      bool
+	err         error
+	done        chan struct{}
+	writeMu     sync.Mutex
+}
+
+func newLink(conn net.Conn, timing Timing, active bool, onASDU func([]byte)) *link {
+	l := &link{
+		conn:     conn,
+		timing:   timing,
+		active:   active,
+		onASDU:   onASDU,
+		lastRecv: time.Now(),
+		done:     make(chan struct{}),
+	}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// run 启动收帧与定时器协程
+func (l *link) run() {
+	go l.readLoop()
+	go l.timerLoop()
+}
+
+func (l *link) write(b []byte) error {
+	l.writeMu.Lock()
+	_ = l.conn.SetWriteDeadline(time.Now().Add(l.timing.T1))
+	_, err := l.conn.Write(b)
+	l.writeMu.Unlock()
+	// close 要取 l.mu，须先释放 writeMu：sendASDU 按 mu -> writeMu 顺序加锁
+	if err != nil {
+		l.close(err)
+	}
+	return err
+}
+
+// close 关闭连接并唤醒等待者，只保留第一个错误
+func (l *link) close(err error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	l.err = err
+	l.started = false
+	l.cond.Broadcast()
+	l.mu.Unlock()
+	_ = l.conn.Close()
+	close(l.done)
+}
+
+// Err 关闭原因
+func (l *link) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+func (l *link) isStarted() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.started && !l.closed
+}
+
+// startDT 主站侧发送STARTDT并等待确认
+func (l *link) startDT() error {
+	l.mu.Lock()
+	l.uSent = time.Now()
+	l.mu.Unlock()
+	if err := l.write(encodeU(uStartDTAct)); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(l.timing.T1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for !l.started && !l.closed {
+		if time.Now().After(deadline) {
+			return errors.New("iec104: STARTDT confirmation timeout")
+		}
+		l.waitFor(deadline)
+	}
+	if l.closed {
+		return l.errOrClosed()
+	}
+	return nil
+}
+
+// sendASDU 发送I帧，未确认帧达到k时等待，最长t1
+func (l *link) sendASDU(asdu []byte) error {
+	l.mu.Lock()
+	deadline := time.Now().Add(l.timing.T1)
+	for !l.closed && l.started && len(l.unacked) >= l.timing.K {
+		if time.Now().After(deadline) {
+			l.mu.Unlock()
+			err := errors.New("iec104: k window full, t1 expired")
+			l.close(err)
+			return err
+		}
+		l.waitFor(deadline)
+	}
+	if l.closed {
+		defer l.mu.Unlock()
+		return l.errOrClosed()
+	}
+	if !l.started {
+		l.mu.Unlock()
+		return errNotStarted
+	}
+	frame := encodeI(l.vs, l.vr, asdu)
+	l.vs = (l.vs + 1) % seqModulo
+	l.unacked = append(l.unacked, time.Now())
+	l.ackVr = l.vr // I帧同时确认已接收的帧
+	l.recvPending = time.Time{}
+	// 写操作在锁内完成以保证帧按序号顺序发出
+	l.writeMu.Lock()
+	_ = l.conn.SetWriteDeadline(time.Now().Add(l.timing.T1))
+	_, err := l.conn.Write(frame)
+	l.writeMu.Unlock()
+	l.mu.Unlock()
+	if err != nil {
+		l.close(err)
+	}
+	return err
+}
+
+// waitFor 在持有l.mu时等待条件变量，最长到deadline
+func (l *link) waitFor(deadline time.Time) {
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		l.mu.Lock()
+		l.cond.Broadcast()
+		l.mu.Unlock()
+	})
+	l.cond.Wait()
+	timer.Stop()
+}
+
+func (l *link) errOrClosed() error {
+	if l.err != nil {
+		return l.err
+	}
+	return errLinkClosed
+}
+
+// ack 处理对端确认的 N(R)
+func (l *link) ack(nr uint16) error {
+	outstanding := uint16(len(l.unacked))
+	acked := seqDiff(nr, seqDiff(l.vs, outstanding))
+	if acked > outstanding {
+		return fmt.Errorf("iec104: invalid N(R)=%d, V(S)=%d, outstanding %d", nr, l.vs, outstanding)
+	}
+	l.unacked = l.unacked[acked:]
+	if acked > 0 {
+		l.cond.Broadcast()
+	}
+	return nil
+}
+
+func (l *link) readLoop() {
+	for {
+		a, err := readAPDU(l.conn)
+		if err != nil {
+			l.close(err)
+			return
+		}
+		if err := l.handle(a); err != nil {
+			log.Printf("[IEC104] %v", err)
+			l.close(err)
+			return
+		}
+	}
+}
+
+func (l *link) handle(a apdu) error {
+	l.mu.Lock()
+	l.lastRecv = time.Now()
+	l.testSent = time.Time{} // 任何接收帧都说明链路正常
+	switch a.kind {
+	case kindI:
+		if !l.started {
+			l.mu.Unlock()
+			return fmt.Errorf("iec104: I frame before STARTDT")
+		}
+		if a.ns != l.vr {
+			l.mu.Unlock()
+			return fmt.Errorf("iec104: sequence error N(S)=%d, V(R)=%d", a.ns, l.vr)
+		}
+		if err := l.ack(a.nr); err != nil {
+			l.mu.Unlock()
+			return err
+		}
+		l.vr = (l.vr + 1) % seqModulo
+		if l.recvPending.IsZero() {
+			l.recvPending = time.Now()
+		}
+		var sFrame []byte
+		if int(seqDiff(l.vr, l.ackVr)) >= l.timing.W {
+			sFrame = encodeS(l.vr)
+			l.ackVr = l.vr
+			l.recvPending = time.Time{}
+		}
+		l.mu.Unlock()
+		if sFrame != nil {
+			if err := l.write(sFrame); err != nil {
+				return err
+			}
+		}
+		if l.onASDU != nil {
+			l.onASDU(a.asdu)
+		}
+		return nil
+	case kindS:
+		err := l.ack(a.nr)
+		l.mu.Unlock()
+		return err
+	}
+
+	// U 帧
+	var reply byte
+	switch a.u {
+	case uTestFRAct:
+		reply = uTestFRCon
+	case uTestFRCon:
+	case uStartDTAct:
+		if !l.active {
+			l.started = true
+			reply = uStartDTCon
+		}
+	case uStartDTCon:
+		if l.active {
+			l.started = true
+			l.uSent = time.Time{}
+		}
+	case uStopDTAct:
+		if !l.active {
+			l.started = false
+			reply = uStopDTCon
+		}
+	case uStopDTCon:
+		if l.active {
+			l.started = false
+			l.uSent = time.Time{}
+		}
+	default:
+		l.mu.Unlock()
+		return fmt.Errorf("iec104: unknown U frame 0x%02X", a.u)
+	}
+	onState, started := l.onState, l.started
+	l.cond.Broadcast()
+	l.mu.Unlock()
+	if reply != 0 {
+		if err := l.write(encodeU(reply)); err != nil {
+			return err
+		}
+	}
+	if onState != nil && (a.u == uStartDTAct || a.u == uStopDTAct || a.u == uStartDTCon || a.u == uStopDTCon) {
+		onState(started)
+	}
+	return nil
+}
+
+// timerLoop 检查 t1/t2/t3
+func (l *link) timerLoop() {
+	tick := l.timing.T2 / 4
+	if l.timing.T1/4 < tick {
+		tick = l.timing.T1 / 4
+	}
+	if tick < 5*time.Millisecond {
+		tick = 5 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case now := <-ticker.C:
+			var send []byte
+			l.mu.Lock()
+			switch {
+			case len(l.unacked) > 0 && now.Sub(l.unacked[0]) > l.timing.T1,
+				!l.testSent.IsZero() && now.Sub(l.testSent) > l.timing.T1,
+				!l.uSent.IsZero() && now.Sub(l.uSent) > l.timing.T1:
+				l.mu.Unlock()
+				l.close(errors.New("iec104: t1 timeout"))
+				return
+			case !l.recvPending.IsZero() && now.Sub(l.recvPending) >= l.timing.T2:
+				send = encodeS(l.vr)
+				l.ackVr = l.vr
+				l.recvPending = time.Time{}
+			case l.testSent.IsZero() && now.Sub(l.lastRecv) >= l.timing.T3:
+				send = encodeU(uTestFRAct)
+				l.testSent = now
+			}
+			l.mu.Unlock()
+			if send != nil {
+				if err := l.write(send); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package iec104
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ServerPoint 子站监视点：IOA 与上送类型，Deadband 为突发上送死区（绝对值）
+type ServerPoint struct {
+	IOA      uint32
+	Type     TypeID
+	Deadband float64
+}
+
+// ServerCommand 子站可接受的控制点，SBO=true 时必须先选择后执行
+type ServerCommand struct {
+	IOA  uint32
+	Type TypeID
+	SBO  bool
+}
+
+// Command 主站下发的控制命令（执行阶段）
+type Command struct {
+	Type      TypeID
+	IOA       uint32
+	Value     float64
+	Qualifier uint8
+}
+
+// CommandHandler 执行控制命令，返回错误时回否定确认
+type CommandHandler func(cmd Command) error
+
+// ServerConfig 子站配置
+type ServerConfig struct {
+	Listen        string // 监听地址，默认 ":2404"
+	CA            uint16
+	Timing        Timing
+	Location      *time.Location
+	MaxConns      int           // 允许的冗余主站连接数，默认4
+	SelectTimeout time.Duration // 选择有效期，默认10s
+	Points        []ServerPoint
+	Commands      []ServerCommand
+}
+
+type pointState struct {
+	cfg     ServerPoint
+	value   float64
+	quality uint8
+	at      time.Time
+	valid   bool    // 是否已有值
+	sent    float64 // 上次突发上送的值，用于死区判断
+	sentQ   uint8
+}
+
+type selection struct {
+	typ   TypeID
+	value float64
+	at    time.Time
+}
+
+// Server 104子站，基于采集数据对上级调度上送，支持多个冗余主站连接
+type Server struct {
+	cfg     ServerConfig
+	handler CommandHandler
+
+	mu       sync.Mutex
+	points   map[uint32]*pointState
+	commands map[uint32]ServerCommand
+	selected map[*serverConn]map[uint32]selection
+	conns    map[*serverConn]struct{}
+	ln       net.Listener
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type serverConn struct {
+	l     *link
+	queue chan []byte // 突发上送队列，写协程按窗口发送
+}
+
+// NewServer 创建子站
+func NewServer(cfg ServerConfig, handler CommandHandler) (*Server, error) {
+	if cfg.Listen == "" {
+		cfg.Listen = ":2404"
+	}
+	if cfg.Timing.K == 0 {
+		cfg.Timing = DefaultTiming()
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	if cfg.MaxConns <= 0 {
+		cfg.MaxConns = 4
+	}
+	if cfg.SelectTimeout <= 0 {
+		cfg.SelectTimeout = 10 * time.Second
+	}
+	s := &Server{
+		cfg:      cfg,
+		handler:  handler,
+		points:   make(map[uint32]*pointState),
+		commands: make(map[uint32]ServerCommand),
+		selected: make(map[*serverConn]map[uint32]selection),
+		conns:    make(map[*serverConn]struct{}),
+	}
+	for _, p := range cfg.Points {
+		if elementSize(p.Type) == 0 || p.Type >= C_SC_NA_1 {
+			return nil, fmt.Errorf("iec104: point %d has unsupported monitor type %d", p.IOA, p.Type)
+		}
+		if _, ok := s.points[p.IOA]; ok {
+			return nil, fmt.Errorf("iec104: duplicate ioa %d", p.IOA)
+		}
+		s.points[p.IOA] = &pointState{cfg: p}
+	}
+	for _, c := range cfg.Commands {
+		switch c.Type {
+		case C_SC_NA_1, C_DC_NA_1, C_SE_NA_1, C_SE_NB_1, C_SE_NC_1:
+		default:
+			return nil, fmt.Errorf("iec104: command %d has unsupported type %d", c.IOA, c.Type)
+		}
+		s.commands[c.IOA] = c
+	}
+	return s, nil
+}
+
+// Start 开始监听
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go s.acceptLoop(ln)
+	log.Printf("[IEC104子站] 监听 %s，%d个监视点，%d个控制点", ln.Addr(), len(s.points), len(s.commands))
+	return nil
+}
+
+// Addr 实际监听地址
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Close 停止监听并断开全部连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	ln := s.ln
+	conns := make([]*serverConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	for _, c := range conns {
+		c.l.close(errors.New("iec104: server closed"))
+	}
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) acceptLoop(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if !closed {
+				log.Printf("[IEC104子站] accept: %v", err)
+			}
+			return
+		}
+		s.mu.Lock()
+		if len(s.conns) >= s.cfg.MaxConns {
+			s.mu.Unlock()
+			log.Printf("[IEC104子站] 连接数已满，拒绝 %s", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		c := &serverConn{queue: make(chan []byte, 1024)}
+		c.l = newLink(conn, s.cfg.Timing, false, func(b []byte) {
+			// 应答可能因k窗口等待，不能阻塞接收协程处理S帧确认
+			go s.handleASDU(c, b)
+		})
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		log.Printf("[IEC104子站] 主站 %s 已连接", conn.RemoteAddr())
+		c.l.run()
+		s.wg.Add(1)
+		go s.writeLoop(c)
+	}
+}
+
+// writeLoop 发送突发数据，连接关闭后清理
+func (s *Server) writeLoop(c *serverConn) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-c.l.done:
+			s.mu.Lock()
+			delete(s.conns, c)
+			delete(s.selected, c)
+			s.mu.Unlock()
+			log.Printf("[IEC104子站] 主站连接断开: %v", c.l.Err())
+			return
+		case b := <-c.queue:
+			if !c.l.isStarted() {
+				continue // 未启动数据传输的备用连接不发送，恢复后由总召唤同步
+			}
+			if err := c.l.sendASDU(b); err != nil {
+				log.Printf("[IEC104子站] 上送失败: %v", err)
+			}
+		}
+	}
+}
+
+// Update 更新监视点的值；超过死区或品质变化时向已启动的连接突发上送。
+// 返回是否触发了突发上送
+func (s *Server) Update(ioa uint32, value float64, quality uint8, at time.Time) bool {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	s.mu.Lock()
+	p, ok := s.points[ioa]
+	if !ok {
+		s.mu.Unlock()
+		return false
+	}
+	first := !p.valid
+	p.value, p.quality, p.at, p.valid = value, quality, at, true
+	changed := first || quality != p.sentQ || math.Abs(value-p.sent) > p.cfg.Deadband
+	if !changed {
+		s.mu.Unlock()
+		return false
+	}
+	p.sent, p.sentQ = value, quality
+	asdu := &ASDU{Type: p.cfg.Type, Cause: CotSpontaneous, CA: s.cfg.CA,
+		Objects: []InfoObject{s.objectOf(p)}}
+	conns := s.startedConns()
+	s.mu.Unlock()
+
+	b, err := asdu.Encode(s.cfg.Location)
+	if err != nil {
+		log.Printf("[IEC104子站] ioa %d 编码失败: %v", ioa, err)
+		return false
+	}
+	for _, c := range conns {
+		select {
+		case c.queue <- b:
+		default:
+			log.Printf("[IEC104子站] 上送队列已满，丢弃ioa %d", ioa)
+		}
+	}
+	return len(conns) > 0
+}
+
+// startedConns 调用方需持有 s.mu
+func (s *Server) startedConns() []*serverConn {
+	var out []*serverConn
+	for c := range s.conns {
+		if c.l.isStarted() {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// objectOf 调用方需持有 s.mu
+func (s *Server) objectOf(p *pointState) InfoObject {
+	o := InfoObject{IOA: p.cfg.IOA, Value: p.value, Quality: p.quality, Time: p.at}
+	if !p.valid {
+		o.Quality |= QualityIV
+	}
+	if p.cfg.Type == M_IT_NA_1 || p.cfg.Type == M_IT_TB_1 {
+		o.Counter = int32(p.value)
+	}
+	return o
+}
+
+// reply 回送镜像ASDU
+func (s *Server) reply(c *serverConn, req *ASDU, cause Cause, negative bool) {
+	resp := *req
+	resp.Cause, resp.Negative = cause, negative
+	if req.Type == C_CS_NA_1 && !negative {
+		resp.Objects = []InfoObject{{IOA: 0, Time: time.Now()}}
+	}
+	b, err := resp.Encode(s.cfg.Location)
+	if err != nil {
+		log.Printf("[IEC104子站] 应答编码失败: %v", err)
+		return
+	}
+	if err := c.l.sendASDU(b); err != nil {
+		log.Printf("[IEC104子站] 应答失败: %v", err)
+	}
+}
+
+func (s *Server) handleASDU(c *serverConn, b []byte) {
+	req, err := DecodeASDU(b, s.cfg.Location)
+	if errors.Is(err, ErrUnknownType) {
+		// 原样回送，传送原因置为未知类型
+		resp := append([]byte(nil), b...)
+		resp[2] = byte(CotUnknownType) | 0x40
+		_ = c.l.sendASDU(resp)
+		return
+	}
+	if err != nil {
+		log.Printf("[IEC104子站] %v", err)
+		return
+	}
+	if req.CA != s.cfg.CA && req.CA != 0xFFFF {
+		s.reply(c, req, CotUnknownCA, true)
+		return
+	}
+	switch req.Type {
+	case C_IC_NA_1:
+		if req.Cause != CotAct {
+			s.reply(c, req, CotUnknownCause, true)
+			return
+		}
+		go s.interrogation(c, req)
+	case C_CI_NA_1:
+		if req.Cause != CotAct {
+			s.reply(c, req, CotUnknownCause, true)
+			return
+		}
+		go s.counterInterrogation(c, req)
+	case C_CS_NA_1:
+		s.reply(c, req, CotActCon, false)
+	case C_SC_NA_1, C_DC_NA_1, C_SE_NA_1, C_SE_NB_1, C_SE_NC_1:
+		s.command(c, req)
+	default:
+		s.reply(c, req, CotUnknownType, true)
+	}
+}
+
+// interrogation 响应总召唤：激活确认 -> 快照（不带时标类型）-> 激活终止
+func (s *Server) interrogation(c *serverConn, req *ASDU) {
+	s.reply(c, req, CotActCon, false)
+	groups := make(map[TypeID][]InfoObject)
+	s.mu.Lock()
+	for _, p := range s.points {
+		t := giType(p.cfg.Type)
+		if t == M_IT_NA_1 {
+			continue // 累计量只响应电度召唤
+		}
+		o := s.objectOf(p)
+		o.Time = time.Time{}
+		groups[t] = append(groups[t], o)
+	}
+	s.mu.Unlock()
+	s.sendGroups(c, groups, CotInrogen)
+	s.reply(c, req, CotActTerm, false)
+}
+
+func (s *Server) counterInterrogation(c *serverConn, req *ASDU) {
+	s.reply(c, req, CotActCon, false)
+	groups := make(map[TypeID][]InfoObject)
+	s.mu.Lock()
+	for _, p := range s.points {
+		if t := giType(p.cfg.Type); t == M_IT_NA_1 {
+			groups[t] = append(groups[t], s.objectOf(p))
+		}
+	}
+	s.mu.Unlock()
+	s.sendGroups(c, groups, CotReqcogen)
+	s.reply(c, req, CotActTerm, false)
+}
+
+// sendGroups 按类型分组、IOA排序并按ASDU长度分包发送
+func (s *Server) sendGroups(c *serverConn, groups map[TypeID][]InfoObject, cause Cause) {
+	types := make([]int, 0, len(groups))
+	for t := range groups {
+		types = append(types, int(t))
+	}
+	sort.Ints(types)
+	for _, ti := range types {
+		t := TypeID(ti)
+		objs := groups[t]
+		sort.Slice(objs, func(i, j int) bool { return objs[i].IOA < objs[j].IOA })
+		per := (maxAPDULen - apciCtrlSize - 6) / (3 + elementSize(t))
+		for len(objs) > 0 {
+			n := per
+			if n > len(objs) {
+				n = len(objs)
+			}
+			asdu := &ASDU{Type: t, Cause: cause, CA: s.cfg.CA, Objects: objs[:n]}
+			objs = objs[n:]
+			b, err := asdu.Encode(s.cfg.Location)
+			if err != nil {
+				log.Printf("[IEC104子站] 召唤数据编码失败: %v", err)
+				continue
+			}
+			if err := c.l.sendASDU(b); err != nil {
+				log.Printf("[IEC104子站] 召唤数据发送失败: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// giType 召唤响应使用不带时标的类型
+func giType(t TypeID) TypeID {
+	switch t {
+	case M_SP_TB_1:
+		return M_SP_NA_1
+	case M_DP_TB_1:
+		return M_DP_NA_1
+	case M_ME_TD_1:
+		return M_ME_NA_1
+	case M_ME_TE_1:
+		return M_ME_NB_1
+	case M_ME_TF_1:
+		return M_ME_NC_1
+	case M_IT_TB_1:
+		return M_IT_NA_1
+	}
+	return t
+}
+
+// command 选择/执行/撤销
+func (s *Server) command(c *serverConn, req *ASDU) {
+	if len(req.Objects) != 1 {
+		s.reply(c, req, CotUnknownIOA, true)
+		return
+	}
+	obj := req.Objects[0]
+	s.mu.Lock()
+	cmdCfg, ok := s.commands[obj.IOA]
+	s.mu.Unlock()
+	if !ok || cmdCfg.Type != req.Type {
+		s.reply(c, req, CotUnknownIOA, true)
+		return
+	}
+	isSelect := obj.Qualifier&SelectBit != 0
+	switch req.Cause {
+	case CotDeact:
+		s.mu.Lock()
+		delete(s.selected[c], obj.IOA)
+		s.mu.Unlock()
+		s.reply(c, req, CotDeactCon, false)
+		return
+	case CotAct:
+	default:
+		s.reply(c, req, CotUnknownCause, true)
+		return
+	}
+
+	if isSelect {
+		s.mu.Lock()
+		if s.selected[c] == nil {
+			s.selected[c] = make(map[uint32]selection)
+		}
+		s.selected[c][obj.IOA] = selection{typ: req.Type, value: obj.Value, at: time.Now()}
+		s.mu.Unlock()
+		s.reply(c, req, CotActCon, false)
+		return
+	}
+	if cmdCfg.SBO {
+		s.mu.Lock()
+		sel, ok := s.selected[c][obj.IOA]
+		delete(s.selected[c], obj.IOA)
+		s.mu.Unlock()
+		if !ok || sel.typ != req.Type || sel.value != obj.Value || time.Since(sel.at) > s.cfg.SelectTimeout {
+			log.Printf("[IEC104子站] ioa %d 未选择或选择失效，拒绝执行", obj.IOA)
+			s.reply(c, req, CotActCon, true)
+			return
+		}
+	}
+	// 执行可能较慢（进入设备控制队列），不阻塞接收协程
+	go func() {
+		var err error
+		if s.handler == nil {
+			err = errors.New("no command handler")
+		} else {
+			err = s.handler(Command{Type: req.Type, IOA: obj.IOA, Value: obj.Value, Qualifier: obj.Qualifier})
+		}
+		if err != nil {
+			log.Printf("[IEC104子站] ioa %d 命令执行失败: %v", obj.IOA, err)
+			s.reply(c, req, CotActCon, true)
+			return
+		}
+		s.reply(c, req, CotActCon, false)
+		s.reply(c, req, CotActTerm, false)
+	}()
+}
@@ -0,0 +1,176 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, handler CommandHandler) *Server {
+	s, err := NewServer(ServerConfig{
+		Listen: "127.0.0.1:0",
+		CA:     1,
+		Timing: Timing{K: 12, W: 8, T0: time.Second, T1: time.Second, T2: 200 * time.Millisecond, T3: 5 * time.Second},
+		Points: []ServerPoint{
+			{IOA: 16385, Type: M_ME_TF_1, Deadband: 0.5},
+			{IOA: 1, Type: M_SP_TB_1},
+			{IOA: 25601, Type: M_IT_NA_1},
+		},
+		Commands: []ServerCommand{
+			{IOA: 24577, Type: C_SC_NA_1, SBO: true},
+			{IOA: 25089, Type: C_SE_NC_1},
+		},
+	}, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func dialServer(t *testing.T, s *Server) *IEC104Adapter {
+	c, err := NewIEC104Client(map[string]interface{}{"address": s.Addr().String(), "commonAddress": 1, "t1Ms": 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+func waitRead(t *testing.T, c *IEC104Adapter, ioa int) []byte {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, err := c.Read(map[string]interface{}{"ioa": ioa})
+		if err == nil {
+			return data
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ioa %d: %v", ioa, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerInterrogationSnapshot(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.Update(16385, 380.2, 0, time.Time{})
+	s.Update(1, 1, 0, time.Time{})
+	s.Update(25601, 9876, 0, time.Time{})
+
+	c := dialServer(t, s)
+	if err := c.GeneralInterrogation(); err != nil {
+		t.Fatal(err)
+	}
+	if v := math.Float32frombits(binary.BigEndian.Uint32(waitRead(t, c, 16385))); v != float32(380.2) {
+		t.Fatalf("GI value %v", v)
+	}
+	if data := waitRead(t, c, 1); data[0] != 1 {
+		t.Fatalf("GI single point % X", data)
+	}
+	// 累计量只在电度召唤中上送
+	c.mu.Lock()
+	ev, ok := c.cache[25601]
+	c.mu.Unlock()
+	if ok {
+		t.Fatalf("counter in GI: %+v", ev)
+	}
+	if err := c.CounterInterrogation(); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	ev = c.cache[25601]
+	c.mu.Unlock()
+	if ev.Type != M_IT_NA_1 || ev.Cause != CotReqcogen || int32(binary.BigEndian.Uint32(ev.Bytes)) != 9876 {
+		t.Fatalf("counter event %+v", ev)
+	}
+}
+
+func TestServerSpontaneousDeadbandRedundant(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.Update(16385, 100, 0, time.Time{})
+
+	var mu sync.Mutex
+	got := map[int][]float64{}
+	clients := []*IEC104Adapter{dialServer(t, s), dialServer(t, s)}
+	for i, c := range clients {
+		i := i
+		c.OnEvent(func(ev Event) {
+			if ev.Cause == CotSpontaneous {
+				mu.Lock()
+				got[i] = append(got[i], ev.Value)
+				mu.Unlock()
+			}
+		})
+		if err := c.GeneralInterrogation(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s.Update(16385, 100.3, 0, time.Time{}) {
+		t.Fatal("change within deadband should not be sent")
+	}
+	if !s.Update(16385, 101, 0, time.Time{}) {
+		t.Fatal("change beyond deadband should be sent")
+	}
+	if !s.Update(16385, 101, QualityIV, time.Time{}) {
+		t.Fatal("quality change should be sent")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		done := len(got[0]) == 2 && len(got[1]) == 2
+		mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("spontaneous events %v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got[0][0] != 101 || got[1][0] != 101 {
+		t.Fatalf("spontaneous values %v", got)
+	}
+}
+
+func TestServerCommands(t *testing.T) {
+	executed := make(chan Command, 4)
+	s := newTestServer(t, func(cmd Command) error {
+		executed <- cmd
+		return nil
+	})
+	c := dialServer(t, s)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未选择直接执行被拒绝
+	if err := c.Write("24577", []byte{1}, map[string]interface{}{"command": "sc"}); err == nil {
+		t.Fatal("execute without select should be rejected")
+	}
+	if err := c.Write("24577", []byte{1}, map[string]interface{}{"command": "sc", "sbo": true}); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-executed; cmd.IOA != 24577 || cmd.Value != 1 {
+		t.Fatalf("executed %+v", cmd)
+	}
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, math.Float32bits(-250))
+	if err := c.Write("25089", b, map[string]interface{}{"command": "se_nc"}); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := <-executed; cmd.Type != C_SE_NC_1 || cmd.Value != -250 {
+		t.Fatalf("setpoint %+v", cmd)
+	}
+	// 未配置的IOA否定确认
+	if err := c.Write("999", b, map[string]interface{}{"command": "se_nc"}); err == nil {
+		t.Fatal("unknown ioa should be rejected")
+	}
+}
@@ -0,0 +1,145 @@
+package iec61850
+
+import (
+	"errors"
+)
+
+// BER 编解码子集：仅单字节标签、确定长度，覆盖 ACSE/表示层/MMS 用到的编码
+
+var errMalformed = errors.New("iec61850: malformed BER")
+
+// tlv 一个 BER 元素，tag 为完整的标识字节（类别|构造|编号）
+type tlv struct {
+	tag   byte
+	value []byte
+}
+
+func (t tlv) constructed() bool { return t.tag&0x20 != 0 }
+
+func berLen(n int) []byte {
+	switch {
+	case n < 0x80:
+		return []byte{byte(n)}
+	case n <= 0xFF:
+		return []byte{0x81, byte(n)}
+	case n <= 0xFFFF:
+		return []byte{0x82, byte(n >> 8), byte(n)}
+	default:
+		return []byte{0x83, byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+}
+
+// ber 编码一个元素，parts 依次拼接为内容
+func ber(tag byte, parts ...[]byte) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	out := make([]byte, 0, n+5)
+	out = append(out, tag)
+	out = append(out, berLen(n)...)
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// intContent 最短补码
+func intContent(v int64) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; ; v >>= 8 {
+		if (v == 0 && b[0]&0x80 == 0) || (v == -1 && b[0]&0x80 != 0) {
+			return b
+		}
+		b = append([]byte{byte(v)}, b...)
+	}
+}
+
+// uintContent 无符号数按 INTEGER 编码，最高位为1时前补0
+func uintContent(v uint64) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; v != 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+func berInt(tag byte, v int64) []byte   { return ber(tag, intContent(v)) }
+func berUint(tag byte, v uint64) []byte { return ber(tag, uintContent(v)) }
+func berStr(tag byte, s string) []byte  { return ber(tag, []byte(s)) }
+
+func berBool(tag byte, v bool) []byte {
+	if v {
+		return []byte{tag, 1, 0xFF}
+	}
+	return []byte{tag, 1, 0}
+}
+
+// parseTLV 解析一个元素，返回剩余字节
+func parseTLV(b []byte) (tlv, []byte, error) {
+	if len(b) < 2 || b[0]&0x1F == 0x1F {
+		return tlv{}, nil, errMalformed
+	}
+	tag, l, rest := b[0], int(b[1]), b[2:]
+	if l&0x80 != 0 {
+		n := l & 0x7F
+		if n == 0 || n > 3 || len(rest) < n {
+			return tlv{}, nil, errMalformed
+		}
+		l = 0
+		for _, c := range rest[:n] {
+			l = l<<8 | int(c)
+		}
+		rest = rest[n:]
+	}
+	if len(rest) < l {
+		return tlv{}, nil, errMalformed
+	}
+	return tlv{tag: tag, value: rest[:l]}, rest[l:], nil
+}
+
+// parseAll 解析连续的元素序列
+func parseAll(b []byte) ([]tlv, error) {
+	var out []tlv
+	for len(b) > 0 {
+		t, rest, err := parseTLV(b)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+		b = rest
+	}
+	return out, nil
+}
+
+// find 取序列中第一个指定标签的元素
+func find(items []tlv, tag byte) (tlv, bool) {
+	for _, t := range items {
+		if t.tag == tag {
+			return t, true
+		}
+	}
+	return tlv{}, false
+}
+
+func decodeInt(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+func decodeUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
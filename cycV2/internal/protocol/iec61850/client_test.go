@@ -0,0 +1,258 @@
+package iec61850
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+func newTestClient(t *testing.T, s *stubServer, extra map[string]interface{}) *IEC61850Adapter {
+	cfg := map[string]interface{}{"address": s.addr(), "password": s.password, "timeoutMs": 2000}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	c, err := NewIEC61850Client(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+func mvData(f float64, q Quality, at time.Time) Data {
+	return Data{Kind: KindStructure, Items: []Data{
+		{Kind: KindStructure, Items: []Data{{Kind: KindFloat, Float: f}}},
+		NewQuality(q),
+		{Kind: KindUTCTime, Time: at},
+	}}
+}
+
+func TestReferenceAndBER(t *testing.T) {
+	cases := []struct{ ref, fc, domain, item string }{
+		{"IED1LD0/MMXU1.TotW.mag.f", "MX", "IED1LD0", "MMXU1$MX$TotW$mag$f"},
+		{"IED1LD0/LLN0.brcb01", "br", "IED1LD0", "LLN0$BR$brcb01"},
+		{"IED1LD0/XCBR1$ST$Pos", "", "IED1LD0", "XCBR1$ST$Pos"},
+	}
+	for _, c := range cases {
+		d, i, err := ParseReference(c.ref, c.fc)
+		if err != nil || d != c.domain || i != c.item {
+			t.Fatalf("%s: %s %s %v", c.ref, d, i, err)
+		}
+	}
+	if _, _, err := ParseReference("IED1LD0/MMXU1.TotW", ""); err == nil {
+		t.Fatal("missing fc should fail")
+	}
+
+	at := time.Date(2026, 3, 4, 5, 6, 7, 125e6, time.UTC)
+	for _, d := range []Data{
+		{Kind: KindInteger, Int: -129}, {Kind: KindUnsigned, Int: 0xFFFFFFFF}, {Kind: KindBoolean, Bool: true},
+		{Kind: KindFloat, Float: -1.5}, {Kind: KindFloat, Float: 1e300, Double: true},
+		NewBitString(13, 1, 12), {Kind: KindVisibleString, Str: "abc"},
+		{Kind: KindUTCTime, Time: at, TimeQuality: 0x0A}, {Kind: KindBinaryTime, Time: at},
+		mvData(3.25, QualityInvalid, at),
+	} {
+		tl, rest, err := parseTLV(d.encode())
+		if err != nil || len(rest) != 0 {
+			t.Fatalf("%v: %v", d, err)
+		}
+		got, err := decodeData(tl)
+		if err != nil || !bytes.Equal(got.encode(), d.encode()) {
+			t.Fatalf("round trip %v -> %v (%v)", d, got, err)
+		}
+	}
+}
+
+func TestAssociationAndDiscovery(t *testing.T) {
+	s := newStubServer(t, "secret")
+	bad := newTestClient(t, s, map[string]interface{}{"password": "wrong"})
+	if err := bad.Connect(); err == nil {
+		t.Fatal("wrong password should be rejected")
+	}
+
+	c := newTestClient(t, s, nil)
+	lds, err := c.LogicalDevices()
+	if err != nil || len(lds) != 1 || lds[0] != "IED1LD0" {
+		t.Fatalf("logical devices %v %v", lds, err)
+	}
+	names, err := c.GetNameList(ClassNamedVariable, "IED1LD0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := len(s.names()); len(names) != want || names[len(names)-1] != "XCBR1$ST$Pos$t" {
+		t.Fatalf("name list (%d, want %d): %v", len(names), want, names)
+	}
+
+	// LLN0 含报告控制块，响应超过 TPDU 大小需要分段
+	spec, err := c.GetVariableAccessAttributes("IED1LD0", "LLN0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcb := spec.Component("BR").Component("brcb01")
+	if rcb == nil || rcb.Component("OptFlds").Size != 10 || rcb.Component("EntryID").Kind != KindOctetString {
+		t.Fatalf("rcb type %+v", rcb)
+	}
+	spec, err = c.GetVariableAccessAttributes("IED1LD0", "MMXU1$MX$TotW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves := spec.Leaves("IED1LD0/MMXU1$MX$TotW")
+	if len(leaves) != 3 || leaves[0] != "IED1LD0/MMXU1$MX$TotW$mag$f" || spec.Component("q").Size != -13 {
+		t.Fatalf("TotW leaves %v", leaves)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	s := newStubServer(t, "")
+	at := time.Date(2026, 5, 6, 7, 8, 9, 500e6, time.UTC)
+	s.set("MMXU1$MX$TotW", mvData(123.5, QualityOldData, at))
+	s.set("XCBR1$ST$Pos$stVal", NewBitString(2, 0)) // on(10)
+	c := newTestClient(t, s, nil)
+
+	v, err := c.ReadValue("IED1LD0/MMXU1.TotW", "MX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Data.Float != 123.5 || !v.HasQuality || v.Quality != QualityOldData || !v.Timestamp.Equal(at) {
+		t.Fatalf("TotW value %+v", v)
+	}
+	data, err := c.Read(map[string]interface{}{"ref": "IED1LD0/MMXU1.TotW.mag.f", "fc": "MX"})
+	if err != nil || math.Float32frombits(binary.BigEndian.Uint32(data)) != 123.5 {
+		t.Fatalf("mag.f % X %v", data, err)
+	}
+	data, err = c.Read(map[string]interface{}{"ref": "IED1LD0/XCBR1$ST$Pos"})
+	if err != nil || binary.BigEndian.Uint32(data) != 2 {
+		t.Fatalf("Pos % X %v", data, err)
+	}
+
+	s.set("MMXU1$MX$TotW$q", NewQuality(QualityInvalid))
+	if _, err := c.Read(map[string]interface{}{"ref": "IED1LD0/MMXU1.TotW", "fc": "MX"}); err == nil {
+		t.Fatal("invalid quality should fail")
+	}
+	var dae DataAccessError
+	if _, err := c.Read(map[string]interface{}{"ref": "IED1LD0/MMXU1.NoSuch", "fc": "MX"}); !errors.As(err, &dae) || dae != 10 {
+		t.Fatalf("missing object: %v", err)
+	}
+
+	if err := c.Write("IED1LD0/MMXU1.TotW.db", []byte{0, 0, 0x27, 0x10}, map[string]interface{}{"fc": "CF"}); err != nil {
+		t.Fatal(err)
+	}
+	if d := s.get("MMXU1$CF$TotW$db"); d.Kind != KindUnsigned || d.Int != 10000 {
+		t.Fatalf("db written %+v", d)
+	}
+}
+
+func TestReportsSubscribe(t *testing.T) {
+	s := newStubServer(t, "")
+	at := time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)
+	s.set("MMXU1$MX$TotW", mvData(50, 0, at))
+	s.set("XCBR1$ST$Pos$stVal", NewBitString(2, 1)) // off(01)
+
+	c := newTestClient(t, s, map[string]interface{}{"reports": []interface{}{
+		map[string]interface{}{"rcb": "IED1LD0/LLN0.brcb01", "buffered": true, "trgOps": []interface{}{"dchg", "gi"}},
+	}})
+	var mu sync.Mutex
+	got := map[string][]protocol.PointUpdate{}
+	var reports []*Report
+	c.OnReport(func(r *Report) {
+		mu.Lock()
+		reports = append(reports, r)
+		mu.Unlock()
+	})
+	for _, ref := range []string{"IED1LD0/MMXU1$MX$TotW$mag$f", "IED1LD0/XCBR1$ST$Pos"} {
+		ref := ref
+		if _, err := c.Subscribe(map[string]interface{}{"ref": ref}, func(u protocol.PointUpdate) {
+			mu.Lock()
+			got[ref] = append(got[ref], u)
+			mu.Unlock()
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait := func(ref string, n int) protocol.PointUpdate {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			mu.Lock()
+			us := got[ref]
+			mu.Unlock()
+			if len(us) >= n {
+				return us[n-1]
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: %d updates, want %d", ref, len(us), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// 建链后使能控制块并总召唤
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	u := wait("IED1LD0/MMXU1$MX$TotW$mag$f", 1)
+	if u.Err != nil || math.Float32frombits(binary.BigEndian.Uint32(u.Bytes)) != 50 || !u.Timestamp.Equal(at) {
+		t.Fatalf("GI TotW %+v", u)
+	}
+	if u := wait("IED1LD0/XCBR1$ST$Pos", 1); u.Err != nil || binary.BigEndian.Uint32(u.Bytes) != 1 {
+		t.Fatalf("GI Pos %+v", u)
+	}
+	opt := s.get("LLN0$BR$brcb01$OptFlds")
+	if !s.get("LLN0$BR$brcb01$RptEna").Bool || !opt.Bit(OptDataRef) || !opt.Bit(OptEntryID) {
+		t.Fatalf("rcb not configured: OptFlds %v", opt)
+	}
+	if trg := s.get("LLN0$BR$brcb01$TrgOps"); trg.String() != "010001" {
+		t.Fatalf("TrgOps %v", trg)
+	}
+
+	// 数据变化报告，品质无效时推送错误
+	s.update("MMXU1$MX$TotW", mvData(75.5, QualityInvalid, at.Add(time.Second)))
+	if u := wait("IED1LD0/MMXU1$MX$TotW$mag$f", 2); u.Err == nil || !u.Timestamp.Equal(at.Add(time.Second)) {
+		t.Fatalf("dchg TotW %+v", u)
+	}
+	mu.Lock()
+	last := reports[len(reports)-1]
+	mu.Unlock()
+	if last.RptID != "meas" || last.DataSet != "IED1LD0/LLN0$Meas" || len(last.Entries) != 1 ||
+		last.Entries[0].Index != 0 || !last.Entries[0].Reason.Bit(TrgDataChange) {
+		t.Fatalf("report %+v", last)
+	}
+
+	// 断线重连后从最后收到的 EntryID 续传
+	s.kick()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.mu.Lock()
+		down := c.conn == nil
+		c.mu.Unlock()
+		if down {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("disconnect not detected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.set("LLN0$BR$brcb01$RptEna", Data{Kind: KindBoolean})
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if id := s.get("LLN0$BR$brcb01$EntryID"); !bytes.Equal(id.Bytes, last.EntryID) {
+		t.Fatalf("EntryID resumed % X, want % X", id.Bytes, last.EntryID)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	a, err := protocol.GetAdapter("iec61850", map[string]interface{}{"address": "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.(protocol.Subscriber); !ok || a.(*IEC61850Adapter).addr != "127.0.0.1:102" {
+		t.Fatalf("adapter %+v", a)
+	}
+}
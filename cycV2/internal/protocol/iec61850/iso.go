@@ -0,0 +1,444 @@
+// Package iec61850 纯Go实现的 IEC 61850 MMS 客户端子集（61850-8-1 映射）：
+// 关联建立、GetNameList/GetVariableAccessAttributes 模型发现、按对象引用读写，
+// 以及缓存/非缓存报告控制块的使能与报告接收
+package iec61850
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MMS 下层协议栈：TPKT(RFC1006) / COTP类0 / ISO会话 / ISO表示层 / ACSE，
+// 只实现 61850-8-1 规定的最小子集（内核功能单元、BER传输语法）
+
+// COTP TPDU 类型
+const (
+	tpduCR = 0xE0
+	tpduCC = 0xD0
+	tpduDR = 0x80
+	tpduDT = 0xF0
+	tpduER = 0x70
+)
+
+// 会话层 SPDU 标识
+const (
+	spduData       = 0x01 // Give Tokens / Data Transfer
+	spduFinish     = 0x09
+	spduDisconnect = 0x0A
+	spduRefuse     = 0x0C
+	spduConnect    = 0x0D
+	spduAccept     = 0x0E
+	spduAbort      = 0x19
+)
+
+// 表示上下文
+const (
+	ctxACSE = 1
+	ctxMMS  = 3
+)
+
+var (
+	oidACSE        = []byte{0x52, 0x01, 0x00, 0x01}       // 2.2.1.0.1
+	oidMMS         = []byte{0x28, 0xCA, 0x22, 0x02, 0x01} // 1.0.9506.2.1
+	oidBER         = []byte{0x51, 0x01}                   // 2.1.1
+	oidMMSContext  = []byte{0x28, 0xCA, 0x22, 0x02, 0x03} // 1.0.9506.2.3
+	oidPasswordMec = []byte{0x52, 0x03, 0x01}             // 2.2.3.1
+	selector       = []byte{0x00, 0x00, 0x00, 0x01}       // 表示层选择子
+)
+
+const defaultTPDUSize = 8192
+
+// cotpConn TPKT+COTP 传输连接，负责 DT TPDU 的分段与重组
+type cotpConn struct {
+	conn    net.Conn
+	maxData int
+	wmu     sync.Mutex
+}
+
+func writeTPKT(w io.Writer, payload []byte) error {
+	b := make([]byte, 4, 4+len(payload))
+	b[0] = 3
+	binary.BigEndian.PutUint16(b[2:], uint16(4+len(payload)))
+	_, err := w.Write(append(b, payload...))
+	return err
+}
+
+func readTPKT(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(hdr[2:]))
+	if hdr[0] != 3 || n < 7 {
+		return nil, fmt.Errorf("iec61850: bad TPKT header % X", hdr)
+	}
+	b := make([]byte, n-4)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+// cotpRequest 构造 CR/CC，参数：TPDU大小、主叫/被叫传输选择子
+func cotpRequest(code byte, dstRef, srcRef uint16, sizeCode byte) []byte {
+	b := []byte{0, code, byte(dstRef >> 8), byte(dstRef), byte(srcRef >> 8), byte(srcRef), 0,
+		0xC0, 1, sizeCode,
+		0xC1, 2, 0, 1,
+		0xC2, 2, 0, 1}
+	b[0] = byte(len(b) - 1)
+	return b
+}
+
+// tpduSizeParam 从 CR/CC 参数中取 TPDU 大小
+func tpduSizeParam(tpdu []byte) int {
+	if len(tpdu) < 7 {
+		return 128
+	}
+	p := tpdu[7:min(len(tpdu), int(tpdu[0])+1)]
+	for len(p) >= 2 && len(p) >= 2+int(p[1]) {
+		if p[0] == 0xC0 && p[1] == 1 && p[2] >= 7 && p[2] <= 13 {
+			return 1 << p[2]
+		}
+		p = p[2+int(p[1]):]
+	}
+	return 128
+}
+
+// cotpDial 主动建立传输连接
+func cotpDial(conn net.Conn) (*cotpConn, error) {
+	if err := writeTPKT(conn, cotpRequest(tpduCR, 0, 1, 13)); err != nil {
+		return nil, err
+	}
+	resp, err := readTPKT(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 7 || resp[1] != tpduCC {
+		return nil, fmt.Errorf("iec61850: COTP connection refused (% X)", resp)
+	}
+	return &cotpConn{conn: conn, maxData: min(tpduSizeParam(resp), defaultTPDUSize) - 3}, nil
+}
+
+// write 发送一个会话层数据单元，按 TPDU 大小分段
+func (c *cotpConn) write(b []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	for {
+		n := min(len(b), c.maxData)
+		eot := byte(0)
+		if n == len(b) {
+			eot = 0x80
+		}
+		if err := writeTPKT(c.conn, append([]byte{2, tpduDT, eot}, b[:n]...)); err != nil {
+			return err
+		}
+		b = b[n:]
+		if eot != 0 {
+			return nil
+		}
+	}
+}
+
+// read 读取并重组一个完整的数据单元
+func (c *cotpConn) read() ([]byte, error) {
+	var msg []byte
+	for {
+		tpdu, err := readTPKT(c.conn)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(tpdu) >= 3 && tpdu[1] == tpduDT:
+			msg = append(msg, tpdu[tpdu[0]+1:]...)
+			if tpdu[2]&0x80 != 0 {
+				return msg, nil
+			}
+		case len(tpdu) >= 2 && (tpdu[1] == tpduDR || tpdu[1] == tpduER):
+			return nil, errors.New("iec61850: transport disconnected by peer")
+		default:
+			return nil, fmt.Errorf("iec61850: unexpected TPDU % X", tpdu[:min(len(tpdu), 8)])
+		}
+	}
+}
+
+// spdu 构造会话层 SPDU，参数长度超过254时用3字节长度
+func spdu(si byte, params ...[]byte) []byte {
+	var body []byte
+	for _, p := range params {
+		body = append(body, p...)
+	}
+	return append(append([]byte{si}, spduLen(len(body))...), body...)
+}
+
+func spduLen(n int) []byte {
+	if n <= 254 {
+		return []byte{byte(n)}
+	}
+	return []byte{0xFF, byte(n >> 8), byte(n)}
+}
+
+func spduParam(code byte, v []byte) []byte {
+	return append(append([]byte{code}, spduLen(len(v))...), v...)
+}
+
+// sessionParams CONNECT/ACCEPT 共用参数：协议选项、版本2、全双工、会话选择子
+func sessionParams(accept bool) []byte {
+	p := []byte{0x05, 0x06, 0x13, 0x01, 0x00, 0x16, 0x01, 0x02, 0x14, 0x02, 0x00, 0x02}
+	if !accept {
+		p = append(p, 0x33, 0x02, 0x00, 0x01, 0x34, 0x02, 0x00, 0x01)
+	}
+	return p
+}
+
+// splitSPDU 拆出一个 SPDU 的标识、参数区与剩余字节
+func splitSPDU(b []byte) (si byte, params, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errMalformed
+	}
+	si, n, b := b[0], int(b[1]), b[2:]
+	if n == 0xFF {
+		if len(b) < 2 {
+			return 0, nil, nil, errMalformed
+		}
+		n, b = int(binary.BigEndian.Uint16(b)), b[2:]
+	}
+	if len(b) < n {
+		return 0, nil, nil, errMalformed
+	}
+	return si, b[:n], b[n:], nil
+}
+
+// parseSPDU 解析会话层数据单元，返回 SPDU 类型与其中的表示层数据
+func parseSPDU(b []byte) (byte, []byte, error) {
+	si, params, rest, err := splitSPDU(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	if si == spduData {
+		// Give Tokens 后紧跟 Data Transfer，用户数据在参数区之后
+		si2, _, user, err := splitSPDU(rest)
+		if err != nil || si2 != spduData {
+			return 0, nil, errMalformed
+		}
+		return spduData, user, nil
+	}
+	for len(params) > 0 {
+		code, v, next, err := splitSPDU(params)
+		if err != nil {
+			return 0, nil, err
+		}
+		if code == 0xC1 || code == 0xC2 {
+			return si, v, nil
+		}
+		params = next
+	}
+	return si, nil, nil
+}
+
+// presUserData 表示层 fully-encoded-data
+func presUserData(ctx int, data []byte) []byte {
+	return ber(0x61, ber(0x30, berInt(0x02, int64(ctx)), ber(0xA0, data)))
+}
+
+// parsePresUserData 解析 fully-encoded-data（不含外层标签的内容）
+func parsePresUserData(v []byte) (int, []byte, error) {
+	pdv, _, err := parseTLV(v)
+	if err != nil || pdv.tag != 0x30 {
+		return 0, nil, errMalformed
+	}
+	items, err := parseAll(pdv.value)
+	if err != nil {
+		return 0, nil, err
+	}
+	id, ok1 := find(items, 0x02)
+	data, ok2 := find(items, 0xA0)
+	if !ok1 || !ok2 {
+		return 0, nil, errMalformed
+	}
+	return int(decodeInt(id.value)), data.value, nil
+}
+
+// presConnect CP-type PPDU，定义 ACSE 与 MMS 两个表示上下文
+func presConnect(acse []byte) []byte {
+	return ber(0x31,
+		ber(0xA0, ber(0x80, []byte{1})),
+		ber(0xA2,
+			ber(0x81, selector),
+			ber(0x82, selector),
+			ber(0xA4,
+				ber(0x30, berInt(0x02, ctxACSE), ber(0x06, oidACSE), ber(0x30, ber(0x06, oidBER))),
+				ber(0x30, berInt(0x02, ctxMMS), ber(0x06, oidMMS), ber(0x30, ber(0x06, oidBER)))),
+			presUserData(ctxACSE, acse)))
+}
+
+// parsePresConnect 解析 CP/CPA PPDU，返回其中的 ACSE 数据
+func parsePresConnect(b []byte) ([]byte, error) {
+	t, _, err := parseTLV(b)
+	if err != nil {
+		return nil, err
+	}
+	if t.tag != 0x31 {
+		return nil, errors.New("iec61850: presentation connection refused")
+	}
+	items, err := parseAll(t.value)
+	if err != nil {
+		return nil, err
+	}
+	normal, ok := find(items, 0xA2)
+	if !ok {
+		return nil, errMalformed
+	}
+	if items, err = parseAll(normal.value); err != nil {
+		return nil, err
+	}
+	ud, ok := find(items, 0x61)
+	if !ok {
+		return nil, errMalformed
+	}
+	_, data, err := parsePresUserData(ud.value)
+	return data, err
+}
+
+// ACSE APDU
+type acsePDU struct {
+	tag      byte // 0x60 AARQ / 0x61 AARE
+	result   int
+	password string
+	mms      []byte
+}
+
+func acseUserInfo(mms []byte) []byte {
+	return ber(0xBE, ber(0x28, berInt(0x02, ctxMMS), ber(0xA0, mms)))
+}
+
+// aarq 关联请求，password 非空时使用口令认证
+func aarq(password string, mms []byte) []byte {
+	parts := [][]byte{ber(0xA1, ber(0x06, oidMMSContext))}
+	if password != "" {
+		parts = append(parts,
+			[]byte{0x8A, 0x02, 0x07, 0x80},
+			ber(0x8B, oidPasswordMec),
+			ber(0xAC, berStr(0x80, password)))
+	}
+	parts = append(parts, acseUserInfo(mms))
+	return ber(0x60, parts...)
+}
+
+func parseACSE(b []byte) (*acsePDU, error) {
+	t, _, err := parseTLV(b)
+	if err != nil {
+		return nil, err
+	}
+	if t.tag != 0x60 && t.tag != 0x61 {
+		return nil, fmt.Errorf("iec61850: unexpected ACSE PDU 0x%02X", t.tag)
+	}
+	items, err := parseAll(t.value)
+	if err != nil {
+		return nil, err
+	}
+	p := &acsePDU{tag: t.tag}
+	for _, it := range items {
+		switch it.tag {
+		case 0xA2:
+			if t.tag == 0x61 {
+				r, _, err := parseTLV(it.value)
+				if err != nil {
+					return nil, err
+				}
+				p.result = int(decodeInt(r.value))
+			}
+		case 0xAC:
+			if v, _, err := parseTLV(it.value); err == nil {
+				p.password = string(v.value)
+			}
+		case 0xBE:
+			ext, _, err := parseTLV(it.value)
+			if err != nil {
+				return nil, err
+			}
+			fields, err := parseAll(ext.value)
+			if err != nil {
+				return nil, err
+			}
+			if single, ok := find(fields, 0xA0); ok {
+				p.mms = single.value
+			}
+		}
+	}
+	return p, nil
+}
+
+// mmsConn 已建立的 MMS 关联
+type mmsConn struct {
+	cotp *cotpConn
+}
+
+// associate 在 TCP 连接上依次建立传输、会话、表示层连接与 ACSE 关联，返回 MMS initiate 响应
+func associate(conn net.Conn, password string, initiate []byte, timeout time.Duration) (*mmsConn, []byte, error) {
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+	cotp, err := cotpDial(conn)
+	if err != nil {
+		return nil, nil, err
+	}
+	req := spdu(spduConnect, sessionParams(false), spduParam(0xC1, presConnect(aarq(password, initiate))))
+	if err := cotp.write(req); err != nil {
+		return nil, nil, err
+	}
+	resp, err := cotp.read()
+	if err != nil {
+		return nil, nil, err
+	}
+	si, user, err := parseSPDU(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if si != spduAccept {
+		return nil, nil, fmt.Errorf("iec61850: session refused (SPDU 0x%02X)", si)
+	}
+	acse, err := parsePresConnect(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	aare, err := parseACSE(acse)
+	if err != nil {
+		return nil, nil, err
+	}
+	if aare.tag != 0x61 || aare.result != 0 {
+		return nil, nil, fmt.Errorf("iec61850: association rejected (result %d)", aare.result)
+	}
+	return &mmsConn{cotp: cotp}, aare.mms, nil
+}
+
+func (c *mmsConn) send(pdu []byte) error {
+	return c.cotp.write(append([]byte{spduData, 0, spduData, 0}, presUserData(ctxMMS, pdu)...))
+}
+
+func (c *mmsConn) recv() ([]byte, error) {
+	msg, err := c.cotp.read()
+	if err != nil {
+		return nil, err
+	}
+	si, user, err := parseSPDU(msg)
+	if err != nil {
+		return nil, err
+	}
+	switch si {
+	case spduData:
+		t, _, err := parseTLV(user)
+		if err != nil || t.tag != 0x61 {
+			return nil, errMalformed
+		}
+		_, pdu, err := parsePresUserData(t.value)
+		return pdu, err
+	case spduAbort, spduFinish, spduDisconnect, spduRefuse:
+		return nil, fmt.Errorf("iec61850: session closed by peer (SPDU 0x%02X)", si)
+	default:
+		return nil, fmt.Errorf("iec61850: unexpected SPDU 0x%02X", si)
+	}
+}
+
+func (c *mmsConn) close() error { return c.cotp.conn.Close() }
@@ -0,0 +1,542 @@
+package iec61850
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DataKind MMS Data 的 CHOICE 编号
+type DataKind byte
+
+const (
+	KindArray         DataKind = 1
+	KindStructure     DataKind = 2
+	KindBoolean       DataKind = 3
+	KindBitString     DataKind = 4
+	KindInteger       DataKind = 5
+	KindUnsigned      DataKind = 6
+	KindFloat         DataKind = 7
+	KindOctetString   DataKind = 9
+	KindVisibleString DataKind = 10
+	KindBinaryTime    DataKind = 12
+	KindMMSString     DataKind = 16
+	KindUTCTime       DataKind = 17
+)
+
+// Data MMS 数据值，按 Kind 使用对应字段
+type Data struct {
+	Kind        DataKind
+	Items       []Data // 数组/结构成员
+	Bool        bool
+	Int         int64 // integer/unsigned
+	Float       float64
+	Double      bool   // 64位浮点
+	Bits        []byte // bit-string，首位为第一个字节最高位
+	BitLen      int
+	Bytes       []byte // octet-string
+	Str         string // visible-string/MMS string
+	Time        time.Time
+	TimeQuality byte // utc-time 时间品质
+}
+
+// NewBitString 构造长度为 n 的位串，set 为置1的位序号
+func NewBitString(n int, set ...int) Data {
+	d := Data{Kind: KindBitString, BitLen: n, Bits: make([]byte, (n+7)/8)}
+	for _, i := range set {
+		if i >= 0 && i < n {
+			d.Bits[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return d
+}
+
+// Bit 位串第 i 位
+func (d Data) Bit(i int) bool {
+	return i >= 0 && i < d.BitLen && i/8 < len(d.Bits) && d.Bits[i/8]&(0x80>>(i%8)) != 0
+}
+
+func (d Data) String() string {
+	switch d.Kind {
+	case KindArray, KindStructure:
+		return fmt.Sprint(d.Items)
+	case KindBoolean:
+		return fmt.Sprint(d.Bool)
+	case KindBitString:
+		s := make([]byte, d.BitLen)
+		for i := range s {
+			s[i] = '0'
+			if d.Bit(i) {
+				s[i] = '1'
+			}
+		}
+		return string(s)
+	case KindInteger, KindUnsigned:
+		return fmt.Sprint(d.Int)
+	case KindFloat:
+		return fmt.Sprint(d.Float)
+	case KindOctetString:
+		return fmt.Sprintf("% X", d.Bytes)
+	case KindVisibleString, KindMMSString:
+		return d.Str
+	case KindUTCTime, KindBinaryTime:
+		return d.Time.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("kind(%d)", d.Kind)
+	}
+}
+
+// 1984-01-01，binary-time 的日期起点
+var epoch1984 = time.Date(1984, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (d Data) encode() []byte {
+	tag := 0x80 | byte(d.Kind)
+	switch d.Kind {
+	case KindArray, KindStructure:
+		parts := make([][]byte, len(d.Items))
+		for i, it := range d.Items {
+			parts[i] = it.encode()
+		}
+		return ber(tag|0x20, parts...)
+	case KindBoolean:
+		return berBool(tag, d.Bool)
+	case KindBitString:
+		return ber(tag, []byte{byte(len(d.Bits)*8 - d.BitLen)}, d.Bits)
+	case KindInteger:
+		return berInt(tag, d.Int)
+	case KindUnsigned:
+		return berUint(tag, uint64(d.Int))
+	case KindFloat:
+		if d.Double {
+			b := make([]byte, 9)
+			b[0] = 11
+			binary.BigEndian.PutUint64(b[1:], math.Float64bits(d.Float))
+			return ber(tag, b)
+		}
+		b := make([]byte, 5)
+		b[0] = 8
+		binary.BigEndian.PutUint32(b[1:], math.Float32bits(float32(d.Float)))
+		return ber(tag, b)
+	case KindOctetString:
+		return ber(tag, d.Bytes)
+	case KindVisibleString, KindMMSString:
+		return berStr(tag, d.Str)
+	case KindUTCTime:
+		return ber(tag, encodeUTCTime(d.Time, d.TimeQuality))
+	case KindBinaryTime:
+		t := d.Time.UTC()
+		b := make([]byte, 6)
+		day := t.Truncate(24 * time.Hour)
+		binary.BigEndian.PutUint32(b, uint32(t.Sub(day)/time.Millisecond))
+		binary.BigEndian.PutUint16(b[4:], uint16(day.Sub(epoch1984)/(24*time.Hour)))
+		return ber(tag, b)
+	default:
+		return ber(tag)
+	}
+}
+
+func encodeUTCTime(t time.Time, quality byte) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()))
+	frac := uint32(uint64(t.Nanosecond()) << 24 / 1e9)
+	b[4], b[5], b[6] = byte(frac>>16), byte(frac>>8), byte(frac)
+	b[7] = quality
+	return b
+}
+
+func decodeData(t tlv) (Data, error) {
+	if t.tag&0xC0 != 0x80 {
+		return Data{}, errMalformed
+	}
+	d := Data{Kind: DataKind(t.tag & 0x1F)}
+	v := t.value
+	switch d.Kind {
+	case KindArray, KindStructure:
+		items, err := parseAll(v)
+		if err != nil {
+			return d, err
+		}
+		d.Items = make([]Data, len(items))
+		for i, it := range items {
+			if d.Items[i], err = decodeData(it); err != nil {
+				return d, err
+			}
+		}
+	case KindBoolean:
+		d.Bool = len(v) > 0 && v[0] != 0
+	case KindBitString:
+		if len(v) == 0 || v[0] > 7 {
+			return d, errMalformed
+		}
+		d.Bits = append([]byte(nil), v[1:]...)
+		d.BitLen = len(d.Bits)*8 - int(v[0])
+	case KindInteger:
+		d.Int = decodeInt(v)
+	case KindUnsigned:
+		d.Int = int64(decodeUint(v))
+	case KindFloat:
+		switch len(v) {
+		case 5:
+			d.Float = float64(math.Float32frombits(binary.BigEndian.Uint32(v[1:])))
+		case 9:
+			d.Float, d.Double = math.Float64frombits(binary.BigEndian.Uint64(v[1:])), true
+		default:
+			return d, errMalformed
+		}
+	case KindOctetString:
+		d.Bytes = append([]byte(nil), v...)
+	case KindVisibleString, KindMMSString:
+		d.Str = string(v)
+	case KindUTCTime:
+		if len(v) != 8 {
+			return d, errMalformed
+		}
+		frac := uint64(v[4])<<16 | uint64(v[5])<<8 | uint64(v[6])
+		d.Time = time.Unix(int64(binary.BigEndian.Uint32(v)), int64(frac*1e9>>24)).UTC()
+		d.TimeQuality = v[7]
+	case KindBinaryTime:
+		if len(v) != 4 && len(v) != 6 {
+			return d, errMalformed
+		}
+		ms := time.Duration(binary.BigEndian.Uint32(v)) * time.Millisecond
+		day := epoch1984
+		if len(v) == 6 {
+			day = day.AddDate(0, 0, int(binary.BigEndian.Uint16(v[4:])))
+		}
+		d.Time = day.Add(ms)
+	}
+	return d, nil
+}
+
+// TypeSpec MMS 类型描述（GetVariableAccessAttributes 结果）
+type TypeSpec struct {
+	Kind       DataKind
+	Size       int         // 位串/字符串长度、整数/浮点位宽，负数表示可变长
+	Elements   int         // 数组元素个数
+	Element    *TypeSpec   // 数组元素类型
+	Components []Component // 结构成员
+}
+
+// Component 结构成员
+type Component struct {
+	Name string
+	Type *TypeSpec
+}
+
+// Component 按名取结构成员类型
+func (t *TypeSpec) Component(name string) *TypeSpec {
+	if t == nil {
+		return nil
+	}
+	for _, c := range t.Components {
+		if c.Name == name {
+			return c.Type
+		}
+	}
+	return nil
+}
+
+// Leaves 展开为全部基本类型属性的 MMS 引用，prefix 为本类型自身的引用
+func (t *TypeSpec) Leaves(prefix string) []string {
+	if t == nil || t.Kind != KindStructure {
+		return []string{prefix}
+	}
+	var out []string
+	for _, c := range t.Components {
+		out = append(out, c.Type.Leaves(prefix+"$"+c.Name)...)
+	}
+	return out
+}
+
+func decodeTypeSpec(t tlv) (*TypeSpec, error) {
+	if t.tag&0xC0 != 0x80 {
+		return nil, errMalformed
+	}
+	ts := &TypeSpec{Kind: DataKind(t.tag & 0x1F)}
+	switch ts.Kind {
+	case 0:
+		return nil, errors.New("iec61850: named types not supported")
+	case KindArray:
+		items, err := parseAll(t.value)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := find(items, 0x81)
+		elem, ok := find(items, 0xA2)
+		if !ok {
+			return nil, errMalformed
+		}
+		inner, _, err := parseTLV(elem.value)
+		if err != nil {
+			return nil, err
+		}
+		ts.Elements = int(decodeInt(n.value))
+		if ts.Element, err = decodeTypeSpec(inner); err != nil {
+			return nil, err
+		}
+	case KindStructure:
+		items, err := parseAll(t.value)
+		if err != nil {
+			return nil, err
+		}
+		comps, ok := find(items, 0xA1)
+		if !ok {
+			return nil, errMalformed
+		}
+		list, err := parseAll(comps.value)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range list {
+			fields, err := parseAll(c.value)
+			if err != nil {
+				return nil, err
+			}
+			name, _ := find(fields, 0x80)
+			typ, ok := find(fields, 0xA1)
+			if !ok {
+				return nil, errMalformed
+			}
+			inner, _, err := parseTLV(typ.value)
+			if err != nil {
+				return nil, err
+			}
+			ct, err := decodeTypeSpec(inner)
+			if err != nil {
+				return nil, err
+			}
+			ts.Components = append(ts.Components, Component{Name: string(name.value), Type: ct})
+		}
+	case KindFloat:
+		items, err := parseAll(t.value)
+		if err != nil || len(items) == 0 {
+			return nil, errMalformed
+		}
+		ts.Size = int(decodeInt(items[0].value))
+	case KindBoolean, KindUTCTime:
+	default:
+		ts.Size = int(decodeInt(t.value))
+	}
+	return ts, nil
+}
+
+// MMS 对象类别（GetNameList）
+type ObjectClass int
+
+const (
+	ClassNamedVariable     ObjectClass = 0
+	ClassNamedVariableList ObjectClass = 2
+	ClassJournal           ObjectClass = 8
+	ClassDomain            ObjectClass = 9
+)
+
+// MMS PDU 标签
+const (
+	pduConfirmedRequest  = 0xA0
+	pduConfirmedResponse = 0xA1
+	pduConfirmedError    = 0xA2
+	pduUnconfirmed       = 0xA3
+	pduReject            = 0xA4
+	pduInitiateRequest   = 0xA8
+	pduInitiateResponse  = 0xA9
+	pduInitiateError     = 0xAA
+	pduConcludeRequest   = 0x8B
+	pduConcludeResponse  = 0x8C
+)
+
+// 确认服务标签
+const (
+	svcGetNameList                 = 0xA1
+	svcRead                        = 0xA4
+	svcWrite                       = 0xA5
+	svcGetVariableAccessAttributes = 0xA6
+)
+
+var (
+	parameterCBB      = []byte{0x05, 0xF1, 0x00}                                                       // str1 str2 vnam valt vlis
+	servicesSupported = []byte{0x03, 0xEE, 0x1C, 0x00, 0x00, 0x04, 0x08, 0x00, 0x00, 0x79, 0xEF, 0x18} // 与常见IED客户端一致
+)
+
+func initiateRequest(maxPDU int) []byte {
+	return ber(pduInitiateRequest,
+		berInt(0x80, int64(maxPDU)),
+		berInt(0x81, 5),
+		berInt(0x82, 5),
+		berInt(0x83, 10),
+		ber(0xA4, berInt(0x80, 1), ber(0x81, parameterCBB), ber(0x82, servicesSupported)))
+}
+
+// parseInitiateResponse 返回协商的最大PDU长度
+func parseInitiateResponse(b []byte) (int, error) {
+	t, _, err := parseTLV(b)
+	if err != nil {
+		return 0, err
+	}
+	if t.tag != pduInitiateResponse {
+		return 0, fmt.Errorf("iec61850: MMS initiate rejected (PDU 0x%02X)", t.tag)
+	}
+	items, err := parseAll(t.value)
+	if err != nil {
+		return 0, err
+	}
+	if v, ok := find(items, 0x80); ok {
+		return int(decodeInt(v.value)), nil
+	}
+	return 0, nil
+}
+
+// objectName domain 为空时为 VMD 范围
+func objectName(domain, item string) []byte {
+	if domain == "" {
+		return berStr(0x80, item)
+	}
+	return ber(0xA1, berStr(0x1A, domain), berStr(0x1A, item))
+}
+
+func variableList(domain, item string) []byte {
+	return ber(0xA0, ber(0x30, ber(0xA0, objectName(domain, item))))
+}
+
+func getNameListRequest(class ObjectClass, domain, after string) []byte {
+	scope := []byte{0x80, 0x00}
+	if domain != "" {
+		scope = berStr(0x81, domain)
+	}
+	parts := [][]byte{ber(0xA0, berInt(0x80, int64(class))), ber(0xA1, scope)}
+	if after != "" {
+		parts = append(parts, berStr(0x82, after))
+	}
+	return ber(svcGetNameList, parts...)
+}
+
+func readRequest(domain, item string) []byte {
+	return ber(svcRead, ber(0xA1, variableList(domain, item)))
+}
+
+func writeRequest(domain, item string, d Data) []byte {
+	return ber(svcWrite, variableList(domain, item), ber(0xA0, d.encode()))
+}
+
+func getVariableAccessAttributesRequest(domain, item string) []byte {
+	return ber(svcGetVariableAccessAttributes, ber(0xA0, objectName(domain, item)))
+}
+
+// DataAccessError 变量访问失败原因
+type DataAccessError int
+
+var dataAccessErrors = []string{"object-invalidated", "hardware-fault", "temporarily-unavailable",
+	"object-access-denied", "object-undefined", "invalid-address", "type-unsupported", "type-inconsistent",
+	"object-attribute-inconsistent", "object-access-unsupported", "object-non-existent", "object-value-invalid"}
+
+func (e DataAccessError) Error() string {
+	if int(e) >= 0 && int(e) < len(dataAccessErrors) {
+		return "iec61850: data access error " + dataAccessErrors[e]
+	}
+	return fmt.Sprintf("iec61850: data access error %d", int(e))
+}
+
+// ServiceError MMS 确认服务错误
+type ServiceError struct {
+	Class, Code int
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("iec61850: MMS service error class %d code %d", e.Class, e.Code)
+}
+
+// parseServiceError 解析 Confirmed-ErrorPDU 内容中的 serviceError
+func parseServiceError(items []tlv) error {
+	se, ok := find(items, 0xA2)
+	if !ok {
+		return &ServiceError{Class: -1, Code: -1}
+	}
+	fields, err := parseAll(se.value)
+	if err != nil {
+		return err
+	}
+	class, ok := find(fields, 0xA0)
+	if !ok {
+		return &ServiceError{Class: -1, Code: -1}
+	}
+	c, _, err := parseTLV(class.value)
+	if err != nil {
+		return err
+	}
+	return &ServiceError{Class: int(c.tag & 0x1F), Code: int(decodeInt(c.value))}
+}
+
+// parseAccessResult AccessResult：失败为 [0] DataAccessError，成功为 Data
+func parseAccessResult(t tlv) (Data, error) {
+	if t.tag == 0x80 {
+		return Data{}, DataAccessError(decodeInt(t.value))
+	}
+	return decodeData(t)
+}
+
+func parseGetNameListResponse(v []byte) ([]string, bool, error) {
+	items, err := parseAll(v)
+	if err != nil {
+		return nil, false, err
+	}
+	list, ok := find(items, 0xA0)
+	if !ok {
+		return nil, false, errMalformed
+	}
+	ids, err := parseAll(list.value)
+	if err != nil {
+		return nil, false, err
+	}
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = string(id.value)
+	}
+	more := true // moreFollows DEFAULT TRUE
+	if m, ok := find(items, 0x81); ok {
+		more = len(m.value) > 0 && m.value[0] != 0
+	}
+	return names, more, nil
+}
+
+func parseReadResponse(v []byte) ([]tlv, error) {
+	items, err := parseAll(v)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := find(items, 0xA1)
+	if !ok {
+		return nil, errMalformed
+	}
+	return parseAll(list.value)
+}
+
+func parseWriteResponse(v []byte) error {
+	items, err := parseAll(v)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return errMalformed
+	}
+	if items[0].tag == 0x80 {
+		return DataAccessError(decodeInt(items[0].value))
+	}
+	return nil
+}
+
+func parseGetVariableAccessAttributesResponse(v []byte) (*TypeSpec, error) {
+	items, err := parseAll(v)
+	if err != nil {
+		return nil, err
+	}
+	typ, ok := find(items, 0xA2)
+	if !ok {
+		return nil, errMalformed
+	}
+	inner, _, err := parseTLV(typ.value)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTypeSpec(inner)
+}
@@ -0,0 +1,314 @@
+package iec61850
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Quality 61850 品质，位 i 对应品质位串的第 i 位
+type Quality uint16
+
+const (
+	QualityValidityMask    Quality = 0x0003
+	QualityInvalid         Quality = 0x0002
+	QualityQuestionable    Quality = 0x0003
+	QualityOverflow        Quality = 1 << 2
+	QualityOutOfRange      Quality = 1 << 3
+	QualityBadReference    Quality = 1 << 4
+	QualityOscillatory     Quality = 1 << 5
+	QualityFailure         Quality = 1 << 6
+	QualityOldData         Quality = 1 << 7
+	QualityInconsistent    Quality = 1 << 8
+	QualityInaccurate      Quality = 1 << 9
+	QualitySubstituted     Quality = 1 << 10
+	QualityTest            Quality = 1 << 11
+	QualityOperatorBlocked Quality = 1 << 12
+)
+
+// Invalid 有效性为无效
+func (q Quality) Invalid() bool { return q&QualityValidityMask == QualityInvalid }
+
+func isQuality(d Data) bool { return d.Kind == KindBitString && d.BitLen == 13 }
+
+func qualityOf(d Data) Quality {
+	var q Quality
+	for i := 0; i < d.BitLen && i < 16; i++ {
+		if d.Bit(i) {
+			q |= 1 << i
+		}
+	}
+	return q
+}
+
+// NewQuality 构造品质位串
+func NewQuality(q Quality) Data {
+	var set []int
+	for i := 0; i < 13; i++ {
+		if q&(1<<i) != 0 {
+			set = append(set, i)
+		}
+	}
+	return NewBitString(13, set...)
+}
+
+// Value 读取或报告得到的数据：读取整个数据对象（如 MMXU1.TotW 的 MX 部分）时，
+// 主值取第一个非品质/时标的基本属性（mag.f、stVal 等），品质与时标取同级的 q、t
+type Value struct {
+	Data       Data
+	Quality    Quality
+	HasQuality bool
+	Timestamp  time.Time
+}
+
+func valueOf(d Data) Value {
+	if d.Kind != KindStructure {
+		return Value{Data: d}
+	}
+	v := Value{Data: d}
+	found := false
+	for _, it := range d.Items {
+		switch {
+		case isQuality(it) && !v.HasQuality:
+			v.Quality, v.HasQuality = qualityOf(it), true
+		case it.Kind == KindUTCTime && v.Timestamp.IsZero():
+			v.Timestamp = it.Time
+		case !found:
+			v.Data, found = primaryOf(it)
+		}
+	}
+	if !found {
+		v.Data = d
+	}
+	return v
+}
+
+func primaryOf(d Data) (Data, bool) {
+	switch d.Kind {
+	case KindStructure:
+		for _, it := range d.Items {
+			if isQuality(it) || it.Kind == KindUTCTime {
+				continue
+			}
+			if p, ok := primaryOf(it); ok {
+				return p, true
+			}
+		}
+		return d, false
+	case KindArray:
+		return d, false
+	default:
+		return d, true
+	}
+}
+
+// Bytes 主值转为点位原始字节（大端）：boolean 1字节，integer 为 int32，unsigned 为 uint32，
+// 浮点为 float32，位串（如 Dbpos）按首位为最高位转为 uint32，字符串为原文
+func (v Value) Bytes() ([]byte, error) {
+	d := v.Data
+	b := make([]byte, 4)
+	switch d.Kind {
+	case KindBoolean:
+		if d.Bool {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case KindInteger, KindUnsigned:
+		binary.BigEndian.PutUint32(b, uint32(d.Int))
+	case KindFloat:
+		binary.BigEndian.PutUint32(b, math.Float32bits(float32(d.Float)))
+	case KindBitString:
+		var n uint32
+		for i := 0; i < d.BitLen && i < 32; i++ {
+			n <<= 1
+			if d.Bit(i) {
+				n |= 1
+			}
+		}
+		binary.BigEndian.PutUint32(b, n)
+	case KindUTCTime, KindBinaryTime:
+		binary.BigEndian.PutUint32(b, uint32(d.Time.Unix()))
+	case KindVisibleString, KindMMSString:
+		return []byte(d.Str), nil
+	case KindOctetString:
+		return append([]byte(nil), d.Bytes...), nil
+	default:
+		return nil, errors.New("iec61850: structured value, reference a data attribute")
+	}
+	return b, nil
+}
+
+// ParseReference 对象引用转为 MMS 域名与变量名。支持 61850 形式 "LD/LN.DO.DA"
+// （需给出功能约束 fc，如 MX、ST）与 MMS 形式 "LD/LN$FC$DO$DA"
+func ParseReference(ref, fc string) (domain, item string, err error) {
+	i := strings.IndexByte(ref, '/')
+	if i <= 0 || i == len(ref)-1 {
+		return "", "", fmt.Errorf("iec61850: invalid reference %q", ref)
+	}
+	domain, rest := ref[:i], ref[i+1:]
+	if strings.Contains(rest, "$") {
+		return domain, rest, nil
+	}
+	if fc == "" {
+		return "", "", fmt.Errorf("iec61850: reference %q needs functional constraint", ref)
+	}
+	parts := strings.Split(rest, ".")
+	item = parts[0] + "$" + strings.ToUpper(fc)
+	if len(parts) > 1 {
+		item += "$" + strings.Join(parts[1:], "$")
+	}
+	return domain, item, nil
+}
+
+// 报告选项域 OptFlds 的位
+const (
+	OptSeqNum       = 1
+	OptTimeStamp    = 2
+	OptReason       = 3
+	OptDataSet      = 4
+	OptDataRef      = 5
+	OptBufOvfl      = 6
+	OptEntryID      = 7
+	OptConfRev      = 8
+	OptSegmentation = 9
+)
+
+// 触发条件 TrgOps 的位
+const (
+	TrgDataChange    = 1
+	TrgQualityChange = 2
+	TrgDataUpdate    = 3
+	TrgIntegrity     = 4
+	TrgGI            = 5
+)
+
+// Report 一份报告（InformationReport，变量列表名为 RPT）
+type Report struct {
+	RptID       string
+	SeqNum      int64
+	TimeOfEntry time.Time
+	DataSet     string
+	BufOvfl     bool
+	EntryID     []byte
+	ConfRev     int64
+	Entries     []ReportEntry
+}
+
+// ReportEntry 报告中包含的一个数据集成员
+type ReportEntry struct {
+	Index     int    // 数据集成员序号
+	Reference string // MMS 引用，报告未带数据引用时为空
+	Value     Data
+	Reason    Data // 包含原因位串，未带时为零值
+}
+
+// parseReport 按 61850-8-1 报告格式解析访问结果序列
+func parseReport(items []Data) (*Report, error) {
+	i := 0
+	next := func(kind DataKind) (Data, error) {
+		if i >= len(items) {
+			return Data{}, errors.New("iec61850: report truncated")
+		}
+		d := items[i]
+		i++
+		if kind != 0 && d.Kind != kind {
+			return d, fmt.Errorf("iec61850: report field kind %d, want %d", d.Kind, kind)
+		}
+		return d, nil
+	}
+	r := &Report{}
+	id, err := next(KindVisibleString)
+	if err != nil {
+		return nil, err
+	}
+	r.RptID = id.Str
+	opt, err := next(KindBitString)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Bit(OptSeqNum) {
+		d, err := next(KindUnsigned)
+		if err != nil {
+			return nil, err
+		}
+		r.SeqNum = d.Int
+	}
+	if opt.Bit(OptTimeStamp) {
+		d, err := next(KindBinaryTime)
+		if err != nil {
+			return nil, err
+		}
+		r.TimeOfEntry = d.Time
+	}
+	if opt.Bit(OptDataSet) {
+		d, err := next(KindVisibleString)
+		if err != nil {
+			return nil, err
+		}
+		r.DataSet = d.Str
+	}
+	if opt.Bit(OptBufOvfl) {
+		d, err := next(KindBoolean)
+		if err != nil {
+			return nil, err
+		}
+		r.BufOvfl = d.Bool
+	}
+	if opt.Bit(OptEntryID) {
+		d, err := next(KindOctetString)
+		if err != nil {
+			return nil, err
+		}
+		r.EntryID = d.Bytes
+	}
+	if opt.Bit(OptConfRev) {
+		d, err := next(KindUnsigned)
+		if err != nil {
+			return nil, err
+		}
+		r.ConfRev = d.Int
+	}
+	if opt.Bit(OptSegmentation) {
+		// SubSqNum、MoreSegmentsFollow
+		if _, err := next(KindUnsigned); err != nil {
+			return nil, err
+		}
+		if _, err := next(KindBoolean); err != nil {
+			return nil, err
+		}
+	}
+	inclusion, err := next(KindBitString)
+	if err != nil {
+		return nil, err
+	}
+	for n := 0; n < inclusion.BitLen; n++ {
+		if inclusion.Bit(n) {
+			r.Entries = append(r.Entries, ReportEntry{Index: n})
+		}
+	}
+	if opt.Bit(OptDataRef) {
+		for k := range r.Entries {
+			d, err := next(KindVisibleString)
+			if err != nil {
+				return nil, err
+			}
+			r.Entries[k].Reference = d.Str
+		}
+	}
+	for k := range r.Entries {
+		if r.Entries[k].Value, err = next(0); err != nil {
+			return nil, err
+		}
+	}
+	if opt.Bit(OptReason) {
+		for k := range r.Entries {
+			if r.Entries[k].Reason, err = next(KindBitString); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
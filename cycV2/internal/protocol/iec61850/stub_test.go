@@ -0,0 +1,476 @@
+package iec61850
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 测试用 MMS 服务器桩：单个逻辑设备，按 TypeSpec/Data 树保存模型，
+// 支持 GetNameList、GetVariableAccessAttributes、Read、Write 与一个缓存报告控制块
+
+var (
+	tFloat   = &TypeSpec{Kind: KindFloat, Size: 32}
+	tQuality = &TypeSpec{Kind: KindBitString, Size: -13}
+	tTime    = &TypeSpec{Kind: KindUTCTime}
+	tBool    = &TypeSpec{Kind: KindBoolean}
+	tU32     = &TypeSpec{Kind: KindUnsigned, Size: 32}
+	tVStr    = &TypeSpec{Kind: KindVisibleString, Size: 65}
+	tDbpos   = &TypeSpec{Kind: KindBitString, Size: 2}
+)
+
+func structOf(kv ...interface{}) *TypeSpec {
+	t := &TypeSpec{Kind: KindStructure}
+	for i := 0; i < len(kv); i += 2 {
+		t.Components = append(t.Components, Component{Name: kv[i].(string), Type: kv[i+1].(*TypeSpec)})
+	}
+	return t
+}
+
+func zeroData(t *TypeSpec) Data {
+	switch t.Kind {
+	case KindStructure:
+		d := Data{Kind: KindStructure}
+		for _, c := range t.Components {
+			d.Items = append(d.Items, zeroData(c.Type))
+		}
+		return d
+	case KindBitString:
+		n := t.Size
+		if n < 0 {
+			n = -n
+		}
+		return NewBitString(n)
+	case KindOctetString:
+		return Data{Kind: KindOctetString, Bytes: make([]byte, t.Size)}
+	case KindBinaryTime:
+		return Data{Kind: KindBinaryTime, Time: epoch1984}
+	default:
+		return Data{Kind: t.Kind}
+	}
+}
+
+func encodeTypeSpec(t *TypeSpec) []byte {
+	tag := 0x80 | byte(t.Kind)
+	switch t.Kind {
+	case KindStructure:
+		comps := make([][]byte, len(t.Components))
+		for i, c := range t.Components {
+			comps[i] = ber(0x30, berStr(0x80, c.Name), ber(0xA1, encodeTypeSpec(c.Type)))
+		}
+		return ber(tag|0x20, ber(0xA1, comps...))
+	case KindArray:
+		return ber(tag|0x20, berInt(0x81, int64(t.Elements)), ber(0xA2, encodeTypeSpec(t.Element)))
+	case KindFloat:
+		return ber(tag|0x20, berInt(0x02, int64(t.Size)), berInt(0x02, 8))
+	case KindBoolean, KindUTCTime:
+		return []byte{tag, 0}
+	default:
+		return berInt(tag, int64(t.Size))
+	}
+}
+
+type stubVar struct {
+	spec *TypeSpec
+	data Data
+}
+
+type stubServer struct {
+	t        *testing.T
+	ln       net.Listener
+	password string
+	domain   string
+	dataset  []string // 数据集成员（不含域名）
+
+	mu      sync.Mutex
+	vars    map[string]*stubVar
+	conns   []*mmsConn
+	writes  []string
+	seq     int64
+	entryID uint64
+}
+
+func newStubServer(t *testing.T, password string) *stubServer {
+	mv := structOf("mag", structOf("f", tFloat), "q", tQuality, "t", tTime)
+	rcb := structOf("RptID", tVStr, "RptEna", tBool, "DatSet", tVStr, "ConfRev", tU32,
+		"OptFlds", &TypeSpec{Kind: KindBitString, Size: 10}, "BufTm", tU32, "SqNum", tU32,
+		"TrgOps", &TypeSpec{Kind: KindBitString, Size: 6}, "IntgPd", tU32, "GI", tBool, "PurgeBuf", tBool,
+		"EntryID", &TypeSpec{Kind: KindOctetString, Size: 8}, "TimeOfEntry", &TypeSpec{Kind: KindBinaryTime, Size: 6})
+	s := &stubServer{
+		t:        t,
+		password: password,
+		domain:   "IED1LD0",
+		dataset:  []string{"MMXU1$MX$TotW", "XCBR1$ST$Pos"},
+		vars:     make(map[string]*stubVar),
+	}
+	add := func(name string, spec *TypeSpec) { s.vars[name] = &stubVar{spec: spec, data: zeroData(spec)} }
+	add("LLN0", structOf("BR", structOf("brcb01", rcb)))
+	add("MMXU1", structOf("MX", structOf("TotW", mv, "Hz", mv), "CF", structOf("TotW", structOf("db", tU32))))
+	add("XCBR1", structOf("ST", structOf("Pos", structOf("stVal", tDbpos, "q", tQuality, "t", tTime))))
+	s.set("LLN0$BR$brcb01$RptID", Data{Kind: KindVisibleString, Str: "meas"})
+	s.set("LLN0$BR$brcb01$DatSet", Data{Kind: KindVisibleString, Str: "IED1LD0/LLN0$Meas"})
+	s.set("LLN0$BR$brcb01$ConfRev", Data{Kind: KindUnsigned, Int: 1})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ln = ln
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *stubServer) addr() string { return s.ln.Addr().String() }
+
+func (s *stubServer) close() {
+	s.ln.Close()
+	s.kick()
+}
+
+// kick 断开全部客户端
+func (s *stubServer) kick() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+// resolve 按 MMS 变量名找到类型与数据，调用方持有 mu
+func (s *stubServer) resolve(item string) (*TypeSpec, *Data) {
+	parts := strings.Split(item, "$")
+	v, ok := s.vars[parts[0]]
+	if !ok {
+		return nil, nil
+	}
+	spec, d := v.spec, &v.data
+	for _, name := range parts[1:] {
+		idx := -1
+		for i, c := range spec.Components {
+			if c.Name == name {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			return nil, nil
+		}
+		spec, d = spec.Components[idx].Type, &d.Items[idx]
+	}
+	return spec, d
+}
+
+func (s *stubServer) set(item string, v Data) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, d := s.resolve(item)
+	if d == nil {
+		s.t.Fatalf("stub: no variable %s", item)
+	}
+	*d = v
+}
+
+func (s *stubServer) get(item string) Data {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, d := s.resolve(item)
+	if d == nil {
+		s.t.Fatalf("stub: no variable %s", item)
+	}
+	return *d
+}
+
+// names 全部变量名（各层级），按字母序
+func (s *stubServer) names() []string {
+	var out []string
+	var walk func(prefix string, t *TypeSpec)
+	walk = func(prefix string, t *TypeSpec) {
+		out = append(out, prefix)
+		for _, c := range t.Components {
+			walk(prefix+"$"+c.Name, c.Type)
+		}
+	}
+	for name, v := range s.vars {
+		walk(name, v.spec)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func presAccept(acse []byte) []byte {
+	result := ber(0x30, ber(0x80, []byte{0}), ber(0x81, oidBER))
+	return ber(0x31,
+		ber(0xA0, ber(0x80, []byte{1})),
+		ber(0xA2, ber(0x83, selector), ber(0xA5, result, result), presUserData(ctxACSE, acse)))
+}
+
+func initiateResponse() []byte {
+	return ber(pduInitiateResponse, berInt(0x80, 65000), berInt(0x81, 5), berInt(0x82, 5), berInt(0x83, 10),
+		ber(0xA4, berInt(0x80, 1), ber(0x81, parameterCBB), ber(0x82, servicesSupported)))
+}
+
+func (s *stubServer) serve(conn net.Conn) {
+	defer conn.Close()
+	cr, err := readTPKT(conn)
+	if err != nil || len(cr) < 7 || cr[1] != tpduCR {
+		return
+	}
+	// TPDU 大小取 128，客户端收发都需要分段
+	if writeTPKT(conn, cotpRequest(tpduCC, binary.BigEndian.Uint16(cr[4:]), 1, 7)) != nil {
+		return
+	}
+	cotp := &cotpConn{conn: conn, maxData: 128 - 3}
+	msg, err := cotp.read()
+	if err != nil {
+		return
+	}
+	si, user, err := parseSPDU(msg)
+	if err != nil || si != spduConnect {
+		return
+	}
+	acse, err := parsePresConnect(user)
+	if err != nil {
+		return
+	}
+	req, err := parseACSE(acse)
+	if err != nil || req.tag != 0x60 {
+		return
+	}
+	if _, _, err := parseTLV(req.mms); err != nil || req.mms[0] != pduInitiateRequest {
+		return
+	}
+	result := 0
+	if req.password != s.password {
+		result = 1
+	}
+	aare := ber(0x61, ber(0xA1, ber(0x06, oidMMSContext)),
+		ber(0xA2, berInt(0x02, int64(result))), ber(0xA3, ber(0xA1, berInt(0x02, 0))),
+		acseUserInfo(initiateResponse()))
+	if cotp.write(spdu(spduAccept, sessionParams(true), spduParam(0xC1, presAccept(aare)))) != nil || result != 0 {
+		return
+	}
+	mc := &mmsConn{cotp: cotp}
+	s.mu.Lock()
+	s.conns = append(s.conns, mc)
+	s.mu.Unlock()
+	for {
+		pdu, err := mc.recv()
+		if err != nil {
+			return
+		}
+		if pdu[0] == pduConcludeRequest {
+			mc.send([]byte{pduConcludeResponse, 0})
+			return
+		}
+		s.handle(mc, pdu)
+	}
+}
+
+// varName 解析 listOfVariable 中第一个变量的域名与变量名
+func varName(list []byte) (string, string) {
+	seq, _, _ := parseTLV(list)
+	spec, _, _ := parseTLV(seq.value)
+	name, _, _ := parseTLV(spec.value)
+	return objectNameOf(name)
+}
+
+func objectNameOf(name tlv) (string, string) {
+	if name.tag == 0x80 {
+		return "", string(name.value)
+	}
+	ids, _ := parseAll(name.value)
+	if len(ids) != 2 {
+		return "", ""
+	}
+	return string(ids[0].value), string(ids[1].value)
+}
+
+func (s *stubServer) handle(mc *mmsConn, pdu []byte) {
+	t, _, err := parseTLV(pdu)
+	if err != nil || t.tag != pduConfirmedRequest {
+		return
+	}
+	items, _ := parseAll(t.value)
+	id, svc := items[0].value, items[1]
+	fields, _ := parseAll(svc.value)
+	respond := func(service []byte) { mc.send(ber(pduConfirmedResponse, ber(0x02, id), service)) }
+	serviceError := func() {
+		mc.send(ber(pduConfirmedError, ber(0x80, id), ber(0xA2, ber(0xA0, berInt(0x87, 1)))))
+	}
+
+	switch svc.tag {
+	case svcGetNameList:
+		classT, _ := find(fields, 0xA0)
+		class, _, _ := parseTLV(classT.value)
+		scopeT, _ := find(fields, 0xA1)
+		scope, _, _ := parseTLV(scopeT.value)
+		var all []string
+		switch {
+		case ObjectClass(decodeInt(class.value)) == ClassDomain && scope.tag == 0x80:
+			all = []string{s.domain}
+		case ObjectClass(decodeInt(class.value)) == ClassNamedVariable && string(scope.value) == s.domain:
+			s.mu.Lock()
+			all = s.names()
+			s.mu.Unlock()
+		default:
+			serviceError()
+			return
+		}
+		if after, ok := find(fields, 0x82); ok {
+			i := sort.SearchStrings(all, string(after.value))
+			if i < len(all) && all[i] == string(after.value) {
+				i++
+			}
+			all = all[i:]
+		}
+		// 每批最多8个，验证分批读取
+		more := len(all) > 8
+		if more {
+			all = all[:8]
+		}
+		ids := make([][]byte, len(all))
+		for i, n := range all {
+			ids[i] = berStr(0x1A, n)
+		}
+		respond(ber(svcGetNameList, ber(0xA0, ids...), berBool(0x81, more)))
+
+	case svcGetVariableAccessAttributes:
+		name, _, _ := parseTLV(fields[0].value)
+		domain, item := objectNameOf(name)
+		s.mu.Lock()
+		spec, _ := s.resolve(item)
+		s.mu.Unlock()
+		if domain != s.domain || spec == nil {
+			serviceError()
+			return
+		}
+		respond(ber(svcGetVariableAccessAttributes, berBool(0x80, false), ber(0xA2, encodeTypeSpec(spec))))
+
+	case svcRead:
+		spec, _ := find(fields, 0xA1)
+		list, _, _ := parseTLV(spec.value)
+		domain, item := varName(list.value)
+		s.mu.Lock()
+		_, d := s.resolve(item)
+		var result []byte
+		if domain != s.domain || d == nil {
+			result = berInt(0x80, 10) // object-non-existent
+		} else {
+			result = d.encode()
+		}
+		s.mu.Unlock()
+		respond(ber(svcRead, ber(0xA1, result)))
+
+	case svcWrite:
+		domain, item := varName(fields[0].value)
+		values, _ := parseAll(fields[1].value)
+		v, err := decodeData(values[0])
+		s.mu.Lock()
+		spec, d := s.resolve(item)
+		ok := domain == s.domain && d != nil && err == nil && spec.Kind == v.Kind
+		if ok {
+			*d = v
+			s.writes = append(s.writes, item)
+		}
+		s.mu.Unlock()
+		if !ok {
+			respond(ber(svcWrite, berInt(0x80, 7))) // type-inconsistent
+			return
+		}
+		respond(ber(svcWrite, []byte{0x81, 0}))
+		if item == "LLN0$BR$brcb01$GI" && v.Bool {
+			s.report(mc, s.dataset, TrgGI)
+		}
+
+	default:
+		serviceError()
+	}
+}
+
+// update 修改数据集成员的值，报告已使能且触发条件含数据变化时上送
+func (s *stubServer) update(member string, v Data) {
+	s.set(member, v)
+	rcb := "LLN0$BR$brcb01$"
+	if !s.get(rcb+"RptEna").Bool || !s.get(rcb+"TrgOps").Bit(TrgDataChange) {
+		return
+	}
+	s.mu.Lock()
+	conns := append([]*mmsConn(nil), s.conns...)
+	s.mu.Unlock()
+	for _, mc := range conns {
+		s.report(mc, []string{member}, TrgDataChange)
+	}
+}
+
+// report 按控制块当前的 OptFlds 组织报告
+func (s *stubServer) report(mc *mmsConn, members []string, reason int) {
+	rcb := "LLN0$BR$brcb01$"
+	opt := s.get(rcb + "OptFlds")
+	s.mu.Lock()
+	s.seq++
+	s.entryID++
+	seq, entry := s.seq, s.entryID
+	s.mu.Unlock()
+
+	values := []Data{s.get(rcb + "RptID"), opt}
+	if opt.Bit(OptSeqNum) {
+		values = append(values, Data{Kind: KindUnsigned, Int: seq})
+	}
+	if opt.Bit(OptTimeStamp) {
+		values = append(values, Data{Kind: KindBinaryTime, Time: epoch1984.AddDate(40, 0, 0)})
+	}
+	if opt.Bit(OptDataSet) {
+		values = append(values, s.get(rcb+"DatSet"))
+	}
+	if opt.Bit(OptBufOvfl) {
+		values = append(values, Data{Kind: KindBoolean})
+	}
+	if opt.Bit(OptEntryID) {
+		id := make([]byte, 8)
+		binary.BigEndian.PutUint64(id, entry)
+		values = append(values, Data{Kind: KindOctetString, Bytes: id})
+	}
+	if opt.Bit(OptConfRev) {
+		values = append(values, s.get(rcb+"ConfRev"))
+	}
+	var included []int
+	for _, m := range members {
+		for i, d := range s.dataset {
+			if d == m {
+				included = append(included, i)
+			}
+		}
+	}
+	values = append(values, NewBitString(len(s.dataset), included...))
+	if opt.Bit(OptDataRef) {
+		for _, m := range members {
+			values = append(values, Data{Kind: KindVisibleString, Str: s.domain + "/" + m})
+		}
+	}
+	for _, m := range members {
+		values = append(values, s.get(m))
+	}
+	if opt.Bit(OptReason) {
+		for range members {
+			values = append(values, NewBitString(7, reason))
+		}
+	}
+	results := make([][]byte, len(values))
+	for i, v := range values {
+		results[i] = v.encode()
+	}
+	mc.send(ber(pduUnconfirmed, ber(0xA0, ber(0xA1, berStr(0x80, "RPT")), ber(0xA0, results...))))
+}
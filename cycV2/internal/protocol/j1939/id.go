@@ -0,0 +1,71 @@
+// Package j1939 在 CAN 适配器之上实现 SAE J1939：PGN/SPN 寻址、TP 多包传输(BAM/RTS-CTS)、
+// 地址声明以及请求PGN轮询
+package j1939
+
+import "fmt"
+
+// 常用PGN
+const (
+	PGNRequest      = 0xEA00 // 59904 请求PGN
+	PGNAddressClaim = 0xEE00 // 60928 地址声明
+	PGNTPCM         = 0xEC00 // 60416 TP连接管理
+	PGNTPDT         = 0xEB00 // 60160 TP数据传输
+	PGNAck          = 0xE800 // 59392 确认
+
+	AddrGlobal = 0xFF // 全局地址
+	AddrNull   = 0xFE // 无法声明地址时使用的空地址
+)
+
+// ID 29位J1939标识符各字段
+type ID struct {
+	Priority uint8
+	PGN      uint32
+	SA       uint8 // 源地址
+	DA       uint8 // 目的地址，PDU2格式恒为全局
+}
+
+// pdu1 PF<240 为PDU1格式，PS为目的地址
+func pdu1(pgn uint32) bool {
+	return (pgn>>8)&0xFF < 240
+}
+
+// ParseID 解析29位扩展帧ID
+func ParseID(canID uint32) ID {
+	id := ID{
+		Priority: uint8((canID >> 26) & 0x7),
+		SA:       uint8(canID),
+	}
+	dp := (canID >> 24) & 0x3 // EDP + DP
+	pf := (canID >> 16) & 0xFF
+	ps := (canID >> 8) & 0xFF
+	if pf < 240 {
+		id.PGN = dp<<16 | pf<<8
+		id.DA = uint8(ps)
+	} else {
+		id.PGN = dp<<16 | pf<<8 | ps
+		id.DA = AddrGlobal
+	}
+	return id
+}
+
+// CANID 组装29位扩展帧ID
+func (id ID) CANID() uint32 {
+	pgn := id.PGN & 0x3FFFF
+	if pdu1(pgn) {
+		pgn = pgn&0x3FF00 | uint32(id.DA)
+	}
+	return uint32(id.Priority&0x7)<<26 | pgn<<8 | uint32(id.SA)
+}
+
+func (id ID) String() string {
+	return fmt.Sprintf("pri=%d pgn=%d sa=0x%02X da=0x%02X", id.Priority, id.PGN, id.SA, id.DA)
+}
+
+// pgnBytes PGN的3字节小端表示（请求/TP报文中使用）
+func pgnBytes(pgn uint32) []byte {
+	return []byte{byte(pgn), byte(pgn >> 8), byte(pgn >> 16)}
+}
+
+func pgnFrom(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
@@ -0,0 +1,467 @@
+package j1939
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+	"cycV2/internal/protocol/can"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("j1939", NewJ1939Adapter)
+}
+
+type cacheKey struct {
+	pgn uint32
+	sa  uint8
+}
+
+type waiter struct {
+	pgn uint32
+	sa  int // -1 表示任意源地址
+	ch  chan Message
+}
+
+// J1939Adapter 实现 protocol.ProtocolAdapter 接口，在 CANAdapter 之上按 PGN/源地址缓存报文。
+// Read 返回某PGN最新一包数据（多包已重组），点位通过 dataType "spn" 按起始位/长度解析SPN
+type J1939Adapter struct {
+	can        *can.CANAdapter
+	name       uint64 // 本节点64位NAME，bit63为任意地址能力
+	preferred  uint8
+	timeout    time.Duration
+	claimWait  time.Duration
+	listenOnce sync.Once
+
+	mu        sync.Mutex
+	sa        uint8
+	claimed   bool
+	needClaim bool // 首次连接或断开重连后需要重新声明地址
+	tp        *reassembler
+	cache     map[cacheKey]Message
+	latest    map[uint32]Message // 按PGN的最新一包，不区分源地址
+	nodes     map[uint8]uint64   // 总线上已声明地址的节点
+	waiters   []*waiter
+	subs      protocol.Subscriptions[subKey]
+}
+
+// subKey 订阅键，sa=-1 表示任意源地址
+type subKey struct {
+	pgn uint32
+	sa  int
+}
+
+// NewJ1939Adapter 工厂函数
+// cfg: CAN参数(interface/filters) + sourceAddress, name, timeoutMs, claimWaitMs
+func NewJ1939Adapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewJ1939AdapterWithDialer(cfg, can.DefaultDialer)
+}
+
+// NewJ1939AdapterWithDialer 使用指定CAN拨号函数创建适配器，测试时注入 MemBus
+func NewJ1939AdapterWithDialer(cfg map[string]interface{}, dial can.Dialer) (*J1939Adapter, error) {
+	canAdapter, err := can.NewCANAdapterWithDialer(cfg, dial)
+	if err != nil {
+		return nil, err
+	}
+	sa := uint8(0x80)
+	if v, ok := parseUint(cfg["sourceAddress"]); ok {
+		if v > 253 {
+			return nil, fmt.Errorf("j1939: invalid sourceAddress %d", v)
+		}
+		sa = uint8(v)
+	}
+	name, _ := parseUint(cfg["name"])
+	timeout := 500 * time.Millisecond
+	if v, ok := parseUint(cfg["timeoutMs"]); ok && v > 0 {
+		timeout = time.Duration(v) * time.Millisecond
+	}
+	claimWait := 250 * time.Millisecond
+	if v, ok := parseUint(cfg["claimWaitMs"]); ok {
+		claimWait = time.Duration(v) * time.Millisecond
+	}
+	return &J1939Adapter{
+		can:       canAdapter,
+		name:      name,
+		preferred: sa,
+		sa:        sa,
+		timeout:   timeout,
+		claimWait: claimWait,
+		tp:        newReassembler(),
+		cache:     make(map[cacheKey]Message),
+		latest:    make(map[uint32]Message),
+		nodes:     make(map[uint8]uint64),
+		needClaim: true,
+	}, nil
+}
+
+// Connect 打开CAN并声明地址，声明后等待 claimWait 无冲突才可使用该地址
+func (j *J1939Adapter) Connect() error {
+	if err := j.can.Connect(); err != nil {
+		return err
+	}
+	j.listenOnce.Do(func() {
+		j.can.AddListener(j.handleFrame)
+	})
+	j.mu.Lock()
+	needClaim := j.needClaim
+	j.needClaim = false
+	j.mu.Unlock()
+	if needClaim {
+		if err := j.claim(j.preferred); err != nil {
+			return err
+		}
+		time.Sleep(j.claimWait)
+	}
+	return nil
+}
+
+// Disconnect 断开CAN
+func (j *J1939Adapter) Disconnect() error {
+	j.mu.Lock()
+	j.claimed = false
+	j.needClaim = true
+	j.mu.Unlock()
+	return j.can.Disconnect()
+}
+
+// SourceAddress 当前声明成功的源地址，未声明成功返回 AddrNull
+func (j *J1939Adapter) SourceAddress() uint8 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.claimed {
+		return AddrNull
+	}
+	return j.sa
+}
+
+// Nodes 返回总线上已声明地址的节点 地址->NAME
+func (j *J1939Adapter) Nodes() map[uint8]uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make(map[uint8]uint64, len(j.nodes))
+	for k, v := range j.nodes {
+		out[k] = v
+	}
+	return out
+}
+
+// Latest 返回某PGN最新报文，sa<0 表示任意源地址
+func (j *J1939Adapter) Latest(pgn uint32, sa int) (Message, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if sa < 0 {
+		m, ok := j.latest[pgn]
+		return m, ok
+	}
+	m, ok := j.cache[cacheKey{pgn: pgn, sa: uint8(sa)}]
+	return m, ok
+}
+
+// Request 发送请求PGN并等待应答，da 为目标地址（AddrGlobal 表示全局请求）
+func (j *J1939Adapter) Request(pgn uint32, da uint8) (Message, error) {
+	sa := -1
+	if da != AddrGlobal {
+		sa = int(da)
+	}
+	w := &waiter{pgn: pgn, sa: sa, ch: make(chan Message, 1)}
+	j.mu.Lock()
+	j.waiters = append(j.waiters, w)
+	j.mu.Unlock()
+	defer j.removeWaiter(w)
+
+	if err := j.send(6, PGNRequest, da, pgnBytes(pgn)); err != nil {
+		return Message{}, err
+	}
+	select {
+	case m := <-w.ch:
+		return m, nil
+	case <-time.After(j.timeout):
+		return Message{}, fmt.Errorf("j1939: request pgn %d to 0x%02X timeout", pgn, da)
+	}
+}
+
+// Read 读取某PGN最新数据
+// params: pgn, sa(源地址，可选), requestMs(缓存超过该时间则发请求PGN轮询), maxAgeMs
+func (j *J1939Adapter) Read(params map[string]interface{}) ([]byte, error) {
+	if err := j.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	pgn, ok := parseUint(params["pgn"])
+	if !ok {
+		return nil, errors.New("j1939: missing pgn")
+	}
+	sa := -1
+	if v, ok := parseUint(params["sa"]); ok {
+		sa = int(v)
+	}
+	m, ok := j.Latest(uint32(pgn), sa)
+	if reqMs, _ := parseUint(params["requestMs"]); reqMs > 0 && (!ok || time.Since(m.At) > time.Duration(reqMs)*time.Millisecond) {
+		da := uint8(AddrGlobal)
+		if sa >= 0 {
+			da = uint8(sa)
+		}
+		var err error
+		if m, err = j.Request(uint32(pgn), da); err != nil {
+			return nil, err
+		}
+		ok = true
+	}
+	if !ok {
+		return nil, fmt.Errorf("j1939: no message for pgn %d", pgn)
+	}
+	if maxAge, _ := parseUint(params["maxAgeMs"]); maxAge > 0 && time.Since(m.At) > time.Duration(maxAge)*time.Millisecond {
+		return nil, fmt.Errorf("j1939: pgn %d stale", pgn)
+	}
+	return append([]byte(nil), m.Data...), nil
+}
+
+// Subscribe 实现 protocol.Subscriber，每收到一包（多包重组完成后）匹配的PGN回调一次
+// params: pgn, sa（可选，不填接收任意源地址）
+func (j *J1939Adapter) Subscribe(params map[string]interface{}, handler func(protocol.PointUpdate)) (func(), error) {
+	pgn, ok := parseUint(params["pgn"])
+	if !ok {
+		return nil, errors.New("j1939: missing pgn")
+	}
+	sa := -1
+	if v, ok := parseUint(params["sa"]); ok {
+		sa = int(v)
+	}
+	if err := j.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	return j.subs.Add(subKey{pgn: uint32(pgn), sa: sa}, handler), nil
+}
+
+func (j *J1939Adapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("j1939: BatchRead not supported")
+}
+
+// Write 发送PGN，address 为PGN（可为空，取 params["pgn"]）
+// params: pgn, da(默认全局), priority(默认6)；超过8字节使用BAM广播
+func (j *J1939Adapter) Write(address string, data []byte, params map[string]interface{}) error {
+	if err := j.Connect(); err != nil {
+		return fmt.Errorf("connect failed: %w", err)
+	}
+	pgn, ok := parseUint(params["pgn"])
+	if address != "" {
+		pgn, ok = parseUint(address)
+	}
+	if !ok {
+		return errors.New("j1939: missing pgn")
+	}
+	da := uint64(AddrGlobal)
+	if v, ok := parseUint(params["da"]); ok {
+		da = v
+	}
+	prio := uint64(6)
+	if v, ok := parseUint(params["priority"]); ok {
+		prio = v
+	}
+	if len(data) <= 8 {
+		return j.send(uint8(prio), uint32(pgn), uint8(da), data)
+	}
+	if da != AddrGlobal {
+		return errors.New("j1939: point-to-point multi-packet (RTS/CTS) send not supported")
+	}
+	cm, dts := splitBAM(uint32(pgn), data)
+	if err := j.send(7, PGNTPCM, AddrGlobal, cm); err != nil {
+		return err
+	}
+	for _, dt := range dts {
+		time.Sleep(50 * time.Millisecond) // BAM 包间隔 50~200ms
+		if err := j.send(7, PGNTPDT, AddrGlobal, dt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *J1939Adapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("j1939: WriteModbus not supported")
+}
+
+// send 以当前源地址发送单帧
+func (j *J1939Adapter) send(prio uint8, pgn uint32, da uint8, data []byte) error {
+	j.mu.Lock()
+	sa, claimed := j.sa, j.claimed
+	j.mu.Unlock()
+	if !claimed && pgn != PGNAddressClaim {
+		return errors.New("j1939: source address not claimed")
+	}
+	id := ID{Priority: prio, PGN: pgn, SA: sa, DA: da}
+	return j.can.SendFrame(can.Frame{ID: id.CANID(), Extended: true, Data: data})
+}
+
+// claim 广播地址声明
+func (j *J1939Adapter) claim(sa uint8) error {
+	j.mu.Lock()
+	j.sa = sa
+	j.claimed = sa != AddrNull
+	j.mu.Unlock()
+	nameBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nameBytes, j.name)
+	id := ID{Priority: 6, PGN: PGNAddressClaim, SA: sa, DA: AddrGlobal}
+	return j.can.SendFrame(can.Frame{ID: id.CANID(), Extended: true, Data: nameBytes})
+}
+
+// handleFrame CAN接收回调
+func (j *J1939Adapter) handleFrame(f can.Frame) {
+	if !f.Extended {
+		return
+	}
+	id := ParseID(f.ID)
+	now := time.Now()
+	switch id.PGN {
+	case PGNTPCM:
+		j.mu.Lock()
+		j.tp.expire(now)
+		replies := j.tp.handleCM(id, f.Data, j.sa, now)
+		j.mu.Unlock()
+		j.sendReplies(replies)
+	case PGNTPDT:
+		j.mu.Lock()
+		msg, replies := j.tp.handleDT(id, f.Data, now)
+		j.mu.Unlock()
+		j.sendReplies(replies)
+		if msg != nil {
+			j.store(*msg)
+		}
+	case PGNAddressClaim:
+		j.handleClaim(id, f.Data)
+	case PGNRequest:
+		if len(f.Data) >= 3 && pgnFrom(f.Data) == PGNAddressClaim {
+			j.mu.Lock()
+			mine := id.DA == AddrGlobal || id.DA == j.sa
+			sa := j.sa
+			if !j.claimed {
+				sa = AddrNull
+			}
+			j.mu.Unlock()
+			if mine {
+				if err := j.claim(sa); err != nil {
+					log.Printf("[J1939] 响应地址请求失败: %v", err)
+				}
+			}
+		}
+		j.store(Message{PGN: id.PGN, SA: id.SA, DA: id.DA, Priority: id.Priority, Data: f.Data, At: now})
+	default:
+		j.store(Message{PGN: id.PGN, SA: id.SA, DA: id.DA, Priority: id.Priority, Data: f.Data, At: now})
+	}
+}
+
+// handleClaim 处理其他节点的地址声明，NAME 数值小者优先
+func (j *J1939Adapter) handleClaim(id ID, data []byte) {
+	if len(data) < 8 {
+		return
+	}
+	other := binary.LittleEndian.Uint64(data)
+	j.mu.Lock()
+	if id.SA != AddrNull {
+		j.nodes[id.SA] = other
+	}
+	conflict := j.claimed && id.SA == j.sa && other != j.name
+	mySA := j.sa
+	j.mu.Unlock()
+	if !conflict {
+		return
+	}
+	if j.name < other {
+		// 本节点优先，重申地址
+		if err := j.claim(mySA); err != nil {
+			log.Printf("[J1939] 重申地址失败: %v", err)
+		}
+		return
+	}
+	next := uint8(AddrNull)
+	if j.name>>63 == 1 { // 任意地址能力
+		next = j.freeAddress()
+	}
+	log.Printf("[J1939] 地址0x%02X被NAME %016X 抢占, 改用0x%02X", mySA, other, next)
+	if err := j.claim(next); err != nil {
+		log.Printf("[J1939] 地址声明失败: %v", err)
+	}
+}
+
+// freeAddress 在动态地址区 128~247 中选择未被占用的地址
+func (j *J1939Adapter) freeAddress() uint8 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for a := 128; a <= 247; a++ {
+		if _, used := j.nodes[uint8(a)]; !used && uint8(a) != j.sa {
+			return uint8(a)
+		}
+	}
+	return AddrNull
+}
+
+func (j *J1939Adapter) sendReplies(replies []reply) {
+	for _, r := range replies {
+		if err := j.send(7, PGNTPCM, r.da, r.data); err != nil {
+			log.Printf("[J1939] TP回复失败: %v", err)
+		}
+	}
+}
+
+// store 写入缓存并唤醒等待该PGN的请求
+func (j *J1939Adapter) store(m Message) {
+	j.mu.Lock()
+	j.cache[cacheKey{pgn: m.PGN, sa: m.SA}] = m
+	j.latest[m.PGN] = m
+	var hit []*waiter
+	for _, w := range j.waiters {
+		if w.pgn == m.PGN && (w.sa < 0 || w.sa == int(m.SA)) {
+			hit = append(hit, w)
+		}
+	}
+	j.mu.Unlock()
+	for _, w := range hit {
+		select {
+		case w.ch <- m:
+		default:
+		}
+	}
+	for _, key := range []subKey{{pgn: m.PGN, sa: int(m.SA)}, {pgn: m.PGN, sa: -1}} {
+		j.subs.Publish(key, protocol.PointUpdate{Bytes: append([]byte(nil), m.Data...), Timestamp: m.At})
+	}
+}
+
+func (j *J1939Adapter) removeWaiter(w *waiter) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, o := range j.waiters {
+		if o == w {
+			j.waiters = append(j.waiters[:i], j.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// parseUint 兼容 int/float64 以及 "0x.." 字符串
+func parseUint(raw interface{}) (uint64, bool) {
+	switch v := raw.(type) {
+	case int:
+		return uint64(v), true
+	case int64:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case uint32:
+		return uint64(v), true
+	case uint8:
+		return uint64(v), true
+	case float64:
+		return uint64(v), true
+	case string:
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 0, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
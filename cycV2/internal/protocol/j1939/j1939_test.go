@@ -0,0 +1,167 @@
+package j1939
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol/can"
+)
+
+// 录制的帧序列：源地址0x00的发动机控制器
+func frame(prio uint8, pgn uint32, sa, da uint8, data ...byte) can.Frame {
+	return can.Frame{ID: ID{Priority: prio, PGN: pgn, SA: sa, DA: da}.CANID(), Extended: true, Data: data}
+}
+
+func newTestAdapter(t *testing.T, name uint64) (*J1939Adapter, *can.MemSocket) {
+	t.Helper()
+	bus := can.NewMemBus()
+	peer := bus.Open()
+	t.Cleanup(func() { peer.Close() })
+	a, err := NewJ1939AdapterWithDialer(map[string]interface{}{
+		"interface":     "can0",
+		"sourceAddress": 0x80,
+		"name":          name,
+		"claimWaitMs":   0,
+		"timeoutMs":     300,
+	}, bus.Dialer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Disconnect() })
+	// 丢弃上电地址声明
+	if f, err := peer.Recv(); err != nil || ParseID(f.ID).PGN != PGNAddressClaim {
+		t.Fatalf("expect address claim on connect, got %v %v", f, err)
+	}
+	return a, peer
+}
+
+func recvPGN(t *testing.T, s *can.MemSocket, pgn uint32) (ID, []byte) {
+	t.Helper()
+	for {
+		f, err := s.Recv()
+		if err != nil {
+			t.Fatalf("waiting pgn %d: %v", pgn, err)
+		}
+		if id := ParseID(f.ID); id.PGN == pgn {
+			return id, f.Data
+		}
+	}
+}
+
+func TestIDRoundTrip(t *testing.T) {
+	// EEC1 0CF00400: pri 3, PGN 61444, SA 0
+	id := ParseID(0x0CF00400)
+	if id.Priority != 3 || id.PGN != 61444 || id.SA != 0 || id.DA != AddrGlobal {
+		t.Fatalf("parse EEC1: %v", id)
+	}
+	if id.CANID() != 0x0CF00400 {
+		t.Fatalf("rebuild: %X", id.CANID())
+	}
+	req := ID{Priority: 6, PGN: PGNRequest, SA: 0xF9, DA: 0x00}
+	if got := ParseID(req.CANID()); got != req {
+		t.Fatalf("pdu1 round trip: %v", got)
+	}
+}
+
+func TestBAMReassembly(t *testing.T) {
+	a, peer := newTestAdapter(t, 1)
+	payload := []byte{0x00, 0xFF, 0x64, 0x00, 0x04, 0x01, 0x9D, 0x00, 0x13, 0x02}
+	peer.Send(frame(7, PGNTPCM, 0x00, AddrGlobal, tpBAM, 10, 0, 2, 0xFF, 0xCA, 0xFE, 0x00))
+	peer.Send(frame(7, PGNTPDT, 0x00, AddrGlobal, append([]byte{1}, payload[:7]...)...))
+	peer.Send(frame(7, PGNTPDT, 0x00, AddrGlobal, append(append([]byte{2}, payload[7:]...), 0xFF, 0xFF, 0xFF, 0xFF)...))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, err := a.Read(map[string]interface{}{"pgn": 65226, "sa": 0})
+		if err == nil {
+			if !bytes.Equal(data, payload) {
+				t.Fatalf("DM1 payload % X", data)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRTSCTSReassembly(t *testing.T) {
+	a, peer := newTestAdapter(t, 1)
+	data := make([]byte, 16)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	peer.Send(frame(7, PGNTPCM, 0x03, 0x80, tpRTS, 16, 0, 3, 2, 0x00, 0xFE, 0x00))
+	_, cts := recvPGN(t, peer, PGNTPCM)
+	if cts[0] != tpCTS || cts[1] != 2 || cts[2] != 1 {
+		t.Fatalf("first CTS % X", cts)
+	}
+	peer.Send(frame(7, PGNTPDT, 0x03, 0x80, append([]byte{1}, data[0:7]...)...))
+	peer.Send(frame(7, PGNTPDT, 0x03, 0x80, append([]byte{2}, data[7:14]...)...))
+	_, cts = recvPGN(t, peer, PGNTPCM)
+	if cts[0] != tpCTS || cts[1] != 1 || cts[2] != 3 {
+		t.Fatalf("second CTS % X", cts)
+	}
+	peer.Send(frame(7, PGNTPDT, 0x03, 0x80, 3, data[14], data[15], 0xFF, 0xFF, 0xFF, 0xFF, 0xFF))
+	id, eoma := recvPGN(t, peer, PGNTPCM)
+	if eoma[0] != tpEOMA || id.DA != 0x03 || binary.LittleEndian.Uint16(eoma[1:3]) != 16 {
+		t.Fatalf("EOMA % X to %v", eoma, id)
+	}
+	m, ok := a.Latest(0xFE00, 3)
+	if !ok || !bytes.Equal(m.Data, data) {
+		t.Fatalf("reassembled %v %v", m, ok)
+	}
+}
+
+func TestAddressClaimConflict(t *testing.T) {
+	a, peer := newTestAdapter(t, 1<<63|0x5000)
+	// 另一节点以更小的NAME声明0x80
+	nameBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nameBytes, 0x1000)
+	peer.Send(frame(6, PGNAddressClaim, 0x80, AddrGlobal, nameBytes...))
+	id, _ := recvPGN(t, peer, PGNAddressClaim)
+	if id.SA != 0x81 || a.SourceAddress() != 0x81 {
+		t.Fatalf("expect move to 0x81, claimed %v sa=%X", id, a.SourceAddress())
+	}
+	// 请求地址声明时应答
+	peer.Send(frame(6, PGNRequest, 0x00, AddrGlobal, pgnBytes(PGNAddressClaim)...))
+	if id, _ := recvPGN(t, peer, PGNAddressClaim); id.SA != 0x81 {
+		t.Fatalf("claim response from %X", id.SA)
+	}
+	if a.Nodes()[0x80] != 0x1000 {
+		t.Errorf("node table %v", a.Nodes())
+	}
+}
+
+func TestRequestPolling(t *testing.T) {
+	a, peer := newTestAdapter(t, 1)
+	go func() {
+		for {
+			f, err := peer.Recv()
+			if err != nil {
+				return
+			}
+			if id := ParseID(f.ID); id.PGN == PGNRequest && id.DA == 0x00 && pgnFrom(f.Data) == 65260 {
+				peer.Send(frame(6, 65260, 0x00, AddrGlobal, 'V', 'I', 'N', '1', '2', '3', '4', '*'))
+				return
+			}
+		}
+	}()
+	data, err := a.Read(map[string]interface{}{"pgn": 65260, "sa": 0, "requestMs": 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "VIN1234*" {
+		t.Fatalf("vehicle id %q", data)
+	}
+	// 缓存未过期，不再发请求
+	if _, err := a.Read(map[string]interface{}{"pgn": 65260, "sa": 0, "requestMs": 1000}); err != nil {
+		t.Fatal(err)
+	}
+}
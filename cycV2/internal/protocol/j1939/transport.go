@@ -0,0 +1,184 @@
+package j1939
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// TP.CM 控制字节
+const (
+	tpRTS   = 16
+	tpCTS   = 17
+	tpEOMA  = 19 // End of Message Acknowledge
+	tpBAM   = 32
+	tpAbort = 255
+
+	tpTimeout = 1250 * time.Millisecond // T2/T3，会话无数据即丢弃
+)
+
+// Message 重组完成的J1939报文
+type Message struct {
+	PGN      uint32
+	SA       uint8
+	DA       uint8
+	Priority uint8
+	Data     []byte
+	At       time.Time
+}
+
+type sessionKey struct {
+	sa, da uint8
+}
+
+type session struct {
+	pgn      uint32
+	size     int
+	packets  int
+	bam      bool
+	data     []byte
+	received int
+	nextSeq  int // RTS/CTS 下一个期望序号
+	maxPer   int // RTS中发送方每个CTS可发的最大包数
+	window   int // 本次CTS允许的包数
+	lastSeen time.Time
+}
+
+// reassembler TP多包重组，不负责收发，需要回复CTS/EOMA时通过 reply 返回待发帧
+type reassembler struct {
+	sessions map[sessionKey]*session
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{sessions: make(map[sessionKey]*session)}
+}
+
+// reply 需要发出的TP.CM回复
+type reply struct {
+	da   uint8
+	data []byte
+}
+
+// handleCM 处理 TP.CM，localSA 为本节点地址（RTS 只响应发给自己的）
+func (r *reassembler) handleCM(id ID, data []byte, localSA uint8, now time.Time) []reply {
+	if len(data) < 8 {
+		return nil
+	}
+	key := sessionKey{sa: id.SA, da: id.DA}
+	pgn := pgnFrom(data[5:8])
+	switch data[0] {
+	case tpBAM:
+		size := int(binary.LittleEndian.Uint16(data[1:3]))
+		r.sessions[key] = &session{pgn: pgn, size: size, packets: int(data[3]), bam: true, data: make([]byte, size), lastSeen: now}
+	case tpRTS:
+		if id.DA != localSA {
+			return nil
+		}
+		size := int(binary.LittleEndian.Uint16(data[1:3]))
+		s := &session{pgn: pgn, size: size, packets: int(data[3]), maxPer: int(data[4]), data: make([]byte, size), nextSeq: 1, lastSeen: now}
+		if s.maxPer == 0 || s.maxPer == 0xFF {
+			s.maxPer = s.packets
+		}
+		r.sessions[key] = s
+		return []reply{{da: id.SA, data: s.cts()}}
+	case tpAbort:
+		delete(r.sessions, key)
+	}
+	return nil
+}
+
+// handleDT 处理 TP.DT，返回完成的报文及需要发出的回复
+func (r *reassembler) handleDT(id ID, data []byte, now time.Time) (*Message, []reply) {
+	if len(data) < 2 {
+		return nil, nil
+	}
+	key := sessionKey{sa: id.SA, da: id.DA}
+	s, ok := r.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	seq := int(data[0])
+	if seq < 1 || seq > s.packets {
+		return nil, nil
+	}
+	if !s.bam && seq != s.nextSeq {
+		// 序号错乱，中止会话
+		delete(r.sessions, key)
+		return nil, []reply{{da: id.SA, data: abort(s.pgn)}}
+	}
+	off := (seq - 1) * 7
+	copy(s.data[off:minInt(off+7, s.size)], data[1:])
+	s.received++
+	s.lastSeen = now
+
+	var replies []reply
+	if !s.bam {
+		s.nextSeq = seq + 1
+		s.window--
+	}
+	if s.received >= s.packets {
+		delete(r.sessions, key)
+		if !s.bam {
+			eoma := make([]byte, 8)
+			eoma[0] = tpEOMA
+			binary.LittleEndian.PutUint16(eoma[1:3], uint16(s.size))
+			eoma[3] = byte(s.packets)
+			eoma[4] = 0xFF
+			copy(eoma[5:], pgnBytes(s.pgn))
+			replies = append(replies, reply{da: id.SA, data: eoma})
+		}
+		da := id.DA
+		if s.bam {
+			da = AddrGlobal
+		}
+		return &Message{PGN: s.pgn, SA: id.SA, DA: da, Priority: id.Priority, Data: s.data, At: now}, replies
+	}
+	if !s.bam && s.window == 0 {
+		replies = append(replies, reply{da: id.SA, data: s.cts()})
+	}
+	return nil, replies
+}
+
+// expire 清理超时会话
+func (r *reassembler) expire(now time.Time) {
+	for k, s := range r.sessions {
+		if now.Sub(s.lastSeen) > tpTimeout {
+			delete(r.sessions, k)
+		}
+	}
+}
+
+// cts 生成下一个CTS，并设置本轮窗口
+func (s *session) cts() []byte {
+	n := minInt(s.maxPer, s.packets-s.nextSeq+1)
+	s.window = n
+	out := []byte{tpCTS, byte(n), byte(s.nextSeq), 0xFF, 0xFF, 0, 0, 0}
+	copy(out[5:], pgnBytes(s.pgn))
+	return out
+}
+
+func abort(pgn uint32) []byte {
+	out := []byte{tpAbort, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0}
+	copy(out[5:], pgnBytes(pgn))
+	return out
+}
+
+// splitBAM 将超过8字节的数据拆为 BAM 的 TP.CM 与 TP.DT 数据域
+func splitBAM(pgn uint32, data []byte) (cm []byte, dts [][]byte) {
+	packets := (len(data) + 6) / 7
+	cm = []byte{tpBAM, 0, 0, byte(packets), 0xFF, 0, 0, 0}
+	binary.LittleEndian.PutUint16(cm[1:3], uint16(len(data)))
+	copy(cm[5:], pgnBytes(pgn))
+	for i := 0; i < packets; i++ {
+		dt := []byte{byte(i + 1), 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+		copy(dt[1:], data[i*7:minInt(i*7+7, len(data))])
+		dts = append(dts, dt)
+	}
+	return cm, dts
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,125 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/grid-x/modbus"
+)
+
+// 点位合并读取：同一功能码下地址连续或重叠的点位合并为一次块读取，块长度不超过协议上限。
+// 块读取收到异常应答时（部分从站不支持跨区读取）退回逐点读取；超时等错误计入块内所有点位
+const (
+	maxReadRegisters = 125
+	maxReadBits      = 2000
+)
+
+type readItem struct {
+	idx       int // params 下标
+	addr, qty int
+}
+
+// readBlock 一次块读取，地址区间 [start, end)
+type readBlock struct {
+	fn         string
+	start, end int
+	items      []readItem
+}
+
+// ReadMulti 实现 protocol.MultiReader：点位参数与 Read 相同，相邻点位合并为一次请求
+func (m *ModbusAdapter) ReadMulti(params []map[string]interface{}) ([][]byte, []error) {
+	vals := make([][]byte, len(params))
+	errs := make([]error, len(params))
+	if !m.opened {
+		if err := m.Connect(); err != nil {
+			for i := range errs {
+				errs[i] = fmt.Errorf("connect failed: %w", err)
+			}
+			return vals, errs
+		}
+	}
+	for _, b := range planBlocks(params, errs) {
+		data, err := m.BatchRead(b.fn, uint16(b.start), uint16(b.end-b.start))
+		var mbErr *modbus.Error
+		if err != nil && len(b.items) > 1 && errors.As(err, &mbErr) {
+			for _, it := range b.items {
+				vals[it.idx], errs[it.idx] = m.BatchRead(b.fn, uint16(it.addr), uint16(it.qty))
+			}
+			continue
+		}
+		for _, it := range b.items {
+			if err != nil {
+				errs[it.idx] = err
+				continue
+			}
+			vals[it.idx], errs[it.idx] = sliceBlock(data, b.fn, it.addr-b.start, it.qty)
+		}
+	}
+	return vals, errs
+}
+
+// planBlocks 按功能码分组、按地址排序后合并相邻点位；功能码无效的点位直接记入 errs
+func planBlocks(params []map[string]interface{}, errs []error) []readBlock {
+	byFunc := make(map[string][]readItem)
+	var funcs []string
+	for i, p := range params {
+		fn, _ := p["func"].(string)
+		if fn == "" {
+			fn = "hr"
+		}
+		switch fn {
+		case "hr", "ir", "co", "di":
+		default:
+			errs[i] = fmt.Errorf("unknown func type: %s", fn)
+			continue
+		}
+		if _, ok := byFunc[fn]; !ok {
+			funcs = append(funcs, fn)
+		}
+		byFunc[fn] = append(byFunc[fn], readItem{idx: i, addr: int(parseUint16(p["address"], 0)), qty: int(parseUint16(p["quantity"], 1))})
+	}
+	var blocks []readBlock
+	for _, fn := range funcs {
+		items := byFunc[fn]
+		sort.SliceStable(items, func(a, b int) bool { return items[a].addr < items[b].addr })
+		limit := maxReadRegisters
+		if fn == "co" || fn == "di" {
+			limit = maxReadBits
+		}
+		first := len(blocks)
+		for _, it := range items {
+			end := it.addr + it.qty
+			if n := len(blocks); n > first {
+				cur := &blocks[n-1]
+				if it.addr <= cur.end && max(cur.end, end)-cur.start <= limit {
+					cur.end = max(cur.end, end)
+					cur.items = append(cur.items, it)
+					continue
+				}
+			}
+			blocks = append(blocks, readBlock{fn: fn, start: it.addr, end: end, items: []readItem{it}})
+		}
+	}
+	return blocks
+}
+
+// sliceBlock 从块读取结果中取出偏移 off 起 qty 个寄存器/位，格式与单点读取一致
+func sliceBlock(data []byte, fn string, off, qty int) ([]byte, error) {
+	if fn == "co" || fn == "di" {
+		if len(data)*8 < off+qty {
+			return nil, fmt.Errorf("short response: %d byte(s) for bit %d+%d", len(data), off, qty)
+		}
+		out := make([]byte, (qty+7)/8)
+		for j := 0; j < qty; j++ {
+			if bit := off + j; data[bit/8]>>(bit%8)&1 != 0 {
+				out[j/8] |= 1 << (j % 8)
+			}
+		}
+		return out, nil
+	}
+	if len(data) < 2*(off+qty) {
+		return nil, fmt.Errorf("short response: %d byte(s) for register %d+%d", len(data), off, qty)
+	}
+	return append([]byte(nil), data[2*off:2*(off+qty)]...), nil
+}
@@ -0,0 +1,122 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+// 计数从站：寄存器值等于地址，线圈/离散量地址为3的倍数时置位；跨越地址20的寄存器读取返回非法地址
+func startBlockStub(t *testing.T) (string, *atomic.Int64) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var requests atomic.Int64
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				hdr := make([]byte, 7)
+				for {
+					if _, err := io.ReadFull(conn, hdr); err != nil {
+						return
+					}
+					pdu := make([]byte, int(binary.BigEndian.Uint16(hdr[4:]))-1)
+					if _, err := io.ReadFull(conn, pdu); err != nil {
+						return
+					}
+					requests.Add(1)
+					fc := pdu[0]
+					start, qty := int(binary.BigEndian.Uint16(pdu[1:])), int(binary.BigEndian.Uint16(pdu[3:]))
+					var resp []byte
+					switch {
+					case (fc == 0x03 || fc == 0x04) && start < 20 && start+qty > 20:
+						resp = []byte{fc | 0x80, 0x02}
+					case fc == 0x03 || fc == 0x04:
+						resp = []byte{fc, byte(qty * 2)}
+						for a := start; a < start+qty; a++ {
+							resp = binary.BigEndian.AppendUint16(resp, uint16(a))
+						}
+					default:
+						bits := make([]byte, (qty+7)/8)
+						for j := 0; j < qty; j++ {
+							if (start+j)%3 == 0 {
+								bits[j/8] |= 1 << (j % 8)
+							}
+						}
+						resp = append([]byte{fc, byte(len(bits))}, bits...)
+					}
+					out := make([]byte, 7, 7+len(resp))
+					copy(out, hdr[:4])
+					binary.BigEndian.PutUint16(out[4:], uint16(len(resp)+1))
+					out[6] = hdr[6]
+					conn.Write(append(out, resp...))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), &requests
+}
+
+func TestReadMultiMergesAdjacentPoints(t *testing.T) {
+	addr, requests := startBlockStub(t)
+	a, err := NewModbusAdapter(map[string]interface{}{"mode": "tcp", "address": addr, "slaveId": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Disconnect()
+	m := a.(*ModbusAdapter)
+
+	p := func(fn string, address, qty int) map[string]interface{} {
+		return map[string]interface{}{"func": fn, "address": float64(address), "quantity": float64(qty)}
+	}
+	params := []map[string]interface{}{
+		p("hr", 3, 1), p("hr", 0, 1), p("hr", 1, 2), // hr 0~3 一次
+		p("hr", 10, 1),                // 不相邻，单独一次
+		p("co", 2, 3), p("co", 4, 10), // 重叠，一次
+		p("hr", 19, 1), p("hr", 20, 1), // 块读取异常后逐点读取
+		{"func": "xx"},
+	}
+	// 逐点读取的结果作为对照
+	want := make([][]byte, len(params))
+	for i, p := range params[:len(params)-1] {
+		if want[i], err = m.Read(p); err != nil {
+			t.Fatalf("read %v: %v", p, err)
+		}
+	}
+	requests.Store(0)
+
+	vals, errs := m.ReadMulti(params)
+	for i := range params[:len(params)-1] {
+		if errs[i] != nil || !bytes.Equal(vals[i], want[i]) {
+			t.Errorf("point %d %v: % X, %v, want % X", i, params[i], vals[i], errs[i], want[i])
+		}
+	}
+	if errs[len(params)-1] == nil {
+		t.Error("unknown func accepted")
+	}
+	// hr 0~3、hr 10、co 2~13 各一次；hr 19~20 异常1次 + 逐点2次
+	if n := requests.Load(); n != 6 {
+		t.Errorf("requests = %d, want 6", n)
+	}
+}
+
+func TestPlanBlocksLimit(t *testing.T) {
+	var params []map[string]interface{}
+	for addr := 0; addr < 130; addr += 10 {
+		params = append(params, map[string]interface{}{"address": addr, "quantity": 10})
+	}
+	blocks := planBlocks(params, make([]error, len(params)))
+	if len(blocks) != 2 || blocks[0].end-blocks[0].start != 120 || blocks[1].start != 120 {
+		t.Errorf("blocks = %+v", blocks)
+	}
+}
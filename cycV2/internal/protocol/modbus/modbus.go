@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 
@@ -50,7 +52,7 @@ func NewModbusAdapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, err
 	slave := parseUint8(cfg["slaveId"], 1)
 
 	timeout := time.Second * 2
-	if to, ok := cfg["timeoutMs"].(int); ok && to > 0 {
+	if to, ok := toInt(cfg["timeoutMs"]); ok && to > 0 {
 		timeout = time.Duration(to) * time.Millisecond
 	}
 	fmt.Println("slaveId:", slave)
@@ -68,15 +70,15 @@ func NewModbusAdapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, err
 		}, nil
 	case "rtu":
 		baud := 9600
-		if v, ok := cfg["baudrate"].(int); ok && v > 0 {
+		if v, ok := toInt(cfg["baudrate"]); ok && v > 0 {
 			baud = v
 		}
 		dataBits := 8
-		if v, ok := cfg["databits"].(int); ok && v > 0 {
+		if v, ok := toInt(cfg["databits"]); ok && v > 0 {
 			dataBits = v
 		}
 		stopBits := 1
-		if v, ok := cfg["stopbits"].(int); ok && v > 0 {
+		if v, ok := toInt(cfg["stopbits"]); ok && v > 0 {
 			stopBits = v
 		}
 		parity := "N"
@@ -273,6 +275,21 @@ func (m *ModbusAdapter) setSlaveId(salveId uint8) {
 	}
 }
 
+// SetSlaveID 切换后续请求的从站号（扫描等同一连接轮询多个站号的场景使用）
+func (m *ModbusAdapter) SetSlaveID(slaveId uint8) {
+	m.setSlaveId(slaveId)
+}
+
+// SetTimeout 修改单次请求超时时间
+func (m *ModbusAdapter) SetTimeout(timeout time.Duration) {
+	switch h := m.handler.(type) {
+	case *modbus.TCPClientHandler:
+		h.Timeout = timeout
+	case *modbus.RTUClientHandler:
+		h.Timeout = timeout
+	}
+}
+
 // ------- 参数类型转换工具 --------
 // 兼容前端/配置json传int/float64
 func parseUint8(raw interface{}, def uint8) uint8 {
@@ -289,6 +306,24 @@ func parseUint8(raw interface{}, def uint8) uint8 {
 		return def
 	}
 }
+
+// toInt 兼容 int/float64/字符串配置（JSON 解码的数值为 float64）
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
+
 func parseUint16(raw interface{}, def uint16) uint16 {
 	switch v := raw.(type) {
 	case uint16:
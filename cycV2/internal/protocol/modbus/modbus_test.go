@@ -29,7 +29,7 @@ func TestModbusConnectAndReadHR(t *testing.T) {
 		"address":  0,
 		"quantity": 2,
 	}
-	data, err := adapter.Read("", params)
+	data, err := adapter.Read(params)
 	if err != nil {
 		t.Fatalf("modbus read hr failed: %v", err)
 	}
@@ -78,7 +78,7 @@ func TestModbusConnectAndReadCoil(t *testing.T) {
 		"address":  0,
 		"quantity": 8,
 	}
-	data, err := adapter.Read("", params)
+	data, err := adapter.Read(params)
 	if err != nil {
 		t.Fatalf("modbus read coil failed: %v", err)
 	}
@@ -119,7 +119,7 @@ func TestReadWriteRegisters(t *testing.T) {
 		t.Errorf("Write err: %v", err)
 	}
 	readParams := writeParams
-	readRet, err := m.Read("", readParams)
+	readRet, err := m.Read(readParams)
 	if err != nil {
 		t.Errorf("Read err: %v", err)
 	}
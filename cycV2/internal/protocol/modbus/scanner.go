@@ -0,0 +1,263 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/grid-x/modbus"
+)
+
+// ProbeRange 单个功能码的探测区间
+type ProbeRange struct {
+	Func      string `json:"func"`      // "hr" | "ir" | "co" | "di"
+	StartAddr uint16 `json:"startAddr"` // 起始地址
+	EndAddr   uint16 `json:"endAddr"`   // 结束地址（含）
+	BlockSize uint16 `json:"blockSize"` // 每次读取数量，默认寄存器10个，线圈80个
+	Refine    bool   `json:"refine"`    // 块读取失败时逐个地址细化探测
+}
+
+// ScanConfig 站号/寄存器扫描参数
+type ScanConfig struct {
+	StartID   uint8         `json:"startId"`
+	EndID     uint8         `json:"endId"`
+	Timeout   time.Duration `json:"timeout"` // 单次请求超时，扫描宜短，默认200ms
+	Probes    []ProbeRange  `json:"probes"`
+	IdleDelay time.Duration `json:"idleDelay"` // 两次请求间隔，串口总线防止帧粘连
+}
+
+// ReadableRange 可读的连续地址区间
+type ReadableRange struct {
+	Func      string `json:"func"`
+	StartAddr uint16 `json:"startAddr"`
+	Quantity  uint16 `json:"quantity"`
+}
+
+// SlaveReport 单个站号的扫描结果
+type SlaveReport struct {
+	SlaveID    uint8           `json:"slaveId"`
+	Responsive bool            `json:"responsive"` // 有任何应答（包括异常应答）即认为在线
+	Ranges     []ReadableRange `json:"ranges"`
+	Exceptions map[string]int  `json:"exceptions"` // "hr:02" -> 次数
+}
+
+// ScanReport 整条总线的扫描结果
+type ScanReport struct {
+	Slaves    []SlaveReport `json:"slaves"`
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Responsive 返回在线的站号结果
+func (r *ScanReport) Responsive() []SlaveReport {
+	out := make([]SlaveReport, 0, len(r.Slaves))
+	for _, s := range r.Slaves {
+		if s.Responsive {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// DefaultProbes 现场调试常用的默认探测区间
+func DefaultProbes() []ProbeRange {
+	return []ProbeRange{
+		{Func: "hr", StartAddr: 0, EndAddr: 99, BlockSize: 10},
+		{Func: "ir", StartAddr: 0, EndAddr: 99, BlockSize: 10},
+	}
+}
+
+// probeResult 单次探测的分类
+type probeResult int
+
+const (
+	probeOK        probeResult = iota // 正常应答
+	probeException                    // 异常应答，站号在线
+	probeNoAnswer                     // 超时/链路错误
+)
+
+// Scanner 基于 ModbusAdapter 的站号/寄存器扫描器，同一总线串行探测
+type Scanner struct {
+	adapter *ModbusAdapter
+	cfg     ScanConfig
+}
+
+// NewScanner 使用已创建的 ModbusAdapter 构造扫描器
+func NewScanner(adapter *ModbusAdapter, cfg ScanConfig) (*Scanner, error) {
+	if adapter == nil {
+		return nil, errors.New("scanner: nil adapter")
+	}
+	if cfg.StartID == 0 {
+		cfg.StartID = 1
+	}
+	if cfg.EndID == 0 {
+		cfg.EndID = 247
+	}
+	if cfg.EndID < cfg.StartID {
+		return nil, fmt.Errorf("scanner: invalid slave range %d-%d", cfg.StartID, cfg.EndID)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 200 * time.Millisecond
+	}
+	if len(cfg.Probes) == 0 {
+		cfg.Probes = DefaultProbes()
+	}
+	for i, p := range cfg.Probes {
+		if _, err := funcCodeOf(p.Func); err != nil {
+			return nil, err
+		}
+		if p.EndAddr < p.StartAddr {
+			return nil, fmt.Errorf("scanner: invalid address range %d-%d for %s", p.StartAddr, p.EndAddr, p.Func)
+		}
+		if p.BlockSize == 0 {
+			cfg.Probes[i].BlockSize = defaultBlockSize(p.Func)
+		}
+	}
+	return &Scanner{adapter: adapter, cfg: cfg}, nil
+}
+
+// Scan 依次扫描站号区间，返回所有站号的结果
+func (s *Scanner) Scan() (*ScanReport, error) {
+	if err := s.adapter.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	s.adapter.SetTimeout(s.cfg.Timeout)
+
+	report := &ScanReport{StartedAt: time.Now()}
+	for id := int(s.cfg.StartID); id <= int(s.cfg.EndID); id++ {
+		rep := s.ScanSlave(uint8(id))
+		if rep.Responsive {
+			log.Printf("[扫描] 站号%d在线, 可读区间%d个", id, len(rep.Ranges))
+		}
+		report.Slaves = append(report.Slaves, rep)
+	}
+	report.Duration = time.Since(report.StartedAt)
+	return report, nil
+}
+
+// ScanSlave 探测单个站号。首个探测块无任何应答时直接判定离线，避免整段超时
+func (s *Scanner) ScanSlave(id uint8) SlaveReport {
+	s.adapter.SetSlaveID(id)
+	rep := SlaveReport{SlaveID: id, Exceptions: make(map[string]int)}
+	first := true
+	for _, p := range s.cfg.Probes {
+		var okBlocks []ReadableRange
+		for start := int(p.StartAddr); start <= int(p.EndAddr); start += int(p.BlockSize) {
+			qty := uint16(p.BlockSize)
+			if start+int(qty)-1 > int(p.EndAddr) {
+				qty = uint16(int(p.EndAddr) - start + 1)
+			}
+			res, code := s.probe(p.Func, uint16(start), qty)
+			if first && res == probeNoAnswer {
+				return rep
+			}
+			first = false
+			switch res {
+			case probeOK:
+				rep.Responsive = true
+				okBlocks = append(okBlocks, ReadableRange{Func: p.Func, StartAddr: uint16(start), Quantity: qty})
+			case probeException:
+				rep.Responsive = true
+				rep.Exceptions[fmt.Sprintf("%s:%02X", p.Func, code)]++
+				if p.Refine && qty > 1 {
+					okBlocks = append(okBlocks, s.refine(p.Func, uint16(start), qty, &rep)...)
+				}
+			}
+		}
+		rep.Ranges = append(rep.Ranges, mergeRanges(okBlocks)...)
+	}
+	return rep
+}
+
+// refine 块读取出现异常时逐个地址探测，找出块内真正可读的地址
+func (s *Scanner) refine(fn string, start, qty uint16, rep *SlaveReport) []ReadableRange {
+	var out []ReadableRange
+	for addr := int(start); addr < int(start)+int(qty); addr++ {
+		res, code := s.probe(fn, uint16(addr), 1)
+		switch res {
+		case probeOK:
+			out = append(out, ReadableRange{Func: fn, StartAddr: uint16(addr), Quantity: 1})
+		case probeException:
+			rep.Exceptions[fmt.Sprintf("%s:%02X", fn, code)]++
+		}
+	}
+	return out
+}
+
+// probe 执行一次读取并对结果分类，异常应答时返回异常码
+func (s *Scanner) probe(fn string, addr, qty uint16) (probeResult, byte) {
+	if s.cfg.IdleDelay > 0 {
+		time.Sleep(s.cfg.IdleDelay)
+	}
+	_, err := s.adapter.BatchRead(fn, addr, qty)
+	if err == nil {
+		return probeOK, 0
+	}
+	var mbErr *modbus.Error
+	if errors.As(err, &mbErr) {
+		return probeException, mbErr.ExceptionCode
+	}
+	return probeNoAnswer, 0
+}
+
+// mergeRanges 合并相邻的可读区间
+func mergeRanges(blocks []ReadableRange) []ReadableRange {
+	if len(blocks) == 0 {
+		return nil
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].StartAddr < blocks[j].StartAddr })
+	out := []ReadableRange{blocks[0]}
+	for _, b := range blocks[1:] {
+		last := &out[len(out)-1]
+		if int(last.StartAddr)+int(last.Quantity) == int(b.StartAddr) {
+			last.Quantity += b.Quantity
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func funcCodeOf(fn string) (byte, error) {
+	switch fn {
+	case "co", "01":
+		return modbus.FuncCodeReadCoils, nil
+	case "di", "02":
+		return modbus.FuncCodeReadDiscreteInputs, nil
+	case "hr", "03":
+		return modbus.FuncCodeReadHoldingRegisters, nil
+	case "ir", "04":
+		return modbus.FuncCodeReadInputRegisters, nil
+	default:
+		return 0, fmt.Errorf("scanner: unsupported func %q", fn)
+	}
+}
+
+// FuncName 功能码统一为适配器 Read 使用的名称 hr/ir/co/di（"01"~"04" 转为对应名称），无法识别时原样返回
+func FuncName(fn string) string {
+	code, err := funcCodeOf(fn)
+	if err != nil {
+		return fn
+	}
+	switch code {
+	case modbus.FuncCodeReadCoils:
+		return "co"
+	case modbus.FuncCodeReadDiscreteInputs:
+		return "di"
+	case modbus.FuncCodeReadHoldingRegisters:
+		return "hr"
+	default:
+		return "ir"
+	}
+}
+
+func defaultBlockSize(fn string) uint16 {
+	switch fn {
+	case "co", "01", "di", "02":
+		return 80
+	default:
+		return 10
+	}
+}
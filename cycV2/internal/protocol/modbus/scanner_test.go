@@ -0,0 +1,109 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 简易 Modbus TCP 从站：站号1保持寄存器0~19可读，站号2所有请求返回非法功能，其他站号不应答
+func startScanStub(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveScanStub(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveScanStub(conn net.Conn) {
+	defer conn.Close()
+	hdr := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(conn, hdr); err != nil {
+			return
+		}
+		pdu := make([]byte, int(binary.BigEndian.Uint16(hdr[4:]))-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+		unit, fc := hdr[6], pdu[0]
+		start := binary.BigEndian.Uint16(pdu[1:])
+		qty := binary.BigEndian.Uint16(pdu[3:])
+		var resp []byte
+		switch {
+		case unit == 2:
+			resp = []byte{fc | 0x80, 0x01}
+		case unit == 1 && fc == 0x03 && int(start)+int(qty) <= 20:
+			resp = append([]byte{fc, byte(qty * 2)}, make([]byte, qty*2)...)
+		case unit == 1:
+			resp = []byte{fc | 0x80, 0x02}
+		default:
+			continue
+		}
+		out := make([]byte, 7, 7+len(resp))
+		copy(out, hdr[:4])
+		binary.BigEndian.PutUint16(out[4:], uint16(len(resp)+1))
+		out[6] = unit
+		conn.Write(append(out, resp...))
+	}
+}
+
+func TestScannerFindsSlavesAndRanges(t *testing.T) {
+	addr := startScanStub(t)
+	a, err := NewModbusAdapter(map[string]interface{}{"mode": "tcp", "address": addr, "slaveId": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Disconnect()
+
+	s, err := NewScanner(a.(*ModbusAdapter), ScanConfig{
+		StartID: 1,
+		EndID:   3,
+		Timeout: 100 * time.Millisecond,
+		Probes: []ProbeRange{
+			{Func: "hr", StartAddr: 0, EndAddr: 29, BlockSize: 8, Refine: true},
+			{Func: "ir", StartAddr: 0, EndAddr: 9},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Slaves) != 3 {
+		t.Fatalf("expect 3 slave reports, got %d", len(report.Slaves))
+	}
+
+	s1 := report.Slaves[0]
+	if !s1.Responsive || len(s1.Ranges) != 1 {
+		t.Fatalf("slave 1 unexpected: %+v", s1)
+	}
+	if r := s1.Ranges[0]; r.Func != "hr" || r.StartAddr != 0 || r.Quantity != 20 {
+		t.Errorf("slave 1 range expect hr 0+20, got %+v", r)
+	}
+	if s1.Exceptions["ir:02"] != 1 {
+		t.Errorf("slave 1 expect one ir illegal address exception, got %v", s1.Exceptions)
+	}
+
+	s2 := report.Slaves[1]
+	if !s2.Responsive || len(s2.Ranges) != 0 || s2.Exceptions["hr:01"] == 0 {
+		t.Errorf("slave 2 should answer with exceptions only: %+v", s2)
+	}
+	if report.Slaves[2].Responsive {
+		t.Errorf("slave 3 should be silent")
+	}
+}
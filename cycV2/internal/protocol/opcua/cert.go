@@ -0,0 +1,95 @@
+package opcua
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"time"
+)
+
+// loadCertificate 读取 PEM 或 DER 格式的证书与私钥
+func loadCertificate(certFile, keyFile string) ([]byte, *rsa.PrivateKey, error) {
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opcua: read certificate: %w", err)
+	}
+	if blk, _ := pem.Decode(certData); blk != nil {
+		certData = blk.Bytes
+	}
+	if _, err := x509.ParseCertificate(certData); err != nil {
+		return nil, nil, fmt.Errorf("opcua: parse certificate: %w", err)
+	}
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opcua: read private key: %w", err)
+	}
+	if blk, _ := pem.Decode(keyData); blk != nil {
+		keyData = blk.Bytes
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(keyData); err == nil {
+		return certData, key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(keyData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opcua: parse private key: %w", err)
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.New("opcua: private key is not RSA")
+	}
+	return certData, key, nil
+}
+
+// loadServerCertificate 读取对端证书（PEM 或 DER）
+func loadServerCertificate(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("opcua: read server certificate: %w", err)
+	}
+	if blk, _ := pem.Decode(data); blk != nil {
+		data = blk.Bytes
+	}
+	if _, err := parseCertKey(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// generateCertificate 生成自签名应用实例证书，SAN 中带 ApplicationUri 与主机名
+func generateCertificate(appURI string, hosts ...string) ([]byte, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, nil, err
+	}
+	uri, err := url.Parse(appURI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opcua: invalid application uri %q", appURI)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "cycV2", Organization: []string{"cycV2"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageKeyEncipherment | x509.KeyUsageDataEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		URIs:                  []*url.URL{uri},
+		DNSNames:              hosts,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return der, key, nil
+}
@@ -0,0 +1,877 @@
+package opcua
+
+import (
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("opcua", NewOPCUAAdapter)
+}
+
+// monKey 监视项按节点与采样周期区分，同一节点不同采样周期建立不同监视项
+type monKey struct {
+	node     NodeID
+	sampling time.Duration
+}
+
+type monItem struct {
+	handle uint32
+	id     uint32
+}
+
+// OPCUAAdapter OPC UA 客户端，实现 protocol.ProtocolAdapter 与 protocol.Subscriber。
+// 点位参数 nodeId 指定节点；订阅的点位建立监视项，数据变化经 OnDataChange/Subscribe 推送
+type OPCUAAdapter struct {
+	endpoint         string
+	addr             string
+	policy           string
+	mode             MessageSecurityMode
+	appURI           string
+	certFile         string
+	keyFile          string
+	serverCertFile   string
+	username         string
+	password         string
+	timeout          time.Duration
+	sessionTimeout   time.Duration
+	publishInterval  time.Duration
+	samplingInterval time.Duration
+
+	connMu     sync.Mutex // 串行化建链
+	syncMu     sync.Mutex // 串行化监视项同步
+	mu         sync.Mutex
+	conn       *clientConn
+	sess       *session
+	localCert  []byte
+	localKey   *rsa.PrivateKey
+	serverCert []byte
+	subID      uint32
+	items      map[monKey]*monItem
+	handles    map[uint32]monKey
+	nextHandle uint32
+	acks       []SubscriptionAcknowledgement
+	cache      map[NodeID]DataValue
+	handlers   []func(NodeID, DataValue)
+	subs       protocol.Subscriptions[monKey]
+	stopCh     chan struct{}
+}
+
+// NewOPCUAAdapter 工厂函数
+// cfg: endpoint("opc.tcp://host:4840/path"，也可用address), securityPolicy(None|Basic256Sha256),
+//
+//	securityMode(None|Sign|SignAndEncrypt), certFile, keyFile, applicationUri, serverCertFile,
+//	username, password, timeoutMs, sessionTimeoutMs, publishIntervalMs, samplingIntervalMs
+func NewOPCUAAdapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewOPCUAClient(cfg)
+}
+
+// NewOPCUAClient 创建客户端，返回具体类型以便浏览与注册回调
+func NewOPCUAClient(cfg map[string]interface{}) (*OPCUAAdapter, error) {
+	endpoint, _ := cfg["endpoint"].(string)
+	if endpoint == "" {
+		endpoint, _ = cfg["address"].(string)
+	}
+	if endpoint == "" {
+		return nil, errors.New("opcua: missing endpoint")
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "opc.tcp://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "opc.tcp" || u.Host == "" {
+		return nil, fmt.Errorf("opcua: invalid endpoint %q", endpoint)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4840")
+	}
+	a := &OPCUAAdapter{
+		endpoint:         endpoint,
+		addr:             addr,
+		appURI:           "urn:cycV2:opcua:client",
+		timeout:          5 * time.Second,
+		sessionTimeout:   time.Minute,
+		publishInterval:  time.Second,
+		samplingInterval: time.Second,
+		items:            make(map[monKey]*monItem),
+		handles:          make(map[uint32]monKey),
+		cache:            make(map[NodeID]DataValue),
+	}
+	name, _ := cfg["securityPolicy"].(string)
+	if a.policy, err = policyURI(name); err != nil {
+		return nil, err
+	}
+	mode, _ := cfg["securityMode"].(string)
+	switch strings.ToLower(mode) {
+	case "":
+		a.mode = SecurityModeNone
+		if a.policy != PolicyNone {
+			a.mode = SecurityModeSignAndEncrypt
+		}
+	case "none":
+		a.mode = SecurityModeNone
+	case "sign":
+		a.mode = SecurityModeSign
+	case "signandencrypt":
+		a.mode = SecurityModeSignAndEncrypt
+	default:
+		return nil, fmt.Errorf("opcua: unsupported security mode %q", mode)
+	}
+	if (a.policy == PolicyNone) != (a.mode == SecurityModeNone) {
+		return nil, fmt.Errorf("opcua: security mode %s conflicts with policy %s", a.mode, name)
+	}
+	a.certFile, _ = cfg["certFile"].(string)
+	a.keyFile, _ = cfg["keyFile"].(string)
+	if (a.certFile == "") != (a.keyFile == "") {
+		return nil, errors.New("opcua: certFile and keyFile must be set together")
+	}
+	if v, _ := cfg["applicationUri"].(string); v != "" {
+		a.appURI = v
+	}
+	a.serverCertFile, _ = cfg["serverCertFile"].(string)
+	a.username, _ = cfg["username"].(string)
+	a.password, _ = cfg["password"].(string)
+	if v, ok := toInt(cfg["timeoutMs"]); ok && v > 0 {
+		a.timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(cfg["sessionTimeoutMs"]); ok && v > 0 {
+		a.sessionTimeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(cfg["publishIntervalMs"]); ok && v > 0 {
+		a.publishInterval = time.Duration(v) * time.Millisecond
+	}
+	a.samplingInterval = a.publishInterval
+	if v, ok := toInt(cfg["samplingIntervalMs"]); ok && v >= 0 {
+		a.samplingInterval = time.Duration(v) * time.Millisecond
+	}
+	return a, nil
+}
+
+// OnDataChange 注册监视项数据变化回调
+func (a *OPCUAAdapter) OnDataChange(h func(NodeID, DataValue)) {
+	a.mu.Lock()
+	a.handlers = append(a.handlers, h)
+	a.mu.Unlock()
+}
+
+// Subscribe 实现 protocol.Subscriber，按 params["nodeId"] 建立监视项，
+// params["samplingIntervalMs"] 覆盖默认采样周期
+func (a *OPCUAAdapter) Subscribe(params map[string]interface{}, handler func(protocol.PointUpdate)) (func(), error) {
+	node, err := nodeParam(params)
+	if err != nil {
+		return nil, err
+	}
+	key := monKey{node: node, sampling: a.samplingInterval}
+	if v, ok := toInt(params["samplingIntervalMs"]); ok && v >= 0 {
+		key.sampling = time.Duration(v) * time.Millisecond
+	}
+	cancel := a.subs.Add(key, handler)
+	if a.connected() {
+		if err := a.syncMonitored(); err != nil {
+			log.Printf("[OPCUA] %s 建立监视项失败: %v", a.endpoint, err)
+		}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			if a.connected() {
+				a.syncMonitored()
+			}
+		})
+	}, nil
+}
+
+func (a *OPCUAAdapter) connected() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.conn != nil && a.conn.alive() && a.sess != nil
+}
+
+// Connect 建立安全通道与会话，有订阅时创建订阅与监视项
+func (a *OPCUAAdapter) Connect() error {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+	if a.connected() {
+		return nil
+	}
+	a.mu.Lock()
+	old := a.conn
+	a.conn, a.sess = nil, nil
+	a.mu.Unlock()
+	if old != nil {
+		old.close(errors.New("opcua: reconnect"))
+	}
+
+	opts := channelOptions{endpoint: a.endpoint, addr: a.addr, policy: a.policy, mode: a.mode, timeout: a.timeout}
+	if a.policy != PolicyNone {
+		if err := a.loadCertificates(); err != nil {
+			return err
+		}
+		opts.localCert, opts.localKey, opts.remoteCert = a.localCert, a.localKey, a.serverCert
+	}
+	conn, err := dialChannel(opts)
+	if err != nil {
+		return err
+	}
+	sess, err := conn.createSession(sessionOptions{
+		endpoint:       a.endpoint,
+		appURI:         a.appURI,
+		name:           "cycV2-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		timeout:        a.timeout,
+		username:       a.username,
+		password:       a.password,
+		localCert:      a.localCert,
+		localKey:       a.localKey,
+		sessionTimeout: a.sessionTimeout,
+	})
+	if err != nil {
+		conn.shutdown()
+		return err
+	}
+	stopCh := make(chan struct{})
+	a.mu.Lock()
+	a.conn, a.sess = conn, sess
+	a.subID, a.acks = 0, nil
+	a.items = make(map[monKey]*monItem)
+	a.handles = make(map[uint32]monKey)
+	a.cache = make(map[NodeID]DataValue)
+	if a.stopCh != nil {
+		close(a.stopCh)
+	}
+	a.stopCh = stopCh
+	a.mu.Unlock()
+	log.Printf("[OPCUA] %s 会话已激活（%s/%s）", a.endpoint, policyName(a.policy), a.mode)
+
+	go a.watch(conn, sess, stopCh)
+	if len(a.subs.Keys()) > 0 {
+		if err := a.syncMonitored(); err != nil {
+			log.Printf("[OPCUA] %s 建立监视项失败: %v", a.endpoint, err)
+		}
+	}
+	return nil
+}
+
+// loadCertificates 加载或生成客户端证书；未配置服务器证书时经 GetEndpoints 获取
+func (a *OPCUAAdapter) loadCertificates() error {
+	if a.localKey == nil {
+		var err error
+		if a.certFile != "" {
+			a.localCert, a.localKey, err = loadCertificate(a.certFile, a.keyFile)
+		} else {
+			host, _ := os.Hostname()
+			a.localCert, a.localKey, err = generateCertificate(a.appURI, host)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if a.serverCert != nil {
+		return nil
+	}
+	if a.serverCertFile != "" {
+		cert, err := loadServerCertificate(a.serverCertFile)
+		if err != nil {
+			return err
+		}
+		a.serverCert = cert
+		return nil
+	}
+	eps, err := getEndpoints(a.endpoint, a.addr, a.timeout)
+	if err != nil {
+		return fmt.Errorf("opcua: get endpoints: %w", err)
+	}
+	for _, ep := range eps {
+		if ep.SecurityPolicyURI == a.policy && ep.SecurityMode == a.mode && len(ep.ServerCertificate) > 0 {
+			a.serverCert = ep.ServerCertificate
+			return nil
+		}
+	}
+	return fmt.Errorf("opcua: server has no endpoint for %s/%s", policyName(a.policy), a.mode)
+}
+
+func policyName(uri string) string {
+	return uri[strings.LastIndexByte(uri, '#')+1:]
+}
+
+// watch 无订阅时定期读取服务器时间保持会话；连接断开后有订阅则重连并重建监视项
+func (a *OPCUAAdapter) watch(conn *clientConn, sess *session, stopCh <-chan struct{}) {
+	keepAlive := sess.timeout / 3
+	if keepAlive <= 0 || keepAlive > a.sessionTimeout/3 {
+		keepAlive = a.sessionTimeout / 3
+	}
+	t := time.NewTicker(keepAlive)
+	defer t.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-conn.done:
+			log.Printf("[OPCUA] %s 连接断开: %v", a.endpoint, conn.closeErr())
+			a.reconnect(stopCh)
+			return
+		case <-t.C:
+			a.mu.Lock()
+			idle := a.subID == 0
+			a.mu.Unlock()
+			if idle {
+				// 失败时连接会被关闭，由 conn.done 分支处理
+				a.ReadValues(NewNumericNodeID(0, 2258))
+			}
+		}
+	}
+}
+
+// reconnect 按退避间隔重连，直到成功或被 Disconnect 停止；无订阅时由下次读写触发重连
+func (a *OPCUAAdapter) reconnect(stopCh <-chan struct{}) {
+	delay := time.Second
+	for len(a.subs.Keys()) > 0 {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(delay):
+		}
+		if err := a.Connect(); err == nil {
+			return
+		} else {
+			log.Printf("[OPCUA] %s 重连失败: %v", a.endpoint, err)
+		}
+		delay = min(delay*2, 30*time.Second)
+	}
+}
+
+// Disconnect 关闭会话与安全通道
+func (a *OPCUAAdapter) Disconnect() error {
+	a.mu.Lock()
+	conn := a.conn
+	a.conn, a.sess, a.subID = nil, nil, 0
+	if a.stopCh != nil {
+		close(a.stopCh)
+		a.stopCh = nil
+	}
+	a.mu.Unlock()
+	if conn != nil {
+		conn.closeSession()
+		conn.shutdown()
+	}
+	return nil
+}
+
+// call 在当前会话上调用服务
+func (a *OPCUAAdapter) call(req interface{}, timeout time.Duration) (interface{}, error) {
+	a.mu.Lock()
+	conn := a.conn
+	a.mu.Unlock()
+	if conn == nil {
+		return nil, errChannelClosed
+	}
+	resp, err := conn.call("MSG", req, timeout)
+	if err == StatusBadSessionIDInvalid || err == StatusBadSessionClosed || err == StatusBadSessionNotActivated {
+		// 会话失效，断开后由下次调用重建
+		conn.close(err)
+	}
+	return resp, err
+}
+
+// syncMonitored 按当前订阅表增删监视项，必要时先创建订阅
+func (a *OPCUAAdapter) syncMonitored() error {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	keys := a.subs.Keys()
+	want := make(map[monKey]bool, len(keys))
+	for _, k := range keys {
+		want[k] = true
+	}
+	a.mu.Lock()
+	subID, conn := a.subID, a.conn
+	var create []monKey
+	var remove []uint32
+	for _, k := range keys {
+		if a.items[k] == nil {
+			create = append(create, k)
+		}
+	}
+	for k, it := range a.items {
+		if !want[k] {
+			remove = append(remove, it.id)
+			delete(a.items, k)
+			delete(a.handles, it.handle)
+			delete(a.cache, k.node)
+		}
+	}
+	a.mu.Unlock()
+	if conn == nil {
+		return errChannelClosed
+	}
+	if subID == 0 {
+		if len(create) == 0 {
+			return nil
+		}
+		var err error
+		if subID, err = a.createSubscription(conn); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		if _, err := a.call(&DeleteMonitoredItemsRequest{SubscriptionID: subID, MonitoredItemIDs: remove}, a.timeout); err != nil {
+			log.Printf("[OPCUA] %s 删除监视项失败: %v", a.endpoint, err)
+		}
+	}
+	if len(create) == 0 {
+		return nil
+	}
+	reqs := make([]MonitoredItemCreateRequest, len(create))
+	a.mu.Lock()
+	for i, k := range create {
+		a.nextHandle++
+		reqs[i] = MonitoredItemCreateRequest{
+			ItemToMonitor:  ReadValueID{NodeID: k.node, AttributeID: AttrValue},
+			MonitoringMode: 2,
+			RequestedParameters: MonitoringParameters{
+				ClientHandle:     a.nextHandle,
+				SamplingInterval: float64(k.sampling / time.Millisecond),
+				QueueSize:        1,
+				DiscardOldest:    true,
+			},
+		}
+		a.handles[a.nextHandle] = k
+	}
+	a.mu.Unlock()
+	resp, err := a.call(&CreateMonitoredItemsRequest{SubscriptionID: subID, TimestampsToReturn: 2, ItemsToCreate: reqs}, a.timeout)
+	if err != nil {
+		a.mu.Lock()
+		for _, r := range reqs {
+			delete(a.handles, r.RequestedParameters.ClientHandle)
+		}
+		a.mu.Unlock()
+		return err
+	}
+	results := resp.(*CreateMonitoredItemsResponse).Results
+	for i, r := range reqs {
+		k, handle := create[i], r.RequestedParameters.ClientHandle
+		if i >= len(results) || results[i].StatusCode.IsBad() {
+			status := StatusBadUnexpectedError
+			if i < len(results) {
+				status = results[i].StatusCode
+			}
+			a.mu.Lock()
+			delete(a.handles, handle)
+			a.mu.Unlock()
+			log.Printf("[OPCUA] %s 监视项 %s 创建失败: %s", a.endpoint, k.node, status)
+			a.subs.Publish(k, protocol.PointUpdate{Timestamp: time.Now(), Err: fmt.Errorf("opcua: monitor %s: %w", k.node, status)})
+			continue
+		}
+		a.mu.Lock()
+		a.items[k] = &monItem{handle: handle, id: results[i].MonitoredItemID}
+		a.mu.Unlock()
+	}
+	return nil
+}
+
+// createSubscription 创建订阅并启动发布请求循环
+func (a *OPCUAAdapter) createSubscription(conn *clientConn) (uint32, error) {
+	resp, err := a.call(&CreateSubscriptionRequest{
+		RequestedPublishingInterval: float64(a.publishInterval / time.Millisecond),
+		RequestedLifetimeCount:      60,
+		RequestedMaxKeepAliveCount:  10,
+		PublishingEnabled:           true,
+	}, a.timeout)
+	if err != nil {
+		return 0, fmt.Errorf("opcua: CreateSubscription: %w", err)
+	}
+	r := resp.(*CreateSubscriptionResponse)
+	a.mu.Lock()
+	a.subID = r.SubscriptionID
+	stopCh := a.stopCh
+	a.mu.Unlock()
+	wait := time.Duration(r.RevisedPublishingInterval*float64(max(r.RevisedMaxKeepAliveCount, 1)))*time.Millisecond + a.timeout
+	// 保持两个未完成的发布请求，避免通知在往返期间积压
+	for i := 0; i < 2; i++ {
+		go a.publishLoop(conn, stopCh, wait)
+	}
+	return r.SubscriptionID, nil
+}
+
+// publishLoop 循环发送 PublishRequest，确认已收到的通知
+func (a *OPCUAAdapter) publishLoop(conn *clientConn, stopCh <-chan struct{}, wait time.Duration) {
+	for conn.alive() {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		a.mu.Lock()
+		acks := a.acks
+		a.acks = nil
+		a.mu.Unlock()
+		resp, err := conn.call("MSG", &PublishRequest{SubscriptionAcknowledgements: acks}, wait)
+		if err != nil {
+			a.mu.Lock()
+			a.acks = append(acks, a.acks...)
+			a.mu.Unlock()
+			if err == StatusBadNoSubscription || err == StatusBadTooManyPublishRequests {
+				select {
+				case <-stopCh:
+					return
+				case <-conn.done:
+					return
+				case <-time.After(a.publishInterval):
+				}
+			}
+			continue
+		}
+		a.handlePublish(resp.(*PublishResponse))
+	}
+}
+
+func (a *OPCUAAdapter) handlePublish(r *PublishResponse) {
+	msg := r.NotificationMessage
+	if len(msg.NotificationData) > 0 {
+		a.mu.Lock()
+		a.acks = append(a.acks, SubscriptionAcknowledgement{SubscriptionID: r.SubscriptionID, SequenceNumber: msg.SequenceNumber})
+		a.mu.Unlock()
+	}
+	for _, n := range msg.NotificationData {
+		switch v := n.Value.(type) {
+		case *DataChangeNotification:
+			for _, item := range v.MonitoredItems {
+				a.dataChange(item.ClientHandle, item.Value, msg.PublishTime)
+			}
+		case *StatusChangeNotification:
+			log.Printf("[OPCUA] %s 订阅%d状态变化: %s", a.endpoint, r.SubscriptionID, v.Status)
+			a.mu.Lock()
+			if a.subID == r.SubscriptionID && v.Status.IsBad() {
+				// 订阅已失效，下次同步时重建
+				a.subID = 0
+				a.items = make(map[monKey]*monItem)
+				a.handles = make(map[uint32]monKey)
+			}
+			a.mu.Unlock()
+			go a.syncMonitored()
+		}
+	}
+}
+
+func (a *OPCUAAdapter) dataChange(handle uint32, dv DataValue, publishTime time.Time) {
+	a.mu.Lock()
+	k, ok := a.handles[handle]
+	if ok {
+		a.cache[k.node] = dv
+	}
+	handlers := a.handlers
+	a.mu.Unlock()
+	if !ok {
+		return
+	}
+	for _, h := range handlers {
+		h(k.node, dv)
+	}
+	u := protocol.PointUpdate{Timestamp: dv.SourceTimestamp}
+	if u.Timestamp.IsZero() {
+		u.Timestamp = dv.ServerTimestamp
+	}
+	if u.Timestamp.IsZero() {
+		u.Timestamp = publishTime
+	}
+	if dv.Status.IsBad() {
+		u.Err = fmt.Errorf("opcua: %s: %w", k.node, dv.Status)
+	} else {
+		u.Bytes, u.Err = ValueBytes(dv.Value)
+	}
+	a.subs.Publish(k, u)
+}
+
+// Browse 浏览节点的正向层级引用，自动跟随续传点
+func (a *OPCUAAdapter) Browse(node NodeID) ([]ReferenceDescription, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+	resp, err := a.call(&BrowseRequest{NodesToBrowse: []BrowseDescription{{
+		NodeID:          node,
+		BrowseDirection: BrowseForward,
+		ReferenceTypeID: HierarchicalReference,
+		IncludeSubtypes: true,
+		ResultMask:      0x3F,
+	}}}, a.timeout)
+	if err != nil {
+		return nil, err
+	}
+	results := resp.(*BrowseResponse).Results
+	var refs []ReferenceDescription
+	for len(results) == 1 {
+		res := results[0]
+		if res.StatusCode.IsBad() {
+			return nil, fmt.Errorf("opcua: browse %s: %w", node, res.StatusCode)
+		}
+		refs = append(refs, res.References...)
+		if len(res.ContinuationPoint) == 0 {
+			return refs, nil
+		}
+		resp, err := a.call(&BrowseNextRequest{ContinuationPoints: [][]byte{res.ContinuationPoint}}, a.timeout)
+		if err != nil {
+			return nil, err
+		}
+		results = resp.(*BrowseNextResponse).Results
+	}
+	return nil, fmt.Errorf("opcua: browse %s: unexpected result count %d", node, len(results))
+}
+
+// ReadValues 读取多个节点的 Value 属性
+func (a *OPCUAAdapter) ReadValues(nodes ...NodeID) ([]DataValue, error) {
+	if err := a.Connect(); err != nil {
+		return nil, err
+	}
+	ids := make([]ReadValueID, len(nodes))
+	for i, n := range nodes {
+		ids[i] = ReadValueID{NodeID: n, AttributeID: AttrValue}
+	}
+	resp, err := a.call(&ReadRequest{TimestampsToReturn: 2, NodesToRead: ids}, a.timeout)
+	if err != nil {
+		return nil, err
+	}
+	results := resp.(*ReadResponse).Results
+	if len(results) != len(nodes) {
+		return nil, fmt.Errorf("opcua: read returned %d results for %d nodes", len(results), len(nodes))
+	}
+	return results, nil
+}
+
+// WriteValue 写节点的 Value 属性，v 须为与节点数据类型一致的 Go 值
+func (a *OPCUAAdapter) WriteValue(node NodeID, v interface{}) error {
+	vv, ok := v.(Variant)
+	if !ok {
+		var err error
+		if vv, err = NewVariant(v); err != nil {
+			return err
+		}
+	}
+	if err := a.Connect(); err != nil {
+		return err
+	}
+	resp, err := a.call(&WriteRequest{NodesToWrite: []WriteValue{{NodeID: node, AttributeID: AttrValue, Value: DataValue{Value: vv}}}}, a.timeout)
+	if err != nil {
+		return err
+	}
+	results := resp.(*WriteResponse).Results
+	if len(results) != 1 {
+		return fmt.Errorf("opcua: write returned %d results", len(results))
+	}
+	if results[0].IsBad() {
+		return fmt.Errorf("opcua: write %s: %w", node, results[0])
+	}
+	return nil
+}
+
+// Read 读取 params["nodeId"] 的值；已订阅且收到过通知的节点返回监视项缓存。
+// 返回大端字节，格式见 ValueBytes
+func (a *OPCUAAdapter) Read(params map[string]interface{}) ([]byte, error) {
+	node, err := nodeParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Connect(); err != nil {
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+	a.mu.Lock()
+	dv, ok := a.cache[node]
+	a.mu.Unlock()
+	if !ok {
+		values, err := a.ReadValues(node)
+		if err != nil {
+			return nil, err
+		}
+		dv = values[0]
+	}
+	if dv.Status.IsBad() {
+		return nil, fmt.Errorf("opcua: %s: %w", node, dv.Status)
+	}
+	return ValueBytes(dv.Value)
+}
+
+func (a *OPCUAAdapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("opcua: BatchRead not supported")
+}
+
+// Write 写节点值，address 为 NodeId（为空时取 params["nodeId"]）。
+// 先读取节点当前值确定数据类型，再按 Read 的字节格式转换 data
+func (a *OPCUAAdapter) Write(address string, data []byte, params map[string]interface{}) error {
+	if address == "" {
+		address, _ = params["nodeId"].(string)
+	}
+	node, err := ParseNodeID(address)
+	if err != nil {
+		return err
+	}
+	values, err := a.ReadValues(node)
+	if err != nil {
+		return err
+	}
+	if values[0].Status.IsBad() {
+		return fmt.Errorf("opcua: %s: %w", node, values[0].Status)
+	}
+	v, err := VariantFromBytes(values[0].Value.Type, data)
+	if err != nil {
+		return fmt.Errorf("opcua: %s: %w", node, err)
+	}
+	return a.WriteValue(node, v)
+}
+
+func (a *OPCUAAdapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("opcua: WriteModbus not supported")
+}
+
+func nodeParam(params map[string]interface{}) (NodeID, error) {
+	s, _ := params["nodeId"].(string)
+	if s == "" {
+		return NodeID{}, errors.New("opcua: missing nodeId")
+	}
+	return ParseNodeID(s)
+}
+
+// ValueBytes 变体转为点位原始字节（大端）：Boolean 1字节，整数与浮点按自身宽度，
+// DateTime 为 Unix 毫秒 int64，String/ByteString 为原始字节，LocalizedText 取文本；数值数组依次拼接
+func ValueBytes(v Variant) ([]byte, error) {
+	if v.Type == TypeNull {
+		return nil, errors.New("opcua: null value")
+	}
+	if v.IsArray() {
+		switch v.Type {
+		case TypeString, TypeByteString, TypeXMLElement, TypeLocalizedText:
+			return nil, fmt.Errorf("opcua: unsupported array of type %d", v.Type)
+		}
+		var out []byte
+		arr := Variant{Type: v.Type}
+		for _, x := range sliceValues(v.Value) {
+			arr.Value = x
+			b, err := ValueBytes(arr)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, b...)
+		}
+		return out, nil
+	}
+	switch x := v.Value.(type) {
+	case bool:
+		if x {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case int8:
+		return []byte{byte(x)}, nil
+	case uint8:
+		return []byte{x}, nil
+	case int16:
+		return binary.BigEndian.AppendUint16(nil, uint16(x)), nil
+	case uint16:
+		return binary.BigEndian.AppendUint16(nil, x), nil
+	case int32:
+		return binary.BigEndian.AppendUint32(nil, uint32(x)), nil
+	case uint32:
+		return binary.BigEndian.AppendUint32(nil, x), nil
+	case StatusCode:
+		return binary.BigEndian.AppendUint32(nil, uint32(x)), nil
+	case int64:
+		return binary.BigEndian.AppendUint64(nil, uint64(x)), nil
+	case uint64:
+		return binary.BigEndian.AppendUint64(nil, x), nil
+	case float32:
+		return binary.BigEndian.AppendUint32(nil, math.Float32bits(x)), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(x)), nil
+	case string:
+		return []byte(x), nil
+	case []byte:
+		return append([]byte{}, x...), nil
+	case time.Time:
+		return binary.BigEndian.AppendUint64(nil, uint64(x.UnixMilli())), nil
+	case LocalizedText:
+		return []byte(x.Text), nil
+	}
+	return nil, fmt.Errorf("opcua: unsupported value type %T", v.Value)
+}
+
+// VariantFromBytes 按内置类型把大端字节转为变体，与 ValueBytes 互逆（仅标量）
+func VariantFromBytes(t TypeID, b []byte) (Variant, error) {
+	need := map[TypeID]int{
+		TypeSByte: 1, TypeByte: 1, TypeInt16: 2, TypeUInt16: 2, TypeInt32: 4, TypeUInt32: 4,
+		TypeInt64: 8, TypeUInt64: 8, TypeFloat: 4, TypeDouble: 8, TypeDateTime: 8,
+	}
+	if n, ok := need[t]; ok && len(b) != n {
+		return Variant{}, fmt.Errorf("type %d expects %d bytes, got %d", t, n, len(b))
+	}
+	var v interface{}
+	switch t {
+	case TypeBoolean:
+		on := false
+		for _, c := range b {
+			on = on || c != 0
+		}
+		v = on
+	case TypeSByte:
+		v = int8(b[0])
+	case TypeByte:
+		v = b[0]
+	case TypeInt16:
+		v = int16(binary.BigEndian.Uint16(b))
+	case TypeUInt16:
+		v = binary.BigEndian.Uint16(b)
+	case TypeInt32:
+		v = int32(binary.BigEndian.Uint32(b))
+	case TypeUInt32:
+		v = binary.BigEndian.Uint32(b)
+	case TypeInt64:
+		v = int64(binary.BigEndian.Uint64(b))
+	case TypeUInt64:
+		v = binary.BigEndian.Uint64(b)
+	case TypeFloat:
+		v = math.Float32frombits(binary.BigEndian.Uint32(b))
+	case TypeDouble:
+		v = math.Float64frombits(binary.BigEndian.Uint64(b))
+	case TypeDateTime:
+		v = time.UnixMilli(int64(binary.BigEndian.Uint64(b))).UTC()
+	case TypeString:
+		v = string(b)
+	case TypeByteString:
+		v = append([]byte{}, b...)
+	case TypeLocalizedText:
+		v = LocalizedText{Text: string(b)}
+	default:
+		return Variant{}, fmt.Errorf("unsupported write type %d", t)
+	}
+	return Variant{Type: t, Value: v}, nil
+}
+
+// toInt 兼容 int/float64/字符串配置
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,339 @@
+package opcua
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, s := range []string{"i=85", "ns=2;s=Plant.Temp", "ns=3;i=70000", "ns=1;g=72962B91-FA75-4AE6-8D28-B404DC7DAF63", "ns=4;b=AQID"} {
+		n, err := ParseNodeID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.String() != s {
+			t.Errorf("ParseNodeID(%q).String() = %q", s, n.String())
+		}
+		var back NodeID
+		if err := decode(encode(n), &back); err != nil || back != n {
+			t.Errorf("node id %s round trip = %v, %v", s, back, err)
+		}
+	}
+	// 两字节/四字节编码形式
+	if b := encode(NewNumericNodeID(0, 85)); !bytes.Equal(b, []byte{0x00, 85}) {
+		t.Errorf("two byte node id = % X", b)
+	}
+	if b := encode(NewNumericNodeID(2, 1001)); !bytes.Equal(b, []byte{0x01, 2, 0xE9, 0x03}) {
+		t.Errorf("four byte node id = % X", b)
+	}
+
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123400000, time.UTC)
+	in := ReadResponse{
+		ResponseHeader: ResponseHeader{Timestamp: ts, RequestHandle: 9, StringTable: []string{"a"}},
+		Results: []DataValue{
+			{Value: Variant{TypeDouble, 1.25}, SourceTimestamp: ts},
+			{Value: Variant{TypeInt16, []int16{1, -2}}},
+			{Value: Variant{TypeByteString, []byte{1, 2}}},
+			{Value: Variant{TypeLocalizedText, LocalizedText{Locale: "en", Text: "x"}}},
+			{Status: StatusBadNodeIDUnknown},
+		},
+	}
+	var out ReadResponse
+	if err := decode(encode(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ResponseHeader.Timestamp != ts || out.ResponseHeader.RequestHandle != 9 || len(out.Results) != 5 {
+		t.Fatalf("header = %+v", out.ResponseHeader)
+	}
+	if v := out.Results[1].Value.Value.([]int16); v[1] != -2 || !out.Results[1].Value.IsArray() {
+		t.Errorf("array variant = %v", out.Results[1].Value)
+	}
+	if out.Results[2].Value.IsArray() || out.Results[3].Value.Value.(LocalizedText).Text != "x" || out.Results[4].Status != StatusBadNodeIDUnknown {
+		t.Errorf("results = %+v", out.Results)
+	}
+	if _, err := decodeService([]byte{0x01, 0x00, 0x7A, 0x02, 0x00}); err == nil {
+		t.Error("truncated service decoded")
+	}
+
+	// P_SHA256 派生长度与确定性
+	k1, k2 := deriveKeys([]byte("server"), []byte("client")), deriveKeys([]byte("server"), []byte("client"))
+	if len(k1.sign) != 32 || len(k1.enc) != 32 || len(k1.iv) != 16 || !bytes.Equal(k1.enc, k2.enc) {
+		t.Errorf("derived keys = %+v", k1)
+	}
+	if StatusCode(0x80340000).Error() != "opcua: BadNodeIdUnknown" || !StatusBadTimeout.IsBad() {
+		t.Error("status code names")
+	}
+}
+
+func connectClient(t *testing.T, s *stubServer, extra map[string]interface{}) *OPCUAAdapter {
+	t.Helper()
+	cfg := map[string]interface{}{"endpoint": s.endpoint(), "timeoutMs": 2000, "publishIntervalMs": 20}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	c, err := NewOPCUAClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+func TestBrowseReadWrite(t *testing.T) {
+	s := newStubServer(t)
+	c := connectClient(t, s, nil)
+
+	refs, err := c.Browse(ObjectsFolder)
+	if err != nil || len(refs) != 1 || refs[0].BrowseName.Name != "Plant" {
+		t.Fatalf("browse objects = %+v, %v", refs, err)
+	}
+	refs, err = c.Browse(refs[0].NodeID.NodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range refs {
+		names = append(names, r.BrowseName.String())
+	}
+	// 5 条引用分三页返回
+	if strings.Join(names, ",") != "2:Temp,2:Count,2:Run,2:Name,2:Serial" || refs[4].NodeID.String() != "ns=2;i=1001" {
+		t.Errorf("browse plant = %v", names)
+	}
+	if _, err := c.Browse(NewStringNodeID(2, "Missing")); !errors.Is(err, StatusBadNodeIDUnknown) {
+		t.Errorf("browse missing = %v", err)
+	}
+
+	read := func(node string) []byte {
+		t.Helper()
+		b, err := c.Read(map[string]interface{}{"nodeId": node})
+		if err != nil {
+			t.Fatalf("read %s: %v", node, err)
+		}
+		return b
+	}
+	if b := read("ns=2;s=Plant.Temp"); math.Float64frombits(binary.BigEndian.Uint64(b)) != 21.5 {
+		t.Errorf("temp = % X", b)
+	}
+	if b := read("ns=2;s=Plant.Count"); int32(binary.BigEndian.Uint32(b)) != -7 {
+		t.Errorf("count = % X", b)
+	}
+	if b := read("ns=2;i=1001"); !bytes.Equal(b, []byte{0x12, 0x34}) {
+		t.Errorf("serial = % X", b)
+	}
+	if b := read("ns=2;s=Plant.Name"); string(b) != "line-1" {
+		t.Errorf("name = %q", b)
+	}
+	if _, err := c.Read(map[string]interface{}{"nodeId": "ns=2;s=Missing"}); !errors.Is(err, StatusBadNodeIDUnknown) {
+		t.Errorf("read missing = %v", err)
+	}
+	if _, err := c.Read(map[string]interface{}{}); err == nil {
+		t.Error("read without nodeId succeeded")
+	}
+
+	// Write 按节点当前类型转换字节
+	if err := c.Write("ns=2;s=Plant.Temp", binary.BigEndian.AppendUint64(nil, math.Float64bits(-3.5)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write("", []byte{0}, map[string]interface{}{"nodeId": "ns=2;s=Plant.Run"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write("ns=2;s=Plant.Count", []byte{1}, nil); err == nil {
+		t.Error("short int32 write succeeded")
+	}
+	if err := c.Write("ns=2;i=1001", []byte{0, 1}, nil); !errors.Is(err, StatusBadNotWritable) {
+		t.Errorf("write read-only = %v", err)
+	}
+	if err := c.WriteValue(NewStringNodeID(2, "Plant.Count"), "x"); !errors.Is(err, StatusBadTypeMismatch) {
+		t.Errorf("write mismatched type = %v", err)
+	}
+	if err := c.WriteValue(NewStringNodeID(2, "Plant.Name"), "line-2"); err != nil {
+		t.Fatal(err)
+	}
+	writes, _, _ := s.snapshot()
+	if len(writes) != 3 || writes[0].Value.Value.Value != -3.5 || writes[1].Value.Value.Value != false || writes[2].Value.Value.Value != "line-2" {
+		t.Errorf("server writes = %+v", writes)
+	}
+	if b := read("ns=2;s=Plant.Temp"); math.Float64frombits(binary.BigEndian.Uint64(b)) != -3.5 {
+		t.Errorf("temp after write = % X", b)
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	s := newStubServer(t)
+	c := connectClient(t, s, nil)
+
+	var mu sync.Mutex
+	got := make(map[string][]protocol.PointUpdate)
+	sub := func(node string, params map[string]interface{}) func() {
+		t.Helper()
+		p := map[string]interface{}{"nodeId": node}
+		for k, v := range params {
+			p[k] = v
+		}
+		cancel, err := c.Subscribe(p, func(u protocol.PointUpdate) {
+			mu.Lock()
+			got[node] = append(got[node], u)
+			mu.Unlock()
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cancel
+	}
+	wait := func(node string, n int) []protocol.PointUpdate {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for {
+			mu.Lock()
+			us := append([]protocol.PointUpdate(nil), got[node]...)
+			mu.Unlock()
+			if len(us) >= n {
+				return us
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: got %d updates, want %d", node, len(us), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	sub("ns=2;s=Plant.Temp", map[string]interface{}{"samplingIntervalMs": 100})
+	cancelCount := sub("ns=2;s=Plant.Count", nil)
+	var changes []NodeID
+	c.OnDataChange(func(n NodeID, dv DataValue) {
+		mu.Lock()
+		changes = append(changes, n)
+		mu.Unlock()
+	})
+	// 建立监视项后先收到初始值
+	if us := wait("ns=2;s=Plant.Temp", 1); math.Float64frombits(binary.BigEndian.Uint64(us[0].Bytes)) != 21.5 || us[0].Err != nil {
+		t.Errorf("initial temp = %+v", us[0])
+	}
+	wait("ns=2;s=Plant.Count", 1)
+
+	s.set(NewStringNodeID(2, "Plant.Temp"), Variant{TypeDouble, 30.0})
+	if us := wait("ns=2;s=Plant.Temp", 2); math.Float64frombits(binary.BigEndian.Uint64(us[1].Bytes)) != 30 || us[1].Timestamp.IsZero() {
+		t.Errorf("temp update = %+v", us[1])
+	}
+	// 订阅的节点 Read 返回监视项缓存
+	if b, err := c.Read(map[string]interface{}{"nodeId": "ns=2;s=Plant.Temp"}); err != nil || math.Float64frombits(binary.BigEndian.Uint64(b)) != 30 {
+		t.Errorf("cached read = % X, %v", b, err)
+	}
+
+	cancelCount()
+	cancelCount()
+	s.set(NewStringNodeID(2, "Plant.Count"), Variant{TypeInt32, int32(5)})
+	s.set(NewStringNodeID(2, "Plant.Temp"), Variant{TypeDouble, 31.0})
+	wait("ns=2;s=Plant.Temp", 3)
+	mu.Lock()
+	if n := len(got["ns=2;s=Plant.Count"]); n != 1 {
+		t.Errorf("count updates after cancel = %d", n)
+	}
+	if len(changes) == 0 {
+		t.Error("OnDataChange not called")
+	}
+	mu.Unlock()
+
+	// 不存在的节点：回调收到错误
+	sub("ns=2;s=Missing", nil)
+	if us := wait("ns=2;s=Missing", 1); !errors.Is(us[0].Err, StatusBadNodeIDUnknown) {
+		t.Errorf("missing node update = %+v", us[0])
+	}
+
+	// 连接断开后自动重连并重建监视项
+	s.dropConns()
+	s.set(NewStringNodeID(2, "Plant.Temp"), Variant{TypeDouble, 32.0})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		us := wait("ns=2;s=Plant.Temp", 3)
+		if last := us[len(us)-1]; len(last.Bytes) == 8 && math.Float64frombits(binary.BigEndian.Uint64(last.Bytes)) == 32 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no update after reconnect")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSecurity(t *testing.T) {
+	s := newStubServer(t)
+	for _, mode := range []string{"SignAndEncrypt", "Sign"} {
+		c := connectClient(t, s, map[string]interface{}{"securityPolicy": "Basic256Sha256", "securityMode": mode, "applicationUri": "urn:test:client"})
+		if b, err := c.Read(map[string]interface{}{"nodeId": "ns=2;s=Plant.Name"}); err != nil || string(b) != "line-1" {
+			t.Fatalf("%s read = %q, %v", mode, b, err)
+		}
+		// 大于一个分块的消息
+		long := strings.Repeat("x", 150000)
+		if err := c.WriteValue(NewStringNodeID(2, "Plant.Name"), long); err != nil {
+			t.Fatalf("%s long write: %v", mode, err)
+		}
+		if b, err := c.Read(map[string]interface{}{"nodeId": "ns=2;s=Plant.Name"}); err != nil || string(b) != long {
+			t.Fatalf("%s long read = %d bytes, %v", mode, len(b), err)
+		}
+		s.set(NewStringNodeID(2, "Plant.Name"), Variant{TypeString, "line-1"})
+		// 续期令牌后继续通信
+		if err := c.conn.openChannel(1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ReadValues(NewStringNodeID(2, "Plant.Temp")); err != nil {
+			t.Fatalf("%s read after renew: %v", mode, err)
+		}
+	}
+	_, policies, _ := s.snapshot()
+	// 每个安全连接前先经 None 通道 GetEndpoints 获取服务器证书
+	want := []string{PolicyNone + "/None", PolicyBasic256Sha256 + "/SignAndEncrypt", PolicyNone + "/None", PolicyBasic256Sha256 + "/Sign"}
+	if strings.Join(policies, " ") != strings.Join(want, " ") {
+		t.Errorf("channels = %v", policies)
+	}
+
+	// 用户名密码：令牌策略要求 Basic256Sha256，None 通道上也加密
+	c := connectClient(t, s, map[string]interface{}{"username": "operator", "password": "secret"})
+	_, _, tok := s.snapshot()
+	if u, ok := tok.(*UserNameIdentityToken); !ok || u.UserName != "operator" || bytes.Contains(u.Password, []byte("secret")) {
+		t.Errorf("identity token = %+v", tok)
+	}
+	if _, err := c.ReadValues(ObjectsFolder); err != nil {
+		t.Fatal(err)
+	}
+	bad, _ := NewOPCUAClient(map[string]interface{}{"endpoint": s.endpoint(), "username": "operator", "password": "wrong"})
+	if err := bad.Connect(); !errors.Is(err, StatusBadIdentityTokenRejected) {
+		t.Errorf("wrong password = %v", err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"endpoint": "http://host"},
+		{"endpoint": "opc.tcp://host", "securityPolicy": "Basic128"},
+		{"endpoint": "opc.tcp://host", "securityPolicy": "None", "securityMode": "Sign"},
+		{"endpoint": "opc.tcp://host", "certFile": "a.der"},
+	} {
+		if _, err := NewOPCUAClient(cfg); err == nil {
+			t.Errorf("config %v accepted", cfg)
+		}
+	}
+	a, err := protocol.GetAdapter("opcua", map[string]interface{}{"address": "10.0.0.5", "securityPolicy": "Basic256Sha256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := a.(*OPCUAAdapter)
+	if c.addr != "10.0.0.5:4840" || c.mode != SecurityModeSignAndEncrypt {
+		t.Errorf("adapter = %s %s", c.addr, c.mode)
+	}
+	if _, ok := a.(protocol.Subscriber); !ok {
+		t.Error("adapter is not a Subscriber")
+	}
+}
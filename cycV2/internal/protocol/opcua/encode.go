@@ -0,0 +1,309 @@
+// Package opcua 实现 OPC UA 二进制协议（UA TCP）客户端：内置类型编解码、安全通道
+// （None / Basic256Sha256）、会话、浏览、读写与订阅监视项
+package opcua
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+var errShort = errors.New("opcua: message truncated")
+
+// encoder 二进制编码，小端
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) u8(v byte)     { e.b = append(e.b, v) }
+func (e *encoder) u16(v uint16)  { e.b = binary.LittleEndian.AppendUint16(e.b, v) }
+func (e *encoder) u32(v uint32)  { e.b = binary.LittleEndian.AppendUint32(e.b, v) }
+func (e *encoder) u64(v uint64)  { e.b = binary.LittleEndian.AppendUint64(e.b, v) }
+func (e *encoder) i32(v int32)   { e.u32(uint32(v)) }
+func (e *encoder) f64(v float64) { e.u64(math.Float64bits(v)) }
+
+func (e *encoder) boolean(v bool) {
+	if v {
+		e.u8(1)
+	} else {
+		e.u8(0)
+	}
+}
+
+// str 空串编码为 null（长度-1）
+func (e *encoder) str(s string) {
+	if s == "" {
+		e.i32(-1)
+		return
+	}
+	e.i32(int32(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		e.i32(-1)
+		return
+	}
+	e.i32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// epoch DateTime 为自1601-01-01 UTC起的100ns计数
+var epoch = time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (e *encoder) time(t time.Time) {
+	// time.Duration 只能表示约292年，按秒计算
+	ticks := (t.Unix()-epoch.Unix())*1e7 + int64(t.Nanosecond()/100)
+	if t.IsZero() || ticks < 0 {
+		e.u64(0)
+		return
+	}
+	e.u64(uint64(ticks))
+}
+
+// decoder 二进制解码，错误粘滞，出错后返回零值
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = errShort
+		d.b = nil
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) u8() byte {
+	if b := d.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) u16() uint16 {
+	if b := d.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) u32() uint32 {
+	if b := d.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) u64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) i32() int32    { return int32(d.u32()) }
+func (d *decoder) f64() float64  { return math.Float64frombits(d.u64()) }
+func (d *decoder) boolean() bool { return d.u8() != 0 }
+
+func (d *decoder) bytes() []byte {
+	n := d.i32()
+	if n < 0 {
+		return nil
+	}
+	return append([]byte{}, d.take(int(n))...)
+}
+
+func (d *decoder) str() string { return string(d.bytes()) }
+
+func (d *decoder) time() time.Time {
+	v := d.u64()
+	if v == 0 || d.err != nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(v/1e7)+epoch.Unix(), int64(v%1e7)*100).UTC()
+}
+
+// count 数组长度，超过剩余字节数视为错误，避免异常报文导致大量分配
+func (d *decoder) count() int {
+	n := d.i32()
+	if n < 0 || d.err != nil {
+		return -1
+	}
+	if int(n) > len(d.b) {
+		d.err = fmt.Errorf("opcua: array length %d exceeds message", n)
+		return -1
+	}
+	return int(n)
+}
+
+// 自定义编码的类型（内置类型）：值接收者实现 encodeUA，指针接收者实现 decodeUA
+type (
+	uaEncoder interface{ encodeUA(e *encoder) }
+	uaDecoder interface{ decodeUA(d *decoder) }
+)
+
+var (
+	encoderType = reflect.TypeOf((*uaEncoder)(nil)).Elem()
+	decoderType = reflect.TypeOf((*uaDecoder)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// value 按字段顺序反射编码结构体，切片编码为数组（[]byte 为 ByteString）
+func (e *encoder) value(v reflect.Value) {
+	if v.Kind() == reflect.Ptr {
+		e.value(v.Elem())
+		return
+	}
+	if v.Type().Implements(encoderType) {
+		v.Interface().(uaEncoder).encodeUA(e)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		e.boolean(v.Bool())
+	case reflect.Int8:
+		e.u8(byte(v.Int()))
+	case reflect.Uint8:
+		e.u8(byte(v.Uint()))
+	case reflect.Int16:
+		e.u16(uint16(v.Int()))
+	case reflect.Uint16:
+		e.u16(uint16(v.Uint()))
+	case reflect.Int32:
+		e.u32(uint32(v.Int()))
+	case reflect.Uint32:
+		e.u32(uint32(v.Uint()))
+	case reflect.Int64:
+		e.u64(uint64(v.Int()))
+	case reflect.Uint64:
+		e.u64(v.Uint())
+	case reflect.Float32:
+		e.u32(math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.f64(v.Float())
+	case reflect.String:
+		e.str(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.bytes(v.Bytes())
+			return
+		}
+		if v.IsNil() {
+			e.i32(-1)
+			return
+		}
+		e.i32(int32(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			e.value(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			e.time(v.Interface().(time.Time))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			e.value(v.Field(i))
+		}
+	default:
+		panic(fmt.Sprintf("opcua: cannot encode %s", v.Type()))
+	}
+}
+
+// value 反射解码到可寻址的 v
+func (d *decoder) value(v reflect.Value) {
+	if d.err != nil {
+		return
+	}
+	if v.Addr().Type().Implements(decoderType) {
+		v.Addr().Interface().(uaDecoder).decodeUA(d)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(d.boolean())
+	case reflect.Int8:
+		v.SetInt(int64(int8(d.u8())))
+	case reflect.Uint8:
+		v.SetUint(uint64(d.u8()))
+	case reflect.Int16:
+		v.SetInt(int64(int16(d.u16())))
+	case reflect.Uint16:
+		v.SetUint(uint64(d.u16()))
+	case reflect.Int32:
+		v.SetInt(int64(d.i32()))
+	case reflect.Uint32:
+		v.SetUint(uint64(d.u32()))
+	case reflect.Int64:
+		v.SetInt(int64(d.u64()))
+	case reflect.Uint64:
+		v.SetUint(d.u64())
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(d.u32())))
+	case reflect.Float64:
+		v.SetFloat(d.f64())
+	case reflect.String:
+		v.SetString(d.str())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(d.bytes())
+			return
+		}
+		n := d.count()
+		if n < 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			d.value(s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(d.time()))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			d.value(v.Field(i))
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.value(v.Elem())
+	default:
+		d.err = fmt.Errorf("opcua: cannot decode %s", v.Type())
+	}
+}
+
+// encode 编码任意结构体
+func encode(v interface{}) []byte {
+	e := &encoder{}
+	e.value(reflect.ValueOf(v))
+	return e.b
+}
+
+// decode 解码到指针 v，要求消费全部字节
+func decode(b []byte, v interface{}) error {
+	d := &decoder{b: b}
+	d.value(reflect.ValueOf(v).Elem())
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.b) != 0 {
+		return fmt.Errorf("opcua: %d trailing bytes after %T", len(d.b), v)
+	}
+	return nil
+}
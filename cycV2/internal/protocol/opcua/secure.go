@@ -0,0 +1,676 @@
+package opcua
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// 安全策略
+const (
+	PolicyNone           = "http://opcfoundation.org/UA/SecurityPolicy#None"
+	PolicyBasic256Sha256 = "http://opcfoundation.org/UA/SecurityPolicy#Basic256Sha256"
+
+	algRsaSha256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algRsaOaep   = "http://www.w3.org/2001/04/xmlenc#rsa-oaep"
+
+	transportBinary = "http://opcfoundation.org/UA-Profile/Transport/uatcp-uasc-uabinary"
+)
+
+// Basic256Sha256 密钥长度
+const (
+	signKeyLen  = 32
+	encKeyLen   = 32
+	ivLen       = 16
+	nonceLen    = 32
+	hmacLen     = sha256.Size
+	aesBlockLen = aes.BlockSize
+)
+
+const (
+	bufferSize     = 65535
+	maxMessageSize = 16 << 20
+	maxChunks      = 512
+)
+
+var errChannelClosed = errors.New("opcua: secure channel closed")
+
+// policyURI 配置名转策略URI
+func policyURI(name string) (string, error) {
+	switch name {
+	case "", "None", "none", PolicyNone:
+		return PolicyNone, nil
+	case "Basic256Sha256", "basic256sha256", PolicyBasic256Sha256:
+		return PolicyBasic256Sha256, nil
+	}
+	return "", fmt.Errorf("opcua: unsupported security policy %q", name)
+}
+
+// pSHA256 P_SHA256 密钥派生（RFC 5246 PRF）
+func pSHA256(secret, seed []byte, n int) []byte {
+	out := make([]byte, 0, n+sha256.Size)
+	a := seed
+	for len(out) < n {
+		h := hmac.New(sha256.New, secret)
+		h.Write(a)
+		a = h.Sum(nil)
+		h.Reset()
+		h.Write(a)
+		h.Write(seed)
+		out = h.Sum(out)
+	}
+	return out[:n]
+}
+
+// symKeys 一个方向的对称密钥
+type symKeys struct {
+	sign, enc, iv []byte
+}
+
+func deriveKeys(secret, seed []byte) *symKeys {
+	k := pSHA256(secret, seed, signKeyLen+encKeyLen+ivLen)
+	return &symKeys{sign: k[:signKeyLen], enc: k[signKeyLen : signKeyLen+encKeyLen], iv: k[signKeyLen+encKeyLen:]}
+}
+
+// tokenKeys 一个安全令牌对应的收发密钥
+type tokenKeys struct {
+	local, remote *symKeys
+	expires       time.Time
+}
+
+func thumbprint(cert []byte) []byte {
+	h := sha1.Sum(cert)
+	return h[:]
+}
+
+// rsaSign RSA-PKCS1v15-SHA256
+func rsaSign(key *rsa.PrivateKey, data []byte) ([]byte, error) {
+	h := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+}
+
+func rsaVerify(key *rsa.PublicKey, data, sig []byte) error {
+	h := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig)
+}
+
+// rsaEncrypt RSA-OAEP-SHA1，按块加密
+func rsaEncrypt(key *rsa.PublicKey, data []byte) ([]byte, error) {
+	block := key.Size() - 2*sha1.Size - 2
+	var out []byte
+	for len(data) > 0 {
+		n := min(block, len(data))
+		c, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, key, data[:n], nil)
+		if err != nil {
+			return nil, err
+		}
+		out, data = append(out, c...), data[n:]
+	}
+	return out, nil
+}
+
+func rsaDecrypt(key *rsa.PrivateKey, data []byte) ([]byte, error) {
+	block := key.Size()
+	if len(data)%block != 0 {
+		return nil, errors.New("opcua: invalid asymmetric cipher text length")
+	}
+	var out []byte
+	for ; len(data) > 0; data = data[block:] {
+		p, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, data[:block], nil)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p...)
+	}
+	return out, nil
+}
+
+func parseCertKey(cert []byte) (*rsa.PublicKey, error) {
+	c, err := x509.ParseCertificate(cert)
+	if err != nil {
+		return nil, fmt.Errorf("opcua: invalid certificate: %w", err)
+	}
+	key, ok := c.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("opcua: certificate is not RSA")
+	}
+	return key, nil
+}
+
+// helloMsg HEL/ACK 报文体
+type helloMsg struct {
+	ProtocolVersion   uint32
+	ReceiveBufferSize uint32
+	SendBufferSize    uint32
+	MaxMessageSize    uint32
+	MaxChunkCount     uint32
+}
+
+// message 重组后的一条消息
+type message struct {
+	typ       string // MSG/OPN/CLO
+	requestID uint32
+	body      []byte
+}
+
+// secureChannel UA安全通道（UASC）：分块、签名、加密；client/server 两种角色
+type secureChannel struct {
+	conn   net.Conn
+	server bool
+
+	policy      string
+	mode        MessageSecurityMode
+	localCert   []byte
+	localKey    *rsa.PrivateKey
+	remoteCert  []byte
+	remoteKey   *rsa.PublicKey
+	sendBufSize uint32
+	recvBufSize uint32
+
+	sendMu    sync.Mutex
+	seq       uint32
+	mu        sync.Mutex
+	channelID uint32
+	sendToken uint32
+	tokens    map[uint32]*tokenKeys
+}
+
+func newSecureChannel(conn net.Conn, server bool) *secureChannel {
+	return &secureChannel{
+		conn:        conn,
+		server:      server,
+		policy:      PolicyNone,
+		mode:        SecurityModeNone,
+		sendBufSize: bufferSize,
+		recvBufSize: bufferSize,
+		tokens:      make(map[uint32]*tokenKeys),
+	}
+}
+
+func (c *secureChannel) secure() bool { return c.policy != PolicyNone }
+
+// hello 客户端握手：HEL → ACK/ERR
+func (c *secureChannel) hello(endpoint string, timeout time.Duration) error {
+	e := &encoder{}
+	e.b = encode(helloMsg{ReceiveBufferSize: bufferSize, SendBufferSize: bufferSize, MaxMessageSize: maxMessageSize, MaxChunkCount: maxChunks})
+	e.str(endpoint)
+	if err := c.writeRaw("HEL", e.b); err != nil {
+		return err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	typ, body, err := c.readRaw()
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case "ACK":
+		var ack helloMsg
+		if err := decode(body, &ack); err != nil {
+			return err
+		}
+		c.setBuffers(ack.ReceiveBufferSize, ack.SendBufferSize)
+		return nil
+	case "ERR":
+		return decodeErr(body)
+	}
+	return fmt.Errorf("opcua: unexpected %s during hello", typ)
+}
+
+// acceptHello 服务端握手：读取 HEL，回复 ACK，返回客户端请求的端点URL
+func (c *secureChannel) acceptHello(timeout time.Duration) (string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+	typ, body, err := c.readRaw()
+	if err != nil {
+		return "", err
+	}
+	if typ != "HEL" {
+		c.sendError(StatusBadTcpMessageTypeInvalid, "expected HEL")
+		return "", fmt.Errorf("opcua: expected HEL, got %s", typ)
+	}
+	d := &decoder{b: body}
+	var hel helloMsg
+	d.value(reflect.ValueOf(&hel).Elem())
+	url := d.str()
+	if d.err != nil {
+		return "", d.err
+	}
+	c.setBuffers(hel.ReceiveBufferSize, hel.SendBufferSize)
+	ack := helloMsg{ReceiveBufferSize: c.recvBufSize, SendBufferSize: c.sendBufSize, MaxMessageSize: maxMessageSize, MaxChunkCount: maxChunks}
+	return url, c.writeRaw("ACK", encode(ack))
+}
+
+// setBuffers 对端接收缓冲即本端发送块上限
+func (c *secureChannel) setBuffers(peerRecv, peerSend uint32) {
+	if peerRecv >= 8192 && peerRecv < c.sendBufSize {
+		c.sendBufSize = peerRecv
+	}
+	if peerSend >= 8192 && peerSend < c.recvBufSize {
+		c.recvBufSize = peerSend
+	}
+}
+
+func decodeErr(body []byte) error {
+	d := &decoder{b: body}
+	code := StatusCode(d.u32())
+	reason := d.str()
+	if reason != "" {
+		return fmt.Errorf("opcua: server error %s: %s", code, reason)
+	}
+	return fmt.Errorf("opcua: server error %s", code)
+}
+
+func (c *secureChannel) sendError(code StatusCode, reason string) {
+	e := &encoder{}
+	e.u32(uint32(code))
+	e.str(reason)
+	c.writeRaw("ERR", e.b)
+}
+
+// writeRaw 发送 HEL/ACK/ERR
+func (c *secureChannel) writeRaw(typ string, body []byte) error {
+	b := make([]byte, 8, 8+len(body))
+	copy(b, typ)
+	b[3] = 'F'
+	binary.LittleEndian.PutUint32(b[4:], uint32(8+len(body)))
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	_, err := c.conn.Write(append(b, body...))
+	return err
+}
+
+// readRaw 读取一个完整块，返回类型与块头之后的内容
+func (c *secureChannel) readRaw() (string, []byte, error) {
+	typ, _, chunk, err := c.readChunkRaw()
+	if err != nil {
+		return "", nil, err
+	}
+	return typ, chunk[8:], nil
+}
+
+func (c *secureChannel) readChunkRaw() (string, byte, []byte, error) {
+	hdr := make([]byte, 8)
+	if _, err := io.ReadFull(c.conn, hdr); err != nil {
+		return "", 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[4:])
+	if size < 8 || size > c.recvBufSize+1024 {
+		return "", 0, nil, fmt.Errorf("opcua: invalid chunk size %d", size)
+	}
+	chunk := make([]byte, size)
+	copy(chunk, hdr)
+	if _, err := io.ReadFull(c.conn, chunk[8:]); err != nil {
+		return "", 0, nil, err
+	}
+	return string(hdr[:3]), hdr[3], chunk, nil
+}
+
+// asymHeader 非对称安全头
+func (c *secureChannel) asymHeader() []byte {
+	e := &encoder{}
+	e.str(c.policy)
+	if c.secure() {
+		e.bytes(c.localCert)
+		e.bytes(thumbprint(c.remoteCert))
+	} else {
+		e.bytes(nil)
+		e.bytes(nil)
+	}
+	return e.b
+}
+
+// send 发送一条消息，MSG/CLO 按对端缓冲分块
+func (c *secureChannel) send(typ string, requestID uint32, body []byte) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if typ == "OPN" {
+		return c.writeAsym(requestID, body)
+	}
+	c.mu.Lock()
+	token := c.sendToken
+	keys := c.tokens[token]
+	c.mu.Unlock()
+	max := c.maxBody()
+	for first := true; first || len(body) > 0; first = false {
+		n := min(len(body), max)
+		chunkType := byte('F')
+		if n < len(body) {
+			chunkType = 'C'
+		}
+		if err := c.writeSym(typ, chunkType, token, keys, requestID, body[:n]); err != nil {
+			return err
+		}
+		body = body[n:]
+	}
+	return nil
+}
+
+// maxBody 对称块可容纳的消息体长度
+func (c *secureChannel) maxBody() int {
+	size := int(c.sendBufSize) - 16
+	switch c.mode {
+	case SecurityModeSignAndEncrypt:
+		return size/aesBlockLen*aesBlockLen - 8 - hmacLen - 1
+	case SecurityModeSign:
+		return size - 8 - hmacLen
+	}
+	return size - 8
+}
+
+func (c *secureChannel) nextSeq() uint32 {
+	c.seq++
+	return c.seq
+}
+
+func (c *secureChannel) writeSym(typ string, chunkType byte, token uint32, keys *tokenKeys, requestID uint32, body []byte) error {
+	hdr := make([]byte, 16)
+	copy(hdr, typ)
+	hdr[3] = chunkType
+	c.mu.Lock()
+	binary.LittleEndian.PutUint32(hdr[8:], c.channelID)
+	c.mu.Unlock()
+	binary.LittleEndian.PutUint32(hdr[12:], token)
+	data := binary.LittleEndian.AppendUint32(nil, c.nextSeq())
+	data = binary.LittleEndian.AppendUint32(data, requestID)
+	data = append(data, body...)
+	if !c.secure() || c.mode == SecurityModeNone {
+		binary.LittleEndian.PutUint32(hdr[4:], uint32(16+len(data)))
+		_, err := c.conn.Write(append(hdr, data...))
+		return err
+	}
+	if keys == nil {
+		return errors.New("opcua: no security token")
+	}
+	encrypt := c.mode == SecurityModeSignAndEncrypt
+	if encrypt {
+		pad := (aesBlockLen - (len(data)+1+hmacLen)%aesBlockLen) % aesBlockLen
+		data = append(data, bytes.Repeat([]byte{byte(pad)}, pad+1)...)
+	}
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(16+len(data)+hmacLen))
+	mac := hmac.New(sha256.New, keys.local.sign)
+	mac.Write(hdr)
+	mac.Write(data)
+	data = mac.Sum(data)
+	if encrypt {
+		block, err := aes.NewCipher(keys.local.enc)
+		if err != nil {
+			return err
+		}
+		cipher.NewCBCEncrypter(block, keys.local.iv).CryptBlocks(data, data)
+	}
+	_, err := c.conn.Write(append(hdr, data...))
+	return err
+}
+
+// writeAsym 发送 OPN：安全策略非 None 时总是签名并加密
+func (c *secureChannel) writeAsym(requestID uint32, body []byte) error {
+	hdr := make([]byte, 12)
+	copy(hdr, "OPNF")
+	c.mu.Lock()
+	binary.LittleEndian.PutUint32(hdr[8:], c.channelID)
+	c.mu.Unlock()
+	hdr = append(hdr, c.asymHeader()...)
+	data := binary.LittleEndian.AppendUint32(nil, c.nextSeq())
+	data = binary.LittleEndian.AppendUint32(data, requestID)
+	data = append(data, body...)
+	if !c.secure() {
+		binary.LittleEndian.PutUint32(hdr[4:], uint32(len(hdr)+len(data)))
+		_, err := c.conn.Write(append(hdr, data...))
+		return err
+	}
+	plainBlock := c.remoteKey.Size() - 2*sha1.Size - 2
+	cipherBlock := c.remoteKey.Size()
+	sigLen := c.localKey.Size()
+	extra := 0
+	if c.remoteKey.Size() > 256 {
+		extra = 1 // 密钥超过2048位时填充长度占两字节
+	}
+	pad := (plainBlock - (len(data)+1+extra+sigLen)%plainBlock) % plainBlock
+	data = append(data, bytes.Repeat([]byte{byte(pad)}, pad+1)...)
+	if extra == 1 {
+		data = append(data, byte(pad>>8))
+	}
+	size := len(hdr) + (len(data)+sigLen)/plainBlock*cipherBlock
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(size))
+	sig, err := rsaSign(c.localKey, append(append([]byte(nil), hdr...), data...))
+	if err != nil {
+		return err
+	}
+	enc, err := rsaEncrypt(c.remoteKey, append(data, sig...))
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(append(hdr, enc...))
+	return err
+}
+
+// receive 读取并重组一条消息；ERR 报文转换为错误
+func (c *secureChannel) receive() (*message, error) {
+	var msg *message
+	for chunks := 0; ; chunks++ {
+		if chunks > maxChunks {
+			return nil, errors.New("opcua: too many chunks")
+		}
+		typ, chunkType, chunk, err := c.readChunkRaw()
+		if err != nil {
+			return nil, err
+		}
+		if typ == "ERR" {
+			return nil, decodeErr(chunk[8:])
+		}
+		var requestID uint32
+		var body []byte
+		switch typ {
+		case "OPN":
+			requestID, body, err = c.openAsym(chunk)
+		case "MSG", "CLO":
+			requestID, body, err = c.openSym(chunk)
+		default:
+			err = fmt.Errorf("opcua: unexpected message type %s", typ)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunkType == 'A' {
+			msg = nil
+			continue
+		}
+		if msg == nil {
+			msg = &message{typ: typ, requestID: requestID}
+		} else if msg.requestID != requestID || msg.typ != typ {
+			return nil, errors.New("opcua: interleaved chunks")
+		}
+		msg.body = append(msg.body, body...)
+		if len(msg.body) > maxMessageSize {
+			return nil, errors.New("opcua: message too large")
+		}
+		if chunkType == 'F' {
+			return msg, nil
+		}
+	}
+}
+
+// openAsym 解析 OPN 块。服务端在此记录客户端选择的策略与证书
+func (c *secureChannel) openAsym(chunk []byte) (uint32, []byte, error) {
+	d := &decoder{b: chunk[12:]}
+	policy := d.str()
+	senderCert := d.bytes()
+	receiverThumb := d.bytes()
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+	hdrLen := len(chunk) - len(d.b)
+	if c.server {
+		if policy != PolicyNone && policy != PolicyBasic256Sha256 {
+			return 0, nil, StatusBadSecurityPolicyRejected
+		}
+		c.policy = policy
+	} else if policy != c.policy {
+		return 0, nil, fmt.Errorf("opcua: server answered with policy %s", policy)
+	}
+	c.mu.Lock()
+	if id := binary.LittleEndian.Uint32(chunk[8:]); c.server && c.channelID != 0 && id != c.channelID {
+		c.mu.Unlock()
+		return 0, nil, StatusBadSecureChannelIDInvalid
+	}
+	c.mu.Unlock()
+	data := chunk[hdrLen:]
+	if policy != PolicyNone {
+		if c.localKey == nil {
+			return 0, nil, StatusBadSecurityChecksFailed
+		}
+		if !bytes.Equal(receiverThumb, thumbprint(c.localCert)) {
+			return 0, nil, StatusBadCertificateInvalid
+		}
+		if c.server || c.remoteKey == nil {
+			key, err := parseCertKey(senderCert)
+			if err != nil {
+				return 0, nil, err
+			}
+			c.remoteCert, c.remoteKey = senderCert, key
+		} else if !bytes.Equal(senderCert, c.remoteCert) {
+			return 0, nil, StatusBadCertificateInvalid
+		}
+		plain, err := rsaDecrypt(c.localKey, data)
+		if err != nil {
+			return 0, nil, StatusBadSecurityChecksFailed
+		}
+		sigLen := c.remoteKey.Size()
+		if len(plain) < sigLen+9 {
+			return 0, nil, StatusBadSecurityChecksFailed
+		}
+		signed := append(append([]byte(nil), chunk[:hdrLen]...), plain[:len(plain)-sigLen]...)
+		if err := rsaVerify(c.remoteKey, signed, plain[len(plain)-sigLen:]); err != nil {
+			return 0, nil, StatusBadSecurityChecksFailed
+		}
+		plain = plain[:len(plain)-sigLen]
+		pad := int(plain[len(plain)-1]) + 1
+		if c.localKey.Size() > 256 {
+			pad = (int(plain[len(plain)-1])<<8 | int(plain[len(plain)-2])) + 2
+		}
+		if pad > len(plain)-8 {
+			return 0, nil, StatusBadSecurityChecksFailed
+		}
+		data = plain[:len(plain)-pad]
+	}
+	if len(data) < 8 {
+		return 0, nil, errShort
+	}
+	return binary.LittleEndian.Uint32(data[4:]), data[8:], nil
+}
+
+// openSym 校验/解密 MSG、CLO 块
+func (c *secureChannel) openSym(chunk []byte) (uint32, []byte, error) {
+	if len(chunk) < 24 {
+		return 0, nil, errShort
+	}
+	token := binary.LittleEndian.Uint32(chunk[12:])
+	c.mu.Lock()
+	if id := binary.LittleEndian.Uint32(chunk[8:]); id != c.channelID {
+		c.mu.Unlock()
+		return 0, nil, StatusBadSecureChannelIDInvalid
+	}
+	keys, ok := c.tokens[token]
+	if c.server && ok && token != c.sendToken && token > c.sendToken {
+		// 客户端已启用续期后的令牌，服务端随之切换
+		c.sendToken = token
+	}
+	c.mu.Unlock()
+	data := chunk[16:]
+	if c.secure() && c.mode != SecurityModeNone {
+		if !ok {
+			return 0, nil, StatusBadSecureChannelTokenUnknown
+		}
+		if c.mode == SecurityModeSignAndEncrypt {
+			if len(data)%aesBlockLen != 0 {
+				return 0, nil, StatusBadSecurityChecksFailed
+			}
+			block, err := aes.NewCipher(keys.remote.enc)
+			if err != nil {
+				return 0, nil, err
+			}
+			plain := make([]byte, len(data))
+			cipher.NewCBCDecrypter(block, keys.remote.iv).CryptBlocks(plain, data)
+			data = plain
+		}
+		if len(data) < 8+hmacLen {
+			return 0, nil, StatusBadSecurityChecksFailed
+		}
+		mac := hmac.New(sha256.New, keys.remote.sign)
+		mac.Write(chunk[:16])
+		mac.Write(data[:len(data)-hmacLen])
+		if subtle.ConstantTimeCompare(mac.Sum(nil), data[len(data)-hmacLen:]) != 1 {
+			return 0, nil, StatusBadSecurityChecksFailed
+		}
+		data = data[:len(data)-hmacLen]
+		if c.mode == SecurityModeSignAndEncrypt {
+			pad := int(data[len(data)-1]) + 1
+			if pad > len(data)-8 {
+				return 0, nil, StatusBadSecurityChecksFailed
+			}
+			data = data[:len(data)-pad]
+		}
+	}
+	if len(data) < 8 {
+		return 0, nil, errShort
+	}
+	return binary.LittleEndian.Uint32(data[4:]), data[8:], nil
+}
+
+// newNonce 安全策略为 None 时不需要随机数
+func (c *secureChannel) newNonce() []byte {
+	if !c.secure() {
+		return nil
+	}
+	b := make([]byte, nonceLen)
+	rand.Read(b)
+	return b
+}
+
+// installToken 登记新令牌并派生密钥。客户端收到响应后立即切换发送令牌
+func (c *secureChannel) installToken(tok ChannelSecurityToken, clientNonce, serverNonce []byte) error {
+	k := &tokenKeys{expires: time.Now().Add(time.Duration(tok.RevisedLifetime) * time.Millisecond * 5 / 4)}
+	if c.secure() {
+		if len(clientNonce) != nonceLen || len(serverNonce) != nonceLen {
+			return StatusBadSecurityChecksFailed
+		}
+		clientKeys, serverKeys := deriveKeys(serverNonce, clientNonce), deriveKeys(clientNonce, serverNonce)
+		k.local, k.remote = clientKeys, serverKeys
+		if c.server {
+			k.local, k.remote = serverKeys, clientKeys
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for id, old := range c.tokens {
+		if now.After(old.expires) {
+			delete(c.tokens, id)
+		}
+	}
+	c.channelID = tok.ChannelID
+	c.tokens[tok.TokenID] = k
+	if !c.server || c.sendToken == 0 {
+		c.sendToken = tok.TokenID
+	}
+	return nil
+}
+
+func (c *secureChannel) close() error { return c.conn.Close() }
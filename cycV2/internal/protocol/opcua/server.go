@@ -0,0 +1,1222 @@
+package opcua
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 服务端限制
+const (
+	serverMaxSessions        = 100
+	serverMaxContinuations   = 16
+	serverMaxPublishRequests = 10
+	serverMaxQueue           = 1000
+	serverMinInterval        = 50 * time.Millisecond
+	serverTick               = 10 * time.Millisecond
+	serverChannelLifetime    = uint32(time.Hour / time.Millisecond)
+)
+
+// 标准地址空间节点
+var (
+	RootFolder           = NewNumericNodeID(0, 84)
+	TypesFolder          = NewNumericNodeID(0, 86)
+	ViewsFolder          = NewNumericNodeID(0, 87)
+	NamespaceArrayNode   = NewNumericNodeID(0, 2255)
+	ServerStatusNode     = NewNumericNodeID(0, 2256)
+	CurrentTimeNode      = NewNumericNodeID(0, 2258)
+	ServerStateNode      = NewNumericNodeID(0, 2259)
+	euInformationType    = NewNumericNodeID(0, 887)
+	serverType           = NewNumericNodeID(0, 2004)
+	serverStatusType     = NewNumericNodeID(0, 2138)
+	serverStatusDataType = NewNumericNodeID(0, 862)
+	serverStateType      = NewNumericNodeID(0, 852)
+)
+
+// refParents 引用类型的父类型，用于 IncludeSubtypes 过滤
+var refParents = map[uint32]uint32{
+	32: 31, // NonHierarchicalReferences
+	33: 31, // HierarchicalReferences
+	34: 33, // HasChild
+	35: 33, // Organizes
+	40: 32, // HasTypeDefinition
+	44: 34, // Aggregates
+	45: 34, // HasSubtype
+	46: 44, // HasProperty
+	47: 44, // HasComponent
+}
+
+// 访问级别
+const (
+	accessRead  byte = 0x01
+	accessWrite byte = 0x02
+)
+
+// ServerConfig 服务端参数
+type ServerConfig struct {
+	Listen           string            // 监听地址，默认 ":4840"
+	EndpointURL      string            // 对外公布的端点，默认按监听地址生成
+	ApplicationURI   string            // 默认 "urn:cycV2:opcua:server"
+	ApplicationName  string            // 默认 "cycV2"
+	NamespaceURI     string            // 命名空间2的URI，默认 "urn:cycV2:gateway"
+	CertFile         string            // 证书与私钥，为空时生成自签名证书
+	KeyFile          string            //
+	SecurityPolicies []string          // None / Basic256Sha256，默认 None
+	Users            map[string]string // 用户名 → 密码
+	AllowAnonymous   bool              // 配置了用户时是否仍允许匿名
+}
+
+// Variable 变量节点定义
+type Variable struct {
+	ID          NodeID
+	Name        string
+	Description string
+	DataType    TypeID
+	Unit        string                   // 非空时挂 EngineeringUnits 属性
+	Value       DataValue                // 初值
+	OnWrite     func(Variant) StatusCode // 为 nil 时节点只读
+}
+
+type reference struct {
+	typ     NodeID
+	target  NodeID
+	forward bool
+}
+
+type serverNode struct {
+	id          NodeID
+	class       uint32
+	browseName  QualifiedName
+	displayName LocalizedText
+	description LocalizedText
+	typeDef     NodeID
+	dataType    NodeID
+	valueRank   int32
+	access      byte
+	value       DataValue
+	onWrite     func(Variant) StatusCode
+	refs        []reference
+}
+
+type monitoredItem struct {
+	id        uint32
+	handle    uint32
+	node      NodeID
+	mode      uint32 // 0 停用，1 采样，2 报告
+	queueSize uint32
+}
+
+type serverSubscription struct {
+	id        uint32
+	interval  time.Duration
+	keepAlive uint32
+	maxNotify uint32
+	enabled   bool
+	items     map[uint32]*monitoredItem
+	queue     []MonitoredItemNotification
+	seq       uint32
+	next      time.Time
+	idle      uint32
+	started   bool // 首个发布周期须立即应答（数据或保活）
+}
+
+type pendingPublish struct {
+	req   *PublishRequest
+	reply func(interface{})
+}
+
+type serverSession struct {
+	id        NodeID
+	authToken NodeID
+	ch        *secureChannel
+	nonce     []byte
+	activated bool
+	timeout   time.Duration
+	lastSeen  time.Time
+	cps       map[string][]ReferenceDescription
+	subs      map[uint32]*serverSubscription
+	publishQ  []*pendingPublish
+}
+
+// Server OPC UA 服务端：二进制 TCP 传输，None 与 Basic256Sha256 安全策略，
+// 匿名/用户名认证，浏览、读写与订阅。地址空间由 AddFolder/AddVariable 构建，
+// SetValue 更新变量并通知监视项
+type Server struct {
+	cfg      ServerConfig
+	cert     []byte
+	key      *rsa.PrivateKey
+	policies map[string]bool
+	ln       net.Listener
+	endpoint string
+
+	mu       sync.Mutex
+	nodes    map[NodeID]*serverNode
+	sessions map[NodeID]*serverSession // 认证令牌 → 会话
+	conns    map[net.Conn]struct{}
+	nextID   uint32
+	nextChan uint32
+	closed   bool
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer 创建服务端并建立基础地址空间（Root/Objects/Server）
+func NewServer(cfg ServerConfig) (*Server, error) {
+	if cfg.Listen == "" {
+		cfg.Listen = ":4840"
+	}
+	if cfg.ApplicationURI == "" {
+		cfg.ApplicationURI = "urn:cycV2:opcua:server"
+	}
+	if cfg.ApplicationName == "" {
+		cfg.ApplicationName = "cycV2"
+	}
+	if cfg.NamespaceURI == "" {
+		cfg.NamespaceURI = "urn:cycV2:gateway"
+	}
+	s := &Server{
+		cfg:      cfg,
+		policies: make(map[string]bool),
+		nodes:    make(map[NodeID]*serverNode),
+		sessions: make(map[NodeID]*serverSession),
+		conns:    make(map[net.Conn]struct{}),
+		stopCh:   make(chan struct{}),
+	}
+	if len(cfg.SecurityPolicies) == 0 {
+		cfg.SecurityPolicies = []string{"None"}
+	}
+	for _, name := range cfg.SecurityPolicies {
+		uri, err := policyURI(name)
+		if err != nil {
+			return nil, err
+		}
+		s.policies[uri] = true
+	}
+	var err error
+	switch {
+	case cfg.CertFile != "" && cfg.KeyFile != "":
+		s.cert, s.key, err = loadCertificate(cfg.CertFile, cfg.KeyFile)
+	case cfg.CertFile != "" || cfg.KeyFile != "":
+		err = errors.New("opcua: certFile and keyFile must be set together")
+	default:
+		s.cert, s.key, err = generateCertificate(cfg.ApplicationURI, "localhost")
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.buildBase()
+	return s, nil
+}
+
+// buildBase 标准节点的最小子集，供通用客户端浏览
+func (s *Server) buildBase() {
+	s.addNode(&serverNode{id: RootFolder, class: NodeClassObject, browseName: QualifiedName{Name: "Root"}, typeDef: FolderType}, NodeID{}, NodeID{})
+	for _, f := range []struct {
+		id   NodeID
+		name string
+	}{{ObjectsFolder, "Objects"}, {TypesFolder, "Types"}, {ViewsFolder, "Views"}} {
+		s.addNode(&serverNode{id: f.id, class: NodeClassObject, browseName: QualifiedName{Name: f.name}, typeDef: FolderType}, RootFolder, OrganizesReference)
+	}
+	s.addNode(&serverNode{id: ServerNode, class: NodeClassObject, browseName: QualifiedName{Name: "Server"}, typeDef: serverType}, ObjectsFolder, OrganizesReference)
+	s.addNode(&serverNode{
+		id: NamespaceArrayNode, class: NodeClassVariable, browseName: QualifiedName{Name: "NamespaceArray"}, typeDef: PropertyType,
+		dataType: NewNumericNodeID(0, uint32(TypeString)), valueRank: 1, access: accessRead,
+		value: DataValue{Value: Variant{TypeString, []string{"http://opcfoundation.org/UA/", s.cfg.ApplicationURI, s.cfg.NamespaceURI}}},
+	}, ServerNode, HasPropertyReference)
+	s.addNode(&serverNode{
+		id: ServerStatusNode, class: NodeClassVariable, browseName: QualifiedName{Name: "ServerStatus"}, typeDef: serverStatusType,
+		dataType: serverStatusDataType, valueRank: -1, access: accessRead,
+	}, ServerNode, HasComponentReference)
+	s.addNode(&serverNode{
+		id: CurrentTimeNode, class: NodeClassVariable, browseName: QualifiedName{Name: "CurrentTime"}, typeDef: BaseDataVariableType,
+		dataType: NewNumericNodeID(0, uint32(TypeDateTime)), valueRank: -1, access: accessRead,
+	}, ServerStatusNode, HasComponentReference)
+	s.addNode(&serverNode{
+		id: ServerStateNode, class: NodeClassVariable, browseName: QualifiedName{Name: "State"}, typeDef: BaseDataVariableType,
+		dataType: serverStateType, valueRank: -1, access: accessRead, value: DataValue{Value: Variant{TypeInt32, int32(0)}},
+	}, ServerStatusNode, HasComponentReference)
+}
+
+// Namespace 自定义节点所在的命名空间索引
+func (s *Server) Namespace() uint16 { return 2 }
+
+// Start 开始监听
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	s.endpoint = s.cfg.EndpointURL
+	if s.endpoint == "" {
+		host, port, _ := net.SplitHostPort(ln.Addr().String())
+		if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+			host = "localhost"
+		}
+		s.endpoint = "opc.tcp://" + net.JoinHostPort(host, port)
+	}
+	s.wg.Add(2)
+	go s.acceptLoop()
+	go s.publishLoop()
+	log.Printf("[OPCUA] 服务端监听 %s，端点 %s", ln.Addr(), s.endpoint)
+	return nil
+}
+
+// Addr 实际监听地址
+func (s *Server) Addr() net.Addr {
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// EndpointURL 对外公布的端点URL
+func (s *Server) EndpointURL() string { return s.endpoint }
+
+// Close 停止监听并断开所有连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stopCh)
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	s.wg.Wait()
+	return err
+}
+
+// AddFolder 在 parent 下添加文件夹对象
+func (s *Server) AddFolder(parent, id NodeID, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkAdd(parent, id); err != nil {
+		return err
+	}
+	s.addNode(&serverNode{
+		id: id, class: NodeClassObject, typeDef: FolderType,
+		browseName: QualifiedName{NamespaceIndex: id.Namespace, Name: name}, displayName: LocalizedText{Text: name},
+	}, parent, OrganizesReference)
+	return nil
+}
+
+// AddVariable 在 parent 下添加变量；Unit 非空时附带 EngineeringUnits 属性
+func (s *Server) AddVariable(parent NodeID, v Variable) error {
+	if v.DataType == TypeNull || int(v.DataType) >= len(scalarTypes) {
+		return fmt.Errorf("opcua: invalid data type %d for %s", v.DataType, v.ID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkAdd(parent, v.ID); err != nil {
+		return err
+	}
+	n := &serverNode{
+		id: v.ID, class: NodeClassVariable, typeDef: BaseDataVariableType,
+		browseName:  QualifiedName{NamespaceIndex: v.ID.Namespace, Name: v.Name},
+		displayName: LocalizedText{Text: v.Name}, description: LocalizedText{Text: v.Description},
+		dataType: NewNumericNodeID(0, uint32(v.DataType)), valueRank: -1, access: accessRead,
+		value: v.Value, onWrite: v.OnWrite,
+	}
+	if v.OnWrite != nil {
+		n.access |= accessWrite
+	}
+	s.addNode(n, parent, HasComponentReference)
+	if v.Unit != "" {
+		eu := &EUInformation{DisplayName: LocalizedText{Text: v.Unit}, Description: LocalizedText{Text: v.Unit}, UnitID: -1}
+		id := v.ID
+		if id.Type == IDString {
+			id.Str += ".EngineeringUnits"
+		} else {
+			id = NewStringNodeID(id.Namespace, id.String()+".EngineeringUnits")
+		}
+		if s.nodes[id] == nil {
+			s.addNode(&serverNode{
+				id: id, class: NodeClassVariable, typeDef: PropertyType, browseName: QualifiedName{Name: "EngineeringUnits"},
+				displayName: LocalizedText{Text: "EngineeringUnits"}, dataType: euInformationType, valueRank: -1, access: accessRead,
+				value: DataValue{Value: Variant{TypeExtensionObject, NewExtensionObject(eu)}},
+			}, v.ID, HasPropertyReference)
+		}
+	}
+	return nil
+}
+
+// HasNode 节点是否存在
+func (s *Server) HasNode(id NodeID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nodes[id] != nil
+}
+
+// RemoveNode 删除节点及其层级子节点；监视这些节点的监视项收到 BadNodeIdUnknown
+func (s *Server) RemoveNode(id NodeID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id.Namespace == 0 {
+		return fmt.Errorf("opcua: cannot remove standard node %s", id)
+	}
+	if s.nodes[id] == nil {
+		return StatusBadNodeIDUnknown
+	}
+	s.removeNode(id)
+	return nil
+}
+
+// SetValue 更新变量值。值或品质变化时通知监视项
+func (s *Server) SetValue(id NodeID, dv DataValue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.nodes[id]
+	if n == nil {
+		return StatusBadNodeIDUnknown
+	}
+	if n.class != NodeClassVariable {
+		return StatusBadNotWritable
+	}
+	if dv.ServerTimestamp.IsZero() {
+		dv.ServerTimestamp = time.Now()
+	}
+	changed := dv.Status != n.value.Status || !reflect.DeepEqual(dv.Value, n.value.Value)
+	n.value = dv
+	if changed {
+		s.notify(id, dv)
+	}
+	return nil
+}
+
+// Value 读取变量当前值
+func (s *Server) Value(id NodeID) (DataValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.nodes[id]
+	if n == nil || n.class != NodeClassVariable {
+		return DataValue{}, StatusBadNodeIDUnknown
+	}
+	return n.value, nil
+}
+
+// checkAdd 调用方持有 mu
+func (s *Server) checkAdd(parent, id NodeID) error {
+	if s.nodes[parent] == nil {
+		return fmt.Errorf("opcua: parent %s: %w", parent, StatusBadNodeIDUnknown)
+	}
+	if s.nodes[id] != nil {
+		return fmt.Errorf("opcua: node %s already exists", id)
+	}
+	return nil
+}
+
+// addNode 调用方持有 mu（构造期间除外）
+func (s *Server) addNode(n *serverNode, parent, refType NodeID) {
+	if n.displayName.Text == "" {
+		n.displayName = LocalizedText{Text: n.browseName.Name}
+	}
+	s.nodes[n.id] = n
+	if !parent.IsNull() {
+		p := s.nodes[parent]
+		p.refs = append(p.refs, reference{typ: refType, target: n.id, forward: true})
+		n.refs = append(n.refs, reference{typ: refType, target: parent})
+	}
+	if !n.typeDef.IsNull() {
+		n.refs = append(n.refs, reference{typ: HasTypeDefinition, target: n.typeDef, forward: true})
+	}
+}
+
+// removeNode 调用方持有 mu
+func (s *Server) removeNode(id NodeID) {
+	n := s.nodes[id]
+	if n == nil {
+		return
+	}
+	delete(s.nodes, id)
+	for _, r := range n.refs {
+		if r.forward && isSubtype(r.typ, HierarchicalReference) {
+			s.removeNode(r.target)
+			continue
+		}
+		if t := s.nodes[r.target]; t != nil {
+			refs := t.refs[:0]
+			for _, tr := range t.refs {
+				if tr.target != id {
+					refs = append(refs, tr)
+				}
+			}
+			t.refs = refs
+		}
+	}
+	s.notify(id, DataValue{Status: StatusBadNodeIDUnknown, ServerTimestamp: time.Now()})
+}
+
+// notify 为监视该节点的报告模式监视项排队通知，调用方持有 mu
+func (s *Server) notify(id NodeID, dv DataValue) {
+	for _, sess := range s.sessions {
+		for _, sub := range sess.subs {
+			for _, it := range sub.items {
+				if it.node == id && it.mode == 2 {
+					sub.enqueue(it, dv)
+				}
+			}
+		}
+	}
+}
+
+// enqueue 队列长度为1的监视项只保留最新值
+func (sub *serverSubscription) enqueue(it *monitoredItem, dv DataValue) {
+	n := MonitoredItemNotification{ClientHandle: it.handle, Value: dv}
+	if it.queueSize <= 1 {
+		for i := range sub.queue {
+			if sub.queue[i].ClientHandle == it.handle {
+				sub.queue[i] = n
+				return
+			}
+		}
+	}
+	if len(sub.queue) >= serverMaxQueue {
+		sub.queue = sub.queue[1:]
+	}
+	sub.queue = append(sub.queue, n)
+}
+
+// isSubtype typ 是否为 parent 或其子类型
+func isSubtype(typ, parent NodeID) bool {
+	if typ == parent {
+		return true
+	}
+	if typ.Namespace != 0 || typ.Type != IDNumeric || parent.Namespace != 0 || parent.Type != IDNumeric {
+		return false
+	}
+	for id, ok := typ.Numeric, true; ok; id, ok = refParents[id] {
+		if id == parent.Numeric {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.nextChan++
+		chanID := s.nextChan
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serve(conn, chanID)
+	}
+}
+
+// serve 处理一个 TCP 连接上的安全通道；连接断开时删除绑定的会话
+func (s *Server) serve(conn net.Conn, chanID uint32) {
+	defer s.wg.Done()
+	ch := newSecureChannel(conn, true)
+	ch.localCert, ch.localKey = s.cert, s.key
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		for tok, sess := range s.sessions {
+			if sess.ch == ch {
+				s.dropSession(tok, sess)
+			}
+		}
+		s.mu.Unlock()
+	}()
+	if _, err := ch.acceptHello(10 * time.Second); err != nil {
+		return
+	}
+	reply := func(requestID uint32, resp interface{}) {
+		body, err := encodeService(resp)
+		if err != nil {
+			log.Printf("[OPCUA] 编码响应失败: %v", err)
+			return
+		}
+		ch.send("MSG", requestID, body)
+	}
+	var tokenID uint32
+	for {
+		msg, err := ch.receive()
+		if err != nil {
+			var code StatusCode
+			if errors.As(err, &code) {
+				ch.sendError(code, "")
+			}
+			return
+		}
+		req, err := decodeService(msg.body)
+		if err != nil {
+			if msg.typ == "MSG" {
+				reply(msg.requestID, fault(nil, StatusBadServiceUnsupported))
+				continue
+			}
+			ch.sendError(StatusBadDecodingError, err.Error())
+			return
+		}
+		switch msg.typ {
+		case "OPN":
+			r, ok := req.(*OpenSecureChannelRequest)
+			if !ok {
+				ch.sendError(StatusBadTcpMessageTypeInvalid, "")
+				return
+			}
+			if r.RequestType == 0 {
+				// None 通道始终开放，用于端点发现
+				if !s.channelAllowed(ch.policy, r.SecurityMode) && ch.policy != PolicyNone {
+					ch.sendError(StatusBadSecurityPolicyRejected, "")
+					return
+				}
+				if (ch.policy == PolicyNone) != (r.SecurityMode == SecurityModeNone) {
+					ch.sendError(StatusBadSecurityModeRejected, "")
+					return
+				}
+				ch.mode = r.SecurityMode
+			}
+			tokenID++
+			nonce := ch.newNonce()
+			tok := ChannelSecurityToken{ChannelID: chanID, TokenID: tokenID, CreatedAt: time.Now(), RevisedLifetime: min(max(r.RequestedLifetime, 60000), serverChannelLifetime)}
+			if err := ch.installToken(tok, r.ClientNonce, nonce); err != nil {
+				ch.sendError(StatusBadSecurityChecksFailed, "")
+				return
+			}
+			body, err := encodeService(&OpenSecureChannelResponse{ResponseHeader: responseHeaderFor(req), SecurityToken: tok, ServerNonce: nonce})
+			if err != nil {
+				return
+			}
+			ch.send("OPN", msg.requestID, body)
+			continue
+		case "CLO":
+			return
+		}
+		requestID := msg.requestID
+		switch req.(type) {
+		case *CreateSessionRequest, *ActivateSessionRequest, *CloseSessionRequest:
+			reply(requestID, s.handle(ch, req, nil))
+		default:
+			// 写入可能等待设备控制结果，其余请求也不阻塞接收
+			go func() {
+				if resp := s.handle(ch, req, func(resp interface{}) { reply(requestID, resp) }); resp != nil {
+					reply(requestID, resp)
+				}
+			}()
+		}
+	}
+}
+
+// channelAllowed 通道策略与模式是否对应一个已启用的端点
+func (s *Server) channelAllowed(policy string, mode MessageSecurityMode) bool {
+	if !s.policies[policy] {
+		return false
+	}
+	if policy == PolicyNone {
+		return mode == SecurityModeNone
+	}
+	return mode == SecurityModeSign || mode == SecurityModeSignAndEncrypt
+}
+
+func (s *Server) endpoints() []EndpointDescription {
+	users := []UserTokenPolicy{}
+	if len(s.cfg.Users) == 0 || s.cfg.AllowAnonymous {
+		users = append(users, UserTokenPolicy{PolicyID: "anonymous", TokenType: UserTokenAnonymous})
+	}
+	if len(s.cfg.Users) > 0 {
+		// 密码总以服务器证书加密，None 端点也不明文传输
+		users = append(users, UserTokenPolicy{PolicyID: "username", TokenType: UserTokenUserName, SecurityPolicyURI: PolicyBasic256Sha256})
+	}
+	app := s.application()
+	var eps []EndpointDescription
+	add := func(policy string, mode MessageSecurityMode, level uint8) {
+		eps = append(eps, EndpointDescription{
+			EndpointURL: s.endpoint, Server: app, ServerCertificate: s.cert, SecurityMode: mode,
+			SecurityPolicyURI: policy, UserIdentityTokens: users, TransportProfileURI: transportBinary, SecurityLevel: level,
+		})
+	}
+	if s.policies[PolicyNone] {
+		add(PolicyNone, SecurityModeNone, 0)
+	}
+	if s.policies[PolicyBasic256Sha256] {
+		add(PolicyBasic256Sha256, SecurityModeSign, 1)
+		add(PolicyBasic256Sha256, SecurityModeSignAndEncrypt, 2)
+	}
+	return eps
+}
+
+func (s *Server) application() ApplicationDescription {
+	return ApplicationDescription{
+		ApplicationURI: s.cfg.ApplicationURI, ProductURI: "urn:cycV2",
+		ApplicationName: LocalizedText{Text: s.cfg.ApplicationName}, DiscoveryURLs: []string{s.endpoint},
+	}
+}
+
+func responseHeaderFor(req interface{}) ResponseHeader {
+	h := ResponseHeader{Timestamp: time.Now()}
+	if req != nil {
+		h.RequestHandle = requestHeader(req).RequestHandle
+	}
+	return h
+}
+
+func fault(req interface{}, code StatusCode) *ServiceFault {
+	h := responseHeaderFor(req)
+	h.ServiceResult = code
+	return &ServiceFault{ResponseHeader: h}
+}
+
+// handle 处理服务请求。Publish 挂起后由发布循环经 reply 应答，此时返回 nil
+func (s *Server) handle(ch *secureChannel, req interface{}, reply func(interface{})) interface{} {
+	h := responseHeaderFor(req)
+	switch r := req.(type) {
+	case *GetEndpointsRequest:
+		return &GetEndpointsResponse{ResponseHeader: h, Endpoints: s.endpoints()}
+	case *FindServersRequest:
+		return &FindServersResponse{ResponseHeader: h, Servers: []ApplicationDescription{s.application()}}
+	case *CreateSessionRequest:
+		return s.createSession(ch, r)
+	}
+
+	s.mu.Lock()
+	sess := s.sessions[requestHeader(req).AuthenticationToken]
+	if sess != nil {
+		sess.lastSeen = time.Now()
+	}
+	s.mu.Unlock()
+	if sess == nil {
+		return fault(req, StatusBadSessionIDInvalid)
+	}
+	if r, ok := req.(*ActivateSessionRequest); ok {
+		return s.activateSession(ch, sess, r)
+	}
+	if !sess.activated {
+		return fault(req, StatusBadSessionNotActivated)
+	}
+	if sess.ch != ch {
+		return fault(req, StatusBadSecureChannelIDInvalid)
+	}
+
+	switch r := req.(type) {
+	case *WriteRequest:
+		// 回调可能阻塞，不持锁
+		resp := &WriteResponse{ResponseHeader: h}
+		for _, wv := range r.NodesToWrite {
+			resp.Results = append(resp.Results, s.write(wv))
+		}
+		return resp
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r := req.(type) {
+	case *CloseSessionRequest:
+		s.dropSession(r.RequestHeader.AuthenticationToken, sess)
+		return &CloseSessionResponse{ResponseHeader: h}
+	case *BrowseRequest:
+		resp := &BrowseResponse{ResponseHeader: h}
+		for _, bd := range r.NodesToBrowse {
+			resp.Results = append(resp.Results, s.browse(sess, bd, r.RequestedMaxReferencesPerNode))
+		}
+		return resp
+	case *BrowseNextRequest:
+		resp := &BrowseNextResponse{ResponseHeader: h}
+		for _, cp := range r.ContinuationPoints {
+			refs, ok := sess.cps[string(cp)]
+			delete(sess.cps, string(cp))
+			switch {
+			case !ok:
+				resp.Results = append(resp.Results, BrowseResult{StatusCode: StatusBadContinuationPointInvalid})
+			case r.ReleaseContinuationPoints:
+				resp.Results = append(resp.Results, BrowseResult{})
+			default:
+				resp.Results = append(resp.Results, s.page(sess, refs, uint32(len(refs))))
+			}
+		}
+		return resp
+	case *ReadRequest:
+		resp := &ReadResponse{ResponseHeader: h}
+		for _, rv := range r.NodesToRead {
+			resp.Results = append(resp.Results, s.read(rv, r.TimestampsToReturn))
+		}
+		return resp
+	case *CreateSubscriptionRequest:
+		s.nextID++
+		sub := &serverSubscription{id: s.nextID, enabled: r.PublishingEnabled, items: make(map[uint32]*monitoredItem)}
+		sub.revise(r.RequestedPublishingInterval, r.RequestedMaxKeepAliveCount, r.MaxNotificationsPerPublish)
+		sess.subs[sub.id] = sub
+		return &CreateSubscriptionResponse{
+			ResponseHeader: h, SubscriptionID: sub.id, RevisedPublishingInterval: float64(sub.interval / time.Millisecond),
+			RevisedLifetimeCount: max(r.RequestedLifetimeCount, 3*sub.keepAlive), RevisedMaxKeepAliveCount: sub.keepAlive,
+		}
+	case *ModifySubscriptionRequest:
+		sub := sess.subs[r.SubscriptionID]
+		if sub == nil {
+			return fault(req, StatusBadSubscriptionIDInvalid)
+		}
+		sub.revise(r.RequestedPublishingInterval, r.RequestedMaxKeepAliveCount, r.MaxNotificationsPerPublish)
+		return &ModifySubscriptionResponse{
+			ResponseHeader: h, RevisedPublishingInterval: float64(sub.interval / time.Millisecond),
+			RevisedLifetimeCount: max(r.RequestedLifetimeCount, 3*sub.keepAlive), RevisedMaxKeepAliveCount: sub.keepAlive,
+		}
+	case *SetPublishingModeRequest:
+		resp := &SetPublishingModeResponse{ResponseHeader: h}
+		for _, id := range r.SubscriptionIDs {
+			sub := sess.subs[id]
+			if sub == nil {
+				resp.Results = append(resp.Results, StatusBadSubscriptionIDInvalid)
+				continue
+			}
+			sub.enabled = r.PublishingEnabled
+			resp.Results = append(resp.Results, StatusOK)
+		}
+		return resp
+	case *DeleteSubscriptionsRequest:
+		resp := &DeleteSubscriptionsResponse{ResponseHeader: h}
+		for _, id := range r.SubscriptionIDs {
+			if sess.subs[id] == nil {
+				resp.Results = append(resp.Results, StatusBadSubscriptionIDInvalid)
+				continue
+			}
+			delete(sess.subs, id)
+			resp.Results = append(resp.Results, StatusOK)
+		}
+		s.flushPublish(sess)
+		return resp
+	case *CreateMonitoredItemsRequest:
+		sub := sess.subs[r.SubscriptionID]
+		if sub == nil {
+			return fault(req, StatusBadSubscriptionIDInvalid)
+		}
+		resp := &CreateMonitoredItemsResponse{ResponseHeader: h}
+		for _, it := range r.ItemsToCreate {
+			n := s.nodes[it.ItemToMonitor.NodeID]
+			switch {
+			case n == nil:
+				resp.Results = append(resp.Results, MonitoredItemCreateResult{StatusCode: StatusBadNodeIDUnknown})
+				continue
+			case it.ItemToMonitor.AttributeID != AttrValue || n.class != NodeClassVariable:
+				resp.Results = append(resp.Results, MonitoredItemCreateResult{StatusCode: StatusBadAttributeIDInvalid})
+				continue
+			}
+			s.nextID++
+			mi := &monitoredItem{id: s.nextID, handle: it.RequestedParameters.ClientHandle, node: n.id, mode: it.MonitoringMode, queueSize: max(it.RequestedParameters.QueueSize, 1)}
+			sub.items[mi.id] = mi
+			if mi.mode == 2 {
+				sub.enqueue(mi, s.currentValue(n))
+			}
+			// 值由设备采集推送，采样周期按发布周期修正
+			resp.Results = append(resp.Results, MonitoredItemCreateResult{
+				MonitoredItemID: mi.id, RevisedSamplingInterval: float64(sub.interval / time.Millisecond), RevisedQueueSize: mi.queueSize,
+			})
+		}
+		return resp
+	case *SetMonitoringModeRequest:
+		sub := sess.subs[r.SubscriptionID]
+		if sub == nil {
+			return fault(req, StatusBadSubscriptionIDInvalid)
+		}
+		resp := &SetMonitoringModeResponse{ResponseHeader: h}
+		for _, id := range r.MonitoredItemIDs {
+			mi := sub.items[id]
+			if mi == nil {
+				resp.Results = append(resp.Results, StatusBadMonitoredItemIDInvalid)
+				continue
+			}
+			if mi.mode != 2 && r.MonitoringMode == 2 {
+				if n := s.nodes[mi.node]; n != nil {
+					sub.enqueue(mi, s.currentValue(n))
+				}
+			}
+			mi.mode = r.MonitoringMode
+			resp.Results = append(resp.Results, StatusOK)
+		}
+		return resp
+	case *DeleteMonitoredItemsRequest:
+		sub := sess.subs[r.SubscriptionID]
+		if sub == nil {
+			return fault(req, StatusBadSubscriptionIDInvalid)
+		}
+		resp := &DeleteMonitoredItemsResponse{ResponseHeader: h}
+		for _, id := range r.MonitoredItemIDs {
+			if sub.items[id] == nil {
+				resp.Results = append(resp.Results, StatusBadMonitoredItemIDInvalid)
+				continue
+			}
+			delete(sub.items, id)
+			resp.Results = append(resp.Results, StatusOK)
+		}
+		return resp
+	case *PublishRequest:
+		if len(sess.subs) == 0 {
+			return fault(req, StatusBadNoSubscription)
+		}
+		if len(sess.publishQ) >= serverMaxPublishRequests {
+			old := sess.publishQ[0]
+			sess.publishQ = sess.publishQ[1:]
+			go old.reply(fault(old.req, StatusBadTooManyPublishRequests))
+		}
+		sess.publishQ = append(sess.publishQ, &pendingPublish{req: r, reply: reply})
+		return nil
+	case *RepublishRequest:
+		// 不保留已发送的通知
+		return fault(req, StatusBadMessageNotAvailable)
+	}
+	return fault(req, StatusBadServiceUnsupported)
+}
+
+func (s *Server) createSession(ch *secureChannel, r *CreateSessionRequest) interface{} {
+	if !s.channelAllowed(ch.policy, ch.mode) {
+		return fault(r, StatusBadSecurityPolicyRejected)
+	}
+	sess := &serverSession{
+		ch: ch, nonce: make([]byte, nonceLen), lastSeen: time.Now(),
+		cps: make(map[string][]ReferenceDescription), subs: make(map[uint32]*serverSubscription),
+	}
+	rand.Read(sess.nonce)
+	sess.timeout = time.Duration(r.RequestedSessionTimeout) * time.Millisecond
+	sess.timeout = min(max(sess.timeout, 10*time.Second), time.Hour)
+	resp := &CreateSessionResponse{
+		ResponseHeader: responseHeaderFor(r), RevisedSessionTimeout: float64(sess.timeout / time.Millisecond),
+		ServerNonce: sess.nonce, ServerCertificate: s.cert, ServerEndpoints: s.endpoints(), MaxRequestMessageSize: maxMessageSize,
+	}
+	if ch.secure() {
+		if !bytes.Equal(r.ClientCertificate, ch.remoteCert) {
+			return fault(r, StatusBadCertificateInvalid)
+		}
+		sig, err := rsaSign(s.key, append(append([]byte(nil), r.ClientCertificate...), r.ClientNonce...))
+		if err != nil {
+			return fault(r, StatusBadInternalError)
+		}
+		resp.ServerSignature = SignatureData{Algorithm: algRsaSha256, Signature: sig}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sessions) >= serverMaxSessions {
+		return fault(r, StatusBadTooManySessions)
+	}
+	s.nextID++
+	sess.id = NewNumericNodeID(1, s.nextID)
+	sess.authToken = NewStringNodeID(1, hex.EncodeToString(sess.nonce[:16]))
+	s.sessions[sess.authToken] = sess
+	resp.SessionID, resp.AuthenticationToken = sess.id, sess.authToken
+	return resp
+}
+
+// activateSession 校验客户端签名与用户令牌；允许会话迁移到新通道
+func (s *Server) activateSession(ch *secureChannel, sess *serverSession, r *ActivateSessionRequest) interface{} {
+	if ch.secure() {
+		key := ch.remoteKey
+		if key == nil || rsaVerify(key, append(append([]byte(nil), s.cert...), sess.nonce...), r.ClientSignature.Signature) != nil {
+			return fault(r, StatusBadSecurityChecksFailed)
+		}
+	}
+	if code := s.checkIdentity(r.UserIdentityToken, sess.nonce); code != StatusOK {
+		return fault(r, code)
+	}
+	s.mu.Lock()
+	sess.activated = true
+	sess.ch = ch
+	s.mu.Unlock()
+	return &ActivateSessionResponse{ResponseHeader: responseHeaderFor(r), ServerNonce: sess.nonce}
+}
+
+func (s *Server) checkIdentity(tok ExtensionObject, nonce []byte) StatusCode {
+	switch t := tok.Value.(type) {
+	case nil, *AnonymousIdentityToken:
+		if len(s.cfg.Users) > 0 && !s.cfg.AllowAnonymous {
+			return StatusBadIdentityTokenRejected
+		}
+		return StatusOK
+	case *UserNameIdentityToken:
+		want, ok := s.cfg.Users[t.UserName]
+		if !ok {
+			return StatusBadIdentityTokenRejected
+		}
+		pwd := t.Password
+		if t.EncryptionAlgorithm != "" {
+			if t.EncryptionAlgorithm != algRsaOaep {
+				return StatusBadIdentityTokenInvalid
+			}
+			plain, err := rsaDecrypt(s.key, t.Password)
+			if err != nil || len(plain) < 4+len(nonce) || int(binary.LittleEndian.Uint32(plain)) != len(plain)-4 {
+				return StatusBadIdentityTokenInvalid
+			}
+			if !bytes.Equal(plain[len(plain)-len(nonce):], nonce) {
+				return StatusBadIdentityTokenInvalid
+			}
+			pwd = plain[4 : len(plain)-len(nonce)]
+		}
+		if string(pwd) != want {
+			return StatusBadIdentityTokenRejected
+		}
+		return StatusOK
+	}
+	return StatusBadIdentityTokenInvalid
+}
+
+// dropSession 调用方持有 mu；挂起的发布请求以 BadSessionClosed 结束
+func (s *Server) dropSession(tok NodeID, sess *serverSession) {
+	delete(s.sessions, tok)
+	for _, p := range sess.publishQ {
+		go p.reply(fault(p.req, StatusBadSessionClosed))
+	}
+	sess.publishQ = nil
+	sess.subs = nil
+}
+
+// flushPublish 会话已无订阅时结束挂起的发布请求，调用方持有 mu
+func (s *Server) flushPublish(sess *serverSession) {
+	if len(sess.subs) > 0 {
+		return
+	}
+	for _, p := range sess.publishQ {
+		go p.reply(fault(p.req, StatusBadNoSubscription))
+	}
+	sess.publishQ = nil
+}
+
+// browse 调用方持有 mu
+func (s *Server) browse(sess *serverSession, bd BrowseDescription, maxRefs uint32) BrowseResult {
+	n := s.nodes[bd.NodeID]
+	if n == nil {
+		return BrowseResult{StatusCode: StatusBadNodeIDUnknown}
+	}
+	var refs []ReferenceDescription
+	for _, r := range n.refs {
+		if bd.BrowseDirection == BrowseForward && !r.forward || bd.BrowseDirection == BrowseInverse && r.forward {
+			continue
+		}
+		if !bd.ReferenceTypeID.IsNull() && r.typ != bd.ReferenceTypeID && !(bd.IncludeSubtypes && isSubtype(r.typ, bd.ReferenceTypeID)) {
+			continue
+		}
+		rd := ReferenceDescription{ReferenceTypeID: r.typ, IsForward: r.forward, NodeID: ExpandedNodeID{NodeID: r.target}}
+		if t := s.nodes[r.target]; t != nil {
+			if bd.NodeClassMask != 0 && bd.NodeClassMask&t.class == 0 {
+				continue
+			}
+			rd.BrowseName, rd.DisplayName, rd.NodeClass = t.browseName, t.displayName, t.class
+			rd.TypeDefinition = ExpandedNodeID{NodeID: t.typeDef}
+		} else if bd.NodeClassMask != 0 {
+			// 类型节点不在地址空间中，仅在不过滤类别时返回
+			continue
+		}
+		refs = append(refs, rd)
+	}
+	return s.page(sess, refs, maxRefs)
+}
+
+// page 按 maxRefs 分页，剩余部分保存为续传点
+func (s *Server) page(sess *serverSession, refs []ReferenceDescription, maxRefs uint32) BrowseResult {
+	if maxRefs == 0 || int(maxRefs) >= len(refs) {
+		return BrowseResult{References: refs}
+	}
+	if len(sess.cps) >= serverMaxContinuations {
+		return BrowseResult{StatusCode: StatusBadNoContinuationPoints}
+	}
+	cp := make([]byte, 8)
+	rand.Read(cp)
+	sess.cps[string(cp)] = refs[maxRefs:]
+	return BrowseResult{References: refs[:maxRefs], ContinuationPoint: cp}
+}
+
+// currentValue 调用方持有 mu
+func (s *Server) currentValue(n *serverNode) DataValue {
+	if n.id == CurrentTimeNode {
+		now := time.Now()
+		return DataValue{Value: Variant{TypeDateTime, now.UTC()}, SourceTimestamp: now, ServerTimestamp: now}
+	}
+	return n.value
+}
+
+// read 调用方持有 mu
+func (s *Server) read(rv ReadValueID, timestamps uint32) DataValue {
+	n := s.nodes[rv.NodeID]
+	if n == nil {
+		return DataValue{Status: StatusBadNodeIDUnknown}
+	}
+	var v Variant
+	switch rv.AttributeID {
+	case AttrNodeID:
+		v = Variant{TypeNodeID, n.id}
+	case AttrNodeClass:
+		v = Variant{TypeInt32, int32(n.class)}
+	case AttrBrowseName:
+		v = Variant{TypeQualifiedName, n.browseName}
+	case AttrDisplayName:
+		v = Variant{TypeLocalizedText, n.displayName}
+	case AttrDescription:
+		v = Variant{TypeLocalizedText, n.description}
+	case AttrWriteMask, AttrUserWriteMask:
+		v = Variant{TypeUInt32, uint32(0)}
+	case AttrEventNotifier:
+		if n.class != NodeClassObject {
+			return DataValue{Status: StatusBadAttributeIDInvalid}
+		}
+		v = Variant{TypeByte, byte(0)}
+	case AttrValue:
+		if n.class != NodeClassVariable {
+			return DataValue{Status: StatusBadAttributeIDInvalid}
+		}
+		dv := s.currentValue(n)
+		switch timestamps {
+		case 0:
+			dv.ServerTimestamp = time.Time{}
+		case 1:
+			dv.SourceTimestamp = time.Time{}
+		case 3:
+			dv.SourceTimestamp, dv.ServerTimestamp = time.Time{}, time.Time{}
+		}
+		return dv
+	case AttrDataType, AttrValueRank, AttrAccessLevel, AttrUserAccessLevel, AttrMinimumSamplingInterval, AttrHistorizing:
+		if n.class != NodeClassVariable {
+			return DataValue{Status: StatusBadAttributeIDInvalid}
+		}
+		switch rv.AttributeID {
+		case AttrDataType:
+			v = Variant{TypeNodeID, n.dataType}
+		case AttrValueRank:
+			v = Variant{TypeInt32, n.valueRank}
+		case AttrAccessLevel, AttrUserAccessLevel:
+			v = Variant{TypeByte, n.access}
+		case AttrMinimumSamplingInterval:
+			v = Variant{TypeDouble, float64(0)}
+		default:
+			v = Variant{TypeBoolean, false}
+		}
+	default:
+		return DataValue{Status: StatusBadAttributeIDInvalid}
+	}
+	return DataValue{Value: v}
+}
+
+// write 只支持 Value 属性，类型须与节点数据类型一致；结果由节点回调决定
+func (s *Server) write(wv WriteValue) StatusCode {
+	s.mu.Lock()
+	n := s.nodes[wv.NodeID]
+	var onWrite func(Variant) StatusCode
+	var code StatusCode
+	switch {
+	case n == nil:
+		code = StatusBadNodeIDUnknown
+	case wv.AttributeID != AttrValue:
+		code = StatusBadNotWritable
+	case n.access&accessWrite == 0 || n.onWrite == nil:
+		code = StatusBadNotWritable
+	case wv.IndexRange != "" || wv.Value.Value.IsArray():
+		code = StatusBadTypeMismatch
+	case n.dataType != NewNumericNodeID(0, uint32(wv.Value.Value.Type)):
+		code = StatusBadTypeMismatch
+	default:
+		onWrite = n.onWrite
+	}
+	s.mu.Unlock()
+	if onWrite == nil {
+		return code
+	}
+	return onWrite(wv.Value.Value)
+}
+
+// revise 修正发布参数
+func (sub *serverSubscription) revise(interval float64, keepAlive, maxNotify uint32) {
+	sub.interval = max(time.Duration(interval*float64(time.Millisecond)), serverMinInterval)
+	sub.keepAlive = max(keepAlive, 1)
+	sub.maxNotify = maxNotify
+	sub.next = time.Now().Add(sub.interval)
+}
+
+// publishLoop 按各订阅的发布周期发送通知或保活，并清理超时会话
+func (s *Server) publishLoop() {
+	defer s.wg.Done()
+	t := time.NewTicker(serverTick)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-t.C:
+		}
+		now := time.Now()
+		s.mu.Lock()
+		for tok, sess := range s.sessions {
+			if now.Sub(sess.lastSeen) > sess.timeout && len(sess.publishQ) == 0 {
+				log.Printf("[OPCUA] 会话 %s 超时关闭", sess.id)
+				s.dropSession(tok, sess)
+				continue
+			}
+			s.publishSession(sess, now)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// publishSession 调用方持有 mu
+func (s *Server) publishSession(sess *serverSession, now time.Time) {
+	ids := make([]uint32, 0, len(sess.subs))
+	for id := range sess.subs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		sub := sess.subs[id]
+		if now.Before(sub.next) {
+			continue
+		}
+		sub.next = now.Add(sub.interval)
+		ready := sub.enabled && len(sub.queue) > 0
+		if !ready {
+			sub.idle++
+		}
+		if !ready && sub.started && sub.idle < sub.keepAlive {
+			continue
+		}
+		if len(sess.publishQ) == 0 {
+			// 无可用发布请求，保留队列到下个周期
+			continue
+		}
+		p := sess.publishQ[0]
+		sess.publishQ = sess.publishQ[1:]
+		resp := &PublishResponse{ResponseHeader: responseHeaderFor(p.req), SubscriptionID: id}
+		for range p.req.SubscriptionAcknowledgements {
+			resp.Results = append(resp.Results, StatusOK)
+		}
+		resp.NotificationMessage.PublishTime = now
+		resp.NotificationMessage.SequenceNumber = sub.seq + 1
+		if ready {
+			items := sub.queue
+			if sub.maxNotify > 0 && uint32(len(items)) > sub.maxNotify {
+				items, sub.queue = items[:sub.maxNotify], append([]MonitoredItemNotification(nil), items[sub.maxNotify:]...)
+				resp.MoreNotifications = true
+			} else {
+				sub.queue = nil
+			}
+			sub.seq++
+			resp.AvailableSequenceNumbers = []uint32{sub.seq}
+			resp.NotificationMessage.NotificationData = []ExtensionObject{NewExtensionObject(&DataChangeNotification{MonitoredItems: items})}
+		}
+		sub.idle = 0
+		sub.started = true
+		go p.reply(resp)
+	}
+}
@@ -0,0 +1,227 @@
+package opcua
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+func newTestServer(t *testing.T, cfg ServerConfig) *Server {
+	t.Helper()
+	cfg.Listen = "127.0.0.1:0"
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func dialServer(t *testing.T, s *Server, extra map[string]interface{}) *OPCUAAdapter {
+	t.Helper()
+	cfg := map[string]interface{}{"endpoint": s.EndpointURL(), "timeoutMs": 2000, "publishIntervalMs": 50}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	c, err := NewOPCUAClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+func TestServerAddressSpace(t *testing.T) {
+	s := newTestServer(t, ServerConfig{})
+	plant := NewStringNodeID(2, "Plant")
+	temp := NewStringNodeID(2, "Plant.Temp")
+	var mu sync.Mutex
+	var written []Variant
+	if err := s.AddFolder(ObjectsFolder, plant, "Plant"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddVariable(plant, Variable{
+		ID: temp, Name: "Temp", DataType: TypeDouble, Unit: "℃",
+		Value: DataValue{Value: Variant{TypeDouble, 21.5}, SourceTimestamp: time.Now()},
+		OnWrite: func(v Variant) StatusCode {
+			mu.Lock()
+			defer mu.Unlock()
+			written = append(written, v)
+			return StatusOK
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddVariable(plant, Variable{ID: NewStringNodeID(2, "Plant.Serial"), Name: "Serial", DataType: TypeUInt16}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddFolder(NewStringNodeID(2, "Missing"), NewStringNodeID(2, "X"), "X"); err == nil {
+		t.Error("folder under missing parent added")
+	}
+	if err := s.AddFolder(ObjectsFolder, plant, "Plant"); err == nil {
+		t.Error("duplicate node added")
+	}
+
+	c := dialServer(t, s, nil)
+	refs, err := c.Browse(ObjectsFolder)
+	if err != nil || len(refs) != 2 || refs[0].BrowseName.Name != "Server" || refs[1].NodeID.NodeID != plant {
+		t.Fatalf("browse objects = %+v, %v", refs, err)
+	}
+	refs, err = c.Browse(plant)
+	if err != nil || len(refs) != 2 || refs[0].BrowseName.String() != "2:Temp" || refs[0].NodeClass != NodeClassVariable {
+		t.Fatalf("browse plant = %+v, %v", refs, err)
+	}
+	refs, err = c.Browse(temp)
+	if err != nil || len(refs) != 1 || refs[0].BrowseName.Name != "EngineeringUnits" || refs[0].ReferenceTypeID != HasPropertyReference {
+		t.Fatalf("browse temp = %+v, %v", refs, err)
+	}
+	vals, err := c.ReadValues(temp, refs[0].NodeID.NodeID, NamespaceArrayNode, NewStringNodeID(2, "Plant.Serial"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals[0].Value.Value != 21.5 || vals[0].SourceTimestamp.IsZero() {
+		t.Errorf("temp = %+v", vals[0])
+	}
+	if eu, ok := vals[1].Value.Value.(ExtensionObject).Value.(*EUInformation); !ok || eu.DisplayName.Text != "℃" {
+		t.Errorf("engineering units = %+v", vals[1].Value)
+	}
+	if ns := vals[2].Value.Value.([]string); len(ns) != 3 || ns[2] != "urn:cycV2:gateway" {
+		t.Errorf("namespace array = %v", ns)
+	}
+	if vals[3].Value.Type != TypeNull {
+		t.Errorf("serial without initial value = %+v", vals[3])
+	}
+
+	// 读属性
+	resp, err := c.call(&ReadRequest{NodesToRead: []ReadValueID{
+		{NodeID: temp, AttributeID: AttrDataType},
+		{NodeID: temp, AttributeID: AttrAccessLevel},
+		{NodeID: NewStringNodeID(2, "Plant.Serial"), AttributeID: AttrAccessLevel},
+		{NodeID: plant, AttributeID: AttrValue},
+		{NodeID: plant, AttributeID: AttrDisplayName},
+	}}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.(*ReadResponse).Results
+	if res[0].Value.Value != NewNumericNodeID(0, uint32(TypeDouble)) || res[1].Value.Value != byte(3) || res[2].Value.Value != byte(1) {
+		t.Errorf("attributes = %+v", res[:3])
+	}
+	if res[3].Status != StatusBadAttributeIDInvalid || res[4].Value.Value.(LocalizedText).Text != "Plant" {
+		t.Errorf("folder attributes = %+v", res[3:])
+	}
+
+	// 写入交由回调
+	if err := c.WriteValue(temp, 30.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteValue(temp, int32(1)); !errors.Is(err, StatusBadTypeMismatch) {
+		t.Errorf("write mismatched type = %v", err)
+	}
+	if err := c.WriteValue(NewStringNodeID(2, "Plant.Serial"), uint16(1)); !errors.Is(err, StatusBadNotWritable) {
+		t.Errorf("write read-only = %v", err)
+	}
+	mu.Lock()
+	if len(written) != 1 || written[0].Value != 30.0 {
+		t.Errorf("written = %v", written)
+	}
+	mu.Unlock()
+
+	// 订阅：初值 + 变化，值不变不通知
+	got := make(chan protocol.PointUpdate, 10)
+	cancel, err := c.Subscribe(map[string]interface{}{"nodeId": "ns=2;s=Plant.Temp"}, func(u protocol.PointUpdate) { got <- u })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	next := func() protocol.PointUpdate {
+		t.Helper()
+		select {
+		case u := <-got:
+			return u
+		case <-time.After(3 * time.Second):
+			t.Fatal("no data change")
+		}
+		return protocol.PointUpdate{}
+	}
+	if u := next(); u.Err != nil || len(u.Bytes) != 8 {
+		t.Fatalf("initial update = %+v", u)
+	}
+	s.SetValue(temp, DataValue{Value: Variant{TypeDouble, 21.5}, SourceTimestamp: time.Now()})
+	s.SetValue(temp, DataValue{Value: Variant{TypeDouble, 22.0}, SourceTimestamp: time.Now()})
+	if u := next(); u.Err != nil {
+		t.Fatalf("update = %+v", u)
+	}
+	if dv, _ := s.Value(temp); dv.Value.Value != 22.0 {
+		t.Errorf("server value = %+v", dv)
+	}
+	select {
+	case u := <-got:
+		t.Errorf("unexpected update %+v", u)
+	case <-time.After(200 * time.Millisecond):
+	}
+	s.SetValue(temp, DataValue{Status: StatusBadCommunicationError})
+	if u := next(); !errors.Is(u.Err, StatusBadCommunicationError) {
+		t.Errorf("bad quality update = %+v", u)
+	}
+
+	// 删除节点：子节点一并删除，监视项收到 BadNodeIdUnknown
+	if err := s.RemoveNode(plant); err != nil {
+		t.Fatal(err)
+	}
+	if u := next(); !errors.Is(u.Err, StatusBadNodeIDUnknown) {
+		t.Errorf("removed node update = %+v", u)
+	}
+	if s.HasNode(temp) || s.HasNode(NewStringNodeID(2, "Plant.Temp.EngineeringUnits")) {
+		t.Error("children not removed")
+	}
+	if refs, _ := c.Browse(ObjectsFolder); len(refs) != 1 {
+		t.Errorf("objects after remove = %+v", refs)
+	}
+	if err := s.RemoveNode(ObjectsFolder); err == nil {
+		t.Error("standard node removed")
+	}
+}
+
+func TestServerSecurity(t *testing.T) {
+	s := newTestServer(t, ServerConfig{
+		SecurityPolicies: []string{"Basic256Sha256"},
+		Users:            map[string]string{"operator": "secret"},
+	})
+	for _, mode := range []string{"Sign", "SignAndEncrypt"} {
+		c := dialServer(t, s, map[string]interface{}{"securityPolicy": "Basic256Sha256", "securityMode": mode, "username": "operator", "password": "secret"})
+		if vals, err := c.ReadValues(CurrentTimeNode); err != nil || vals[0].Value.Type != TypeDateTime {
+			t.Errorf("%s read = %+v, %v", mode, vals, err)
+		}
+	}
+
+	connect := func(cfg map[string]interface{}) error {
+		cfg["endpoint"] = s.EndpointURL()
+		cfg["timeoutMs"] = 2000
+		c, err := NewOPCUAClient(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Disconnect()
+		return c.Connect()
+	}
+	// None 通道只用于端点发现
+	if err := connect(map[string]interface{}{"username": "operator", "password": "secret"}); err == nil {
+		t.Error("session on None channel accepted")
+	}
+	if err := connect(map[string]interface{}{"securityPolicy": "Basic256Sha256"}); !errors.Is(err, StatusBadIdentityTokenRejected) {
+		t.Errorf("anonymous = %v", err)
+	}
+	if err := connect(map[string]interface{}{"securityPolicy": "Basic256Sha256", "username": "operator", "password": "wrong"}); !errors.Is(err, StatusBadIdentityTokenRejected) {
+		t.Errorf("wrong password = %v", err)
+	}
+}
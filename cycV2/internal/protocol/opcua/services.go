@@ -0,0 +1,637 @@
+package opcua
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// 服务请求/响应结构，字段顺序即二进制编码顺序（OPC UA Part 4/6），由 encode.go 反射编解码
+
+// MessageSecurityMode 消息安全模式
+type MessageSecurityMode uint32
+
+const (
+	SecurityModeInvalid MessageSecurityMode = iota
+	SecurityModeNone
+	SecurityModeSign
+	SecurityModeSignAndEncrypt
+)
+
+func (m MessageSecurityMode) String() string {
+	switch m {
+	case SecurityModeNone:
+		return "None"
+	case SecurityModeSign:
+		return "Sign"
+	case SecurityModeSignAndEncrypt:
+		return "SignAndEncrypt"
+	default:
+		return "Invalid"
+	}
+}
+
+// 属性ID
+const (
+	AttrNodeID                  uint32 = 1
+	AttrNodeClass               uint32 = 2
+	AttrBrowseName              uint32 = 3
+	AttrDisplayName             uint32 = 4
+	AttrDescription             uint32 = 5
+	AttrWriteMask               uint32 = 6
+	AttrUserWriteMask           uint32 = 7
+	AttrEventNotifier           uint32 = 12
+	AttrValue                   uint32 = 13
+	AttrDataType                uint32 = 14
+	AttrValueRank               uint32 = 15
+	AttrAccessLevel             uint32 = 17
+	AttrUserAccessLevel         uint32 = 18
+	AttrMinimumSamplingInterval uint32 = 19
+	AttrHistorizing             uint32 = 20
+)
+
+// 节点类别
+const (
+	NodeClassObject   uint32 = 1
+	NodeClassVariable uint32 = 2
+	NodeClassMethod   uint32 = 4
+)
+
+// 浏览方向
+const (
+	BrowseForward uint32 = iota
+	BrowseInverse
+	BrowseBoth
+)
+
+// 标准节点与引用类型
+var (
+	ObjectsFolder         = NewNumericNodeID(0, 85)
+	ServerNode            = NewNumericNodeID(0, 2253)
+	HierarchicalReference = NewNumericNodeID(0, 33)
+	OrganizesReference    = NewNumericNodeID(0, 35)
+	HasComponentReference = NewNumericNodeID(0, 47)
+	HasPropertyReference  = NewNumericNodeID(0, 46)
+	HasTypeDefinition     = NewNumericNodeID(0, 40)
+	BaseObjectType        = NewNumericNodeID(0, 58)
+	BaseDataVariableType  = NewNumericNodeID(0, 63)
+	PropertyType          = NewNumericNodeID(0, 68)
+	FolderType            = NewNumericNodeID(0, 61)
+)
+
+// 用户令牌类型
+const (
+	UserTokenAnonymous uint32 = iota
+	UserTokenUserName
+	UserTokenCertificate
+	UserTokenIssued
+)
+
+type RequestHeader struct {
+	AuthenticationToken NodeID
+	Timestamp           time.Time
+	RequestHandle       uint32
+	ReturnDiagnostics   uint32
+	AuditEntryID        string
+	TimeoutHint         uint32
+	AdditionalHeader    ExtensionObject
+}
+
+type ResponseHeader struct {
+	Timestamp          time.Time
+	RequestHandle      uint32
+	ServiceResult      StatusCode
+	ServiceDiagnostics DiagnosticInfo
+	StringTable        []string
+	AdditionalHeader   ExtensionObject
+}
+
+type ServiceFault struct {
+	ResponseHeader ResponseHeader
+}
+
+type ChannelSecurityToken struct {
+	ChannelID       uint32
+	TokenID         uint32
+	CreatedAt       time.Time
+	RevisedLifetime uint32
+}
+
+type OpenSecureChannelRequest struct {
+	RequestHeader         RequestHeader
+	ClientProtocolVersion uint32
+	RequestType           uint32 // 0 新建，1 续期
+	SecurityMode          MessageSecurityMode
+	ClientNonce           []byte
+	RequestedLifetime     uint32
+}
+
+type OpenSecureChannelResponse struct {
+	ResponseHeader        ResponseHeader
+	ServerProtocolVersion uint32
+	SecurityToken         ChannelSecurityToken
+	ServerNonce           []byte
+}
+
+type CloseSecureChannelRequest struct {
+	RequestHeader RequestHeader
+}
+
+type CloseSecureChannelResponse struct {
+	ResponseHeader ResponseHeader
+}
+
+type ApplicationDescription struct {
+	ApplicationURI      string
+	ProductURI          string
+	ApplicationName     LocalizedText
+	ApplicationType     uint32 // 0 服务端，1 客户端
+	GatewayServerURI    string
+	DiscoveryProfileURI string
+	DiscoveryURLs       []string
+}
+
+type UserTokenPolicy struct {
+	PolicyID          string
+	TokenType         uint32
+	IssuedTokenType   string
+	IssuerEndpointURL string
+	SecurityPolicyURI string
+}
+
+type EndpointDescription struct {
+	EndpointURL         string
+	Server              ApplicationDescription
+	ServerCertificate   []byte
+	SecurityMode        MessageSecurityMode
+	SecurityPolicyURI   string
+	UserIdentityTokens  []UserTokenPolicy
+	TransportProfileURI string
+	SecurityLevel       uint8
+}
+
+type GetEndpointsRequest struct {
+	RequestHeader RequestHeader
+	EndpointURL   string
+	LocaleIDs     []string
+	ProfileURIs   []string
+}
+
+type GetEndpointsResponse struct {
+	ResponseHeader ResponseHeader
+	Endpoints      []EndpointDescription
+}
+
+type SignedSoftwareCertificate struct {
+	CertificateData []byte
+	Signature       []byte
+}
+
+type SignatureData struct {
+	Algorithm string
+	Signature []byte
+}
+
+type CreateSessionRequest struct {
+	RequestHeader           RequestHeader
+	ClientDescription       ApplicationDescription
+	ServerURI               string
+	EndpointURL             string
+	SessionName             string
+	ClientNonce             []byte
+	ClientCertificate       []byte
+	RequestedSessionTimeout float64
+	MaxResponseMessageSize  uint32
+}
+
+type CreateSessionResponse struct {
+	ResponseHeader             ResponseHeader
+	SessionID                  NodeID
+	AuthenticationToken        NodeID
+	RevisedSessionTimeout      float64
+	ServerNonce                []byte
+	ServerCertificate          []byte
+	ServerEndpoints            []EndpointDescription
+	ServerSoftwareCertificates []SignedSoftwareCertificate
+	ServerSignature            SignatureData
+	MaxRequestMessageSize      uint32
+}
+
+type AnonymousIdentityToken struct {
+	PolicyID string
+}
+
+type UserNameIdentityToken struct {
+	PolicyID            string
+	UserName            string
+	Password            []byte
+	EncryptionAlgorithm string
+}
+
+type ActivateSessionRequest struct {
+	RequestHeader              RequestHeader
+	ClientSignature            SignatureData
+	ClientSoftwareCertificates []SignedSoftwareCertificate
+	LocaleIDs                  []string
+	UserIdentityToken          ExtensionObject
+	UserTokenSignature         SignatureData
+}
+
+type ActivateSessionResponse struct {
+	ResponseHeader  ResponseHeader
+	ServerNonce     []byte
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type CloseSessionRequest struct {
+	RequestHeader       RequestHeader
+	DeleteSubscriptions bool
+}
+
+type CloseSessionResponse struct {
+	ResponseHeader ResponseHeader
+}
+
+type ViewDescription struct {
+	ViewID      NodeID
+	Timestamp   time.Time
+	ViewVersion uint32
+}
+
+type BrowseDescription struct {
+	NodeID          NodeID
+	BrowseDirection uint32
+	ReferenceTypeID NodeID
+	IncludeSubtypes bool
+	NodeClassMask   uint32
+	ResultMask      uint32
+}
+
+type ReferenceDescription struct {
+	ReferenceTypeID NodeID
+	IsForward       bool
+	NodeID          ExpandedNodeID
+	BrowseName      QualifiedName
+	DisplayName     LocalizedText
+	NodeClass       uint32
+	TypeDefinition  ExpandedNodeID
+}
+
+type BrowseResult struct {
+	StatusCode        StatusCode
+	ContinuationPoint []byte
+	References        []ReferenceDescription
+}
+
+type BrowseRequest struct {
+	RequestHeader                 RequestHeader
+	View                          ViewDescription
+	RequestedMaxReferencesPerNode uint32
+	NodesToBrowse                 []BrowseDescription
+}
+
+type BrowseResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []BrowseResult
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type BrowseNextRequest struct {
+	RequestHeader             RequestHeader
+	ReleaseContinuationPoints bool
+	ContinuationPoints        [][]byte
+}
+
+type BrowseNextResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []BrowseResult
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type ReadValueID struct {
+	NodeID       NodeID
+	AttributeID  uint32
+	IndexRange   string
+	DataEncoding QualifiedName
+}
+
+type ReadRequest struct {
+	RequestHeader      RequestHeader
+	MaxAge             float64
+	TimestampsToReturn uint32 // 0 源，1 服务器，2 两者，3 不返回
+	NodesToRead        []ReadValueID
+}
+
+type ReadResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []DataValue
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type WriteValue struct {
+	NodeID      NodeID
+	AttributeID uint32
+	IndexRange  string
+	Value       DataValue
+}
+
+type WriteRequest struct {
+	RequestHeader RequestHeader
+	NodesToWrite  []WriteValue
+}
+
+type WriteResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type CreateSubscriptionRequest struct {
+	RequestHeader               RequestHeader
+	RequestedPublishingInterval float64
+	RequestedLifetimeCount      uint32
+	RequestedMaxKeepAliveCount  uint32
+	MaxNotificationsPerPublish  uint32
+	PublishingEnabled           bool
+	Priority                    uint8
+}
+
+type CreateSubscriptionResponse struct {
+	ResponseHeader            ResponseHeader
+	SubscriptionID            uint32
+	RevisedPublishingInterval float64
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+}
+
+type DeleteSubscriptionsRequest struct {
+	RequestHeader   RequestHeader
+	SubscriptionIDs []uint32
+}
+
+type DeleteSubscriptionsResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type MonitoringParameters struct {
+	ClientHandle     uint32
+	SamplingInterval float64
+	Filter           ExtensionObject
+	QueueSize        uint32
+	DiscardOldest    bool
+}
+
+type MonitoredItemCreateRequest struct {
+	ItemToMonitor       ReadValueID
+	MonitoringMode      uint32 // 2 报告
+	RequestedParameters MonitoringParameters
+}
+
+type MonitoredItemCreateResult struct {
+	StatusCode              StatusCode
+	MonitoredItemID         uint32
+	RevisedSamplingInterval float64
+	RevisedQueueSize        uint32
+	FilterResult            ExtensionObject
+}
+
+type CreateMonitoredItemsRequest struct {
+	RequestHeader      RequestHeader
+	SubscriptionID     uint32
+	TimestampsToReturn uint32
+	ItemsToCreate      []MonitoredItemCreateRequest
+}
+
+type CreateMonitoredItemsResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []MonitoredItemCreateResult
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type DeleteMonitoredItemsRequest struct {
+	RequestHeader    RequestHeader
+	SubscriptionID   uint32
+	MonitoredItemIDs []uint32
+}
+
+type DeleteMonitoredItemsResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type SubscriptionAcknowledgement struct {
+	SubscriptionID uint32
+	SequenceNumber uint32
+}
+
+type NotificationMessage struct {
+	SequenceNumber   uint32
+	PublishTime      time.Time
+	NotificationData []ExtensionObject
+}
+
+type PublishRequest struct {
+	RequestHeader                RequestHeader
+	SubscriptionAcknowledgements []SubscriptionAcknowledgement
+}
+
+type PublishResponse struct {
+	ResponseHeader           ResponseHeader
+	SubscriptionID           uint32
+	AvailableSequenceNumbers []uint32
+	MoreNotifications        bool
+	NotificationMessage      NotificationMessage
+	Results                  []StatusCode
+	DiagnosticInfos          []DiagnosticInfo
+}
+
+type FindServersRequest struct {
+	RequestHeader RequestHeader
+	EndpointURL   string
+	LocaleIDs     []string
+	ServerURIs    []string
+}
+
+type FindServersResponse struct {
+	ResponseHeader ResponseHeader
+	Servers        []ApplicationDescription
+}
+
+type ModifySubscriptionRequest struct {
+	RequestHeader               RequestHeader
+	SubscriptionID              uint32
+	RequestedPublishingInterval float64
+	RequestedLifetimeCount      uint32
+	RequestedMaxKeepAliveCount  uint32
+	MaxNotificationsPerPublish  uint32
+	Priority                    uint8
+}
+
+type ModifySubscriptionResponse struct {
+	ResponseHeader            ResponseHeader
+	RevisedPublishingInterval float64
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+}
+
+type SetPublishingModeRequest struct {
+	RequestHeader     RequestHeader
+	PublishingEnabled bool
+	SubscriptionIDs   []uint32
+}
+
+type SetPublishingModeResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type SetMonitoringModeRequest struct {
+	RequestHeader    RequestHeader
+	SubscriptionID   uint32
+	MonitoringMode   uint32
+	MonitoredItemIDs []uint32
+}
+
+type SetMonitoringModeResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type RepublishRequest struct {
+	RequestHeader            RequestHeader
+	SubscriptionID           uint32
+	RetransmitSequenceNumber uint32
+}
+
+type RepublishResponse struct {
+	ResponseHeader      ResponseHeader
+	NotificationMessage NotificationMessage
+}
+
+// EUInformation 工程单位（EngineeringUnits 属性值）
+type EUInformation struct {
+	NamespaceURI string
+	UnitID       int32
+	DisplayName  LocalizedText
+	Description  LocalizedText
+}
+
+type MonitoredItemNotification struct {
+	ClientHandle uint32
+	Value        DataValue
+}
+
+type DataChangeNotification struct {
+	MonitoredItems  []MonitoredItemNotification
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type StatusChangeNotification struct {
+	Status         StatusCode
+	DiagnosticInfo DiagnosticInfo
+}
+
+// 二进制编码节点号（DefaultBinary）
+func init() {
+	for id, v := range map[uint32]interface{}{
+		321: AnonymousIdentityToken{},
+		324: UserNameIdentityToken{},
+		397: ServiceFault{},
+		422: FindServersRequest{},
+		425: FindServersResponse{},
+		428: GetEndpointsRequest{},
+		431: GetEndpointsResponse{},
+		446: OpenSecureChannelRequest{},
+		449: OpenSecureChannelResponse{},
+		452: CloseSecureChannelRequest{},
+		455: CloseSecureChannelResponse{},
+		461: CreateSessionRequest{},
+		464: CreateSessionResponse{},
+		467: ActivateSessionRequest{},
+		470: ActivateSessionResponse{},
+		473: CloseSessionRequest{},
+		476: CloseSessionResponse{},
+		527: BrowseRequest{},
+		530: BrowseResponse{},
+		533: BrowseNextRequest{},
+		536: BrowseNextResponse{},
+		631: ReadRequest{},
+		634: ReadResponse{},
+		673: WriteRequest{},
+		676: WriteResponse{},
+		751: CreateMonitoredItemsRequest{},
+		754: CreateMonitoredItemsResponse{},
+		781: DeleteMonitoredItemsRequest{},
+		784: DeleteMonitoredItemsResponse{},
+		787: CreateSubscriptionRequest{},
+		790: CreateSubscriptionResponse{},
+		769: SetMonitoringModeRequest{},
+		772: SetMonitoringModeResponse{},
+		793: ModifySubscriptionRequest{},
+		796: ModifySubscriptionResponse{},
+		799: SetPublishingModeRequest{},
+		802: SetPublishingModeResponse{},
+		811: DataChangeNotification{},
+		889: EUInformation{},
+		820: StatusChangeNotification{},
+		826: PublishRequest{},
+		829: PublishResponse{},
+		832: RepublishRequest{},
+		835: RepublishResponse{},
+		847: DeleteSubscriptionsRequest{},
+		850: DeleteSubscriptionsResponse{},
+	} {
+		registerExt(id, v)
+	}
+}
+
+// encodeService 服务消息体：类型节点 + 结构体
+func encodeService(v interface{}) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	id, ok := extIDs[t]
+	if !ok {
+		return nil, fmt.Errorf("opcua: unknown service type %s", t)
+	}
+	e := &encoder{}
+	NewNumericNodeID(0, id).encodeUA(e)
+	e.value(reflect.ValueOf(v))
+	return e.b, nil
+}
+
+// decodeService 按类型节点解码服务消息，返回结构体指针
+func decodeService(b []byte) (interface{}, error) {
+	d := &decoder{b: b}
+	var id NodeID
+	id.decodeUA(d)
+	if d.err != nil {
+		return nil, d.err
+	}
+	t, ok := extTypes[id.Numeric]
+	if !ok || id.Namespace != 0 || id.Type != IDNumeric {
+		return nil, fmt.Errorf("opcua: unknown service %s", id)
+	}
+	v := reflect.New(t)
+	if err := decode(d.b, v.Interface()); err != nil {
+		return nil, fmt.Errorf("opcua: decode %s: %w", t.Name(), err)
+	}
+	return v.Interface(), nil
+}
+
+// requestHeader 请求结构首字段均为 RequestHeader
+func requestHeader(req interface{}) *RequestHeader {
+	return reflect.ValueOf(req).Elem().Field(0).Addr().Interface().(*RequestHeader)
+}
+
+// responseHeader 响应结构首字段均为 ResponseHeader
+func responseHeader(resp interface{}) *ResponseHeader {
+	return reflect.ValueOf(resp).Elem().Field(0).Addr().Interface().(*ResponseHeader)
+}
@@ -0,0 +1,374 @@
+package opcua
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// clientConn 客户端安全通道：请求按 requestId 匹配响应，令牌到期前自动续期
+type clientConn struct {
+	ch      *secureChannel
+	timeout time.Duration
+
+	mu        sync.Mutex
+	nextID    uint32
+	handle    uint32
+	pending   map[uint32]chan *message
+	authToken NodeID
+	lifetime  time.Duration
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+// channelOptions 建立安全通道所需的参数
+type channelOptions struct {
+	endpoint   string
+	addr       string
+	policy     string
+	mode       MessageSecurityMode
+	localCert  []byte
+	localKey   *rsa.PrivateKey
+	remoteCert []byte
+	timeout    time.Duration
+}
+
+// dialChannel 建立TCP连接，完成 HEL/ACK 与 OpenSecureChannel
+func dialChannel(o channelOptions) (*clientConn, error) {
+	conn, err := net.DialTimeout("tcp", o.addr, o.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("opcua: dial %s: %w", o.addr, err)
+	}
+	ch := newSecureChannel(conn, false)
+	ch.policy, ch.mode = o.policy, o.mode
+	if ch.secure() {
+		key, err := parseCertKey(o.remoteCert)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		ch.localCert, ch.localKey, ch.remoteCert, ch.remoteKey = o.localCert, o.localKey, o.remoteCert, key
+	}
+	if err := ch.hello(o.endpoint, o.timeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &clientConn{ch: ch, timeout: o.timeout, pending: make(map[uint32]chan *message), done: make(chan struct{})}
+	go c.recvLoop()
+	if err := c.openChannel(0); err != nil {
+		c.close(err)
+		return nil, err
+	}
+	go c.renewLoop()
+	return c, nil
+}
+
+// openChannel 新建（0）或续期（1）安全令牌
+func (c *clientConn) openChannel(requestType uint32) error {
+	nonce := c.ch.newNonce()
+	resp, err := c.call("OPN", &OpenSecureChannelRequest{
+		RequestType:       requestType,
+		SecurityMode:      c.ch.mode,
+		ClientNonce:       nonce,
+		RequestedLifetime: 3600000,
+	}, c.timeout)
+	if err != nil {
+		return err
+	}
+	r, ok := resp.(*OpenSecureChannelResponse)
+	if !ok {
+		return fmt.Errorf("opcua: unexpected %T to OpenSecureChannel", resp)
+	}
+	if err := c.ch.installToken(r.SecurityToken, nonce, r.ServerNonce); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.lifetime = time.Duration(r.SecurityToken.RevisedLifetime) * time.Millisecond
+	c.mu.Unlock()
+	return nil
+}
+
+// renewLoop 令牌生命期的75%时续期
+func (c *clientConn) renewLoop() {
+	for {
+		c.mu.Lock()
+		d := c.lifetime * 3 / 4
+		c.mu.Unlock()
+		if d < time.Second {
+			d = time.Second
+		}
+		select {
+		case <-c.done:
+			return
+		case <-time.After(d):
+		}
+		if err := c.openChannel(1); err != nil {
+			log.Printf("[OPCUA] 安全令牌续期失败: %v", err)
+			c.close(err)
+			return
+		}
+	}
+}
+
+func (c *clientConn) recvLoop() {
+	for {
+		msg, err := c.ch.receive()
+		if err != nil {
+			c.close(err)
+			return
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[msg.requestID]
+		delete(c.pending, msg.requestID)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+// call 发送请求并等待响应；ServiceFault 与坏的 ServiceResult 以 StatusCode 错误返回
+func (c *clientConn) call(typ string, req interface{}, timeout time.Duration) (interface{}, error) {
+	c.mu.Lock()
+	c.nextID++
+	c.handle++
+	id := c.nextID
+	h := requestHeader(req)
+	h.AuthenticationToken = c.authToken
+	h.Timestamp = time.Now()
+	h.RequestHandle = c.handle
+	h.TimeoutHint = uint32(timeout / time.Millisecond)
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	body, err := encodeService(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ch.send(typ, id, body); err != nil {
+		c.close(err)
+		return nil, err
+	}
+	var msg *message
+	select {
+	case msg = <-ch:
+	case <-c.done:
+		return nil, c.closeErr()
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("opcua: %T timeout", req)
+	}
+	resp, err := decodeService(msg.body)
+	if err != nil {
+		return nil, err
+	}
+	if fault, ok := resp.(*ServiceFault); ok {
+		return nil, fault.ResponseHeader.ServiceResult
+	}
+	if res := responseHeader(resp).ServiceResult; res.IsBad() {
+		return resp, res
+	}
+	return resp, nil
+}
+
+func (c *clientConn) setAuthToken(tok NodeID) {
+	c.mu.Lock()
+	c.authToken = tok
+	c.mu.Unlock()
+}
+
+func (c *clientConn) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return errChannelClosed
+	}
+	return c.err
+}
+
+func (c *clientConn) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *clientConn) close(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		close(c.done)
+		c.ch.close()
+	})
+}
+
+// shutdown 发送 CloseSecureChannel 后断开
+func (c *clientConn) shutdown() {
+	if c.alive() {
+		if body, err := encodeService(&CloseSecureChannelRequest{}); err == nil {
+			c.ch.send("CLO", 0, body)
+		}
+	}
+	c.close(errChannelClosed)
+}
+
+// getEndpoints 通过无安全通道查询服务器端点
+func getEndpoints(endpoint, addr string, timeout time.Duration) ([]EndpointDescription, error) {
+	c, err := dialChannel(channelOptions{endpoint: endpoint, addr: addr, policy: PolicyNone, mode: SecurityModeNone, timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	defer c.shutdown()
+	resp, err := c.call("MSG", &GetEndpointsRequest{EndpointURL: endpoint, ProfileURIs: []string{transportBinary}}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*GetEndpointsResponse).Endpoints, nil
+}
+
+// sessionOptions 会话参数
+type sessionOptions struct {
+	endpoint       string
+	appURI         string
+	name           string
+	timeout        time.Duration
+	username       string
+	password       string
+	localCert      []byte
+	localKey       *rsa.PrivateKey
+	sessionTimeout time.Duration
+}
+
+// session 已激活的会话
+type session struct {
+	id        NodeID
+	authToken NodeID
+	timeout   time.Duration
+}
+
+// createSession 创建并激活会话；安全通道非 None 时校验服务器签名并对服务器随机数签名
+func (c *clientConn) createSession(o sessionOptions) (*session, error) {
+	nonce := make([]byte, nonceLen)
+	rand.Read(nonce)
+	req := &CreateSessionRequest{
+		ClientDescription: ApplicationDescription{
+			ApplicationURI:  o.appURI,
+			ProductURI:      "urn:cycV2",
+			ApplicationName: LocalizedText{Text: "cycV2"},
+			ApplicationType: 1,
+		},
+		EndpointURL:             o.endpoint,
+		SessionName:             o.name,
+		ClientNonce:             nonce,
+		RequestedSessionTimeout: float64(o.sessionTimeout / time.Millisecond),
+		MaxResponseMessageSize:  maxMessageSize,
+	}
+	secure := c.ch.secure()
+	if secure {
+		req.ClientCertificate = o.localCert
+	}
+	resp, err := c.call("MSG", req, o.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("opcua: CreateSession: %w", err)
+	}
+	cs := resp.(*CreateSessionResponse)
+	if secure {
+		if string(cs.ServerCertificate) != string(c.ch.remoteCert) {
+			return nil, errors.New("opcua: session certificate differs from channel certificate")
+		}
+		if err := rsaVerify(c.ch.remoteKey, append(append([]byte(nil), o.localCert...), nonce...), cs.ServerSignature.Signature); err != nil {
+			return nil, errors.New("opcua: invalid server signature")
+		}
+	}
+	c.setAuthToken(cs.AuthenticationToken)
+
+	token, err := identityToken(o, cs, c.ch.policy, c.ch.mode)
+	if err != nil {
+		return nil, err
+	}
+	act := &ActivateSessionRequest{LocaleIDs: []string{"en"}, UserIdentityToken: token}
+	if secure {
+		sig, err := rsaSign(o.localKey, append(append([]byte(nil), cs.ServerCertificate...), cs.ServerNonce...))
+		if err != nil {
+			return nil, err
+		}
+		act.ClientSignature = SignatureData{Algorithm: algRsaSha256, Signature: sig}
+	}
+	if _, err := c.call("MSG", act, o.timeout); err != nil {
+		return nil, fmt.Errorf("opcua: ActivateSession: %w", err)
+	}
+	return &session{id: cs.SessionID, authToken: cs.AuthenticationToken, timeout: time.Duration(cs.RevisedSessionTimeout) * time.Millisecond}, nil
+}
+
+// identityToken 按服务器端点声明的用户令牌策略构造匿名或用户名令牌
+func identityToken(o sessionOptions, cs *CreateSessionResponse, policy string, mode MessageSecurityMode) (ExtensionObject, error) {
+	want := UserTokenAnonymous
+	if o.username != "" {
+		want = UserTokenUserName
+	}
+	var tp *UserTokenPolicy
+	for i := range cs.ServerEndpoints {
+		ep := &cs.ServerEndpoints[i]
+		if ep.SecurityPolicyURI != policy || ep.SecurityMode != mode {
+			continue
+		}
+		for j := range ep.UserIdentityTokens {
+			if ep.UserIdentityTokens[j].TokenType == want {
+				tp = &ep.UserIdentityTokens[j]
+				break
+			}
+		}
+	}
+	if tp == nil {
+		// 部分服务器不返回端点列表，使用惯用的策略ID
+		tp = &UserTokenPolicy{PolicyID: "anonymous", TokenType: want}
+		if want == UserTokenUserName {
+			tp.PolicyID = "username"
+		}
+	}
+	if want == UserTokenAnonymous {
+		return NewExtensionObject(&AnonymousIdentityToken{PolicyID: tp.PolicyID}), nil
+	}
+	tok := &UserNameIdentityToken{PolicyID: tp.PolicyID, UserName: o.username, Password: []byte(o.password)}
+	tokPolicy := tp.SecurityPolicyURI
+	if tokPolicy == "" {
+		tokPolicy = policy
+	}
+	if tokPolicy != PolicyNone {
+		key, err := parseCertKey(cs.ServerCertificate)
+		if err != nil {
+			return ExtensionObject{}, fmt.Errorf("opcua: encrypt password: %w", err)
+		}
+		plain := make([]byte, 4, 4+len(o.password)+len(cs.ServerNonce))
+		binary.LittleEndian.PutUint32(plain, uint32(len(o.password)+len(cs.ServerNonce)))
+		plain = append(append(plain, o.password...), cs.ServerNonce...)
+		enc, err := rsaEncrypt(key, plain)
+		if err != nil {
+			return ExtensionObject{}, err
+		}
+		tok.Password, tok.EncryptionAlgorithm = enc, algRsaOaep
+	}
+	return NewExtensionObject(tok), nil
+}
+
+// closeSession 关闭会话并删除其订阅
+func (c *clientConn) closeSession() {
+	if c.alive() {
+		c.call("MSG", &CloseSessionRequest{DeleteSubscriptions: true}, c.timeout)
+	}
+}
@@ -0,0 +1,133 @@
+package opcua
+
+import "fmt"
+
+// StatusCode 服务/值状态码，高两位 00 好、01 不确定、10 坏
+type StatusCode uint32
+
+const (
+	StatusOK                           StatusCode = 0
+	StatusUncertain                    StatusCode = 0x40000000
+	StatusBad                          StatusCode = 0x80000000
+	StatusBadUnexpectedError           StatusCode = 0x80010000
+	StatusBadInternalError             StatusCode = 0x80020000
+	StatusBadDecodingError             StatusCode = 0x80070000
+	StatusBadTimeout                   StatusCode = 0x800A0000
+	StatusBadServiceUnsupported        StatusCode = 0x800B0000
+	StatusBadShutdown                  StatusCode = 0x800C0000
+	StatusBadNothingToDo               StatusCode = 0x800F0000
+	StatusBadTooManyOperations         StatusCode = 0x80100000
+	StatusBadSecurityChecksFailed      StatusCode = 0x80130000
+	StatusBadCertificateInvalid        StatusCode = 0x80120000
+	StatusBadUserAccessDenied          StatusCode = 0x801F0000
+	StatusBadIdentityTokenInvalid      StatusCode = 0x80200000
+	StatusBadIdentityTokenRejected     StatusCode = 0x80210000
+	StatusBadSecureChannelIDInvalid    StatusCode = 0x80220000
+	StatusBadSessionIDInvalid          StatusCode = 0x80250000
+	StatusBadSessionClosed             StatusCode = 0x80260000
+	StatusBadSessionNotActivated       StatusCode = 0x80270000
+	StatusBadSubscriptionIDInvalid     StatusCode = 0x80280000
+	StatusBadRequestHeaderInvalid      StatusCode = 0x802A0000
+	StatusBadNodeIDInvalid             StatusCode = 0x80330000
+	StatusBadNodeIDUnknown             StatusCode = 0x80340000
+	StatusBadAttributeIDInvalid        StatusCode = 0x80350000
+	StatusBadNotReadable               StatusCode = 0x803A0000
+	StatusBadNotWritable               StatusCode = 0x803B0000
+	StatusBadOutOfRange                StatusCode = 0x803C0000
+	StatusBadNotSupported              StatusCode = 0x803D0000
+	StatusBadMonitoredItemIDInvalid    StatusCode = 0x80420000
+	StatusBadContinuationPointInvalid  StatusCode = 0x804A0000
+	StatusBadNoContinuationPoints      StatusCode = 0x804B0000
+	StatusBadSecurityModeRejected      StatusCode = 0x80540000
+	StatusBadSecurityPolicyRejected    StatusCode = 0x80550000
+	StatusBadTooManySessions           StatusCode = 0x80560000
+	StatusBadTypeMismatch              StatusCode = 0x80740000
+	StatusBadNoSubscription            StatusCode = 0x80790000
+	StatusBadSequenceNumberUnknown     StatusCode = 0x807A0000
+	StatusBadTcpMessageTypeInvalid     StatusCode = 0x807E0000
+	StatusBadTcpSecureChannelUnknown   StatusCode = 0x807F0000
+	StatusBadTcpMessageTooLarge        StatusCode = 0x80800000
+	StatusBadTcpEndpointURLInvalid     StatusCode = 0x80830000
+	StatusBadRequestTimeout            StatusCode = 0x80850000
+	StatusBadSecureChannelClosed       StatusCode = 0x80860000
+	StatusBadSecureChannelTokenUnknown StatusCode = 0x80870000
+	StatusBadSequenceNumberInvalid     StatusCode = 0x80880000
+	StatusBadCommunicationError        StatusCode = 0x80050000
+	StatusBadWaitingForInitialData     StatusCode = 0x80320000
+	StatusBadConnectionClosed          StatusCode = 0x80AE0000
+	StatusBadTooManyPublishRequests    StatusCode = 0x80780000
+	StatusBadNoData                    StatusCode = 0x809B0000
+	StatusBadMessageNotAvailable       StatusCode = 0x807B0000
+)
+
+var statusNames = map[StatusCode]string{
+	StatusOK:                           "Good",
+	StatusUncertain:                    "Uncertain",
+	StatusBad:                          "Bad",
+	StatusBadUnexpectedError:           "BadUnexpectedError",
+	StatusBadInternalError:             "BadInternalError",
+	StatusBadDecodingError:             "BadDecodingError",
+	StatusBadTimeout:                   "BadTimeout",
+	StatusBadServiceUnsupported:        "BadServiceUnsupported",
+	StatusBadShutdown:                  "BadShutdown",
+	StatusBadNothingToDo:               "BadNothingToDo",
+	StatusBadTooManyOperations:         "BadTooManyOperations",
+	StatusBadSecurityChecksFailed:      "BadSecurityChecksFailed",
+	StatusBadCertificateInvalid:        "BadCertificateInvalid",
+	StatusBadUserAccessDenied:          "BadUserAccessDenied",
+	StatusBadIdentityTokenInvalid:      "BadIdentityTokenInvalid",
+	StatusBadIdentityTokenRejected:     "BadIdentityTokenRejected",
+	StatusBadSecureChannelIDInvalid:    "BadSecureChannelIdInvalid",
+	StatusBadSessionIDInvalid:          "BadSessionIdInvalid",
+	StatusBadSessionClosed:             "BadSessionClosed",
+	StatusBadSessionNotActivated:       "BadSessionNotActivated",
+	StatusBadSubscriptionIDInvalid:     "BadSubscriptionIdInvalid",
+	StatusBadRequestHeaderInvalid:      "BadRequestHeaderInvalid",
+	StatusBadNodeIDInvalid:             "BadNodeIdInvalid",
+	StatusBadNodeIDUnknown:             "BadNodeIdUnknown",
+	StatusBadAttributeIDInvalid:        "BadAttributeIdInvalid",
+	StatusBadNotReadable:               "BadNotReadable",
+	StatusBadNotWritable:               "BadNotWritable",
+	StatusBadOutOfRange:                "BadOutOfRange",
+	StatusBadNotSupported:              "BadNotSupported",
+	StatusBadMonitoredItemIDInvalid:    "BadMonitoredItemIdInvalid",
+	StatusBadContinuationPointInvalid:  "BadContinuationPointInvalid",
+	StatusBadNoContinuationPoints:      "BadNoContinuationPoints",
+	StatusBadSecurityModeRejected:      "BadSecurityModeRejected",
+	StatusBadSecurityPolicyRejected:    "BadSecurityPolicyRejected",
+	StatusBadTooManySessions:           "BadTooManySessions",
+	StatusBadTypeMismatch:              "BadTypeMismatch",
+	StatusBadNoSubscription:            "BadNoSubscription",
+	StatusBadSequenceNumberUnknown:     "BadSequenceNumberUnknown",
+	StatusBadTcpMessageTypeInvalid:     "BadTcpMessageTypeInvalid",
+	StatusBadTcpSecureChannelUnknown:   "BadTcpSecureChannelUnknown",
+	StatusBadTcpMessageTooLarge:        "BadTcpMessageTooLarge",
+	StatusBadTcpEndpointURLInvalid:     "BadTcpEndpointUrlInvalid",
+	StatusBadRequestTimeout:            "BadRequestTimeout",
+	StatusBadSecureChannelClosed:       "BadSecureChannelClosed",
+	StatusBadSecureChannelTokenUnknown: "BadSecureChannelTokenUnknown",
+	StatusBadSequenceNumberInvalid:     "BadSequenceNumberInvalid",
+	StatusBadCommunicationError:        "BadCommunicationError",
+	StatusBadWaitingForInitialData:     "BadWaitingForInitialData",
+	StatusBadConnectionClosed:          "BadConnectionClosed",
+	StatusBadTooManyPublishRequests:    "BadTooManyPublishRequests",
+	StatusBadNoData:                    "BadNoData",
+	StatusBadMessageNotAvailable:       "BadMessageNotAvailable",
+}
+
+// IsBad 坏品质
+func (s StatusCode) IsBad() bool { return s&0xC0000000 == 0x80000000 }
+
+// IsUncertain 不确定品质
+func (s StatusCode) IsUncertain() bool { return s&0xC0000000 == 0x40000000 }
+
+func (s StatusCode) String() string {
+	// 低16位为信息位，按主码查找名称
+	if name, ok := statusNames[s&0xFFFF0000]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%08X", uint32(s))
+}
+
+// Error 使坏状态码可直接作为错误返回
+func (s StatusCode) Error() string { return "opcua: " + s.String() }
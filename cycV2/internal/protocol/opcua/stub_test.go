@@ -0,0 +1,479 @@
+package opcua
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubNode 测试地址空间节点
+type stubNode struct {
+	name     string
+	class    uint32
+	value    Variant
+	writable bool
+	children []NodeID
+}
+
+// stubServer 测试用 OPC UA 服务器：None 与 Basic256Sha256 端点、匿名/用户名登录、
+// 浏览（每页2条引用）、读写、订阅与监视项
+type stubServer struct {
+	t    *testing.T
+	ln   net.Listener
+	cert []byte
+	key  *rsa.PrivateKey
+
+	mu        sync.Mutex
+	nodes     map[NodeID]*stubNode
+	conns     []net.Conn
+	sessions  map[NodeID]*stubSession // 认证令牌 → 会话
+	nextID    uint32
+	writes    []WriteValue
+	policies  []string
+	lastToken interface{}
+}
+
+type stubSession struct {
+	nonce     []byte
+	clientKey *rsa.PublicKey
+	activated bool
+	subs      map[uint32]*stubSub
+}
+
+type stubSub struct {
+	interval time.Duration
+	items    map[uint32]MonitoredItemCreateRequest // 监视项ID → 请求
+	queue    []MonitoredItemNotification
+	seq      uint32
+}
+
+func newStubServer(t *testing.T) *stubServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, key, err := generateCertificate("urn:stub:server", "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubServer{t: t, ln: ln, cert: cert, key: key, sessions: make(map[NodeID]*stubSession)}
+	s.nodes = map[NodeID]*stubNode{
+		ObjectsFolder:                     {name: "Objects", class: NodeClassObject, children: []NodeID{NewStringNodeID(2, "Plant")}},
+		NewStringNodeID(2, "Plant"):       {name: "Plant", class: NodeClassObject},
+		NewStringNodeID(2, "Plant.Temp"):  {name: "Temp", class: NodeClassVariable, value: Variant{TypeDouble, 21.5}, writable: true},
+		NewStringNodeID(2, "Plant.Count"): {name: "Count", class: NodeClassVariable, value: Variant{TypeInt32, int32(-7)}, writable: true},
+		NewStringNodeID(2, "Plant.Run"):   {name: "Run", class: NodeClassVariable, value: Variant{TypeBoolean, true}, writable: true},
+		NewStringNodeID(2, "Plant.Name"):  {name: "Name", class: NodeClassVariable, value: Variant{TypeString, "line-1"}, writable: true},
+		NewNumericNodeID(2, 1001):         {name: "Serial", class: NodeClassVariable, value: Variant{TypeUInt16, uint16(4660)}},
+		NewNumericNodeID(0, 2258):         {name: "CurrentTime", class: NodeClassVariable, value: Variant{TypeDateTime, time.Now().UTC()}},
+	}
+	plant := s.nodes[NewStringNodeID(2, "Plant")]
+	for _, n := range []string{"Plant.Temp", "Plant.Count", "Plant.Run", "Plant.Name"} {
+		plant.children = append(plant.children, NewStringNodeID(2, n))
+	}
+	plant.children = append(plant.children, NewNumericNodeID(2, 1001))
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		s.dropConns()
+	})
+	return s
+}
+
+func (s *stubServer) endpoint() string { return "opc.tcp://" + s.ln.Addr().String() + "/stub" }
+
+func (s *stubServer) dropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+// set 修改节点值，并为监视该节点的监视项排队通知
+func (s *stubServer) set(node NodeID, v Variant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[node].value = v
+	s.notify(node)
+}
+
+// notify 调用方持有 mu
+func (s *stubServer) notify(node NodeID) {
+	for _, sess := range s.sessions {
+		for _, sub := range sess.subs {
+			for _, it := range sub.items {
+				if it.ItemToMonitor.NodeID == node {
+					sub.queue = append(sub.queue, MonitoredItemNotification{
+						ClientHandle: it.RequestedParameters.ClientHandle,
+						Value:        DataValue{Value: s.nodes[node].value, SourceTimestamp: time.Now()},
+					})
+				}
+			}
+		}
+	}
+}
+
+func (s *stubServer) endpoints() []EndpointDescription {
+	users := []UserTokenPolicy{
+		{PolicyID: "anon", TokenType: UserTokenAnonymous},
+		{PolicyID: "user", TokenType: UserTokenUserName, SecurityPolicyURI: PolicyBasic256Sha256},
+	}
+	server := ApplicationDescription{ApplicationURI: "urn:stub:server", ApplicationName: LocalizedText{Text: "stub"}}
+	var eps []EndpointDescription
+	for _, m := range []MessageSecurityMode{SecurityModeNone, SecurityModeSign, SecurityModeSignAndEncrypt} {
+		policy := PolicyBasic256Sha256
+		if m == SecurityModeNone {
+			policy = PolicyNone
+		}
+		eps = append(eps, EndpointDescription{
+			EndpointURL: s.endpoint(), Server: server, ServerCertificate: s.cert, SecurityMode: m,
+			SecurityPolicyURI: policy, UserIdentityTokens: users, TransportProfileURI: transportBinary,
+		})
+	}
+	return eps
+}
+
+func (s *stubServer) serve(conn net.Conn) {
+	defer conn.Close()
+	ch := newSecureChannel(conn, true)
+	ch.localCert, ch.localKey = s.cert, s.key
+	if _, err := ch.acceptHello(time.Second); err != nil {
+		return
+	}
+	reply := func(requestID uint32, typ string, resp interface{}) {
+		body, err := encodeService(resp)
+		if err != nil {
+			s.t.Error(err)
+			return
+		}
+		ch.send(typ, requestID, body)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	var tokenID uint32
+	for {
+		msg, err := ch.receive()
+		if err != nil {
+			return
+		}
+		req, err := decodeService(msg.body)
+		if err != nil {
+			s.t.Error(err)
+			return
+		}
+		switch msg.typ {
+		case "OPN":
+			r := req.(*OpenSecureChannelRequest)
+			if r.RequestType == 0 {
+				ch.mode = r.SecurityMode
+				s.mu.Lock()
+				s.policies = append(s.policies, ch.policy+"/"+ch.mode.String())
+				s.mu.Unlock()
+			}
+			tokenID++
+			nonce := ch.newNonce()
+			tok := ChannelSecurityToken{ChannelID: 7, TokenID: tokenID, CreatedAt: time.Now(), RevisedLifetime: r.RequestedLifetime}
+			if err := ch.installToken(tok, r.ClientNonce, nonce); err != nil {
+				s.t.Error(err)
+				return
+			}
+			reply(msg.requestID, "OPN", &OpenSecureChannelResponse{ResponseHeader: s.header(req), SecurityToken: tok, ServerNonce: nonce})
+			continue
+		case "CLO":
+			return
+		}
+		resp := s.handle(ch, req, func(resp interface{}) { reply(msg.requestID, "MSG", resp) }, done)
+		if resp != nil {
+			reply(msg.requestID, "MSG", resp)
+		}
+	}
+}
+
+func (s *stubServer) header(req interface{}) ResponseHeader {
+	return ResponseHeader{Timestamp: time.Now(), RequestHandle: requestHeader(req).RequestHandle}
+}
+
+func (s *stubServer) fault(req interface{}, code StatusCode) *ServiceFault {
+	h := s.header(req)
+	h.ServiceResult = code
+	return &ServiceFault{ResponseHeader: h}
+}
+
+// handle 处理服务请求；Publish 由订阅发布协程异步应答，返回 nil
+func (s *stubServer) handle(ch *secureChannel, req interface{}, reply func(interface{}), done <-chan struct{}) interface{} {
+	h := s.header(req)
+	switch r := req.(type) {
+	case *GetEndpointsRequest:
+		return &GetEndpointsResponse{ResponseHeader: h, Endpoints: s.endpoints()}
+	case *CreateSessionRequest:
+		sess := &stubSession{nonce: make([]byte, nonceLen), subs: make(map[uint32]*stubSub)}
+		rand.Read(sess.nonce)
+		resp := &CreateSessionResponse{
+			ResponseHeader: h, RevisedSessionTimeout: r.RequestedSessionTimeout,
+			ServerNonce: sess.nonce, ServerCertificate: s.cert, ServerEndpoints: s.endpoints(),
+		}
+		if ch.secure() {
+			if !bytes.Equal(r.ClientCertificate, ch.remoteCert) {
+				return s.fault(req, StatusBadCertificateInvalid)
+			}
+			sess.clientKey = ch.remoteKey
+			sig, _ := rsaSign(s.key, append(append([]byte(nil), r.ClientCertificate...), r.ClientNonce...))
+			resp.ServerSignature = SignatureData{Algorithm: algRsaSha256, Signature: sig}
+		}
+		s.mu.Lock()
+		s.nextID++
+		resp.SessionID = NewNumericNodeID(1, s.nextID)
+		resp.AuthenticationToken = NewNumericNodeID(1, 1000+s.nextID)
+		s.sessions[resp.AuthenticationToken] = sess
+		s.mu.Unlock()
+		return resp
+	}
+
+	s.mu.Lock()
+	sess := s.sessions[requestHeader(req).AuthenticationToken]
+	s.mu.Unlock()
+	if sess == nil {
+		return s.fault(req, StatusBadSessionIDInvalid)
+	}
+	if r, ok := req.(*ActivateSessionRequest); ok {
+		if sess.clientKey != nil {
+			if rsaVerify(sess.clientKey, append(append([]byte(nil), s.cert...), sess.nonce...), r.ClientSignature.Signature) != nil {
+				return s.fault(req, StatusBadSecurityChecksFailed)
+			}
+		}
+		if code := s.checkIdentity(r.UserIdentityToken, sess.nonce); code != StatusOK {
+			return s.fault(req, code)
+		}
+		sess.activated = true
+		return &ActivateSessionResponse{ResponseHeader: h, ServerNonce: sess.nonce}
+	}
+	if !sess.activated {
+		return s.fault(req, StatusBadSessionNotActivated)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r := req.(type) {
+	case *CloseSessionRequest:
+		delete(s.sessions, r.RequestHeader.AuthenticationToken)
+		return &CloseSessionResponse{ResponseHeader: h}
+	case *BrowseRequest:
+		resp := &BrowseResponse{ResponseHeader: h}
+		for _, bd := range r.NodesToBrowse {
+			resp.Results = append(resp.Results, s.browse(bd.NodeID, 0))
+		}
+		return resp
+	case *BrowseNextRequest:
+		resp := &BrowseNextResponse{ResponseHeader: h}
+		for _, cp := range r.ContinuationPoints {
+			node, err := ParseNodeID(string(cp[4:]))
+			if err != nil {
+				resp.Results = append(resp.Results, BrowseResult{StatusCode: StatusBadContinuationPointInvalid})
+				continue
+			}
+			resp.Results = append(resp.Results, s.browse(node, int(binary.LittleEndian.Uint32(cp))))
+		}
+		return resp
+	case *ReadRequest:
+		resp := &ReadResponse{ResponseHeader: h}
+		for _, rv := range r.NodesToRead {
+			n := s.nodes[rv.NodeID]
+			switch {
+			case n == nil:
+				resp.Results = append(resp.Results, DataValue{Status: StatusBadNodeIDUnknown})
+			case rv.AttributeID == AttrValue && n.class == NodeClassVariable:
+				resp.Results = append(resp.Results, DataValue{Value: n.value, SourceTimestamp: time.Now(), ServerTimestamp: time.Now()})
+			case rv.AttributeID == AttrBrowseName:
+				resp.Results = append(resp.Results, DataValue{Value: Variant{TypeQualifiedName, QualifiedName{NamespaceIndex: rv.NodeID.Namespace, Name: n.name}}})
+			default:
+				resp.Results = append(resp.Results, DataValue{Status: StatusBadAttributeIDInvalid})
+			}
+		}
+		return resp
+	case *WriteRequest:
+		resp := &WriteResponse{ResponseHeader: h}
+		for _, wv := range r.NodesToWrite {
+			n := s.nodes[wv.NodeID]
+			switch {
+			case n == nil:
+				resp.Results = append(resp.Results, StatusBadNodeIDUnknown)
+			case !n.writable:
+				resp.Results = append(resp.Results, StatusBadNotWritable)
+			case wv.Value.Value.Type != n.value.Type:
+				resp.Results = append(resp.Results, StatusBadTypeMismatch)
+			default:
+				n.value = wv.Value.Value
+				s.writes = append(s.writes, wv)
+				s.notify(wv.NodeID)
+				resp.Results = append(resp.Results, StatusOK)
+			}
+		}
+		return resp
+	case *CreateSubscriptionRequest:
+		s.nextID++
+		sub := &stubSub{interval: time.Duration(r.RequestedPublishingInterval) * time.Millisecond, items: make(map[uint32]MonitoredItemCreateRequest)}
+		sess.subs[s.nextID] = sub
+		return &CreateSubscriptionResponse{ResponseHeader: h, SubscriptionID: s.nextID, RevisedPublishingInterval: r.RequestedPublishingInterval,
+			RevisedLifetimeCount: r.RequestedLifetimeCount, RevisedMaxKeepAliveCount: r.RequestedMaxKeepAliveCount}
+	case *CreateMonitoredItemsRequest:
+		sub := sess.subs[r.SubscriptionID]
+		if sub == nil {
+			return s.fault(req, StatusBadSubscriptionIDInvalid)
+		}
+		resp := &CreateMonitoredItemsResponse{ResponseHeader: h}
+		for _, it := range r.ItemsToCreate {
+			n := s.nodes[it.ItemToMonitor.NodeID]
+			if n == nil {
+				resp.Results = append(resp.Results, MonitoredItemCreateResult{StatusCode: StatusBadNodeIDUnknown})
+				continue
+			}
+			s.nextID++
+			sub.items[s.nextID] = it
+			sub.queue = append(sub.queue, MonitoredItemNotification{ClientHandle: it.RequestedParameters.ClientHandle, Value: DataValue{Value: n.value, SourceTimestamp: time.Now()}})
+			resp.Results = append(resp.Results, MonitoredItemCreateResult{MonitoredItemID: s.nextID, RevisedSamplingInterval: it.RequestedParameters.SamplingInterval, RevisedQueueSize: 1})
+		}
+		return resp
+	case *DeleteMonitoredItemsRequest:
+		sub := sess.subs[r.SubscriptionID]
+		if sub == nil {
+			return s.fault(req, StatusBadSubscriptionIDInvalid)
+		}
+		resp := &DeleteMonitoredItemsResponse{ResponseHeader: h}
+		for _, id := range r.MonitoredItemIDs {
+			delete(sub.items, id)
+			resp.Results = append(resp.Results, StatusOK)
+		}
+		return resp
+	case *PublishRequest:
+		if len(sess.subs) == 0 {
+			return s.fault(req, StatusBadNoSubscription)
+		}
+		go s.publish(sess, req, reply, done)
+		return nil
+	}
+	return s.fault(req, StatusBadServiceUnsupported)
+}
+
+// browse 每页最多2条引用，续传点为 偏移(4字节)+节点ID字符串
+func (s *stubServer) browse(node NodeID, offset int) BrowseResult {
+	n := s.nodes[node]
+	if n == nil {
+		return BrowseResult{StatusCode: StatusBadNodeIDUnknown}
+	}
+	var res BrowseResult
+	end := min(offset+2, len(n.children))
+	for _, c := range n.children[offset:end] {
+		child := s.nodes[c]
+		typeDef := BaseDataVariableType
+		if child.class == NodeClassObject {
+			typeDef = FolderType
+		}
+		res.References = append(res.References, ReferenceDescription{
+			ReferenceTypeID: OrganizesReference, IsForward: true, NodeID: ExpandedNodeID{NodeID: c},
+			BrowseName: QualifiedName{NamespaceIndex: c.Namespace, Name: child.name}, DisplayName: LocalizedText{Text: child.name},
+			NodeClass: child.class, TypeDefinition: ExpandedNodeID{NodeID: typeDef},
+		})
+	}
+	if end < len(n.children) {
+		res.ContinuationPoint = append(binary.LittleEndian.AppendUint32(nil, uint32(end)), node.String()...)
+	}
+	return res
+}
+
+func (s *stubServer) checkIdentity(tok ExtensionObject, nonce []byte) StatusCode {
+	s.mu.Lock()
+	s.lastToken = tok.Value
+	s.mu.Unlock()
+	switch t := tok.Value.(type) {
+	case *AnonymousIdentityToken:
+		if t.PolicyID != "anon" {
+			return StatusBadIdentityTokenInvalid
+		}
+		return StatusOK
+	case *UserNameIdentityToken:
+		if t.PolicyID != "user" || t.EncryptionAlgorithm != algRsaOaep {
+			return StatusBadIdentityTokenInvalid
+		}
+		plain, err := rsaDecrypt(s.key, t.Password)
+		if err != nil || len(plain) < 4+len(nonce) {
+			return StatusBadIdentityTokenInvalid
+		}
+		n := int(binary.LittleEndian.Uint32(plain))
+		if n != len(plain)-4 || !bytes.Equal(plain[len(plain)-len(nonce):], nonce) {
+			return StatusBadIdentityTokenInvalid
+		}
+		if t.UserName != "operator" || string(plain[4:len(plain)-len(nonce)]) != "secret" {
+			return StatusBadIdentityTokenRejected
+		}
+		return StatusOK
+	}
+	return StatusBadIdentityTokenInvalid
+}
+
+// publish 每个发布周期检查一次队列，有通知即应答；连续3个周期无数据时发送保活
+func (s *stubServer) publish(sess *stubSession, req interface{}, reply func(interface{}), done <-chan struct{}) {
+	for idle := 0; ; idle++ {
+		s.mu.Lock()
+		var subID uint32
+		var sub *stubSub
+		ids := make([]uint32, 0, len(sess.subs))
+		for id := range sess.subs {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			if sub == nil || len(sub.queue) == 0 && len(sess.subs[id].queue) > 0 {
+				subID, sub = id, sess.subs[id]
+			}
+		}
+		if sub == nil {
+			s.mu.Unlock()
+			return
+		}
+		if len(sub.queue) > 0 || idle >= 3 {
+			resp := &PublishResponse{ResponseHeader: s.header(req), SubscriptionID: subID}
+			resp.NotificationMessage.PublishTime = time.Now()
+			resp.NotificationMessage.SequenceNumber = sub.seq + 1
+			if len(sub.queue) > 0 {
+				sub.seq++
+				resp.NotificationMessage.NotificationData = []ExtensionObject{NewExtensionObject(&DataChangeNotification{MonitoredItems: sub.queue})}
+				sub.queue = nil
+			}
+			s.mu.Unlock()
+			reply(resp)
+			return
+		}
+		interval := sub.interval
+		s.mu.Unlock()
+		select {
+		case <-done:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (s *stubServer) snapshot() (writes []WriteValue, policies []string, token interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]WriteValue(nil), s.writes...), append([]string(nil), s.policies...), s.lastToken
+}
@@ -0,0 +1,638 @@
+package opcua
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IDType NodeId 标识类型
+type IDType byte
+
+const (
+	IDNumeric IDType = iota
+	IDString
+	IDGUID
+	IDOpaque
+)
+
+// GUID 按报文字节序存储（前三段小端）
+type GUID [16]byte
+
+func (g GUID) encodeUA(e *encoder)  { e.b = append(e.b, g[:]...) }
+func (g *GUID) decodeUA(d *decoder) { copy(g[:], d.take(16)) }
+
+func (g GUID) String() string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X", binary.LittleEndian.Uint32(g[0:]),
+		binary.LittleEndian.Uint16(g[4:]), binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:])
+}
+
+func parseGUID(s string) (GUID, error) {
+	var g GUID
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return g, fmt.Errorf("opcua: invalid guid %q", s)
+	}
+	raw, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		return g, fmt.Errorf("opcua: invalid guid %q", s)
+	}
+	binary.LittleEndian.PutUint32(g[0:], binary.BigEndian.Uint32(raw[0:]))
+	binary.LittleEndian.PutUint16(g[4:], binary.BigEndian.Uint16(raw[4:]))
+	binary.LittleEndian.PutUint16(g[6:], binary.BigEndian.Uint16(raw[6:]))
+	copy(g[8:], raw[8:])
+	return g, nil
+}
+
+// NodeID 节点标识，可比较，可作为 map 键。Str 保存字符串标识或不透明标识的原始字节
+type NodeID struct {
+	Namespace uint16
+	Type      IDType
+	Numeric   uint32
+	Str       string
+	GUID      GUID
+}
+
+// NewNumericNodeID 数字标识
+func NewNumericNodeID(ns uint16, id uint32) NodeID {
+	return NodeID{Namespace: ns, Numeric: id}
+}
+
+// NewStringNodeID 字符串标识
+func NewStringNodeID(ns uint16, id string) NodeID {
+	return NodeID{Namespace: ns, Type: IDString, Str: id}
+}
+
+// IsNull ns=0;i=0
+func (n NodeID) IsNull() bool { return n == NodeID{} }
+
+func (n NodeID) String() string {
+	var id string
+	switch n.Type {
+	case IDString:
+		id = "s=" + n.Str
+	case IDGUID:
+		id = "g=" + n.GUID.String()
+	case IDOpaque:
+		id = "b=" + base64.StdEncoding.EncodeToString([]byte(n.Str))
+	default:
+		id = "i=" + strconv.FormatUint(uint64(n.Numeric), 10)
+	}
+	if n.Namespace == 0 {
+		return id
+	}
+	return "ns=" + strconv.Itoa(int(n.Namespace)) + ";" + id
+}
+
+// ParseNodeID 解析 "ns=2;s=Tag1"、"i=85"、"ns=1;g=..."、"ns=1;b=base64" 形式
+func ParseNodeID(s string) (NodeID, error) {
+	var n NodeID
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "ns=") {
+		i := strings.IndexByte(s, ';')
+		if i < 0 {
+			return n, fmt.Errorf("opcua: invalid node id %q", s)
+		}
+		ns, err := strconv.ParseUint(s[3:i], 10, 16)
+		if err != nil {
+			return n, fmt.Errorf("opcua: invalid namespace in %q", s)
+		}
+		n.Namespace, s = uint16(ns), s[i+1:]
+	}
+	if len(s) < 2 || s[1] != '=' {
+		return n, fmt.Errorf("opcua: invalid node id %q", s)
+	}
+	id := s[2:]
+	switch s[0] {
+	case 'i':
+		v, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return n, fmt.Errorf("opcua: invalid numeric id %q", id)
+		}
+		n.Numeric = uint32(v)
+	case 's':
+		n.Type, n.Str = IDString, id
+	case 'g':
+		g, err := parseGUID(id)
+		if err != nil {
+			return n, err
+		}
+		n.Type, n.GUID = IDGUID, g
+	case 'b':
+		raw, err := base64.StdEncoding.DecodeString(id)
+		if err != nil {
+			return n, fmt.Errorf("opcua: invalid opaque id %q", id)
+		}
+		n.Type, n.Str = IDOpaque, string(raw)
+	default:
+		return n, fmt.Errorf("opcua: invalid node id %q", s)
+	}
+	return n, nil
+}
+
+func (n NodeID) encodeWithFlags(e *encoder, flags byte) {
+	switch n.Type {
+	case IDString:
+		e.u8(0x03 | flags)
+		e.u16(n.Namespace)
+		e.i32(int32(len(n.Str)))
+		e.b = append(e.b, n.Str...)
+	case IDGUID:
+		e.u8(0x04 | flags)
+		e.u16(n.Namespace)
+		n.GUID.encodeUA(e)
+	case IDOpaque:
+		e.u8(0x05 | flags)
+		e.u16(n.Namespace)
+		e.bytes([]byte(n.Str))
+	default:
+		switch {
+		case n.Namespace == 0 && n.Numeric <= 0xFF:
+			e.u8(0x00 | flags)
+			e.u8(byte(n.Numeric))
+		case n.Namespace <= 0xFF && n.Numeric <= 0xFFFF:
+			e.u8(0x01 | flags)
+			e.u8(byte(n.Namespace))
+			e.u16(uint16(n.Numeric))
+		default:
+			e.u8(0x02 | flags)
+			e.u16(n.Namespace)
+			e.u32(n.Numeric)
+		}
+	}
+}
+
+// decodeWithFlags 返回编码字节的高位标志（ExpandedNodeId 使用）
+func (n *NodeID) decodeWithFlags(d *decoder) byte {
+	enc := d.u8()
+	*n = NodeID{}
+	switch enc & 0x3F {
+	case 0x00:
+		n.Numeric = uint32(d.u8())
+	case 0x01:
+		n.Namespace, n.Numeric = uint16(d.u8()), uint32(d.u16())
+	case 0x02:
+		n.Namespace, n.Numeric = d.u16(), d.u32()
+	case 0x03:
+		n.Namespace, n.Type, n.Str = d.u16(), IDString, d.str()
+	case 0x04:
+		n.Namespace, n.Type = d.u16(), IDGUID
+		n.GUID.decodeUA(d)
+	case 0x05:
+		n.Namespace, n.Type, n.Str = d.u16(), IDOpaque, string(d.bytes())
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("opcua: invalid node id encoding 0x%02X", enc)
+		}
+	}
+	return enc & 0xC0
+}
+
+func (n NodeID) encodeUA(e *encoder)  { n.encodeWithFlags(e, 0) }
+func (n *NodeID) decodeUA(d *decoder) { n.decodeWithFlags(d) }
+
+// ExpandedNodeID 带命名空间URI/服务器序号的节点标识
+type ExpandedNodeID struct {
+	NodeID
+	NamespaceURI string
+	ServerIndex  uint32
+}
+
+func (n ExpandedNodeID) encodeUA(e *encoder) {
+	var flags byte
+	if n.NamespaceURI != "" {
+		flags |= 0x80
+	}
+	if n.ServerIndex != 0 {
+		flags |= 0x40
+	}
+	n.NodeID.encodeWithFlags(e, flags)
+	if n.NamespaceURI != "" {
+		e.str(n.NamespaceURI)
+	}
+	if n.ServerIndex != 0 {
+		e.u32(n.ServerIndex)
+	}
+}
+
+func (n *ExpandedNodeID) decodeUA(d *decoder) {
+	flags := n.NodeID.decodeWithFlags(d)
+	n.NamespaceURI, n.ServerIndex = "", 0
+	if flags&0x80 != 0 {
+		n.NamespaceURI = d.str()
+	}
+	if flags&0x40 != 0 {
+		n.ServerIndex = d.u32()
+	}
+}
+
+// QualifiedName 带命名空间的名称
+type QualifiedName struct {
+	NamespaceIndex uint16
+	Name           string
+}
+
+func (q QualifiedName) String() string {
+	if q.NamespaceIndex == 0 {
+		return q.Name
+	}
+	return strconv.Itoa(int(q.NamespaceIndex)) + ":" + q.Name
+}
+
+// LocalizedText 本地化文本
+type LocalizedText struct {
+	Locale string
+	Text   string
+}
+
+func (t LocalizedText) encodeUA(e *encoder) {
+	var mask byte
+	if t.Locale != "" {
+		mask |= 0x01
+	}
+	if t.Text != "" {
+		mask |= 0x02
+	}
+	e.u8(mask)
+	if t.Locale != "" {
+		e.str(t.Locale)
+	}
+	if t.Text != "" {
+		e.str(t.Text)
+	}
+}
+
+func (t *LocalizedText) decodeUA(d *decoder) {
+	mask := d.u8()
+	*t = LocalizedText{}
+	if mask&0x01 != 0 {
+		t.Locale = d.str()
+	}
+	if mask&0x02 != 0 {
+		t.Text = d.str()
+	}
+}
+
+// extTypes 扩展对象二进制编码节点号与结构体类型的对应，见 services.go
+var (
+	extTypes = map[uint32]reflect.Type{}
+	extIDs   = map[reflect.Type]uint32{}
+)
+
+func registerExt(id uint32, v interface{}) {
+	t := reflect.TypeOf(v)
+	extTypes[id], extIDs[t] = t, id
+}
+
+// ExtensionObject 扩展对象。已注册类型解码为结构体指针放在 Value，其余保留原始 Body
+type ExtensionObject struct {
+	TypeID NodeID
+	Value  interface{}
+	Body   []byte
+}
+
+// NewExtensionObject 包装已注册的结构体
+func NewExtensionObject(v interface{}) ExtensionObject {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	id, ok := extIDs[t]
+	if !ok {
+		panic(fmt.Sprintf("opcua: %s is not a registered extension object", t))
+	}
+	return ExtensionObject{TypeID: NewNumericNodeID(0, id), Value: v}
+}
+
+func (x ExtensionObject) encodeUA(e *encoder) {
+	switch {
+	case x.Value != nil:
+		x.TypeID.encodeUA(e)
+		e.u8(0x01)
+		e.bytes(encode(x.Value))
+	case x.Body != nil:
+		x.TypeID.encodeUA(e)
+		e.u8(0x01)
+		e.bytes(x.Body)
+	default:
+		x.TypeID.encodeUA(e)
+		e.u8(0x00)
+	}
+}
+
+func (x *ExtensionObject) decodeUA(d *decoder) {
+	*x = ExtensionObject{}
+	x.TypeID.decodeUA(d)
+	switch d.u8() {
+	case 0x00:
+		return
+	case 0x01, 0x02:
+		x.Body = d.bytes()
+	}
+	if t, ok := extTypes[x.TypeID.Numeric]; ok && x.TypeID.Namespace == 0 && x.TypeID.Type == IDNumeric && d.err == nil {
+		v := reflect.New(t)
+		if err := decode(x.Body, v.Interface()); err == nil {
+			x.Value, x.Body = v.Interface(), nil
+		}
+	}
+}
+
+// DiagnosticInfo 诊断信息，解码后一般忽略
+type DiagnosticInfo struct {
+	SymbolicID          int32
+	NamespaceURI        int32
+	LocalizedText       int32
+	Locale              int32
+	AdditionalInfo      string
+	InnerStatusCode     StatusCode
+	InnerDiagnosticInfo *DiagnosticInfo
+	mask                byte
+}
+
+func (di DiagnosticInfo) encodeUA(e *encoder) {
+	e.u8(di.mask)
+	if di.mask&0x01 != 0 {
+		e.i32(di.SymbolicID)
+	}
+	if di.mask&0x02 != 0 {
+		e.i32(di.NamespaceURI)
+	}
+	if di.mask&0x04 != 0 {
+		e.i32(di.Locale)
+	}
+	if di.mask&0x08 != 0 {
+		e.i32(di.LocalizedText)
+	}
+	if di.mask&0x10 != 0 {
+		e.str(di.AdditionalInfo)
+	}
+	if di.mask&0x20 != 0 {
+		e.u32(uint32(di.InnerStatusCode))
+	}
+	if di.mask&0x40 != 0 && di.InnerDiagnosticInfo != nil {
+		di.InnerDiagnosticInfo.encodeUA(e)
+	}
+}
+
+func (di *DiagnosticInfo) decodeUA(d *decoder) {
+	*di = DiagnosticInfo{mask: d.u8()}
+	if di.mask&0x01 != 0 {
+		di.SymbolicID = d.i32()
+	}
+	if di.mask&0x02 != 0 {
+		di.NamespaceURI = d.i32()
+	}
+	if di.mask&0x04 != 0 {
+		di.Locale = d.i32()
+	}
+	if di.mask&0x08 != 0 {
+		di.LocalizedText = d.i32()
+	}
+	if di.mask&0x10 != 0 {
+		di.AdditionalInfo = d.str()
+	}
+	if di.mask&0x20 != 0 {
+		di.InnerStatusCode = StatusCode(d.u32())
+	}
+	if di.mask&0x40 != 0 && d.err == nil {
+		di.InnerDiagnosticInfo = &DiagnosticInfo{}
+		di.InnerDiagnosticInfo.decodeUA(d)
+	}
+}
+
+// TypeID 变体内置类型
+type TypeID byte
+
+const (
+	TypeNull TypeID = iota
+	TypeBoolean
+	TypeSByte
+	TypeByte
+	TypeInt16
+	TypeUInt16
+	TypeInt32
+	TypeUInt32
+	TypeInt64
+	TypeUInt64
+	TypeFloat
+	TypeDouble
+	TypeString
+	TypeDateTime
+	TypeGUID
+	TypeByteString
+	TypeXMLElement
+	TypeNodeID
+	TypeExpandedNodeID
+	TypeStatusCode
+	TypeQualifiedName
+	TypeLocalizedText
+	TypeExtensionObject
+	TypeDataValue
+	TypeVariant
+	TypeDiagnosticInfo
+)
+
+// scalarTypes 变体内置类型对应的 Go 类型
+var scalarTypes = [...]reflect.Type{
+	TypeBoolean:         reflect.TypeOf(false),
+	TypeSByte:           reflect.TypeOf(int8(0)),
+	TypeByte:            reflect.TypeOf(uint8(0)),
+	TypeInt16:           reflect.TypeOf(int16(0)),
+	TypeUInt16:          reflect.TypeOf(uint16(0)),
+	TypeInt32:           reflect.TypeOf(int32(0)),
+	TypeUInt32:          reflect.TypeOf(uint32(0)),
+	TypeInt64:           reflect.TypeOf(int64(0)),
+	TypeUInt64:          reflect.TypeOf(uint64(0)),
+	TypeFloat:           reflect.TypeOf(float32(0)),
+	TypeDouble:          reflect.TypeOf(float64(0)),
+	TypeString:          reflect.TypeOf(""),
+	TypeDateTime:        timeType,
+	TypeGUID:            reflect.TypeOf(GUID{}),
+	TypeByteString:      reflect.TypeOf([]byte(nil)),
+	TypeXMLElement:      reflect.TypeOf(""),
+	TypeNodeID:          reflect.TypeOf(NodeID{}),
+	TypeExpandedNodeID:  reflect.TypeOf(ExpandedNodeID{}),
+	TypeStatusCode:      reflect.TypeOf(StatusCode(0)),
+	TypeQualifiedName:   reflect.TypeOf(QualifiedName{}),
+	TypeLocalizedText:   reflect.TypeOf(LocalizedText{}),
+	TypeExtensionObject: reflect.TypeOf(ExtensionObject{}),
+	TypeDataValue:       reflect.TypeOf(DataValue{}),
+	TypeVariant:         reflect.TypeOf(Variant{}),
+	TypeDiagnosticInfo:  reflect.TypeOf(DiagnosticInfo{}),
+}
+
+// Variant 变体。数组的 Value 为对应 Go 类型的切片（ByteString 数组为 [][]byte）
+type Variant struct {
+	Type  TypeID
+	Value interface{}
+}
+
+// NewVariant 按 Go 类型推断内置类型，支持标量与一维切片
+func NewVariant(v interface{}) (Variant, error) {
+	if v == nil {
+		return Variant{}, nil
+	}
+	t := reflect.TypeOf(v)
+	for id, st := range scalarTypes {
+		if st == t && TypeID(id) != TypeXMLElement {
+			return Variant{Type: TypeID(id), Value: v}, nil
+		}
+	}
+	if t.Kind() == reflect.Slice {
+		for id, st := range scalarTypes {
+			if st != nil && st == t.Elem() && TypeID(id) != TypeXMLElement {
+				return Variant{Type: TypeID(id), Value: v}, nil
+			}
+		}
+	}
+	switch x := v.(type) {
+	case int:
+		return Variant{Type: TypeInt64, Value: int64(x)}, nil
+	case uint:
+		return Variant{Type: TypeUInt64, Value: uint64(x)}, nil
+	}
+	return Variant{}, fmt.Errorf("opcua: unsupported variant value %T", v)
+}
+
+// IsArray 值为数组
+func (v Variant) IsArray() bool {
+	if v.Value == nil {
+		return false
+	}
+	t := reflect.TypeOf(v.Value)
+	return t.Kind() == reflect.Slice && (v.Type != TypeByteString || t.Elem().Kind() == reflect.Slice)
+}
+
+func (v Variant) String() string {
+	if v.Type == TypeNull {
+		return "null"
+	}
+	return fmt.Sprintf("%v", v.Value)
+}
+
+func (v Variant) encodeUA(e *encoder) {
+	if v.Type == TypeNull || v.Value == nil {
+		e.u8(0)
+		return
+	}
+	rv := reflect.ValueOf(v.Value)
+	if v.IsArray() {
+		e.u8(byte(v.Type) | 0x80)
+		e.i32(int32(rv.Len()))
+		for i := 0; i < rv.Len(); i++ {
+			e.value(rv.Index(i))
+		}
+		return
+	}
+	e.u8(byte(v.Type))
+	e.value(rv)
+}
+
+func (v *Variant) decodeUA(d *decoder) {
+	*v = Variant{}
+	mask := d.u8()
+	id := TypeID(mask & 0x3F)
+	if id == TypeNull {
+		return
+	}
+	if int(id) >= len(scalarTypes) {
+		if d.err == nil {
+			d.err = fmt.Errorf("opcua: invalid variant type %d", id)
+		}
+		return
+	}
+	t := scalarTypes[id]
+	v.Type = id
+	if mask&0x80 == 0 {
+		x := reflect.New(t).Elem()
+		d.value(x)
+		v.Value = x.Interface()
+		return
+	}
+	n := d.count()
+	s := reflect.MakeSlice(reflect.SliceOf(t), max(n, 0), max(n, 0))
+	for i := 0; i < n && d.err == nil; i++ {
+		d.value(s.Index(i))
+	}
+	v.Value = s.Interface()
+	if mask&0x40 != 0 {
+		// 多维数组按一维展开，维度信息丢弃
+		var dims []int32
+		d.value(reflect.ValueOf(&dims).Elem())
+	}
+}
+
+// DataValue 带品质与时标的值
+type DataValue struct {
+	Value           Variant
+	Status          StatusCode
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+}
+
+func (dv DataValue) encodeUA(e *encoder) {
+	var mask byte
+	if dv.Value.Type != TypeNull {
+		mask |= 0x01
+	}
+	if dv.Status != 0 {
+		mask |= 0x02
+	}
+	if !dv.SourceTimestamp.IsZero() {
+		mask |= 0x04
+	}
+	if !dv.ServerTimestamp.IsZero() {
+		mask |= 0x08
+	}
+	e.u8(mask)
+	if mask&0x01 != 0 {
+		dv.Value.encodeUA(e)
+	}
+	if mask&0x02 != 0 {
+		e.u32(uint32(dv.Status))
+	}
+	if mask&0x04 != 0 {
+		e.time(dv.SourceTimestamp)
+	}
+	if mask&0x08 != 0 {
+		e.time(dv.ServerTimestamp)
+	}
+}
+
+func (dv *DataValue) decodeUA(d *decoder) {
+	*dv = DataValue{}
+	mask := d.u8()
+	if mask&0x01 != 0 {
+		dv.Value.decodeUA(d)
+	}
+	if mask&0x02 != 0 {
+		dv.Status = StatusCode(d.u32())
+	}
+	if mask&0x04 != 0 {
+		dv.SourceTimestamp = d.time()
+	}
+	if mask&0x10 != 0 {
+		d.u16() // 源时标皮秒
+	}
+	if mask&0x08 != 0 {
+		dv.ServerTimestamp = d.time()
+	}
+	if mask&0x20 != 0 {
+		d.u16()
+	}
+}
+
+// sliceValues 切片元素展开为 interface{} 列表
+func sliceValues(s interface{}) []interface{} {
+	rv := reflect.ValueOf(s)
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -22,3 +23,20 @@ func GetAdapter(adapterName string, cfg map[string]interface{}) (ProtocolAdapter
 	}
 	return f.(AdapterFactory)(cfg)
 }
+
+// Registered 适配器是否已注册（需导入对应协议包）
+func Registered(adapterName string) bool {
+	_, ok := registry.Load(adapterName)
+	return ok
+}
+
+// Adapters 已注册的适配器名，按字母序
+func Adapters() []string {
+	var names []string
+	registry.Range(func(k, _ interface{}) bool {
+		names = append(names, k.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
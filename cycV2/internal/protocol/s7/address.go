@@ -0,0 +1,136 @@
+package s7
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Area S7 存储区
+type Area byte
+
+const (
+	AreaI  Area = 0x81 // 输入（I/E）
+	AreaQ  Area = 0x82 // 输出（Q/A）
+	AreaM  Area = 0x83 // 位存储区
+	AreaDB Area = 0x84 // 数据块
+)
+
+var areaNames = map[Area]string{AreaI: "I", AreaQ: "Q", AreaM: "M", AreaDB: "DB"}
+
+// 各类型单个元素的字节数（STRING 另计，BOOL 按位）
+var typeSizes = map[string]int{
+	"BOOL": 1, "BYTE": 1, "CHAR": 1, "SINT": 1, "USINT": 1,
+	"WORD": 2, "INT": 2, "UINT": 2,
+	"DWORD": 4, "DINT": 4, "UDINT": 4, "REAL": 4, "TIME": 4,
+	"LWORD": 8, "LINT": 8, "ULINT": 8, "LREAL": 8,
+	"STRING": 1,
+}
+
+// 地址宽度字母对应的默认类型
+var sizeTypes = map[string]string{"X": "BOOL", "B": "BYTE", "W": "WORD", "D": "DWORD"}
+
+// Tag 解析后的变量地址
+type Tag struct {
+	Area   Area
+	DB     int
+	Offset int    // 字节偏移
+	Bit    int    // 位号（BOOL）
+	Type   string // 大写类型名
+	Count  int    // 数组元素数；STRING 为最大字符数
+}
+
+var (
+	tagRe  = regexp.MustCompile(`^(?:DB(\d+)\.DB([XBWD])|([IEQAM])([XBWD]?))(\d+)(?:\.([0-7]))?$`)
+	typeRe = regexp.MustCompile(`^([A-Z]+)(?:\[(\d+)\])?$`)
+)
+
+// ParseTag 解析地址，如 DB10.DBD4:REAL、DB1.DBX0.3、M10.2、MW20:INT、IB0、DB5.DBB0:STRING[20]、DB2.DBB0:BYTE[16]。
+// 未指定类型时按宽度字母取 BOOL/BYTE/WORD/DWORD；E/A 为 I/Q 的德文助记符
+func ParseTag(s string) (Tag, error) {
+	loc, typ, _ := strings.Cut(strings.ToUpper(strings.TrimSpace(s)), ":")
+	m := tagRe.FindStringSubmatch(strings.ReplaceAll(loc, " ", ""))
+	if m == nil {
+		return Tag{}, fmt.Errorf("s7: invalid address %q", s)
+	}
+	t := Tag{Count: 1}
+	size := m[2]
+	if m[1] != "" {
+		t.Area = AreaDB
+		t.DB, _ = strconv.Atoi(m[1])
+		if t.DB < 1 || t.DB > 65535 {
+			return Tag{}, fmt.Errorf("s7: invalid DB number in %q", s)
+		}
+	} else {
+		t.Area = map[string]Area{"I": AreaI, "E": AreaI, "Q": AreaQ, "A": AreaQ, "M": AreaM}[m[3]]
+		size = m[4]
+	}
+	t.Offset, _ = strconv.Atoi(m[5])
+	if t.Offset > 0xFFFF {
+		return Tag{}, fmt.Errorf("s7: offset out of range in %q", s)
+	}
+	if m[6] != "" {
+		t.Bit, _ = strconv.Atoi(m[6])
+		if size == "" {
+			size = "X"
+		}
+	}
+	switch {
+	case size == "":
+		return Tag{}, fmt.Errorf("s7: missing width (X/B/W/D) in %q", s)
+	case (size == "X") != (m[6] != ""):
+		return Tag{}, fmt.Errorf("s7: bit number required exactly for X addresses in %q", s)
+	}
+	t.Type = sizeTypes[size]
+	if typ != "" {
+		tm := typeRe.FindStringSubmatch(strings.TrimSpace(typ))
+		if tm == nil || typeSizes[tm[1]] == 0 {
+			return Tag{}, fmt.Errorf("s7: unknown type %q", typ)
+		}
+		t.Type = tm[1]
+		if tm[2] != "" {
+			t.Count, _ = strconv.Atoi(tm[2])
+		} else if t.Type == "STRING" {
+			t.Count = 254
+		}
+		if t.Count < 1 || (t.Type == "STRING" && t.Count > 254) {
+			return Tag{}, fmt.Errorf("s7: invalid length in %q", s)
+		}
+	}
+	if (t.Type == "BOOL") != (size == "X") {
+		return Tag{}, fmt.Errorf("s7: BOOL needs a bit address (X) in %q", s)
+	}
+	if t.Type == "BOOL" && t.Count != 1 {
+		return Tag{}, fmt.Errorf("s7: BOOL arrays not supported in %q", s)
+	}
+	return t, nil
+}
+
+// Size 占用字节数
+func (t Tag) Size() int {
+	if t.Type == "STRING" {
+		return t.Count + 2
+	}
+	return typeSizes[t.Type] * t.Count
+}
+
+func (t Tag) String() string {
+	var loc string
+	w := map[string]string{"BOOL": "X", "BYTE": "B", "WORD": "W", "DWORD": "D"}[t.Type]
+	if w == "" {
+		w = "B"
+	}
+	if t.Area == AreaDB {
+		loc = fmt.Sprintf("DB%d.DB%s%d", t.DB, w, t.Offset)
+	} else {
+		loc = fmt.Sprintf("%s%s%d", areaNames[t.Area], w, t.Offset)
+	}
+	if t.Type == "BOOL" {
+		return fmt.Sprintf("%s.%d", loc, t.Bit)
+	}
+	if t.Count > 1 || t.Type == "STRING" {
+		return fmt.Sprintf("%s:%s[%d]", loc, t.Type, t.Count)
+	}
+	return loc + ":" + t.Type
+}
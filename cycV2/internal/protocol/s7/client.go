@@ -0,0 +1,504 @@
+package s7
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cycV2/internal/protocol"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("s7", NewS7Adapter)
+}
+
+// S7Adapter S7comm 客户端，实现 protocol.ProtocolAdapter 与 protocol.MultiReader。
+// 点位参数 address 为 S7 地址（见 ParseTag），Read 返回大端字节（BOOL 为1字节，STRING 为字符内容）
+type S7Adapter struct {
+	addr       string
+	localTSAP  uint16
+	remoteTSAP uint16
+	pduReq     int
+	mergeGap   int
+	timeout    time.Duration
+
+	mu   sync.Mutex // 串行化请求
+	conn net.Conn
+	pdu  int // 协商后的 PDU 大小
+	ref  uint16
+}
+
+// 连接类型（远端 TSAP 高字节）
+var connTypes = map[string]uint16{"pg": 1, "op": 2, "basic": 3}
+
+// NewS7Adapter 工厂函数
+// cfg: address("ip[:102]"), rack(默认0), slot(默认1，cpu 为 s7-300/s7-400 时默认2), cpu,
+//
+//	connectionType(pg|op|basic，默认pg), localTSAP/remoteTSAP(覆盖 rack/slot 计算，如 0x4D57),
+//	pduSize(期望值，默认960), mergeGap(多变量读时合并的最大间隙字节，默认8), timeoutMs
+func NewS7Adapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewS7Client(cfg)
+}
+
+// NewS7Client 创建客户端，返回具体类型以便批量读写
+func NewS7Client(cfg map[string]interface{}) (*S7Adapter, error) {
+	addr, _ := cfg["address"].(string)
+	if addr == "" {
+		return nil, errors.New("s7: missing address")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "102")
+	}
+	a := &S7Adapter{addr: addr, localTSAP: 0x0100, pduReq: 960, mergeGap: 8, timeout: 3 * time.Second}
+	rack, slot := 0, 1
+	cpu, _ := cfg["cpu"].(string)
+	switch strings.ToLower(cpu) {
+	case "s7-300", "s7-400":
+		slot = 2
+	}
+	if v, ok := toInt(cfg["rack"]); ok {
+		rack = v
+	}
+	if v, ok := toInt(cfg["slot"]); ok {
+		slot = v
+	}
+	if rack < 0 || rack > 7 || slot < 0 || slot > 31 {
+		return nil, fmt.Errorf("s7: invalid rack %d / slot %d", rack, slot)
+	}
+	ct := connTypes["pg"]
+	if name, ok := cfg["connectionType"].(string); ok && name != "" {
+		if ct, ok = connTypes[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("s7: unknown connectionType %q", name)
+		}
+	}
+	a.remoteTSAP = ct<<8 | uint16(rack*0x20+slot)
+	if v, ok := toInt(cfg["localTSAP"]); ok {
+		a.localTSAP = uint16(v)
+	}
+	if v, ok := toInt(cfg["remoteTSAP"]); ok {
+		a.remoteTSAP = uint16(v)
+	}
+	if v, ok := toInt(cfg["pduSize"]); ok {
+		if v < 240 || v > 960 {
+			return nil, fmt.Errorf("s7: pduSize %d out of range 240..960", v)
+		}
+		a.pduReq = v
+	}
+	if v, ok := toInt(cfg["mergeGap"]); ok && v >= 0 {
+		a.mergeGap = v
+	}
+	if v, ok := toInt(cfg["timeoutMs"]); ok && v > 0 {
+		a.timeout = time.Duration(v) * time.Millisecond
+	}
+	return a, nil
+}
+
+// Connect COTP 建链并协商 PDU 大小
+func (a *S7Adapter) Connect() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.connectLocked()
+}
+
+func (a *S7Adapter) connectLocked() error {
+	if a.conn != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", a.addr, a.timeout)
+	if err != nil {
+		return fmt.Errorf("s7: dial %s: %w", a.addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(a.timeout))
+	if err := cotpDial(conn, a.localTSAP, a.remoteTSAP); err != nil {
+		conn.Close()
+		return err
+	}
+	a.conn = conn
+	_, params, _, err := a.roundTrip(setupParams(a.pduReq), nil)
+	if err == nil && (len(params) < 8 || params[0] != funcSetup) {
+		err = errMalformed
+	}
+	if err != nil {
+		a.closeLocked()
+		return fmt.Errorf("s7: setup communication: %w", err)
+	}
+	a.pdu = int(binary.BigEndian.Uint16(params[6:]))
+	if a.pdu < 64 {
+		a.closeLocked()
+		return fmt.Errorf("s7: negotiated PDU size %d too small", a.pdu)
+	}
+	log.Printf("[S7] %s 已连接，PDU %d 字节", a.addr, a.pdu)
+	return nil
+}
+
+// Disconnect 关闭连接
+func (a *S7Adapter) Disconnect() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closeLocked()
+	return nil
+}
+
+func (a *S7Adapter) closeLocked() {
+	if a.conn != nil {
+		a.conn.Close()
+		a.conn = nil
+	}
+}
+
+// PDUSize 协商后的 PDU 大小，未连接时为0
+func (a *S7Adapter) PDUSize() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn == nil {
+		return 0
+	}
+	return a.pdu
+}
+
+// roundTrip 发送作业并等待应答，调用方持有 mu；传输错误时断开，下次请求重连
+func (a *S7Adapter) roundTrip(params, data []byte) (uint16, []byte, []byte, error) {
+	a.ref++
+	ref := a.ref
+	a.conn.SetDeadline(time.Now().Add(a.timeout))
+	if err := writeDT(a.conn, s7Header(ref, params, data)); err != nil {
+		a.closeLocked()
+		return 0, nil, nil, err
+	}
+	for {
+		pdu, err := readDT(a.conn)
+		if err != nil {
+			a.closeLocked()
+			return 0, nil, nil, err
+		}
+		got, p, d, err := ackData(pdu)
+		if got != ref && !errors.Is(err, errMalformed) {
+			continue // 迟到的旧应答
+		}
+		if errors.Is(err, errMalformed) {
+			a.closeLocked()
+		}
+		return got, p, d, err
+	}
+}
+
+// span 按字节读取的连续区间 [start, end)
+type span struct {
+	area       Area
+	db         int
+	start, end int
+	data       []byte
+	err        error
+}
+
+// chunk 单个读请求项
+type chunk struct {
+	sp         *span
+	start, len int
+}
+
+// ReadTags 多变量读：同一数据块内相邻（间隙不超过 mergeGap）的变量合并为一个区间，
+// 超过单项上限的区间拆分，再按 PDU 大小与变量数上限装入尽量少的请求。
+// 返回与 tags 一一对应的值与错误；连接级错误时 errs 全部为该错误
+func (a *S7Adapter) ReadTags(tags []Tag) ([][]byte, []error) {
+	vals := make([][]byte, len(tags))
+	errs := make([]error, len(tags))
+	fail := func(err error) ([][]byte, []error) {
+		for i := range errs {
+			errs[i] = err
+		}
+		return vals, errs
+	}
+	if len(tags) == 0 {
+		return vals, errs
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.connectLocked(); err != nil {
+		return fail(err)
+	}
+	spans, owner := a.plan(tags)
+	for _, req := range packChunks(spans, a.pdu) {
+		if err := a.readChunks(req); err != nil {
+			return fail(err)
+		}
+	}
+	for i, t := range tags {
+		sp := spans[owner[i]]
+		if sp.err != nil {
+			errs[i] = fmt.Errorf("%s: %w", t, sp.err)
+			continue
+		}
+		vals[i], errs[i] = tagValue(t, sp.data[t.Offset-sp.start:t.Offset-sp.start+tagBytes(t)])
+	}
+	return vals, errs
+}
+
+// tagBytes 读取时占用的字节数（BOOL 读取所在字节）
+func tagBytes(t Tag) int {
+	if t.Type == "BOOL" {
+		return 1
+	}
+	return t.Size()
+}
+
+// plan 合并区间，owner[i] 为 tags[i] 所在区间下标
+func (a *S7Adapter) plan(tags []Tag) ([]*span, []int) {
+	idx := make([]int, len(tags))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(x, y int) bool {
+		p, q := tags[idx[x]], tags[idx[y]]
+		if p.Area != q.Area {
+			return p.Area < q.Area
+		}
+		if p.DB != q.DB {
+			return p.DB < q.DB
+		}
+		return p.Offset < q.Offset
+	})
+	maxItem := a.pdu - respHeader - 2 - itemHeader
+	var spans []*span
+	owner := make([]int, len(tags))
+	for _, i := range idx {
+		t := tags[i]
+		end := t.Offset + tagBytes(t)
+		if n := len(spans); n > 0 {
+			sp := spans[n-1]
+			// 重叠必合并；相邻间隙不超过 mergeGap 且合并后不超过单项上限时合并
+			if sp.area == t.Area && sp.db == t.DB &&
+				(t.Offset < sp.end || (t.Offset-sp.end <= a.mergeGap && max(end, sp.end)-sp.start <= maxItem)) {
+				sp.end = max(sp.end, end)
+				owner[i] = n - 1
+				continue
+			}
+		}
+		spans = append(spans, &span{area: t.Area, db: t.DB, start: t.Offset, end: end})
+		owner[i] = len(spans) - 1
+	}
+	return spans, owner
+}
+
+// packChunks 拆分过长区间并按 PDU 装箱，保持区间顺序
+func packChunks(spans []*span, pdu int) [][]chunk {
+	maxItem := pdu - respHeader - 2 - itemHeader
+	var reqs [][]chunk
+	var cur []chunk
+	reqLen, respLen := reqHeader+2, respHeader+2
+	for _, sp := range spans {
+		sp.data = make([]byte, sp.end-sp.start)
+		for off := sp.start; off < sp.end; off += maxItem {
+			c := chunk{sp: sp, start: off, len: min(maxItem, sp.end-off)}
+			need := itemHeader + c.len + c.len%2
+			if len(cur) > 0 && (len(cur) == maxItems || reqLen+itemSpec > pdu || respLen+need > pdu) {
+				reqs = append(reqs, cur)
+				cur, reqLen, respLen = nil, reqHeader+2, respHeader+2
+			}
+			cur = append(cur, c)
+			reqLen += itemSpec
+			respLen += need
+		}
+	}
+	if len(cur) > 0 {
+		reqs = append(reqs, cur)
+	}
+	return reqs
+}
+
+// readChunks 执行一个读请求，结果写入所属区间；变量级错误记在区间上
+func (a *S7Adapter) readChunks(req []chunk) error {
+	params := []byte{funcRead, byte(len(req))}
+	for _, c := range req {
+		params = append(params, itemSpecBytes(tsByte, c.len, c.sp.db, c.sp.area, c.start, 0)...)
+	}
+	_, p, data, err := a.roundTrip(params, nil)
+	if err != nil {
+		return err
+	}
+	if len(p) < 2 || p[0] != funcRead || int(p[1]) != len(req) {
+		return errMalformed
+	}
+	for i, c := range req {
+		if len(data) < itemHeader {
+			return errMalformed
+		}
+		code, dts, n := data[0], data[1], int(binary.BigEndian.Uint16(data[2:]))
+		data = data[itemHeader:]
+		if code != itemOK {
+			c.sp.err = ItemError(code)
+			continue
+		}
+		n = dataLen(dts, n)
+		if len(data) < n {
+			return errMalformed
+		}
+		if n != c.len {
+			c.sp.err = fmt.Errorf("s7: got %d bytes, want %d", n, c.len)
+		} else {
+			copy(c.sp.data[c.start-c.sp.start:], data[:n])
+		}
+		data = data[n:]
+		if n%2 == 1 && i < len(req)-1 && len(data) > 0 {
+			data = data[1:]
+		}
+	}
+	return nil
+}
+
+// tagValue 区间字节转为点位值
+func tagValue(t Tag, b []byte) ([]byte, error) {
+	switch t.Type {
+	case "BOOL":
+		return []byte{(b[0] >> t.Bit) & 1}, nil
+	case "STRING":
+		n := min(int(b[1]), int(b[0]), len(b)-2)
+		return append([]byte(nil), b[2:2+n]...), nil
+	}
+	return append([]byte(nil), b...), nil
+}
+
+// WriteTag 写单个变量：BOOL 按位写；STRING 写入长度头与字符；其余 data 须为 Size() 字节大端数据，
+// 超过单个 PDU 时分段写入
+func (a *S7Adapter) WriteTag(t Tag, data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.connectLocked(); err != nil {
+		return err
+	}
+	switch t.Type {
+	case "BOOL":
+		if len(data) == 0 {
+			return errors.New("s7: empty BOOL value")
+		}
+		v := byte(0)
+		for _, c := range data {
+			if c != 0 {
+				v = 1
+			}
+		}
+		return a.writeItem(tsBit, dtsBit, t, t.Offset, t.Bit, []byte{v})
+	case "STRING":
+		if len(data) > t.Count {
+			return fmt.Errorf("s7: string of %d bytes exceeds %s", len(data), t)
+		}
+		data = append([]byte{byte(t.Count), byte(len(data))}, data...)
+	default:
+		if len(data) != t.Size() {
+			return fmt.Errorf("s7: %s needs %d bytes, got %d", t, t.Size(), len(data))
+		}
+	}
+	maxData := a.pdu - reqHeader - 2 - itemSpec - itemHeader
+	for off := 0; off < len(data); off += maxData {
+		part := data[off:min(off+maxData, len(data))]
+		if err := a.writeItem(tsByte, dtsByte, t, t.Offset+off, 0, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *S7Adapter) writeItem(ts, dts byte, t Tag, offset, bit int, b []byte) error {
+	params := append([]byte{funcWrite, 1}, itemSpecBytes(ts, len(b), t.DB, t.Area, offset, bit)...)
+	bits := len(b) * 8
+	if dts == dtsBit {
+		bits = len(b)
+	}
+	data := append([]byte{0, dts, byte(bits >> 8), byte(bits)}, b...)
+	_, p, resp, err := a.roundTrip(params, data)
+	if err != nil {
+		return err
+	}
+	if len(p) < 2 || p[0] != funcWrite || len(resp) < 1 {
+		return errMalformed
+	}
+	if resp[0] != itemOK {
+		return fmt.Errorf("%s: %w", t, ItemError(resp[0]))
+	}
+	return nil
+}
+
+// ReadMulti 实现 protocol.MultiReader：一次读取多个点位，按 PDU 打包
+func (a *S7Adapter) ReadMulti(params []map[string]interface{}) ([][]byte, []error) {
+	vals := make([][]byte, len(params))
+	errs := make([]error, len(params))
+	var tags []Tag
+	var pos []int
+	for i, p := range params {
+		t, err := tagParam(p)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		tags = append(tags, t)
+		pos = append(pos, i)
+	}
+	v, e := a.ReadTags(tags)
+	for j, i := range pos {
+		vals[i], errs[i] = v[j], e[j]
+	}
+	return vals, errs
+}
+
+// Read 读取单个点位，params["address"] 为 S7 地址
+func (a *S7Adapter) Read(params map[string]interface{}) ([]byte, error) {
+	t, err := tagParam(params)
+	if err != nil {
+		return nil, err
+	}
+	v, errs := a.ReadTags([]Tag{t})
+	return v[0], errs[0]
+}
+
+func (a *S7Adapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("s7: BatchRead not supported")
+}
+
+// Write 写点位，地址取 params["address"]（缺省时用 address 参数）；data 与 Read 的字节格式一致
+func (a *S7Adapter) Write(address string, data []byte, params map[string]interface{}) error {
+	if s, ok := params["address"].(string); ok && s != "" {
+		address = s
+	}
+	t, err := ParseTag(address)
+	if err != nil {
+		return err
+	}
+	return a.WriteTag(t, data)
+}
+
+func (a *S7Adapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("s7: WriteModbus not supported")
+}
+
+func tagParam(params map[string]interface{}) (Tag, error) {
+	s, _ := params["address"].(string)
+	if s == "" {
+		return Tag{}, errors.New("s7: missing address")
+	}
+	return ParseTag(s)
+}
+
+// toInt 兼容 int/float64/字符串配置（字符串支持 0x 前缀）
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
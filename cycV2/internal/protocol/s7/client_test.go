@@ -0,0 +1,196 @@
+package s7
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"cycV2/internal/protocol"
+)
+
+func TestParseTag(t *testing.T) {
+	for in, want := range map[string]Tag{
+		"DB10.DBD4:REAL":      {Area: AreaDB, DB: 10, Offset: 4, Type: "REAL", Count: 1},
+		"db1.dbx0.3":          {Area: AreaDB, DB: 1, Offset: 0, Bit: 3, Type: "BOOL", Count: 1},
+		"M10.2":               {Area: AreaM, Offset: 10, Bit: 2, Type: "BOOL", Count: 1},
+		"MW20:INT":            {Area: AreaM, Offset: 20, Type: "INT", Count: 1},
+		"IB0":                 {Area: AreaI, Offset: 0, Type: "BYTE", Count: 1},
+		"A1.7":                {Area: AreaQ, Offset: 1, Bit: 7, Type: "BOOL", Count: 1},
+		"DB5.DBB0:STRING[20]": {Area: AreaDB, DB: 5, Type: "STRING", Count: 20},
+		"DB5.DBB30:STRING":    {Area: AreaDB, DB: 5, Offset: 30, Type: "STRING", Count: 254},
+		"DB2.DBB0:BYTE[16]":   {Area: AreaDB, DB: 2, Type: "BYTE", Count: 16},
+		"DB2.DBD8:LREAL":      {Area: AreaDB, DB: 2, Offset: 8, Type: "LREAL", Count: 1},
+	} {
+		got, err := ParseTag(in)
+		if err != nil || got != want {
+			t.Errorf("ParseTag(%q) = %+v, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", "DB0.DBW0", "DB1.DBW0.1", "DB1.DBX0", "M10", "MW2:BOOL", "DB1.DBX0.1:INT", "DB1.DBW0:FOO", "DB1.DBB0:STRING[300]", "X1.0", "DB1.DBX0.1:BOOL[2]"} {
+		if tag, err := ParseTag(in); err == nil {
+			t.Errorf("ParseTag(%q) accepted: %+v", in, tag)
+		}
+	}
+	if s := (Tag{Area: AreaDB, DB: 5, Type: "STRING", Count: 20}).String(); s != "DB5.DBB0:STRING[20]" {
+		t.Errorf("String() = %s", s)
+	}
+}
+
+func newTestClient(t *testing.T, s *stubPLC, extra map[string]interface{}) *S7Adapter {
+	t.Helper()
+	cfg := map[string]interface{}{"address": s.addr(), "timeoutMs": 2000}
+	for k, v := range extra {
+		cfg[k] = v
+	}
+	a, err := NewS7Client(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Disconnect() })
+	return a
+}
+
+func TestS7ConnectTSAP(t *testing.T) {
+	s := newStubPLC(t, 480, 0x0102) // rack0/slot2 PG 连接
+	if err := newTestClient(t, s, nil).Connect(); err == nil {
+		t.Error("connected with wrong TSAP")
+	}
+	a := newTestClient(t, s, map[string]interface{}{"cpu": "S7-300"})
+	if err := a.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if a.PDUSize() != 480 {
+		t.Errorf("negotiated PDU = %d", a.PDUSize())
+	}
+	if err := newTestClient(t, s, map[string]interface{}{"remoteTSAP": "0x0102"}).Connect(); err != nil {
+		t.Errorf("explicit TSAP: %v", err)
+	}
+	if _, err := NewS7Client(map[string]interface{}{"address": "10.0.0.1", "pduSize": 100}); err == nil {
+		t.Error("pduSize 100 accepted")
+	}
+	if _, err := protocol.GetAdapter("s7", map[string]interface{}{"address": "10.0.0.1", "connectionType": "op"}); err != nil {
+		t.Errorf("registry: %v", err)
+	}
+}
+
+func TestS7ReadWrite(t *testing.T) {
+	s := newStubPLC(t, 240, 0x0101)
+	a := newTestClient(t, s, nil)
+	write := func(addr string, data []byte) {
+		t.Helper()
+		if err := a.Write("", data, map[string]interface{}{"address": addr}); err != nil {
+			t.Fatalf("write %s: %v", addr, err)
+		}
+	}
+	read := func(addr string) []byte {
+		t.Helper()
+		b, err := a.Read(map[string]interface{}{"address": addr})
+		if err != nil {
+			t.Fatalf("read %s: %v", addr, err)
+		}
+		return b
+	}
+
+	write("DB10.DBD4:REAL", binary.BigEndian.AppendUint32(nil, math.Float32bits(-12.5)))
+	if b := read("DB10.DBD4:REAL"); math.Float32frombits(binary.BigEndian.Uint32(b)) != -12.5 {
+		t.Errorf("REAL = % X", b)
+	}
+	write("MW20:INT", []byte{0xFF, 0x38})
+	if b := read("MB21"); !bytes.Equal(b, []byte{0x38}) {
+		t.Errorf("MB21 = % X", b)
+	}
+	// 位写只改目标位
+	write("M10.2", []byte{1})
+	write("M10.0", []byte{1})
+	write("M10.0", []byte{0})
+	if b := read("MB10"); !bytes.Equal(b, []byte{0x04}) {
+		t.Errorf("MB10 = % X", b)
+	}
+	if b := read("M10.2"); !bytes.Equal(b, []byte{1}) {
+		t.Errorf("M10.2 = % X", b)
+	}
+	write("Q0.1", []byte{1})
+	if b := read("QB0"); !bytes.Equal(b, []byte{0x02}) {
+		t.Errorf("QB0 = % X", b)
+	}
+	write("DB10.DBB100:STRING[10]", []byte("PCS-01"))
+	if b := read("DB10.DBB100:STRING[10]"); string(b) != "PCS-01" {
+		t.Errorf("STRING = %q", b)
+	}
+	if err := a.Write("DB10.DBB100:STRING[4]", []byte("toolong"), nil); err == nil {
+		t.Error("long string accepted")
+	}
+	if err := a.Write("", []byte{1}, map[string]interface{}{"address": "DB10.DBD0:REAL"}); err == nil {
+		t.Error("short REAL accepted")
+	}
+
+	// 超过 PDU 的读写分段
+	big := make([]byte, 600)
+	for i := range big {
+		big[i] = byte(i * 7)
+	}
+	write("DB10.DBB200:BYTE[600]", big)
+	reads, _ := s.counts()
+	if b := read("DB10.DBB200:BYTE[600]"); !bytes.Equal(b, big) {
+		t.Error("large read mismatch")
+	}
+	if r, _ := s.counts(); r-reads < 3 {
+		t.Errorf("600 bytes read in %d requests with PDU 240", r-reads)
+	}
+
+	// 变量级错误
+	var ie ItemError
+	if _, err := a.Read(map[string]interface{}{"address": "DB99.DBW0"}); !errors.As(err, &ie) || ie != 0x0A {
+		t.Errorf("missing DB = %v", err)
+	}
+	if err := a.Write("IW20", []byte{0, 1}, nil); !errors.As(err, &ie) || ie != 0x05 {
+		t.Errorf("out of range write = %v", err)
+	}
+}
+
+func TestS7MultiRead(t *testing.T) {
+	s := newStubPLC(t, 240, 0x0101)
+	a := newTestClient(t, s, nil)
+	for i := 0; i < 25; i++ {
+		if err := a.Write(fmt.Sprintf("DB10.DBW%d", i*20), []byte{0, byte(i)}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.Write("DB10.DBD482:DINT", []byte{0, 0, 1, 0}, nil)
+
+	var params []map[string]interface{}
+	// 25 个相距 20 字节的字：不合并，按每请求 19 项装入 2 个请求
+	for i := 0; i < 25; i++ {
+		params = append(params, map[string]interface{}{"address": fmt.Sprintf("DB10.DBW%d:INT", i*20)})
+	}
+	// 紧邻与重叠的变量合并进已有区间；错误点位不影响其余
+	params = append(params,
+		map[string]interface{}{"address": "DB10.DBB1"},
+		map[string]interface{}{"address": "DB10.DBX1.0"},
+		map[string]interface{}{"address": "DB10.DBD482:DINT"},
+		map[string]interface{}{"address": "DB99.DBW0"},
+		map[string]interface{}{"address": "bogus"},
+		map[string]interface{}{"address": "MB0"},
+	)
+	r0, i0 := s.counts()
+	vals, errs := a.ReadMulti(params)
+	r1, i1 := s.counts()
+	for i := 0; i < 25; i++ {
+		if errs[i] != nil || !bytes.Equal(vals[i], []byte{0, byte(i)}) {
+			t.Errorf("DBW%d = % X, %v", i*20, vals[i], errs[i])
+		}
+	}
+	if !bytes.Equal(vals[25], []byte{0}) || !bytes.Equal(vals[26], []byte{0}) || !bytes.Equal(vals[27], []byte{0, 0, 1, 0}) {
+		t.Errorf("merged values = % X", vals[25:28])
+	}
+	if errs[28] == nil || errs[29] == nil || errs[30] != nil {
+		t.Errorf("errors = %v", errs[28:])
+	}
+	// 25 个区间中 DBW480 与 DBD482 合并，另加 DB99、MB0：共 27 项、2 个请求
+	if r1-r0 != 2 || i1-i0 != 27 {
+		t.Errorf("%d requests / %d items, want 2 / 27", r1-r0, i1-i0)
+	}
+}
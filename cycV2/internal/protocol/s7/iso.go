@@ -0,0 +1,221 @@
+// Package s7 纯Go实现的西门子 S7comm 客户端（ISO-on-TCP，RFC1006）：
+// COTP 建链、PDU 大小协商、DB/M/I/Q 区读写，多变量读按 PDU 大小合并打包
+package s7
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// COTP TPDU 类型
+const (
+	tpduCR = 0xE0
+	tpduCC = 0xD0
+	tpduDR = 0x80
+	tpduDT = 0xF0
+)
+
+// S7 报文类型（ROSCTR）
+const (
+	rosJob     = 0x01
+	rosAck     = 0x02
+	rosAckData = 0x03
+)
+
+// S7 功能码
+const (
+	funcSetup = 0xF0
+	funcRead  = 0x04
+	funcWrite = 0x05
+)
+
+// 请求项传输尺寸
+const (
+	tsBit  = 0x01
+	tsByte = 0x02
+)
+
+// 数据区传输尺寸
+const (
+	dtsNull   = 0x00
+	dtsBit    = 0x03
+	dtsByte   = 0x04 // 长度单位为位
+	dtsInt    = 0x05
+	dtsReal   = 0x07
+	dtsOctets = 0x09
+)
+
+// 报文开销：读请求头10+参数2+每项12；读响应头12+参数2+每项4；写请求另加数据项头4
+const (
+	reqHeader  = 10
+	respHeader = 12
+	itemSpec   = 12
+	itemHeader = 4
+	maxItems   = 20 // 单个请求的变量数上限（S7-300 等小 CPU 的限制）
+)
+
+var errMalformed = errors.New("s7: malformed PDU")
+
+// Error 报文头中的错误类别/代码
+type Error struct {
+	Class, Code byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("s7: error class 0x%02X code 0x%02X", e.Class, e.Code)
+}
+
+// ItemError 变量级返回码
+type ItemError byte
+
+var itemErrorNames = map[ItemError]string{
+	0x01: "hardware fault",
+	0x03: "access denied",
+	0x05: "address out of range",
+	0x06: "data type not supported",
+	0x07: "data type inconsistent",
+	0x0A: "object does not exist",
+}
+
+func (e ItemError) Error() string {
+	if s, ok := itemErrorNames[e]; ok {
+		return "s7: " + s
+	}
+	return fmt.Sprintf("s7: item return code 0x%02X", byte(e))
+}
+
+const itemOK = 0xFF
+
+func writeTPKT(w io.Writer, payload []byte) error {
+	b := make([]byte, 4, 4+len(payload))
+	b[0] = 3
+	binary.BigEndian.PutUint16(b[2:], uint16(4+len(payload)))
+	_, err := w.Write(append(b, payload...))
+	return err
+}
+
+func readTPKT(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(hdr[2:]))
+	if hdr[0] != 3 || n < 7 {
+		return nil, fmt.Errorf("s7: bad TPKT header % X", hdr)
+	}
+	b := make([]byte, n-4)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+// cotpConnect 构造 CR/CC：TPDU 大小 1024，主叫/被叫 TSAP
+func cotpConnect(code byte, dstRef, srcRef, localTSAP, remoteTSAP uint16) []byte {
+	b := []byte{0, code, byte(dstRef >> 8), byte(dstRef), byte(srcRef >> 8), byte(srcRef), 0,
+		0xC0, 1, 0x0A,
+		0xC1, 2, byte(localTSAP >> 8), byte(localTSAP),
+		0xC2, 2, byte(remoteTSAP >> 8), byte(remoteTSAP)}
+	b[0] = byte(len(b) - 1)
+	return b
+}
+
+// cotpDial 建立传输连接
+func cotpDial(conn net.Conn, localTSAP, remoteTSAP uint16) error {
+	if err := writeTPKT(conn, cotpConnect(tpduCR, 0, 1, localTSAP, remoteTSAP)); err != nil {
+		return err
+	}
+	resp, err := readTPKT(conn)
+	if err != nil {
+		return err
+	}
+	if len(resp) < 7 || resp[1] != tpduCC {
+		return fmt.Errorf("s7: COTP connection refused (TSAP %04X/%04X)", localTSAP, remoteTSAP)
+	}
+	return nil
+}
+
+// writeDT 发送一个 S7 PDU（协商后的 PDU 不超过 TPDU 大小，无需分段）
+func writeDT(w io.Writer, pdu []byte) error {
+	return writeTPKT(w, append([]byte{2, tpduDT, 0x80}, pdu...))
+}
+
+// readDT 读取并重组一个 S7 PDU
+func readDT(r io.Reader) ([]byte, error) {
+	var msg []byte
+	for {
+		tpdu, err := readTPKT(r)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case len(tpdu) >= 3 && tpdu[1] == tpduDT:
+			msg = append(msg, tpdu[tpdu[0]+1:]...)
+			if tpdu[2]&0x80 != 0 {
+				return msg, nil
+			}
+		case len(tpdu) >= 2 && tpdu[1] == tpduDR:
+			return nil, errors.New("s7: transport disconnected by peer")
+		default:
+			return nil, fmt.Errorf("s7: unexpected TPDU % X", tpdu[:min(len(tpdu), 8)])
+		}
+	}
+}
+
+// s7Header 作业报文头
+func s7Header(ref uint16, params, data []byte) []byte {
+	b := make([]byte, reqHeader, reqHeader+len(params)+len(data))
+	b[0], b[1] = 0x32, rosJob
+	binary.BigEndian.PutUint16(b[4:], ref)
+	binary.BigEndian.PutUint16(b[6:], uint16(len(params)))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(data)))
+	return append(append(b, params...), data...)
+}
+
+// ackData 解析应答报文，返回 PDU 参考号、参数区与数据区
+func ackData(pdu []byte) (ref uint16, params, data []byte, err error) {
+	if len(pdu) < reqHeader || pdu[0] != 0x32 {
+		return 0, nil, nil, errMalformed
+	}
+	ref = binary.BigEndian.Uint16(pdu[4:])
+	pl, dl := int(binary.BigEndian.Uint16(pdu[6:])), int(binary.BigEndian.Uint16(pdu[8:]))
+	hl := reqHeader
+	if pdu[1] == rosAck || pdu[1] == rosAckData {
+		hl = respHeader
+		if len(pdu) < hl {
+			return 0, nil, nil, errMalformed
+		}
+		if pdu[10] != 0 || pdu[11] != 0 {
+			return ref, nil, nil, &Error{Class: pdu[10], Code: pdu[11]}
+		}
+	}
+	if pdu[1] != rosAckData {
+		return ref, nil, nil, fmt.Errorf("s7: unexpected ROSCTR 0x%02X", pdu[1])
+	}
+	if len(pdu) < hl+pl+dl {
+		return 0, nil, nil, errMalformed
+	}
+	return ref, pdu[hl : hl+pl], pdu[hl+pl : hl+pl+dl], nil
+}
+
+// setupParams 通信设置：并发作业数1/1，期望 PDU 大小
+func setupParams(pduSize int) []byte {
+	return []byte{funcSetup, 0, 0, 1, 0, 1, byte(pduSize >> 8), byte(pduSize)}
+}
+
+// itemSpecBytes 变量描述（S7ANY）
+func itemSpecBytes(ts byte, length int, db int, area Area, offset, bit int) []byte {
+	addr := offset*8 + bit
+	return []byte{0x12, 0x0A, 0x10, ts, byte(length >> 8), byte(length), byte(db >> 8), byte(db),
+		byte(area), byte(addr >> 16), byte(addr >> 8), byte(addr)}
+}
+
+// dataLen 数据项长度字段换算为字节数
+func dataLen(dts byte, n int) int {
+	switch dts {
+	case dtsBit, dtsByte, dtsInt:
+		return (n + 7) / 8
+	}
+	return n
+}
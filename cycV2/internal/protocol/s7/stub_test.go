@@ -0,0 +1,185 @@
+package s7
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+)
+
+// 测试用 S7 服务器桩：校验被叫 TSAP，协商 PDU，按存储区/数据块保存字节，
+// 支持读变量（多项）与写变量（字节/位），并统计读请求数与项数
+
+type areaKey struct {
+	area Area
+	db   int
+}
+
+type stubPLC struct {
+	ln   net.Listener
+	pdu  int
+	tsap uint16
+
+	mu       sync.Mutex
+	mem      map[areaKey][]byte
+	reads    int // 读请求数
+	readItem int // 读项总数
+}
+
+func newStubPLC(t *testing.T, pdu int, tsap uint16) *stubPLC {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubPLC{ln: ln, pdu: pdu, tsap: tsap, mem: map[areaKey][]byte{
+		{AreaDB, 10}: make([]byte, 1000),
+		{AreaM, 0}:   make([]byte, 64),
+		{AreaI, 0}:   make([]byte, 16),
+		{AreaQ, 0}:   make([]byte, 16),
+	}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *stubPLC) addr() string { return s.ln.Addr().String() }
+
+func (s *stubPLC) counts() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads, s.readItem
+}
+
+func (s *stubPLC) serve(conn net.Conn) {
+	defer conn.Close()
+	cr, err := readTPKT(conn)
+	if err != nil || len(cr) < 7 || cr[1] != tpduCR {
+		return
+	}
+	var remote uint16
+	for p := cr[7:]; len(p) >= 2 && len(p) >= 2+int(p[1]); p = p[2+int(p[1]):] {
+		if p[0] == 0xC2 && p[1] == 2 {
+			remote = binary.BigEndian.Uint16(p[2:])
+		}
+	}
+	if remote != s.tsap {
+		writeTPKT(conn, []byte{6, tpduDR, 0, 1, 0, 0, 0x80})
+		return
+	}
+	if writeTPKT(conn, cotpConnect(tpduCC, 1, 0, 0x0100, remote)) != nil {
+		return
+	}
+	for {
+		pdu, err := readDT(conn)
+		if err != nil || len(pdu) < reqHeader || pdu[1] != rosJob {
+			return
+		}
+		pl := int(binary.BigEndian.Uint16(pdu[6:]))
+		params, data := pdu[reqHeader:reqHeader+pl], pdu[reqHeader+pl:]
+		var rp, rd []byte
+		switch params[0] {
+		case funcSetup:
+			n := min(int(binary.BigEndian.Uint16(params[6:])), s.pdu)
+			rp = append(append([]byte(nil), params[:6]...), byte(n>>8), byte(n))
+		case funcRead:
+			rp, rd = s.read(params)
+		case funcWrite:
+			rp, rd = s.write(params, data)
+		}
+		resp := make([]byte, respHeader)
+		resp[0], resp[1] = 0x32, rosAckData
+		copy(resp[4:6], pdu[4:6])
+		binary.BigEndian.PutUint16(resp[6:], uint16(len(rp)))
+		binary.BigEndian.PutUint16(resp[8:], uint16(len(rd)))
+		if len(resp)+len(rp)+len(rd) > s.pdu {
+			resp[10], resp[11] = 0x85, 0x00 // PDU 超长
+			resp[6], resp[7], resp[8], resp[9] = 0, 0, 0, 0
+			rp, rd = nil, nil
+		}
+		if writeDT(conn, append(append(resp, rp...), rd...)) != nil {
+			return
+		}
+	}
+}
+
+// locate 解析变量描述，返回所在存储区字节与起始偏移
+func (s *stubPLC) locate(spec []byte) (mem []byte, ts byte, n, offset, bit int, code byte) {
+	ts, n = spec[3], int(binary.BigEndian.Uint16(spec[4:]))
+	db := int(binary.BigEndian.Uint16(spec[6:]))
+	addr := int(spec[9])<<16 | int(spec[10])<<8 | int(spec[11])
+	key := areaKey{Area(spec[8]), 0}
+	if key.area == AreaDB {
+		key.db = db
+	}
+	mem, ok := s.mem[key]
+	if !ok {
+		return nil, ts, n, 0, 0, 0x0A
+	}
+	offset, bit = addr>>3, addr&7
+	if ts == tsBit {
+		n = 1
+	}
+	if offset+n > len(mem) {
+		return nil, ts, n, 0, 0, 0x05
+	}
+	return mem, ts, n, offset, bit, itemOK
+}
+
+func (s *stubPLC) read(params []byte) ([]byte, []byte) {
+	count := int(params[1])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+	s.readItem += count
+	var out []byte
+	for i := 0; i < count; i++ {
+		mem, _, n, off, _, code := s.locate(params[2+i*itemSpec:])
+		if code != itemOK {
+			out = append(out, code, dtsNull, 0, 0)
+			continue
+		}
+		out = append(out, itemOK, dtsByte, byte(n*8>>8), byte(n*8))
+		out = append(out, mem[off:off+n]...)
+		if n%2 == 1 && i < count-1 {
+			out = append(out, 0)
+		}
+	}
+	return params[:2], out
+}
+
+func (s *stubPLC) write(params, data []byte) ([]byte, []byte) {
+	count := int(params[1])
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []byte
+	for i := 0; i < count; i++ {
+		mem, ts, n, off, bit, code := s.locate(params[2+i*itemSpec:])
+		dn := dataLen(data[1], int(binary.BigEndian.Uint16(data[2:])))
+		v := data[itemHeader : itemHeader+dn]
+		data = data[itemHeader+dn:]
+		if len(data) > 0 && dn%2 == 1 {
+			data = data[1:]
+		}
+		if code == itemOK && dn != n {
+			code = 0x07
+		}
+		if code == itemOK {
+			if ts == tsBit {
+				mem[off] = mem[off]&^(1<<bit) | (v[0]&1)<<bit
+			} else {
+				copy(mem[off:], v)
+			}
+		}
+		out = append(out, code)
+	}
+	return params[:2], out
+}
@@ -0,0 +1,440 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	"cycV2/internal/protocol"
+)
+
+// 协议注册
+func init() {
+	protocol.Register("snmp", NewSNMPAdapter)
+}
+
+// SNMPAdapter SNMP v1/v2c/v3 轮询适配器，实现 protocol.ProtocolAdapter 与 protocol.Subscriber。
+// 点位参数 oid；walk 为 true 时遍历子树并按 aggregate 汇总；rate 为 true 时计数器换算为每秒速率。
+// 配置 trapListen 后接收 Trap：携带点位 OID 的变量绑定、以及 trapOid 匹配的事件经 Subscribe 推送
+type SNMPAdapter struct {
+	target         string
+	port           uint16
+	version        gosnmp.SnmpVersion
+	community      string
+	timeout        time.Duration
+	retries        int
+	maxRepetitions uint32
+	msgFlags       gosnmp.SnmpV3MsgFlags
+	usm            *gosnmp.UsmSecurityParameters
+	contextName    string
+	trapListen     string
+	trapCommunity  string
+
+	mu       sync.Mutex // gosnmp 客户端非并发安全，请求串行
+	client   *gosnmp.GoSNMP
+	rates    map[string]rateState
+	trapMu   sync.Mutex
+	hub      *trapHub
+	trapSubs int
+	events   map[string][]byte // Trap OID → 最近一次事件
+	subs     protocol.Subscriptions[string]
+}
+
+// NewSNMPAdapter 工厂函数
+// cfg: address(host[:161]), version(1|2c|3，默认2c), community(默认public), timeoutMs, retries, maxRepetitions,
+//
+//	v3: username, securityLevel(noAuthNoPriv|authNoPriv|authPriv), authProtocol(MD5|SHA|SHA224|SHA256|SHA384|SHA512),
+//	authPassword, privProtocol(DES|AES|AES192|AES256|AES192C|AES256C), privPassword, contextName
+//	Trap: trapListen("0.0.0.0:162"，同一地址的多台设备共用监听), trapCommunity(默认同 community)
+func NewSNMPAdapter(cfg map[string]interface{}) (protocol.ProtocolAdapter, error) {
+	return NewSNMPClient(cfg)
+}
+
+// NewSNMPClient 创建客户端，返回具体类型以便直接 GET/GETBULK/WALK
+func NewSNMPClient(cfg map[string]interface{}) (*SNMPAdapter, error) {
+	addr, _ := cfg["address"].(string)
+	if addr == "" {
+		return nil, errors.New("snmp: missing address")
+	}
+	a := &SNMPAdapter{
+		target:         addr,
+		port:           161,
+		version:        gosnmp.Version2c,
+		community:      "public",
+		timeout:        2 * time.Second,
+		retries:        1,
+		maxRepetitions: 20,
+		rates:          make(map[string]rateState),
+		events:         make(map[string][]byte),
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("snmp: invalid port in %q", addr)
+		}
+		a.target, a.port = host, uint16(p)
+	}
+	if v, ok := cfg["community"].(string); ok && v != "" {
+		a.community = v
+	}
+	a.trapCommunity = a.community
+	if v, ok := cfg["trapCommunity"].(string); ok && v != "" {
+		a.trapCommunity = v
+	}
+	if v, ok := toInt(cfg["timeoutMs"]); ok && v > 0 {
+		a.timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(cfg["retries"]); ok && v >= 0 {
+		a.retries = v
+	}
+	if v, ok := toInt(cfg["maxRepetitions"]); ok && v > 0 {
+		a.maxRepetitions = uint32(v)
+	}
+	a.trapListen, _ = cfg["trapListen"].(string)
+
+	version := fmt.Sprint(cfg["version"])
+	switch version {
+	case "1", "v1":
+		a.version = gosnmp.Version1
+	case "<nil>", "", "2", "2c", "v2c":
+		a.version = gosnmp.Version2c
+	case "3", "v3":
+		a.version = gosnmp.Version3
+		if err := a.parseV3(cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("snmp: unsupported version %q", version)
+	}
+	return a, nil
+}
+
+// parseV3 USM 用户参数
+func (a *SNMPAdapter) parseV3(cfg map[string]interface{}) error {
+	user, _ := cfg["username"].(string)
+	if user == "" {
+		return errors.New("snmp: v3 requires username")
+	}
+	a.usm = &gosnmp.UsmSecurityParameters{UserName: user, AuthenticationProtocol: gosnmp.NoAuth, PrivacyProtocol: gosnmp.NoPriv}
+	a.contextName, _ = cfg["contextName"].(string)
+	level, _ := cfg["securityLevel"].(string)
+	switch strings.ToLower(level) {
+	case "", "noauthnopriv":
+		a.msgFlags = gosnmp.NoAuthNoPriv
+	case "authnopriv":
+		a.msgFlags = gosnmp.AuthNoPriv
+	case "authpriv":
+		a.msgFlags = gosnmp.AuthPriv
+	default:
+		return fmt.Errorf("snmp: invalid securityLevel %q", level)
+	}
+	if a.msgFlags&gosnmp.AuthNoPriv != 0 {
+		name, _ := cfg["authProtocol"].(string)
+		auth, ok := map[string]gosnmp.SnmpV3AuthProtocol{
+			"MD5": gosnmp.MD5, "SHA": gosnmp.SHA, "SHA224": gosnmp.SHA224,
+			"SHA256": gosnmp.SHA256, "SHA384": gosnmp.SHA384, "SHA512": gosnmp.SHA512,
+		}[strings.ToUpper(name)]
+		if !ok {
+			return fmt.Errorf("snmp: invalid authProtocol %q", name)
+		}
+		a.usm.AuthenticationProtocol = auth
+		a.usm.AuthenticationPassphrase, _ = cfg["authPassword"].(string)
+		if len(a.usm.AuthenticationPassphrase) < 8 {
+			return errors.New("snmp: authPassword must be at least 8 characters")
+		}
+	}
+	if a.msgFlags&gosnmp.AuthPriv == gosnmp.AuthPriv {
+		name, _ := cfg["privProtocol"].(string)
+		priv, ok := map[string]gosnmp.SnmpV3PrivProtocol{
+			"DES": gosnmp.DES, "AES": gosnmp.AES, "AES192": gosnmp.AES192,
+			"AES256": gosnmp.AES256, "AES192C": gosnmp.AES192C, "AES256C": gosnmp.AES256C,
+		}[strings.ToUpper(name)]
+		if !ok {
+			return fmt.Errorf("snmp: invalid privProtocol %q", name)
+		}
+		a.usm.PrivacyProtocol = priv
+		a.usm.PrivacyPassphrase, _ = cfg["privPassword"].(string)
+		if len(a.usm.PrivacyPassphrase) < 8 {
+			return errors.New("snmp: privPassword must be at least 8 characters")
+		}
+	}
+	return nil
+}
+
+// params 按配置生成 gosnmp 参数（轮询与 v3 Trap 解码共用）
+func (a *SNMPAdapter) params() *gosnmp.GoSNMP {
+	g := &gosnmp.GoSNMP{
+		Target:         a.target,
+		Port:           a.port,
+		Version:        a.version,
+		Community:      a.community,
+		Timeout:        a.timeout,
+		Retries:        a.retries,
+		MaxRepetitions: a.maxRepetitions,
+		MaxOids:        gosnmp.MaxOids,
+	}
+	if a.version == gosnmp.Version3 {
+		g.SecurityModel = gosnmp.UserSecurityModel
+		g.MsgFlags = a.msgFlags
+		g.SecurityParameters = a.usm.Copy()
+		g.ContextName = a.contextName
+	}
+	return g
+}
+
+// Connect 建立 UDP 套接字；SNMP 无连接，目标不可达在首次请求时才发现
+func (a *SNMPAdapter) Connect() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.connectLocked()
+}
+
+func (a *SNMPAdapter) connectLocked() error {
+	if a.client != nil {
+		return nil
+	}
+	g := a.params()
+	if err := g.Connect(); err != nil {
+		return fmt.Errorf("snmp: connect %s: %w", a.target, err)
+	}
+	a.client = g
+	return nil
+}
+
+// Disconnect 关闭套接字并退出 Trap 监听
+func (a *SNMPAdapter) Disconnect() error {
+	a.mu.Lock()
+	if a.client != nil {
+		a.client.Conn.Close()
+		a.client = nil
+	}
+	a.mu.Unlock()
+	a.trapMu.Lock()
+	defer a.trapMu.Unlock()
+	if a.hub != nil {
+		a.hub.leave(a)
+		a.hub, a.trapSubs = nil, 0
+	}
+	return nil
+}
+
+// do 串行执行一次请求；超时等网络错误后重建套接字
+func (a *SNMPAdapter) do(f func(g *gosnmp.GoSNMP) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.connectLocked(); err != nil {
+		return err
+	}
+	err := f(a.client)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		a.client.Conn.Close()
+		a.client = nil
+	}
+	return err
+}
+
+// Get 读取一组 OID
+func (a *SNMPAdapter) Get(oids ...string) ([]gosnmp.SnmpPDU, error) {
+	var vars []gosnmp.SnmpPDU
+	err := a.do(func(g *gosnmp.GoSNMP) error {
+		resp, err := g.Get(oids)
+		if err != nil {
+			return err
+		}
+		if resp.Error != gosnmp.NoError {
+			return fmt.Errorf("snmp: get: %s at index %d", resp.Error, resp.ErrorIndex)
+		}
+		vars = resp.Variables
+		return nil
+	})
+	return vars, err
+}
+
+// GetBulk GETBULK 请求（v2c/v3）
+func (a *SNMPAdapter) GetBulk(oids []string, nonRepeaters uint8, maxRepetitions uint32) ([]gosnmp.SnmpPDU, error) {
+	if a.version == gosnmp.Version1 {
+		return nil, errors.New("snmp: GETBULK requires v2c or v3")
+	}
+	var vars []gosnmp.SnmpPDU
+	err := a.do(func(g *gosnmp.GoSNMP) error {
+		resp, err := g.GetBulk(oids, nonRepeaters, maxRepetitions)
+		if err != nil {
+			return err
+		}
+		if resp.Error != gosnmp.NoError {
+			return fmt.Errorf("snmp: getbulk: %s at index %d", resp.Error, resp.ErrorIndex)
+		}
+		vars = resp.Variables
+		return nil
+	})
+	return vars, err
+}
+
+// Walk 遍历子树；v2c/v3 使用 GETBULK，v1 使用 GETNEXT
+func (a *SNMPAdapter) Walk(root string) ([]gosnmp.SnmpPDU, error) {
+	var vars []gosnmp.SnmpPDU
+	err := a.do(func(g *gosnmp.GoSNMP) error {
+		var err error
+		if a.version == gosnmp.Version1 {
+			vars, err = g.WalkAll(root)
+		} else {
+			vars, err = g.BulkWalkAll(root)
+		}
+		return err
+	})
+	return vars, err
+}
+
+// Set 写单个 OID，value 须为与 typ 对应的 Go 值（见 pduValue）
+func (a *SNMPAdapter) Set(oid string, typ gosnmp.Asn1BER, value interface{}) error {
+	return a.do(func(g *gosnmp.GoSNMP) error {
+		resp, err := g.Set([]gosnmp.SnmpPDU{{Name: oid, Type: typ, Value: value}})
+		if err != nil {
+			return err
+		}
+		if resp.Error != gosnmp.NoError {
+			return fmt.Errorf("snmp: set %s: %s", oid, resp.Error)
+		}
+		return nil
+	})
+}
+
+// Read 读取点位，返回大端字节（格式见 valueBytes）。
+// params: oid；type 校验返回类型；walk+aggregate(sum|avg|min|max|count) 汇总子树；
+// rate 换算计数器每秒增量（float64），rateFactor 为倍率（如字节→比特为8）；
+// trapOid 点位返回最近一次 Trap 事件（JSON）
+func (a *SNMPAdapter) Read(params map[string]interface{}) ([]byte, error) {
+	if trapOID, ok := params["trapOid"].(string); ok && trapOID != "" {
+		a.trapMu.Lock()
+		ev, ok := a.events[normalizeOID(trapOID)]
+		a.trapMu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("snmp: no trap %s received", trapOID)
+		}
+		return ev, nil
+	}
+	oid, err := oidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	rate, _ := params["rate"].(bool)
+	if walk, _ := params["walk"].(bool); walk {
+		vars, err := a.Walk(oid)
+		if err != nil {
+			return nil, err
+		}
+		agg, _ := params["aggregate"].(string)
+		res, err := aggregate(vars, agg)
+		if err != nil {
+			return nil, fmt.Errorf("snmp: walk %s: %w", oid, err)
+		}
+		if rate {
+			if !res.counter {
+				return nil, fmt.Errorf("snmp: walk %s: rate needs counter values", oid)
+			}
+			return a.rateBytes("walk:"+agg+":"+oid, res.sum, 64, params)
+		}
+		return res.bytes(), nil
+	}
+	vars, err := a.Get(oid)
+	if err != nil {
+		return nil, err
+	}
+	if len(vars) != 1 {
+		return nil, fmt.Errorf("snmp: get %s returned %d variables", oid, len(vars))
+	}
+	pdu := vars[0]
+	if want, ok := params["type"].(string); ok && want != "" {
+		t, err := parseType(want)
+		if err != nil {
+			return nil, err
+		}
+		if pdu.Type != t && !isNull(pdu.Type) {
+			return nil, fmt.Errorf("snmp: %s is %s, expected %s", oid, pdu.Type, t)
+		}
+	}
+	if rate {
+		v, bits, ok := counterValue(pdu)
+		if !ok {
+			return nil, fmt.Errorf("snmp: %s is %s, rate needs a counter", oid, pdu.Type)
+		}
+		return a.rateBytes(oid, v, bits, params)
+	}
+	return valueBytes(pdu)
+}
+
+// BatchRead 不支持
+func (a *SNMPAdapter) BatchRead(funcCode string, startAddr, quantity uint16) ([]byte, error) {
+	return nil, errors.New("snmp: BatchRead not supported")
+}
+
+// Write SET：address 为 OID（为空时取 params["oid"]），params["type"] 指定值类型，
+// data 为大端字节（整数类型 1/2/4/8 字节）或字符串内容
+func (a *SNMPAdapter) Write(address string, data []byte, params map[string]interface{}) error {
+	oid := address
+	if oid == "" {
+		var err error
+		if oid, err = oidParam(params); err != nil {
+			return err
+		}
+	}
+	name, _ := params["type"].(string)
+	if name == "" {
+		return fmt.Errorf("snmp: write %s: missing type", oid)
+	}
+	t, err := parseType(name)
+	if err != nil {
+		return err
+	}
+	v, err := pduValue(t, data)
+	if err != nil {
+		return fmt.Errorf("snmp: write %s: %w", oid, err)
+	}
+	return a.Set(normalizeOID(oid), t, v)
+}
+
+// WriteModbus 不支持
+func (a *SNMPAdapter) WriteModbus(funcCode string, addr uint16, value []byte) error {
+	return errors.New("snmp: WriteModbus not supported")
+}
+
+func oidParam(params map[string]interface{}) (string, error) {
+	oid, _ := params["oid"].(string)
+	if oid == "" {
+		return "", errors.New("snmp: missing oid")
+	}
+	return normalizeOID(oid), nil
+}
+
+// normalizeOID 统一为带前导点的数字形式
+func normalizeOID(oid string) string {
+	oid = strings.TrimSpace(oid)
+	if !strings.HasPrefix(oid, ".") {
+		oid = "." + oid
+	}
+	return oid
+}
+
+// toInt 兼容 int/float64/字符串配置
+func toInt(raw interface{}) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+		return int(n), err == nil
+	default:
+		return 0, false
+	}
+}
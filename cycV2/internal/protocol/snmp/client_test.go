@@ -0,0 +1,399 @@
+package snmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	"cycV2/internal/protocol"
+)
+
+const (
+	oidDescr    = ".1.3.6.1.2.1.1.1.0"
+	oidBattery  = ".1.3.6.1.2.1.33.1.2.4.0"
+	oidInOctets = ".1.3.6.1.2.1.2.2.1.10"
+	oidHCOctets = ".1.3.6.1.2.1.31.1.1.1.6.1"
+)
+
+// testAgent 最小 SNMP v1/v2c 代理：GET/GETNEXT/GETBULK/SET
+type testAgent struct {
+	conn *net.UDPConn
+	mu   sync.Mutex
+	vars map[string]gosnmp.SnmpPDU
+}
+
+func newTestAgent(t *testing.T) *testAgent {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ag := &testAgent{conn: conn, vars: make(map[string]gosnmp.SnmpPDU)}
+	ag.set(oidDescr, gosnmp.OctetString, []byte("UPS-3000"))
+	ag.set(oidBattery, gosnmp.Integer, 95)
+	ag.set(oidInOctets+".1", gosnmp.Counter32, uint(4294967000))
+	ag.set(oidInOctets+".2", gosnmp.Counter32, uint(100))
+	ag.set(oidHCOctets, gosnmp.Counter64, uint64(1<<40))
+	go ag.serve()
+	t.Cleanup(func() { conn.Close() })
+	return ag
+}
+
+func (ag *testAgent) addr() string { return ag.conn.LocalAddr().String() }
+
+func (ag *testAgent) set(oid string, typ gosnmp.Asn1BER, v interface{}) {
+	ag.mu.Lock()
+	defer ag.mu.Unlock()
+	ag.vars[oid] = gosnmp.SnmpPDU{Name: oid, Type: typ, Value: v}
+}
+
+func oidLess(a, b string) bool {
+	pa, pb := strings.Split(strings.Trim(a, "."), "."), strings.Split(strings.Trim(b, "."), ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		x, _ := strconv.Atoi(pa[i])
+		y, _ := strconv.Atoi(pb[i])
+		if x != y {
+			return x < y
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+// next 字典序后继
+func (ag *testAgent) next(oid string, v1 bool) (gosnmp.SnmpPDU, bool) {
+	keys := make([]string, 0, len(ag.vars))
+	for k := range ag.vars {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return oidLess(keys[i], keys[j]) })
+	for _, k := range keys {
+		if oidLess(oid, k) {
+			return ag.vars[k], true
+		}
+	}
+	if v1 {
+		return gosnmp.SnmpPDU{}, false
+	}
+	return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView}, true
+}
+
+func (ag *testAgent) serve() {
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := ag.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req, err := (&gosnmp.GoSNMP{}).SnmpDecodePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		v1 := req.Version == gosnmp.Version1
+		var out []gosnmp.SnmpPDU
+		ag.mu.Lock()
+		switch req.PDUType {
+		case gosnmp.GetRequest:
+			for _, v := range req.Variables {
+				pdu, ok := ag.vars[normalizeOID(v.Name)]
+				if !ok {
+					if v1 {
+						req.Error, req.ErrorIndex = gosnmp.NoSuchName, 1
+						pdu = v
+					} else {
+						pdu = gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchObject}
+					}
+				}
+				out = append(out, pdu)
+			}
+		case gosnmp.GetNextRequest:
+			for _, v := range req.Variables {
+				pdu, ok := ag.next(normalizeOID(v.Name), v1)
+				if !ok {
+					req.Error, req.ErrorIndex, pdu = gosnmp.NoSuchName, 1, v
+				}
+				out = append(out, pdu)
+			}
+		case gosnmp.GetBulkRequest:
+			for i, v := range req.Variables {
+				oid := normalizeOID(v.Name)
+				reps := int(req.MaxRepetitions)
+				if i < int(req.NonRepeaters) {
+					reps = 1
+				}
+				for r := 0; r < reps; r++ {
+					pdu, _ := ag.next(oid, false)
+					out = append(out, pdu)
+					if pdu.Type == gosnmp.EndOfMibView {
+						break
+					}
+					oid = pdu.Name
+				}
+			}
+		case gosnmp.SetRequest:
+			for _, v := range req.Variables {
+				v.Name = normalizeOID(v.Name)
+				ag.vars[v.Name] = v
+				out = append(out, v)
+			}
+		}
+		ag.mu.Unlock()
+		req.PDUType, req.Variables = gosnmp.GetResponse, out
+		if msg, err := req.MarshalMsg(); err == nil {
+			ag.conn.WriteToUDP(msg, from)
+		}
+	}
+}
+
+func newClient(t *testing.T, cfg map[string]interface{}) *SNMPAdapter {
+	t.Helper()
+	cfg["timeoutMs"] = 1000
+	a, err := NewSNMPClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Disconnect() })
+	return a
+}
+
+func TestSNMPReadWalkSet(t *testing.T) {
+	ag := newTestAgent(t)
+	a := newClient(t, map[string]interface{}{"address": ag.addr()})
+
+	b, err := a.Read(map[string]interface{}{"oid": "1.3.6.1.2.1.1.1.0"})
+	if err != nil || string(b) != "UPS-3000" {
+		t.Fatalf("descr = %q, %v", b, err)
+	}
+	b, err = a.Read(map[string]interface{}{"oid": oidBattery, "type": "Integer"})
+	if err != nil || !bytes.Equal(b, []byte{0, 0, 0, 95}) {
+		t.Fatalf("battery = % X, %v", b, err)
+	}
+	if _, err := a.Read(map[string]interface{}{"oid": oidBattery, "type": "gauge"}); err == nil {
+		t.Error("type mismatch accepted")
+	}
+	if _, err := a.Read(map[string]interface{}{"oid": ".1.3.6.1.9.9.0"}); err == nil {
+		t.Error("missing object read")
+	}
+	b, err = a.Read(map[string]interface{}{"oid": oidHCOctets})
+	if err != nil || binary.BigEndian.Uint64(b) != 1<<40 {
+		t.Fatalf("counter64 = % X, %v", b, err)
+	}
+
+	// WALK 汇总（GETBULK）
+	f64 := func(b []byte) float64 { return math.Float64frombits(binary.BigEndian.Uint64(b)) }
+	b, err = a.Read(map[string]interface{}{"oid": oidInOctets, "walk": true, "aggregate": "sum"})
+	if err != nil || f64(b) != 4294967100 {
+		t.Fatalf("walk sum = %v, %v", b, err)
+	}
+	b, err = a.Read(map[string]interface{}{"oid": oidInOctets, "walk": true, "aggregate": "count"})
+	if err != nil || f64(b) != 2 {
+		t.Fatalf("walk count = %v, %v", b, err)
+	}
+	vars, err := a.GetBulk([]string{oidDescr}, 0, 2)
+	if err != nil || len(vars) != 2 || vars[0].Name != oidInOctets+".1" {
+		t.Fatalf("getbulk = %+v, %v", vars, err)
+	}
+
+	// 计数器速率：首个样本预热，Counter32 回绕补偿，Counter64 减小视为复位
+	rate := map[string]interface{}{"oid": oidInOctets + ".1", "rate": true, "rateFactor": 8}
+	if _, err := a.Read(rate); !errors.Is(err, errRateWarmup) {
+		t.Fatalf("first rate sample = %v", err)
+	}
+	backdate := func(key string) {
+		a.mu.Lock()
+		s := a.rates[key]
+		s.at = s.at.Add(-2 * time.Second)
+		a.rates[key] = s
+		a.mu.Unlock()
+	}
+	backdate(oidInOctets + ".1")
+	ag.set(oidInOctets+".1", gosnmp.Counter32, uint(200))
+	b, err = a.Read(rate)
+	if err != nil || math.Abs(f64(b)-496*8/2.0) > 20 {
+		t.Fatalf("wrapped rate = %v, %v", f64(b), err)
+	}
+	hc := map[string]interface{}{"oid": oidHCOctets, "rate": true}
+	a.Read(hc)
+	ag.set(oidHCOctets, gosnmp.Counter64, uint64(5))
+	backdate(oidHCOctets)
+	if _, err := a.Read(hc); err == nil || !strings.Contains(err.Error(), "reset") {
+		t.Errorf("counter64 reset = %v", err)
+	}
+	if _, err := a.Read(map[string]interface{}{"oid": oidBattery, "rate": true}); err == nil {
+		t.Error("rate on integer accepted")
+	}
+
+	// SET
+	if err := a.Write("", []byte{0, 50}, map[string]interface{}{"oid": oidBattery, "type": "integer"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := a.Read(map[string]interface{}{"oid": oidBattery}); !bytes.Equal(b, []byte{0, 0, 0, 50}) {
+		t.Errorf("after set = % X", b)
+	}
+	if err := a.Write(oidDescr, []byte("x"), nil); err == nil {
+		t.Error("write without type accepted")
+	}
+
+	// v1 使用 GETNEXT 遍历
+	v1 := newClient(t, map[string]interface{}{"address": ag.addr(), "version": "1"})
+	vars, err = v1.Walk(oidInOctets)
+	if err != nil || len(vars) != 2 {
+		t.Fatalf("v1 walk = %+v, %v", vars, err)
+	}
+	if _, err := v1.GetBulk([]string{oidDescr}, 0, 2); err == nil {
+		t.Error("v1 getbulk accepted")
+	}
+}
+
+func TestSNMPConfig(t *testing.T) {
+	a, err := NewSNMPClient(map[string]interface{}{
+		"address": "10.0.0.5", "version": "3", "username": "ops", "securityLevel": "authPriv",
+		"authProtocol": "sha256", "authPassword": "authpass1", "privProtocol": "AES", "privPassword": "privpass1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := a.params()
+	usm := p.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if p.Port != 161 || p.MsgFlags != gosnmp.AuthPriv || usm.AuthenticationProtocol != gosnmp.SHA256 || usm.PrivacyProtocol != gosnmp.AES {
+		t.Errorf("v3 params = %+v %+v", p, usm)
+	}
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"address": "10.0.0.5", "version": "4"},
+		{"address": "10.0.0.5", "version": "3"},
+		{"address": "10.0.0.5", "version": "3", "username": "ops", "securityLevel": "authNoPriv", "authProtocol": "MD5", "authPassword": "short"},
+		{"address": "10.0.0.5:x"},
+	} {
+		if _, err := NewSNMPClient(cfg); err == nil {
+			t.Errorf("config %v accepted", cfg)
+		}
+	}
+	if _, err := protocol.GetAdapter("snmp", map[string]interface{}{"address": "10.0.0.5:1161"}); err != nil {
+		t.Errorf("registry: %v", err)
+	}
+}
+
+func freeUDPPort(t *testing.T) string {
+	t.Helper()
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	return c.LocalAddr().String()
+}
+
+func TestSNMPTrapSubscribe(t *testing.T) {
+	listen := freeUDPPort(t)
+	cfg := func(addr string) map[string]interface{} {
+		return map[string]interface{}{"address": addr, "community": "ro", "trapCommunity": "traps", "trapListen": listen}
+	}
+	a := newClient(t, cfg("127.0.0.1"))
+	other := newClient(t, cfg("127.0.0.2"))
+
+	got := make(chan protocol.PointUpdate, 10)
+	events := make(chan protocol.PointUpdate, 10)
+	var cancels []func()
+	for _, sub := range []struct {
+		a      *SNMPAdapter
+		params map[string]interface{}
+		ch     chan protocol.PointUpdate
+	}{
+		{a, map[string]interface{}{"oid": oidBattery}, got},
+		{a, map[string]interface{}{"trapOid": "1.3.6.1.4.1.9999.0.3"}, events},
+		{a, map[string]interface{}{"trapOid": "*"}, events},
+		{other, map[string]interface{}{"trapOid": "*"}, got},
+	} {
+		ch := sub.ch
+		cancel, err := sub.a.Subscribe(sub.params, func(u protocol.PointUpdate) { ch <- u })
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancels = append(cancels, cancel)
+	}
+	if _, err := a.Subscribe(map[string]interface{}{"oid": oidInOctets, "walk": true}, func(protocol.PointUpdate) {}); err == nil {
+		t.Error("walk point subscribed")
+	}
+	if _, err := newClient(t, map[string]interface{}{"address": "127.0.0.1"}).Subscribe(map[string]interface{}{"oid": oidBattery}, func(protocol.PointUpdate) {}); err == nil {
+		t.Error("subscribe without trapListen accepted")
+	}
+
+	host, port, _ := net.SplitHostPort(listen)
+	p, _ := strconv.Atoi(port)
+	send := func(version gosnmp.SnmpVersion, community string, trap gosnmp.SnmpTrap) {
+		t.Helper()
+		g := &gosnmp.GoSNMP{Target: host, Port: uint16(p), Version: version, Community: community, Timeout: time.Second}
+		if err := g.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer g.Conn.Close()
+		if _, err := g.SendTrap(trap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := func(ch chan protocol.PointUpdate) protocol.PointUpdate {
+		t.Helper()
+		select {
+		case u := <-ch:
+			return u
+		case <-time.After(2 * time.Second):
+			t.Fatal("no trap update")
+		}
+		return protocol.PointUpdate{}
+	}
+
+	// 团体名不符丢弃；v2c Trap 变量绑定推送到 oid 点位，事件推送到 "*"
+	send(gosnmp.Version2c, "public", gosnmp.SnmpTrap{Variables: []gosnmp.SnmpPDU{{Name: oidBattery, Type: gosnmp.Integer, Value: 1}}})
+	send(gosnmp.Version2c, "traps", gosnmp.SnmpTrap{Variables: []gosnmp.SnmpPDU{
+		{Name: snmpTrapOID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.9999.0.1"},
+		{Name: oidBattery, Type: gosnmp.Integer, Value: 12},
+	}})
+	if u := next(got); u.Err != nil || !bytes.Equal(u.Bytes, []byte{0, 0, 0, 12}) {
+		t.Fatalf("battery update = %+v", u)
+	}
+	var ev trapEvent
+	if u := next(events); json.Unmarshal(u.Bytes, &ev) != nil || ev.TrapOID != ".1.3.6.1.4.1.9999.0.1" || ev.Source != "127.0.0.1" || ev.Vars[oidBattery] != 12.0 {
+		t.Fatalf("event = %s", u.Bytes)
+	}
+
+	// v1 企业 Trap 转换为 enterprise.0.specific，同时匹配 trapOid 与 "*"
+	send(gosnmp.Version1, "traps", gosnmp.SnmpTrap{
+		Enterprise: ".1.3.6.1.4.1.9999", AgentAddress: "127.0.0.1", GenericTrap: 6, SpecificTrap: 3,
+		Variables: []gosnmp.SnmpPDU{{Name: oidDescr, Type: gosnmp.OctetString, Value: "on battery"}},
+	})
+	for i := 0; i < 2; i++ {
+		if u := next(events); json.Unmarshal(u.Bytes, &ev) != nil || ev.TrapOID != ".1.3.6.1.4.1.9999.0.3" || ev.Vars[oidDescr] != "on battery" {
+			t.Fatalf("v1 event = %s", u.Bytes)
+		}
+	}
+	if b, err := a.Read(map[string]interface{}{"trapOid": ".1.3.6.1.4.1.9999.0.3"}); err != nil || !bytes.Contains(b, []byte("on battery")) {
+		t.Errorf("last event = %s, %v", b, err)
+	}
+	select {
+	case u := <-got:
+		t.Errorf("unexpected update %+v", u)
+	default:
+	}
+
+	// 全部取消后释放监听
+	for _, cancel := range cancels {
+		cancel()
+	}
+	hubs.Lock()
+	n := len(hubs.m)
+	hubs.Unlock()
+	if n != 0 {
+		t.Errorf("%d trap listeners left open", n)
+	}
+}
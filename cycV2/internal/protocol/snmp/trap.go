@@ -0,0 +1,230 @@
+package snmp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	"cycV2/internal/protocol"
+)
+
+// SNMPv2-MIB::snmpTrapOID.0
+const snmpTrapOID = ".1.3.6.1.6.3.1.1.4.1.0"
+
+// v1 通用 Trap 对应的 v2 Trap OID（RFC 3584 3.1）
+const genericTrapPrefix = ".1.3.6.1.6.3.1.1.5."
+
+// trapHub 同一监听地址的 Trap 接收器，多台设备共用，按来源地址分发
+type trapHub struct {
+	addr     string
+	ln       *gosnmp.TrapListener
+	mu       sync.RWMutex
+	adapters map[*SNMPAdapter]struct{}
+}
+
+var hubs = struct {
+	sync.Mutex
+	m map[string]*trapHub
+}{m: make(map[string]*trapHub)}
+
+// joinTrapHub 加入监听，首个使用者负责启动监听；v3 Trap 按首个使用者的 USM 参数解码
+func joinTrapHub(addr string, a *SNMPAdapter) (*trapHub, error) {
+	hubs.Lock()
+	defer hubs.Unlock()
+	if h, ok := hubs.m[addr]; ok {
+		h.mu.Lock()
+		h.adapters[a] = struct{}{}
+		h.mu.Unlock()
+		return h, nil
+	}
+	h := &trapHub{addr: addr, ln: gosnmp.NewTrapListener(), adapters: map[*SNMPAdapter]struct{}{a: {}}}
+	h.ln.Params = a.params()
+	h.ln.OnNewTrap = h.dispatch
+	errCh := make(chan error, 1)
+	go func() { errCh <- h.ln.Listen(addr) }()
+	select {
+	case <-h.ln.Listening():
+	case err := <-errCh:
+		return nil, fmt.Errorf("snmp: trap listen %s: %w", addr, err)
+	case <-time.After(2 * time.Second):
+		h.ln.Close()
+		return nil, fmt.Errorf("snmp: trap listen %s: timeout", addr)
+	}
+	hubs.m[addr] = h
+	return h, nil
+}
+
+// leave 退出监听，最后一个使用者关闭套接字
+func (h *trapHub) leave(a *SNMPAdapter) {
+	hubs.Lock()
+	defer hubs.Unlock()
+	h.mu.Lock()
+	delete(h.adapters, a)
+	empty := len(h.adapters) == 0
+	h.mu.Unlock()
+	if empty && hubs.m[h.addr] == h {
+		delete(hubs.m, h.addr)
+		h.ln.Close()
+	}
+}
+
+func (h *trapHub) dispatch(p *gosnmp.SnmpPacket, from *net.UDPAddr) {
+	h.mu.RLock()
+	targets := make([]*SNMPAdapter, 0, len(h.adapters))
+	for a := range h.adapters {
+		if a.acceptsTrap(p, from) {
+			targets = append(targets, a)
+		}
+	}
+	h.mu.RUnlock()
+	for _, a := range targets {
+		a.onTrap(p, from)
+	}
+}
+
+// acceptsTrap 来源地址（或 v1 agent-addr）为本设备，且团体名匹配
+func (a *SNMPAdapter) acceptsTrap(p *gosnmp.SnmpPacket, from *net.UDPAddr) bool {
+	if p.Version != gosnmp.Version3 && p.Community != a.trapCommunity {
+		return false
+	}
+	if from != nil && a.isTarget(from.IP) {
+		return true
+	}
+	return p.AgentAddress != "" && a.isTarget(net.ParseIP(p.AgentAddress))
+}
+
+func (a *SNMPAdapter) isTarget(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if t := net.ParseIP(a.target); t != nil {
+		return t.Equal(ip)
+	}
+	addrs, err := net.LookupIP(a.target)
+	if err != nil {
+		return false
+	}
+	for _, t := range addrs {
+		if t.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trapEvent Trap 事件，作为字符串推送给 trapOid 点位
+type trapEvent struct {
+	TrapOID string                 `json:"trapOid"`
+	Source  string                 `json:"source"`
+	Vars    map[string]interface{} `json:"vars"`
+}
+
+// onTrap 变量绑定按 OID 推送；整条 Trap 转为事件推送给 trapOid 及 "*" 订阅
+func (a *SNMPAdapter) onTrap(p *gosnmp.SnmpPacket, from *net.UDPAddr) {
+	now := time.Now()
+	ev := trapEvent{Vars: make(map[string]interface{}, len(p.Variables))}
+	if from != nil {
+		ev.Source = from.IP.String()
+	}
+	if p.Version == gosnmp.Version1 {
+		if p.GenericTrap == 6 {
+			ev.TrapOID = normalizeOID(p.Enterprise) + ".0." + fmt.Sprint(p.SpecificTrap)
+		} else {
+			ev.TrapOID = genericTrapPrefix + fmt.Sprint(p.GenericTrap+1)
+		}
+	}
+	for _, pdu := range p.Variables {
+		name := normalizeOID(pdu.Name)
+		if name == snmpTrapOID {
+			if s, ok := pdu.Value.(string); ok {
+				ev.TrapOID = normalizeOID(s)
+			}
+			continue
+		}
+		b, err := valueBytes(pdu)
+		a.subs.Publish(name, protocol.PointUpdate{Bytes: b, Timestamp: now, Err: err})
+		if s, ok := pdu.Value.([]byte); ok {
+			ev.Vars[name] = string(s)
+		} else {
+			ev.Vars[name] = pdu.Value
+		}
+	}
+	if ev.TrapOID == "" {
+		return
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("[snmp] Trap %s 编码失败: %v", ev.TrapOID, err)
+		return
+	}
+	a.trapMu.Lock()
+	a.events[ev.TrapOID] = data
+	a.trapMu.Unlock()
+	u := protocol.PointUpdate{Bytes: data, Timestamp: now}
+	a.subs.Publish("trap:"+ev.TrapOID, u)
+	a.subs.Publish("trap:*", u)
+}
+
+// Subscribe 订阅 Trap：oid 点位接收 Trap 中携带该 OID 的变量值；
+// trapOid 点位（"*" 表示全部）接收 JSON 事件 {trapOid, source, vars}。
+// 需配置 trapListen，否则返回错误由采集管道回退为轮询
+func (a *SNMPAdapter) Subscribe(params map[string]interface{}, handler func(protocol.PointUpdate)) (func(), error) {
+	if a.trapListen == "" {
+		return nil, errors.New("snmp: trapListen not configured")
+	}
+	var key string
+	if trapOID, ok := params["trapOid"].(string); ok && trapOID != "" {
+		if trapOID != "*" {
+			trapOID = normalizeOID(trapOID)
+		}
+		key = "trap:" + trapOID
+	} else {
+		oid, err := oidParam(params)
+		if err != nil {
+			return nil, err
+		}
+		if walk, _ := params["walk"].(bool); walk {
+			return nil, errors.New("snmp: walk points are polled only")
+		}
+		if rate, _ := params["rate"].(bool); rate {
+			return nil, errors.New("snmp: rate points are polled only")
+		}
+		key = oid
+	}
+
+	a.trapMu.Lock()
+	if a.hub == nil {
+		h, err := joinTrapHub(a.trapListen, a)
+		if err != nil {
+			a.trapMu.Unlock()
+			return nil, err
+		}
+		a.hub = h
+	}
+	a.trapSubs++
+	a.trapMu.Unlock()
+
+	cancel := a.subs.Add(key, handler)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			a.trapMu.Lock()
+			defer a.trapMu.Unlock()
+			if a.hub == nil {
+				return
+			}
+			// 最后一个订阅取消后退出监听，热加载后旧适配器不再占用端口
+			if a.trapSubs--; a.trapSubs == 0 {
+				a.hub.leave(a)
+				a.hub = nil
+			}
+		})
+	}, nil
+}
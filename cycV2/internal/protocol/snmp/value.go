@@ -0,0 +1,285 @@
+package snmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// 类型名映射，用于点位 type 参数与 SET
+var typeNames = map[string]gosnmp.Asn1BER{
+	"integer":     gosnmp.Integer,
+	"integer32":   gosnmp.Integer,
+	"gauge":       gosnmp.Gauge32,
+	"gauge32":     gosnmp.Gauge32,
+	"counter":     gosnmp.Counter32,
+	"counter32":   gosnmp.Counter32,
+	"counter64":   gosnmp.Counter64,
+	"timeticks":   gosnmp.TimeTicks,
+	"uinteger32":  gosnmp.Uinteger32,
+	"octetstring": gosnmp.OctetString,
+	"string":      gosnmp.OctetString,
+	"ipaddress":   gosnmp.IPAddress,
+	"oid":         gosnmp.ObjectIdentifier,
+	"float":       gosnmp.OpaqueFloat,
+	"double":      gosnmp.OpaqueDouble,
+}
+
+func parseType(name string) (gosnmp.Asn1BER, error) {
+	t, ok := typeNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("snmp: unknown type %q", name)
+	}
+	return t, nil
+}
+
+// isNull 无值类型（对象/实例不存在、MIB 结束）
+func isNull(t gosnmp.Asn1BER) bool {
+	switch t {
+	case gosnmp.Null, gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+		return true
+	}
+	return false
+}
+
+// valueBytes 变量绑定转大端字节：
+// Integer→int32(4)，Gauge32/Counter32/TimeTicks/Uinteger32→uint32(4)，Counter64→uint64(8)，
+// OpaqueFloat→float32(4)，OpaqueDouble→float64(8)，OctetString 原样，IPAddress/OID 为字符串
+func valueBytes(pdu gosnmp.SnmpPDU) ([]byte, error) {
+	switch pdu.Type {
+	case gosnmp.Integer:
+		v, ok := pdu.Value.(int)
+		if !ok {
+			break
+		}
+		return binary.BigEndian.AppendUint32(nil, uint32(int32(v))), nil
+	case gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Uinteger32:
+		return binary.BigEndian.AppendUint32(nil, uint32(gosnmp.ToBigInt(pdu.Value).Uint64())), nil
+	case gosnmp.Counter64:
+		return binary.BigEndian.AppendUint64(nil, gosnmp.ToBigInt(pdu.Value).Uint64()), nil
+	case gosnmp.OpaqueFloat:
+		if v, ok := pdu.Value.(float32); ok {
+			return binary.BigEndian.AppendUint32(nil, math.Float32bits(v)), nil
+		}
+	case gosnmp.OpaqueDouble:
+		if v, ok := pdu.Value.(float64); ok {
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(v)), nil
+		}
+	case gosnmp.OctetString, gosnmp.BitString, gosnmp.Opaque:
+		if v, ok := pdu.Value.([]byte); ok {
+			return v, nil
+		}
+	case gosnmp.IPAddress, gosnmp.ObjectIdentifier:
+		if v, ok := pdu.Value.(string); ok {
+			return []byte(v), nil
+		}
+	case gosnmp.Boolean:
+		if v, ok := pdu.Value.(bool); ok && v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return nil, fmt.Errorf("snmp: %s: %s", pdu.Name, pdu.Type)
+	}
+	return nil, fmt.Errorf("snmp: %s: unsupported value %s %T", pdu.Name, pdu.Type, pdu.Value)
+}
+
+// numericValue 数值型变量转 float64
+func numericValue(pdu gosnmp.SnmpPDU) (float64, bool) {
+	switch pdu.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Uinteger32, gosnmp.Counter64:
+		f, _ := new(big.Float).SetInt(gosnmp.ToBigInt(pdu.Value)).Float64()
+		return f, true
+	case gosnmp.OpaqueFloat:
+		v, ok := pdu.Value.(float32)
+		return float64(v), ok
+	case gosnmp.OpaqueDouble:
+		v, ok := pdu.Value.(float64)
+		return v, ok
+	}
+	return 0, false
+}
+
+// counterValue 计数器取值与位宽
+func counterValue(pdu gosnmp.SnmpPDU) (uint64, int, bool) {
+	switch pdu.Type {
+	case gosnmp.Counter32:
+		return gosnmp.ToBigInt(pdu.Value).Uint64(), 32, true
+	case gosnmp.Counter64:
+		return gosnmp.ToBigInt(pdu.Value).Uint64(), 64, true
+	}
+	return 0, 0, false
+}
+
+// pduValue 写入字节按目标类型转换为 gosnmp 值
+func pduValue(t gosnmp.Asn1BER, data []byte) (interface{}, error) {
+	switch t {
+	case gosnmp.Integer:
+		switch len(data) {
+		case 1:
+			return int(int8(data[0])), nil
+		case 2:
+			return int(int16(binary.BigEndian.Uint16(data))), nil
+		case 4:
+			return int(int32(binary.BigEndian.Uint32(data))), nil
+		case 8:
+			v := int64(binary.BigEndian.Uint64(data))
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return nil, fmt.Errorf("integer %d out of range", v)
+			}
+			return int(v), nil
+		}
+	case gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Uinteger32:
+		if v, ok := beUint(data); ok {
+			if v > math.MaxUint32 {
+				return nil, fmt.Errorf("value %d out of range", v)
+			}
+			return uint32(v), nil
+		}
+	case gosnmp.Counter64:
+		if v, ok := beUint(data); ok {
+			return v, nil
+		}
+	case gosnmp.OpaqueFloat:
+		if len(data) == 4 {
+			return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+		}
+	case gosnmp.OpaqueDouble:
+		if len(data) == 8 {
+			return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+		}
+	case gosnmp.OctetString:
+		return data, nil
+	case gosnmp.IPAddress, gosnmp.ObjectIdentifier:
+		return string(data), nil
+	}
+	return nil, fmt.Errorf("cannot encode %d bytes as %s", len(data), t)
+}
+
+func beUint(data []byte) (uint64, bool) {
+	switch len(data) {
+	case 1:
+		return uint64(data[0]), true
+	case 2:
+		return uint64(binary.BigEndian.Uint16(data)), true
+	case 4:
+		return uint64(binary.BigEndian.Uint32(data)), true
+	case 8:
+		return binary.BigEndian.Uint64(data), true
+	}
+	return 0, false
+}
+
+// 计数器速率换算
+
+var errRateWarmup = errors.New("snmp: rate needs a second sample")
+
+type rateState struct {
+	value uint64
+	at    time.Time
+}
+
+// rateBytes 计算 key 对应计数器的每秒增量（float64 字节）。
+// Counter32 回绕按 2^32 补偿；Counter64 或汇总值减小视为设备重启，重新起算
+func (a *SNMPAdapter) rateBytes(key string, v uint64, bits int, params map[string]interface{}) ([]byte, error) {
+	now := time.Now()
+	a.mu.Lock()
+	prev, ok := a.rates[key]
+	a.rates[key] = rateState{value: v, at: now}
+	a.mu.Unlock()
+	if !ok {
+		return nil, errRateWarmup
+	}
+	dt := now.Sub(prev.at).Seconds()
+	if dt <= 0 {
+		return nil, errRateWarmup
+	}
+	var delta uint64
+	switch {
+	case v >= prev.value:
+		delta = v - prev.value
+	case bits == 32:
+		delta = v + (1 << 32) - prev.value
+	default:
+		return nil, fmt.Errorf("snmp: %s counter reset", key)
+	}
+	rate := float64(delta) / dt
+	if f, ok := params["rateFactor"].(float64); ok && f != 0 {
+		rate *= f
+	} else if n, ok := toInt(params["rateFactor"]); ok && n != 0 {
+		rate *= float64(n)
+	}
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(rate)), nil
+}
+
+// 子树汇总
+
+type walkResult struct {
+	value   float64
+	sum     uint64 // 计数器累计（供速率换算）
+	counter bool   // 全部为计数器
+}
+
+// bytes 汇总结果为 float64
+func (r walkResult) bytes() []byte {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(r.value))
+}
+
+// aggregate 对 WALK 结果汇总：sum(默认)|avg|min|max|count，非数值变量仅计入 count
+func aggregate(vars []gosnmp.SnmpPDU, fn string) (walkResult, error) {
+	res := walkResult{counter: true}
+	n := 0
+	for _, pdu := range vars {
+		if isNull(pdu.Type) {
+			continue
+		}
+		if fn == "count" {
+			n++
+			continue
+		}
+		v, ok := numericValue(pdu)
+		if !ok {
+			continue
+		}
+		if c, _, ok := counterValue(pdu); ok {
+			res.sum += c
+		} else {
+			res.counter = false
+		}
+		switch {
+		case n == 0:
+			res.value = v
+		case fn == "min":
+			res.value = math.Min(res.value, v)
+		case fn == "max":
+			res.value = math.Max(res.value, v)
+		default:
+			res.value += v
+		}
+		n++
+	}
+	switch fn {
+	case "count":
+		res.value, res.counter = float64(n), false
+		return res, nil
+	case "", "sum", "avg", "min", "max":
+	default:
+		return res, fmt.Errorf("unknown aggregate %q", fn)
+	}
+	if n == 0 {
+		return res, errors.New("no numeric values")
+	}
+	if fn == "avg" {
+		res.value /= float64(n)
+	}
+	if fn == "avg" || fn == "min" || fn == "max" {
+		res.counter = false
+	}
+	return res, nil
+}
@@ -0,0 +1,74 @@
+package protocol
+
+import (
+	"sync"
+	"time"
+)
+
+// PointUpdate 推送型协议异步上报的一个点位数据
+type PointUpdate struct {
+	Bytes     []byte    // 与 Read 返回格式一致
+	Timestamp time.Time // 数据时标（报文时标或接收时间）
+	Err       error     // 品质无效等
+}
+
+// Subscriber 订阅/推送型协议（CAN、IEC104、61850报告等）可选实现的接口。
+// params 与 Read 使用相同的点位参数；数据到达时在适配器接收协程中回调 handler，回调不得阻塞。
+// 返回的 cancel 用于取消订阅，可重复调用
+type Subscriber interface {
+	Subscribe(params map[string]interface{}, handler func(PointUpdate)) (cancel func(), err error)
+}
+
+// Subscriptions 适配器内部的订阅表，按协议自身的键（帧ID、IOA等）分组
+type Subscriptions[K comparable] struct {
+	mu   sync.RWMutex
+	next int
+	subs map[K]map[int]func(PointUpdate)
+}
+
+// Add 登记订阅，返回取消函数
+func (s *Subscriptions[K]) Add(key K, h func(PointUpdate)) (cancel func()) {
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[K]map[int]func(PointUpdate))
+	}
+	if s.subs[key] == nil {
+		s.subs[key] = make(map[int]func(PointUpdate))
+	}
+	id := s.next
+	s.next++
+	s.subs[key][id] = h
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		delete(s.subs[key], id)
+		if len(s.subs[key]) == 0 {
+			delete(s.subs, key)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Publish 回调某个键的全部订阅
+func (s *Subscriptions[K]) Publish(key K, u PointUpdate) {
+	s.mu.RLock()
+	hs := make([]func(PointUpdate), 0, len(s.subs[key]))
+	for _, h := range s.subs[key] {
+		hs = append(hs, h)
+	}
+	s.mu.RUnlock()
+	for _, h := range hs {
+		h(u)
+	}
+}
+
+// Keys 当前有订阅的键
+func (s *Subscriptions[K]) Keys() []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]K, 0, len(s.subs))
+	for k := range s.subs {
+		keys = append(keys, k)
+	}
+	return keys
+}